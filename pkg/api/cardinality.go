@@ -0,0 +1,117 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/NYTimes/gziphandler"
+	"github.com/timescale/promscale/pkg/pgmodel/cardinality"
+	"github.com/timescale/promscale/pkg/tenancy"
+)
+
+const defaultSeriesCreatedLookback = 24 * time.Hour
+
+// TSDBStatus returns the cardinality statistics of the series in the format of
+// the Prometheus /api/v1/status/tsdb endpoint.
+func TSDBStatus(conf *Config, reader *cardinality.Reader) http.Handler {
+	hf := corsWrapper(conf, tsdbStatusHandler(conf, reader))
+	return gziphandler.GzipHandler(hf)
+}
+
+// Cardinality returns the cardinality statistics of the series, along with the
+// series created per hour and the series count of each tenant.
+func Cardinality(conf *Config, reader *cardinality.Reader) http.Handler {
+	hf := corsWrapper(conf, cardinalityHandler(conf, reader))
+	return gziphandler.GzipHandler(hf)
+}
+
+func tsdbStatusHandler(conf *Config, reader *cardinality.Reader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseCardinalityQuery(conf, r)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+		status, err := reader.TSDBStatus(r.Context(), q)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err, "internal")
+			return
+		}
+		respond(w, http.StatusOK, status)
+	}
+}
+
+func cardinalityHandler(conf *Config, reader *cardinality.Reader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseCardinalityQuery(conf, r)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+		c, err := reader.Cardinality(r.Context(), q)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err, "internal")
+			return
+		}
+		respond(w, http.StatusOK, c)
+	}
+}
+
+// parseCardinalityQuery parses the parameters of the cardinality endpoints. The
+// statistics are restricted to the tenants the request is authorized for, and
+// to the tenant parameter if set.
+func parseCardinalityQuery(conf *Config, r *http.Request) (cardinality.Query, error) {
+	if err := r.ParseForm(); err != nil {
+		return cardinality.Query{}, err
+	}
+	q := cardinality.Query{Limit: cardinality.DefaultLimit}
+	if limit := r.FormValue("limit"); limit != "" {
+		var err error
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit <= 0 {
+			return q, fmt.Errorf("invalid parameter 'limit': must be a positive integer")
+		}
+		if q.Limit > cardinality.MaxLimit {
+			return q, fmt.Errorf("invalid parameter 'limit': must not exceed %d", cardinality.MaxLimit)
+		}
+	}
+
+	var err error
+	if q.End, err = parseTimeParam(r, "end", time.Now()); err != nil {
+		return q, err
+	}
+	if q.Start, err = parseTimeParam(r, "start", q.End.Add(-defaultSeriesCreatedLookback)); err != nil {
+		return q, err
+	}
+	if q.End.Before(q.Start) {
+		return q, fmt.Errorf("end timestamp must not be before start time")
+	}
+
+	var authConfig tenancy.AuthConfig
+	if conf.MultiTenancy != nil {
+		if ra, ok := conf.MultiTenancy.ReadAuthorizer().(tenancy.AuthConfig); ok {
+			authConfig = ra
+		}
+	}
+	q.Tenants, q.Restricted = tenancy.AuthorizedTenants(r.Context(), authConfig)
+	if tenant := r.FormValue("tenant"); tenant != "" {
+		authorized := !q.Restricted
+		for _, t := range q.Tenants {
+			authorized = authorized || t == tenant
+		}
+		q.Tenants, q.Restricted = nil, true
+		if authorized {
+			q.Tenants = []string{tenant}
+		}
+	}
+	// The valid tenants are unordered, sorting them keeps the cache key of
+	// the statistics stable.
+	sort.Strings(q.Tenants)
+	return q, nil
+}
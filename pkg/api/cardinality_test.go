@@ -0,0 +1,98 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/auth"
+	"github.com/timescale/promscale/pkg/pgmodel/cardinality"
+	"github.com/timescale/promscale/pkg/tenancy"
+)
+
+func TestParseCardinalityQuery(t *testing.T) {
+	mt, err := tenancy.NewAuthorizer(tenancy.NewSelectiveTenancyConfig([]string{"a", "b"}, false, true))
+	require.NoError(t, err)
+	cases := []struct {
+		name      string
+		params    url.Values
+		principal *auth.Principal
+		mt        tenancy.Authorizer
+		expected  cardinality.Query
+		fails     bool
+	}{
+		{
+			name:   "all tenants",
+			params: url.Values{"limit": {"5"}, "start": {"100"}, "end": {"200"}},
+			expected: cardinality.Query{
+				Limit: 5,
+				Start: time.Unix(100, 0).UTC(),
+				End:   time.Unix(200, 0).UTC(),
+			},
+		},
+		{
+			name:   "tenant",
+			params: url.Values{"end": {"86400"}, "tenant": {"a"}},
+			expected: cardinality.Query{
+				Limit:      cardinality.DefaultLimit,
+				Tenants:    []string{"a"},
+				Restricted: true,
+				Start:      time.Unix(0, 0).UTC(),
+				End:        time.Unix(86400, 0).UTC(),
+			},
+		},
+		{
+			name:   "valid tenants",
+			params: url.Values{"end": {"86400"}},
+			mt:     mt,
+			expected: cardinality.Query{
+				Limit:      cardinality.DefaultLimit,
+				Tenants:    []string{"a", "b"},
+				Restricted: true,
+				Start:      time.Unix(0, 0).UTC(),
+				End:        time.Unix(86400, 0).UTC(),
+			},
+		},
+		{
+			name:      "tenant of another principal",
+			params:    url.Values{"end": {"86400"}, "tenant": {"b"}},
+			principal: &auth.Principal{Name: "user", Tenants: []string{"a"}},
+			expected: cardinality.Query{
+				Limit:      cardinality.DefaultLimit,
+				Restricted: true,
+				Start:      time.Unix(0, 0).UTC(),
+				End:        time.Unix(86400, 0).UTC(),
+			},
+		},
+		{
+			name:   "limit too big",
+			params: url.Values{"limit": {"10001"}},
+			fails:  true,
+		},
+		{
+			name:   "end before start",
+			params: url.Values{"start": {"200"}, "end": {"100"}},
+			fails:  true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/status/cardinality?"+c.params.Encode(), nil)
+			r = r.WithContext(auth.NewContext(r.Context(), c.principal))
+			q, err := parseCardinalityQuery(&Config{MultiTenancy: c.mt}, r)
+			if c.fails {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expected, q)
+		})
+	}
+}
@@ -20,9 +20,14 @@ import (
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/util/httputil"
+	"github.com/timescale/promscale/pkg/auth"
+	"github.com/timescale/promscale/pkg/ha"
 	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/otlp"
+	deletePkg "github.com/timescale/promscale/pkg/pgmodel/delete"
 	pgmodel "github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/relabel"
 	"github.com/timescale/promscale/pkg/rules"
 	"github.com/timescale/promscale/pkg/tenancy"
 )
@@ -36,23 +41,40 @@ type Config struct {
 	AllowedOrigin    *regexp.Regexp
 	ReadOnly         bool
 	HighAvailability bool
+	HAClusterLabel   string
+	HAReplicaLabel   string
 	AdminAPIEnabled  bool
 	TelemetryPath    string
 
 	MultiTenancy tenancy.Authorizer
+	TenantLimits *tenancy.Limiter
+	Relabeler    *relabel.Relabeler
 	Rules        *rules.Manager
+	OTLPMetrics  *otlp.Translator
+	DeleteJobs   *deletePkg.JobManager
+	HAService    *ha.Service
 }
 
 func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
 	fs.BoolVar(&cfg.ReadOnly, "db.read-only", false, "Read-only mode for the connector. Operations related to writing or updating the database are disallowed. It is used when pointing the connector to a TimescaleDB read replica.")
 	fs.BoolVar(&cfg.HighAvailability, "metrics.high-availability", false, "Enable external_labels based HA.")
-	fs.BoolVar(&cfg.AdminAPIEnabled, "web.enable-admin-api", false, "Allow operations via API that are for advanced users. Currently, these operations are limited to deletion of series.")
+	fs.StringVar(&cfg.HAClusterLabel, "metrics.high-availability.cluster-label", ha.ClusterNameLabel, "Label naming the HA cluster of series.")
+	fs.StringVar(&cfg.HAReplicaLabel, "metrics.high-availability.replica-label", ha.ReplicaNameLabel, "Label naming the HA replica of series. It is removed from the ingested series.")
+	fs.BoolVar(&cfg.AdminAPIEnabled, "web.enable-admin-api", false, "Allow operations via API that are for advanced users. Currently, these operations are limited to deletion of series and administration of HA leases.")
 	fs.StringVar(&cfg.TelemetryPath, "web.telemetry-path", "/metrics", "Web endpoint for exposing Promscale's Prometheus metrics.")
 
 	return cfg
 }
 
 func Validate(cfg *Config) error {
+	if cfg.HighAvailability {
+		if !model.LabelName(cfg.HAClusterLabel).IsValid() || !model.LabelName(cfg.HAReplicaLabel).IsValid() {
+			return fmt.Errorf("invalid HA cluster label %q or replica label %q", cfg.HAClusterLabel, cfg.HAReplicaLabel)
+		}
+		if cfg.HAClusterLabel == cfg.HAReplicaLabel {
+			return fmt.Errorf("HA cluster and replica labels must be different")
+		}
+	}
 	return nil
 }
 
@@ -66,6 +88,38 @@ func corsWrapper(conf *Config, f http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// queryLimitWrapper rejects the queries exceeding the concurrent queries limit
+// of their tenants.
+func queryLimitWrapper(conf *Config, h http.Handler) http.Handler {
+	if conf.TenantLimits == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		done, err := conf.TenantLimits.StartQuery(queryLimitTenants(r)...)
+		if err != nil {
+			respondError(w, http.StatusTooManyRequests, err, "unavailable")
+			return
+		}
+		defer done()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// queryLimitTenants returns the tenants whose query limits apply to the request.
+// Principals restricted to some tenants are limited by their tenants, or by the
+// limits of the data without a tenant if they have none, whichever TENANT header
+// they send.
+func queryLimitTenants(r *http.Request) []string {
+	p := auth.PrincipalFromContext(r.Context())
+	switch {
+	case !p.RestrictsTenants():
+		return []string{tenancy.TenantFromRequest(r)}
+	case len(p.Tenants) == 0:
+		return []string{""}
+	}
+	return p.Tenants
+}
+
 func setResponseHeaders(w http.ResponseWriter, samples *promql.Result, isExemplar bool, warnings storage.Warnings) {
 	w.Header().Set("Content-Type", "application/json")
 	if len(warnings) > 0 {
@@ -11,13 +11,16 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/grafana/regexp"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
 
+	"github.com/timescale/promscale/pkg/auth"
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/tenancy"
 )
 
 func TestCORSWrapper(t *testing.T) {
@@ -119,6 +122,48 @@ func doCORSWrapperRequest(t *testing.T, queryHandler http.Handler, url, origin s
 	return w
 }
 
+func TestQueryLimitWrapper(t *testing.T) {
+	conf := &Config{TenantLimits: tenancy.NewLimiter(tenancy.LimitsConfig{Defaults: tenancy.Limits{MaxConcurrentQueries: 1}}, time.Minute, []string{"a", "b"})}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := queryLimitWrapper(conf, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("TENANT") == "a" {
+			close(started)
+			<-release
+		}
+	}))
+	query := func(tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost/api/v1/query", nil)
+		req.Header.Set("TENANT", tenant)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	done := make(chan struct{})
+	go func() {
+		query("a")
+		close(done)
+	}()
+	<-started
+
+	w := query("a")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Contains(t, w.Body.String(), "concurrent queries limit of 1 exceeded")
+	// Other tenants have their own slots.
+	require.Equal(t, http.StatusOK, query("b").Code)
+	// Principals restricted to tenants are limited by their tenants, whatever their header.
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/api/v1/query", nil)
+	req = req.WithContext(auth.NewContext(req.Context(), &auth.Principal{Name: "user-a", Tenants: []string{"a"}}))
+	req.Header.Set("TENANT", "b")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	close(release)
+	<-done
+}
+
 func TestMarshalExemplar(t *testing.T) {
 	tcs := []struct {
 		name        string
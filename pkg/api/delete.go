@@ -7,35 +7,33 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/NYTimes/gziphandler"
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgclient"
-	"github.com/timescale/promscale/pkg/pgmodel/common/errors"
 	deletePkg "github.com/timescale/promscale/pkg/pgmodel/delete"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/query/resultscache"
 )
 
-func Delete(conf *Config, client *pgclient.Client) http.Handler {
-	hf := corsWrapper(conf, deleteHandler(conf, client))
+func Delete(conf *Config, client *pgclient.Client, resultsCache *resultscache.Cache) http.Handler {
+	hf := corsWrapper(conf, deleteHandler(conf, client, resultsCache))
 	return gziphandler.GzipHandler(hf)
 }
 
-func deleteHandler(config *Config, client *pgclient.Client) http.HandlerFunc {
+func deleteHandler(config *Config, client *pgclient.Client, resultsCache *resultscache.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if config.ReadOnly {
-			respondError(w, http.StatusForbidden, fmt.Errorf("read-only connector cannot perform deletion"), "operation_not_permitted")
-			return
-		}
-		if !config.AdminAPIEnabled {
-			respondError(w, http.StatusForbidden, fmt.Errorf("deletion of series requires admin permissions. Use -web-enable-admin-api flag to allow deletion operations"), "operation_not_permitted")
+		if !deletionAllowed(w, config) {
 			return
 		}
 		var (
 			totalRowsDeleted int
 			metricsTouched   []string
 			seriesDeleted    []model.SeriesID
+			rowsDeleted      = make(map[string]int)
 		)
 		if err := r.ParseForm(); err != nil {
 			respondError(w, http.StatusBadRequest, err, "bad_data")
@@ -57,9 +55,17 @@ func deleteHandler(config *Config, client *pgclient.Client) http.HandlerFunc {
 			respondError(w, http.StatusBadRequest, err, "bad_data")
 			return
 		}
-		if start != model.MinTime || end != model.MaxTime {
-			log.Warn("msg", "Time based series deletion is unsupported.")
-			respondError(w, http.StatusBadRequest, errors.ErrTimeBasedDeletion, "bad_data")
+		if end.Before(start) {
+			respondError(w, http.StatusBadRequest, fmt.Errorf("end timestamp must not be before start time"), "bad_data")
+			return
+		}
+		async, err := parseAsyncParam(r)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+		if async {
+			submitDeleteJob(w, r, config, start, end)
 			return
 		}
 		for _, s := range r.Form["match[]"] {
@@ -72,29 +78,114 @@ func deleteHandler(config *Config, client *pgclient.Client) http.HandlerFunc {
 				continue
 			}
 			pgDelete := deletePkg.PgDelete{Conn: client.ReadOnlyConnection()}
-			touchedMetrics, deletedSeriesIDs, rowsDeleted, err := pgDelete.DeleteSeries(r.Context(), matchers, start, end)
+			touchedMetrics, deletedSeriesIDs, metricRowsDeleted, err := pgDelete.DeleteSeries(r.Context(), matchers, start, end)
+			metricsTouched = append(metricsTouched, touchedMetrics...)
+			seriesDeleted = append(seriesDeleted, deletedSeriesIDs...)
+			for metric, rows := range metricRowsDeleted {
+				rowsDeleted[metric] += rows
+				totalRowsDeleted += rows
+			}
+			if totalRowsDeleted > 0 && resultsCache != nil {
+				// The cached results may hold the deleted samples.
+				resultsCache.Invalidate()
+			}
 			if err != nil {
 				respondErrorWithMessage(w, http.StatusInternalServerError, err, "deleting_series",
-					fmt.Sprintf("partial delete: deleted %v series IDs from %v metrics, affecting %d rows in total.",
+					fmt.Sprintf("partial delete: deleted data of %v series IDs from %v metrics, affecting %d rows in total (rows per metric: %v).",
 						distinctValues(seriesDeleted),
 						distinctValues(metricsTouched),
 						totalRowsDeleted,
+						rowsDeleted,
 					),
 				)
 				return
 			}
-			metricsTouched = append(metricsTouched, touchedMetrics...)
-			seriesDeleted = append(seriesDeleted, deletedSeriesIDs...)
-			totalRowsDeleted += rowsDeleted
 		}
-		respond(w, http.StatusOK,
-			fmt.Sprintf("deleted %v series IDs from %v metrics, affecting %d rows in total.",
-				distinctValues(seriesDeleted),
-				distinctValues(metricsTouched),
-				totalRowsDeleted,
-			),
-		)
+		if !timeScoped(r) {
+			// Existing clients expect the message without a time range.
+			respond(w, http.StatusOK,
+				fmt.Sprintf("deleted %v series IDs from %v metrics, affecting %d rows in total.",
+					distinctValues(seriesDeleted),
+					distinctValues(metricsTouched),
+					totalRowsDeleted,
+				),
+			)
+			return
+		}
+		respond(w, http.StatusOK, deleteSeriesResponse{
+			SeriesIDs:        distinctValues(seriesDeleted),
+			Metrics:          distinctValues(metricsTouched),
+			RowsDeleted:      rowsDeleted,
+			TotalRowsDeleted: totalRowsDeleted,
+		})
+	}
+}
+
+// deletionAllowed responds with an error and returns false if the connector
+// is not allowed to delete data.
+func deletionAllowed(w http.ResponseWriter, config *Config) bool {
+	if config.ReadOnly {
+		respondError(w, http.StatusForbidden, fmt.Errorf("read-only connector cannot perform deletion"), "operation_not_permitted")
+		return false
 	}
+	if !config.AdminAPIEnabled {
+		respondError(w, http.StatusForbidden, fmt.Errorf("deletion of series requires admin permissions. Use -web-enable-admin-api flag to allow deletion operations"), "operation_not_permitted")
+		return false
+	}
+	return true
+}
+
+// timeScoped returns true if the delete request has a time range, which is
+// answered with a deleteSeriesResponse.
+func timeScoped(r *http.Request) bool {
+	return r.FormValue("start") != "" || r.FormValue("end") != ""
+}
+
+func parseAsyncParam(r *http.Request) (bool, error) {
+	val := r.FormValue("async")
+	if val == "" {
+		return false, nil
+	}
+	async, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("invalid async parameter %q: %w", val, err)
+	}
+	return async, nil
+}
+
+// submitDeleteJob creates an asynchronous delete job for the matchers of the
+// request, and responds with its ID.
+func submitDeleteJob(w http.ResponseWriter, r *http.Request, config *Config, start, end time.Time) {
+	matchers := r.Form["match[]"]
+	for _, s := range matchers {
+		if _, err := parser.ParseMetricSelector(s); err != nil {
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+	}
+	if config.DeleteJobs == nil {
+		respondError(w, http.StatusServiceUnavailable, fmt.Errorf("asynchronous deletion is not available"), "unavailable")
+		return
+	}
+	id, err := config.DeleteJobs.Submit(r.Context(), matchers, start, end)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err, "deleting_series")
+		return
+	}
+	respond(w, http.StatusAccepted, deleteJobSubmittedResponse{JobID: id})
+}
+
+type deleteJobSubmittedResponse struct {
+	JobID int64 `json:"job_id"`
+}
+
+// deleteSeriesResponse describes the data deleted by a time scoped
+// delete_series request.
+type deleteSeriesResponse struct {
+	SeriesIDs        []string       `json:"series_ids"`
+	Metrics          []string       `json:"metrics"`
+	RowsDeleted      map[string]int `json:"rows_deleted"`
+	TotalRowsDeleted int            `json:"total_rows_deleted"`
 }
 
 func distinctValues(slice interface{}) []string {
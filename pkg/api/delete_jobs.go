@@ -0,0 +1,124 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/NYTimes/gziphandler"
+	"github.com/gorilla/mux"
+	"github.com/timescale/promscale/pkg/pgmodel/common/errors"
+	deletePkg "github.com/timescale/promscale/pkg/pgmodel/delete"
+)
+
+// DeleteJobs lists the asynchronous delete jobs.
+func DeleteJobs(conf *Config) http.Handler {
+	hf := corsWrapper(conf, deleteJobsHandler(conf))
+	return gziphandler.GzipHandler(hf)
+}
+
+// DeleteJob returns the status and progress of an asynchronous delete job.
+func DeleteJob(conf *Config) http.Handler {
+	hf := corsWrapper(conf, deleteJobHandler(conf))
+	return gziphandler.GzipHandler(hf)
+}
+
+// CancelDeleteJob cancels an asynchronous delete job.
+func CancelDeleteJob(conf *Config) http.Handler {
+	hf := corsWrapper(conf, cancelDeleteJobHandler(conf))
+	return gziphandler.GzipHandler(hf)
+}
+
+func deleteJobsHandler(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !deleteJobsAvailable(w, conf) {
+			return
+		}
+		limit := deletePkg.DefaultListLimit
+		if s := r.FormValue("limit"); s != "" {
+			var err error
+			if limit, err = strconv.Atoi(s); err != nil || limit <= 0 {
+				respondError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q: must be a positive integer", s), "bad_data")
+				return
+			}
+		}
+		jobs, err := conf.DeleteJobs.List(r.Context(), limit)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err, "internal")
+			return
+		}
+		respond(w, http.StatusOK, jobs)
+	}
+}
+
+func deleteJobHandler(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !deleteJobsAvailable(w, conf) {
+			return
+		}
+		id, ok := parseDeleteJobID(w, r)
+		if !ok {
+			return
+		}
+		job, err := conf.DeleteJobs.Get(r.Context(), id)
+		if err != nil {
+			respondDeleteJobError(w, err)
+			return
+		}
+		respond(w, http.StatusOK, job)
+	}
+}
+
+func cancelDeleteJobHandler(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !deleteJobsAvailable(w, conf) {
+			return
+		}
+		id, ok := parseDeleteJobID(w, r)
+		if !ok {
+			return
+		}
+		job, err := conf.DeleteJobs.Cancel(r.Context(), id)
+		if err != nil {
+			respondDeleteJobError(w, err)
+			return
+		}
+		respond(w, http.StatusOK, job)
+	}
+}
+
+func deleteJobsAvailable(w http.ResponseWriter, conf *Config) bool {
+	if !deletionAllowed(w, conf) {
+		return false
+	}
+	if conf.DeleteJobs == nil {
+		respondError(w, http.StatusServiceUnavailable, fmt.Errorf("asynchronous deletion is not available"), "unavailable")
+		return false
+	}
+	return true
+}
+
+func parseDeleteJobID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	s := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Errorf("invalid job id: %s", s), "bad_data")
+		return 0, false
+	}
+	return id, true
+}
+
+func respondDeleteJobError(w http.ResponseWriter, err error) {
+	switch err {
+	case errors.ErrDeleteJobNotFound:
+		respondError(w, http.StatusNotFound, err, "not_found")
+	case errors.ErrDeleteJobFinished:
+		respondError(w, http.StatusConflict, err, "conflict")
+	default:
+		respondError(w, http.StatusInternalServerError, err, "internal")
+	}
+}
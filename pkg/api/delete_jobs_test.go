@@ -0,0 +1,134 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	deletePkg "github.com/timescale/promscale/pkg/pgmodel/delete"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+)
+
+func TestAsyncDelete(t *testing.T) {
+	cases := []struct {
+		name         string
+		values       url.Values
+		withJobs     bool
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "submitted",
+			values:       url.Values{"match[]": {`{__name__="foo"}`}, "async": {"true"}},
+			withJobs:     true,
+			expectedCode: http.StatusAccepted,
+			expectedBody: `{"status":"Accepted","data":{"job_id":7}}`,
+		},
+		{
+			name:         "invalid async",
+			values:       url.Values{"match[]": {`{__name__="foo"}`}, "async": {"maybe"}},
+			withJobs:     true,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid matcher",
+			values:       url.Values{"match[]": {`{__name__=}`}, "async": {"true"}},
+			withJobs:     true,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "jobs unavailable",
+			values:       url.Values{"match[]": {`{__name__="foo"}`}, "async": {"true"}},
+			expectedCode: http.StatusServiceUnavailable,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := &Config{AdminAPIEnabled: true}
+			if c.withJobs {
+				config.DeleteJobs = deletePkg.NewJobManager(model.NewSqlRecorder([]model.SqlQuery{
+					{
+						Sql:     "INSERT INTO _prom_catalog.delete_job (matchers, start_time, end_time) VALUES ($1, $2, $3) RETURNING id",
+						Args:    []interface{}{[]string{`{__name__="foo"}`}, (*time.Time)(nil), (*time.Time)(nil)},
+						Results: model.RowResults{{int64(7)}},
+					},
+				}, t))
+			}
+			req := httptest.NewRequest(http.MethodPost, "/delete_series", strings.NewReader(c.values.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			deleteHandler(config, nil, nil).ServeHTTP(w, req)
+			require.Equal(t, c.expectedCode, w.Code)
+			if c.expectedBody != "" {
+				require.JSONEq(t, c.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeleteJobHandlers(t *testing.T) {
+	cases := []struct {
+		name         string
+		config       *Config
+		handler      func(*Config) http.HandlerFunc
+		id           string
+		expectedCode int
+	}{
+		{
+			name:         "admin API disabled",
+			config:       &Config{DeleteJobs: deletePkg.NewJobManager(nil)},
+			handler:      deleteJobHandler,
+			id:           "1",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "read-only connector",
+			config:       &Config{ReadOnly: true, AdminAPIEnabled: true},
+			handler:      cancelDeleteJobHandler,
+			id:           "1",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "jobs unavailable",
+			config:       &Config{AdminAPIEnabled: true},
+			handler:      deleteJobsHandler,
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:         "invalid id",
+			config:       &Config{AdminAPIEnabled: true, DeleteJobs: deletePkg.NewJobManager(nil)},
+			handler:      deleteJobHandler,
+			id:           "abc",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid cancel id",
+			config:       &Config{AdminAPIEnabled: true, DeleteJobs: deletePkg.NewJobManager(nil)},
+			handler:      cancelDeleteJobHandler,
+			id:           "-",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/delete_jobs", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": c.id})
+			w := httptest.NewRecorder()
+			c.handler(c.config).ServeHTTP(w, req)
+			require.Equal(t, c.expectedCode, w.Code)
+			var resp errResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Equal(t, "error", resp.Status)
+		})
+	}
+}
@@ -44,26 +44,29 @@ func TestDelete(t *testing.T) {
 			name:         "normal_with_start",
 			matchers:     []string{`{__name__=~".*"}`},
 			start:        "1604311719000",
-			expectedCode: http.StatusBadRequest,
-			fails:        true,
-			message:      "time based series deletion is unsupported",
+			expectedCode: http.StatusOK,
 		},
 		{
 			name:         "normal_with_end",
 			matchers:     []string{`{__name__=~".*"}`},
 			end:          "1604311719000",
-			expectedCode: http.StatusBadRequest,
-			fails:        true,
-			message:      "time based series deletion is unsupported",
+			expectedCode: http.StatusOK,
 		},
 		{
 			name:         "normal_with_start_end",
 			matchers:     []string{`{__name__=~".*"}`},
 			start:        "1604311711000",
 			end:          "1604311719000",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "end_before_start",
+			matchers:     []string{`{__name__=~".*"}`},
+			start:        "1604311719000",
+			end:          "1604311711000",
 			expectedCode: http.StatusBadRequest,
 			fails:        true,
-			message:      "time based series deletion is unsupported",
+			message:      "end timestamp must not be before start time",
 		},
 		{
 			name:         "normal_with_start_end_without_matchers",
@@ -83,7 +86,7 @@ func TestDelete(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			handler := deleteHandler(config, nil)
+			handler := deleteHandler(config, nil, nil)
 			vals := constructRequestValues(tc.start, tc.end, tc.matchers)
 			// Post delete request.
 			wPost := doPostDeleteRequest(t, handler, vals)
@@ -130,6 +133,43 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestDeleteResponse(t *testing.T) {
+	config := &Config{
+		ReadOnly:        false,
+		AdminAPIEnabled: true,
+	}
+	handler := deleteHandler(config, nil, nil)
+
+	var response struct {
+		Data json.RawMessage `json:"data"`
+	}
+	read := func(resp *http.Response) {
+		t.Helper()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Unexpected HTTP status code received: got %d wanted %d", resp.StatusCode, http.StatusOK)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Requests without a time range keep the message of earlier versions.
+	read(doPostDeleteRequest(t, handler, constructRequestValues("", "", []string{`{__name__="foo"}`})))
+	var message string
+	if err := json.Unmarshal(response.Data, &message); err != nil {
+		t.Fatalf("expected a message, got %s: %v", response.Data, err)
+	}
+	if message != "deleted [] series IDs from [] metrics, affecting 0 rows in total." {
+		t.Errorf("Unexpected message received: %s", message)
+	}
+
+	read(doPostDeleteRequest(t, handler, constructRequestValues("1604311711000", "", []string{`{__name__="foo"}`})))
+	var deleted deleteSeriesResponse
+	if err := json.Unmarshal(response.Data, &deleted); err != nil {
+		t.Fatalf("expected an object, got %s: %v", response.Data, err)
+	}
+}
+
 func constructRequestValues(start, end string, matchers []string) url.Values {
 	values := make(url.Values)
 	if start != "" {
@@ -0,0 +1,42 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/NYTimes/gziphandler"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+)
+
+// maxDuplicatesLimit is the default and maximum number of duplicates listed.
+const maxDuplicatesLimit = 100
+
+// Duplicates lists the recent duplicate samples received by this Promscale,
+// along with the tenant, HA replica and client which sent them.
+func Duplicates(conf *Config) http.Handler {
+	hf := corsWrapper(conf, duplicatesHandler(ingestor.RecentDuplicates))
+	return gziphandler.GzipHandler(hf)
+}
+
+func duplicatesHandler(recent func(metric string, limit int) []ingestor.DuplicateExample) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+		limit := maxDuplicatesLimit
+		if l := r.FormValue("limit"); l != "" {
+			var err error
+			if limit, err = strconv.Atoi(l); err != nil || limit <= 0 || limit > maxDuplicatesLimit {
+				respondError(w, http.StatusBadRequest, fmt.Errorf("invalid parameter 'limit': must be a positive integer not exceeding %d", maxDuplicatesLimit), "bad_data")
+				return
+			}
+		}
+		respond(w, http.StatusOK, recent(r.FormValue("metric"), limit))
+	}
+}
@@ -0,0 +1,71 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+)
+
+func TestDuplicatesHandler(t *testing.T) {
+	example := ingestor.DuplicateExample{
+		Metric:   "up",
+		Detected: ingestor.DetectedInDatabase,
+		Samples:  3,
+		Source:   model.Source{Tenant: "a", Replica: "r1", Client: "10.0.0.1"},
+	}
+	var gotMetric string
+	var gotLimit int
+	handler := duplicatesHandler(func(metric string, limit int) []ingestor.DuplicateExample {
+		gotMetric, gotLimit = metric, limit
+		return []ingestor.DuplicateExample{example}
+	})
+
+	cases := []struct {
+		name   string
+		query  string
+		code   int
+		metric string
+		limit  int
+	}{
+		{name: "default", code: http.StatusOK, limit: maxDuplicatesLimit},
+		{name: "metric and limit", query: "?metric=up&limit=5", code: http.StatusOK, metric: "up", limit: 5},
+		{name: "invalid limit", query: "?limit=0", code: http.StatusBadRequest},
+		{name: "limit too high", query: "?limit=1000", code: http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gotMetric, gotLimit = "", 0
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(http.MethodGet, "/api/v1/status/duplicates"+c.query, nil))
+			require.Equal(t, c.code, w.Code)
+			if c.code != http.StatusOK {
+				return
+			}
+			require.Equal(t, c.metric, gotMetric)
+			require.Equal(t, c.limit, gotLimit)
+			var resp struct {
+				Status string
+				Data   []ingestor.DuplicateExample
+			}
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			require.Equal(t, "success", resp.Status)
+			require.Equal(t, []ingestor.DuplicateExample{example}, resp.Data)
+		})
+	}
+}
+
+func TestNewSource(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/write", nil)
+	r.RemoteAddr = "10.0.0.1:51234"
+	r.Header.Set("TENANT", "a")
+	require.Equal(t, &model.Source{Tenant: "a", Client: "10.0.0.1"}, newSource(r))
+}
@@ -0,0 +1,156 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/NYTimes/gziphandler"
+	"github.com/gorilla/mux"
+	"github.com/timescale/promscale/pkg/auth"
+	"github.com/timescale/promscale/pkg/ha"
+	"github.com/timescale/promscale/pkg/log"
+)
+
+// HALeases lists the HA leases of all the clusters.
+func HALeases(conf *Config) http.Handler {
+	hf := corsWrapper(conf, haLeasesHandler(conf))
+	return gziphandler.GzipHandler(hf)
+}
+
+// HAFailover forces the failover of a HA cluster to a replica.
+func HAFailover(conf *Config) http.Handler {
+	hf := corsWrapper(conf, haFailoverHandler(conf))
+	return gziphandler.GzipHandler(hf)
+}
+
+// HAPin pins the leader of a HA cluster, or unpins it on DELETE.
+func HAPin(conf *Config) http.Handler {
+	hf := corsWrapper(conf, haPinHandler(conf))
+	return gziphandler.GzipHandler(hf)
+}
+
+func haLeasesHandler(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !haAdminAvailable(w, r, conf, "list") {
+			return
+		}
+		leases, err := conf.HAService.Leases(r.Context())
+		if err != nil {
+			respondHAError(w, err)
+			return
+		}
+		respond(w, http.StatusOK, leases)
+	}
+}
+
+func haFailoverHandler(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !haAdminAvailable(w, r, conf, "failover") {
+			return
+		}
+		cluster, replica, ok := parseHAParams(w, r, true)
+		if !ok {
+			return
+		}
+		lease, err := conf.HAService.Failover(r.Context(), cluster, replica)
+		auditHA(r, "failover", cluster, replica, err)
+		if err != nil {
+			respondHAError(w, err)
+			return
+		}
+		respond(w, http.StatusOK, lease)
+	}
+}
+
+func haPinHandler(conf *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		action := "pin"
+		if r.Method == http.MethodDelete {
+			action = "unpin"
+		}
+		if !haAdminAvailable(w, r, conf, action) {
+			return
+		}
+		cluster, replica, ok := parseHAParams(w, r, action == "pin")
+		if !ok {
+			return
+		}
+		if action == "unpin" {
+			err := conf.HAService.UnpinLeader(r.Context(), cluster)
+			auditHA(r, action, cluster, "", err)
+			if err != nil {
+				respondHAError(w, err)
+				return
+			}
+			respond(w, http.StatusOK, nil)
+			return
+		}
+		lease, err := conf.HAService.PinLeader(r.Context(), cluster, replica)
+		auditHA(r, action, cluster, replica, err)
+		if err != nil {
+			respondHAError(w, err)
+			return
+		}
+		respond(w, http.StatusOK, lease)
+	}
+}
+
+func haAdminAvailable(w http.ResponseWriter, r *http.Request, conf *Config, action string) bool {
+	if !conf.AdminAPIEnabled {
+		err := fmt.Errorf("administration of HA leases requires admin permissions. Use -web.enable-admin-api flag to allow it")
+		auditHA(r, action, "", "", err)
+		respondError(w, http.StatusForbidden, err, "operation_not_permitted")
+		return false
+	}
+	if conf.HAService == nil {
+		respondError(w, http.StatusServiceUnavailable, fmt.Errorf("HA is not enabled"), "unavailable")
+		return false
+	}
+	return true
+}
+
+func parseHAParams(w http.ResponseWriter, r *http.Request, requireReplica bool) (cluster, replica string, ok bool) {
+	cluster, err := url.PathUnescape(mux.Vars(r)["cluster"])
+	if err != nil || cluster == "" {
+		respondError(w, http.StatusBadRequest, fmt.Errorf("invalid cluster: %s", mux.Vars(r)["cluster"]), "bad_data")
+		return "", "", false
+	}
+	replica = r.FormValue("replica")
+	if requireReplica && replica == "" {
+		respondError(w, http.StatusBadRequest, fmt.Errorf("replica parameter is required"), "bad_data")
+		return "", "", false
+	}
+	return cluster, replica, true
+}
+
+func respondHAError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ha.ErrUnknownCluster):
+		respondError(w, http.StatusNotFound, err, "not_found")
+	case errors.Is(err, ha.ErrLeaderPinned):
+		respondError(w, http.StatusConflict, err, "conflict")
+	default:
+		respondError(w, http.StatusInternalServerError, err, "internal")
+	}
+}
+
+// auditHA logs the HA lease administration actions, along with who requested them.
+func auditHA(r *http.Request, action, cluster, replica string, err error) {
+	principal := ""
+	if p := auth.PrincipalFromContext(r.Context()); p != nil {
+		principal = p.Name
+	}
+	keyvals := []interface{}{"msg", "HA lease admin action", "action", action, "cluster", cluster, "replica", replica,
+		"principal", principal, "remote_addr", r.RemoteAddr}
+	if err != nil {
+		log.Warn(append(keyvals, "err", err)...)
+		return
+	}
+	log.Info(keyvals...)
+}
@@ -0,0 +1,136 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/ha"
+	"github.com/timescale/promscale/pkg/ha/client"
+)
+
+func newTestHAService(t *testing.T) *ha.Service {
+	leaseStart := time.Unix(100, 0)
+	service := ha.MockNewHAService()
+	ha.SetLeaderInMockService(service, []client.LeaseDBState{
+		{Cluster: "cluster 1", Leader: "replica1", LeaseStart: leaseStart, LeaseUntil: leaseStart.Add(time.Minute)},
+	})
+	_, _, err := service.CheckLease(leaseStart, leaseStart, "cluster 1", "replica1")
+	require.NoError(t, err)
+	return service
+}
+
+func TestHALeaseHandlers(t *testing.T) {
+	cases := []struct {
+		name           string
+		noAdmin        bool
+		noService      bool
+		handler        func(*Config) http.HandlerFunc
+		method         string
+		cluster        string
+		replica        string
+		expectedCode   int
+		expectedLeader string
+		expectedPin    string
+	}{
+		{
+			name:         "admin API disabled",
+			noAdmin:      true,
+			handler:      haLeasesHandler,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "HA disabled",
+			noService:    true,
+			handler:      haFailoverHandler,
+			cluster:      "cluster%201",
+			replica:      "replica2",
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:         "list",
+			handler:      haLeasesHandler,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "failover without replica",
+			handler:      haFailoverHandler,
+			cluster:      "cluster%201",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "failover of unknown cluster",
+			handler:      haFailoverHandler,
+			cluster:      "cluster2",
+			replica:      "replica2",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:           "failover",
+			handler:        haFailoverHandler,
+			cluster:        "cluster%201",
+			replica:        "replica2",
+			expectedCode:   http.StatusOK,
+			expectedLeader: "replica2",
+		},
+		{
+			name:           "pin",
+			handler:        haPinHandler,
+			method:         http.MethodPost,
+			cluster:        "cluster%201",
+			replica:        "replica2",
+			expectedCode:   http.StatusOK,
+			expectedLeader: "replica2",
+			expectedPin:    "replica2",
+		},
+		{
+			name:         "unpin",
+			handler:      haPinHandler,
+			method:       http.MethodDelete,
+			cluster:      "cluster%201",
+			expectedCode: http.StatusOK,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := &Config{AdminAPIEnabled: !c.noAdmin}
+			if !c.noService {
+				config.HAService = newTestHAService(t)
+			}
+			method := c.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, "/api/v1/admin/ha/leases", strings.NewReader(url.Values{"replica": {c.replica}}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req = mux.SetURLVars(req, map[string]string{"cluster": c.cluster})
+			w := httptest.NewRecorder()
+			c.handler(config).ServeHTTP(w, req)
+			require.Equal(t, c.expectedCode, w.Code, w.Body.String())
+			if c.expectedLeader == "" {
+				return
+			}
+			var resp struct {
+				Data struct {
+					Cluster      string `json:"cluster"`
+					Leader       string `json:"leader"`
+					PinnedLeader string `json:"pinned_leader"`
+				} `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Equal(t, "cluster 1", resp.Data.Cluster)
+			require.Equal(t, c.expectedLeader, resp.Data.Leader)
+			require.Equal(t, c.expectedPin, resp.Data.PinnedLeader)
+		})
+	}
+}
@@ -6,13 +6,19 @@ package api
 
 import (
 	"encoding/json"
-	"math"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/NYTimes/gziphandler"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/storage"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/promql"
 )
 
@@ -33,14 +39,20 @@ func Labels(conf *Config, queryable promql.Queryable) http.Handler {
 
 func labelsHandler(queryable promql.Queryable) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		querier, err := queryable.SamplesQuerier(r.Context(), math.MinInt64, math.MaxInt64)
+		params, err := parseLabelsParams(r)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+		querier, err := queryable.SamplesQuerier(r.Context(), params.start, params.end)
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, err, "internal")
 			return
 		}
 		defer querier.Close()
-		var names labelsValue
-		names, warnings, err := querier.LabelNames()
+		names, warnings, err := params.read(func(matchers []*labels.Matcher) ([]string, storage.Warnings, error) {
+			return querier.LabelNames(params.hints, matchers...)
+		})
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, err, "internal")
 			return
@@ -51,6 +63,78 @@ func labelsHandler(queryable promql.Queryable) http.HandlerFunc {
 	}
 }
 
+// labelsParams are the parameters of the label names and values requests.
+type labelsParams struct {
+	start, end  int64
+	matcherSets [][]*labels.Matcher
+	hints       *promql.LabelHints
+}
+
+func parseLabelsParams(r *http.Request) (*labelsParams, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, fmt.Errorf("error parsing form values: %w", err)
+	}
+	start, err := parseTimeParam(r, "start", model.MinTime)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseTimeParam(r, "end", model.MaxTime)
+	if err != nil {
+		return nil, err
+	}
+	if end.Before(start) {
+		return nil, errors.New("end timestamp must not be before start time")
+	}
+	params := &labelsParams{
+		start: timestamp.FromTime(start),
+		end:   timestamp.FromTime(end),
+		hints: &promql.LabelHints{},
+	}
+	if l := r.FormValue("limit"); l != "" {
+		if params.hints.Limit, err = strconv.Atoi(l); err != nil || params.hints.Limit < 0 {
+			return nil, errors.New("invalid parameter 'limit': must be a non-negative integer")
+		}
+	}
+	for _, s := range r.Form["match[]"] {
+		matchers, err := parser.ParseMetricSelector(s)
+		if err != nil {
+			return nil, err
+		}
+		params.matcherSets = append(params.matcherSets, matchers)
+	}
+	return params, nil
+}
+
+// read returns the sorted union of the labels read for each matcher set, up
+// to the limit.
+func (p *labelsParams) read(readLabels func([]*labels.Matcher) ([]string, storage.Warnings, error)) (labelsValue, storage.Warnings, error) {
+	if len(p.matcherSets) == 0 {
+		res, warnings, err := readLabels(nil)
+		return res, warnings, err
+	}
+	seen := make(map[string]struct{})
+	var warnings storage.Warnings
+	for _, matchers := range p.matcherSets {
+		res, w, err := readLabels(matchers)
+		if err != nil {
+			return nil, nil, err
+		}
+		warnings = append(warnings, w...)
+		for _, l := range res {
+			seen[l] = struct{}{}
+		}
+	}
+	res := make(labelsValue, 0, len(seen))
+	for l := range seen {
+		res = append(res, l)
+	}
+	sort.Strings(res)
+	if p.hints.Limit > 0 && len(res) > p.hints.Limit {
+		res = res[:p.hints.Limit]
+	}
+	return res, warnings, nil
+}
+
 func respondLabels(w http.ResponseWriter, res *promql.Result, warnings storage.Warnings) {
 	setResponseHeaders(w, res, false, warnings)
 	resp := &response{
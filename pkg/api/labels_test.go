@@ -14,7 +14,13 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/gorilla/mux"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/stretchr/testify/require"
 	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/lreader"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/query"
 )
 
@@ -91,3 +97,70 @@ func doLabels(t *testing.T, queryHandler http.Handler) *httptest.ResponseRecorde
 	queryHandler.ServeHTTP(w, req)
 	return w
 }
+
+func TestLabelValuesParams(t *testing.T) {
+	testCases := []struct {
+		name          string
+		query         string
+		labelValues   map[string][]string
+		expectCode    int
+		expectValues  []string
+		expectQueries []lreader.LabelsQuery
+	}{
+		{
+			name:          "Unscoped",
+			expectCode:    http.StatusOK,
+			expectQueries: []lreader.LabelsQuery{{Start: timestamp.FromTime(model.MinTime), End: timestamp.FromTime(model.MaxTime)}},
+		}, {
+			name:         "Matcher sets are merged and limited",
+			query:        `?match[]={job="a"}&match[]={job="b"}&start=1&end=2&limit=3`,
+			labelValues:  map[string][]string{"a": {"x", "z"}, "b": {"w", "x"}},
+			expectCode:   http.StatusOK,
+			expectValues: []string{"w", "x", "z"},
+			expectQueries: []lreader.LabelsQuery{
+				{Matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "job", "a")}, Start: 1000, End: 2000, Limit: 3},
+				{Matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "job", "b")}, Start: 1000, End: 2000, Limit: 3},
+			},
+		}, {
+			name:         "Merged result is limited",
+			query:        `?match[]={job="a"}&match[]={job="b"}&limit=2`,
+			labelValues:  map[string][]string{"a": {"x", "z"}, "b": {"w", "x"}},
+			expectCode:   http.StatusOK,
+			expectValues: []string{"w", "x"},
+		}, {
+			name:       "Invalid limit",
+			query:      "?limit=-1",
+			expectCode: http.StatusBadRequest,
+		}, {
+			name:       "Invalid matcher",
+			query:      "?match[]={job=~}",
+			expectCode: http.StatusBadRequest,
+		}, {
+			name:       "End before start",
+			query:      "?start=2&end=1",
+			expectCode: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reader := &mockLabelsReader{labelValues: tc.labelValues}
+			handler := labelValues(query.NewQueryable(nil, reader))
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/label/job/values"+tc.query, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, mux.SetURLVars(req, map[string]string{"name": "instance"}))
+
+			require.Equal(t, tc.expectCode, w.Code)
+			if tc.expectCode != http.StatusOK {
+				return
+			}
+			var res struct {
+				Data []string
+			}
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+			require.Equal(t, tc.expectValues, res.Data)
+			if tc.expectQueries != nil {
+				require.Equal(t, tc.expectQueries, reader.queries)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NYTimes/gziphandler"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/timescale/promscale/pkg/pgmodel/logs"
+	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
+)
+
+const defaultLogsLookback = time.Hour
+
+func QueryLogs(conf *Config, conn pgxconn.PgxConn) http.Handler {
+	var rAuth tenancy.ReadAuthorizer
+	if conf.MultiTenancy != nil {
+		rAuth = conf.MultiTenancy.ReadAuthorizer()
+	}
+	hf := corsWrapper(conf, queryLogsHandler(conn, rAuth))
+	return gziphandler.GzipHandler(hf)
+}
+
+// queryLogsHandler returns the logs matching the query. As for metrics, the
+// read authorizer, nil without multi-tenancy, restricts the tenants whose logs
+// are read.
+func queryLogsHandler(conn pgxconn.PgxConn, rAuth tenancy.ReadAuthorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+		q, err := parseLogsQuery(r)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err, "bad_data")
+			return
+		}
+		if rAuth != nil {
+			q.ResourceMatchers = tenantMatchers(rAuth.AppendTenantMatcher(r.Context(), nil))
+		}
+		entries, err := logs.QueryLogs(r.Context(), conn, q)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err, "fetching logs")
+			return
+		}
+		respond(w, http.StatusOK, entries)
+	}
+}
+
+func parseLogsQuery(r *http.Request) (logs.Query, error) {
+	var (
+		q   logs.Query
+		err error
+	)
+	if q.Selector, err = logs.ParseSelector(r.FormValue("query")); err != nil {
+		return q, fmt.Errorf("invalid parameter 'query': %w", err)
+	}
+	if q.End, err = parseTimeParam(r, "end", time.Now()); err != nil {
+		return q, err
+	}
+	if q.Start, err = parseTimeParam(r, "start", q.End.Add(-defaultLogsLookback)); err != nil {
+		return q, err
+	}
+	if q.End.Before(q.Start) {
+		return q, fmt.Errorf("end timestamp must not be before start time")
+	}
+	if traceID := r.FormValue("trace_id"); traceID != "" {
+		id, err := logs.ParseTraceID(traceID)
+		if err != nil {
+			return q, fmt.Errorf("invalid parameter 'trace_id': %w", err)
+		}
+		q.TraceID = &id
+	}
+	if limit := r.FormValue("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit <= 0 {
+			return q, fmt.Errorf("invalid parameter 'limit': must be a positive integer")
+		}
+		if q.Limit > logs.MaxLimit {
+			return q, fmt.Errorf("invalid parameter 'limit': must not exceed %d", logs.MaxLimit)
+		}
+	}
+	return q, nil
+}
+
+// tenantMatchers converts the tenant matchers of the read authorizer into
+// matchers of the resource attributes of the logs, which hold their tenant.
+func tenantMatchers(ms []*labels.Matcher) []logs.TagMatcher {
+	matchers := make([]logs.TagMatcher, len(ms))
+	for i, m := range ms {
+		matchers[i] = logs.TagMatcher{Key: m.Name, Value: m.Value}
+		switch m.Type {
+		case labels.MatchEqual:
+			matchers[i].Type = logs.MatchEqual
+		case labels.MatchNotEqual:
+			matchers[i].Type = logs.MatchNotEqual
+		case labels.MatchRegexp:
+			matchers[i].Type = logs.MatchRegexp
+		case labels.MatchNotRegexp:
+			matchers[i].Type = logs.MatchNotRegexp
+		}
+	}
+	return matchers
+}
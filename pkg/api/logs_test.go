@@ -0,0 +1,106 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/auth"
+	"github.com/timescale/promscale/pkg/pgmodel/logs"
+	"github.com/timescale/promscale/pkg/tenancy"
+)
+
+func TestParseLogsQuery(t *testing.T) {
+	traceID := [16]byte{15: 0xab}
+	cases := []struct {
+		name     string
+		params   url.Values
+		expected logs.Query
+		fails    bool
+	}{
+		{
+			name: "full query",
+			params: url.Values{
+				"query":    {`{service.name="checkout"} |= "timeout"`},
+				"start":    {"100"},
+				"end":      {"200"},
+				"trace_id": {"ab"},
+				"limit":    {"10"},
+			},
+			expected: logs.Query{
+				Selector: logs.Selector{
+					Matchers: []logs.TagMatcher{{Type: logs.MatchEqual, Key: "service.name", Value: "checkout"}},
+					Filters:  []logs.LineFilter{{Type: logs.FilterContains, Value: "timeout"}},
+				},
+				Start:   time.Unix(100, 0).UTC(),
+				End:     time.Unix(200, 0).UTC(),
+				TraceID: &traceID,
+				Limit:   10,
+			},
+		},
+		{
+			name:   "default start",
+			params: url.Values{"end": {"7200"}},
+			expected: logs.Query{
+				Start: time.Unix(3600, 0).UTC(),
+				End:   time.Unix(7200, 0).UTC(),
+			},
+		},
+		{
+			name:   "invalid selector",
+			params: url.Values{"query": {`{service.name=checkout}`}},
+			fails:  true,
+		},
+		{
+			name:   "end before start",
+			params: url.Values{"start": {"200"}, "end": {"100"}},
+			fails:  true,
+		},
+		{
+			name:   "invalid trace id",
+			params: url.Values{"trace_id": {"xyz"}},
+			fails:  true,
+		},
+		{
+			name:   "negative limit",
+			params: url.Values{"limit": {"-1"}},
+			fails:  true,
+		},
+		{
+			name:   "limit too big",
+			params: url.Values{"limit": {"5001"}},
+			fails:  true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/logs/query?"+c.params.Encode(), nil)
+			q, err := parseLogsQuery(r)
+			if c.fails {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expected, q)
+		})
+	}
+}
+
+func TestLogsTenantMatchers(t *testing.T) {
+	rAuth, err := tenancy.NewReadAuthorizer(tenancy.NewSelectiveTenancyConfig([]string{"tenant-a", "tenant-b"}, false, false))
+	require.NoError(t, err)
+
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Name: "user", Tenants: []string{"tenant-a"}})
+	require.Equal(t, []logs.TagMatcher{
+		{Type: logs.MatchRegexp, Key: tenancy.TenantLabelKey, Value: "tenant-a|tenant-b"},
+		{Type: logs.MatchRegexp, Key: tenancy.TenantLabelKey, Value: "tenant-a"},
+	}, tenantMatchers(rAuth.AppendTenantMatcher(ctx, nil)))
+}
@@ -69,24 +69,104 @@ func marshalMatrixData(out *errorWrapper, data promql.Matrix) {
 			}
 			out.WriteStrings(open)
 			marshalLabels(out, data.Metric)
-			out.WriteStrings(`},"values":[`)
-			for i, point := range data.Points {
-				open = ",["
-				if i == 0 {
-					open = open[1:]
+			numHistograms := 0
+			for _, point := range data.Points {
+				if point.H != nil {
+					numHistograms++
 				}
-				out.WriteStrings(open)
-				out.writeJsonFloat(float64(point.T) / 1000)
-				out.WriteStrings(`,"`)
-				out.writeFloat(point.V)
-				out.WriteStrings(`"]`)
 			}
-			out.WriteStrings(`]}`)
+			if numHistograms < len(data.Points) || numHistograms == 0 {
+				out.WriteStrings(`},"values":[`)
+				first := true
+				for _, point := range data.Points {
+					if point.H != nil {
+						continue
+					}
+					open = ",["
+					if first {
+						open = open[1:]
+						first = false
+					}
+					out.WriteStrings(open)
+					out.writeJsonFloat(float64(point.T) / 1000)
+					out.WriteStrings(`,"`)
+					out.writeFloat(point.V)
+					out.WriteStrings(`"]`)
+				}
+				out.WriteStrings(`]`)
+			} else {
+				out.WriteStrings(`}`)
+			}
+			if numHistograms > 0 {
+				out.WriteStrings(`,"histograms":[`)
+				first := true
+				for _, point := range data.Points {
+					if point.H == nil {
+						continue
+					}
+					open = ",["
+					if first {
+						open = open[1:]
+						first = false
+					}
+					out.WriteStrings(open)
+					out.writeJsonFloat(float64(point.T) / 1000)
+					out.WriteStrings(`,`)
+					marshalHistogram(out, point.H)
+					out.WriteStrings(`]`)
+				}
+				out.WriteStrings(`]`)
+			}
+			out.WriteStrings(`}`)
 		}
 	}
 	out.WriteStrings(`]}`)
 }
 
+// marshalHistogram writes a native histogram in the format of the Prometheus HTTP API.
+// Each bucket is written as [boundary_rule, lower, upper, count], where boundary_rule
+// is 0 for (lower, upper], 1 for [lower, upper), 2 for (lower, upper) and 3 for [lower, upper].
+func marshalHistogram(out *errorWrapper, h *model.FloatHistogram) {
+	out.WriteStrings(`{"count":"`)
+	out.writeFloat(h.Count)
+	out.WriteStrings(`","sum":"`)
+	out.writeFloat(h.Sum)
+	out.WriteStrings(`"`)
+	first := true
+	for _, bucket := range h.AllBuckets() {
+		if bucket.Count == 0 {
+			continue
+		}
+		if first {
+			out.WriteStrings(`,"buckets":[`)
+			first = false
+		} else {
+			out.WriteStrings(`,`)
+		}
+		boundaries := 2
+		if bucket.LowerInclusive {
+			if bucket.UpperInclusive {
+				boundaries = 3
+			} else {
+				boundaries = 1
+			}
+		} else if bucket.UpperInclusive {
+			boundaries = 0
+		}
+		out.WriteStrings(`[`, strconv.Itoa(boundaries), `,"`)
+		out.writeFloat(bucket.Lower)
+		out.WriteStrings(`","`)
+		out.writeFloat(bucket.Upper)
+		out.WriteStrings(`","`)
+		out.writeFloat(bucket.Count)
+		out.WriteStrings(`"]`)
+	}
+	if !first {
+		out.WriteStrings(`]`)
+	}
+	out.WriteStrings(`}`)
+}
+
 func marshalExemplarData(out *errorWrapper, data []model.ExemplarQueryResult) {
 	out.WriteStrings(`[`)
 	for i := range data {
@@ -137,16 +217,25 @@ func marshalVectorData(out *errorWrapper, data promql.Vector) {
 			}
 			out.WriteStrings(open)
 			marshalLabels(out, data.Metric)
-			out.WriteStrings(`},"value":[`)
+			if data.Point.H != nil {
+				out.WriteStrings(`},"histogram":[`)
+			} else {
+				out.WriteStrings(`},"value":[`)
+			}
 			{
 				if floatLen == 0 {
 					floatLen = out.writeJsonFloat(float64(data.Point.T) / 1000)
 				} else {
 					out.writeCachedJsonFloat(floatLen)
 				}
-				out.WriteStrings(`,"`)
-				out.writeFloat(data.Point.V)
-				out.WriteStrings(`"`)
+				if data.Point.H != nil {
+					out.WriteStrings(`,`)
+					marshalHistogram(out, data.Point.H)
+				} else {
+					out.WriteStrings(`,"`)
+					out.writeFloat(data.Point.V)
+					out.WriteStrings(`"`)
+				}
 			}
 			out.WriteStrings(`]}`)
 		}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/promql"
 )
 
@@ -330,6 +331,42 @@ func TestMarshalMatrix(t *testing.T) {
 	}
 }
 
+func TestMarshalHistograms(t *testing.T) {
+	h := &model.FloatHistogram{
+		Count:           5,
+		Sum:             12,
+		Schema:          0,
+		ZeroThreshold:   0.001,
+		ZeroCount:       1,
+		PositiveSpans:   []model.HistogramSpan{{Offset: 0, Length: 2}},
+		PositiveBuckets: []float64{3, 1},
+	}
+	metric := labels.Labels{{Name: "__name__", Value: "nameVal"}}
+	histogramJSON := `{"count":"5","sum":"12","buckets":[[3,"-0.001","0.001","1"],[0,"0.5","1","3"],[0,"1","2","1"]]}`
+
+	builder := strings.Builder{}
+	_ = marshalVectorResponse(&builder, promql.Vector{
+		{Metric: metric, Point: promql.Point{T: 1000, V: h.Count, H: h}},
+	}, nil)
+	expected := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"__name__":"nameVal"},"histogram":[1,` +
+		histogramJSON + `]}]}}` + "\n"
+	if builder.String() != expected {
+		t.Errorf("unexpected output\ngot:\n\t%s\nexpected:\n\t%s\n", builder.String(), expected)
+	}
+
+	builder.Reset()
+	_ = marshalMatrixResponse(&builder, promql.Matrix{
+		{Metric: metric, Points: []promql.Point{{T: 1000, V: h.Count, H: h}, {T: 2000, V: h.Count, H: h}}},
+		{Metric: metric, Points: []promql.Point{{T: 1000, V: 1}, {T: 2000, V: h.Count, H: h}}},
+	}, nil)
+	expected = `{"status":"success","data":{"resultType":"matrix","result":[` +
+		`{"metric":{"__name__":"nameVal"},"histograms":[[1,` + histogramJSON + `],[2,` + histogramJSON + `]]},` +
+		`{"metric":{"__name__":"nameVal"},"values":[[1,"1"]],"histograms":[[2,` + histogramJSON + `]]}]}}` + "\n"
+	if builder.String() != expected {
+		t.Errorf("unexpected output\ngot:\n\t%s\nexpected:\n\t%s\n", builder.String(), expected)
+	}
+}
+
 func builtinMarshal(val parser.Value, warnings []string) string {
 	resp := &response{
 		Status: "success",
@@ -5,22 +5,261 @@
 package api
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
 
+	"github.com/timescale/promscale/pkg/api/parser"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/otlp"
 	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/serieslimit"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/tenancy"
+	"github.com/timescale/promscale/pkg/tracer"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
 	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
-func NewTraceServer(i ingestor.DBInserter) ptraceotlp.GRPCServer {
+// NewTraceServer returns the OTLP traces gRPC server. The traces go through the
+// processors, then are tagged with their tenant by the trace authorizer, which is
+// nil when multi-tenancy is disabled.
+func NewTraceServer(i ingestor.DBInserter, authorizer tenancy.TraceAuthorizer, processors ...ingestor.TraceProcessor) ptraceotlp.GRPCServer {
 	return &tracesServer{
-		ingestor: i,
+		ingestor:   i,
+		authorizer: authorizer,
+		processors: processors,
 	}
 }
 
 type tracesServer struct {
-	ingestor ingestor.DBInserter
+	ingestor   ingestor.DBInserter
+	authorizer tenancy.TraceAuthorizer
+	processors []ingestor.TraceProcessor
 }
 
 func (t *tracesServer) Export(ctx context.Context, tr ptraceotlp.Request) (ptraceotlp.Response, error) {
+	for _, processor := range t.processors {
+		if err := processor.ProcessTraces(ctx, tr.Traces()); err != nil {
+			return ptraceotlp.NewResponse(), status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	if tr.Traces().SpanCount() == 0 {
+		return ptraceotlp.NewResponse(), nil
+	}
+	if t.authorizer != nil {
+		if err := t.authorizer.ProcessTraces(ctx, tr.Traces()); err != nil {
+			return ptraceotlp.NewResponse(), status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
 	return ptraceotlp.NewResponse(), t.ingestor.IngestTraces(ctx, tr.Traces())
 }
+
+// NewLogsServer returns the OTLP logs gRPC server. The logs are tagged with their
+// tenant by the log authorizer, which is nil when multi-tenancy is disabled.
+func NewLogsServer(i ingestor.DBInserter, authorizer tenancy.LogAuthorizer) plogotlp.GRPCServer {
+	return &logsServer{
+		ingestor:   i,
+		authorizer: authorizer,
+	}
+}
+
+type logsServer struct {
+	ingestor   ingestor.DBInserter
+	authorizer tenancy.LogAuthorizer
+}
+
+func (l *logsServer) Export(ctx context.Context, lr plogotlp.Request) (plogotlp.Response, error) {
+	if l.authorizer != nil {
+		if err := l.authorizer.ProcessLogs(ctx, lr.Logs()); err != nil {
+			return plogotlp.NewResponse(), status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	return plogotlp.NewResponse(), l.ingestor.IngestLogs(ctx, lr.Logs())
+}
+
+// NewMetricsServer returns the OTLP metrics gRPC server. Metrics are translated into
+// Prometheus series and go through the same preprocessors as remote-write requests.
+func NewMetricsServer(i ingestor.DBInserter, translator *otlp.Translator, dataParser *parser.DefaultParser) pmetricotlp.GRPCServer {
+	return &metricsServer{
+		ingestor:   i,
+		translator: translator,
+		dataParser: dataParser,
+	}
+}
+
+type metricsServer struct {
+	ingestor   ingestor.DBInserter
+	translator *otlp.Translator
+	dataParser *parser.DefaultParser
+}
+
+func (m *metricsServer) Export(ctx context.Context, req pmetricotlp.Request) (pmetricotlp.Response, error) {
+	// Preprocessors work on HTTP requests, hence the gRPC metadata is passed on as HTTP headers.
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "", nil)
+	if err != nil {
+		return pmetricotlp.NewResponse(), status.Error(codes.Internal, err.Error())
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		r.RemoteAddr = p.Addr.String()
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for k, values := range md {
+			for _, v := range values {
+				r.Header.Add(k, v)
+			}
+		}
+	}
+
+	code, err := ingestOTLPMetrics(ctx, r, req.Metrics(), m.ingestor, m.translator, m.dataParser)
+	switch code {
+	case http.StatusOK:
+		return pmetricotlp.NewResponse(), nil
+	case http.StatusBadRequest:
+		return pmetricotlp.NewResponse(), status.Error(codes.InvalidArgument, err.Error())
+	case http.StatusTooManyRequests:
+		return pmetricotlp.NewResponse(), status.Error(codes.ResourceExhausted, err.Error())
+	default:
+		return pmetricotlp.NewResponse(), status.Error(codes.Unavailable, err.Error())
+	}
+}
+
+// OTLPMetrics returns an http.Handler that ingests metrics sent over OTLP/HTTP,
+// encoded either as protobuf or as JSON.
+func OTLPMetrics(inserter ingestor.DBInserter, translator *otlp.Translator, dataParser *parser.DefaultParser) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracer.Default().Start(r.Context(), "otlp-metrics")
+		defer span.End()
+
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			invalidRequestError(w, "OTLP metrics header validation error", "error parsing media type from Content-Type header", metrics)
+			return
+		}
+		req := pmetricotlp.NewRequest()
+		body, err := readOTLPBody(r)
+		if err != nil {
+			invalidRequestError(w, "OTLP metrics read error", err.Error(), metrics)
+			return
+		}
+		switch mediaType {
+		case "application/x-protobuf":
+			err = req.UnmarshalProto(body)
+		case "application/json":
+			err = req.UnmarshalJSON(body)
+		default:
+			http.Error(w, fmt.Sprintf("unsupported content type %s", mediaType), http.StatusUnsupportedMediaType)
+			return
+		}
+		if err != nil {
+			invalidRequestError(w, "OTLP metrics decode error", err.Error(), metrics)
+			return
+		}
+
+		code, err := ingestOTLPMetrics(r.Context(), r, req.Metrics(), inserter, translator, dataParser)
+		if err != nil {
+			log.Warn("msg", "Error ingesting OTLP metrics", "err", err)
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		resp := pmetricotlp.NewResponse()
+		var out []byte
+		if mediaType == "application/json" {
+			out, err = resp.MarshalJSON()
+		} else {
+			out, err = resp.MarshalProto()
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", mediaType)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(out)
+	})
+}
+
+func readOTLPBody(r *http.Request) ([]byte, error) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("gzip decode error: %w", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+	var b bytes.Buffer
+	if _, err := b.ReadFrom(body); err != nil {
+		return nil, fmt.Errorf("request body read error: %w", err)
+	}
+	return b.Bytes(), r.Body.Close()
+}
+
+// ingestOTLPMetrics translates and ingests the metrics. It returns the HTTP status code
+// describing the outcome. Failed ingestion is reported as 503 since OTLP clients only
+// retry on a few status codes, and the request can succeed later.
+func ingestOTLPMetrics(ctx context.Context, r *http.Request, md pmetric.Metrics, inserter ingestor.DBInserter, translator *otlp.Translator, dataParser *parser.DefaultParser) (int, error) {
+	begin := time.Now()
+	statusCode := "400"
+	numSamplesReceived := 0
+	numMetadataReceived := 0
+	defer func() {
+		updateIngestMetrics(statusCode, time.Since(begin).Seconds(), float64(numSamplesReceived), float64(numMetadataReceived))
+	}()
+
+	source := newSource(r)
+	ctx = model.NewSourceContext(ctx, source)
+	r = r.WithContext(model.NewSourceContext(r.Context(), source))
+
+	req := ingestor.NewWriteRequest()
+	stats := translator.ToWriteRequest(md, req)
+	if stats.DroppedDataPoints > 0 {
+		log.Debug("msg", "Dropped OTLP data points that could not be translated", "num_dropped", stats.DroppedDataPoints)
+	}
+	if err := dataParser.Preprocess(r, req); err != nil {
+		ingestor.FinishWriteRequest(req)
+		if errors.Is(err, tenancy.ErrLimitExceeded) {
+			statusCode = "429"
+			return http.StatusTooManyRequests, err
+		}
+		return http.StatusBadRequest, err
+	}
+	numSamplesReceived = getTotalSamples(req)
+	numMetadataReceived = len(req.Metadata)
+
+	if len(req.Timeseries) == 0 && len(req.Metadata) == 0 {
+		statusCode = "2xx"
+		ingestor.FinishWriteRequest(req)
+		return http.StatusOK, nil
+	}
+
+	if _, _, err := inserter.IngestMetrics(ctx, req); err != nil {
+		if errors.Is(err, serieslimit.ErrLimitExceeded) {
+			statusCode = "429"
+			return http.StatusTooManyRequests, err
+		}
+		var partialErr *ingestor.PartialWriteError
+		if errors.As(err, &partialErr) {
+			statusCode = "400"
+			return http.StatusBadRequest, err
+		}
+		statusCode = "500"
+		return http.StatusServiceUnavailable, err
+	}
+	statusCode = "2xx"
+	return http.StatusOK, nil
+}
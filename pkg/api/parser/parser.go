@@ -13,6 +13,20 @@ import (
 
 type formatParser func(*http.Request, *prompb.WriteRequest) error
 
+const protobufMediaType = "application/x-protobuf"
+
+// remoteWriteFormat returns the format of protobuf remote-write requests which
+// specify the message they contain with the proto parameter of the content type.
+func remoteWriteFormat(msg string) string {
+	return protobufMediaType + ";proto=" + msg
+}
+
+// IsRemoteWriteV2 returns true if the request contains a Remote-Write 2.0 message.
+func IsRemoteWriteV2(r *http.Request) bool {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == protobufMediaType && params["proto"] == protobuf.RemoteWriteV2Message
+}
+
 // Preprocessor is used to transform the incoming write request before sending
 // it for ingestion.
 type Preprocessor interface {
@@ -30,10 +44,12 @@ type DefaultParser struct {
 func NewParser() *DefaultParser {
 	return &DefaultParser{
 		formatParsers: map[string]formatParser{
-			"application/x-protobuf":       protobuf.ParseRequest,
-			"application/json":             json.ParseRequest,
-			"text/plain":                   text.ParseRequest,
-			"application/openmetrics-text": text.ParseRequest,
+			"application/x-protobuf":                         protobuf.ParseRequest,
+			"application/json":                               json.ParseRequest,
+			"text/plain":                                     text.ParseRequest,
+			"application/openmetrics-text":                   text.ParseRequest,
+			remoteWriteFormat(protobuf.RemoteWriteV1Message): protobuf.ParseRequest,
+			remoteWriteFormat(protobuf.RemoteWriteV2Message): protobuf.ParseRequestV2,
 		},
 	}
 }
@@ -49,10 +65,13 @@ func (p *DefaultParser) AddPreprocessor(pre Preprocessor) {
 // ParseRequest runs the correct parser on the format of the request and runs the
 // preprocessors on the payload afterwards.
 func (d DefaultParser) ParseRequest(r *http.Request, req *prompb.WriteRequest) error {
-	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
 	if err != nil {
 		return fmt.Errorf("parser error: unable to parse format: %w", err)
 	}
+	if msg, ok := params["proto"]; ok && mediaType == protobufMediaType {
+		mediaType = remoteWriteFormat(msg)
+	}
 	parser, ok := d.formatParsers[mediaType]
 	if !ok {
 		return fmt.Errorf("parser error: unsupported format")
@@ -62,11 +81,16 @@ func (d DefaultParser) ParseRequest(r *http.Request, req *prompb.WriteRequest) e
 		return fmt.Errorf("parser error: %w", err)
 	}
 
+	return d.Preprocess(r, req)
+}
+
+// Preprocess runs the preprocessors on a write request which was not parsed
+// by ParseRequest, e.g. one translated from OTLP.
+func (d DefaultParser) Preprocess(r *http.Request, req *prompb.WriteRequest) error {
 	if len(req.Timeseries) == 0 {
 		return nil
 	}
 
-	// run preprocessors
 	for _, p := range d.preprocessors {
 		err := p.Process(r, req)
 
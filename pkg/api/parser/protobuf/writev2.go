@@ -0,0 +1,244 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package protobuf
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/timescale/promscale/pkg/prompb"
+	writev2 "github.com/timescale/promscale/pkg/prompb/io/prometheus/write/v2"
+)
+
+const (
+	// RemoteWriteV1Message is the fully qualified protobuf message name of Remote-Write 1.0 requests.
+	RemoteWriteV1Message = "prometheus.WriteRequest"
+	// RemoteWriteV2Message is the fully qualified protobuf message name of Remote-Write 2.0 requests.
+	RemoteWriteV2Message = "io.prometheus.write.v2.Request"
+)
+
+// ParseRequestV2 is responsible for populating the write request from the
+// data in a Remote-Write 2.0 request. Label, exemplar and metadata references
+// are resolved against the symbol table of the request. Metadata is attached
+// to the write request once per metric and the created timestamp of a counter
+// or histogram is marked by a zero valued sample (or histogram) preceding its
+// first sample. The ingestor decides whether the zero is written.
+func ParseRequestV2(r *http.Request, wr *prompb.WriteRequest) error {
+	b := bufPool.Get().(*bytes.Buffer)
+	defer bufPool.Put(b)
+	b.Reset()
+
+	_, err := b.ReadFrom(r.Body)
+	if err != nil {
+		return fmt.Errorf("request body read error: %w", err)
+	}
+
+	req := new(writev2.Request)
+	if err = proto.Unmarshal(b.Bytes(), req); err != nil {
+		return fmt.Errorf("protobuf unmarshal error: %w", err)
+	}
+
+	if err = convertV2Request(req, wr); err != nil {
+		return err
+	}
+
+	return r.Body.Close()
+}
+
+func convertV2Request(req *writev2.Request, wr *prompb.WriteRequest) error {
+	symbols := symbolTable(req.Symbols)
+	seenMetadata := make(map[string]struct{})
+	for i := range req.Timeseries {
+		ts := &req.Timeseries[i]
+
+		lbls, err := symbols.labels(ts.LabelsRefs)
+		if err != nil {
+			return fmt.Errorf("series %d: %w", i, err)
+		}
+		converted := prompb.TimeSeries{Labels: lbls}
+
+		if ts.CreatedTimestamp != 0 {
+			converted.Samples, converted.Histograms = createdTimestampZeros(ts)
+		}
+		for _, s := range ts.Samples {
+			converted.Samples = append(converted.Samples, prompb.Sample{Timestamp: s.Timestamp, Value: s.Value})
+		}
+		for j := range ts.Histograms {
+			converted.Histograms = append(converted.Histograms, convertV2Histogram(&ts.Histograms[j]))
+		}
+		for j, e := range ts.Exemplars {
+			exemplarLabels, err := symbols.labels(e.LabelsRefs)
+			if err != nil {
+				return fmt.Errorf("series %d exemplar %d: %w", i, j, err)
+			}
+			converted.Exemplars = append(converted.Exemplars, prompb.Exemplar{Labels: exemplarLabels, Value: e.Value, Timestamp: e.Timestamp})
+		}
+		wr.Timeseries = append(wr.Timeseries, converted)
+
+		md, ok, err := symbols.metadata(lbls, ts.Metadata)
+		if err != nil {
+			return fmt.Errorf("series %d metadata: %w", i, err)
+		}
+		if !ok {
+			continue
+		}
+		if _, seen := seenMetadata[md.MetricFamilyName]; seen {
+			continue
+		}
+		seenMetadata[md.MetricFamilyName] = struct{}{}
+		wr.Metadata = append(wr.Metadata, md)
+	}
+	return nil
+}
+
+// createdTimestampZeros returns the zero valued sample or histogram marking the
+// created timestamp of the series, see prompb.CreatedZero. Only counters and
+// histograms start from zero, a zero would be a wrong value of a gauge. Nothing
+// is returned when the created timestamp is not strictly older than the first
+// sample, as the zero would overwrite real data.
+func createdTimestampZeros(ts *writev2.TimeSeries) ([]prompb.Sample, []prompb.Histogram) {
+	ct := ts.CreatedTimestamp
+	switch {
+	case len(ts.Samples) > 0:
+		if ct >= ts.Samples[0].Timestamp {
+			return nil, nil
+		}
+		// The series of classic histograms are counters.
+		if t := ts.Metadata.Type; t != writev2.Metadata_METRIC_TYPE_COUNTER && t != writev2.Metadata_METRIC_TYPE_HISTOGRAM {
+			return nil, nil
+		}
+		return []prompb.Sample{{Timestamp: ct, Value: prompb.CreatedZero}}, nil
+	case len(ts.Histograms) > 0:
+		first := &ts.Histograms[0]
+		if ct >= first.Timestamp {
+			return nil, nil
+		}
+		if ts.Metadata.Type == writev2.Metadata_METRIC_TYPE_GAUGEHISTOGRAM || first.ResetHint == writev2.Histogram_RESET_HINT_GAUGE {
+			return nil, nil
+		}
+		zero := prompb.Histogram{
+			Sum:           prompb.CreatedZero,
+			Schema:        first.Schema,
+			ZeroThreshold: first.ZeroThreshold,
+			ResetHint:     prompb.Histogram_ResetHint(first.ResetHint),
+			Timestamp:     ct,
+		}
+		if _, isFloat := first.GetCount().(*writev2.Histogram_CountFloat); isFloat {
+			zero.Count = &prompb.Histogram_CountFloat{}
+			zero.ZeroCount = &prompb.Histogram_ZeroCountFloat{}
+		} else {
+			zero.Count = &prompb.Histogram_CountInt{}
+			zero.ZeroCount = &prompb.Histogram_ZeroCountInt{}
+		}
+		return nil, []prompb.Histogram{zero}
+	}
+	return nil, nil
+}
+
+func convertV2Histogram(h *writev2.Histogram) prompb.Histogram {
+	res := prompb.Histogram{
+		Sum:            h.Sum,
+		Schema:         h.Schema,
+		ZeroThreshold:  h.ZeroThreshold,
+		NegativeSpans:  convertV2Spans(h.NegativeSpans),
+		NegativeDeltas: h.NegativeDeltas,
+		NegativeCounts: h.NegativeCounts,
+		PositiveSpans:  convertV2Spans(h.PositiveSpans),
+		PositiveDeltas: h.PositiveDeltas,
+		PositiveCounts: h.PositiveCounts,
+		ResetHint:      prompb.Histogram_ResetHint(h.ResetHint),
+		Timestamp:      h.Timestamp,
+	}
+	switch c := h.Count.(type) {
+	case *writev2.Histogram_CountInt:
+		res.Count = &prompb.Histogram_CountInt{CountInt: c.CountInt}
+	case *writev2.Histogram_CountFloat:
+		res.Count = &prompb.Histogram_CountFloat{CountFloat: c.CountFloat}
+	}
+	switch c := h.ZeroCount.(type) {
+	case *writev2.Histogram_ZeroCountInt:
+		res.ZeroCount = &prompb.Histogram_ZeroCountInt{ZeroCountInt: c.ZeroCountInt}
+	case *writev2.Histogram_ZeroCountFloat:
+		res.ZeroCount = &prompb.Histogram_ZeroCountFloat{ZeroCountFloat: c.ZeroCountFloat}
+	}
+	return res
+}
+
+func convertV2Spans(spans []writev2.BucketSpan) []prompb.BucketSpan {
+	if len(spans) == 0 {
+		return nil
+	}
+	res := make([]prompb.BucketSpan, len(spans))
+	for i, s := range spans {
+		res[i] = prompb.BucketSpan{Offset: s.Offset, Length: s.Length}
+	}
+	return res
+}
+
+type symbolTable []string
+
+func (s symbolTable) get(ref uint32) (string, error) {
+	if ref == 0 && len(s) == 0 {
+		// The first symbol is always the empty string, allow senders to omit it.
+		return "", nil
+	}
+	if int(ref) >= len(s) {
+		return "", fmt.Errorf("symbol reference %d out of range, symbol table has %d entries", ref, len(s))
+	}
+	return s[ref], nil
+}
+
+func (s symbolTable) labels(refs []uint32) ([]prompb.Label, error) {
+	if len(refs)%2 != 0 {
+		return nil, fmt.Errorf("odd number of label references: %d", len(refs))
+	}
+	res := make([]prompb.Label, 0, len(refs)/2)
+	for i := 0; i < len(refs); i += 2 {
+		name, err := s.get(refs[i])
+		if err != nil {
+			return nil, err
+		}
+		value, err := s.get(refs[i+1])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, prompb.Label{Name: name, Value: value})
+	}
+	return res, nil
+}
+
+// metadata returns the metric metadata of the series, if the series carries any.
+func (s symbolTable) metadata(lbls []prompb.Label, md writev2.Metadata) (prompb.MetricMetadata, bool, error) {
+	if md.Type == writev2.Metadata_METRIC_TYPE_UNSPECIFIED && md.HelpRef == 0 && md.UnitRef == 0 {
+		return prompb.MetricMetadata{}, false, nil
+	}
+	help, err := s.get(md.HelpRef)
+	if err != nil {
+		return prompb.MetricMetadata{}, false, err
+	}
+	unit, err := s.get(md.UnitRef)
+	if err != nil {
+		return prompb.MetricMetadata{}, false, err
+	}
+	var metricName string
+	for _, l := range lbls {
+		if l.Name == labels.MetricName {
+			metricName = l.Value
+			break
+		}
+	}
+	if metricName == "" {
+		return prompb.MetricMetadata{}, false, nil
+	}
+	return prompb.MetricMetadata{
+		Type:             prompb.MetricMetadata_MetricType(md.Type),
+		MetricFamilyName: metricName,
+		Help:             help,
+		Unit:             unit,
+	}, true, nil
+}
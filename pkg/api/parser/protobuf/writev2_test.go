@@ -0,0 +1,226 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package protobuf
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/prompb"
+	writev2 "github.com/timescale/promscale/pkg/prompb/io/prometheus/write/v2"
+)
+
+func TestParseRequestV2(t *testing.T) {
+	testCases := []struct {
+		name        string
+		request     *writev2.Request
+		expected    *prompb.WriteRequest
+		expectedErr string
+		// Number of samples and histograms marking created timestamps, which
+		// are expected as zeros.
+		createdZeros int
+	}{
+		{
+			name: "samples, exemplars and metadata",
+			request: &writev2.Request{
+				Symbols: []string{"", "__name__", "http_requests_total", "job", "api", "trace_id", "abc", "Total requests.", "requests"},
+				Timeseries: []writev2.TimeSeries{
+					{
+						LabelsRefs: []uint32{1, 2, 3, 4},
+						Samples:    []writev2.Sample{{Value: 5, Timestamp: 2000}},
+						Exemplars:  []writev2.Exemplar{{LabelsRefs: []uint32{5, 6}, Value: 1, Timestamp: 2000}},
+						Metadata: writev2.Metadata{
+							Type:    writev2.Metadata_METRIC_TYPE_COUNTER,
+							HelpRef: 7,
+							UnitRef: 8,
+						},
+						CreatedTimestamp: 1000,
+					},
+					{
+						// Same metric, metadata must only be attached once.
+						LabelsRefs: []uint32{1, 2, 3, 2},
+						Samples:    []writev2.Sample{{Value: 1, Timestamp: 2000}},
+						Metadata:   writev2.Metadata{Type: writev2.Metadata_METRIC_TYPE_COUNTER, HelpRef: 7, UnitRef: 8},
+						// Created timestamp newer than the samples is ignored.
+						CreatedTimestamp: 3000,
+					},
+				},
+			},
+			expected: &prompb.WriteRequest{
+				Timeseries: []prompb.TimeSeries{
+					{
+						Labels:    []prompb.Label{{Name: "__name__", Value: "http_requests_total"}, {Name: "job", Value: "api"}},
+						Samples:   []prompb.Sample{{Value: 0, Timestamp: 1000}, {Value: 5, Timestamp: 2000}},
+						Exemplars: []prompb.Exemplar{{Labels: []prompb.Label{{Name: "trace_id", Value: "abc"}}, Value: 1, Timestamp: 2000}},
+					},
+					{
+						Labels:  []prompb.Label{{Name: "__name__", Value: "http_requests_total"}, {Name: "job", Value: "http_requests_total"}},
+						Samples: []prompb.Sample{{Value: 1, Timestamp: 2000}},
+					},
+				},
+				Metadata: []prompb.MetricMetadata{{
+					Type:             prompb.MetricMetadata_COUNTER,
+					MetricFamilyName: "http_requests_total",
+					Help:             "Total requests.",
+					Unit:             "requests",
+				}},
+			},
+			createdZeros: 1,
+		},
+		{
+			name: "native histograms",
+			request: &writev2.Request{
+				Symbols: []string{"", "__name__", "latency"},
+				Timeseries: []writev2.TimeSeries{{
+					LabelsRefs: []uint32{1, 2},
+					Histograms: []writev2.Histogram{{
+						Count:          &writev2.Histogram_CountInt{CountInt: 3},
+						Sum:            1.5,
+						Schema:         2,
+						ZeroThreshold:  0.001,
+						ZeroCount:      &writev2.Histogram_ZeroCountInt{ZeroCountInt: 1},
+						PositiveSpans:  []writev2.BucketSpan{{Offset: 1, Length: 2}},
+						PositiveDeltas: []int64{1, 0},
+						ResetHint:      writev2.Histogram_RESET_HINT_NO,
+						Timestamp:      2000,
+					}},
+					CreatedTimestamp: 1000,
+				}},
+			},
+			expected: &prompb.WriteRequest{
+				Timeseries: []prompb.TimeSeries{{
+					Labels: []prompb.Label{{Name: "__name__", Value: "latency"}},
+					Histograms: []prompb.Histogram{
+						{
+							Count:         &prompb.Histogram_CountInt{},
+							Schema:        2,
+							ZeroThreshold: 0.001,
+							ZeroCount:     &prompb.Histogram_ZeroCountInt{},
+							ResetHint:     prompb.Histogram_NO,
+							Timestamp:     1000,
+						},
+						{
+							Count:          &prompb.Histogram_CountInt{CountInt: 3},
+							Sum:            1.5,
+							Schema:         2,
+							ZeroThreshold:  0.001,
+							ZeroCount:      &prompb.Histogram_ZeroCountInt{ZeroCountInt: 1},
+							PositiveSpans:  []prompb.BucketSpan{{Offset: 1, Length: 2}},
+							PositiveDeltas: []int64{1, 0},
+							ResetHint:      prompb.Histogram_NO,
+							Timestamp:      2000,
+						},
+					},
+				}},
+			},
+			createdZeros: 1,
+		},
+		{
+			name: "gauges have no created timestamp zeros",
+			request: &writev2.Request{
+				Symbols: []string{"", "__name__", "temperature"},
+				Timeseries: []writev2.TimeSeries{
+					{
+						LabelsRefs:       []uint32{1, 2},
+						Samples:          []writev2.Sample{{Value: 20, Timestamp: 2000}},
+						Metadata:         writev2.Metadata{Type: writev2.Metadata_METRIC_TYPE_GAUGE},
+						CreatedTimestamp: 1000,
+					},
+					{
+						LabelsRefs: []uint32{1, 2},
+						Histograms: []writev2.Histogram{{
+							Count:     &writev2.Histogram_CountInt{CountInt: 1},
+							ZeroCount: &writev2.Histogram_ZeroCountInt{},
+							ResetHint: writev2.Histogram_RESET_HINT_GAUGE,
+							Timestamp: 2000,
+						}},
+						CreatedTimestamp: 1000,
+					},
+				},
+			},
+			expected: &prompb.WriteRequest{
+				Timeseries: []prompb.TimeSeries{
+					{
+						Labels:  []prompb.Label{{Name: "__name__", Value: "temperature"}},
+						Samples: []prompb.Sample{{Value: 20, Timestamp: 2000}},
+					},
+					{
+						Labels: []prompb.Label{{Name: "__name__", Value: "temperature"}},
+						Histograms: []prompb.Histogram{{
+							Count:     &prompb.Histogram_CountInt{CountInt: 1},
+							ZeroCount: &prompb.Histogram_ZeroCountInt{},
+							ResetHint: prompb.Histogram_GAUGE,
+							Timestamp: 2000,
+						}},
+					},
+				},
+				Metadata: []prompb.MetricMetadata{{
+					Type:             prompb.MetricMetadata_GAUGE,
+					MetricFamilyName: "temperature",
+				}},
+			},
+		},
+		{
+			name: "label reference out of range",
+			request: &writev2.Request{
+				Symbols:    []string{"", "__name__"},
+				Timeseries: []writev2.TimeSeries{{LabelsRefs: []uint32{1, 2}}},
+			},
+			expectedErr: "series 0: symbol reference 2 out of range, symbol table has 2 entries",
+		},
+		{
+			name: "odd number of label references",
+			request: &writev2.Request{
+				Symbols:    []string{"", "__name__"},
+				Timeseries: []writev2.TimeSeries{{LabelsRefs: []uint32{1}}},
+			},
+			expectedErr: "series 0: odd number of label references: 1",
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			data, err := proto.Marshal(c.request)
+			require.NoError(t, err)
+			r := &http.Request{Body: io.NopCloser(bytes.NewReader(data))}
+
+			wr := &prompb.WriteRequest{}
+			err = ParseRequestV2(r, wr)
+			if c.expectedErr != "" {
+				require.EqualError(t, err, c.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.createdZeros, replaceCreatedZeros(wr))
+			require.Equal(t, c.expected, wr)
+		})
+	}
+}
+
+// replaceCreatedZeros replaces the markers of created timestamps by zeros, which
+// can be compared unlike the NaN markers, and returns their number.
+func replaceCreatedZeros(wr *prompb.WriteRequest) int {
+	n := 0
+	for i := range wr.Timeseries {
+		ts := &wr.Timeseries[i]
+		for j := range ts.Samples {
+			if ts.Samples[j].IsCreatedZero() {
+				ts.Samples[j].Value = 0
+				n++
+			}
+		}
+		for j := range ts.Histograms {
+			if ts.Histograms[j].IsCreatedZero() {
+				ts.Histograms[j].Sum = 0
+				n++
+			}
+		}
+	}
+	return n
+}
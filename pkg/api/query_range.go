@@ -12,18 +12,22 @@ import (
 
 	"github.com/NYTimes/gziphandler"
 	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/storage"
 
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/promql"
 	"github.com/timescale/promscale/pkg/query"
+	"github.com/timescale/promscale/pkg/query/resultscache"
+	"github.com/timescale/promscale/pkg/tenancy"
 )
 
-func QueryRange(conf *Config, promqlConf *query.Config, queryEngine *promql.Engine, queryable promql.Queryable, updateMetrics func(handler, code string, duration float64)) http.Handler {
-	hf := corsWrapper(conf, queryRange(promqlConf, queryEngine, queryable, updateMetrics))
+func QueryRange(conf *Config, promqlConf *query.Config, queryEngine *promql.Engine, queryable promql.Queryable, resultsCache *resultscache.Cache, updateMetrics func(handler, code string, duration float64)) http.Handler {
+	hf := corsWrapper(conf, queryRange(promqlConf, queryEngine, queryable, resultsCache, updateMetrics))
 	return gziphandler.GzipHandler(hf)
 }
 
-func queryRange(promqlConf *query.Config, queryEngine *promql.Engine, queryable promql.Queryable, updateMetrics func(handler, code string, duration float64)) http.HandlerFunc {
+func queryRange(promqlConf *query.Config, queryEngine *promql.Engine, queryable promql.Queryable, resultsCache *resultscache.Cache, updateMetrics func(handler, code string, duration float64)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		statusCode := "400"
 		begin := time.Now()
@@ -87,14 +91,27 @@ func queryRange(promqlConf *query.Config, queryEngine *promql.Engine, queryable
 			defer cancel()
 		}
 
-		qry, err := queryEngine.NewRangeQuery(
-			queryable,
-			&promql.QueryOpts{EnablePerStepStats: true},
-			r.FormValue("query"),
-			start,
-			end,
-			step,
-		)
+		var res *promql.Result
+		q := r.FormValue("query")
+		tenants, restricted := tenancy.AuthorizedTenants(ctx, nil)
+		// Principals restricted to no tenants read no data, there is nothing worth caching.
+		if resultsCache != nil && step%time.Millisecond == 0 && (!restricted || len(tenants) > 0) && resultsCache.Cacheable(q) {
+			key := resultscache.Key(q, step, tenants, restricted)
+			res, err = cachedQueryRange(ctx, resultsCache, key, queryEngine, queryable, q, start, end, step)
+		} else {
+			var qry promql.Query
+			qry, err = queryEngine.NewRangeQuery(
+				queryable,
+				&promql.QueryOpts{EnablePerStepStats: true},
+				q,
+				start,
+				end,
+				step,
+			)
+			if err == nil {
+				res = qry.Exec(ctx)
+			}
+		}
 		if err != nil {
 			statusCode = "400"
 			log.Info("msg", "Query parse error: "+err.Error())
@@ -102,8 +119,6 @@ func queryRange(promqlConf *query.Config, queryEngine *promql.Engine, queryable
 			return
 		}
 
-		res := qry.Exec(ctx)
-
 		if res.Err != nil {
 			log.Error("msg", res.Err, "endpoint", "query_range")
 			switch res.Err.(type) {
@@ -128,3 +143,30 @@ func queryRange(promqlConf *query.Config, queryEngine *promql.Engine, queryable
 		respondQuery(w, res, res.Warnings)
 	}
 }
+
+// cachedQueryRange evaluates a range query aligned to its step, reading the
+// results older than the freshness window from the results cache under key.
+// Errors creating the query are returned, and errors evaluating it are in the
+// result.
+func cachedQueryRange(ctx context.Context, resultsCache *resultscache.Cache, key string, queryEngine *promql.Engine, queryable promql.Queryable, q string, start, end time.Time, step time.Duration) (*promql.Result, error) {
+	stepMs := step.Milliseconds()
+	startMs, endMs := resultscache.AlignToStep(timestamp.FromTime(start), timestamp.FromTime(end), stepMs)
+	var queryErr error
+	matrix, warnings, err := resultsCache.QueryRange(key, startMs, endMs, stepMs, func(start, end int64) (promql.Matrix, storage.Warnings, error) {
+		qry, err := queryEngine.NewRangeQuery(queryable, &promql.QueryOpts{EnablePerStepStats: true}, q, timestamp.Time(start), timestamp.Time(end), step)
+		if err != nil {
+			queryErr = err
+			return nil, nil, err
+		}
+		res := qry.Exec(ctx)
+		if res.Err != nil {
+			return nil, res.Warnings, res.Err
+		}
+		matrix, _ := res.Value.(promql.Matrix)
+		return matrix, res.Warnings, nil
+	})
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	return &promql.Result{Value: matrix, Warnings: warnings, Err: err}, nil
+}
@@ -16,9 +16,15 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/timescale/promscale/pkg/auth"
 	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/querier"
 	"github.com/timescale/promscale/pkg/promql"
 	"github.com/timescale/promscale/pkg/query"
+	"github.com/timescale/promscale/pkg/query/resultscache"
 )
 
 func TestRangedQuery(t *testing.T) {
@@ -162,7 +168,7 @@ func TestRangedQuery(t *testing.T) {
 				},
 			)
 
-			handler := queryRange(&query.Config{MaxPointsPerTs: 11000}, engine, query.NewQueryable(tc.querier, nil), mockUpdaterForQuery(&mockMetric{}, nil))
+			handler := queryRange(&query.Config{MaxPointsPerTs: 11000}, engine, query.NewQueryable(tc.querier, nil), nil, mockUpdaterForQuery(&mockMetric{}, nil))
 			queryUrl := constructRangedQuery(tc.metric, tc.start, tc.end, tc.step, tc.timeout)
 			w := doRangedQuery(t, handler, queryUrl, tc.canceled)
 
@@ -184,6 +190,64 @@ func TestRangedQuery(t *testing.T) {
 
 }
 
+// countingQuerier counts the selects of the queries evaluated.
+type countingQuerier struct {
+	mockQuerier
+	selects *int
+}
+
+func (m countingQuerier) SamplesQuerier(_ context.Context) querier.SamplesQuerier {
+	return m
+}
+
+func (m countingQuerier) Select(mint, maxt int64, sortSeries bool, hints *storage.SelectHints, qh *querier.QueryHints, path []parser.Node, ms ...*labels.Matcher) (querier.SeriesSet, parser.Node) {
+	*m.selects++
+	return m.mockQuerier.Select(mint, maxt, sortSeries, hints, qh, path, ms...)
+}
+
+func TestRangedQueryResultsCacheTenants(t *testing.T) {
+	engine := promql.NewEngine(
+		promql.EngineOpts{
+			Logger:     log.GetLogger(),
+			Reg:        prometheus.NewRegistry(),
+			MaxSamples: math.MaxInt32,
+			Timeout:    time.Minute,
+		},
+	)
+	selects := 0
+	cache := resultscache.New(resultscache.NewMemoryBackend(100, 1<<20, time.Hour), time.Minute)
+	handler := queryRange(&query.Config{MaxPointsPerTs: 11000}, engine, query.NewQueryable(countingQuerier{selects: &selects}, nil), cache, mockUpdaterForQuery(&mockMetric{}, nil))
+	queryUrl := constructRangedQuery("m", "1", "2", "1s", "30s")
+	run := func(p *auth.Principal) {
+		t.Helper()
+		req := httptest.NewRequest("GET", queryUrl, nil).WithContext(auth.NewContext(context.Background(), p))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected HTTP status code received: got %d wanted %d", w.Code, http.StatusOK)
+		}
+	}
+
+	// The results of an unrestricted principal are cached.
+	run(nil)
+	run(nil)
+	if selects != 1 {
+		t.Fatalf("expected the query to be cached, got %d selects", selects)
+	}
+
+	// A principal restricted to no tenants does not read them.
+	run(&auth.Principal{Name: "no-tenants", Tenants: []string{}})
+	if selects != 2 {
+		t.Fatalf("expected the query to be evaluated again, got %d selects", selects)
+	}
+
+	// Nor do principals restricted to some tenants.
+	run(&auth.Principal{Name: "tenant-a", Tenants: []string{"a"}})
+	if selects != 3 {
+		t.Fatalf("expected the query to be evaluated again, got %d selects", selects)
+	}
+}
+
 func constructRangedQuery(metric, start, end, step, timeout string) string {
 	return fmt.Sprintf(
 		"http://localhost:9090/query_range?query=%s&start=%s&end=%s&step=%s&timeout=%s",
@@ -20,6 +20,7 @@ import (
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/lreader"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/pgmodel/querier"
 	"github.com/timescale/promscale/pkg/prompb"
@@ -88,13 +89,20 @@ func (me mockExemplarQuerier) Select(_, _ time.Time, _ ...[]*labels.Matcher) ([]
 type mockLabelsReader struct {
 	labelNames    []string
 	labelNamesErr error
+	labelValues   map[string][]string
+	queries       []lreader.LabelsQuery
 }
 
-func (m mockLabelsReader) LabelNames() ([]string, error) {
+func (m *mockLabelsReader) LabelNames(_ context.Context, q lreader.LabelsQuery) ([]string, error) {
+	m.queries = append(m.queries, q)
 	return m.labelNames, m.labelNamesErr
 }
 
-func (m mockLabelsReader) LabelValues(string) ([]string, error) {
+func (m *mockLabelsReader) LabelValues(_ context.Context, _ string, q lreader.LabelsQuery) ([]string, error) {
+	m.queries = append(m.queries, q)
+	if len(q.Matchers) > 0 {
+		return m.labelValues[q.Matchers[0].Value], nil
+	}
 	return nil, nil
 }
 
@@ -17,33 +17,55 @@ import (
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 
 	"github.com/timescale/promscale/pkg/api/parser"
+	"github.com/timescale/promscale/pkg/auth"
 	"github.com/timescale/promscale/pkg/ha"
 	haClient "github.com/timescale/promscale/pkg/ha/client"
 	"github.com/timescale/promscale/pkg/jaeger"
 	jaegerStore "github.com/timescale/promscale/pkg/jaeger/store"
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgclient"
+	"github.com/timescale/promscale/pkg/pgmodel/cardinality"
 	pgMetrics "github.com/timescale/promscale/pkg/pgmodel/metrics"
 	"github.com/timescale/promscale/pkg/query"
+	"github.com/timescale/promscale/pkg/query/resultscache"
 	"github.com/timescale/promscale/pkg/telemetry"
 )
 
-// TODO: Refactor this function to reduce number of paramaters.
-func GenerateRouter(apiConf *Config, promqlConf *query.Config, client *pgclient.Client, store *jaegerStore.Store, authWrapper mux.MiddlewareFunc, reload func() error) (*mux.Router, error) {
+// NewWriteParser returns the parser for incoming write requests, along with the
+// preprocessors enabled by the configuration. The same parser must be shared by
+// all the write endpoints, as preprocessors can hold state (e.g. HA leases).
+func NewWriteParser(apiConf *Config, client *pgclient.Client) *parser.DefaultParser {
 	var writePreprocessors []parser.Preprocessor
 	if apiConf.HighAvailability {
-		service := ha.NewService(haClient.NewLeaseClient(client.ReadOnlyConnection()))
-		writePreprocessors = append(writePreprocessors, ha.NewFilter(service))
+		service := apiConf.HAService
+		if service == nil {
+			service = ha.NewService(haClient.NewLeaseClient(client.ReadOnlyConnection()))
+		}
+		writePreprocessors = append(writePreprocessors, ha.NewFilterWith(service, apiConf.HAClusterLabel, apiConf.HAReplicaLabel))
+	}
+	if apiConf.Relabeler != nil {
+		// Relabeling runs before the write authorizer, so that the tenant of the
+		// relabeled series is checked: rules may rewrite or drop the tenant label,
+		// but cannot move series to a tenant the request is not authorized for.
+		writePreprocessors = append(writePreprocessors, apiConf.Relabeler)
 	}
 	if apiConf.MultiTenancy != nil {
 		writePreprocessors = append(writePreprocessors, apiConf.MultiTenancy.WriteAuthorizer())
 	}
+	if apiConf.TenantLimits != nil {
+		// Limits are enforced per tenant, hence they must be checked after the write authorizer.
+		writePreprocessors = append(writePreprocessors, apiConf.TenantLimits)
+	}
 
 	dataParser := parser.NewParser()
 	for _, preproc := range writePreprocessors {
 		dataParser.AddPreprocessor(preproc)
 	}
+	return dataParser
+}
 
+// TODO: Refactor this function to reduce number of paramaters.
+func GenerateRouter(apiConf *Config, promqlConf *query.Config, client *pgclient.Client, dataParser *parser.DefaultParser, store *jaegerStore.Store, authWrapper mux.MiddlewareFunc, reload func() error) (*mux.Router, error) {
 	writeHandler := timeHandler(metrics.HTTPRequestDuration, "write", otelhttp.NewHandler(Write(client, dataParser, updateIngestMetrics), "write-metrics"))
 
 	// If we are running in read-only mode, log and send NotFound status.
@@ -56,57 +78,107 @@ func GenerateRouter(apiConf *Config, promqlConf *query.Config, client *pgclient.
 		router.Use(authWrapper)
 	}
 
-	router.Path("/write").Methods(http.MethodPost).HandlerFunc(writeHandler)
+	router.Path("/write").Methods(http.MethodPost).Handler(auth.RequireScope(auth.WriteScope, writeHandler))
 
-	readHandler := timeHandler(metrics.HTTPRequestDuration, "read", Read(apiConf, client, metrics, updateQueryMetrics))
-	router.Path("/read").Methods(http.MethodGet, http.MethodPost).HandlerFunc(readHandler)
+	if apiConf.OTLPMetrics != nil {
+		otlpMetricsHandler := timeHandler(metrics.HTTPRequestDuration, "otlp_metrics", otelhttp.NewHandler(OTLPMetrics(client, apiConf.OTLPMetrics, dataParser), "write-otlp-metrics"))
+		if apiConf.ReadOnly {
+			otlpMetricsHandler = withWarnLog("trying to send OTLP metrics to write API while connector is in read-only mode", http.NotFoundHandler())
+		}
+		router.Path("/v1/metrics").Methods(http.MethodPost).Handler(auth.RequireScope(auth.WriteScope, otlpMetricsHandler))
+	}
+
+	readHandler := timeHandler(metrics.HTTPRequestDuration, "read", queryLimitWrapper(apiConf, Read(apiConf, client, metrics, updateQueryMetrics)))
+	router.Path("/read").Methods(http.MethodGet, http.MethodPost).Handler(auth.RequireScope(auth.ReadScope, readHandler))
 
-	deleteHandler := timeHandler(metrics.HTTPRequestDuration, "delete_series", Delete(apiConf, client))
-	router.Path("/delete_series").Methods(http.MethodPut, http.MethodPost).HandlerFunc(deleteHandler)
+	var resultsCache *resultscache.Cache
+	if promqlConf.ResultsCacheEnabled {
+		resultsCache = resultscache.New(resultscache.NewMemoryBackend(promqlConf.ResultsCacheMaxEntries, promqlConf.ResultsCacheMaxBytes, promqlConf.ResultsCacheTTL), promqlConf.ResultsCacheFreshness)
+		if apiConf.DeleteJobs != nil {
+			apiConf.DeleteJobs.OnDelete(resultsCache.Invalidate)
+		}
+	}
+
+	deleteHandler := timeHandler(metrics.HTTPRequestDuration, "delete_series", Delete(apiConf, client, resultsCache))
+	router.Path("/delete_series").Methods(http.MethodPut, http.MethodPost).Handler(auth.RequireScope(auth.AdminScope, deleteHandler))
 
 	queryable := client.Queryable()
 	queryEngine := client.QueryEngine()
 
 	apiV1 := router.PathPrefix("/api/v1").Subrouter()
-	queryHandler := timeHandler(metrics.HTTPRequestDuration, "query", Query(apiConf, queryEngine, queryable, updateQueryMetrics))
-	apiV1.Path("/query").Methods(http.MethodGet, http.MethodPost).HandlerFunc(queryHandler)
+	queryHandler := timeHandler(metrics.HTTPRequestDuration, "query", queryLimitWrapper(apiConf, Query(apiConf, queryEngine, queryable, updateQueryMetrics)))
+	apiV1.Path("/query").Methods(http.MethodGet, http.MethodPost).Handler(auth.RequireScope(auth.ReadScope, queryHandler))
 
-	queryRangeHandler := timeHandler(metrics.HTTPRequestDuration, "query_range", QueryRange(apiConf, promqlConf, queryEngine, queryable, updateQueryMetrics))
-	apiV1.Path("/query_range").Methods(http.MethodGet, http.MethodPost).HandlerFunc(queryRangeHandler)
+	queryRangeHandler := timeHandler(metrics.HTTPRequestDuration, "query_range", queryLimitWrapper(apiConf, QueryRange(apiConf, promqlConf, queryEngine, queryable, resultsCache, updateQueryMetrics)))
+	apiV1.Path("/query_range").Methods(http.MethodGet, http.MethodPost).Handler(auth.RequireScope(auth.ReadScope, queryRangeHandler))
 
-	exemplarQueryHandler := timeHandler(metrics.HTTPRequestDuration, "query_exemplar", QueryExemplar(apiConf, queryable, updateQueryMetrics))
-	apiV1.Path("/query_exemplars").Methods(http.MethodGet, http.MethodPost).HandlerFunc(exemplarQueryHandler)
+	exemplarQueryHandler := timeHandler(metrics.HTTPRequestDuration, "query_exemplar", queryLimitWrapper(apiConf, QueryExemplar(apiConf, queryable, updateQueryMetrics)))
+	apiV1.Path("/query_exemplars").Methods(http.MethodGet, http.MethodPost).Handler(auth.RequireScope(auth.ReadScope, exemplarQueryHandler))
 
-	seriesHandler := timeHandler(metrics.HTTPRequestDuration, "series", Series(apiConf, queryable))
-	apiV1.Path("/series").Methods(http.MethodGet, http.MethodPost).HandlerFunc(seriesHandler)
+	seriesHandler := timeHandler(metrics.HTTPRequestDuration, "series", queryLimitWrapper(apiConf, Series(apiConf, queryable)))
+	apiV1.Path("/series").Methods(http.MethodGet, http.MethodPost).Handler(auth.RequireScope(auth.ReadScope, seriesHandler))
 
-	labelsHandler := timeHandler(metrics.HTTPRequestDuration, "labels", Labels(apiConf, queryable))
-	apiV1.Path("/labels").Methods(http.MethodGet, http.MethodPost).HandlerFunc(labelsHandler)
+	labelsHandler := timeHandler(metrics.HTTPRequestDuration, "labels", queryLimitWrapper(apiConf, Labels(apiConf, queryable)))
+	apiV1.Path("/labels").Methods(http.MethodGet, http.MethodPost).Handler(auth.RequireScope(auth.ReadScope, labelsHandler))
 
 	metadataHandler := timeHandler(metrics.HTTPRequestDuration, "metadata", MetricMetadata(apiConf, client))
-	apiV1.Path("/metadata").Methods(http.MethodGet, http.MethodPost).HandlerFunc(metadataHandler)
+	apiV1.Path("/metadata").Methods(http.MethodGet, http.MethodPost).Handler(auth.RequireScope(auth.ReadScope, metadataHandler))
 
 	rulesHandler := timeHandler(metrics.HTTPRequestDuration, "rules", Rules(apiConf, updateQueryMetrics))
-	apiV1.Path("/rules").Methods(http.MethodGet).HandlerFunc(rulesHandler)
+	apiV1.Path("/rules").Methods(http.MethodGet).Handler(auth.RequireScope(auth.ReadScope, rulesHandler))
 
 	alertsHandler := timeHandler(metrics.HTTPRequestDuration, "alerts", Alerts(apiConf, updateQueryMetrics))
-	apiV1.Path("/alerts").Methods(http.MethodGet).HandlerFunc(alertsHandler)
+	apiV1.Path("/alerts").Methods(http.MethodGet).Handler(auth.RequireScope(auth.ReadScope, alertsHandler))
+
+	logsHandler := timeHandler(metrics.HTTPRequestDuration, "logs/query", QueryLogs(apiConf, client.ReadOnlyConnection()))
+	apiV1.Path("/logs/query").Methods(http.MethodGet, http.MethodPost).Handler(auth.RequireScope(auth.ReadScope, logsHandler))
+
+	deleteJobsHandler := timeHandler(metrics.HTTPRequestDuration, "admin/delete_jobs", DeleteJobs(apiConf))
+	apiV1.Path("/admin/delete_jobs").Methods(http.MethodGet).Handler(auth.RequireScope(auth.AdminScope, deleteJobsHandler))
+
+	deleteJobHandler := timeHandler(metrics.HTTPRequestDuration, "admin/delete_jobs/:id", DeleteJob(apiConf))
+	apiV1.Path("/admin/delete_jobs/{id}").Methods(http.MethodGet).Handler(auth.RequireScope(auth.AdminScope, deleteJobHandler))
+
+	cancelDeleteJobHandler := timeHandler(metrics.HTTPRequestDuration, "admin/delete_jobs/:id/cancel", CancelDeleteJob(apiConf))
+	apiV1.Path("/admin/delete_jobs/{id}/cancel").Methods(http.MethodPost).Handler(auth.RequireScope(auth.AdminScope, cancelDeleteJobHandler))
+
+	haLeasesHandler := timeHandler(metrics.HTTPRequestDuration, "admin/ha/leases", HALeases(apiConf))
+	apiV1.Path("/admin/ha/leases").Methods(http.MethodGet).Handler(auth.RequireScope(auth.AdminScope, haLeasesHandler))
+
+	haFailoverHandler := timeHandler(metrics.HTTPRequestDuration, "admin/ha/leases/:cluster/failover", HAFailover(apiConf))
+	apiV1.Path("/admin/ha/leases/{cluster}/failover").Methods(http.MethodPost).Handler(auth.RequireScope(auth.AdminScope, haFailoverHandler))
+
+	haPinHandler := timeHandler(metrics.HTTPRequestDuration, "admin/ha/leases/:cluster/pin", HAPin(apiConf))
+	apiV1.Path("/admin/ha/leases/{cluster}/pin").Methods(http.MethodPost, http.MethodDelete).Handler(auth.RequireScope(auth.AdminScope, haPinHandler))
+
+	cardinalityReader := cardinality.NewReader(client.ReadOnlyConnection())
+	tsdbStatusHandler := timeHandler(metrics.HTTPRequestDuration, "status/tsdb", TSDBStatus(apiConf, cardinalityReader))
+	apiV1.Path("/status/tsdb").Methods(http.MethodGet).Handler(auth.RequireScope(auth.ReadScope, tsdbStatusHandler))
+
+	cardinalityHandler := timeHandler(metrics.HTTPRequestDuration, "status/cardinality", Cardinality(apiConf, cardinalityReader))
+	apiV1.Path("/status/cardinality").Methods(http.MethodGet, http.MethodPost).Handler(auth.RequireScope(auth.ReadScope, cardinalityHandler))
+
+	duplicatesHandler := timeHandler(metrics.HTTPRequestDuration, "status/duplicates", Duplicates(apiConf))
+	apiV1.Path("/status/duplicates").Methods(http.MethodGet).Handler(auth.RequireScope(auth.AdminScope, duplicatesHandler))
 
-	labelValuesHandler := timeHandler(metrics.HTTPRequestDuration, "label/:name/values", LabelValues(apiConf, queryable))
-	apiV1.Path("/label/{name}/values").Methods(http.MethodGet).HandlerFunc(labelValuesHandler)
+	labelValuesHandler := timeHandler(metrics.HTTPRequestDuration, "label/:name/values", queryLimitWrapper(apiConf, LabelValues(apiConf, queryable)))
+	apiV1.Path("/label/{name}/values").Methods(http.MethodGet).Handler(auth.RequireScope(auth.ReadScope, labelValuesHandler))
 
 	healthChecker := func() error { return client.HealthCheck() }
 	router.Path("/healthz").Methods(http.MethodGet, http.MethodOptions, http.MethodHead).HandlerFunc(Health(healthChecker))
 	router.Path(apiConf.TelemetryPath).Methods(http.MethodGet).HandlerFunc(promhttp.Handler().ServeHTTP)
 
 	reloadHandler := timeHandler(metrics.HTTPRequestDuration, "/-/reload", Reload(reload, apiConf.AdminAPIEnabled))
-	router.Path("/-/reload").Methods(http.MethodPost).HandlerFunc(reloadHandler)
+	router.Path("/-/reload").Methods(http.MethodPost).Handler(auth.RequireScope(auth.AdminScope, reloadHandler))
 
 	if store != nil {
-		jaeger.ExtendQueryAPIs(router, client.ReadOnlyConnection(), store)
+		jaegerRouter := router.NewRoute().Subrouter()
+		jaegerRouter.Use(func(h http.Handler) http.Handler { return auth.RequireScope(auth.ReadScope, h) })
+		jaeger.ExtendQueryAPIs(jaegerRouter, client.ReadOnlyConnection(), store)
 	}
 
 	debugProf := router.PathPrefix("/debug/pprof").Subrouter()
+	debugProf.Use(func(h http.Handler) http.Handler { return auth.RequireScope(auth.AdminScope, h) })
 	debugProf.Path("").Methods(http.MethodGet).HandlerFunc(pprof.Index)
 	debugProf.Path("/cmdline").Methods(http.MethodGet).HandlerFunc(pprof.Cmdline)
 	debugProf.Path("/profile").Methods(http.MethodGet).HandlerFunc(pprof.Profile)
@@ -119,7 +191,7 @@ func GenerateRouter(apiConf *Config, promqlConf *query.Config, client *pgclient.
 	debugProf.Path("/allocs").Methods(http.MethodGet).HandlerFunc(pprof.Handler("allocs").ServeHTTP)
 	debugProf.Path("/mutex").Methods(http.MethodGet).HandlerFunc(pprof.Handler("mutex").ServeHTTP)
 
-	router.Path("/debug/fgprof").Methods(http.MethodGet).HandlerFunc(fgprof.Handler().ServeHTTP)
+	router.Path("/debug/fgprof").Methods(http.MethodGet).Handler(auth.RequireScope(auth.AdminScope, fgprof.Handler()))
 	return router, nil
 }
 
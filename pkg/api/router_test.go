@@ -12,6 +12,13 @@ import (
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/prompb"
+	"github.com/timescale/promscale/pkg/relabel"
+	"github.com/timescale/promscale/pkg/tenancy"
 )
 
 type mockHTTPHandler struct {
@@ -110,3 +117,28 @@ func generateHandleTester(t *testing.T, handleFunc http.Handler) HandleTester {
 		return w
 	}
 }
+
+func TestNewWriteParserChecksRelabeledTenant(t *testing.T) {
+	var rules relabel.Rules
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+metrics:
+  relabel_configs:
+    - target_label: __tenant__
+      replacement: b
+`), &rules))
+	authorizer, err := tenancy.NewAuthorizer(tenancy.NewSelectiveTenancyConfig([]string{"a"}, false, false))
+	require.NoError(t, err)
+	dataParser := NewWriteParser(&Config{Relabeler: relabel.NewRelabeler(rules), MultiTenancy: authorizer}, nil)
+
+	r := httptest.NewRequest(http.MethodPost, "/write", nil)
+	wr := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{{
+		Labels: []prompb.Label{
+			{Name: model.MetricNameLabelName, Value: "test"},
+			{Name: tenancy.TenantLabelKey, Value: "a"},
+		},
+		Samples: []prompb.Sample{{Timestamp: 1, Value: 1}},
+	}}}
+	// The tenant set by the rules is not allowed, even though the request
+	// was sent for an allowed one.
+	require.ErrorIs(t, dataParser.Preprocess(r, wr), tenancy.ErrUnauthorizedTenant)
+}
@@ -6,19 +6,26 @@ package api
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"mime"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/golang/snappy"
 	"github.com/timescale/promscale/pkg/api/parser"
+	"github.com/timescale/promscale/pkg/api/parser/protobuf"
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/serieslimit"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/prompb"
+	"github.com/timescale/promscale/pkg/tenancy"
 	"github.com/timescale/promscale/pkg/tracer"
 )
 
@@ -71,7 +78,7 @@ func validateWriteHeaders(w http.ResponseWriter, r *http.Request) bool {
 		return false
 	}
 
-	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
 	if err != nil {
 		validateError(w, "Error parsing media type from Content-Type header", metrics)
 		return false
@@ -83,14 +90,27 @@ func validateWriteHeaders(w http.ResponseWriter, r *http.Request) bool {
 			return false
 		}
 
+		expectedVersion := "0.1."
+		switch params["proto"] {
+		case "", protobuf.RemoteWriteV1Message:
+		case protobuf.RemoteWriteV2Message:
+			expectedVersion = "2.0."
+		default:
+			// Remote-Write 2.0 specification requires 415 for unknown messages, so that
+			// senders can fall back to a different message.
+			log.Error("msg", "Write header validation error", "err", "unsupported protobuf message "+params["proto"])
+			http.Error(w, fmt.Sprintf("unsupported protobuf message %s", params["proto"]), http.StatusUnsupportedMediaType)
+			return false
+		}
+
 		remoteWriteVersion := r.Header.Get("X-Prometheus-Remote-Write-Version")
 		if remoteWriteVersion == "" {
 			validateError(w, "Missing X-Prometheus-Remote-Write-Version header", metrics)
 			return false
 		}
 
-		if !strings.HasPrefix(remoteWriteVersion, "0.1.") {
-			validateError(w, fmt.Sprintf("unexpected Remote-Write-Version %s, expected 0.1.X", remoteWriteVersion), metrics)
+		if !strings.HasPrefix(remoteWriteVersion, expectedVersion) {
+			validateError(w, fmt.Sprintf("unexpected Remote-Write-Version %s, expected %sX", remoteWriteVersion, expectedVersion), metrics)
 			return false
 		}
 	case "application/json":
@@ -217,13 +237,30 @@ func ingest(
 				float64(numSamplesReceived), float64(numMetadataReceived),
 			)
 		}()
+		r = r.WithContext(model.NewSourceContext(r.Context(), newSource(r)))
 		ctx, span := tracer.Default().Start(r.Context(), "ingest")
 		defer span.End()
 
+		// Remote-Write 2.0 senders rely on the written headers to verify that the receiver
+		// understood the request, hence they are sent in every response, including errors.
+		isRemoteWriteV2 := parser.IsRemoteWriteV2(r)
+		setWrittenHeaders := func(stats writtenStats) {
+			if isRemoteWriteV2 {
+				stats.setHeaders(w)
+			}
+		}
+
 		req := ingestor.NewWriteRequest()
 		err := dataParser.ParseRequest(r, req)
 		if err != nil {
 			ingestor.FinishWriteRequest(req)
+			setWrittenHeaders(writtenStats{})
+			if errors.Is(err, tenancy.ErrLimitExceeded) {
+				statusCode = "429"
+				log.Warn("msg", "Write request rejected", "err", err)
+				http.Error(w, err.Error(), http.StatusTooManyRequests)
+				return false
+			}
 			invalidRequestError(w, "parser error", err.Error(), metrics)
 			return false
 		}
@@ -235,21 +272,56 @@ func ingest(
 		if len(req.Timeseries) == 0 && len(req.Metadata) == 0 {
 			statusCode = "2xx"
 			ingestor.FinishWriteRequest(req)
+			setWrittenHeaders(writtenStats{})
 			return false
 		}
 
+		// Ingestion takes ownership of the request, so the stats are collected beforehand.
+		stats := newWrittenStats(req)
 		numSamples, _, err := inserter.IngestMetrics(ctx, req)
+		if errors.Is(err, serieslimit.ErrLimitExceeded) {
+			statusCode = "429"
+			log.Warn("msg", "Write request rejected", "err", err)
+			setWrittenHeaders(writtenStats{})
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return false
+		}
+		// Rejected series are not retryable, the remote-write spec requires a 4xx
+		// for them. The other series are written, and reported as such. This
+		// includes the series dropped by a series limit, unlike the 429 of a
+		// rejected request, since the request is not to be retried.
+		var partialErr *ingestor.PartialWriteError
+		if errors.As(err, &partialErr) {
+			statusCode = "400"
+			log.Warn("msg", "Write request partially rejected", "err", err)
+			setWrittenHeaders(newWrittenStatsFromOutcomes(partialErr.Outcomes))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return false
+		}
 		if err != nil {
 			statusCode = "500"
 			log.Warn("msg", "Error sending samples to remote storage", "err", err, "num_samples", numSamples)
+			setWrittenHeaders(writtenStats{})
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return false
 		}
+		setWrittenHeaders(stats)
 		statusCode = "2xx"
 		return true
 	}
 }
 
+// newSource returns the source of a write request, to which its duplicate
+// samples are attributed. The HA replica is set by the HA filter.
+func newSource(r *http.Request) *model.Source {
+	client := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(client); err == nil {
+		// The port changes with every connection of the client.
+		client = host
+	}
+	return &model.Source{Tenant: tenancy.TenantFromRequest(r), Client: client}
+}
+
 func invalidRequestError(w http.ResponseWriter, msg, err string, m *Metrics) {
 	log.Error("msg", msg, "err", err)
 	http.Error(w, err, http.StatusBadRequest)
@@ -277,7 +349,63 @@ func detectSnappyStreamFormat(input []byte) bool {
 func getTotalSamples(wr *prompb.WriteRequest) int {
 	total := 0
 	for _, ts := range wr.Timeseries {
-		total += len(ts.Samples)
+		total += len(ts.Samples) + len(ts.Histograms)
 	}
 	return total
 }
+
+const (
+	samplesWrittenHeader    = "X-Prometheus-Remote-Write-Samples-Written"
+	histogramsWrittenHeader = "X-Prometheus-Remote-Write-Histograms-Written"
+	exemplarsWrittenHeader  = "X-Prometheus-Remote-Write-Exemplars-Written"
+)
+
+// writtenStats holds the number of samples, histograms and exemplars of a write
+// request, as reported to Remote-Write 2.0 senders.
+type writtenStats struct {
+	samples, histograms, exemplars int
+}
+
+// newWrittenStats returns the number of samples, histograms and exemplars of a
+// write request. The zeros marking created timestamps were not sent, hence are
+// not counted.
+func newWrittenStats(wr *prompb.WriteRequest) writtenStats {
+	var stats writtenStats
+	for _, ts := range wr.Timeseries {
+		stats.samples += len(ts.Samples)
+		stats.histograms += len(ts.Histograms)
+		stats.exemplars += len(ts.Exemplars)
+		if len(ts.Samples) > 0 && ts.Samples[0].IsCreatedZero() {
+			stats.samples--
+		}
+		if len(ts.Histograms) > 0 && ts.Histograms[0].IsCreatedZero() {
+			stats.histograms--
+		}
+	}
+	return stats
+}
+
+// newWrittenStatsFromOutcomes returns the number of samples, histograms and
+// exemplars written of a partially rejected write request. Duplicates count as
+// written, since another entry of their series is. The zeros written for created
+// timestamps were not sent, hence are not counted.
+func newWrittenStatsFromOutcomes(o ingestor.Outcomes) writtenStats {
+	stats := writtenStats{
+		samples:    -o.CreatedZeros.Samples,
+		histograms: -o.CreatedZeros.Histograms,
+	}
+	for _, outcome := range []ingestor.Outcome{ingestor.Accepted, ingestor.Duplicate} {
+		stats.samples += o.Counts[outcome].Samples
+		stats.histograms += o.Counts[outcome].Histograms
+		stats.exemplars += o.Counts[outcome].Exemplars
+	}
+	return stats
+}
+
+// setHeaders sets the written headers on the response. Headers are only sent to
+// the client if set before the response status is written.
+func (s writtenStats) setHeaders(w http.ResponseWriter) {
+	w.Header().Set(samplesWrittenHeader, strconv.Itoa(s.samples))
+	w.Header().Set(histogramsWrittenHeader, strconv.Itoa(s.histograms))
+	w.Header().Set(exemplarsWrittenHeader, strconv.Itoa(s.exemplars))
+}
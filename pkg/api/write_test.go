@@ -13,17 +13,22 @@ import (
 	"strings"
 	"testing"
 	"testing/iotest"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/snappy"
 	"github.com/prometheus/client_golang/prometheus"
 	io_prometheus_client "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 
 	"github.com/timescale/promscale/pkg/api/parser"
 	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
 	"github.com/timescale/promscale/pkg/prompb"
+	writev2 "github.com/timescale/promscale/pkg/prompb/io/prometheus/write/v2"
+	"github.com/timescale/promscale/pkg/tenancy"
 )
 
 func TestDetectSnappyStreamFormat(t *testing.T) {
@@ -93,6 +98,7 @@ func TestWrite(t *testing.T) {
 		receivedSamples int64
 		inserterErr     error
 		customHeaders   map[string]string
+		preprocessor    parser.Preprocessor
 	}{
 		{
 			name:         "write request body error",
@@ -211,6 +217,13 @@ func TestWrite(t *testing.T) {
 				"Content-Encoding": "snappy",
 			},
 		},
+		{
+			name:          "tenant limit exceeded",
+			responseCode:  http.StatusTooManyRequests,
+			requestBody:   `{"labels":{"labelName":"labelValue"}, "samples":[[1,2],[2,2],[3,2]]}`,
+			customHeaders: jsonHeaders,
+			preprocessor:  tenancy.NewLimiter(tenancy.LimitsConfig{Defaults: tenancy.Limits{IngestionRate: 1}}, time.Minute, nil),
+		},
 	}
 
 	for _, c := range testCases {
@@ -221,6 +234,9 @@ func TestWrite(t *testing.T) {
 			}
 			metrics = &Metrics{LastRequestUnixNano: 0}
 			dataParser := parser.NewParser()
+			if c.preprocessor != nil {
+				dataParser.AddPreprocessor(c.preprocessor)
+			}
 			numSamplesReceived := &mockMetric{}
 			handler := Write(mock, dataParser, mockUpdaterForIngest(&mockMetric{}, nil, numSamplesReceived, nil))
 
@@ -248,6 +264,129 @@ func TestWrite(t *testing.T) {
 	}
 }
 
+func TestWriteV2(t *testing.T) {
+	require.NoError(t, log.Init(log.Config{
+		Level: "debug",
+	}))
+
+	v2Headers := map[string]string{
+		"Content-Encoding":                  "snappy",
+		"Content-Type":                      "application/x-protobuf;proto=io.prometheus.write.v2.Request",
+		"X-Prometheus-Remote-Write-Version": "2.0.0",
+	}
+	request := &writev2.Request{
+		Symbols: []string{"", "__name__", "foo", "job", "bar", "trace_id", "1234"},
+		Timeseries: []writev2.TimeSeries{
+			{
+				LabelsRefs: []uint32{1, 2, 3, 4},
+				Samples:    []writev2.Sample{{Value: 1, Timestamp: 10}, {Value: 2, Timestamp: 20}},
+				Exemplars:  []writev2.Exemplar{{LabelsRefs: []uint32{5, 6}, Value: 1, Timestamp: 10}},
+				Metadata:   writev2.Metadata{Type: writev2.Metadata_METRIC_TYPE_COUNTER},
+				// The zero added for the created timestamp is not reported as written.
+				CreatedTimestamp: 5,
+			},
+			{
+				LabelsRefs: []uint32{1, 2},
+				Histograms: []writev2.Histogram{{Count: &writev2.Histogram_CountInt{CountInt: 1}, Timestamp: 10}},
+			},
+		},
+	}
+	data, err := proto.Marshal(request)
+	require.NoError(t, err)
+	body := string(snappy.Encode(nil, data))
+
+	testCases := []struct {
+		name            string
+		responseCode    int
+		headers         map[string]string
+		inserterErr     error
+		expectedWritten []string
+	}{
+		{
+			name:            "happy path",
+			responseCode:    http.StatusOK,
+			headers:         v2Headers,
+			expectedWritten: []string{"2", "1", "1"},
+		},
+		{
+			name:            "write error",
+			responseCode:    http.StatusInternalServerError,
+			headers:         v2Headers,
+			inserterErr:     fmt.Errorf("some error"),
+			expectedWritten: []string{"0", "0", "0"},
+		},
+		{
+			name:            "partial write",
+			responseCode:    http.StatusBadRequest,
+			headers:         v2Headers,
+			inserterErr:     partialWriteError(),
+			expectedWritten: []string{"2", "0", "1"},
+		},
+		{
+			name:         "wrong remote write version",
+			responseCode: http.StatusBadRequest,
+			headers: map[string]string{
+				"Content-Encoding":                  "snappy",
+				"Content-Type":                      "application/x-protobuf;proto=io.prometheus.write.v2.Request",
+				"X-Prometheus-Remote-Write-Version": "0.1.0",
+			},
+		},
+		{
+			name:         "unknown protobuf message",
+			responseCode: http.StatusUnsupportedMediaType,
+			headers: map[string]string{
+				"Content-Encoding":                  "snappy",
+				"Content-Type":                      "application/x-protobuf;proto=io.prometheus.write.v3.Request",
+				"X-Prometheus-Remote-Write-Version": "2.0.0",
+			},
+		},
+		{
+			name:         "remote write 1.0 does not send written headers",
+			responseCode: http.StatusOK,
+			headers: map[string]string{
+				"Content-Encoding":                  "snappy",
+				"Content-Type":                      "application/x-protobuf;proto=prometheus.WriteRequest",
+				"X-Prometheus-Remote-Write-Version": "0.1.0",
+			},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := &mockInserter{err: c.inserterErr}
+			metrics = &Metrics{LastRequestUnixNano: 0}
+			handler := Write(mock, parser.NewParser(), mockUpdaterForIngest(&mockMetric{}, nil, &mockMetric{}, nil))
+
+			reqBody := body
+			if c.headers["X-Prometheus-Remote-Write-Version"] == "0.1.0" {
+				reqBody = writeRequestToString(&prompb.WriteRequest{Timeseries: []prompb.TimeSeries{{Samples: []prompb.Sample{{}}}}})
+			}
+			w := GenerateWriteHandleTester(t, handler, c.headers)("POST", getReader(reqBody))
+			require.Equal(t, c.responseCode, w.Code)
+
+			written := []string{
+				w.Header().Get("X-Prometheus-Remote-Write-Samples-Written"),
+				w.Header().Get("X-Prometheus-Remote-Write-Histograms-Written"),
+				w.Header().Get("X-Prometheus-Remote-Write-Exemplars-Written"),
+			}
+			if c.expectedWritten == nil {
+				require.Equal(t, []string{"", "", ""}, written)
+				return
+			}
+			require.Equal(t, c.expectedWritten, written)
+		})
+	}
+}
+
+func partialWriteError() error {
+	var o ingestor.Outcomes
+	// The accepted samples include the zero of the created timestamp.
+	o.Counts[ingestor.Accepted] = ingestor.OutcomeCount{Series: 1, Samples: 3, Exemplars: 1}
+	o.CreatedZeros = ingestor.OutcomeCount{Samples: 1}
+	o.Counts[ingestor.Invalid] = ingestor.OutcomeCount{Series: 1, Histograms: 1}
+	return &ingestor.PartialWriteError{Outcomes: o}
+}
+
 func writeRequestToString(r *prompb.WriteRequest) string {
 	data, _ := proto.Marshal(r)
 	return string(snappy.Encode(nil, data))
@@ -280,6 +419,9 @@ type mockInserter struct {
 func (m *mockInserter) IngestTraces(_ context.Context, _ ptrace.Traces) error {
 	panic("not implemented") // TODO: Implement
 }
+func (m *mockInserter) IngestLogs(_ context.Context, _ plog.Logs) error {
+	panic("not implemented") // TODO: Implement
+}
 func (m *mockInserter) IngestMetrics(_ context.Context, r *prompb.WriteRequest) (uint64, uint64, error) {
 	m.ts = r.Timeseries
 	return uint64(m.result), 0, m.err
@@ -5,12 +5,14 @@
 package auth
 
 import (
+	"crypto/x509"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/timescale/promscale/pkg/log"
 )
@@ -21,6 +23,7 @@ var (
 	noPasswordFlagsSetError       = fmt.Errorf("one of basic-auth-password & basic-auth-password-file must be configured")
 	multiplePasswordFlagsSetError = fmt.Errorf("at most one of basic-auth-password & basic-auth-password-file must be configured")
 	multipleTokenFlagsSetError    = fmt.Errorf("at most one of bearer-token & bearer-token-file must be set")
+	credentialsFileSetError       = fmt.Errorf("credentials-file is mutually exclusive with basic-auth and bearer-token flags")
 )
 
 type arrayOfIgnorePaths []string
@@ -33,6 +36,16 @@ type Config struct {
 	BearerToken     string
 	BearerTokenFile string
 
+	CredentialsFile string
+	credentials     *Credentials
+
+	JWT JWTConfig
+
+	ClientCAFile    string
+	ClientRolesFile string
+	clientCAs       *x509.CertPool
+	clientRoles     *ClientRoles
+
 	IgnorePaths arrayOfIgnorePaths
 }
 
@@ -58,7 +71,26 @@ func readFromFile(path string, defaultValue string) (string, error) {
 }
 
 func (a *Config) Validate() error {
+	if err := a.validateClientTLS(); err != nil {
+		return err
+	}
 	switch {
+	case a.JWT.enabled():
+		if a.BasicAuthUsername != "" || a.BasicAuthPassword != "" || a.BasicAuthPasswordFile != "" || a.BearerToken != "" || a.BearerTokenFile != "" || a.CredentialsFile != "" {
+			return jwtExclusiveSetError
+		}
+		if err := a.JWT.validate(); err != nil {
+			return err
+		}
+	case a.CredentialsFile != "":
+		if a.BasicAuthUsername != "" || a.BasicAuthPassword != "" || a.BasicAuthPasswordFile != "" || a.BearerToken != "" || a.BearerTokenFile != "" {
+			return credentialsFileSetError
+		}
+		credentials, err := LoadCredentials(a.CredentialsFile)
+		if err != nil {
+			return fmt.Errorf("error reading credentials file: %w", err)
+		}
+		a.credentials = credentials
 	case a.BasicAuthUsername != "":
 		if a.BearerToken != "" || a.BearerTokenFile != "" {
 			return usernameAndTokenFlagsSetError
@@ -99,12 +131,50 @@ func (a *Config) Validate() error {
 	return nil
 }
 
+// validateClientTLS loads the client CA and the roles of client certificates.
+func (a *Config) validateClientTLS() error {
+	if a.ClientCAFile == "" {
+		if a.ClientRolesFile != "" {
+			return clientRolesFileSetError
+		}
+		return nil
+	}
+	pool, err := loadCertPool(a.ClientCAFile)
+	if err != nil {
+		return fmt.Errorf("error reading client CA file: %w", err)
+	}
+	a.clientCAs = pool
+	if a.ClientRolesFile == "" {
+		return nil
+	}
+	if a.BasicAuthUsername != "" || a.BasicAuthPassword != "" || a.BasicAuthPasswordFile != "" || a.BearerToken != "" || a.BearerTokenFile != "" || a.CredentialsFile != "" || a.JWT.enabled() {
+		return clientRolesExclusiveErr
+	}
+	roles, err := LoadClientRoles(a.ClientRolesFile)
+	if err != nil {
+		return fmt.Errorf("error reading client roles file: %w", err)
+	}
+	a.clientRoles = roles
+	return nil
+}
+
+// ClientTLSEnabled returns true if client certificates are verified.
+func (a *Config) ClientTLSEnabled() bool {
+	return a.ClientCAFile != ""
+}
+
 func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
 	fs.StringVar(&cfg.BasicAuthUsername, "web.auth.username", "", "Authentication username used for web endpoint authentication. Disabled by default.")
 	fs.StringVar(&cfg.BasicAuthPassword, "web.auth.password", "", "Authentication password used for web endpoint authentication. This flag should be set together with auth-username. It is mutually exclusive with auth-password-file and bearer-token flags.")
 	fs.StringVar(&cfg.BasicAuthPasswordFile, "web.auth.password-file", "", "Path for auth password file containing the actual password used for web endpoint authentication. This flag should be set together with auth-username. It is mutually exclusive with auth-password and bearer-token methods.")
 	fs.StringVar(&cfg.BearerToken, "web.auth.bearer-token", "", "Bearer token (JWT) used for web endpoint authentication. Disabled by default. Mutually exclusive with bearer-token-file and basic auth methods.")
 	fs.StringVar(&cfg.BearerTokenFile, "web.auth.bearer-token-file", "", "Path of the file containing the bearer token (JWT) used for web endpoint authentication. Disabled by default. Mutually exclusive with bearer-token and basic auth methods.")
+	fs.StringVar(&cfg.CredentialsFile, "web.auth.credentials-file", "", "Path of a YAML file with the basic auth users and bearer tokens used for web endpoint authentication, along with the tenants each of them is allowed to access. "+
+		"Disabled by default. Mutually exclusive with basic auth and bearer-token methods.")
+	parseJWTFlags(fs, &cfg.JWT)
+	fs.StringVar(&cfg.ClientCAFile, "auth.tls-client-ca-file", "", "Path of the CA bundle used to verify client certificates of the web and gRPC servers. Requires auth.tls-cert-file. Clients must present a certificate signed by one of these CAs. Disabled by default.")
+	fs.StringVar(&cfg.ClientRolesFile, "auth.tls-client-roles-file", "", "Path of a YAML file mapping the subject or SAN of client certificates to their roles (reader, writer or admin) and optionally to a tenant. Requires auth.tls-client-ca-file. "+
+		"Mutually exclusive with basic auth, bearer-token, credentials-file and JWT methods.")
 	fs.Var(&cfg.IgnorePaths, "web.auth.ignore-path", "HTTP paths which has to be skipped from authentication. This flag shall be repeated and each one would be appended to the ignore list.")
 	return cfg
 }
@@ -123,7 +193,60 @@ func (cfg *Config) isIgnoredPath(r *http.Request) bool {
 	return false
 }
 
+// AuthHandler returns a handler authenticating requests with the configured method.
+// Client certificates are checked before any other method.
 func (cfg *Config) AuthHandler(handler http.Handler) http.Handler {
+	handler = cfg.authHandler(handler)
+	if cfg.clientCAs != nil {
+		handler = cfg.clientCertHandler(handler)
+	}
+	return handler
+}
+
+func (cfg *Config) authHandler(handler http.Handler) http.Handler {
+	if cfg.JWT.verifier != nil {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.isIgnoredPath(r) {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			splitToken := strings.Split(r.Header.Get("Authorization"), "Bearer ")
+			if len(splitToken) != 2 {
+				log.Error("msg", "Unauthorized access to endpoint, missing bearer token")
+				http.Error(w, "Unauthorized access to endpoint, missing bearer token", http.StatusUnauthorized)
+				return
+			}
+			principal, err := cfg.JWT.verifier.verify(splitToken[1], time.Now())
+			if err != nil {
+				log.Error("msg", "Unauthorized access to endpoint, invalid bearer token", "err", err)
+				http.Error(w, "Unauthorized access to endpoint, invalid bearer token", http.StatusUnauthorized)
+				return
+			}
+			handler.ServeHTTP(w, r.WithContext(NewContext(r.Context(), principal)))
+		})
+	}
+
+	if cfg.credentials != nil {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.isIgnoredPath(r) {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			var principal *Principal
+			if user, pass, ok := r.BasicAuth(); ok {
+				principal = cfg.credentials.authenticateUser(user, pass)
+			} else if splitToken := strings.Split(r.Header.Get("Authorization"), "Bearer "); len(splitToken) == 2 {
+				principal = cfg.credentials.authenticateToken(splitToken[1])
+			}
+			if principal == nil {
+				log.Error("msg", "Unauthorized access to endpoint, invalid credentials")
+				http.Error(w, "Unauthorized access to endpoint, invalid credentials", http.StatusUnauthorized)
+				return
+			}
+			handler.ServeHTTP(w, r.WithContext(NewContext(r.Context(), principal)))
+		})
+	}
+
 	if cfg.BasicAuthUsername != "" {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if cfg.isIgnoredPath(r) {
@@ -158,3 +281,16 @@ func (cfg *Config) AuthHandler(handler http.Handler) http.Handler {
 
 	return handler
 }
+
+// RequireScope returns a handler that responds with 403 Forbidden if the principal
+// of the request is not authorized for the scope.
+func RequireScope(scope Scope, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p := PrincipalFromContext(r.Context()); !p.HasScope(scope) {
+			log.Error("msg", "Forbidden access to endpoint, missing scope", "principal", p.Name, "scope", scope)
+			http.Error(w, fmt.Sprintf("Forbidden access to endpoint, %s scope required", scope), http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
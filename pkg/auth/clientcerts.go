@@ -0,0 +1,194 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/timescale/promscale/pkg/log"
+)
+
+var (
+	clientRolesFileSetError = fmt.Errorf("tls-client-roles-file requires tls-client-ca-file")
+	clientRolesExclusiveErr = fmt.Errorf("tls-client-roles-file is mutually exclusive with basic-auth, bearer-token, credentials-file and JWT flags")
+)
+
+// Roles of client certificates and the scopes they authorize.
+var roleScopes = map[string]Scope{
+	"reader": ReadScope,
+	"writer": WriteScope,
+	"admin":  AdminScope,
+}
+
+// ClientRoles maps client certificates to their roles and tenant.
+type ClientRoles struct {
+	Clients []ClientRole `yaml:"clients"`
+}
+
+// ClientRole matches certificates by the common name of their subject or by one
+// of their subject alternative names (DNS name, URI, email address or IP address).
+type ClientRole struct {
+	CommonName string   `yaml:"common_name"`
+	SAN        string   `yaml:"san"`
+	Roles      []string `yaml:"roles"`
+	Tenant     string   `yaml:"tenant"`
+}
+
+// LoadClientRoles reads the roles of client certificates from a YAML file.
+func LoadClientRoles(path string) (*ClientRoles, error) {
+	contents, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file %s: %w", path, err)
+	}
+	c := &ClientRoles{}
+	if err = yaml.UnmarshalStrict(contents, c); err != nil {
+		return nil, fmt.Errorf("parse client roles file: %w", err)
+	}
+	if err = c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *ClientRoles) validate() error {
+	if len(c.Clients) == 0 {
+		return fmt.Errorf("client roles file has no clients")
+	}
+	for _, client := range c.Clients {
+		if (client.CommonName == "") == (client.SAN == "") {
+			return fmt.Errorf("exactly one of common_name & san must be set for each client")
+		}
+		if len(client.Roles) == 0 {
+			return fmt.Errorf("client %s has no roles", client.name())
+		}
+		for _, role := range client.Roles {
+			if _, ok := roleScopes[role]; !ok {
+				return fmt.Errorf("client %s has an invalid role %q: must be one of reader, writer or admin", client.name(), role)
+			}
+		}
+	}
+	return nil
+}
+
+func (c ClientRole) name() string {
+	if c.CommonName != "" {
+		return c.CommonName
+	}
+	return c.SAN
+}
+
+func (c ClientRole) matches(cert *x509.Certificate) bool {
+	if c.CommonName != "" {
+		return cert.Subject.CommonName == c.CommonName
+	}
+	for _, name := range cert.DNSNames {
+		if name == c.SAN {
+			return true
+		}
+	}
+	for _, email := range cert.EmailAddresses {
+		if email == c.SAN {
+			return true
+		}
+	}
+	for _, ip := range cert.IPAddresses {
+		if ip.String() == c.SAN {
+			return true
+		}
+	}
+	for _, uri := range cert.URIs {
+		if uri.String() == c.SAN {
+			return true
+		}
+	}
+	return false
+}
+
+// principal returns the principal of the first client matching the certificate, or
+// nil if no client matches.
+func (c *ClientRoles) principal(cert *x509.Certificate) *Principal {
+	for _, client := range c.Clients {
+		if !client.matches(cert) {
+			continue
+		}
+		p := &Principal{Name: client.name(), Scopes: make([]Scope, 0, len(client.Roles))}
+		for _, role := range client.Roles {
+			p.Scopes = append(p.Scopes, roleScopes[role])
+		}
+		p.Tenants = []string{AllTenants}
+		if client.Tenant != "" {
+			p.Tenants = []string{client.Tenant}
+		}
+		return p
+	}
+	return nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	contents, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file %s: %w", path, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(contents) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// ConfigureClientTLS enables the verification of client certificates in the TLS
+// configuration of a server, if a client CA is configured. Certificates are
+// verified if given, and required by the authentication handlers and interceptors,
+// so that ignored paths remain reachable without a certificate.
+func (cfg *Config) ConfigureClientTLS(tlsConfig *tls.Config) {
+	if cfg.clientCAs == nil {
+		return
+	}
+	tlsConfig.ClientCAs = cfg.clientCAs
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+}
+
+// authenticateCertificate returns the principal of a verified client certificate.
+// The principal is nil if no roles are configured, in which case any certificate
+// signed by the client CA is accepted.
+func (cfg *Config) authenticateCertificate(state *tls.ConnectionState) (*Principal, error) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil, fmt.Errorf("client certificate required")
+	}
+	if cfg.clientRoles == nil {
+		return nil, nil
+	}
+	cert := state.VerifiedChains[0][0]
+	p := cfg.clientRoles.principal(cert)
+	if p == nil {
+		return nil, fmt.Errorf("client certificate %q has no roles", cert.Subject.String())
+	}
+	return p, nil
+}
+
+func (cfg *Config) clientCertHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.isIgnoredPath(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		principal, err := cfg.authenticateCertificate(r.TLS)
+		if err != nil {
+			log.Error("msg", "Unauthorized access to endpoint, invalid client certificate", "err", err)
+			http.Error(w, "Unauthorized access to endpoint, invalid client certificate", http.StatusUnauthorized)
+			return
+		}
+		if principal != nil {
+			r = r.WithContext(NewContext(r.Context(), principal))
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
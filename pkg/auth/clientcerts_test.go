@@ -0,0 +1,259 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const testClientRoles = `clients:
+  - common_name: prometheus-a
+    roles: [writer]
+    tenant: tenant-a
+  - san: spiffe://cluster/grafana
+    roles: [reader]
+  - san: ops.example.com
+    roles: [admin]
+`
+
+func writeTestFile(t *testing.T, name string, contents []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, contents, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestCA(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func verifiedState(cert *x509.Certificate) *tls.ConnectionState {
+	return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+}
+
+func TestLoadClientRoles(t *testing.T) {
+	testCases := []struct {
+		name     string
+		contents string
+		invalid  bool
+	}{
+		{name: "valid", contents: testClientRoles},
+		{name: "empty", invalid: true},
+		{name: "no match", contents: "clients:\n  - roles: [reader]", invalid: true},
+		{name: "common name and san", contents: "clients:\n  - common_name: a\n    san: b\n    roles: [reader]", invalid: true},
+		{name: "no roles", contents: "clients:\n  - common_name: a", invalid: true},
+		{name: "invalid role", contents: "clients:\n  - common_name: a\n    roles: [owner]", invalid: true},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := LoadClientRoles(writeTestFile(t, "roles.yaml", []byte(c.contents)))
+			if c.invalid && err == nil {
+				t.Errorf("expected an error")
+			}
+			if !c.invalid && err != nil {
+				t.Errorf("unexpected error received: %s", err)
+			}
+		})
+	}
+}
+
+func TestValidateClientTLS(t *testing.T) {
+	caFile := writeTestFile(t, "ca.pem", newTestCA(t))
+	rolesFile := writeTestFile(t, "roles.yaml", []byte(testClientRoles))
+
+	if err := Validate(&Config{ClientRolesFile: rolesFile}); err != clientRolesFileSetError {
+		t.Fatalf("unexpected error received: %v", err)
+	}
+	for _, cfg := range []*Config{
+		{ClientCAFile: caFile, ClientRolesFile: rolesFile, BearerToken: "foo"},
+		{ClientCAFile: caFile, ClientRolesFile: rolesFile, BasicAuthPassword: "foo"},
+		{ClientCAFile: caFile, ClientRolesFile: rolesFile, BasicAuthPasswordFile: "foo"},
+	} {
+		if err := Validate(cfg); err != clientRolesExclusiveErr {
+			t.Fatalf("unexpected error received: %v", err)
+		}
+	}
+	if err := Validate(&Config{ClientCAFile: rolesFile}); err == nil {
+		t.Fatal("expected an error for a CA file without certificates")
+	}
+	// Client certificates can be verified along with another authentication method.
+	cfg := &Config{ClientCAFile: caFile, BearerToken: "foo"}
+	if err := Validate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig := &tls.Config{}
+	cfg.ConfigureClientTLS(tlsConfig)
+	if tlsConfig.ClientCAs == nil || tlsConfig.ClientAuth != tls.VerifyClientCertIfGiven {
+		t.Fatalf("client certificates are not verified: %+v", tlsConfig)
+	}
+}
+
+func TestClientRolesPrincipal(t *testing.T) {
+	roles, err := LoadClientRoles(writeTestFile(t, "roles.yaml", []byte(testClientRoles)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spiffe, _ := url.Parse("spiffe://cluster/grafana")
+
+	testCases := []struct {
+		name      string
+		cert      *x509.Certificate
+		principal *Principal
+	}{
+		{
+			name:      "common name",
+			cert:      &x509.Certificate{Subject: pkix.Name{CommonName: "prometheus-a"}},
+			principal: &Principal{Name: "prometheus-a", Tenants: []string{"tenant-a"}, Scopes: []Scope{WriteScope}},
+		},
+		{
+			name:      "URI SAN",
+			cert:      &x509.Certificate{Subject: pkix.Name{CommonName: "grafana"}, URIs: []*url.URL{spiffe}},
+			principal: &Principal{Name: "spiffe://cluster/grafana", Tenants: []string{AllTenants}, Scopes: []Scope{ReadScope}},
+		},
+		{
+			name:      "DNS SAN",
+			cert:      &x509.Certificate{DNSNames: []string{"other.example.com", "ops.example.com"}},
+			principal: &Principal{Name: "ops.example.com", Tenants: []string{AllTenants}, Scopes: []Scope{AdminScope}},
+		},
+		{
+			name: "unknown",
+			cert: &x509.Certificate{Subject: pkix.Name{CommonName: "prometheus-b"}, DNSNames: []string{"prometheus-a"}},
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			if p := roles.principal(c.cert); !reflect.DeepEqual(c.principal, p) {
+				t.Errorf("unexpected principal: got %+v wanted %+v", p, c.principal)
+			}
+		})
+	}
+}
+
+func TestClientCertAuthHandler(t *testing.T) {
+	cfg := &Config{
+		ClientCAFile:    writeTestFile(t, "ca.pem", newTestCA(t)),
+		ClientRolesFile: writeTestFile(t, "roles.yaml", []byte(testClientRoles)),
+		IgnorePaths:     []string{"/healthz"},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name  string
+		path  string
+		state *tls.ConnectionState
+		code  int
+	}{
+		{name: "no certificate", path: "/write", code: http.StatusUnauthorized},
+		{name: "unverified certificate", path: "/write", state: &tls.ConnectionState{}, code: http.StatusUnauthorized},
+		{name: "ignored path", path: "/healthz", code: http.StatusOK},
+		{name: "unknown certificate", path: "/write", state: verifiedState(&x509.Certificate{Subject: pkix.Name{CommonName: "foo"}}), code: http.StatusUnauthorized},
+		{name: "authorized", path: "/write", state: verifiedState(&x509.Certificate{Subject: pkix.Name{CommonName: "prometheus-a"}}), code: http.StatusOK},
+		{name: "forbidden", path: "/api/v1/query", state: verifiedState(&x509.Certificate{Subject: pkix.Name{CommonName: "prometheus-a"}}), code: http.StatusForbidden},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			scope := WriteScope
+			if c.path == "/api/v1/query" {
+				scope = ReadScope
+			}
+			h := cfg.AuthHandler(RequireScope(scope, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+			req := httptest.NewRequest("POST", c.path, nil)
+			req.TLS = c.state
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != c.code {
+				t.Errorf("unexpected HTTP status code received: got %d wanted %d", w.Code, c.code)
+			}
+		})
+	}
+}
+
+func TestClientCertUnaryServerInterceptor(t *testing.T) {
+	cfg := &Config{
+		ClientCAFile:    writeTestFile(t, "ca.pem", newTestCA(t)),
+		ClientRolesFile: writeTestFile(t, "roles.yaml", []byte(testClientRoles)),
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	interceptor := cfg.UnaryServerInterceptor(func(fullMethod string) (Scope, bool) {
+		if fullMethod == "/write" {
+			return WriteScope, true
+		}
+		return ReadScope, true
+	})
+	writer := &x509.Certificate{Subject: pkix.Name{CommonName: "prometheus-a"}}
+
+	testCases := []struct {
+		name   string
+		method string
+		state  *tls.ConnectionState
+		code   codes.Code
+	}{
+		{name: "no certificate", method: "/write", code: codes.Unauthenticated},
+		{name: "authorized", method: "/write", state: verifiedState(writer), code: codes.OK},
+		{name: "forbidden", method: "/read", state: verifiedState(writer), code: codes.PermissionDenied},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			if c.state != nil {
+				ctx = peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{State: *c.state}})
+			}
+			var principal *Principal
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: c.method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				principal = PrincipalFromContext(ctx)
+				return nil, nil
+			})
+			if status.Code(err) != c.code {
+				t.Fatalf("unexpected status code: got %s wanted %s", status.Code(err), c.code)
+			}
+			if c.code == codes.OK && (principal == nil || principal.Tenants[0] != "tenant-a") {
+				t.Fatalf("unexpected principal: %+v", principal)
+			}
+		})
+	}
+}
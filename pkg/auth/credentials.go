@@ -0,0 +1,105 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Credentials maps the users and bearer tokens allowed to access the web
+// endpoints to the tenants they are allowed to access. Each user and token must
+// list its tenants, or AllTenants to access all of them.
+type Credentials struct {
+	Users  []UserCredentials  `yaml:"users"`
+	Tokens []TokenCredentials `yaml:"tokens"`
+}
+
+// UserCredentials are the credentials of a basic auth user.
+type UserCredentials struct {
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	Tenants  []string `yaml:"tenants"`
+}
+
+// TokenCredentials are the credentials of a bearer token. The name identifies
+// the token in logs.
+type TokenCredentials struct {
+	Name    string   `yaml:"name"`
+	Token   string   `yaml:"token"`
+	Tenants []string `yaml:"tenants"`
+}
+
+// LoadCredentials reads the credentials from a YAML file.
+func LoadCredentials(path string) (*Credentials, error) {
+	contents, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file %s: %w", path, err)
+	}
+	c := &Credentials{}
+	if err = yaml.UnmarshalStrict(contents, c); err != nil {
+		return nil, fmt.Errorf("parse credentials file: %w", err)
+	}
+	if err = c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Credentials) validate() error {
+	users := make(map[string]struct{}, len(c.Users))
+	for _, u := range c.Users {
+		if u.Username == "" || u.Password == "" {
+			return fmt.Errorf("credentials of users require a username and a password")
+		}
+		if len(u.Tenants) == 0 {
+			return fmt.Errorf("credentials of user %s require tenants, use [\"%s\"] to allow all tenants", u.Username, AllTenants)
+		}
+		if _, ok := users[u.Username]; ok {
+			return fmt.Errorf("duplicate credentials for user %s", u.Username)
+		}
+		users[u.Username] = struct{}{}
+	}
+	tokens := make(map[string]struct{}, len(c.Tokens))
+	for _, t := range c.Tokens {
+		if t.Name == "" || t.Token == "" {
+			return fmt.Errorf("credentials of tokens require a name and a token")
+		}
+		if len(t.Tenants) == 0 {
+			return fmt.Errorf("credentials of token %s require tenants, use [\"%s\"] to allow all tenants", t.Name, AllTenants)
+		}
+		if _, ok := tokens[t.Name]; ok {
+			return fmt.Errorf("duplicate credentials for token %s", t.Name)
+		}
+		tokens[t.Name] = struct{}{}
+	}
+	if len(users) == 0 && len(tokens) == 0 {
+		return fmt.Errorf("credentials file has no users or tokens")
+	}
+	return nil
+}
+
+// authenticateUser returns the principal of the user, or nil if the password is invalid.
+func (c *Credentials) authenticateUser(username, password string) *Principal {
+	for _, u := range c.Users {
+		if u.Username == username && subtle.ConstantTimeCompare([]byte(u.Password), []byte(password)) == 1 {
+			return &Principal{Name: u.Username, Tenants: u.Tenants}
+		}
+	}
+	return nil
+}
+
+// authenticateToken returns the principal of the token, or nil if the token is invalid.
+func (c *Credentials) authenticateToken(token string) *Principal {
+	for _, t := range c.Tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			return &Principal{Name: t.Name, Tenants: t.Tenants}
+		}
+	}
+	return nil
+}
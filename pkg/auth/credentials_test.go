@@ -0,0 +1,165 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package auth
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testCredentials = `users:
+  - username: prometheus-a
+    password: secret-a
+    tenants: [tenant-a]
+tokens:
+  - name: admin
+    token: admin-token
+    tenants: ["*"]
+`
+
+func TestLoadCredentials(t *testing.T) {
+	testCases := []struct {
+		name     string
+		contents string
+		invalid  bool
+	}{
+		{
+			name:     "valid",
+			contents: testCredentials,
+		},
+		{
+			name:    "empty",
+			invalid: true,
+		},
+		{
+			name:     "unknown field",
+			contents: "users:\n  - username: foo\n    pass: bar",
+			invalid:  true,
+		},
+		{
+			name:     "missing password",
+			contents: "users:\n  - username: foo",
+			invalid:  true,
+		},
+		{
+			name:     "duplicate user",
+			contents: "users:\n  - username: foo\n    password: bar\n    tenants: [a]\n  - username: foo\n    password: baz\n    tenants: [a]",
+			invalid:  true,
+		},
+		{
+			name:     "missing tenants",
+			contents: "users:\n  - username: foo\n    password: bar",
+			invalid:  true,
+		},
+		{
+			name:     "no tenants",
+			contents: "tokens:\n  - name: foo\n    token: bar\n    tenants: []",
+			invalid:  true,
+		},
+		{
+			name:     "unnamed token",
+			contents: "tokens:\n  - token: foo",
+			invalid:  true,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "credentials.yaml")
+			if err := os.WriteFile(path, []byte(c.contents), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadCredentials(path)
+			if c.invalid && err == nil {
+				t.Errorf("expected an error")
+			}
+			if !c.invalid && err != nil {
+				t.Errorf("unexpected error received: %s", err)
+			}
+		})
+	}
+}
+
+func TestCredentialsAuthHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.yaml")
+	if err := os.WriteFile(path, []byte(testCredentials), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{CredentialsFile: path, BearerToken: "foo"}
+	if err := Validate(cfg); err != credentialsFileSetError {
+		t.Fatalf("unexpected error received: %v", err)
+	}
+	cfg = &Config{CredentialsFile: path}
+	if err := Validate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name      string
+		header    string
+		principal *Principal
+	}{
+		{
+			name: "no credentials",
+		},
+		{
+			name:   "wrong password",
+			header: "Basic " + base64.StdEncoding.EncodeToString([]byte("prometheus-a:secret-b")),
+		},
+		{
+			name:      "user",
+			header:    "Basic " + base64.StdEncoding.EncodeToString([]byte("prometheus-a:secret-a")),
+			principal: &Principal{Name: "prometheus-a", Tenants: []string{"tenant-a"}},
+		},
+		{
+			name:   "wrong token",
+			header: "Bearer secret-a",
+		},
+		{
+			name:      "token",
+			header:    "Bearer admin-token",
+			principal: &Principal{Name: "admin", Tenants: []string{AllTenants}},
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			var principal *Principal
+			h := cfg.AuthHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				principal = PrincipalFromContext(r.Context())
+			}))
+			req := httptest.NewRequest("GET", "/api/v1/query", nil)
+			if c.header != "" {
+				req.Header.Set("Authorization", c.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if c.principal == nil && w.Code != http.StatusUnauthorized {
+				t.Errorf("unexpected HTTP status code received: got %d wanted %d", w.Code, http.StatusUnauthorized)
+			}
+			if !reflect.DeepEqual(c.principal, principal) {
+				t.Errorf("unexpected principal: got %+v wanted %+v", principal, c.principal)
+			}
+		})
+	}
+}
+
+func TestPrincipalAllowsTenant(t *testing.T) {
+	var unauthenticated *Principal
+	if !unauthenticated.AllowsTenant("a") || !(&Principal{Tenants: []string{AllTenants}}).AllowsTenant("a") {
+		t.Errorf("unauthenticated principals and principals with all tenants must allow all tenants")
+	}
+	if (&Principal{}).AllowsTenant("a") || (&Principal{}).AllowsTenant("") {
+		t.Errorf("principals without tenants must not allow any tenant")
+	}
+	p := &Principal{Tenants: []string{"a", "b"}}
+	if !p.AllowsTenant("b") || p.AllowsTenant("c") || p.AllowsTenant("") {
+		t.Errorf("principal must only allow its tenants")
+	}
+}
@@ -0,0 +1,107 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/timescale/promscale/pkg/log"
+)
+
+// MethodScope returns the scope required to call a gRPC method, given its full
+// name. Methods that do not require authentication return false.
+type MethodScope func(fullMethod string) (Scope, bool)
+
+// UnaryServerInterceptor returns a gRPC interceptor that authenticates the calls
+// with their client certificate and with the JSON Web Token of their "authorization"
+// metadata, and checks the scope of the methods. The interceptor does nothing if
+// neither client certificates nor JWT authentication are enabled.
+func (cfg *Config) UnaryServerInterceptor(methodScope MethodScope) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := cfg.authenticateGRPC(ctx, info.FullMethod, methodScope)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+func (cfg *Config) StreamServerInterceptor(methodScope MethodScope) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := cfg.authenticateGRPC(ss.Context(), info.FullMethod, methodScope)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (cfg *Config) authenticateGRPC(ctx context.Context, fullMethod string, methodScope MethodScope) (context.Context, error) {
+	if cfg.JWT.verifier == nil && cfg.clientCAs == nil {
+		return ctx, nil
+	}
+	scope, required := methodScope(fullMethod)
+	if !required {
+		return ctx, nil
+	}
+	var principal *Principal
+	if cfg.clientCAs != nil {
+		var state *tls.ConnectionState
+		if p, ok := peer.FromContext(ctx); ok {
+			if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+				state = &info.State
+			}
+		}
+		var err error
+		if principal, err = cfg.authenticateCertificate(state); err != nil {
+			log.Error("msg", "Unauthorized gRPC call, invalid client certificate", "method", fullMethod, "err", err)
+			return nil, status.Error(codes.Unauthenticated, "invalid client certificate")
+		}
+	}
+	if cfg.JWT.verifier != nil {
+		var token string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get("authorization"); len(values) > 0 {
+				token = strings.TrimPrefix(values[0], "Bearer ")
+			}
+		}
+		if token == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+		}
+		var err error
+		if principal, err = cfg.JWT.verifier.verify(token, time.Now()); err != nil {
+			log.Error("msg", "Unauthorized gRPC call, invalid bearer token", "method", fullMethod, "err", err)
+			return nil, status.Error(codes.Unauthenticated, "invalid bearer token")
+		}
+	}
+	if principal == nil {
+		return ctx, nil
+	}
+	if !principal.HasScope(scope) {
+		log.Error("msg", "Forbidden gRPC call, missing scope", "method", fullMethod, "principal", principal.Name, "scope", scope)
+		return nil, status.Errorf(codes.PermissionDenied, "%s scope required", scope)
+	}
+	return NewContext(ctx, principal), nil
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
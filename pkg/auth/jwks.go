@@ -0,0 +1,275 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/timescale/promscale/pkg/log"
+)
+
+const (
+	// jwksRefreshInterval is how often the keys of a JWKS URL are fetched again.
+	jwksRefreshInterval = 5 * time.Minute
+	// jwksMinRefreshInterval limits how often the keys are fetched again when a
+	// token is signed by an unknown key, e.g. after a key rotation.
+	jwksMinRefreshInterval = 30 * time.Second
+	// jwksRetryInterval is how long fetches wait after a failed fetch. It doubles
+	// with each consecutive failure, up to jwksRefreshInterval.
+	jwksRetryInterval = time.Second
+	jwksFetchTimeout  = 10 * time.Second
+)
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA keys.
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC keys.
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// parseJWKS returns the public keys of a JSON Web Key Set by key ID. Keys that are
+// not used for signatures or of an unsupported type are skipped.
+func parseJWKS(contents []byte) (map[string]crypto.PublicKey, error) {
+	var set jsonWebKeySet
+	if err := json.Unmarshal(contents, &set); err != nil {
+		return nil, fmt.Errorf("parse JWKS: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var (
+			key crypto.PublicKey
+			err error
+		)
+		switch k.Kty {
+		case "RSA":
+			key, err = k.rsaPublicKey()
+		case "EC":
+			key, err = k.ecdsaPublicKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse JWKS key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS has no RSA or EC signing keys")
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("modulus: %w", err)
+	}
+	e, err := decodeBigInt(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("exponent: %w", err)
+	}
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("exponent out of range")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (k jsonWebKey) ecdsaPublicKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+	x, err := decodeBigInt(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("x coordinate: %w", err)
+	}
+	y, err := decodeBigInt(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("y coordinate: %w", err)
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, fmt.Errorf("missing value")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// keySet holds the keys used to verify the signatures of tokens. The keys of a
+// JWKS URL are fetched lazily and refreshed periodically. A single fetch runs at
+// a time, without holding the lock, and fetches back off after failures.
+type keySet struct {
+	url    string
+	client *http.Client
+
+	mux       sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time     // time of the last fetch attempt
+	failures  int           // consecutive failed fetches
+	fetchErr  error         // error of the last fetch
+	fetching  chan struct{} // closed once the running fetch is done, nil if none
+}
+
+func newFileKeySet(path string) (*keySet, error) {
+	contents, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file %s: %w", path, err)
+	}
+	keys, err := parseJWKS(contents)
+	if err != nil {
+		return nil, err
+	}
+	return &keySet{keys: keys}, nil
+}
+
+func newURLKeySet(url string) *keySet {
+	return &keySet{url: url, client: &http.Client{Timeout: jwksFetchTimeout}}
+}
+
+// key returns the key with the ID kid. If kid is empty, the key set must contain
+// a single key.
+func (s *keySet) key(kid string, now time.Time) (crypto.PublicKey, error) {
+	if s.url != "" {
+		if err := s.refresh(kid, now); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, nil
+		}
+	}
+	k, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return k, nil
+}
+
+// refresh fetches the keys of the URL if they are missing or stale, or if kid is
+// unknown. Concurrent callers wait for the running fetch instead of starting
+// another one. An error is only returned if no keys were ever fetched.
+func (s *keySet) refresh(kid string, now time.Time) error {
+	s.mux.Lock()
+	if fetching := s.fetching; fetching != nil {
+		s.mux.Unlock()
+		<-fetching
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		if s.keys == nil {
+			return s.fetchErr
+		}
+		return nil
+	}
+	_, known := s.keys[kid]
+	if !s.shouldFetch(known, now) {
+		defer s.mux.Unlock()
+		if s.keys == nil {
+			return s.fetchErr
+		}
+		return nil
+	}
+	done := make(chan struct{})
+	s.fetching = done
+	s.mux.Unlock()
+
+	keys, err := s.fetch()
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.fetchedAt = now
+	s.fetchErr = err
+	if err != nil {
+		s.failures++
+	} else {
+		s.keys = keys
+		s.failures = 0
+	}
+	s.fetching = nil
+	close(done)
+	if err != nil {
+		if s.keys == nil {
+			return err
+		}
+		log.Warn("msg", "Using previously fetched JWKS keys", "err", err)
+	}
+	return nil
+}
+
+// shouldFetch returns true if the keys have to be fetched, unless fetches are
+// backing off after a failure. It must be called with the lock held.
+func (s *keySet) shouldFetch(known bool, now time.Time) bool {
+	since := now.Sub(s.fetchedAt)
+	if s.failures > 0 {
+		backoff := jwksRetryInterval << (s.failures - 1)
+		if s.failures > 16 || backoff > jwksRefreshInterval {
+			backoff = jwksRefreshInterval
+		}
+		if since < backoff {
+			return false
+		}
+	}
+	return s.keys == nil || since > jwksRefreshInterval || (!known && since > jwksMinRefreshInterval)
+}
+
+func (s *keySet) fetch() (map[string]crypto.PublicKey, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS: unexpected status code %d", resp.StatusCode)
+	}
+	contents, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	return parseJWKS(contents)
+}
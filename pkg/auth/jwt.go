@@ -0,0 +1,184 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package auth
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	defaultReadScope  = "promscale:read"
+	defaultWriteScope = "promscale:write"
+	defaultAdminScope = "promscale:admin"
+
+	// jwtLeeway is the clock skew tolerated when checking the validity period of tokens.
+	jwtLeeway = time.Minute
+)
+
+var (
+	jwksFlagsSetError    = fmt.Errorf("exactly one of jwt.jwks-file & jwt.jwks-url must be set to enable JWT authentication")
+	jwtExclusiveSetError = fmt.Errorf("JWT authentication is mutually exclusive with basic-auth, bearer-token and credentials-file flags")
+)
+
+// JWTConfig configures the authentication with JSON Web Tokens, e.g. issued by an
+// OpenID Connect provider. Tokens are verified with the keys of a JWKS.
+type JWTConfig struct {
+	JWKSFile string
+	JWKSURL  string
+	Issuer   string
+	Audience string
+
+	ReadScope  string
+	WriteScope string
+	AdminScope string
+
+	TenantsClaim string
+
+	verifier *jwtVerifier
+}
+
+func parseJWTFlags(fs *flag.FlagSet, cfg *JWTConfig) {
+	fs.StringVar(&cfg.JWKSFile, "web.auth.jwt.jwks-file", "", "Path of a JSON Web Key Set file with the keys used to verify JSON Web Tokens. Setting it enables JWT authentication of the web and gRPC endpoints. "+
+		"Mutually exclusive with jwt.jwks-url, basic auth, bearer-token and credentials-file methods.")
+	fs.StringVar(&cfg.JWKSURL, "web.auth.jwt.jwks-url", "", "URL of the JSON Web Key Set with the keys used to verify JSON Web Tokens, e.g. the jwks_uri of an OpenID Connect provider. Setting it enables JWT authentication of the web and gRPC endpoints. "+
+		"Mutually exclusive with jwt.jwks-file, basic auth, bearer-token and credentials-file methods.")
+	fs.StringVar(&cfg.Issuer, "web.auth.jwt.issuer", "", "Required issuer (iss claim) of JSON Web Tokens. Not checked if empty.")
+	fs.StringVar(&cfg.Audience, "web.auth.jwt.audience", "", "Required audience (aud claim) of JSON Web Tokens. Not checked if empty.")
+	fs.StringVar(&cfg.ReadScope, "web.auth.jwt.read-scope", defaultReadScope, "Scope of JSON Web Tokens authorizing queries.")
+	fs.StringVar(&cfg.WriteScope, "web.auth.jwt.write-scope", defaultWriteScope, "Scope of JSON Web Tokens authorizing ingestion.")
+	fs.StringVar(&cfg.AdminScope, "web.auth.jwt.admin-scope", defaultAdminScope, "Scope of JSON Web Tokens authorizing deletes and administrative endpoints, in addition to queries and ingestion.")
+	fs.StringVar(&cfg.TenantsClaim, "web.auth.jwt.tenants-claim", "", "Claim of JSON Web Tokens listing the tenants the token is allowed to access in multi-tenancy mode. All tenants are allowed if empty, while tokens without the claim are not allowed to access any tenant.")
+}
+
+func (c *JWTConfig) enabled() bool {
+	return c.JWKSFile != "" || c.JWKSURL != ""
+}
+
+func (c *JWTConfig) validate() error {
+	if c.JWKSFile != "" && c.JWKSURL != "" {
+		return jwksFlagsSetError
+	}
+	var keys *keySet
+	if c.JWKSFile != "" {
+		var err error
+		if keys, err = newFileKeySet(c.JWKSFile); err != nil {
+			return fmt.Errorf("error reading JWKS file: %w", err)
+		}
+	} else {
+		keys = newURLKeySet(c.JWKSURL)
+	}
+	scopes := map[string]Scope{}
+	for scope, name := range map[Scope]string{ReadScope: c.ReadScope, WriteScope: c.WriteScope, AdminScope: c.AdminScope} {
+		if name == "" {
+			return fmt.Errorf("JWT %s scope must not be empty", scope)
+		}
+		if _, ok := scopes[name]; ok {
+			return fmt.Errorf("JWT scope %q is used for several operations", name)
+		}
+		scopes[name] = scope
+	}
+	c.verifier = &jwtVerifier{
+		keys:         keys,
+		issuer:       c.Issuer,
+		audience:     c.Audience,
+		scopes:       scopes,
+		tenantsClaim: c.TenantsClaim,
+	}
+	return nil
+}
+
+type jwtVerifier struct {
+	keys         *keySet
+	issuer       string
+	audience     string
+	scopes       map[string]Scope
+	tenantsClaim string
+}
+
+// jwtParser only accepts the asymmetric algorithms of the keys a JWKS can hold.
+var jwtParser = jwt.NewParser(jwt.WithValidMethods([]string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+}))
+
+// jwtClaims are the claims of a token, validated against the time of the request.
+type jwtClaims struct {
+	jwt.MapClaims
+	verifier *jwtVerifier
+	now      time.Time
+}
+
+func (c *jwtClaims) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &c.MapClaims)
+}
+
+// Valid implements the jwt.Claims interface. Tokens must expire, and their
+// validity period is checked with some leeway for clock skew.
+func (c *jwtClaims) Valid() error {
+	iss, _ := c.MapClaims["iss"].(string)
+	switch {
+	case !c.VerifyExpiresAt(c.now.Add(-jwtLeeway).Unix(), true):
+		return fmt.Errorf("token is expired or has no expiration time")
+	case !c.VerifyNotBefore(c.now.Add(jwtLeeway).Unix(), false):
+		return fmt.Errorf("token is not valid yet")
+	case c.verifier.issuer != "" && !c.VerifyIssuer(c.verifier.issuer, true):
+		return fmt.Errorf("unexpected token issuer %q", iss)
+	case c.verifier.audience != "" && !c.VerifyAudience(c.verifier.audience, true):
+		return fmt.Errorf("token audience does not include %q", c.verifier.audience)
+	}
+	return nil
+}
+
+// verify checks the signature and the claims of the token, and returns the principal
+// it authenticates.
+func (v *jwtVerifier) verify(token string, now time.Time) (*Principal, error) {
+	claims := &jwtClaims{verifier: v, now: now}
+	_, err := jwtParser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.key(kid, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	subject, _ := claims.MapClaims["sub"].(string)
+	p := &Principal{Name: subject, Tenants: []string{AllTenants}, Scopes: []Scope{}}
+	for _, s := range stringsClaim(claims.MapClaims, "scope", "scp") {
+		if scope, ok := v.scopes[s]; ok {
+			p.Scopes = append(p.Scopes, scope)
+		}
+	}
+	if v.tenantsClaim != "" {
+		// A token without the claim is not allowed to access any tenant.
+		p.Tenants = stringsClaim(claims.MapClaims, v.tenantsClaim)
+	}
+	return p, nil
+}
+
+// stringsClaim returns the values of the first of the claims that exists. Claims
+// can be a list of strings or a string of space-separated values.
+func stringsClaim(claims jwt.MapClaims, names ...string) []string {
+	for _, name := range names {
+		switch v := claims[name].(type) {
+		case string:
+			return strings.Fields(v)
+		case []interface{}:
+			values := make([]string, 0, len(v))
+			for _, e := range v {
+				if s, ok := e.(string); ok {
+					values = append(values, s)
+				}
+			}
+			return values
+		}
+	}
+	return nil
+}
@@ -0,0 +1,439 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type testKeys struct {
+	rsa  *rsa.PrivateKey
+	ecds *ecdsa.PrivateKey
+	jwks []byte
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	jwks, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecKey.X.Bytes()), "y": encode(ecKey.Y.Bytes())},
+			{"kty": "oct", "kid": "symmetric", "k": "c2VjcmV0"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKeys{rsa: rsaKey, ecds: ecKey, jwks: jwks}
+}
+
+func (k *testKeys) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(alg), jwt.MapClaims(claims))
+	token.Header["kid"] = kid
+	var key interface{}
+	switch alg {
+	case "RS256":
+		key = k.rsa
+	case "ES256":
+		key = k.ecds
+	case "none":
+		key = jwt.UnsafeAllowNoneSignatureType
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// withClaimsOf returns the token with the claims of another token.
+func withClaimsOf(token, other string) string {
+	parts, otherParts := strings.Split(token, "."), strings.Split(other, ".")
+	return parts[0] + "." + otherParts[1] + "." + parts[2]
+}
+
+func newJWTConfig(t *testing.T, keys *testKeys) *Config {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, keys.jwks, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{JWT: JWTConfig{
+		JWKSFile:     path,
+		Issuer:       "https://sso.example.com",
+		Audience:     "promscale",
+		ReadScope:    defaultReadScope,
+		WriteScope:   defaultWriteScope,
+		AdminScope:   defaultAdminScope,
+		TenantsClaim: "tenants",
+	}}
+	if err := Validate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestJWTVerify(t *testing.T) {
+	keys := newTestKeys(t)
+	cfg := newJWTConfig(t, keys)
+	now := time.Now()
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub":     "service-a",
+			"iss":     "https://sso.example.com",
+			"aud":     []string{"other", "promscale"},
+			"exp":     now.Add(time.Hour).Unix(),
+			"scope":   "openid promscale:read promscale:write",
+			"tenants": []string{"tenant-a"},
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+
+	testCases := []struct {
+		name      string
+		token     string
+		principal *Principal
+	}{
+		{
+			name:      "RSA",
+			token:     keys.sign(t, "RS256", "rsa", claims(nil)),
+			principal: &Principal{Name: "service-a", Tenants: []string{"tenant-a"}, Scopes: []Scope{ReadScope, WriteScope}},
+		},
+		{
+			name:      "EC with scp claim",
+			token:     keys.sign(t, "ES256", "ec", claims(map[string]interface{}{"scope": nil, "scp": []string{"promscale:admin"}, "tenants": []string{AllTenants}})),
+			principal: &Principal{Name: "service-a", Tenants: []string{AllTenants}, Scopes: []Scope{AdminScope}},
+		},
+		{
+			name:      "missing tenants claim",
+			token:     keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"tenants": nil})),
+			principal: &Principal{Name: "service-a", Scopes: []Scope{ReadScope, WriteScope}},
+		},
+		{
+			name:  "algorithm of another key",
+			token: keys.sign(t, "ES256", "rsa", claims(nil)),
+		},
+		{
+			name:  "unknown key",
+			token: keys.sign(t, "RS256", "unknown", claims(nil)),
+		},
+		{
+			name:  "none algorithm",
+			token: keys.sign(t, "none", "rsa", claims(nil)),
+		},
+		{
+			name:      "expired within leeway",
+			token:     keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"exp": now.Add(-jwtLeeway / 2).Unix()})),
+			principal: &Principal{Name: "service-a", Tenants: []string{"tenant-a"}, Scopes: []Scope{ReadScope, WriteScope}},
+		},
+		{
+			name:  "expired",
+			token: keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})),
+		},
+		{
+			name:  "no expiration",
+			token: keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"exp": nil})),
+		},
+		{
+			name:  "not valid yet",
+			token: keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})),
+		},
+		{
+			name:  "wrong issuer",
+			token: keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"iss": "https://evil.example.com"})),
+		},
+		{
+			name:  "wrong audience",
+			token: keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"aud": "other"})),
+		},
+		{
+			name:  "tampered claims",
+			token: withClaimsOf(keys.sign(t, "RS256", "rsa", claims(nil)), keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"sub": "admin"}))),
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			principal, err := cfg.JWT.verifier.verify(c.token, now)
+			if c.principal == nil {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error received: %s", err)
+			}
+			if principal.Name != c.principal.Name || len(principal.Scopes) != len(c.principal.Scopes) || len(principal.Tenants) != len(c.principal.Tenants) {
+				t.Fatalf("unexpected principal: got %+v wanted %+v", principal, c.principal)
+			}
+			for i := range principal.Scopes {
+				if principal.Scopes[i] != c.principal.Scopes[i] {
+					t.Fatalf("unexpected principal: got %+v wanted %+v", principal, c.principal)
+				}
+			}
+		})
+	}
+}
+
+func TestJWTKeySetURL(t *testing.T) {
+	keys := newTestKeys(t)
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		_, _ = w.Write(keys.jwks)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	set := newURLKeySet(server.URL)
+	if _, err := set.key("rsa", now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.key("ec", now); err != nil {
+		t.Fatal(err)
+	}
+	// Unknown keys are only fetched again after the minimum refresh interval.
+	if _, err := set.key("unknown", now.Add(time.Second)); err == nil {
+		t.Fatal("expected an error")
+	}
+	if fetches != 1 {
+		t.Fatalf("unexpected number of fetches: got %d wanted 1", fetches)
+	}
+	if _, err := set.key("unknown", now.Add(jwksMinRefreshInterval+time.Second)); err == nil {
+		t.Fatal("expected an error")
+	}
+	if fetches != 2 {
+		t.Fatalf("unexpected number of fetches: got %d wanted 2", fetches)
+	}
+}
+
+func TestJWTKeySetURLFailures(t *testing.T) {
+	keys := newTestKeys(t)
+	var (
+		mu      sync.Mutex
+		fetches int
+		failing = true
+	)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches++
+		fail := failing
+		mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		<-release
+		_, _ = w.Write(keys.jwks)
+	}))
+	defer server.Close()
+	fetchCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return fetches
+	}
+
+	now := time.Now()
+	set := newURLKeySet(server.URL)
+	if _, err := set.key("rsa", now); err == nil {
+		t.Fatal("expected an error")
+	}
+	// Fetches back off after a failure.
+	if _, err := set.key("rsa", now.Add(jwksRetryInterval/2)); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := fetchCount(); got != 1 {
+		t.Fatalf("unexpected number of fetches: got %d wanted 1", got)
+	}
+
+	// Concurrent requests wait for a single fetch.
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := set.key("rsa", now.Add(jwksRetryInterval))
+			errs <- err
+		}()
+	}
+	for fetchCount() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := fetchCount(); got != 2 {
+		t.Fatalf("unexpected number of fetches: got %d wanted 2", got)
+	}
+}
+
+func TestJWTValidateConfig(t *testing.T) {
+	keys := newTestKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, keys.jwks, 0600); err != nil {
+		t.Fatal(err)
+	}
+	scopes := JWTConfig{ReadScope: "r", WriteScope: "w", AdminScope: "a"}
+
+	cfg := &Config{JWT: scopes, BearerToken: "foo"}
+	cfg.JWT.JWKSFile = path
+	if err := Validate(cfg); err != jwtExclusiveSetError {
+		t.Fatalf("unexpected error received: %v", err)
+	}
+	cfg = &Config{JWT: scopes}
+	cfg.JWT.JWKSFile, cfg.JWT.JWKSURL = path, "http://localhost"
+	if err := Validate(cfg); err != jwksFlagsSetError {
+		t.Fatalf("unexpected error received: %v", err)
+	}
+	cfg = &Config{JWT: scopes}
+	cfg.JWT.JWKSFile, cfg.JWT.WriteScope = path, "r"
+	if err := Validate(cfg); err == nil {
+		t.Fatal("expected an error for duplicate scopes")
+	}
+	cfg = &Config{JWT: scopes}
+	cfg.JWT.JWKSFile = "auth_test.go"
+	if err := Validate(cfg); err == nil {
+		t.Fatal("expected an error for an invalid JWKS file")
+	}
+}
+
+func TestJWTAuthHandler(t *testing.T) {
+	keys := newTestKeys(t)
+	cfg := newJWTConfig(t, keys)
+	claims := map[string]interface{}{
+		"sub":   "reader",
+		"iss":   "https://sso.example.com",
+		"aud":   "promscale",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "promscale:read",
+	}
+	token := keys.sign(t, "RS256", "rsa", claims)
+
+	testCases := []struct {
+		name   string
+		header string
+		scope  Scope
+		code   int
+	}{
+		{name: "no token", scope: ReadScope, code: http.StatusUnauthorized},
+		{name: "invalid token", header: "Bearer foo", scope: ReadScope, code: http.StatusUnauthorized},
+		{name: "authorized", header: "Bearer " + token, scope: ReadScope, code: http.StatusOK},
+		{name: "forbidden", header: "Bearer " + token, scope: WriteScope, code: http.StatusForbidden},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			h := cfg.AuthHandler(RequireScope(c.scope, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+			req := httptest.NewRequest("GET", "/api/v1/query", nil)
+			if c.header != "" {
+				req.Header.Set("Authorization", c.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != c.code {
+				t.Errorf("unexpected HTTP status code received: got %d wanted %d", w.Code, c.code)
+			}
+		})
+	}
+}
+
+func TestJWTUnaryServerInterceptor(t *testing.T) {
+	keys := newTestKeys(t)
+	cfg := newJWTConfig(t, keys)
+	token := keys.sign(t, "ES256", "ec", map[string]interface{}{
+		"sub":   "writer",
+		"iss":   "https://sso.example.com",
+		"aud":   "promscale",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "promscale:write",
+	})
+	methodScope := func(fullMethod string) (Scope, bool) {
+		switch fullMethod {
+		case "/health":
+			return ReadScope, false
+		case "/write":
+			return WriteScope, true
+		}
+		return ReadScope, true
+	}
+	interceptor := cfg.UnaryServerInterceptor(methodScope)
+
+	testCases := []struct {
+		name   string
+		method string
+		token  string
+		code   codes.Code
+	}{
+		{name: "no authentication required", method: "/health", code: codes.OK},
+		{name: "no token", method: "/write", code: codes.Unauthenticated},
+		{name: "invalid token", method: "/write", token: "foo", code: codes.Unauthenticated},
+		{name: "authorized", method: "/write", token: token, code: codes.OK},
+		{name: "forbidden", method: "/read", token: token, code: codes.PermissionDenied},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			if c.token != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+c.token))
+			}
+			var principal *Principal
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: c.method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				principal = PrincipalFromContext(ctx)
+				return nil, nil
+			})
+			if status.Code(err) != c.code {
+				t.Fatalf("unexpected status code: got %s wanted %s", status.Code(err), c.code)
+			}
+			if c.code == codes.OK && c.token != "" && (principal == nil || principal.Name != "writer") {
+				t.Fatalf("unexpected principal: %+v", principal)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package auth
+
+import "context"
+
+// Scope is an operation that a principal can be authorized for.
+type Scope int
+
+const (
+	// ReadScope allows querying data.
+	ReadScope Scope = iota
+	// WriteScope allows ingesting data.
+	WriteScope
+	// AdminScope allows deleting data and using the administrative endpoints. It
+	// implies the read and write scopes.
+	AdminScope
+)
+
+func (s Scope) String() string {
+	switch s {
+	case ReadScope:
+		return "read"
+	case WriteScope:
+		return "write"
+	case AdminScope:
+		return "admin"
+	}
+	return "unknown"
+}
+
+// AllTenants is the tenant name allowing a principal to access all tenants.
+const AllTenants = "*"
+
+// Principal is the authenticated identity of a request.
+type Principal struct {
+	Name string
+	// Tenants that the principal is allowed to read and write. No tenant is
+	// allowed if empty, and all tenants are allowed if it contains AllTenants.
+	Tenants []string
+	// Scopes that the principal is authorized for. All scopes are authorized if nil.
+	Scopes []Scope
+}
+
+// AllowsTenant returns true if the principal is allowed to access the tenant.
+// A nil principal, i.e. an unauthenticated request, is not restricted.
+func (p *Principal) AllowsTenant(tenant string) bool {
+	if !p.RestrictsTenants() {
+		return true
+	}
+	for _, t := range p.Tenants {
+		if t == tenant {
+			return true
+		}
+	}
+	return false
+}
+
+// RestrictsTenants returns true if the principal is only allowed to access some tenants,
+// possibly none.
+func (p *Principal) RestrictsTenants() bool {
+	if p == nil {
+		return false
+	}
+	for _, t := range p.Tenants {
+		if t == AllTenants {
+			return false
+		}
+	}
+	return true
+}
+
+// SingleTenant returns the tenant of a principal restricted to a single tenant, or
+// an empty string otherwise.
+func (p *Principal) SingleTenant() string {
+	if !p.RestrictsTenants() || len(p.Tenants) != 1 {
+		return ""
+	}
+	return p.Tenants[0]
+}
+
+// HasScope returns true if the principal is authorized for the scope. A nil
+// principal, i.e. an unauthenticated request, is authorized for all scopes.
+func (p *Principal) HasScope(scope Scope) bool {
+	if p == nil || p.Scopes == nil {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope || s == AdminScope {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// NewContext returns a new context carrying the principal.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored in the context, or nil if
+// the request was not authenticated.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
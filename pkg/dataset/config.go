@@ -1,3 +1,7 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
 package dataset
 
 import (
@@ -17,6 +21,7 @@ const (
 	defaultMetricHALeaseTimeout  = 1 * time.Minute
 	defaultMetricRetentionPeriod = 90 * 24 * time.Hour
 	defaultTraceRetentionPeriod  = 30 * 24 * time.Hour
+	defaultLogRetentionPeriod    = 30 * 24 * time.Hour
 )
 
 var (
@@ -26,6 +31,7 @@ var (
 	setDefaultMetricHAReleaseTimeoutSQL = `SELECT _prom_catalog.set_default_value('ha_lease_timeout', $1::text)`
 	setDefaultMetricRetentionPeriodSQL  = "SELECT prom_api.set_default_retention_period($1)"
 	setDefaultTraceRetentionPeriodSQL   = "SELECT ps_trace.set_trace_retention_period($1)"
+	setDefaultLogRetentionPeriodSQL     = "SELECT _ps_log.set_log_retention_period($1)"
 
 	defaultMetricCompressionVar = defaultMetricCompression
 )
@@ -34,6 +40,7 @@ var (
 type Config struct {
 	Metrics `yaml:"metrics"`
 	Traces  `yaml:"traces"`
+	Logs    `yaml:"logs"`
 }
 
 // Metrics contains dataset configuration options for metrics data.
@@ -43,6 +50,10 @@ type Metrics struct {
 	HALeaseRefresh  DayDuration `yaml:"ha_lease_refresh"`
 	HALeaseTimeout  DayDuration `yaml:"ha_lease_timeout"`
 	RetentionPeriod DayDuration `yaml:"default_retention_period"`
+	// Overrides of the defaults for specific metrics.
+	Overrides []MetricOverride `yaml:"overrides"`
+	// Downsampled copies of the metrics.
+	Rollups []Rollup `yaml:"rollups"`
 }
 
 // Traces contains dataset configuration options for traces data.
@@ -50,10 +61,25 @@ type Traces struct {
 	RetentionPeriod DayDuration `yaml:"default_retention_period"`
 }
 
+// Logs contains dataset configuration options for logs data.
+type Logs struct {
+	RetentionPeriod DayDuration `yaml:"default_retention_period"`
+}
+
 // NewConfig creates a new dataset config based on the configuration YAML contents.
 func NewConfig(contents string) (cfg Config, err error) {
-	err = yaml.Unmarshal([]byte(contents), &cfg)
-	return cfg, err
+	if err = yaml.Unmarshal([]byte(contents), &cfg); err != nil {
+		return cfg, err
+	}
+	for i, o := range cfg.Metrics.Overrides {
+		if err = o.validate(); err != nil {
+			return cfg, fmt.Errorf("metrics override %d: %w", i, err)
+		}
+	}
+	if err = validateRollups(cfg.Metrics.Rollups); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
 }
 
 // Apply applies the configuration to the database via the supplied DB connection.
@@ -66,6 +92,7 @@ func (c *Config) Apply(conn *pgx.Conn) error {
 	log.Info("msg", fmt.Sprintf("Setting metric dataset default high availability lease timeout to %s", c.Metrics.HALeaseTimeout))
 	log.Info("msg", fmt.Sprintf("Setting metric dataset default retention period to %s", c.Metrics.RetentionPeriod))
 	log.Info("msg", fmt.Sprintf("Setting trace dataset default retention period to %s", c.Traces.RetentionPeriod))
+	log.Info("msg", fmt.Sprintf("Setting log dataset default retention period to %s", c.Logs.RetentionPeriod))
 
 	queries := map[string]interface{}{
 		setDefaultMetricChunkIntervalSQL:    time.Duration(c.Metrics.ChunkInterval),
@@ -74,6 +101,7 @@ func (c *Config) Apply(conn *pgx.Conn) error {
 		setDefaultMetricHAReleaseTimeoutSQL: time.Duration(c.Metrics.HALeaseTimeout),
 		setDefaultMetricRetentionPeriodSQL:  time.Duration(c.Metrics.RetentionPeriod),
 		setDefaultTraceRetentionPeriodSQL:   time.Duration(c.Traces.RetentionPeriod),
+		setDefaultLogRetentionPeriodSQL:     time.Duration(c.Logs.RetentionPeriod),
 	}
 
 	for sql, param := range queries {
@@ -82,7 +110,10 @@ func (c *Config) Apply(conn *pgx.Conn) error {
 		}
 	}
 
-	return nil
+	if err := c.applyMetricOverrides(context.Background(), conn); err != nil {
+		return err
+	}
+	return c.applyRollups(context.Background(), conn)
 }
 
 func (c *Config) applyDefaults() {
@@ -104,4 +135,7 @@ func (c *Config) applyDefaults() {
 	if c.Traces.RetentionPeriod <= 0 {
 		c.Traces.RetentionPeriod = DayDuration(defaultTraceRetentionPeriod)
 	}
+	if c.Logs.RetentionPeriod <= 0 {
+		c.Logs.RetentionPeriod = DayDuration(defaultLogRetentionPeriod)
+	}
 }
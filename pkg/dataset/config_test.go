@@ -58,7 +58,9 @@ func TestNewConfig(t *testing.T) {
   ha_lease_timeout: 5s
   default_retention_period: 30d
 traces:
-  default_retention_period: 15d`,
+  default_retention_period: 15d
+logs:
+  default_retention_period: 7d`,
 			cfg: Config{
 				Metrics: Metrics{
 					ChunkInterval:   DayDuration(3 * time.Hour),
@@ -70,8 +72,117 @@ traces:
 				Traces: Traces{
 					RetentionPeriod: DayDuration(15 * 24 * time.Hour),
 				},
+				Logs: Logs{
+					RetentionPeriod: DayDuration(7 * 24 * time.Hour),
+				},
 			},
 		},
+		{
+			name: "metric overrides",
+			input: `metrics:
+  overrides:
+    - metric: http_requests_total
+      retention_period: 30d
+    - metric_regex: node_.*
+      chunk_interval: 1h
+      compress_data: true`,
+			cfg: Config{
+				Metrics: Metrics{
+					Overrides: []MetricOverride{
+						{Metric: "http_requests_total", RetentionPeriod: DayDuration(30 * 24 * time.Hour)},
+						{MetricRegex: "node_.*", ChunkInterval: DayDuration(time.Hour), Compression: &testCompressionSetting},
+					},
+				},
+			},
+		},
+		{
+			name: "override without metric",
+			input: `metrics:
+  overrides:
+    - retention_period: 30d`,
+			err: "metrics override 0: exactly one of metric and metric_regex must be set",
+		},
+		{
+			name: "override with metric and regex",
+			input: `metrics:
+  overrides:
+    - metric: foo
+      metric_regex: foo.*
+      retention_period: 30d`,
+			err: "metrics override 0: exactly one of metric and metric_regex must be set",
+		},
+		{
+			name: "override with invalid regex",
+			input: `metrics:
+  overrides:
+    - metric_regex: "foo("
+      retention_period: 30d`,
+			err: "metrics override 0: invalid metric_regex \"foo(\": error parsing regexp: missing closing ): `^(?:foo()$`",
+		},
+		{
+			name: "override without settings",
+			input: `metrics:
+  overrides:
+    - metric: foo`,
+			err: "metrics override 0: at least one of chunk_interval, compress_data and retention_period must be set",
+		},
+		{
+			name: "rollups",
+			input: `metrics:
+  rollups:
+    - name: 5m
+      resolution: 5m
+      retention_period: 180d
+    - name: 1h
+      resolution: 1h
+      retention_period: 730d`,
+			cfg: Config{
+				Metrics: Metrics{
+					Rollups: []Rollup{
+						{Name: "5m", Resolution: DayDuration(5 * time.Minute), RetentionPeriod: DayDuration(180 * 24 * time.Hour)},
+						{Name: "1h", Resolution: DayDuration(time.Hour), RetentionPeriod: DayDuration(730 * 24 * time.Hour)},
+					},
+				},
+			},
+		},
+		{
+			name: "rollup with invalid name",
+			input: `metrics:
+  rollups:
+    - name: 5-minutes
+      resolution: 5m
+      retention_period: 180d`,
+			err: `metrics rollup 0: name "5-minutes" must consist of 1 to 46 lowercase letters, digits and underscores`,
+		},
+		{
+			name: "rollup with sub-second resolution",
+			input: `metrics:
+  rollups:
+    - name: fast
+      resolution: 1500ms
+      retention_period: 1d`,
+			err: "metrics rollup 0: resolution must be a positive number of seconds",
+		},
+		{
+			name: "rollup without retention period",
+			input: `metrics:
+  rollups:
+    - name: 5m
+      resolution: 5m`,
+			err: "metrics rollup 0: retention_period must be at least the resolution",
+		},
+		{
+			name: "rollups with the same resolution",
+			input: `metrics:
+  rollups:
+    - name: a
+      resolution: 1h
+      retention_period: 30d
+    - name: b
+      resolution: 60m
+      retention_period: 60d`,
+			err: "metrics rollup 1: duplicate resolution 1h0m0s",
+		},
 	}
 
 	for _, c := range testCases {
@@ -106,6 +217,9 @@ func TestApplyDefaults(t *testing.T) {
 			Traces: Traces{
 				RetentionPeriod: DayDuration(defaultTraceRetentionPeriod),
 			},
+			Logs: Logs{
+				RetentionPeriod: DayDuration(defaultLogRetentionPeriod),
+			},
 		},
 		c,
 	)
@@ -121,6 +235,9 @@ func TestApplyDefaults(t *testing.T) {
 		Traces: Traces{
 			RetentionPeriod: DayDuration(15 * 24 * time.Hour),
 		},
+		Logs: Logs{
+			RetentionPeriod: DayDuration(7 * 24 * time.Hour),
+		},
 	}
 
 	copyConfig := untouched
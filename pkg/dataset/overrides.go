@@ -0,0 +1,275 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package dataset
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/regexp"
+	"github.com/jackc/pgx/v4"
+	"github.com/timescale/promscale/pkg/log"
+)
+
+var (
+	listMetricsSQL           = "SELECT metric_name FROM _prom_catalog.metric WHERE NOT is_view"
+	listAppliedOverridesSQL  = "SELECT metric_name, chunk_interval, compress_data, retention_period FROM _prom_catalog.metric_setting_override"
+	clearAppliedOverridesSQL = "DELETE FROM _prom_catalog.metric_setting_override"
+	insertAppliedOverrideSQL = "INSERT INTO _prom_catalog.metric_setting_override (metric_name, chunk_interval, compress_data, retention_period) VALUES ($1, $2, $3, $4)"
+
+	setMetricChunkIntervalSQL     = "SELECT prom_api.set_metric_chunk_interval($1, $2)"
+	resetMetricChunkIntervalSQL   = "SELECT prom_api.reset_metric_chunk_interval($1)"
+	setMetricCompressionSQL       = "SELECT prom_api.set_metric_compression_setting($1, $2)"
+	resetMetricCompressionSQL     = "SELECT prom_api.reset_metric_compression_setting($1)"
+	setMetricRetentionPeriodSQL   = "SELECT prom_api.set_metric_retention_period($1, $2)"
+	resetMetricRetentionPeriodSQL = "SELECT prom_api.reset_metric_retention_period($1)"
+)
+
+// MetricOverride overrides the dataset defaults for the metrics matching either
+// Metric exactly or MetricRegex. Settings that are not set keep the defaults.
+type MetricOverride struct {
+	Metric          string      `yaml:"metric"`
+	MetricRegex     string      `yaml:"metric_regex"`
+	ChunkInterval   DayDuration `yaml:"chunk_interval"`
+	Compression     *bool       `yaml:"compress_data"`
+	RetentionPeriod DayDuration `yaml:"retention_period"`
+}
+
+func (o MetricOverride) validate() error {
+	if (o.Metric == "") == (o.MetricRegex == "") {
+		return fmt.Errorf("exactly one of metric and metric_regex must be set")
+	}
+	if o.MetricRegex != "" {
+		if _, err := compileMetricRegex(o.MetricRegex); err != nil {
+			return fmt.Errorf("invalid metric_regex %q: %w", o.MetricRegex, err)
+		}
+	}
+	if o.ChunkInterval < 0 || o.RetentionPeriod < 0 {
+		return fmt.Errorf("chunk_interval and retention_period must not be negative")
+	}
+	if o.ChunkInterval == 0 && o.Compression == nil && o.RetentionPeriod == 0 {
+		return fmt.Errorf("at least one of chunk_interval, compress_data and retention_period must be set")
+	}
+	return nil
+}
+
+func compileMetricRegex(s string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + s + ")$")
+}
+
+// metricSettings are the settings of a metric that differ from the dataset defaults.
+// A nil setting is not overridden.
+type metricSettings struct {
+	ChunkInterval   *time.Duration
+	Compression     *bool
+	RetentionPeriod *time.Duration
+}
+
+// resolveMetricOverrides returns the settings of the metrics matched by the overrides.
+// Overrides with an exact metric name apply whether the metric exists or not, while
+// regex overrides only apply to the given existing metrics. Metrics created later are
+// matched by ApplyNewMetricOverrides. For each setting, the first matching override
+// that sets it wins.
+func resolveMetricOverrides(overrides []MetricOverride, metrics []string) map[string]metricSettings {
+	resolved := make(map[string]metricSettings)
+	merge := func(metric string, o MetricOverride) {
+		s := resolved[metric]
+		if s.ChunkInterval == nil && o.ChunkInterval > 0 {
+			d := time.Duration(o.ChunkInterval)
+			s.ChunkInterval = &d
+		}
+		if s.Compression == nil && o.Compression != nil {
+			c := *o.Compression
+			s.Compression = &c
+		}
+		if s.RetentionPeriod == nil && o.RetentionPeriod > 0 {
+			d := time.Duration(o.RetentionPeriod)
+			s.RetentionPeriod = &d
+		}
+		resolved[metric] = s
+	}
+	for _, o := range overrides {
+		if o.Metric != "" {
+			merge(o.Metric, o)
+			continue
+		}
+		// The regex has been validated by NewConfig.
+		re, err := compileMetricRegex(o.MetricRegex)
+		if err != nil {
+			continue
+		}
+		for _, metric := range metrics {
+			if re.MatchString(metric) {
+				merge(metric, o)
+			}
+		}
+	}
+	return resolved
+}
+
+type statement struct {
+	sql  string
+	args []interface{}
+}
+
+// reconcileStatements returns the statements that bring the metrics from the
+// previously applied settings to the desired ones. Settings that are no longer
+// overridden are reset to the defaults, unless the metric does not exist anymore.
+func reconcileStatements(previous, desired map[string]metricSettings, existing []string) []statement {
+	exists := make(map[string]bool, len(existing))
+	for _, m := range existing {
+		exists[m] = true
+	}
+	metrics := make([]string, 0, len(previous)+len(desired))
+	for m := range desired {
+		metrics = append(metrics, m)
+	}
+	for m := range previous {
+		if _, ok := desired[m]; !ok {
+			metrics = append(metrics, m)
+		}
+	}
+	sort.Strings(metrics)
+
+	var stmts []statement
+	for _, m := range metrics {
+		want, prev := desired[m], previous[m]
+		canReset := exists[m]
+		switch {
+		case want.ChunkInterval != nil:
+			stmts = append(stmts, statement{setMetricChunkIntervalSQL, []interface{}{m, *want.ChunkInterval}})
+		case prev.ChunkInterval != nil && canReset:
+			stmts = append(stmts, statement{resetMetricChunkIntervalSQL, []interface{}{m}})
+		}
+		switch {
+		case want.Compression != nil:
+			stmts = append(stmts, statement{setMetricCompressionSQL, []interface{}{m, *want.Compression}})
+		case prev.Compression != nil && canReset:
+			stmts = append(stmts, statement{resetMetricCompressionSQL, []interface{}{m}})
+		}
+		switch {
+		case want.RetentionPeriod != nil:
+			stmts = append(stmts, statement{setMetricRetentionPeriodSQL, []interface{}{m, *want.RetentionPeriod}})
+		case prev.RetentionPeriod != nil && canReset:
+			stmts = append(stmts, statement{resetMetricRetentionPeriodSQL, []interface{}{m}})
+		}
+	}
+	return stmts
+}
+
+// applyMetricOverrides reconciles the per-metric settings with the overrides of the
+// configuration. The applied settings are recorded, so that the settings of metrics
+// which are not matched anymore are reset on the next reconciliation.
+func (c *Config) applyMetricOverrides(ctx context.Context, conn *pgx.Conn) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	metrics, err := queryStrings(ctx, tx, listMetricsSQL)
+	if err != nil {
+		return fmt.Errorf("list metrics: %w", err)
+	}
+	previous, err := appliedMetricOverrides(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("list applied metric overrides: %w", err)
+	}
+	desired := resolveMetricOverrides(c.Metrics.Overrides, metrics)
+
+	for _, stmt := range reconcileStatements(previous, desired, metrics) {
+		if _, err = tx.Exec(ctx, stmt.sql, stmt.args...); err != nil {
+			return fmt.Errorf("apply override of metric %s: %w", stmt.args[0], err)
+		}
+	}
+	if _, err = tx.Exec(ctx, clearAppliedOverridesSQL); err != nil {
+		return fmt.Errorf("record applied metric overrides: %w", err)
+	}
+	for metric, s := range desired {
+		if _, err = tx.Exec(ctx, insertAppliedOverrideSQL, metric, s.ChunkInterval, s.Compression, s.RetentionPeriod); err != nil {
+			return fmt.Errorf("record applied metric overrides: %w", err)
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit metric overrides: %w", err)
+	}
+	if len(desired) > 0 || len(previous) > 0 {
+		log.Info("msg", fmt.Sprintf("Applied dataset overrides to %d metrics", len(desired)))
+	}
+	return nil
+}
+
+// ApplyNewMetricOverrides applies the overrides to the metrics that have no applied
+// override recorded yet, i.e. the metrics created since the last reconciliation that
+// match a regex override. It is run by the ingestor after new metrics are created.
+func (c *Config) ApplyNewMetricOverrides(ctx context.Context, tx pgx.Tx) error {
+	metrics, err := queryStrings(ctx, tx, listMetricsSQL)
+	if err != nil {
+		return fmt.Errorf("list metrics: %w", err)
+	}
+	previous, err := appliedMetricOverrides(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("list applied metric overrides: %w", err)
+	}
+	desired := resolveMetricOverrides(c.Metrics.Overrides, metrics)
+	for metric := range previous {
+		delete(desired, metric)
+	}
+	if len(desired) == 0 {
+		return nil
+	}
+
+	for _, stmt := range reconcileStatements(nil, desired, metrics) {
+		if _, err = tx.Exec(ctx, stmt.sql, stmt.args...); err != nil {
+			return fmt.Errorf("apply override of metric %s: %w", stmt.args[0], err)
+		}
+	}
+	for metric, s := range desired {
+		if _, err = tx.Exec(ctx, insertAppliedOverrideSQL, metric, s.ChunkInterval, s.Compression, s.RetentionPeriod); err != nil {
+			return fmt.Errorf("record applied metric overrides: %w", err)
+		}
+	}
+	log.Info("msg", fmt.Sprintf("Applied dataset overrides to %d new metrics", len(desired)))
+	return nil
+}
+
+func queryStrings(ctx context.Context, tx pgx.Tx, sql string) ([]string, error) {
+	rows, err := tx.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []string
+	for rows.Next() {
+		var s string
+		if err = rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+func appliedMetricOverrides(ctx context.Context, tx pgx.Tx) (map[string]metricSettings, error) {
+	rows, err := tx.Query(ctx, listAppliedOverridesSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[string]metricSettings)
+	for rows.Next() {
+		var (
+			metric string
+			s      metricSettings
+		)
+		if err = rows.Scan(&metric, &s.ChunkInterval, &s.Compression, &s.RetentionPeriod); err != nil {
+			return nil, err
+		}
+		applied[metric] = s
+	}
+	return applied, rows.Err()
+}
@@ -0,0 +1,55 @@
+package dataset
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveMetricOverrides(t *testing.T) {
+	var (
+		disabled = false
+		hour     = time.Hour
+		day      = 24 * time.Hour
+		week     = 7 * 24 * time.Hour
+	)
+	overrides := []MetricOverride{
+		{Metric: "node_load1", RetentionPeriod: DayDuration(week)},
+		{MetricRegex: "node_.*", ChunkInterval: DayDuration(hour), RetentionPeriod: DayDuration(day)},
+		{MetricRegex: "node_cpu_.*", ChunkInterval: DayDuration(day), Compression: &disabled},
+		{Metric: "not_yet_ingested", RetentionPeriod: DayDuration(day)},
+	}
+	metrics := []string{"node_load1", "node_cpu_seconds_total", "up"}
+
+	require.Equal(t, map[string]metricSettings{
+		"node_load1":             {ChunkInterval: &hour, RetentionPeriod: &week},
+		"node_cpu_seconds_total": {ChunkInterval: &hour, Compression: &disabled, RetentionPeriod: &day},
+		"not_yet_ingested":       {RetentionPeriod: &day},
+	}, resolveMetricOverrides(overrides, metrics))
+}
+
+func TestReconcileStatements(t *testing.T) {
+	var (
+		enabled = true
+		hour    = time.Hour
+		day     = 24 * time.Hour
+	)
+	previous := map[string]metricSettings{
+		"changed": {ChunkInterval: &hour, RetentionPeriod: &day},
+		"removed": {Compression: &enabled},
+		"dropped": {RetentionPeriod: &day},
+	}
+	desired := map[string]metricSettings{
+		"added":   {RetentionPeriod: &hour},
+		"changed": {ChunkInterval: &day},
+	}
+	existing := []string{"added", "changed", "removed"}
+
+	require.Equal(t, []statement{
+		{setMetricRetentionPeriodSQL, []interface{}{"added", hour}},
+		{setMetricChunkIntervalSQL, []interface{}{"changed", day}},
+		{resetMetricRetentionPeriodSQL, []interface{}{"changed"}},
+		{resetMetricCompressionSQL, []interface{}{"removed"}},
+	}, reconcileStatements(previous, desired, existing))
+}
@@ -0,0 +1,106 @@
+package dataset
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/regexp"
+	"github.com/jackc/pgx/v4"
+	"github.com/timescale/promscale/pkg/log"
+)
+
+var (
+	listRollupsSQL  = "SELECT name FROM _prom_catalog.rollup"
+	createRollupSQL = "SELECT _prom_catalog.create_rollup($1, $2, $3)"
+	dropRollupSQL   = "SELECT _prom_catalog.drop_rollup($1)"
+
+	// The rollup schema is prefixed with prom_data_rollup_, and must fit in a NAME.
+	rollupNameRegex = regexp.MustCompile("^[a-z0-9_]{1,46}$")
+)
+
+// Rollup declares a downsampled copy of all metrics at a coarser resolution,
+// which is kept for its own retention period. Queries with a step of at least
+// the resolution read from the rollup instead of the raw samples.
+type Rollup struct {
+	Name            string      `yaml:"name"`
+	Resolution      DayDuration `yaml:"resolution"`
+	RetentionPeriod DayDuration `yaml:"retention_period"`
+}
+
+func (r Rollup) validate() error {
+	if !rollupNameRegex.MatchString(r.Name) {
+		return fmt.Errorf("name %q must consist of 1 to 46 lowercase letters, digits and underscores", r.Name)
+	}
+	if r.Resolution < DayDuration(time.Second) || time.Duration(r.Resolution)%time.Second != 0 {
+		return fmt.Errorf("resolution must be a positive number of seconds")
+	}
+	if r.RetentionPeriod < r.Resolution {
+		return fmt.Errorf("retention_period must be at least the resolution")
+	}
+	return nil
+}
+
+func validateRollups(rollups []Rollup) error {
+	names := make(map[string]bool, len(rollups))
+	resolutions := make(map[DayDuration]bool, len(rollups))
+	for i, r := range rollups {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("metrics rollup %d: %w", i, err)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("metrics rollup %d: duplicate name %q", i, r.Name)
+		}
+		if resolutions[r.Resolution] {
+			return fmt.Errorf("metrics rollup %d: duplicate resolution %s", i, time.Duration(r.Resolution))
+		}
+		names[r.Name], resolutions[r.Resolution] = true, true
+	}
+	return nil
+}
+
+// rollupStatements returns the statements that create or update the desired
+// rollups and drop the existing rollups which are not declared anymore.
+func rollupStatements(existing []string, desired []Rollup) []statement {
+	declared := make(map[string]bool, len(desired))
+	var stmts []statement
+	for _, r := range desired {
+		declared[r.Name] = true
+		stmts = append(stmts, statement{createRollupSQL, []interface{}{r.Name, time.Duration(r.Resolution), time.Duration(r.RetentionPeriod)}})
+	}
+	sort.Strings(existing)
+	for _, name := range existing {
+		if !declared[name] {
+			stmts = append(stmts, statement{dropRollupSQL, []interface{}{name}})
+		}
+	}
+	return stmts
+}
+
+// applyRollups reconciles the rollups in the database with the ones of the
+// configuration. Rollups which are not declared anymore are dropped with their data.
+func (c *Config) applyRollups(ctx context.Context, conn *pgx.Conn) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	existing, err := queryStrings(ctx, tx, listRollupsSQL)
+	if err != nil {
+		return fmt.Errorf("list rollups: %w", err)
+	}
+	for _, stmt := range rollupStatements(existing, c.Metrics.Rollups) {
+		if _, err = tx.Exec(ctx, stmt.sql, stmt.args...); err != nil {
+			return fmt.Errorf("apply rollup %s: %w", stmt.args[0], err)
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit rollups: %w", err)
+	}
+	if len(c.Metrics.Rollups) > 0 || len(existing) > 0 {
+		log.Info("msg", fmt.Sprintf("Applied %d metric rollups", len(c.Metrics.Rollups)))
+	}
+	return nil
+}
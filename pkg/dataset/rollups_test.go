@@ -0,0 +1,23 @@
+package dataset
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollupStatements(t *testing.T) {
+	desired := []Rollup{
+		{Name: "1h", Resolution: DayDuration(time.Hour), RetentionPeriod: DayDuration(365 * 24 * time.Hour)},
+		{Name: "5m", Resolution: DayDuration(5 * time.Minute), RetentionPeriod: DayDuration(30 * 24 * time.Hour)},
+	}
+	existing := []string{"removed", "5m", "also_removed"}
+
+	require.Equal(t, []statement{
+		{createRollupSQL, []interface{}{"1h", time.Hour, 365 * 24 * time.Hour}},
+		{createRollupSQL, []interface{}{"5m", 5 * time.Minute, 30 * 24 * time.Hour}},
+		{dropRollupSQL, []interface{}{"also_removed"}},
+		{dropRollupSQL, []interface{}{"removed"}},
+	}, rollupStatements(existing, desired))
+}
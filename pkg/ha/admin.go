@@ -0,0 +1,175 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package ha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/timescale/promscale/pkg/ha/client"
+	"github.com/timescale/promscale/pkg/ha/state"
+	"github.com/timescale/promscale/pkg/log"
+)
+
+var (
+	ErrUnknownCluster = fmt.Errorf("unknown HA cluster")
+	ErrLeaderPinned   = fmt.Errorf("leader of HA cluster is pinned")
+)
+
+// Leases returns the state of the leases of all the clusters, as stored in the
+// database, sorted by cluster. The data times seen are only known for the
+// clusters this service has seen data from.
+func (s *Service) Leases(ctx context.Context) ([]state.Snapshot, error) {
+	leases, err := s.leaseClient.GetLeases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pins, err := s.leaseClient.GetLeaderPins(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]state.Snapshot, 0, len(leases))
+	for _, lease := range leases {
+		snapshots = append(snapshots, s.snapshot(lease, pins[lease.Cluster]))
+	}
+	return snapshots, nil
+}
+
+// Failover makes the replica the leader of the cluster from the end of the
+// current lease, without waiting for the leader to stop sending data. Clusters
+// pinned to another leader must be unpinned first.
+func (s *Service) Failover(ctx context.Context, cluster, replica string) (state.Snapshot, error) {
+	current, err := s.currentLease(ctx, cluster)
+	if err != nil {
+		return state.Snapshot{}, err
+	}
+	pins, err := s.leaseClient.GetLeaderPins(ctx)
+	if err != nil {
+		return state.Snapshot{}, err
+	}
+	pinned := pins[cluster]
+	if pinned != "" && pinned != replica {
+		return s.snapshot(current, pinned), fmt.Errorf("%w: cluster %s is pinned to %s", ErrLeaderPinned, cluster, pinned)
+	}
+	if current.Leader == replica {
+		return s.snapshot(current, pinned), nil
+	}
+	current, err = s.changeLeader(ctx, current, replica)
+	return s.snapshot(current, pinned), err
+}
+
+// PinLeader makes the replica the leader of the cluster, failing over to it if
+// needed, and disables automatic leader changes until the cluster is unpinned.
+// Pins are stored in the database, so they apply to all Promscale instances.
+func (s *Service) PinLeader(ctx context.Context, cluster, replica string) (state.Snapshot, error) {
+	current, err := s.currentLease(ctx, cluster)
+	if err != nil {
+		return state.Snapshot{}, err
+	}
+	if current.Leader != replica {
+		if current, err = s.changeLeader(ctx, current, replica); err != nil {
+			return s.snapshot(current, ""), err
+		}
+	}
+	if err = s.leaseClient.PinLeader(ctx, cluster, replica); err != nil {
+		return s.snapshot(current, ""), err
+	}
+	s.pins.Store(cluster, replica)
+	if l, ok := s.state.Load(cluster); ok {
+		l.(*state.Lease).SetPinnedLeader(replica)
+	}
+	return s.snapshot(current, replica), nil
+}
+
+// UnpinLeader enables automatic leader changes of the cluster again.
+func (s *Service) UnpinLeader(ctx context.Context, cluster string) error {
+	if err := s.leaseClient.UnpinLeader(ctx, cluster); err != nil {
+		return err
+	}
+	s.pins.Delete(cluster)
+	if l, ok := s.state.Load(cluster); ok {
+		l.(*state.Lease).SetPinnedLeader("")
+	}
+	return nil
+}
+
+// currentLease reads the lease of the cluster from the database, so that the
+// clusters this service has not seen data from can be administered too.
+func (s *Service) currentLease(ctx context.Context, cluster string) (client.LeaseDBState, error) {
+	lease, err := s.leaseClient.GetLease(ctx, cluster)
+	if errors.Is(err, client.ErrNoLease) {
+		return lease, fmt.Errorf("%w: %s", ErrUnknownCluster, cluster)
+	}
+	return lease, err
+}
+
+// changeLeader makes the replica the leader of the cluster from the end of the
+// current lease, or from the max data time seen by this service if later.
+func (s *Service) changeLeader(ctx context.Context, current client.LeaseDBState, replica string) (client.LeaseDBState, error) {
+	maxTime := current.LeaseUntil
+	l, seen := s.state.Load(current.Cluster)
+	if seen {
+		if maxTimeSeen := l.(*state.Lease).Snapshot().MaxTimeSeen; maxTimeSeen.After(maxTime) {
+			maxTime = maxTimeSeen
+		}
+	}
+	changed, err := s.leaseClient.TryChangeLeader(ctx, current.Cluster, replica, maxTime)
+	if err != nil {
+		return current, fmt.Errorf("could not change leader: %w", err)
+	}
+	if seen {
+		l.(*state.Lease).SetState(changed)
+	}
+	if changed.Leader != replica {
+		return changed, fmt.Errorf("leader of cluster %s changed concurrently to %s", current.Cluster, changed.Leader)
+	}
+	return changed, nil
+}
+
+// snapshot returns the state of a lease stored in the database, along with the
+// data times seen by this service for the cluster, if any.
+func (s *Service) snapshot(lease client.LeaseDBState, pinned string) state.Snapshot {
+	snapshot := state.Snapshot{
+		Cluster:      lease.Cluster,
+		Leader:       lease.Leader,
+		LeaseStart:   lease.LeaseStart,
+		LeaseUntil:   lease.LeaseUntil,
+		PinnedLeader: pinned,
+	}
+	if l, ok := s.state.Load(lease.Cluster); ok {
+		local := l.(*state.Lease).Snapshot()
+		snapshot.MaxTimeSeen = local.MaxTimeSeen
+		snapshot.MaxTimeInstance = local.MaxTimeInstance
+		if local.Leader == lease.Leader {
+			snapshot.MaxTimeSeenLeader = local.MaxTimeSeenLeader
+			snapshot.RecentLeaderWriteTime = local.RecentLeaderWriteTime
+		}
+	}
+	return snapshot
+}
+
+// syncPins refreshes the pinned leaders from the database, so that pins set
+// through other Promscale instances are applied.
+func (s *Service) syncPins() {
+	pins, err := s.leaseClient.GetLeaderPins(context.Background())
+	if err != nil {
+		log.Error("msg", "failed to get pinned HA leaders", "err", err)
+		return
+	}
+	s.pins.Range(func(c, _ interface{}) bool {
+		if _, ok := pins[c.(string)]; !ok {
+			s.pins.Delete(c)
+		}
+		return true
+	})
+	for cluster, leader := range pins {
+		s.pins.Store(cluster, leader)
+	}
+	s.state.Range(func(c, l interface{}) bool {
+		l.(*state.Lease).SetPinnedLeader(pins[c.(string)])
+		return true
+	})
+}
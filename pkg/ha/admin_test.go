@@ -0,0 +1,108 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package ha
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/timescale/promscale/pkg/ha/client"
+	"github.com/timescale/promscale/pkg/ha/state"
+)
+
+func newAdminTestService(t *testing.T) *Service {
+	leaseStart := time.Unix(100, 0)
+	service := MockNewHAService()
+	SetLeaderInMockService(service, []client.LeaseDBState{
+		{Cluster: "cluster1", Leader: "replica1", LeaseStart: leaseStart, LeaseUntil: leaseStart.Add(time.Minute)},
+		{Cluster: "cluster2", Leader: "replica1", LeaseStart: leaseStart, LeaseUntil: leaseStart.Add(time.Minute)},
+	})
+	if _, _, err := service.CheckLease(leaseStart, leaseStart.Add(time.Second), "cluster1", "replica1"); err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+func TestServiceLeases(t *testing.T) {
+	service := newAdminTestService(t)
+	leases, err := service.Leases(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Clusters this service has not seen data from are listed from the database.
+	if len(leases) != 2 || leases[0].Cluster != "cluster1" || leases[1].Cluster != "cluster2" || leases[1].Leader != "replica1" {
+		t.Fatalf("unexpected leases: %+v", leases)
+	}
+	if !leases[0].MaxTimeSeen.Equal(time.Unix(101, 0)) || !leases[1].MaxTimeSeen.IsZero() {
+		t.Fatalf("unexpected max time seen: %s, %s", leases[0].MaxTimeSeen, leases[1].MaxTimeSeen)
+	}
+}
+
+func TestServiceFailover(t *testing.T) {
+	service := newAdminTestService(t)
+	ctx := context.Background()
+	if _, err := service.Failover(ctx, "unknown", "replica2"); !errors.Is(err, ErrUnknownCluster) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lease, err := service.Failover(ctx, "cluster1", "replica2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Leader != "replica2" {
+		t.Fatalf("failover did not change the leader: %+v", lease)
+	}
+	allowInsert, _, err := service.CheckLease(lease.LeaseStart, lease.LeaseStart, "cluster1", "replica1")
+	if err != nil || allowInsert {
+		t.Fatalf("previous leader can still insert: %v, %v", allowInsert, err)
+	}
+
+	// Clusters this service has not seen data from are failed over in the database.
+	if lease, err = service.Failover(ctx, "cluster2", "replica2"); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := service.leaseClient.GetLease(ctx, "cluster2"); lease.Leader != "replica2" || stored.Leader != "replica2" {
+		t.Fatalf("failover did not change the leader: %+v, %+v", lease, stored)
+	}
+}
+
+func TestServicePinLeader(t *testing.T) {
+	service := newAdminTestService(t)
+	lease, err := service.PinLeader(context.Background(), "cluster1", "replica2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Leader != "replica2" || lease.PinnedLeader != "replica2" {
+		t.Fatalf("unexpected lease: %+v", lease)
+	}
+	if _, err = service.Failover(context.Background(), "cluster1", "replica1"); !errors.Is(err, ErrLeaderPinned) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Pins are not changed automatically, even if only the standby sends data.
+	loaded, _ := service.state.Load("cluster1")
+	l := loaded.(*state.Lease)
+	farFuture := lease.LeaseUntil.Add(time.Hour)
+	l.UpdateMaxSeenTime("replica1", farFuture, time.Now())
+	if err = l.TryChangeLeader(time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if leader := l.Snapshot().Leader; leader != "replica2" {
+		t.Fatalf("pinned leader changed to %s", leader)
+	}
+
+	// Pins set by other instances are synced from the database.
+	if err = service.UnpinLeader(context.Background(), "cluster1"); err != nil {
+		t.Fatal(err)
+	}
+	if pinned := l.Snapshot().PinnedLeader; pinned != "" {
+		t.Fatalf("cluster still pinned to %s", pinned)
+	}
+	_ = service.leaseClient.PinLeader(context.Background(), "cluster1", "replica2")
+	service.syncPins()
+	if pinned := l.Snapshot().PinnedLeader; pinned != "replica2" {
+		t.Fatalf("pin was not synced: %q", pinned)
+	}
+}
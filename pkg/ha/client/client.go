@@ -17,11 +17,13 @@ import (
 const (
 	leasesTable         = "_prom_catalog.ha_leases"
 	leaseLogsTable      = "_prom_catalog.ha_leases_logs"
+	leaderPinsTable     = "_prom_catalog.ha_leader_pin"
 	updateLeaseFn       = "_prom_catalog.update_lease"
 	tryChangeLeaderFn   = "_prom_catalog.try_change_leader"
 	updateLeaseSQL      = "SELECT * FROM " + updateLeaseFn + "($1, $2, $3, $4)"
 	tryChangeLeaderSQL  = "SELECT * FROM " + tryChangeLeaderFn + "($1, $2, $3)"
 	latestLeaseStateSQL = "SELECT leader_name, lease_start, lease_until FROM " + leasesTable + " WHERE cluster_name = $1"
+	getLeasesSQL        = "SELECT cluster_name, leader_name, lease_start, lease_until FROM " + leasesTable + " ORDER BY cluster_name"
 	getPastLeaseInfoSQL = "SELECT lease_start, lease_until FROM " + leaseLogsTable +
 		" WHERE cluster_name = $1" +
 		" AND leader_name = $2" +
@@ -29,11 +31,18 @@ const (
 		" AND lease_until <= $4" +
 		" ORDER BY lease_start" +
 		" LIMIT 1"
+	getLeaderPinsSQL = "SELECT cluster_name, leader_name FROM " + leaderPinsTable
+	pinLeaderSQL     = "INSERT INTO " + leaderPinsTable + " (cluster_name, leader_name) VALUES ($1, $2)" +
+		" ON CONFLICT (cluster_name) DO UPDATE SET leader_name = EXCLUDED.leader_name, pinned_at = now()"
+	unpinLeaderSQL = "DELETE FROM " + leaderPinsTable + " WHERE cluster_name = $1"
 
 	leaderChangedErrCode = "PS010"
 )
 
-var ErrNoPastLease = fmt.Errorf("no past leases found")
+var (
+	ErrNoPastLease = fmt.Errorf("no past leases found")
+	ErrNoLease     = fmt.Errorf("no lease found")
+)
 
 // LeaseDBState represents the current lock holder
 // as reported from the DB.
@@ -61,6 +70,17 @@ type LeaseClient interface {
 	// error signifying the call couldn't be made
 	TryChangeLeader(ctx context.Context, cluster, newLeader string, maxTime time.Time) (LeaseDBState, error)
 	GetPastLeaseInfo(ctx context.Context, cluster, replica string, start, end time.Time) (LeaseDBState, error)
+	// GetLease returns the current lease of a cluster, or ErrNoLease if the
+	// cluster has none.
+	GetLease(ctx context.Context, cluster string) (LeaseDBState, error)
+	// GetLeases returns the current lease of every cluster, sorted by cluster.
+	GetLeases(ctx context.Context) ([]LeaseDBState, error)
+	// GetLeaderPins returns the pinned leader of each pinned cluster.
+	GetLeaderPins(ctx context.Context) (map[string]string, error)
+	// PinLeader pins the leader of a cluster, replacing any existing pin.
+	PinLeader(ctx context.Context, cluster, leader string) error
+	// UnpinLeader removes the pin of a cluster, if any.
+	UnpinLeader(ctx context.Context, cluster string) error
 }
 
 type leaseClientDB struct {
@@ -87,7 +107,7 @@ func (l *leaseClientDB) UpdateLease(ctx context.Context, cluster, leader string,
 	// leader changed
 	if leaderHasChanged {
 		// read latest lease state
-		dbState, err = l.readLeaseState(context.Background(), cluster)
+		dbState, err = l.GetLease(context.Background(), cluster)
 		// couldn't get latest lease state
 		if err != nil {
 			return dbState, fmt.Errorf("could not update lease: %#v", err)
@@ -120,11 +140,62 @@ func (l *leaseClientDB) GetPastLeaseInfo(ctx context.Context, cluster, replica s
 	return dbState, nil
 }
 
-func (l *leaseClientDB) readLeaseState(ctx context.Context, cluster string) (LeaseDBState, error) {
+func (l *leaseClientDB) GetLeaderPins(ctx context.Context) (map[string]string, error) {
+	rows, err := l.dbConn.Query(ctx, getLeaderPinsSQL)
+	if err != nil {
+		return nil, fmt.Errorf("could not get leader pins: %w", err)
+	}
+	defer rows.Close()
+	pins := make(map[string]string)
+	for rows.Next() {
+		var cluster, leader string
+		if err = rows.Scan(&cluster, &leader); err != nil {
+			return nil, fmt.Errorf("could not get leader pins: %w", err)
+		}
+		pins[cluster] = leader
+	}
+	return pins, rows.Err()
+}
+
+func (l *leaseClientDB) PinLeader(ctx context.Context, cluster, leader string) error {
+	if _, err := l.dbConn.Exec(ctx, pinLeaderSQL, cluster, leader); err != nil {
+		return fmt.Errorf("could not pin leader: %w", err)
+	}
+	return nil
+}
+
+func (l *leaseClientDB) UnpinLeader(ctx context.Context, cluster string) error {
+	if _, err := l.dbConn.Exec(ctx, unpinLeaderSQL, cluster); err != nil {
+		return fmt.Errorf("could not unpin leader: %w", err)
+	}
+	return nil
+}
+
+func (l *leaseClientDB) GetLease(ctx context.Context, cluster string) (LeaseDBState, error) {
 	dbState := LeaseDBState{Cluster: cluster}
 	row := l.dbConn.QueryRow(ctx, latestLeaseStateSQL, cluster)
 	if err := row.Scan(&dbState.Leader, &dbState.LeaseStart, &dbState.LeaseUntil); err != nil {
+		if err == pgx.ErrNoRows {
+			return dbState, ErrNoLease
+		}
 		return dbState, err
 	}
 	return dbState, nil
 }
+
+func (l *leaseClientDB) GetLeases(ctx context.Context) ([]LeaseDBState, error) {
+	rows, err := l.dbConn.Query(ctx, getLeasesSQL)
+	if err != nil {
+		return nil, fmt.Errorf("could not get leases: %w", err)
+	}
+	defer rows.Close()
+	var leases []LeaseDBState
+	for rows.Next() {
+		var dbState LeaseDBState
+		if err = rows.Scan(&dbState.Cluster, &dbState.Leader, &dbState.LeaseStart, &dbState.LeaseUntil); err != nil {
+			return nil, fmt.Errorf("could not get leases: %w", err)
+		}
+		leases = append(leases, dbState)
+	}
+	return leases, rows.Err()
+}
@@ -7,43 +7,116 @@ package ha
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/prometheus/common/model"
+	pgmodel "github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/prompb"
 )
 
+// Default names of the labels identifying the HA cluster and replica of series.
 const ReplicaNameLabel = "__replica__"
 const ClusterNameLabel = "cluster"
 
 // Filter is a HA filter which filters data based on lease information it
 // gets from the lease service.
 type Filter struct {
-	service *Service
+	service      *Service
+	clusterLabel string
+	replicaLabel string
 }
 
-// NewFilter creates a new Filter based on the provided Service.
+// NewFilter creates a new Filter based on the provided Service, with the
+// default cluster and replica label names.
 func NewFilter(service *Service) *Filter {
+	return NewFilterWith(service, ClusterNameLabel, ReplicaNameLabel)
+}
+
+// NewFilterWith creates a new Filter based on the provided Service and the
+// names of the labels identifying the cluster and replica of series.
+func NewFilterWith(service *Service, clusterLabel, replicaLabel string) *Filter {
 	return &Filter{
-		service: service,
+		service:      service,
+		clusterLabel: clusterLabel,
+		replicaLabel: replicaLabel,
 	}
 }
 
 // FilterData validates and filters timeseries based on lease info from the service.
 // When Prometheus & Promscale are running HA mode the below FilterData is used
 // to validate leader replica samples & ha_locks in TimescaleDB.
-func (h *Filter) Process(_ *http.Request, wr *prompb.WriteRequest) error {
-	defer finalFiltering(wr)
+// Requests mixing series of several clusters or replicas, as sent by aggregating
+// agents, are split and the lease of each replica is checked separately.
+func (h *Filter) Process(r *http.Request, wr *prompb.WriteRequest) error {
+	defer h.finalFiltering(wr)
 	tts := wr.Timeseries
 	if len(tts) == 0 {
 		return nil
 	}
 
-	clusterName, replicaName := haLabels(tts[0].Labels)
-
-	if err := validateClusterLabels(clusterName, replicaName); err != nil {
+	first, err := h.replicaOf(tts[0].Labels)
+	if err != nil {
 		return err
 	}
+	mixed := false
+	for i := 1; i < len(tts); i++ {
+		r, err := h.replicaOf(tts[i].Labels)
+		if err != nil {
+			return err
+		}
+		mixed = mixed || r != first
+	}
+	if !mixed {
+		recordReplicas(r, first)
+		return h.filterReplica(wr, first.cluster, first.replica)
+	}
+
+	var replicas []haReplica
+	perReplica := make(map[haReplica]*prompb.WriteRequest)
+	for i := range tts {
+		r, _ := h.replicaOf(tts[i].Labels)
+		sub, ok := perReplica[r]
+		if !ok {
+			sub = &prompb.WriteRequest{}
+			perReplica[r] = sub
+			replicas = append(replicas, r)
+		}
+		sub.Timeseries = append(sub.Timeseries, tts[i])
+	}
+	recordReplicas(r, replicas...)
+	kept := tts[:0]
+	for _, r := range replicas {
+		sub := perReplica[r]
+		if err := h.filterReplica(sub, r.cluster, r.replica); err != nil {
+			return err
+		}
+		kept = append(kept, sub.Timeseries...)
+	}
+	wr.Timeseries = kept
+	return nil
+}
+
+// recordReplicas records the replicas of the request on its source, so that
+// duplicate samples can be attributed to them.
+func recordReplicas(r *http.Request, replicas ...haReplica) {
+	if r == nil {
+		return
+	}
+	source := pgmodel.SourceFromContext(r.Context())
+	if source == nil {
+		return
+	}
+	names := make([]string, len(replicas))
+	for i, replica := range replicas {
+		names[i] = replica.replica
+	}
+	source.SetReplicas(names)
+}
+
+// filterReplica filters the timeseries of a single replica of a cluster.
+func (h *Filter) filterReplica(wr *prompb.WriteRequest, clusterName, replicaName string) error {
+	tts := wr.Timeseries
 
 	// find samples time range
 	minTUnix, maxTUnix := findDataTimeRange(tts)
@@ -148,18 +221,32 @@ func filterOutSampleRange(wr *prompb.WriteRequest, timeStartIncl, timeEndExcl in
 			t.Samples[j] = prompb.Sample{}
 		}
 		t.Samples = t.Samples[:numAccepted]
+
+		numAccepted = 0
+		for j := range t.Histograms {
+			histogram := t.Histograms[j]
+			if histogram.Timestamp >= timeStartIncl && histogram.Timestamp < timeEndExcl {
+				continue
+			}
+			t.Histograms[numAccepted] = histogram
+			numAccepted++
+		}
+		for j := numAccepted; j < len(t.Histograms); j++ {
+			t.Histograms[j] = prompb.Histogram{}
+		}
+		t.Histograms = t.Histograms[:numAccepted]
 	}
 }
 
 // finalFiltering goes through all the `Timeseries` of a `WriteRequest` filtering
-// out any instances without any samples. If the timeseries does contain samples,
-// it filters out the HA replica labels so it won't create different series
-// based on that label value.
-func finalFiltering(wr *prompb.WriteRequest) {
+// out any instances without any samples or histograms. If the timeseries does
+// contain data, it filters out the HA replica labels so it won't create
+// different series based on that label value.
+func (h *Filter) finalFiltering(wr *prompb.WriteRequest) {
 	numAccepted := 0
 	for i := range wr.Timeseries {
 		t := &wr.Timeseries[i]
-		if len(t.Samples) == 0 {
+		if len(t.Samples) == 0 && len(t.Histograms) == 0 {
 			continue
 		}
 		wr.Timeseries[numAccepted] = *t
@@ -168,7 +255,7 @@ func finalFiltering(wr *prompb.WriteRequest) {
 		// we don't want samples from the same Prometheus
 		// HA set to become different series.
 		for ind, value := range t.Labels {
-			if value.Name == ReplicaNameLabel {
+			if value.Name == h.replicaLabel {
 				t.Labels = append(t.Labels[:ind], t.Labels[ind+1:]...)
 				break
 			}
@@ -181,61 +268,72 @@ func finalFiltering(wr *prompb.WriteRequest) {
 }
 
 // findDataTimeRange finds the minimum and maximum timestamps in a set of samples
+// and histograms
 func findDataTimeRange(tts []prompb.TimeSeries) (minTUnix int64, maxTUnix int64) {
 	timesWereSet := false
+	update := func(timestamp int64) {
+		if !timesWereSet {
+			timesWereSet = true
+			minTUnix = timestamp
+			maxTUnix = timestamp
+			return
+		}
+		if timestamp < minTUnix {
+			minTUnix = timestamp
+		}
+		if timestamp > maxTUnix {
+			maxTUnix = timestamp
+		}
+	}
 	for i := range tts {
 		t := &tts[i]
-		if len(t.Samples) == 0 {
-			continue
-		}
-
 		for _, sample := range t.Samples {
-			if !timesWereSet {
-				timesWereSet = true
-				minTUnix = sample.Timestamp
-				maxTUnix = sample.Timestamp
-				continue
-			}
-			if sample.Timestamp < minTUnix {
-				minTUnix = sample.Timestamp
-			}
-
-			if sample.Timestamp > maxTUnix {
-				maxTUnix = sample.Timestamp
-			}
+			update(sample.Timestamp)
+		}
+		for _, histogram := range t.Histograms {
+			update(histogram.Timestamp)
 		}
 	}
 	return minTUnix, maxTUnix
 }
 
-func haLabels(labels []prompb.Label) (cluster, replica string) {
+// replicaOf returns the cluster and replica of a series, which must have both
+// labels.
+func (h *Filter) replicaOf(labels []prompb.Label) (haReplica, error) {
+	var r haReplica
 	for _, label := range labels {
-		if label.Name == ClusterNameLabel {
-			cluster = label.Value
-		} else if label.Name == ReplicaNameLabel {
-			replica = label.Value
+		if label.Name == h.clusterLabel {
+			r.cluster = label.Value
+		} else if label.Name == h.replicaLabel {
+			r.replica = label.Value
 		}
 	}
-	return cluster, replica
+	return r, h.validateClusterLabels(r.cluster, r.replica)
 }
 
-func validateClusterLabels(cluster, replica string) error {
+func (h *Filter) validateClusterLabels(cluster, replica string) error {
 	if cluster == "" && replica == "" {
 		return fmt.Errorf("HA enabled, but both %s and %s labels are empty",
-			ClusterNameLabel,
-			ReplicaNameLabel,
+			h.clusterLabel,
+			h.replicaLabel,
 		)
 	} else if cluster == "" {
 		return fmt.Errorf("HA enabled, but %s label is empty; %s set to: %s",
-			ClusterNameLabel,
-			ReplicaNameLabel,
+			h.clusterLabel,
+			h.replicaLabel,
 			replica,
 		)
 	} else if replica == "" {
 		return fmt.Errorf("HA enabled, but %s label is empty; %s set to: %s",
-			ReplicaNameLabel,
-			ClusterNameLabel,
+			h.replicaLabel,
+			h.clusterLabel,
+			cluster,
+		)
+	} else if strings.HasPrefix(cluster, TraceClusterPrefix) {
+		return fmt.Errorf("HA enabled, but %s label %s uses the prefix %s reserved for trace clusters",
+			h.clusterLabel,
 			cluster,
+			TraceClusterPrefix,
 		)
 	}
 	return nil
@@ -6,6 +6,8 @@ package ha
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 	"time"
@@ -99,6 +101,25 @@ func TestHaParserParseData(t *testing.T) {
 			wantErr:     true,
 			resultError: fmt.Errorf("HA enabled, but cluster label is empty; __replica__ set to: replica1"),
 		},
+		{
+			name: "HA enabled but cluster uses the trace prefix.",
+			args: &prompb.WriteRequest{
+				Timeseries: []prompb.TimeSeries{
+					{
+						Labels: []prompb.Label{
+							{Name: model.MetricNameLabelName, Value: "test"},
+							{Name: ClusterNameLabel, Value: "traces:cluster1"},
+							{Name: ReplicaNameLabel, Value: "replica1"},
+						},
+						Samples: []prompb.Sample{
+							{Timestamp: inLeaseTimestamp, Value: 0.1},
+						},
+					},
+				},
+			},
+			wantErr:     true,
+			resultError: fmt.Errorf("HA enabled, but cluster label traces:cluster1 uses the prefix traces: reserved for trace clusters"),
+		},
 		{
 			name: "HA enabled parse samples from leader prom instance.",
 			args: &prompb.WriteRequest{
@@ -250,6 +271,47 @@ func TestHaParserParseData(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "HA enabled parse histograms from leader & histograms are in interval [leaseStart-X, leaseUntil)",
+			args: &prompb.WriteRequest{
+				Timeseries: []prompb.TimeSeries{
+					{
+						Labels: []prompb.Label{
+							{Name: model.MetricNameLabelName, Value: "test"},
+							{Name: ReplicaNameLabel, Value: "replica1"},
+							{Name: ClusterNameLabel, Value: "cluster3"},
+						},
+						Histograms: []prompb.Histogram{
+							{Timestamp: behindLeaseTimestamp, Sum: 0.1},
+							{Timestamp: inLeaseTimestamp, Sum: 0.2},
+						},
+					},
+				},
+			},
+			wantErr: false,
+			wanted: &prompb.WriteRequest{
+				Timeseries: []prompb.TimeSeries{
+					{
+						Labels: []prompb.Label{
+							{Name: model.MetricNameLabelName, Value: "test"},
+							{Name: ClusterNameLabel, Value: "cluster3"},
+						},
+						Histograms: []prompb.Histogram{
+							{Timestamp: inLeaseTimestamp, Sum: 0.2},
+						},
+					},
+				},
+			},
+			cluster: "cluster3",
+			setClusterStates: []client.LeaseDBState{
+				{
+					Cluster:    "cluster3",
+					Leader:     "replica1",
+					LeaseStart: leaseStart,
+					LeaseUntil: leaseUntil,
+				},
+			},
+		},
 		{
 			name: "HA enabled parse from leader & samples are in interval [leaseStart, leaseUntil+X].",
 			args: &prompb.WriteRequest{
@@ -514,6 +576,14 @@ func TestFilterSamples(t *testing.T) {
 							{Timestamp: 10},
 						},
 					},
+					{
+						Histograms: []prompb.Histogram{
+							{Timestamp: 4},
+							{Timestamp: 5},
+							{Timestamp: 9},
+							{Timestamp: 10},
+						},
+					},
 				},
 			},
 			timeStart: 5,
@@ -549,6 +619,12 @@ func TestFilterSamples(t *testing.T) {
 							{Timestamp: 10},
 						},
 					},
+					{
+						Histograms: []prompb.Histogram{
+							{Timestamp: 4},
+							{Timestamp: 10},
+						},
+					},
 				},
 			},
 		},
@@ -562,3 +638,71 @@ func TestFilterSamples(t *testing.T) {
 	}
 
 }
+
+func TestHaParserMixedClusters(t *testing.T) {
+	leaseStart := time.Unix(1, 0)
+	leaseUntil := leaseStart.Add(2 * time.Second)
+	inLeaseTimestamp := leaseStart.Add(time.Second).UnixNano() / 1000000
+
+	series := func(cluster, replica string) prompb.TimeSeries {
+		return prompb.TimeSeries{
+			Labels: []prompb.Label{
+				{Name: model.MetricNameLabelName, Value: "test"},
+				{Name: "prometheus", Value: cluster},
+				{Name: "prometheus_replica", Value: replica},
+			},
+			Samples: []prompb.Sample{{Timestamp: inLeaseTimestamp, Value: 0.1}},
+		}
+	}
+	kept := func(cluster string) prompb.TimeSeries {
+		return prompb.TimeSeries{
+			Labels: []prompb.Label{
+				{Name: model.MetricNameLabelName, Value: "test"},
+				{Name: "prometheus", Value: cluster},
+			},
+			Samples: []prompb.Sample{{Timestamp: inLeaseTimestamp, Value: 0.1}},
+		}
+	}
+
+	service := MockNewHAService()
+	SetLeaderInMockService(service, []client.LeaseDBState{
+		{Cluster: "cluster1", Leader: "replica1", LeaseStart: leaseStart, LeaseUntil: leaseUntil},
+		{Cluster: "cluster2", Leader: "replica2", LeaseStart: leaseStart, LeaseUntil: leaseUntil},
+	})
+	h := NewFilterWith(service, "prometheus", "prometheus_replica")
+
+	wr := &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{
+			series("cluster1", "replica1"),
+			series("cluster2", "replica1"),
+			series("cluster1", "replica2"),
+			series("cluster2", "replica2"),
+		},
+	}
+	source := &model.Source{}
+	r := httptest.NewRequest(http.MethodPost, "/write", nil)
+	r = r.WithContext(model.NewSourceContext(r.Context(), source))
+	if err := h.Process(r, wr); err != nil {
+		t.Fatal(err)
+	}
+	if source.Replica != "replica1,replica2" {
+		t.Fatalf("unexpected replicas of the source: %s", source.Replica)
+	}
+	wanted := &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{kept("cluster1"), kept("cluster2")},
+	}
+	if !reflect.DeepEqual(wanted, wr) {
+		t.Fatalf("unexpected result from Process:\ngot\n%+v\nwant\n%+v\n", wr, wanted)
+	}
+
+	wr = &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{
+			series("cluster1", "replica1"),
+			{Labels: []prompb.Label{{Name: "prometheus", Value: "cluster2"}}},
+		},
+	}
+	err := h.Process(nil, wr)
+	if err == nil || err.Error() != "HA enabled, but prometheus_replica label is empty; prometheus set to: cluster2" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
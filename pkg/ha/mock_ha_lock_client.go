@@ -7,6 +7,7 @@ package ha
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/timescale/promscale/pkg/ha/client"
@@ -14,9 +15,15 @@ import (
 
 type mockLockClient struct {
 	leadersPerCluster map[string][]client.LeaseDBState
+	pins              map[string]string
+	// err is returned by the lease updates and checks, if set.
+	err error
 }
 
 func (m *mockLockClient) GetPastLeaseInfo(ctx context.Context, cluster string, replica string, start time.Time, end time.Time) (client.LeaseDBState, error) {
+	if m.err != nil {
+		return client.LeaseDBState{}, m.err
+	}
 	locks, exists := m.leadersPerCluster[cluster]
 	if !exists {
 		return client.LeaseDBState{}, client.ErrNoPastLease
@@ -34,6 +41,9 @@ func (m *mockLockClient) GetPastLeaseInfo(ctx context.Context, cluster string, r
 }
 
 func (m *mockLockClient) UpdateLease(_ context.Context, cluster, leader string, minTime, maxTime time.Time) (client.LeaseDBState, error) {
+	if m.err != nil {
+		return client.LeaseDBState{}, m.err
+	}
 	lock, exists := m.leadersPerCluster[cluster]
 	if !exists {
 		lock := client.LeaseDBState{
@@ -49,6 +59,9 @@ func (m *mockLockClient) UpdateLease(_ context.Context, cluster, leader string,
 }
 
 func (m *mockLockClient) TryChangeLeader(_ context.Context, cluster, newLeader string, maxTime time.Time) (client.LeaseDBState, error) {
+	if m.err != nil {
+		return client.LeaseDBState{}, m.err
+	}
 	locks, exists := m.leadersPerCluster[cluster]
 	if !exists {
 		return client.LeaseDBState{}, fmt.Errorf("no leader for %s, UpdateLease never called before TryChangeLeader", cluster)
@@ -63,6 +76,44 @@ func (m *mockLockClient) TryChangeLeader(_ context.Context, cluster, newLeader s
 	return lock, nil
 }
 
+func (m *mockLockClient) GetLease(_ context.Context, cluster string) (client.LeaseDBState, error) {
+	locks, exists := m.leadersPerCluster[cluster]
+	if !exists {
+		return client.LeaseDBState{Cluster: cluster}, client.ErrNoLease
+	}
+	return locks[len(locks)-1], nil
+}
+
+func (m *mockLockClient) GetLeases(_ context.Context) ([]client.LeaseDBState, error) {
+	leases := make([]client.LeaseDBState, 0, len(m.leadersPerCluster))
+	for _, locks := range m.leadersPerCluster {
+		leases = append(leases, locks[len(locks)-1])
+	}
+	sort.Slice(leases, func(i, j int) bool { return leases[i].Cluster < leases[j].Cluster })
+	return leases, nil
+}
+
+func (m *mockLockClient) GetLeaderPins(_ context.Context) (map[string]string, error) {
+	pins := make(map[string]string, len(m.pins))
+	for cluster, leader := range m.pins {
+		pins[cluster] = leader
+	}
+	return pins, nil
+}
+
+func (m *mockLockClient) PinLeader(_ context.Context, cluster, leader string) error {
+	m.pins[cluster] = leader
+	return nil
+}
+
+func (m *mockLockClient) UnpinLeader(_ context.Context, cluster string) error {
+	delete(m.pins, cluster)
+	return nil
+}
+
 func newMockLockClient() *mockLockClient {
-	return &mockLockClient{leadersPerCluster: make(map[string][]client.LeaseDBState)}
+	return &mockLockClient{
+		leadersPerCluster: make(map[string][]client.LeaseDBState),
+		pins:              make(map[string]string),
+	}
 }
@@ -16,6 +16,7 @@ func MockNewHAService() *Service {
 
 	service := &Service{
 		state:               &sync.Map{},
+		pins:                &sync.Map{},
 		leaseClient:         lockClient,
 		currentTimeProvider: time.Now,
 	}
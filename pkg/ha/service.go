@@ -10,6 +10,8 @@ import (
 	"sync"
 	"time"
 
+	"go.uber.org/atomic"
+
 	"github.com/timescale/promscale/pkg/ha/client"
 	"github.com/timescale/promscale/pkg/ha/state"
 	"github.com/timescale/promscale/pkg/log"
@@ -30,11 +32,13 @@ var ErrNoLeasesInRange = fmt.Errorf("no valid leases in range found")
 // up to date by periodically refreshing it from the database.
 type Service struct {
 	state               *sync.Map
+	pins                *sync.Map // pinned leader of each pinned cluster
 	leaseClient         client.LeaseClient
 	syncTicker          util.Ticker
 	currentTimeProvider func() time.Time
 	doneChannel         chan bool
 	doneWG              sync.WaitGroup
+	cachedLeaderOnError atomic.Bool
 }
 
 // NewService constructs a new HA lease service with the supplied
@@ -54,6 +58,7 @@ func NewServiceWith(leaseClient client.LeaseClient, ticker util.Ticker, currentT
 
 	service := &Service{
 		state:               &sync.Map{},
+		pins:                &sync.Map{},
 		leaseClient:         leaseClient,
 		syncTicker:          ticker,
 		currentTimeProvider: currentTimeFn,
@@ -68,12 +73,14 @@ func NewServiceWith(leaseClient client.LeaseClient, ticker util.Ticker, currentT
 // lease states with latest values from the database and initiates
 // a leader change if the conditions are met.
 func (s *Service) haStateSyncer() {
+	s.syncPins()
 	for {
 		select {
 		case <-s.doneChannel:
 			s.doneWG.Done()
 			break
 		case <-s.syncTicker.Channel():
+			s.syncPins()
 			s.state.Range(func(c, l interface{}) bool {
 				cluster := fmt.Sprint(c)
 				lease := l.(*state.Lease)
@@ -107,7 +114,20 @@ func (s *Service) CheckLease(minT, maxT time.Time, clusterName, replicaName stri
 		return false, time.Time{}, err
 	}
 
-	return lease.ValidateSamplesInfo(replicaName, minT, maxT, s.currentTimeProvider())
+	allowInsert, acceptedMinT, err = lease.ValidateSamplesInfo(replicaName, minT, maxT, s.currentTimeProvider())
+	if err != nil && s.cachedLeaderOnError.Load() {
+		snapshot := lease.Snapshot()
+		log.WarnRateLimited("msg", "Could not check HA lease, falling back to the cached leader", "cluster", clusterName, "leader", snapshot.Leader, "err", err)
+		return snapshot.Leader == replicaName, snapshot.LeaseStart, nil
+	}
+	return allowInsert, acceptedMinT, err
+}
+
+// FallBackToCachedLeader makes the lease checks which fail, e.g. because the
+// database is unreachable while writes are spooled, accept the samples of the
+// cached leader of the cluster, and drop the others, instead of failing.
+func (s *Service) FallBackToCachedLeader() {
+	s.cachedLeaderOnError.Store(true)
 }
 
 func (s *Service) Close() {
@@ -127,6 +147,9 @@ func (s *Service) getLocalClusterLease(clusterName, replicaName string, minT, ma
 	if err != nil {
 		return nil, err
 	}
+	if pinned, ok := s.pins.Load(clusterName); ok {
+		newLease.SetPinnedLeader(pinned.(string))
+	}
 	l, _ = s.state.LoadOrStore(clusterName, newLease)
 	newLease = l.(*state.Lease)
 	return newLease, nil
@@ -134,7 +157,12 @@ func (s *Service) getLocalClusterLease(clusterName, replicaName string, minT, ma
 
 func (s *Service) GetBackfillLeaseRange(start, end time.Time, cluster string, replica string) (time.Time, time.Time, error) {
 	state, err := s.leaseClient.GetPastLeaseInfo(context.Background(), cluster, replica, start, end)
-	if err == client.ErrNoPastLease {
+	switch {
+	case err == client.ErrNoPastLease:
+		err = ErrNoLeasesInRange
+	case err != nil && s.cachedLeaderOnError.Load():
+		// Past leases are not cached, hence the backfill is dropped.
+		log.WarnRateLimited("msg", "Could not check past HA leases, dropping backfill", "cluster", cluster, "replica", replica, "err", err)
 		err = ErrNoLeasesInRange
 	}
 	return state.LeaseStart, state.LeaseUntil, err
@@ -0,0 +1,45 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package ha
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/timescale/promscale/pkg/ha/client"
+)
+
+func TestServiceFallBackToCachedLeader(t *testing.T) {
+	leaseStart := time.Unix(100, 0)
+	leaseUntil := leaseStart.Add(10 * time.Second)
+	service := MockNewHAService()
+	SetLeaderInMockService(service, []client.LeaseDBState{
+		{Cluster: "cluster1", Leader: "replica1", LeaseStart: leaseStart, LeaseUntil: leaseUntil},
+	})
+	if _, _, err := service.CheckLease(leaseStart, leaseStart, "cluster1", "replica1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The database becomes unreachable while the lease has to be extended.
+	service.leaseClient.(*mockLockClient).err = fmt.Errorf("connection refused")
+	afterLease := leaseUntil.Add(time.Minute)
+	if _, _, err := service.CheckLease(afterLease, afterLease, "cluster1", "replica1"); err == nil {
+		t.Fatalf("expected an error without the fallback")
+	}
+
+	service.FallBackToCachedLeader()
+	allowInsert, start, err := service.CheckLease(afterLease, afterLease, "cluster1", "replica1")
+	if err != nil || !allowInsert || !start.Equal(leaseStart) {
+		t.Fatalf("cached leader rejected: %v, %s, %v", allowInsert, start, err)
+	}
+	allowInsert, _, err = service.CheckLease(afterLease, afterLease, "cluster1", "replica2")
+	if err != nil || allowInsert {
+		t.Fatalf("standby accepted: %v, %v", allowInsert, err)
+	}
+	if _, _, err = service.GetBackfillLeaseRange(leaseStart, afterLease, "cluster1", "replica2"); err != ErrNoLeasesInRange {
+		t.Fatalf("unexpected backfill error: %v", err)
+	}
+}
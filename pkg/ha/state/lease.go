@@ -29,10 +29,24 @@ type Lease struct {
 	MaxTimeInstance       string    // the replica name that’s seen the maxtime
 	MaxTimeSeenLeader     time.Time // max data time seen by current leader
 	RecentLeaderWriteTime time.Time // real time when leader last wrote data
+	pinnedLeader          string    // leader pinned by an operator, if any
 
 	client client.LeaseClient
 }
 
+// Snapshot is a copy of the state of a lease at a given time.
+type Snapshot struct {
+	Cluster               string    `json:"cluster"`
+	Leader                string    `json:"leader"`
+	LeaseStart            time.Time `json:"lease_start"`
+	LeaseUntil            time.Time `json:"lease_until"`
+	MaxTimeSeen           time.Time `json:"max_time_seen"`
+	MaxTimeInstance       string    `json:"max_time_instance"`
+	MaxTimeSeenLeader     time.Time `json:"max_time_seen_leader"`
+	RecentLeaderWriteTime time.Time `json:"recent_leader_write_time"`
+	PinnedLeader          string    `json:"pinned_leader,omitempty"`
+}
+
 // Creates a new Lease and immediately synchronizes with the database, it either
 //	- sets the potentialLeader as the leader for the cluster with a lease
 //	  for the requested minT and maxT
@@ -133,8 +147,14 @@ func (l *Lease) TryChangeLeader(currT time.Time) error {
 	maxTimeInstance := l.MaxTimeInstance
 	maxTimeSeen := l.MaxTimeSeen
 	recentLeaderSeen := l.RecentLeaderWriteTime
+	pinned := l.pinnedLeader != ""
 	l._mu.RUnlock()
 
+	// Pinned leaders are only changed by an operator.
+	if pinned {
+		return nil
+	}
+
 	// Only proceed if we have seen samples after the lease expired.
 	if leaseUntil.After(maxTimeSeen) {
 		return nil
@@ -156,6 +176,37 @@ func (l *Lease) TryChangeLeader(currT time.Time) error {
 	return nil
 }
 
+// SetState replaces the state of the lease with the one read from the database,
+// e.g. after the leader was changed by an operator.
+func (l *Lease) SetState(stateFromDB client.LeaseDBState) {
+	l.setUpdateFromDB(stateFromDB)
+}
+
+// SetPinnedLeader pins the leader of the lease, which disables automatic leader
+// changes. An empty leader unpins it.
+func (l *Lease) SetPinnedLeader(leader string) {
+	l._mu.Lock()
+	defer l._mu.Unlock()
+	l.pinnedLeader = leader
+}
+
+// Snapshot returns a copy of the state of the lease.
+func (l *Lease) Snapshot() Snapshot {
+	l._mu.RLock()
+	defer l._mu.RUnlock()
+	return Snapshot{
+		Cluster:               l.state.Cluster,
+		Leader:                l.state.Leader,
+		LeaseStart:            l.state.LeaseStart,
+		LeaseUntil:            l.state.LeaseUntil,
+		MaxTimeSeen:           l.MaxTimeSeen,
+		MaxTimeInstance:       l.MaxTimeInstance,
+		MaxTimeSeenLeader:     l.MaxTimeSeenLeader,
+		RecentLeaderWriteTime: l.RecentLeaderWriteTime,
+		PinnedLeader:          l.pinnedLeader,
+	}
+}
+
 func (l *Lease) changeLeader(cluster string, leader string, maxTimeSeen time.Time) error {
 	stateFromDB, err := l.client.TryChangeLeader(
 		context.Background(), cluster, leader, maxTimeSeen,
@@ -0,0 +1,182 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package ha
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// TraceClusterPrefix is prepended to the names of trace clusters in the leases
+// table, so that their leases are kept apart from the ones of Prometheus clusters
+// of the same name.
+const TraceClusterPrefix = "traces:"
+
+// TraceFilter is a HA filter for traces. Replicas of a cluster, e.g. a pair of
+// OpenTelemetry collectors, are identified by a pair of resource attributes, and
+// only the spans of the replica holding the lease of the cluster are kept.
+type TraceFilter struct {
+	service          *Service
+	clusterAttribute string
+	replicaAttribute string
+}
+
+// NewTraceFilter creates a new TraceFilter based on the provided Service and
+// resource attributes.
+func NewTraceFilter(service *Service, clusterAttribute, replicaAttribute string) *TraceFilter {
+	return &TraceFilter{
+		service:          service,
+		clusterAttribute: clusterAttribute,
+		replicaAttribute: replicaAttribute,
+	}
+}
+
+type haReplica struct {
+	cluster, replica string
+}
+
+// timeRange is a range of data time, with an exclusive end. A zero end means the
+// range is unbounded.
+type timeRange struct {
+	start, end time.Time
+}
+
+func (r timeRange) contains(t time.Time) bool {
+	return !t.Before(r.start) && (r.end.IsZero() || t.Before(r.end))
+}
+
+// ProcessTraces drops the spans of replicas that do not hold the lease of their
+// cluster at the start time of the spans. Resources without the cluster and
+// replica attributes are not filtered. The replica attribute is removed from the
+// kept resources, so that the spans of all replicas belong to the same resource.
+func (f *TraceFilter) ProcessTraces(_ context.Context, traces ptrace.Traces) error {
+	resourceSpans := traces.ResourceSpans()
+	timeRanges := make(map[haReplica]timeRange)
+	for i := 0; i < resourceSpans.Len(); i++ {
+		rs := resourceSpans.At(i)
+		r, ok, err := f.replicaOf(rs.Resource().Attributes())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		tr, seen := timeRanges[r]
+		forEachSpan(rs, func(span ptrace.Span) {
+			t := span.StartTimestamp().AsTime()
+			if !seen {
+				tr, seen = timeRange{start: t, end: t}, true
+				return
+			}
+			if t.Before(tr.start) {
+				tr.start = t
+			}
+			if t.After(tr.end) {
+				tr.end = t
+			}
+		})
+		if seen {
+			timeRanges[r] = tr
+		}
+	}
+
+	keep := make(map[haReplica][]timeRange, len(timeRanges))
+	for r, tr := range timeRanges {
+		ranges, err := f.leaseRanges(r, tr.start, tr.end)
+		if err != nil {
+			return err
+		}
+		keep[r] = ranges
+	}
+
+	resourceSpans.RemoveIf(func(rs ptrace.ResourceSpans) bool {
+		attrs := rs.Resource().Attributes()
+		r, ok, _ := f.replicaOf(attrs)
+		if !ok {
+			return false
+		}
+		ranges := keep[r]
+		rs.ScopeSpans().RemoveIf(func(ss ptrace.ScopeSpans) bool {
+			ss.Spans().RemoveIf(func(span ptrace.Span) bool {
+				t := span.StartTimestamp().AsTime()
+				for _, tr := range ranges {
+					if tr.contains(t) {
+						return false
+					}
+				}
+				return true
+			})
+			return ss.Spans().Len() == 0
+		})
+		if rs.ScopeSpans().Len() == 0 {
+			return true
+		}
+		attrs.Remove(f.replicaAttribute)
+		return false
+	})
+	return nil
+}
+
+// replicaOf returns the cluster and replica of a resource, and false if the
+// resource has neither of them.
+func (f *TraceFilter) replicaOf(attrs pcommon.Map) (haReplica, bool, error) {
+	var r haReplica
+	if v, ok := attrs.Get(f.clusterAttribute); ok {
+		r.cluster = v.AsString()
+	}
+	if v, ok := attrs.Get(f.replicaAttribute); ok {
+		r.replica = v.AsString()
+	}
+	switch {
+	case r.cluster == "" && r.replica == "":
+		return r, false, nil
+	case r.cluster == "":
+		return r, false, fmt.Errorf("HA enabled, but %s resource attribute is empty; %s set to: %s", f.clusterAttribute, f.replicaAttribute, r.replica)
+	case r.replica == "":
+		return r, false, fmt.Errorf("HA enabled, but %s resource attribute is empty; %s set to: %s", f.replicaAttribute, f.clusterAttribute, r.cluster)
+	}
+	return r, true, nil
+}
+
+// leaseRanges returns the time ranges, between minT and maxT, in which the replica
+// holds or held the lease of its cluster.
+func (f *TraceFilter) leaseRanges(r haReplica, minT, maxT time.Time) ([]timeRange, error) {
+	cluster := TraceClusterPrefix + r.cluster
+	allowInsert, leaseStart, err := f.service.CheckLease(minT, maxT, cluster, r.replica)
+	if err != nil {
+		return nil, fmt.Errorf("could not check ha lease: %#v", err)
+	}
+	var ranges []timeRange
+	if allowInsert {
+		ranges = append(ranges, timeRange{start: leaseStart})
+	}
+	backfillStart := minT
+	for backfillStart.Before(leaseStart) {
+		keepRangeStart, keepRangeEnd, err := f.service.GetBackfillLeaseRange(backfillStart, leaseStart, cluster, r.replica)
+		if err != nil {
+			if err == ErrNoLeasesInRange {
+				break
+			}
+			return nil, fmt.Errorf("could not check backfill ha lease: %#v", err)
+		}
+		ranges = append(ranges, timeRange{start: keepRangeStart, end: keepRangeEnd})
+		backfillStart = keepRangeEnd
+	}
+	return ranges, nil
+}
+
+func forEachSpan(rs ptrace.ResourceSpans, f func(ptrace.Span)) {
+	scopeSpans := rs.ScopeSpans()
+	for i := 0; i < scopeSpans.Len(); i++ {
+		spans := scopeSpans.At(i).Spans()
+		for j := 0; j < spans.Len(); j++ {
+			f(spans.At(j))
+		}
+	}
+}
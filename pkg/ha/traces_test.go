@@ -0,0 +1,110 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package ha
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/timescale/promscale/pkg/ha/client"
+)
+
+func addResourceSpans(traces ptrace.Traces, attrs map[string]string, starts ...time.Time) {
+	rs := traces.ResourceSpans().AppendEmpty()
+	for k, v := range attrs {
+		rs.Resource().Attributes().PutString(k, v)
+	}
+	spans := rs.ScopeSpans().AppendEmpty().Spans()
+	for _, start := range starts {
+		spans.AppendEmpty().SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	}
+}
+
+func TestTraceFilterProcessTraces(t *testing.T) {
+	leaseStart := time.Unix(100, 0)
+	leaseUntil := leaseStart.Add(10 * time.Second)
+	inLease := leaseStart.Add(time.Second)
+
+	tests := []struct {
+		name      string
+		attrs     map[string]string
+		starts    []time.Time
+		wantSpans int
+		wantErr   bool
+	}{
+		{
+			name:      "no HA attributes",
+			attrs:     map[string]string{"service.name": "foo"},
+			starts:    []time.Time{inLease},
+			wantSpans: 1,
+		},
+		{
+			name:    "replica attribute missing",
+			attrs:   map[string]string{ClusterNameLabel: "cluster1"},
+			starts:  []time.Time{inLease},
+			wantErr: true,
+		},
+		{
+			name:    "cluster attribute missing",
+			attrs:   map[string]string{ReplicaNameLabel: "replica1"},
+			starts:  []time.Time{inLease},
+			wantErr: true,
+		},
+		{
+			name:      "leader",
+			attrs:     map[string]string{ClusterNameLabel: "cluster1", ReplicaNameLabel: "replica1"},
+			starts:    []time.Time{inLease, inLease.Add(time.Second)},
+			wantSpans: 2,
+		},
+		{
+			name:      "standby",
+			attrs:     map[string]string{ClusterNameLabel: "cluster1", ReplicaNameLabel: "replica2"},
+			starts:    []time.Time{inLease},
+			wantSpans: 0,
+		},
+		{
+			name:      "leader spans before lease",
+			attrs:     map[string]string{ClusterNameLabel: "cluster1", ReplicaNameLabel: "replica1"},
+			starts:    []time.Time{leaseStart.Add(-time.Second), inLease},
+			wantSpans: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := MockNewHAService()
+			SetLeaderInMockService(service, []client.LeaseDBState{
+				{Cluster: "cluster1", Leader: "replica2", LeaseStart: leaseStart, LeaseUntil: leaseUntil},
+				{Cluster: TraceClusterPrefix + "cluster1", Leader: "replica1", LeaseStart: leaseStart, LeaseUntil: leaseUntil},
+			})
+			filter := NewTraceFilter(service, ClusterNameLabel, ReplicaNameLabel)
+
+			traces := ptrace.NewTraces()
+			addResourceSpans(traces, tt.attrs, tt.starts...)
+			err := filter.ProcessTraces(context.Background(), traces)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ProcessTraces() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := traces.SpanCount(); got != tt.wantSpans {
+				t.Fatalf("unexpected span count: got %d wanted %d", got, tt.wantSpans)
+			}
+			if tt.wantSpans == 0 {
+				if traces.ResourceSpans().Len() != 0 {
+					t.Fatalf("empty resource spans were not removed")
+				}
+				return
+			}
+			if _, ok := traces.ResourceSpans().At(0).Resource().Attributes().Get(ReplicaNameLabel); ok {
+				t.Fatalf("replica attribute was not removed")
+			}
+		})
+	}
+}
@@ -2,7 +2,10 @@ package store
 
 import (
 	"flag"
+	"fmt"
 	"time"
+
+	"github.com/timescale/promscale/pkg/ha"
 )
 
 const (
@@ -12,6 +15,10 @@ const (
 type Config struct {
 	MaxTraceDuration    time.Duration
 	StreamingSpanWriter bool
+
+	HighAvailability   bool
+	HAClusterAttribute string
+	HAReplicaAttribute string
 }
 
 var DefaultConfig = Config{
@@ -22,9 +29,20 @@ var DefaultConfig = Config{
 func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
 	fs.DurationVar(&cfg.MaxTraceDuration, "tracing.max-trace-duration", DefaultMaxTraceDuration, "Maximum duration of any trace in the system. This parameter is used to optimize queries.")
 	fs.BoolVar(&cfg.StreamingSpanWriter, "tracing.streaming-span-writer", true, "StreamingSpanWriter for remote Jaeger grpc store.")
+	fs.BoolVar(&cfg.HighAvailability, "tracing.high-availability", false, "Enable lease based HA for traces. Only the spans of the replica holding the lease of their cluster are ingested.")
+	fs.StringVar(&cfg.HAClusterAttribute, "tracing.high-availability.cluster-attribute", ha.ClusterNameLabel, "Resource attribute naming the HA cluster of spans.")
+	fs.StringVar(&cfg.HAReplicaAttribute, "tracing.high-availability.replica-attribute", ha.ReplicaNameLabel, "Resource attribute naming the HA replica of spans. It is removed from the ingested spans.")
 	return cfg
 }
 
 func Validate(cfg *Config) error {
+	if cfg.HighAvailability {
+		if cfg.HAClusterAttribute == "" || cfg.HAReplicaAttribute == "" {
+			return fmt.Errorf("tracing HA cluster and replica attributes must not be empty")
+		}
+		if cfg.HAClusterAttribute == cfg.HAReplicaAttribute {
+			return fmt.Errorf("tracing HA cluster and replica attributes must be different")
+		}
+	}
 	return nil
 }
@@ -12,9 +12,10 @@ import (
 	"github.com/jaegertracing/jaeger/model"
 	"github.com/jaegertracing/jaeger/storage/spanstore"
 	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
 )
 
-func findTraceIDs(ctx context.Context, builder *Builder, conn pgxconn.PgxConn, q *spanstore.TraceQueryParameters) ([]model.TraceID, error) {
+func findTraceIDs(ctx context.Context, builder *Builder, conn pgxconn.PgxConn, q *spanstore.TraceQueryParameters, filter tenancy.ReadFilter) ([]model.TraceID, error) {
 	tInfo, err := FindTagInfo(ctx, q, conn)
 	if err != nil {
 		return nil, fmt.Errorf("querying trace tags error: %w", err)
@@ -23,6 +24,7 @@ func findTraceIDs(ctx context.Context, builder *Builder, conn pgxconn.PgxConn, q
 		//tags cannot be matched
 		return []model.TraceID{}, nil
 	}
+	tInfo.restrictTenants(filter)
 	query, params := builder.findTraceIDsQuery(q, tInfo)
 	rows, err := conn.Query(ctx, query, params...)
 	if err != nil {
@@ -11,10 +11,11 @@ import (
 	"github.com/jaegertracing/jaeger/model"
 	"github.com/jaegertracing/jaeger/storage/spanstore"
 	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 )
 
-func findTraces(ctx context.Context, builder *Builder, conn pgxconn.PgxConn, q *spanstore.TraceQueryParameters) ([]*model.Trace, error) {
+func findTraces(ctx context.Context, builder *Builder, conn pgxconn.PgxConn, q *spanstore.TraceQueryParameters, filter tenancy.ReadFilter) ([]*model.Trace, error) {
 	tInfo, err := FindTagInfo(ctx, q, conn)
 	if err != nil {
 		return nil, fmt.Errorf("querying trace tags error: %w", err)
@@ -23,7 +24,8 @@ func findTraces(ctx context.Context, builder *Builder, conn pgxconn.PgxConn, q *
 		//tags cannot be matched
 		return []*model.Trace{}, nil
 	}
-	query, params := builder.findTracesQuery(q, tInfo)
+	spanClause := tInfo.restrictTenants(filter)
+	query, params := builder.findTracesQuery(q, tInfo, spanClause)
 	rows, err := conn.Query(ctx, query, params...)
 	if err != nil {
 		return nil, fmt.Errorf("querying traces error: %w query:\n%s", err, query)
@@ -11,6 +11,7 @@ import (
 
 	"github.com/jaegertracing/jaeger/model"
 	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
 )
 
 //note the key='service.name' is there only for constraint exclusion of partitions
@@ -25,8 +26,37 @@ INNER JOIN _ps_trace.operation parent_op ON (ops.parent_operation_id = parent_op
 WHERE parent_op.service_name_id != child_op.service_name_id
 GROUP BY parent_op.service_name_id,child_op.service_name_id`
 
+// getDependenciesTenantSQLFormat is getDependenciesSQL where the calls are
+// restricted to the spans matching the tenant qualifiers of the child and parent
+// spans. The calls are aggregated inline, as ps_trace.operation_calls cannot be
+// filtered by the tags of the spans.
+const getDependenciesTenantSQLFormat = `
+SELECT
+   (SELECT value #>> '{}' FROM _ps_trace.tag WHERE id = parent_op.service_name_id AND key='service.name') as parent_service,
+   (SELECT value #>> '{}' FROM _ps_trace.tag WHERE id = child_op.service_name_id AND key='service.name') as child_service,
+   sum(ops.cnt) as cnt
+FROM (
+	SELECT
+		parent.operation_id as parent_operation_id,
+		child.operation_id as child_operation_id,
+		count(*) as cnt
+	FROM
+		_ps_trace.span child
+	INNER JOIN
+		_ps_trace.span parent ON (parent.span_id = child.parent_span_id AND parent.trace_id = child.trace_id)
+	WHERE
+		child.start_time > $1 AND child.start_time < $2 AND
+		parent.start_time > $1 AND parent.start_time < $2 AND
+		%s AND %s
+	GROUP BY parent.operation_id, child.operation_id
+) ops
+INNER JOIN _ps_trace.operation child_op ON (ops.child_operation_id = child_op.id)
+INNER JOIN _ps_trace.operation parent_op ON (ops.parent_operation_id = parent_op.id)
+WHERE parent_op.service_name_id != child_op.service_name_id
+GROUP BY parent_op.service_name_id,child_op.service_name_id`
+
 // getDependencies returns the inter service dependencies along with a count of how many times the parent service called the child service.
-func getDependencies(ctx context.Context, conn pgxconn.PgxConn, endTs time.Time, lookback time.Duration) ([]model.DependencyLink, error) {
+func getDependencies(ctx context.Context, conn pgxconn.PgxConn, endTs time.Time, lookback time.Duration, filter tenancy.ReadFilter) ([]model.DependencyLink, error) {
 	startTs := endTs.Add(-1 * lookback)
 
 	var (
@@ -35,7 +65,15 @@ func getDependencies(ctx context.Context, conn pgxconn.PgxConn, endTs time.Time,
 		cnt            uint64
 	)
 
-	rows, err := conn.Query(ctx, getDependenciesSQL, startTs, endTs)
+	sqlQuery, args := getDependenciesSQL, []interface{}{startTs, endTs}
+	if filter.Restricted() {
+		var childQual, parentQual string
+		childQual, args = tenantClause("child", filter, args)
+		parentQual, args = tenantClause("parent", filter, args)
+		sqlQuery = fmt.Sprintf(getDependenciesTenantSQLFormat, childQual, parentQual)
+	}
+
+	rows, err := conn.Query(ctx, sqlQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("fetching dependencies: %w", err)
 	}
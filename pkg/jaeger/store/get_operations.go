@@ -11,6 +11,7 @@ import (
 	"github.com/jackc/pgtype"
 	"github.com/jaegertracing/jaeger/storage/spanstore"
 	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
 )
 
 const (
@@ -29,10 +30,14 @@ WHERE
 		AND _prom_ext.jsonb_digest(value) = _prom_ext.jsonb_digest(to_jsonb($1::text))
 	)
 	AND %s
+	AND %s
 `
+	// tenantOperationQualFormat only matches the operations of the spans matching
+	// the tenant qualifier.
+	tenantOperationQualFormat = `EXISTS (SELECT 1 FROM _ps_trace.span s WHERE s.operation_id = o.id AND %s)`
 )
 
-func getOperations(ctx context.Context, conn pgxconn.PgxConn, query spanstore.OperationQueryParameters) ([]spanstore.Operation, error) {
+func getOperations(ctx context.Context, conn pgxconn.PgxConn, query spanstore.OperationQueryParameters, filter tenancy.ReadFilter) ([]spanstore.Operation, error) {
 	var (
 		pgOperationNames, pgSpanKinds pgtype.TextArray
 		operationsResp                []spanstore.Operation
@@ -49,7 +54,14 @@ func getOperations(ctx context.Context, conn pgxconn.PgxConn, query spanstore.Op
 		kindQual = "o.span_kind = $2"
 	}
 
-	sqlQuery := fmt.Sprintf(getOperationsSQLFormat, kindQual)
+	tenantQual := "TRUE"
+	if filter.Restricted() {
+		var spanQual string
+		spanQual, args = tenantClause("s", filter, args)
+		tenantQual = fmt.Sprintf(tenantOperationQualFormat, spanQual)
+	}
+
+	sqlQuery := fmt.Sprintf(getOperationsSQLFormat, kindQual, tenantQual)
 
 	if err := conn.QueryRow(ctx, sqlQuery, args...).Scan(&pgOperationNames, &pgSpanKinds); err != nil {
 		return operationsResp, fmt.Errorf("fetching operations: %w", err)
@@ -12,6 +12,7 @@ import (
 	"github.com/jackc/pgx/v4"
 	"github.com/pkg/errors"
 	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
 )
 
 const getServicesSQL = `
@@ -22,9 +23,34 @@ FROM
 WHERE
          key='service.name' and value IS NOT NULL`
 
-func getServices(ctx context.Context, conn pgxconn.PgxConn) ([]string, error) {
-	var pgServices pgtype.TextArray
-	if err := conn.QueryRow(ctx, getServicesSQL).Scan(&pgServices); err != nil {
+// getServicesTenantSQLFormat only returns the services of the spans matching the
+// tenant qualifier.
+const getServicesTenantSQLFormat = `
+SELECT
+ 	array_agg(t.value#>>'{}' ORDER BY t.value)
+FROM
+	_ps_trace.tag t
+WHERE
+         t.key='service.name' and t.value IS NOT NULL
+	AND EXISTS (
+		SELECT 1
+		FROM _ps_trace.operation o
+		INNER JOIN _ps_trace.span s ON (s.operation_id = o.id)
+		WHERE o.service_name_id = t.id AND %s
+	)`
+
+func getServices(ctx context.Context, conn pgxconn.PgxConn, filter tenancy.ReadFilter) ([]string, error) {
+	var (
+		pgServices pgtype.TextArray
+		sqlQuery   = getServicesSQL
+		args       []interface{}
+	)
+	if filter.Restricted() {
+		var tenantQual string
+		tenantQual, args = tenantClause("s", filter, args)
+		sqlQuery = fmt.Sprintf(getServicesTenantSQLFormat, tenantQual)
+	}
+	if err := conn.QueryRow(ctx, sqlQuery, args...).Scan(&pgServices); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return []string{}, nil
 		}
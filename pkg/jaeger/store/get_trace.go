@@ -11,10 +11,11 @@ import (
 	"github.com/jaegertracing/jaeger/model"
 	"github.com/jaegertracing/jaeger/storage/spanstore"
 	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
 )
 
-func getTrace(ctx context.Context, builder *Builder, conn pgxconn.PgxConn, traceID model.TraceID) (*model.Trace, error) {
-	query, params, err := builder.getTraceQuery(traceID)
+func getTrace(ctx context.Context, builder *Builder, conn pgxconn.PgxConn, traceID model.TraceID, filter tenancy.ReadFilter) (*model.Trace, error) {
+	query, params, err := builder.getTraceQuery(traceID, filter)
 	if err != nil {
 		return nil, fmt.Errorf("get trace query: %w", err)
 	}
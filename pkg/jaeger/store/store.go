@@ -18,16 +18,23 @@ import (
 	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
 	"github.com/timescale/promscale/pkg/pgmodel/metrics"
 	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
 )
 
 type Store struct {
 	conn     pgxconn.PgxConn
 	inserter ingestor.DBInserter
 	builder  *Builder
+	// nil when multi-tenancy is disabled.
+	tenancy    tenancy.TraceAuthorizer
+	processors []ingestor.TraceProcessor
 }
 
-func New(conn pgxconn.PgxConn, inserter ingestor.DBInserter, cfg *Config) *Store {
-	return &Store{conn, inserter, NewBuilder(cfg)}
+// New returns a new Store. The spans are written and read according to the
+// trace authorizer, which is nil when multi-tenancy is disabled. Written spans
+// go through the processors before being authorized.
+func New(conn pgxconn.PgxConn, inserter ingestor.DBInserter, cfg *Config, traceAuthorizer tenancy.TraceAuthorizer, processors ...ingestor.TraceProcessor) *Store {
+	return &Store{conn, inserter, NewBuilder(cfg), traceAuthorizer, processors}
 }
 
 func (p *Store) SpanReader() spanstore.Reader {
@@ -51,6 +58,19 @@ func (p *Store) WriteSpan(ctx context.Context, span *model.Span) error {
 	if err != nil {
 		return err
 	}
+	for _, processor := range p.processors {
+		if err = processor.ProcessTraces(ctx, traces); err != nil {
+			return err
+		}
+	}
+	if traces.SpanCount() == 0 {
+		return nil
+	}
+	if p.tenancy != nil {
+		if err = p.tenancy.ProcessTraces(ctx, traces); err != nil {
+			return err
+		}
+	}
 	return p.inserter.IngestTraces(ctx, traces)
 }
 
@@ -68,7 +88,7 @@ func (p *Store) GetTrace(ctx context.Context, traceID model.TraceID) (*model.Tra
 		metrics.Query.With(prometheus.Labels{"type": "trace", "handler": "Get_Trace", "code": code}).Inc()
 		metrics.QueryDuration.With(prometheus.Labels{"type": "trace", "handler": "Get_Trace", "code": code}).Observe(time.Since(start).Seconds())
 	}()
-	res, err := getTrace(ctx, p.builder, p.conn, traceID)
+	res, err := getTrace(ctx, p.builder, p.conn, traceID, p.readFilter(ctx))
 
 	if err != nil {
 		if !errors.Is(err, spanstore.ErrTraceNotFound) {
@@ -89,7 +109,7 @@ func (p *Store) GetServices(ctx context.Context) ([]string, error) {
 		metrics.Query.With(prometheus.Labels{"type": "trace", "handler": "Get_Services", "code": code}).Inc()
 		metrics.QueryDuration.With(prometheus.Labels{"type": "trace", "handler": "Get_Services", "code": code}).Observe(time.Since(start).Seconds())
 	}()
-	res, err := getServices(ctx, p.conn)
+	res, err := getServices(ctx, p.conn, p.readFilter(ctx))
 	if err != nil {
 		return nil, logError(err)
 	}
@@ -104,7 +124,7 @@ func (p *Store) GetOperations(ctx context.Context, query spanstore.OperationQuer
 		metrics.Query.With(prometheus.Labels{"type": "trace", "handler": "Get_Operations", "code": code}).Inc()
 		metrics.QueryDuration.With(prometheus.Labels{"type": "trace", "handler": "Get_Operations", "code": code}).Observe(time.Since(start).Seconds())
 	}()
-	res, err := getOperations(ctx, p.conn, query)
+	res, err := getOperations(ctx, p.conn, query, p.readFilter(ctx))
 	if err != nil {
 		return nil, logError(err)
 	}
@@ -119,7 +139,7 @@ func (p *Store) FindTraces(ctx context.Context, query *spanstore.TraceQueryParam
 		metrics.Query.With(prometheus.Labels{"type": "trace", "handler": "Find_Traces", "code": code}).Inc()
 		metrics.QueryDuration.With(prometheus.Labels{"type": "trace", "handler": "Find_Traces", "code": code}).Observe(time.Since(start).Seconds())
 	}()
-	res, err := findTraces(ctx, p.builder, p.conn, query)
+	res, err := findTraces(ctx, p.builder, p.conn, query, p.readFilter(ctx))
 	if err != nil {
 		return nil, logError(err)
 	}
@@ -135,7 +155,7 @@ func (p *Store) FindTraceIDs(ctx context.Context, query *spanstore.TraceQueryPar
 		metrics.Query.With(prometheus.Labels{"type": "trace", "handler": "Find_Trace_IDs", "code": code}).Inc()
 		metrics.QueryDuration.With(prometheus.Labels{"type": "trace", "handler": "Find_Trace_IDs", "code": code}).Observe(time.Since(start).Seconds())
 	}()
-	res, err := findTraceIDs(ctx, p.builder, p.conn, query)
+	res, err := findTraceIDs(ctx, p.builder, p.conn, query, p.readFilter(ctx))
 	if err != nil {
 		return nil, logError(err)
 	}
@@ -152,7 +172,7 @@ func (p *Store) GetDependencies(ctx context.Context, endTs time.Time, lookback t
 		metrics.QueryDuration.With(prometheus.Labels{"type": "trace", "handler": "Get_Dependencies", "code": code}).Observe(time.Since(start).Seconds())
 	}()
 
-	res, err := getDependencies(ctx, p.conn, endTs, lookback)
+	res, err := getDependencies(ctx, p.conn, endTs, lookback, p.readFilter(ctx))
 	if err != nil {
 		return nil, logError(err)
 	}
@@ -161,6 +181,14 @@ func (p *Store) GetDependencies(ctx context.Context, endTs time.Time, lookback t
 	return res, nil
 }
 
+// readFilter returns the tenants whose spans can be read in the context.
+func (p *Store) readFilter(ctx context.Context) tenancy.ReadFilter {
+	if p.tenancy == nil {
+		return tenancy.ReadFilter{NonTenants: true}
+	}
+	return p.tenancy.ReadFilter(ctx)
+}
+
 func (p *Store) GetBuilder() *Builder {
 	return p.builder
 }
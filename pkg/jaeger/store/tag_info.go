@@ -10,6 +10,7 @@ import (
 
 	"github.com/jaegertracing/jaeger/storage/spanstore"
 	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/tenancy"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	semconv "go.opentelemetry.io/collector/semconv/v1.6.1"
 )
@@ -96,6 +97,17 @@ func FindTagInfo(ctx context.Context, q *spanstore.TraceQueryParameters, conn pg
 	return tagsInfo, nil
 }
 
+// restrictTenants restricts the matching spans to the tenants of the filter. It
+// returns the qualifier of the spans, to restrict the spans of the matching traces.
+func (t *tagsInfo) restrictTenants(filter tenancy.ReadFilter) string {
+	var clause string
+	clause, t.params = tenantClause("s", filter, t.params)
+	if filter.Restricted() {
+		t.spanClauses = append(t.spanClauses, clause)
+	}
+	return clause
+}
+
 // BuildTagsQuery returns the query to be used to scan tags.
 // Exposed for end to end tests.
 func (t *tagsInfo) BuildTagsQuery(q *spanstore.TraceQueryParameters) (string, []interface{}) {
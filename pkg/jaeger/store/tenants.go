@@ -0,0 +1,42 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/timescale/promscale/pkg/tenancy"
+)
+
+const (
+	tenantsQualFormat = `%[1]s.resource_tags @> ANY(ARRAY(
+		SELECT pg_catalog.jsonb_build_object(t.key_id, t.id)
+		FROM _ps_trace.tag t
+		WHERE t.key = '%[3]s'
+		AND t.value = ANY(SELECT pg_catalog.to_jsonb(v) FROM unnest($%[2]d::text[]) v)
+	))`
+	hasTenantQualFormat = `_ps_trace.has_tag(%[1]s.resource_tags, '%[2]s')`
+)
+
+// tenantClause returns the qualifier restricting the spans aliased as alias to the
+// tenants of the filter, along with the parameters. The qualifier is "TRUE" if the
+// filter allows reading all the spans.
+func tenantClause(alias string, filter tenancy.ReadFilter, params []interface{}) (string, []interface{}) {
+	if !filter.Restricted() {
+		return "TRUE", params
+	}
+	var clauses []string
+	if filter.Tenants != nil {
+		params = append(params, filter.Tenants)
+		clauses = append(clauses, fmt.Sprintf(tenantsQualFormat, alias, len(params), tenancy.TenantLabelKey))
+	} else {
+		clauses = append(clauses, fmt.Sprintf(hasTenantQualFormat, alias, tenancy.TenantLabelKey))
+	}
+	if filter.NonTenants {
+		clauses = append(clauses, "NOT "+fmt.Sprintf(hasTenantQualFormat, alias, tenancy.TenantLabelKey))
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", params
+}
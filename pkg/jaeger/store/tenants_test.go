@@ -0,0 +1,27 @@
+package store
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timescale/promscale/pkg/tenancy"
+)
+
+func TestTenantClause(t *testing.T) {
+	params := []interface{}{"service"}
+
+	clause, newParams := tenantClause("s", tenancy.ReadFilter{NonTenants: true}, params)
+	assert.Equal(t, "TRUE", clause)
+	assert.Equal(t, params, newParams)
+
+	clause, newParams = tenantClause("s", tenancy.ReadFilter{}, params)
+	assert.Equal(t, "(_ps_trace.has_tag(s.resource_tags, '__tenant__'))", clause)
+	assert.Equal(t, params, newParams)
+
+	clause, newParams = tenantClause("child", tenancy.ReadFilter{Tenants: []string{"a", "b"}, NonTenants: true}, params)
+	assert.True(t, strings.HasPrefix(clause, "(child.resource_tags @> ANY("), clause)
+	assert.Contains(t, clause, "unnest($2::text[])")
+	assert.True(t, strings.HasSuffix(clause, " OR NOT _ps_trace.has_tag(child.resource_tags, '__tenant__'))"), clause)
+	assert.Equal(t, []interface{}{"service", []string{"a", "b"}}, newParams)
+}
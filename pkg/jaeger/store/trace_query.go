@@ -12,6 +12,7 @@ import (
 	"github.com/jackc/pgtype"
 	"github.com/jaegertracing/jaeger/model"
 	"github.com/jaegertracing/jaeger/storage/spanstore"
+	"github.com/timescale/promscale/pkg/tenancy"
 )
 
 const (
@@ -29,14 +30,14 @@ const (
 	) as trace_sub
 	ORDER BY trace_sub.start_time_max DESC
 	`
-	subqueryTimeRangeForTraceID = `
+	subqueryTimeRangeForTraceIDFormat = `
 		SELECT
 			s.trace_id,
 			s.start_time - $1::interval as time_low,
 			s.start_time + $1::interval as time_high
 		FROM _ps_trace.span s
 		WHERE
-			s.trace_id = $2
+			s.trace_id = $2 AND %s
 		LIMIT 1
 	`
 
@@ -132,6 +133,7 @@ const (
 		WHERE
 			s.trace_id = trace_ids.trace_id
 			AND s.start_time > trace_ids.time_low AND s.start_time < trace_ids.time_high
+			AND %s
 		GROUP BY
 			s.trace_id,
 			s.span_id,
@@ -175,9 +177,11 @@ func NewBuilder(cfg *Config) *Builder {
 	return &Builder{cfg}
 }
 
-func (b *Builder) findTracesQuery(q *spanstore.TraceQueryParameters, tInfo *tagsInfo) (string, []interface{}) {
+// findTracesQuery returns the query of the traces matching q. The spans of the
+// traces are restricted by spanClause.
+func (b *Builder) findTracesQuery(q *spanstore.TraceQueryParameters, tInfo *tagsInfo, spanClause string) (string, []interface{}) {
 	subquery, params := b.BuildTraceIDSubquery(q, tInfo)
-	return fmt.Sprintf(findTraceSQLFormat, subquery), params
+	return fmt.Sprintf(findTraceSQLFormat, subquery, spanClause), params
 }
 
 func (b *Builder) findTraceIDsQuery(q *spanstore.TraceQueryParameters, tInfo *tagsInfo) (string, []interface{}) {
@@ -198,7 +202,7 @@ func getUUIDFromTraceID(traceID model.TraceID) (pgtype.UUID, error) {
 	return uuid, nil
 }
 
-func (b *Builder) getTraceQuery(traceID model.TraceID) (string, []interface{}, error) {
+func (b *Builder) getTraceQuery(traceID model.TraceID, filter tenancy.ReadFilter) (string, []interface{}, error) {
 	traceUUID, err := getUUIDFromTraceID(traceID)
 	if err != nil {
 		return "", nil, fmt.Errorf("TraceID to UUID conversion: %w", err)
@@ -207,7 +211,8 @@ func (b *Builder) getTraceQuery(traceID model.TraceID) (string, []interface{}, e
 	//it may seem silly to build a traceID subquery when we know the traceID
 	//but, this allows us to get the time range of the trace for the rest of the query.
 	subquery, params := b.BuildTraceTimeRangeSubqueryForTraceID(traceUUID)
-	return fmt.Sprintf(findTraceSQLFormat, subquery), params, nil
+	spanClause, params := tenantClause("s", filter, params)
+	return fmt.Sprintf(findTraceSQLFormat, fmt.Sprintf(subquery, spanClause), spanClause), params, nil
 }
 
 func (b *Builder) buildOperationSubquery(q *spanstore.TraceQueryParameters, tInfo *tagsInfo, params []interface{}) (string, []interface{}) {
@@ -282,9 +287,12 @@ func (b *Builder) buildTagClauses(q *spanstore.TraceQueryParameters, tInfo *tags
 
 }
 
+// BuildTraceTimeRangeSubqueryForTraceID returns the subquery of the time range of
+// the trace. The spans of the subquery are restricted by the qualifier formatted
+// into it.
 func (b *Builder) BuildTraceTimeRangeSubqueryForTraceID(traceID pgtype.UUID) (string, []interface{}) {
 	params := []interface{}{b.cfg.MaxTraceDuration, traceID}
-	return subqueryTimeRangeForTraceID, params
+	return subqueryTimeRangeForTraceIDFormat, params
 }
 
 func (b *Builder) BuildTraceIDSubquery(q *spanstore.TraceQueryParameters, tInfo *tagsInfo) (string, []interface{}) {
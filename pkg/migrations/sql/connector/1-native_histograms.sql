@@ -0,0 +1,40 @@
+-- Storage for Prometheus native (sparse) histograms. Unlike float samples, which
+-- are stored in a per-metric table, native histograms of all metrics are stored
+-- in a single hypertable keyed on the metric id.
+CREATE SCHEMA IF NOT EXISTS prom_data_histogram;
+GRANT USAGE ON SCHEMA prom_data_histogram TO prom_reader;
+
+CREATE TABLE IF NOT EXISTS prom_data_histogram.sample (
+    time             TIMESTAMPTZ NOT NULL,
+    series_id        BIGINT NOT NULL,
+    metric_id        INT NOT NULL,
+    count            DOUBLE PRECISION NOT NULL,
+    sum              DOUBLE PRECISION NOT NULL,
+    schema           INT NOT NULL,
+    zero_threshold   DOUBLE PRECISION NOT NULL,
+    zero_count       DOUBLE PRECISION NOT NULL,
+    -- spans are stored as flattened (offset, length) pairs.
+    positive_spans   INT[] NOT NULL,
+    positive_buckets DOUBLE PRECISION[] NOT NULL,
+    negative_spans   INT[] NOT NULL,
+    negative_buckets DOUBLE PRECISION[] NOT NULL,
+    reset_hint       SMALLINT NOT NULL DEFAULT 0,
+    UNIQUE (series_id, time)
+);
+CREATE INDEX IF NOT EXISTS sample_metric_id_time_idx ON prom_data_histogram.sample (metric_id, time DESC);
+GRANT SELECT ON TABLE prom_data_histogram.sample TO prom_reader;
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE prom_data_histogram.sample TO prom_writer;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_catalog.pg_extension WHERE extname = 'timescaledb') THEN
+        PERFORM public.create_hypertable(
+            'prom_data_histogram.sample'::regclass,
+            'time'::name,
+            chunk_time_interval=>'8 hours'::interval,
+            create_default_indexes=>false,
+            if_not_exists=>true
+        );
+    END IF;
+END
+$$;
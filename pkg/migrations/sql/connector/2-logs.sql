@@ -0,0 +1,120 @@
+-- Storage for OpenTelemetry log records. Attributes are kept as JSONB, with
+-- resource attributes stored next to the record attributes, so that logs can be
+-- filtered by both without joins. trace_id and span_id follow the types used in
+-- _ps_trace.span, which allows correlating logs with spans.
+CREATE SCHEMA IF NOT EXISTS _ps_log;
+GRANT USAGE ON SCHEMA _ps_log TO prom_reader;
+
+CREATE TABLE IF NOT EXISTS _ps_log.log (
+    time                        TIMESTAMPTZ NOT NULL,
+    observed_time               TIMESTAMPTZ NULL,
+    trace_id                    UUID NULL,
+    span_id                     BIGINT NULL,
+    trace_flags                 INT NOT NULL DEFAULT 0,
+    severity_number             SMALLINT NOT NULL DEFAULT 0,
+    severity_text               TEXT NULL,
+    body                        TEXT NOT NULL,
+    attributes                  JSONB NOT NULL DEFAULT '{}'::jsonb,
+    dropped_attributes_count    INT NOT NULL DEFAULT 0,
+    resource_attributes         JSONB NOT NULL DEFAULT '{}'::jsonb,
+    resource_schema_url         TEXT NULL,
+    scope_name                  TEXT NULL,
+    scope_version               TEXT NULL
+);
+CREATE INDEX IF NOT EXISTS log_time_idx ON _ps_log.log (time DESC);
+CREATE INDEX IF NOT EXISTS log_trace_id_idx ON _ps_log.log (trace_id, time DESC) WHERE trace_id IS NOT NULL;
+GRANT SELECT ON TABLE _ps_log.log TO prom_reader;
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE _ps_log.log TO prom_writer;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_catalog.pg_extension WHERE extname = 'timescaledb') THEN
+        PERFORM public.create_hypertable(
+            '_ps_log.log'::regclass,
+            'time'::name,
+            chunk_time_interval=>'8 hours'::interval,
+            create_default_indexes=>false,
+            if_not_exists=>true
+        );
+    END IF;
+END
+$$;
+
+-- Logs are compressed an hour after their chunk ends, like traces.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_catalog.pg_extension WHERE extname = 'timescaledb')
+        AND NOT _prom_catalog.is_timescaledb_oss()
+        AND _prom_catalog.get_timescale_major_version() >= 2 THEN
+        IF NOT EXISTS (
+            SELECT 1 FROM timescaledb_information.compression_settings s
+            WHERE s.hypertable_schema = '_ps_log' AND s.hypertable_name = 'log'
+        ) THEN
+            ALTER TABLE _ps_log.log SET (
+                timescaledb.compress,
+                timescaledb.compress_orderby = 'time DESC'
+            );
+        END IF;
+        PERFORM public.add_compression_policy('_ps_log.log', INTERVAL '1 hour', if_not_exists => true);
+    END IF;
+END
+$$;
+
+-- Logs older than the log retention period are dropped by the connectors, see
+-- _ps_log.apply_log_retention().
+INSERT INTO _prom_catalog.default(key, value)
+VALUES ('log_retention_period', (30 * INTERVAL '1 days')::text)
+ON CONFLICT (key) DO NOTHING;
+
+CREATE OR REPLACE FUNCTION _ps_log.set_log_retention_period(_log_retention_period INTERVAL)
+RETURNS BOOLEAN
+AS $$
+    INSERT INTO _prom_catalog.default(key, value) VALUES ('log_retention_period', _log_retention_period::text)
+    ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value;
+    SELECT true;
+$$
+LANGUAGE SQL VOLATILE;
+COMMENT ON FUNCTION _ps_log.set_log_retention_period(INTERVAL)
+IS 'set the retention period for log data';
+GRANT EXECUTE ON FUNCTION _ps_log.set_log_retention_period(INTERVAL) TO prom_admin;
+
+CREATE OR REPLACE FUNCTION _ps_log.get_log_retention_period()
+RETURNS INTERVAL
+AS $$
+    SELECT value::interval
+    FROM _prom_catalog.default
+    WHERE key = 'log_retention_period'
+$$
+LANGUAGE SQL STABLE;
+COMMENT ON FUNCTION _ps_log.get_log_retention_period()
+IS 'get the retention period for log data';
+GRANT EXECUTE ON FUNCTION _ps_log.get_log_retention_period() TO prom_reader;
+
+-- Drops the logs older than the log retention period. A retention period that
+-- is not positive keeps all the logs.
+CREATE OR REPLACE FUNCTION _ps_log.apply_log_retention()
+RETURNS VOID
+AS
+$$
+DECLARE
+    _older_than timestamptz;
+BEGIN
+    _older_than = now() - _ps_log.get_log_retention_period();
+    IF _older_than IS NULL OR _older_than >= now() THEN
+        RETURN;
+    END IF;
+
+    IF _prom_catalog.is_timescaledb_installed() THEN
+        PERFORM public.drop_chunks('_ps_log.log'::regclass, older_than => _older_than);
+    ELSE
+        DELETE FROM _ps_log.log WHERE time < _older_than;
+    END IF;
+END;
+$$
+LANGUAGE PLPGSQL VOLATILE
+SECURITY DEFINER
+--search path must be set for security definer
+SET search_path = pg_temp;
+--redundant given schema settings but extra caution for security definers
+REVOKE ALL ON FUNCTION _ps_log.apply_log_retention() FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION _ps_log.apply_log_retention() TO prom_maintenance;
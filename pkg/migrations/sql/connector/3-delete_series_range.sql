@@ -0,0 +1,80 @@
+-- Time-range scoped series deletion. Unlike _prom_catalog.delete_series_from_metric,
+-- the series themselves are kept, only their samples, exemplars and native
+-- histograms within [start_time, end_time] are deleted.
+
+-- Decompresses the chunks of the hypertable that overlap the time range, so that
+-- rows can be deleted from them. Chunks are recompressed by the compression policy.
+CREATE OR REPLACE FUNCTION _prom_catalog.decompress_chunks_in_range(hypertable_schema name, hypertable_name name, start_time TIMESTAMPTZ, end_time TIMESTAMPTZ)
+RETURNS VOID
+AS
+$$
+DECLARE
+    chunk record;
+BEGIN
+    IF NOT _prom_catalog.is_timescaledb_installed() OR _prom_catalog.get_timescale_major_version() < 2 THEN
+        RETURN;
+    END IF;
+    FOR chunk IN
+        SELECT c.chunk_schema, c.chunk_name
+        FROM timescaledb_information.chunks c
+        WHERE c.hypertable_schema = decompress_chunks_in_range.hypertable_schema
+          AND c.hypertable_name = decompress_chunks_in_range.hypertable_name
+          AND c.is_compressed
+          AND c.range_start <= end_time
+          AND c.range_end > start_time
+    LOOP
+        RAISE NOTICE 'Promscale is decompressing chunk: %.%', chunk.chunk_schema, chunk.chunk_name;
+        PERFORM public.decompress_chunk(format('%I.%I', chunk.chunk_schema, chunk.chunk_name)::regclass, if_compressed=>true);
+    END LOOP;
+END;
+$$
+LANGUAGE PLPGSQL VOLATILE
+SECURITY DEFINER
+--search path must be set for security definer
+SET search_path = pg_temp;
+--redundant given schema settings but extra caution for security definers
+REVOKE ALL ON FUNCTION _prom_catalog.decompress_chunks_in_range(name, name, TIMESTAMPTZ, TIMESTAMPTZ) FROM PUBLIC;
+
+CREATE OR REPLACE FUNCTION _prom_catalog.delete_series_from_metric_in_range(name text, series_ids bigint[], start_time TIMESTAMPTZ, end_time TIMESTAMPTZ)
+RETURNS BIGINT
+AS
+$$
+DECLARE
+    metric_id int;
+    metric_table name;
+    exemplar_table name;
+    rows_affected bigint;
+    num_rows_deleted bigint := 0;
+BEGIN
+    SELECT m.id, m.table_name INTO metric_id, metric_table FROM _prom_catalog.metric m WHERE m.metric_name=name AND m.is_view = false;
+    IF metric_table IS NULL THEN
+        RETURN 0;
+    END IF;
+
+    PERFORM _prom_catalog.decompress_chunks_in_range('prom_data', metric_table, start_time, end_time);
+    EXECUTE FORMAT('DELETE FROM prom_data.%1$I WHERE series_id = ANY($1) AND time >= $2 AND time <= $3', metric_table)
+        USING series_ids, start_time, end_time;
+    GET DIAGNOSTICS rows_affected = ROW_COUNT;
+    num_rows_deleted = num_rows_deleted + rows_affected;
+
+    SELECT e.table_name INTO exemplar_table FROM _prom_catalog.exemplar e WHERE e.metric_name = name;
+    IF exemplar_table IS NOT NULL THEN
+        PERFORM _prom_catalog.decompress_chunks_in_range('prom_data_exemplar', exemplar_table, start_time, end_time);
+        EXECUTE FORMAT('DELETE FROM prom_data_exemplar.%1$I WHERE series_id = ANY($1) AND time >= $2 AND time <= $3', exemplar_table)
+            USING series_ids, start_time, end_time;
+        GET DIAGNOSTICS rows_affected = ROW_COUNT;
+        num_rows_deleted = num_rows_deleted + rows_affected;
+    END IF;
+
+    num_rows_deleted = num_rows_deleted + _prom_catalog.delete_histograms(metric_id, series_ids, start_time, end_time);
+
+    RETURN num_rows_deleted;
+END;
+$$
+LANGUAGE PLPGSQL VOLATILE
+SECURITY DEFINER
+--search path must be set for security definer
+SET search_path = pg_temp;
+--redundant given schema settings but extra caution for security definers
+REVOKE ALL ON FUNCTION _prom_catalog.delete_series_from_metric_in_range(text, bigint[], TIMESTAMPTZ, TIMESTAMPTZ) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION _prom_catalog.delete_series_from_metric_in_range(text, bigint[], TIMESTAMPTZ, TIMESTAMPTZ) TO prom_modifier;
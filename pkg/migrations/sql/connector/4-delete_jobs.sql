@@ -0,0 +1,29 @@
+-- State of the asynchronous series deletion jobs submitted through the
+-- delete_series API. Jobs are executed by the connectors, which claim pending
+-- jobs and running jobs whose heartbeat stopped, e.g. after a restart.
+CREATE TABLE IF NOT EXISTS _prom_catalog.delete_job (
+    id               BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    matchers         TEXT[] NOT NULL,
+    -- NULL means the time range is unbounded on that side.
+    start_time       TIMESTAMPTZ,
+    end_time         TIMESTAMPTZ,
+    status           TEXT NOT NULL DEFAULT 'pending'
+                     CHECK (status IN ('pending', 'running', 'succeeded', 'failed', 'cancelled')),
+    cancel_requested BOOLEAN NOT NULL DEFAULT false,
+    metrics_total    INT NOT NULL DEFAULT 0,
+    metrics_done     INT NOT NULL DEFAULT 0,
+    -- Metrics left to process, set when the job first runs so that a resumed
+    -- job continues with them.
+    metrics_pending  TEXT[],
+    series_deleted   BIGINT NOT NULL DEFAULT 0,
+    rows_deleted     BIGINT NOT NULL DEFAULT 0,
+    error            TEXT,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    started_at       TIMESTAMPTZ,
+    finished_at      TIMESTAMPTZ,
+    heartbeat        TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS delete_job_status_idx ON _prom_catalog.delete_job (status, id)
+    WHERE status IN ('pending', 'running');
+GRANT SELECT ON TABLE _prom_catalog.delete_job TO prom_reader;
+GRANT SELECT, INSERT, UPDATE ON TABLE _prom_catalog.delete_job TO prom_modifier;
@@ -0,0 +1,11 @@
+-- Per-metric settings applied from the overrides of the dataset configuration.
+-- They are recorded so that the settings of metrics which are not matched by any
+-- override anymore can be reset to the defaults. NULL means not overridden.
+CREATE TABLE IF NOT EXISTS _prom_catalog.metric_setting_override (
+    metric_name      TEXT PRIMARY KEY,
+    chunk_interval   INTERVAL,
+    compress_data    BOOLEAN,
+    retention_period INTERVAL
+);
+GRANT SELECT ON TABLE _prom_catalog.metric_setting_override TO prom_reader;
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE _prom_catalog.metric_setting_override TO prom_admin;
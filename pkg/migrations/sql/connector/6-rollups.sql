@@ -0,0 +1,183 @@
+-- Rollups are downsampled copies of the metrics, at a coarser resolution and with
+-- their own retention period. They are declared in the dataset configuration and
+-- refreshed by the connectors. Each rollup has its own schema containing one table
+-- per metric, named like the metric table in prom_data. A row of a rollup table
+-- summarizes the samples of a series within [time, time + resolution).
+CREATE TABLE IF NOT EXISTS _prom_catalog.rollup (
+    name             TEXT PRIMARY KEY,
+    schema_name      NAME NOT NULL UNIQUE,
+    resolution       INTERVAL NOT NULL,
+    retention_period INTERVAL NOT NULL
+);
+GRANT SELECT ON TABLE _prom_catalog.rollup TO prom_reader;
+
+CREATE TABLE IF NOT EXISTS _prom_catalog.metric_rollup (
+    rollup_name     TEXT NOT NULL REFERENCES _prom_catalog.rollup (name) ON DELETE CASCADE,
+    table_name      NAME NOT NULL,
+    -- The buckets before this time are complete. Queries read the rollup
+    -- before it, and the raw samples after it.
+    refreshed_until TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (rollup_name, table_name)
+);
+GRANT SELECT ON TABLE _prom_catalog.metric_rollup TO prom_reader;
+
+CREATE OR REPLACE FUNCTION _prom_catalog.create_rollup(rollup_name TEXT, resolution INTERVAL, retention_period INTERVAL)
+RETURNS VOID
+AS
+$$
+DECLARE
+    rollup_schema name := 'prom_data_rollup_' || rollup_name;
+    current_resolution INTERVAL;
+BEGIN
+    IF NOT _prom_catalog.is_timescaledb_installed() THEN
+        RAISE EXCEPTION 'rollups require TimescaleDB';
+    END IF;
+
+    SELECT r.resolution INTO current_resolution FROM _prom_catalog.rollup r WHERE r.name = rollup_name;
+    IF current_resolution IS NOT NULL AND current_resolution <> create_rollup.resolution THEN
+        RAISE EXCEPTION 'cannot change the resolution of rollup % from % to %', rollup_name, current_resolution, create_rollup.resolution
+            USING HINT = 'Remove the rollup and add it back with a different name.';
+    END IF;
+
+    EXECUTE format('CREATE SCHEMA IF NOT EXISTS %I', rollup_schema);
+    EXECUTE format('GRANT USAGE ON SCHEMA %I TO prom_reader', rollup_schema);
+    INSERT INTO _prom_catalog.rollup (name, schema_name, resolution, retention_period)
+    VALUES (rollup_name, rollup_schema, create_rollup.resolution, create_rollup.retention_period)
+    ON CONFLICT (name) DO UPDATE SET retention_period = excluded.retention_period;
+END;
+$$
+LANGUAGE PLPGSQL VOLATILE
+SECURITY DEFINER
+--search path must be set for security definer
+SET search_path = pg_temp;
+--redundant given schema settings but extra caution for security definers
+REVOKE ALL ON FUNCTION _prom_catalog.create_rollup(TEXT, INTERVAL, INTERVAL) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION _prom_catalog.create_rollup(TEXT, INTERVAL, INTERVAL) TO prom_admin;
+
+CREATE OR REPLACE FUNCTION _prom_catalog.drop_rollup(rollup_name TEXT)
+RETURNS VOID
+AS
+$$
+DECLARE
+    rollup_schema name;
+BEGIN
+    DELETE FROM _prom_catalog.rollup r WHERE r.name = rollup_name RETURNING r.schema_name INTO rollup_schema;
+    IF rollup_schema IS NOT NULL THEN
+        EXECUTE format('DROP SCHEMA IF EXISTS %I CASCADE', rollup_schema);
+    END IF;
+END;
+$$
+LANGUAGE PLPGSQL VOLATILE
+SECURITY DEFINER
+--search path must be set for security definer
+SET search_path = pg_temp;
+--redundant given schema settings but extra caution for security definers
+REVOKE ALL ON FUNCTION _prom_catalog.drop_rollup(TEXT) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION _prom_catalog.drop_rollup(TEXT) TO prom_admin;
+
+-- Rolls up the complete buckets of the metric table up to refresh_until, at most
+-- max_window at a time, and returns the new refreshed_until of the metric. The
+-- rollup table of the metric is created on the first refresh, and is filled
+-- starting from the oldest raw sample.
+CREATE OR REPLACE FUNCTION _prom_catalog.refresh_metric_rollup(rollup_name TEXT, metric_table NAME, refresh_until TIMESTAMPTZ, max_window INTERVAL)
+RETURNS TIMESTAMPTZ
+AS
+$$
+DECLARE
+    r _prom_catalog.rollup;
+    watermark TIMESTAMPTZ;
+    target TIMESTAMPTZ;
+BEGIN
+    SELECT * INTO r FROM _prom_catalog.rollup WHERE name = rollup_name;
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'rollup % does not exist', rollup_name;
+    END IF;
+
+    target := public.time_bucket(r.resolution, refresh_until);
+
+    SELECT mr.refreshed_until INTO watermark
+    FROM _prom_catalog.metric_rollup mr
+    WHERE mr.rollup_name = r.name AND mr.table_name = metric_table;
+    IF NOT FOUND THEN
+        EXECUTE format(
+            $sql$CREATE TABLE IF NOT EXISTS %I.%I (
+                time      TIMESTAMPTZ NOT NULL,
+                series_id BIGINT NOT NULL,
+                value     DOUBLE PRECISION NOT NULL,
+                sum       DOUBLE PRECISION NOT NULL,
+                min       DOUBLE PRECISION NOT NULL,
+                max       DOUBLE PRECISION NOT NULL,
+                UNIQUE (series_id, time)
+            )$sql$, r.schema_name, metric_table);
+        EXECUTE format('GRANT SELECT ON TABLE %I.%I TO prom_reader', r.schema_name, metric_table);
+        PERFORM public.create_hypertable(
+            format('%I.%I', r.schema_name, metric_table)::regclass,
+            'time',
+            chunk_time_interval => r.resolution * 1000,
+            create_default_indexes => false,
+            if_not_exists => true);
+
+        EXECUTE format('SELECT public.time_bucket($1, min(time)) FROM prom_data.%I', metric_table)
+            INTO watermark USING r.resolution;
+        watermark := coalesce(watermark, target);
+        INSERT INTO _prom_catalog.metric_rollup (rollup_name, table_name, refreshed_until)
+        VALUES (r.name, metric_table, watermark);
+    END IF;
+
+    IF watermark >= target THEN
+        RETURN watermark;
+    END IF;
+    target := least(target, greatest(public.time_bucket(r.resolution, watermark + max_window), watermark + r.resolution));
+
+    -- Stale markers are NaNs, and are not rolled up.
+    EXECUTE format(
+        $sql$INSERT INTO %1$I.%2$I (time, series_id, value, sum, min, max)
+        SELECT public.time_bucket($1, time), series_id, public.last(value, time), sum(value), min(value), max(value)
+        FROM prom_data.%2$I
+        WHERE time >= $2 AND time < $3 AND value <> 'NaN'::double precision
+        GROUP BY 1, 2
+        ON CONFLICT (series_id, time) DO UPDATE
+        SET value = excluded.value, sum = excluded.sum, min = excluded.min, max = excluded.max$sql$,
+        r.schema_name, metric_table) USING r.resolution, watermark, target;
+
+    UPDATE _prom_catalog.metric_rollup mr SET refreshed_until = target
+    WHERE mr.rollup_name = r.name AND mr.table_name = metric_table;
+    RETURN target;
+END;
+$$
+LANGUAGE PLPGSQL VOLATILE
+SECURITY DEFINER
+--search path must be set for security definer
+SET search_path = pg_temp;
+--redundant given schema settings but extra caution for security definers
+REVOKE ALL ON FUNCTION _prom_catalog.refresh_metric_rollup(TEXT, NAME, TIMESTAMPTZ, INTERVAL) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION _prom_catalog.refresh_metric_rollup(TEXT, NAME, TIMESTAMPTZ, INTERVAL) TO prom_maintenance;
+
+-- Drops the chunks of the rollup tables which are older than the retention period
+-- of the rollup.
+CREATE OR REPLACE FUNCTION _prom_catalog.apply_rollup_retention(rollup_name TEXT)
+RETURNS VOID
+AS
+$$
+DECLARE
+    r _prom_catalog.rollup;
+    metric_table name;
+BEGIN
+    SELECT * INTO r FROM _prom_catalog.rollup WHERE name = rollup_name;
+    IF NOT FOUND THEN
+        RETURN;
+    END IF;
+    FOR metric_table IN
+        SELECT mr.table_name FROM _prom_catalog.metric_rollup mr WHERE mr.rollup_name = r.name
+    LOOP
+        PERFORM public.drop_chunks(format('%I.%I', r.schema_name, metric_table)::regclass, older_than => now() - r.retention_period);
+    END LOOP;
+END;
+$$
+LANGUAGE PLPGSQL VOLATILE
+SECURITY DEFINER
+--search path must be set for security definer
+SET search_path = pg_temp;
+--redundant given schema settings but extra caution for security definers
+REVOKE ALL ON FUNCTION _prom_catalog.apply_rollup_retention(TEXT) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION _prom_catalog.apply_rollup_retention(TEXT) TO prom_maintenance;
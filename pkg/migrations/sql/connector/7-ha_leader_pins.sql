@@ -0,0 +1,9 @@
+-- Leaders pinned through the HA admin API. The leader of a pinned cluster is
+-- not changed automatically, e.g. when its lease expires during maintenance.
+CREATE TABLE IF NOT EXISTS _prom_catalog.ha_leader_pin (
+    cluster_name TEXT PRIMARY KEY,
+    leader_name  TEXT NOT NULL,
+    pinned_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+GRANT SELECT ON TABLE _prom_catalog.ha_leader_pin TO prom_reader;
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE _prom_catalog.ha_leader_pin TO prom_writer;
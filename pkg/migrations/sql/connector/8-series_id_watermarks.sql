@@ -0,0 +1,9 @@
+-- Highest series id at the start of each hour, recorded by the connector. Series
+-- ids are allocated in increasing order, hence the series created during an hour
+-- are the ones with an id between the watermark of the hour and the next one.
+CREATE TABLE IF NOT EXISTS _prom_catalog.series_id_watermark (
+    time      TIMESTAMPTZ PRIMARY KEY,
+    series_id BIGINT NOT NULL
+);
+GRANT SELECT ON TABLE _prom_catalog.series_id_watermark TO prom_reader;
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE _prom_catalog.series_id_watermark TO prom_writer;
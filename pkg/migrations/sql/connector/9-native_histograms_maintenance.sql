@@ -0,0 +1,213 @@
+-- Maintenance of prom_data_histogram.sample. The per-metric hypertables are
+-- maintained by the Promscale extension, which does not know about native
+-- histograms. The functions below apply the same retention, compression and
+-- series deletion to the histograms, and are run by the connectors.
+
+-- Deletes the native histograms of the series of the metric within
+-- [start_time, end_time], decompressing the chunks holding them first. All the
+-- series of the metric are deleted if series_ids is NULL.
+CREATE OR REPLACE FUNCTION _prom_catalog.delete_histograms(metric_id INT, series_ids BIGINT[], start_time TIMESTAMPTZ, end_time TIMESTAMPTZ)
+RETURNS BIGINT
+AS
+$$
+DECLARE
+    first_time TIMESTAMPTZ;
+    last_time TIMESTAMPTZ;
+    rows_affected bigint;
+BEGIN
+    SELECT min(h.time), max(h.time) INTO first_time, last_time
+    FROM prom_data_histogram.sample h
+    WHERE h.metric_id = delete_histograms.metric_id
+      AND (series_ids IS NULL OR h.series_id = ANY(series_ids))
+      AND h.time >= start_time AND h.time <= end_time;
+    IF first_time IS NULL THEN
+        RETURN 0;
+    END IF;
+
+    PERFORM _prom_catalog.decompress_chunks_in_range('prom_data_histogram', 'sample', first_time, last_time);
+    DELETE FROM prom_data_histogram.sample h
+    WHERE h.metric_id = delete_histograms.metric_id
+      AND (series_ids IS NULL OR h.series_id = ANY(series_ids))
+      AND h.time >= first_time AND h.time <= last_time;
+    GET DIAGNOSTICS rows_affected = ROW_COUNT;
+    RETURN rows_affected;
+END;
+$$
+LANGUAGE PLPGSQL VOLATILE
+SECURITY DEFINER
+--search path must be set for security definer
+SET search_path = pg_temp;
+--redundant given schema settings but extra caution for security definers
+REVOKE ALL ON FUNCTION _prom_catalog.delete_histograms(INT, BIGINT[], TIMESTAMPTZ, TIMESTAMPTZ) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION _prom_catalog.delete_histograms(INT, BIGINT[], TIMESTAMPTZ, TIMESTAMPTZ) TO prom_modifier;
+GRANT EXECUTE ON FUNCTION _prom_catalog.delete_histograms(INT, BIGINT[], TIMESTAMPTZ, TIMESTAMPTZ) TO prom_maintenance;
+
+-- Same as _prom_catalog.delete_series_from_metric, also deleting the native
+-- histograms of the series.
+CREATE OR REPLACE FUNCTION _prom_catalog.delete_series_and_histograms_from_metric(name text, series_ids bigint[])
+RETURNS BIGINT
+AS
+$$
+DECLARE
+    histogram_metric_id int;
+    num_rows_deleted bigint := 0;
+BEGIN
+    SELECT m.id INTO histogram_metric_id FROM _prom_catalog.metric m WHERE m.metric_name = name AND m.is_view = false;
+    IF histogram_metric_id IS NOT NULL THEN
+        num_rows_deleted = _prom_catalog.delete_histograms(histogram_metric_id, series_ids, '-infinity', 'infinity');
+    END IF;
+    RETURN num_rows_deleted + _prom_catalog.delete_series_from_metric(name, series_ids);
+END;
+$$
+LANGUAGE PLPGSQL VOLATILE
+SECURITY DEFINER
+--search path must be set for security definer
+SET search_path = pg_temp;
+--redundant given schema settings but extra caution for security definers
+REVOKE ALL ON FUNCTION _prom_catalog.delete_series_and_histograms_from_metric(text, bigint[]) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION _prom_catalog.delete_series_and_histograms_from_metric(text, bigint[]) TO prom_modifier;
+
+-- Applies the retention period of each metric to its native histograms, and
+-- marks the series left without any sample or histogram as unused, so that they
+-- are deleted by the series garbage collection of the metric.
+CREATE OR REPLACE FUNCTION _prom_catalog.apply_histogram_retention()
+RETURNS VOID
+AS
+$$
+DECLARE
+    r record;
+    longest_retention INTERVAL;
+BEGIN
+    -- The chunks older than the longest retention period only hold expired
+    -- histograms, and are dropped at once.
+    SELECT greatest(_prom_catalog.get_default_retention_period(), max(_prom_catalog.get_metric_retention_period(m.table_schema, m.metric_name)))
+    INTO longest_retention
+    FROM _prom_catalog.metric m
+    WHERE m.table_schema = 'prom_data' AND NOT m.is_view;
+
+    FOR r IN
+        SELECT m.id, m.table_name, m.series_table, now() - _prom_catalog.get_metric_retention_period(m.table_schema, m.metric_name) AS older_than
+        FROM _prom_catalog.metric m
+        WHERE m.table_schema = 'prom_data' AND NOT m.is_view
+          AND EXISTS (SELECT 1 FROM prom_data_histogram.sample h WHERE h.metric_id = m.id)
+    LOOP
+        UPDATE _prom_catalog.series s SET delete_epoch = e.current_epoch + 1
+        FROM _prom_catalog.ids_epoch e
+        WHERE s.delete_epoch IS NULL
+          AND s.id IN (
+            SELECT unnest(_prom_catalog.get_confirmed_unused_series('prom_data', r.table_name, r.series_table, array_agg(p.series_id), '-infinity'))
+            FROM (
+                SELECT DISTINCT h.series_id FROM prom_data_histogram.sample h WHERE h.metric_id = r.id AND h.time < r.older_than
+                EXCEPT
+                SELECT DISTINCT h.series_id FROM prom_data_histogram.sample h WHERE h.metric_id = r.id AND h.time >= r.older_than
+            ) p
+          );
+
+        IF r.older_than > now() - longest_retention THEN
+            PERFORM _prom_catalog.delete_histograms(r.id, NULL, '-infinity', r.older_than);
+        END IF;
+    END LOOP;
+
+    IF _prom_catalog.is_timescaledb_installed() THEN
+        PERFORM public.drop_chunks('prom_data_histogram.sample'::regclass, older_than => now() - longest_retention);
+    ELSE
+        DELETE FROM prom_data_histogram.sample h WHERE h.time < now() - longest_retention;
+    END IF;
+END;
+$$
+LANGUAGE PLPGSQL VOLATILE
+SECURITY DEFINER
+--search path must be set for security definer
+SET search_path = pg_temp;
+--redundant given schema settings but extra caution for security definers
+REVOKE ALL ON FUNCTION _prom_catalog.apply_histogram_retention() FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION _prom_catalog.apply_histogram_retention() TO prom_maintenance;
+
+-- Deletes the native histograms of the dropped metrics, and keeps the series
+-- which still have native histograms from being garbage collected, as the
+-- series garbage collection of a metric only looks at its float samples.
+CREATE OR REPLACE FUNCTION _prom_catalog.collect_histogram_series()
+RETURNS VOID
+AS
+$$
+DECLARE
+    dropped_metric_id int;
+BEGIN
+    FOR dropped_metric_id IN
+        WITH RECURSIVE metric_ids AS (
+            SELECT min(h.metric_id) AS id FROM prom_data_histogram.sample h
+            UNION ALL
+            SELECT (SELECT min(h.metric_id) FROM prom_data_histogram.sample h WHERE h.metric_id > metric_ids.id)
+            FROM metric_ids
+            WHERE metric_ids.id IS NOT NULL
+        )
+        SELECT i.id FROM metric_ids i
+        WHERE i.id IS NOT NULL
+          AND NOT EXISTS (SELECT 1 FROM _prom_catalog.metric m WHERE m.id = i.id)
+    LOOP
+        PERFORM _prom_catalog.delete_histograms(dropped_metric_id, NULL, '-infinity', 'infinity');
+    END LOOP;
+
+    UPDATE _prom_catalog.series s SET delete_epoch = NULL
+    WHERE s.delete_epoch IS NOT NULL
+      AND EXISTS (SELECT 1 FROM prom_data_histogram.sample h WHERE h.series_id = s.id);
+END;
+$$
+LANGUAGE PLPGSQL VOLATILE
+SECURITY DEFINER
+--search path must be set for security definer
+SET search_path = pg_temp;
+--redundant given schema settings but extra caution for security definers
+REVOKE ALL ON FUNCTION _prom_catalog.collect_histogram_series() FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION _prom_catalog.collect_histogram_series() TO prom_maintenance;
+
+-- Compresses the chunks of native histograms which ended more than an hour ago,
+-- except the most recent one, like the chunks of the metric tables. Histograms
+-- are only compressed if metric compression is enabled by default.
+CREATE OR REPLACE FUNCTION _prom_catalog.compress_histogram_chunks()
+RETURNS VOID
+AS
+$$
+DECLARE
+    chunk record;
+BEGIN
+    IF NOT _prom_catalog.is_timescaledb_installed()
+        OR _prom_catalog.is_timescaledb_oss()
+        OR _prom_catalog.get_timescale_major_version() < 2
+        OR NOT _prom_catalog.get_default_compression_setting() THEN
+        RETURN;
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM timescaledb_information.compression_settings s
+        WHERE s.hypertable_schema = 'prom_data_histogram' AND s.hypertable_name = 'sample'
+    ) THEN
+        ALTER TABLE prom_data_histogram.sample SET (
+            timescaledb.compress,
+            timescaledb.compress_segmentby = 'metric_id, series_id',
+            timescaledb.compress_orderby = 'time'
+        );
+    END IF;
+
+    FOR chunk IN
+        SELECT c.chunk_schema, c.chunk_name
+        FROM (
+            SELECT c.chunk_schema, c.chunk_name, c.range_end, c.is_compressed,
+                row_number() OVER (ORDER BY c.range_end DESC) AS chunk_num
+            FROM timescaledb_information.chunks c
+            WHERE c.hypertable_schema = 'prom_data_histogram' AND c.hypertable_name = 'sample'
+        ) c
+        WHERE NOT c.is_compressed AND c.chunk_num > 1 AND c.range_end <= now() - INTERVAL '1 hour'
+        ORDER BY c.range_end ASC
+    LOOP
+        PERFORM public.compress_chunk(format('%I.%I', chunk.chunk_schema, chunk.chunk_name)::regclass, if_not_compressed => true);
+    END LOOP;
+END;
+$$
+LANGUAGE PLPGSQL VOLATILE
+SECURITY DEFINER
+--search path must be set for security definer
+SET search_path = pg_temp;
+--redundant given schema settings but extra caution for security definers
+REVOKE ALL ON FUNCTION _prom_catalog.compress_histogram_chunks() FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION _prom_catalog.compress_histogram_chunks() TO prom_maintenance;
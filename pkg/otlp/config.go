@@ -0,0 +1,91 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package otlp
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultDeltaStaleness = time.Hour
+
+	copyAllAttributes = "*"
+)
+
+// Config holds the configuration of the OTLP metrics receiver.
+type Config struct {
+	// ResourceAttributes is a comma separated list of rules that copy resource
+	// attributes into series labels. Each rule is either an attribute name, which
+	// is copied into a label of the same (sanitized) name, or `attribute=label`.
+	// `*` copies all resource attributes.
+	ResourceAttributes string
+	// DeltaStaleness is the duration after which the running totals of delta
+	// temporality series that did not receive data are forgotten.
+	DeltaStaleness time.Duration
+}
+
+var DefaultConfig = Config{
+	DeltaStaleness: DefaultDeltaStaleness,
+}
+
+func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
+	fs.StringVar(&cfg.ResourceAttributes, "metrics.otlp.resource-attributes", "", "Comma separated list of OTLP resource attributes that are copied into series labels. "+
+		"Use `attribute=label` to copy an attribute into a label with a different name and `*` to copy all resource attributes. "+
+		"The job and instance labels are always derived from service.namespace, service.name and service.instance.id.")
+	fs.DurationVar(&cfg.DeltaStaleness, "metrics.otlp.delta-staleness", DefaultDeltaStaleness, "Duration after which the running total of an OTLP delta temporality series that stopped receiving data is forgotten. "+
+		"A series that resumes afterwards starts counting from zero again.")
+	return cfg
+}
+
+func Validate(cfg *Config) error {
+	if _, err := parseResourceRules(cfg.ResourceAttributes); err != nil {
+		return fmt.Errorf("invalid metrics.otlp.resource-attributes: %w", err)
+	}
+	if cfg.DeltaStaleness <= 0 {
+		return fmt.Errorf("metrics.otlp.delta-staleness must be positive, got %s", cfg.DeltaStaleness)
+	}
+	return nil
+}
+
+// resourceRule copies the resource attribute into the label.
+type resourceRule struct {
+	attribute string
+	label     string
+}
+
+type resourceRules struct {
+	copyAll bool
+	rules   []resourceRule
+}
+
+func parseResourceRules(s string) (resourceRules, error) {
+	var res resourceRules
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+			continue
+		case entry == copyAllAttributes:
+			res.copyAll = true
+			continue
+		}
+		attribute, label, renamed := strings.Cut(entry, "=")
+		attribute, label = strings.TrimSpace(attribute), strings.TrimSpace(label)
+		if attribute == "" {
+			return resourceRules{}, fmt.Errorf("empty attribute name in rule %q", entry)
+		}
+		if !renamed {
+			label = attribute
+		}
+		if label == "" {
+			return resourceRules{}, fmt.Errorf("empty label name in rule %q", entry)
+		}
+		res.rules = append(res.rules, resourceRule{attribute: attribute, label: SanitizeLabelName(label)})
+	}
+	return res, nil
+}
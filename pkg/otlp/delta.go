@@ -0,0 +1,89 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package otlp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+)
+
+// deltaAccumulator converts delta temporality data points into cumulative values by
+// keeping the running total of every series. Totals are kept in memory only, hence
+// a restart of Promscale (or sending the same series to several Promscale instances)
+// shows up as a counter reset.
+type deltaAccumulator struct {
+	mu        sync.Mutex
+	staleness time.Duration
+	lastEvict time.Time
+	totals    map[string]*deltaTotal
+}
+
+type deltaTotal struct {
+	lastTs    int64
+	lastSeen  time.Time
+	value     float64
+	histogram *model.FloatHistogram
+}
+
+func newDeltaAccumulator(staleness time.Duration) *deltaAccumulator {
+	return &deltaAccumulator{
+		staleness: staleness,
+		totals:    make(map[string]*deltaTotal),
+	}
+}
+
+// accumulate adds the delta to the running total of the series and returns the new total.
+// Data points which are not newer than the last data point of the series are rejected,
+// since their delta would be counted twice.
+func (d *deltaAccumulator) accumulate(key string, ts int64, delta float64, now time.Time) (float64, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	total, ok := d.totals[key]
+	if !ok {
+		d.totals[key] = &deltaTotal{lastTs: ts, lastSeen: now, value: delta}
+		return delta, true
+	}
+	if ts <= total.lastTs {
+		return 0, false
+	}
+	total.lastTs, total.lastSeen = ts, now
+	total.value += delta
+	return total.value, true
+}
+
+// accumulateHistogram is the equivalent of accumulate for exponential histograms.
+func (d *deltaAccumulator) accumulateHistogram(key string, ts int64, delta *model.FloatHistogram, now time.Time) (*model.FloatHistogram, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	total, ok := d.totals[key]
+	if !ok || total.histogram == nil {
+		d.totals[key] = &deltaTotal{lastTs: ts, lastSeen: now, histogram: delta.Copy()}
+		return delta, true
+	}
+	if ts <= total.lastTs {
+		return nil, false
+	}
+	total.lastTs, total.lastSeen = ts, now
+	total.histogram.Add(delta)
+	return total.histogram.Copy(), true
+}
+
+// evictStale forgets the totals of series that did not receive data within the
+// staleness duration. Eviction runs at most once per staleness duration.
+func (d *deltaAccumulator) evictStale(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if now.Sub(d.lastEvict) < d.staleness {
+		return
+	}
+	d.lastEvict = now
+	for key, total := range d.totals {
+		if now.Sub(total.lastSeen) >= d.staleness {
+			delete(d.totals, key)
+		}
+	}
+}
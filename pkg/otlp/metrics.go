@@ -0,0 +1,540 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package otlp
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	pmodel "github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/prompb"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	conventions "go.opentelemetry.io/collector/semconv/v1.9.0"
+)
+
+const (
+	jobLabel      = "job"
+	instanceLabel = "instance"
+
+	traceIDLabel = "trace_id"
+	spanIDLabel  = "span_id"
+
+	bucketSuffix = "_bucket"
+	sumSuffix    = "_sum"
+	countSuffix  = "_count"
+
+	// maxSchema is the highest resolution supported by native histograms. Exponential
+	// histograms with a higher scale are reduced to it.
+	maxSchema = 8
+	minSchema = -4
+)
+
+// Translator converts OTLP metrics into Prometheus series. It is safe for concurrent use.
+//
+// Gauges, cumulative sums and summaries map directly to series. Explicit bucket
+// histograms are mapped to classic Prometheus histograms (`_bucket`, `_sum` and `_count`
+// series), while exponential histograms are stored as native histograms. Data points
+// with delta temporality are converted to cumulative values by keeping a running total
+// per series in memory.
+type Translator struct {
+	rules  resourceRules
+	deltas *deltaAccumulator
+}
+
+// NewTranslator returns a translator configured by cfg.
+func NewTranslator(cfg Config) (*Translator, error) {
+	rules, err := parseResourceRules(cfg.ResourceAttributes)
+	if err != nil {
+		return nil, err
+	}
+	staleness := cfg.DeltaStaleness
+	if staleness <= 0 {
+		staleness = DefaultDeltaStaleness
+	}
+	return &Translator{
+		rules:  rules,
+		deltas: newDeltaAccumulator(staleness),
+	}, nil
+}
+
+// Stats holds the number of OTLP data points translated and dropped.
+type Stats struct {
+	DataPoints        int
+	DroppedDataPoints int
+}
+
+// ToWriteRequest appends the series translated from md to wr, along with
+// the metadata of every metric.
+func (t *Translator) ToWriteRequest(md pmetric.Metrics, wr *prompb.WriteRequest) Stats {
+	b := &requestBuilder{
+		wr:           wr,
+		seriesIndex:  make(map[string]int),
+		seenMetadata: make(map[string]struct{}),
+		deltas:       t.deltas,
+		now:          time.Now(),
+	}
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		rm := rms.At(i)
+		resourceLabels := t.resourceLabels(rm.Resource())
+		sms := rm.ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			metrics := sms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				b.addMetric(resourceLabels, metrics.At(k))
+			}
+		}
+	}
+	t.deltas.evictStale(b.now)
+	return b.stats
+}
+
+// resourceLabels returns the labels derived from the resource, following the
+// Prometheus conventions for job and instance.
+func (t *Translator) resourceLabels(resource pcommon.Resource) map[string]string {
+	attrs := resource.Attributes()
+	res := make(map[string]string)
+	if t.rules.copyAll {
+		attrs.Range(func(k string, v pcommon.Value) bool {
+			addLabel(res, SanitizeLabelName(k), v.AsString())
+			return true
+		})
+	}
+	if serviceName, ok := attrs.Get(conventions.AttributeServiceName); ok {
+		job := serviceName.AsString()
+		if namespace, ok := attrs.Get(conventions.AttributeServiceNamespace); ok {
+			job = namespace.AsString() + "/" + job
+		}
+		res[jobLabel] = job
+	}
+	if instance, ok := attrs.Get(conventions.AttributeServiceInstanceID); ok {
+		res[instanceLabel] = instance.AsString()
+	}
+	for _, rule := range t.rules.rules {
+		if v, ok := attrs.Get(rule.attribute); ok {
+			res[rule.label] = v.AsString()
+		}
+	}
+	return res
+}
+
+type requestBuilder struct {
+	wr           *prompb.WriteRequest
+	seriesIndex  map[string]int
+	seenMetadata map[string]struct{}
+	deltas       *deltaAccumulator
+	now          time.Time
+	stats        Stats
+}
+
+func (b *requestBuilder) addMetric(resourceLabels map[string]string, m pmetric.Metric) {
+	name := sanitizeMetricName(m.Name())
+	if name == "" {
+		b.stats.DroppedDataPoints += dataPointCount(m)
+		return
+	}
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		b.addMetadata(name, m, prompb.MetricMetadata_GAUGE)
+		b.addNumberDataPoints(resourceLabels, name, m.Gauge().DataPoints(), false)
+	case pmetric.MetricTypeSum:
+		sum := m.Sum()
+		typ := prompb.MetricMetadata_GAUGE
+		if sum.IsMonotonic() {
+			typ = prompb.MetricMetadata_COUNTER
+		}
+		b.addMetadata(name, m, typ)
+		b.addNumberDataPoints(resourceLabels, name, sum.DataPoints(), isDelta(sum.AggregationTemporality()))
+	case pmetric.MetricTypeHistogram:
+		h := m.Histogram()
+		b.addMetadata(name, m, prompb.MetricMetadata_HISTOGRAM)
+		b.addHistogramDataPoints(resourceLabels, name, h.DataPoints(), isDelta(h.AggregationTemporality()))
+	case pmetric.MetricTypeExponentialHistogram:
+		h := m.ExponentialHistogram()
+		b.addMetadata(name, m, prompb.MetricMetadata_HISTOGRAM)
+		b.addExponentialHistogramDataPoints(resourceLabels, name, h.DataPoints(), isDelta(h.AggregationTemporality()))
+	case pmetric.MetricTypeSummary:
+		b.addMetadata(name, m, prompb.MetricMetadata_SUMMARY)
+		b.addSummaryDataPoints(resourceLabels, name, m.Summary().DataPoints())
+	}
+}
+
+func (b *requestBuilder) addMetadata(name string, m pmetric.Metric, typ prompb.MetricMetadata_MetricType) {
+	if _, seen := b.seenMetadata[name]; seen {
+		return
+	}
+	b.seenMetadata[name] = struct{}{}
+	b.wr.Metadata = append(b.wr.Metadata, prompb.MetricMetadata{
+		Type:             typ,
+		MetricFamilyName: name,
+		Help:             m.Description(),
+		Unit:             m.Unit(),
+	})
+}
+
+func (b *requestBuilder) addNumberDataPoints(resourceLabels map[string]string, name string, points pmetric.NumberDataPointSlice, delta bool) {
+	for i := 0; i < points.Len(); i++ {
+		p := points.At(i)
+		var v float64
+		switch {
+		case p.Flags().NoRecordedValue():
+			v = math.Float64frombits(value.StaleNaN)
+		case p.ValueType() == pmetric.NumberDataPointValueTypeInt:
+			v = float64(p.IntValue())
+		case p.ValueType() == pmetric.NumberDataPointValueTypeDouble:
+			v = p.DoubleValue()
+		default:
+			b.stats.DroppedDataPoints++
+			continue
+		}
+		ts := toMillis(p.Timestamp())
+		lbls := buildLabels(resourceLabels, p.Attributes(), name)
+
+		if delta && !p.Flags().NoRecordedValue() {
+			var ok bool
+			if v, ok = b.deltas.accumulate(lbls.key(), ts, v, b.now); !ok {
+				b.stats.DroppedDataPoints++
+				continue
+			}
+		}
+		b.stats.DataPoints++
+		b.addSample(lbls, ts, v, convertExemplars(p.Exemplars()))
+	}
+}
+
+func (b *requestBuilder) addHistogramDataPoints(resourceLabels map[string]string, name string, points pmetric.HistogramDataPointSlice, delta bool) {
+	for i := 0; i < points.Len(); i++ {
+		p := points.At(i)
+		ts := toMillis(p.Timestamp())
+		stale := p.Flags().NoRecordedValue()
+		bounds := p.ExplicitBounds()
+		counts := p.BucketCounts()
+		if counts.Len() != 0 && counts.Len() != bounds.Len()+1 {
+			b.stats.DroppedDataPoints++
+			continue
+		}
+
+		// Each component of the histogram is a series on its own, hence the running totals
+		// of delta histograms are kept per component.
+		emit := func(lbls seriesLabels, v float64, exemplars []prompb.Exemplar) bool {
+			switch {
+			case stale:
+				v = math.Float64frombits(value.StaleNaN)
+			case delta:
+				var ok bool
+				if v, ok = b.deltas.accumulate(lbls.key(), ts, v, b.now); !ok {
+					return false
+				}
+			}
+			b.addSample(lbls, ts, v, exemplars)
+			return true
+		}
+
+		exemplars := convertExemplars(p.Exemplars())
+		exemplarValues := exemplarValues(p.Exemplars())
+		cumulative := uint64(0)
+		accepted := true
+		for j := 0; j < counts.Len(); j++ {
+			upper := math.Inf(1)
+			if j < bounds.Len() {
+				upper = bounds.At(j)
+			}
+			cumulative += counts.At(j)
+			lbls := buildLabels(resourceLabels, p.Attributes(), name+bucketSuffix, prompb.Label{Name: labels.BucketLabel, Value: formatFloat(upper)})
+			var bucketExemplars []prompb.Exemplar
+			for k, ev := range exemplarValues {
+				lower := math.Inf(-1)
+				if j > 0 {
+					lower = bounds.At(j - 1)
+				}
+				if ev > lower && ev <= upper {
+					bucketExemplars = append(bucketExemplars, exemplars[k])
+				}
+			}
+			accepted = emit(lbls, float64(cumulative), bucketExemplars) && accepted
+		}
+		if counts.Len() == 0 {
+			// Without buckets only the +Inf bucket, which equals the count, is known.
+			lbls := buildLabels(resourceLabels, p.Attributes(), name+bucketSuffix, prompb.Label{Name: labels.BucketLabel, Value: formatFloat(math.Inf(1))})
+			accepted = emit(lbls, float64(p.Count()), exemplars) && accepted
+		}
+		if p.HasSum() {
+			accepted = emit(buildLabels(resourceLabels, p.Attributes(), name+sumSuffix), p.Sum(), nil) && accepted
+		}
+		accepted = emit(buildLabels(resourceLabels, p.Attributes(), name+countSuffix), float64(p.Count()), nil) && accepted
+
+		if accepted {
+			b.stats.DataPoints++
+		} else {
+			b.stats.DroppedDataPoints++
+		}
+	}
+}
+
+func (b *requestBuilder) addExponentialHistogramDataPoints(resourceLabels map[string]string, name string, points pmetric.ExponentialHistogramDataPointSlice, delta bool) {
+	for i := 0; i < points.Len(); i++ {
+		p := points.At(i)
+		ts := toMillis(p.Timestamp())
+		lbls := buildLabels(resourceLabels, p.Attributes(), name)
+		if p.Flags().NoRecordedValue() {
+			// Native histograms have no stale marker representation here, the point is skipped.
+			b.stats.DroppedDataPoints++
+			continue
+		}
+		h, ok := exponentialToFloatHistogram(p)
+		if !ok {
+			b.stats.DroppedDataPoints++
+			continue
+		}
+		if delta {
+			if h, ok = b.deltas.accumulateHistogram(lbls.key(), ts, h, b.now); !ok {
+				b.stats.DroppedDataPoints++
+				continue
+			}
+		}
+		b.stats.DataPoints++
+		series := b.series(lbls)
+		series.Histograms = append(series.Histograms, h.ToProto(ts))
+		series.Exemplars = append(series.Exemplars, convertExemplars(p.Exemplars())...)
+	}
+}
+
+func (b *requestBuilder) addSummaryDataPoints(resourceLabels map[string]string, name string, points pmetric.SummaryDataPointSlice) {
+	for i := 0; i < points.Len(); i++ {
+		p := points.At(i)
+		ts := toMillis(p.Timestamp())
+		stale := p.Flags().NoRecordedValue()
+		valueOrStale := func(v float64) float64 {
+			if stale {
+				return math.Float64frombits(value.StaleNaN)
+			}
+			return v
+		}
+
+		quantiles := p.QuantileValues()
+		for j := 0; j < quantiles.Len(); j++ {
+			q := quantiles.At(j)
+			lbls := buildLabels(resourceLabels, p.Attributes(), name, prompb.Label{Name: pmodel.QuantileLabel, Value: formatFloat(q.Quantile())})
+			b.addSample(lbls, ts, valueOrStale(q.Value()), nil)
+		}
+		b.addSample(buildLabels(resourceLabels, p.Attributes(), name+sumSuffix), ts, valueOrStale(p.Sum()), nil)
+		b.addSample(buildLabels(resourceLabels, p.Attributes(), name+countSuffix), ts, valueOrStale(float64(p.Count())), nil)
+		b.stats.DataPoints++
+	}
+}
+
+func (b *requestBuilder) addSample(lbls seriesLabels, ts int64, v float64, exemplars []prompb.Exemplar) {
+	series := b.series(lbls)
+	series.Samples = append(series.Samples, prompb.Sample{Timestamp: ts, Value: v})
+	series.Exemplars = append(series.Exemplars, exemplars...)
+}
+
+// series returns the series with the given labels from the write request, adding
+// it if needed. The same series can appear in multiple resource or scope metrics.
+func (b *requestBuilder) series(lbls seriesLabels) *prompb.TimeSeries {
+	key := lbls.key()
+	if i, ok := b.seriesIndex[key]; ok {
+		return &b.wr.Timeseries[i]
+	}
+	b.seriesIndex[key] = len(b.wr.Timeseries)
+	b.wr.Timeseries = append(b.wr.Timeseries, prompb.TimeSeries{Labels: lbls})
+	return &b.wr.Timeseries[len(b.wr.Timeseries)-1]
+}
+
+// seriesLabels are the sorted labels of a series.
+type seriesLabels []prompb.Label
+
+// buildLabels returns the labels of a series, data point attributes take
+// precedence over the labels derived from the resource. The extra labels
+// (e.g. le or quantile) take precedence over both.
+func buildLabels(resourceLabels map[string]string, attrs pcommon.Map, name string, extra ...prompb.Label) seriesLabels {
+	lbls := make(map[string]string, len(resourceLabels)+attrs.Len()+len(extra)+1)
+	for k, v := range resourceLabels {
+		lbls[k] = v
+	}
+	pointLabels := make(map[string]string, attrs.Len())
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		addLabel(pointLabels, SanitizeLabelName(k), v.AsString())
+		return true
+	})
+	for k, v := range pointLabels {
+		lbls[k] = v
+	}
+	for _, l := range extra {
+		lbls[l.Name] = l.Value
+	}
+	lbls[labels.MetricName] = name
+
+	res := make(seriesLabels, 0, len(lbls))
+	for k, v := range lbls {
+		if v == "" {
+			continue
+		}
+		res = append(res, prompb.Label{Name: k, Value: v})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+func (l seriesLabels) key() string {
+	var sb strings.Builder
+	for _, lbl := range l {
+		sb.WriteString(lbl.Name)
+		sb.WriteByte(0xff)
+		sb.WriteString(lbl.Value)
+		sb.WriteByte(0xff)
+	}
+	return sb.String()
+}
+
+// addLabel adds the label, joining the values of attributes whose names
+// collide after sanitization.
+func addLabel(lbls map[string]string, name, v string) {
+	if existing, ok := lbls[name]; ok {
+		v = existing + ";" + v
+	}
+	lbls[name] = v
+}
+
+func convertExemplars(exemplars pmetric.ExemplarSlice) []prompb.Exemplar {
+	if exemplars.Len() == 0 {
+		return nil
+	}
+	res := make([]prompb.Exemplar, 0, exemplars.Len())
+	for i := 0; i < exemplars.Len(); i++ {
+		e := exemplars.At(i)
+		var lbls []prompb.Label
+		if traceID := e.TraceID(); !traceID.IsEmpty() {
+			lbls = append(lbls, prompb.Label{Name: traceIDLabel, Value: traceID.HexString()})
+		}
+		if spanID := e.SpanID(); !spanID.IsEmpty() {
+			lbls = append(lbls, prompb.Label{Name: spanIDLabel, Value: spanID.HexString()})
+		}
+		e.FilteredAttributes().Range(func(k string, v pcommon.Value) bool {
+			lbls = append(lbls, prompb.Label{Name: SanitizeLabelName(k), Value: v.AsString()})
+			return true
+		})
+		res = append(res, prompb.Exemplar{
+			Labels:    lbls,
+			Value:     exemplarValue(e),
+			Timestamp: toMillis(e.Timestamp()),
+		})
+	}
+	return res
+}
+
+func exemplarValues(exemplars pmetric.ExemplarSlice) []float64 {
+	res := make([]float64, exemplars.Len())
+	for i := range res {
+		res[i] = exemplarValue(exemplars.At(i))
+	}
+	return res
+}
+
+func exemplarValue(e pmetric.Exemplar) float64 {
+	if e.ValueType() == pmetric.ExemplarValueTypeInt {
+		return float64(e.IntValue())
+	}
+	return e.DoubleValue()
+}
+
+// exponentialToFloatHistogram converts an exponential histogram into a native histogram.
+// OTLP bucket index i covers (base^i, base^(i+1)], while native histogram bucket index i
+// covers (base^(i-1), base^i], hence the offsets are shifted by one.
+func exponentialToFloatHistogram(p pmetric.ExponentialHistogramDataPoint) (*model.FloatHistogram, bool) {
+	scale := p.Scale()
+	if scale < minSchema {
+		return nil, false
+	}
+	h := &model.FloatHistogram{
+		Schema:    scale,
+		ZeroCount: float64(p.ZeroCount()),
+		Count:     float64(p.Count()),
+		Sum:       p.Sum(),
+	}
+	h.PositiveSpans, h.PositiveBuckets = exponentialBuckets(p.Positive())
+	h.NegativeSpans, h.NegativeBuckets = exponentialBuckets(p.Negative())
+	if scale > maxSchema {
+		// Adding to an empty histogram of the maximum schema merges the buckets into the lower resolution.
+		h = (&model.FloatHistogram{Schema: maxSchema}).Add(h)
+	}
+	return h, true
+}
+
+func exponentialBuckets(b pmetric.Buckets) ([]model.HistogramSpan, []float64) {
+	counts := b.BucketCounts()
+	if counts.Len() == 0 {
+		return nil, nil
+	}
+	res := make([]float64, counts.Len())
+	for i := range res {
+		res[i] = float64(counts.At(i))
+	}
+	return []model.HistogramSpan{{Offset: b.Offset() + 1, Length: uint32(counts.Len())}}, res
+}
+
+func isDelta(t pmetric.MetricAggregationTemporality) bool {
+	return t == pmetric.MetricAggregationTemporalityDelta
+}
+
+func dataPointCount(m pmetric.Metric) int {
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		return m.Gauge().DataPoints().Len()
+	case pmetric.MetricTypeSum:
+		return m.Sum().DataPoints().Len()
+	case pmetric.MetricTypeHistogram:
+		return m.Histogram().DataPoints().Len()
+	case pmetric.MetricTypeExponentialHistogram:
+		return m.ExponentialHistogram().DataPoints().Len()
+	case pmetric.MetricTypeSummary:
+		return m.Summary().DataPoints().Len()
+	}
+	return 0
+}
+
+func toMillis(ts pcommon.Timestamp) int64 {
+	return int64(ts) / int64(time.Millisecond)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// sanitizeMetricName replaces the characters not allowed in Prometheus metric names with underscores.
+func sanitizeMetricName(name string) string {
+	return sanitize(name, "_", func(r rune) bool { return r == ':' })
+}
+
+// SanitizeLabelName replaces the characters not allowed in Prometheus label names with underscores.
+func SanitizeLabelName(name string) string {
+	return sanitize(name, "key_", func(rune) bool { return false })
+}
+
+// sanitize replaces the invalid characters of the name with underscores and
+// prefixes names starting with a digit with digitPrefix.
+func sanitize(name, digitPrefix string, allowed func(rune) bool) string {
+	if name == "" {
+		return ""
+	}
+	s := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || allowed(r)) {
+			return r
+		}
+		return '_'
+	}, name)
+	if unicode.IsDigit(rune(s[0])) {
+		s = digitPrefix + s
+	}
+	return s
+}
@@ -0,0 +1,251 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package otlp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/prompb"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+var testTime = time.Unix(1000, 0)
+
+func newTestMetrics(resourceAttrs map[string]interface{}) (pmetric.Metrics, pmetric.MetricSlice) {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().FromRaw(resourceAttrs)
+	return md, rm.ScopeMetrics().AppendEmpty().Metrics()
+}
+
+func ts(offset time.Duration) pcommon.Timestamp {
+	return pcommon.NewTimestampFromTime(testTime.Add(offset))
+}
+
+func millis(offset time.Duration) int64 {
+	return testTime.Add(offset).UnixMilli()
+}
+
+func newTestTranslator(t *testing.T, cfg Config) *Translator {
+	translator, err := NewTranslator(cfg)
+	require.NoError(t, err)
+	return translator
+}
+
+func TestTranslateGaugeAndResourceAttributes(t *testing.T) {
+	md, metrics := newTestMetrics(map[string]interface{}{
+		"service.name":        "checkout",
+		"service.namespace":   "shop",
+		"service.instance.id": "pod-1",
+		"k8s.namespace.name":  "prod",
+		"host.name":           "node-1",
+		"cloud.region":        "eu",
+	})
+	m := metrics.AppendEmpty()
+	m.SetName("queue.size")
+	m.SetDescription("Items in queue")
+	m.SetUnit("1")
+	p := m.SetEmptyGauge().DataPoints().AppendEmpty()
+	p.SetTimestamp(ts(0))
+	p.SetIntValue(5)
+	p.Attributes().PutString("queue", "orders")
+	p.Attributes().PutString("host.name", "overridden")
+
+	translator := newTestTranslator(t, Config{ResourceAttributes: "k8s.namespace.name=namespace, host.name"})
+	wr := &prompb.WriteRequest{}
+	stats := translator.ToWriteRequest(md, wr)
+
+	require.Equal(t, Stats{DataPoints: 1}, stats)
+	require.Equal(t, []prompb.TimeSeries{{
+		Labels: []prompb.Label{
+			{Name: "__name__", Value: "queue_size"},
+			{Name: "host_name", Value: "overridden"},
+			{Name: "instance", Value: "pod-1"},
+			{Name: "job", Value: "shop/checkout"},
+			{Name: "namespace", Value: "prod"},
+			{Name: "queue", Value: "orders"},
+		},
+		Samples: []prompb.Sample{{Timestamp: millis(0), Value: 5}},
+	}}, wr.Timeseries)
+	require.Equal(t, []prompb.MetricMetadata{{
+		Type:             prompb.MetricMetadata_GAUGE,
+		MetricFamilyName: "queue_size",
+		Help:             "Items in queue",
+		Unit:             "1",
+	}}, wr.Metadata)
+
+	// Copying all attributes.
+	translator = newTestTranslator(t, Config{ResourceAttributes: "*"})
+	wr = &prompb.WriteRequest{}
+	translator.ToWriteRequest(md, wr)
+	require.Contains(t, wr.Timeseries[0].Labels, prompb.Label{Name: "cloud_region", Value: "eu"})
+	require.Contains(t, wr.Timeseries[0].Labels, prompb.Label{Name: "k8s_namespace_name", Value: "prod"})
+}
+
+func TestTranslateDeltaSum(t *testing.T) {
+	translator := newTestTranslator(t, DefaultConfig)
+
+	deltaRequest := func(offset time.Duration, v float64) pmetric.Metrics {
+		md, metrics := newTestMetrics(nil)
+		m := metrics.AppendEmpty()
+		m.SetName("requests")
+		sum := m.SetEmptySum()
+		sum.SetIsMonotonic(true)
+		sum.SetAggregationTemporality(pmetric.MetricAggregationTemporalityDelta)
+		p := sum.DataPoints().AppendEmpty()
+		p.SetTimestamp(ts(offset))
+		p.SetDoubleValue(v)
+		return md
+	}
+
+	var values []float64
+	for i, delta := range []float64{2, 3, 5} {
+		wr := &prompb.WriteRequest{}
+		translator.ToWriteRequest(deltaRequest(time.Duration(i)*time.Second, delta), wr)
+		require.Len(t, wr.Timeseries, 1)
+		values = append(values, wr.Timeseries[0].Samples[0].Value)
+		require.Equal(t, prompb.MetricMetadata_COUNTER, wr.Metadata[0].Type)
+	}
+	require.Equal(t, []float64{2, 5, 10}, values)
+
+	// A data point which is not newer than the last one would be counted twice.
+	wr := &prompb.WriteRequest{}
+	stats := translator.ToWriteRequest(deltaRequest(time.Second, 1), wr)
+	require.Equal(t, Stats{DroppedDataPoints: 1}, stats)
+	require.Empty(t, wr.Timeseries)
+}
+
+func TestTranslateHistogram(t *testing.T) {
+	md, metrics := newTestMetrics(nil)
+	m := metrics.AppendEmpty()
+	m.SetName("latency")
+	h := m.SetEmptyHistogram()
+	h.SetAggregationTemporality(pmetric.MetricAggregationTemporalityCumulative)
+	p := h.DataPoints().AppendEmpty()
+	p.SetTimestamp(ts(0))
+	p.SetCount(6)
+	p.SetSum(4.2)
+	p.ExplicitBounds().FromRaw([]float64{0.5, 1})
+	p.BucketCounts().FromRaw([]uint64{1, 2, 3})
+	e := p.Exemplars().AppendEmpty()
+	e.SetDoubleValue(0.7)
+	e.SetTimestamp(ts(0))
+	e.SetTraceID(pcommon.TraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}))
+
+	wr := &prompb.WriteRequest{}
+	stats := newTestTranslator(t, DefaultConfig).ToWriteRequest(md, wr)
+	require.Equal(t, Stats{DataPoints: 1}, stats)
+
+	exemplar := prompb.Exemplar{
+		Labels:    []prompb.Label{{Name: "trace_id", Value: "0102030405060708090a0b0c0d0e0f10"}},
+		Value:     0.7,
+		Timestamp: millis(0),
+	}
+	require.Equal(t, []prompb.TimeSeries{
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "latency_bucket"}, {Name: "le", Value: "0.5"}},
+			Samples: []prompb.Sample{{Timestamp: millis(0), Value: 1}},
+		},
+		{
+			Labels:    []prompb.Label{{Name: "__name__", Value: "latency_bucket"}, {Name: "le", Value: "1"}},
+			Samples:   []prompb.Sample{{Timestamp: millis(0), Value: 3}},
+			Exemplars: []prompb.Exemplar{exemplar},
+		},
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "latency_bucket"}, {Name: "le", Value: "+Inf"}},
+			Samples: []prompb.Sample{{Timestamp: millis(0), Value: 6}},
+		},
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "latency_sum"}},
+			Samples: []prompb.Sample{{Timestamp: millis(0), Value: 4.2}},
+		},
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "latency_count"}},
+			Samples: []prompb.Sample{{Timestamp: millis(0), Value: 6}},
+		},
+	}, wr.Timeseries)
+}
+
+func TestTranslateExponentialHistogram(t *testing.T) {
+	md, metrics := newTestMetrics(nil)
+	m := metrics.AppendEmpty()
+	m.SetName("latency")
+	h := m.SetEmptyExponentialHistogram()
+	h.SetAggregationTemporality(pmetric.MetricAggregationTemporalityCumulative)
+	p := h.DataPoints().AppendEmpty()
+	p.SetTimestamp(ts(0))
+	p.SetScale(1)
+	p.SetCount(4)
+	p.SetSum(3)
+	p.SetZeroCount(1)
+	p.Positive().SetOffset(-1)
+	p.Positive().BucketCounts().FromRaw([]uint64{2, 1})
+
+	wr := &prompb.WriteRequest{}
+	newTestTranslator(t, DefaultConfig).ToWriteRequest(md, wr)
+	require.Len(t, wr.Timeseries, 1)
+	require.Equal(t, []prompb.Histogram{{
+		Count:          &prompb.Histogram_CountFloat{CountFloat: 4},
+		Sum:            3,
+		Schema:         1,
+		ZeroCount:      &prompb.Histogram_ZeroCountFloat{ZeroCountFloat: 1},
+		PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: 2}},
+		PositiveCounts: []float64{2, 1},
+		Timestamp:      millis(0),
+	}}, wr.Timeseries[0].Histograms)
+}
+
+func TestTranslateSummaryAndStaleness(t *testing.T) {
+	md, metrics := newTestMetrics(nil)
+	m := metrics.AppendEmpty()
+	m.SetName("rpc.duration")
+	p := m.SetEmptySummary().DataPoints().AppendEmpty()
+	p.SetTimestamp(ts(0))
+	p.SetCount(10)
+	p.SetSum(20)
+	q := p.QuantileValues().AppendEmpty()
+	q.SetQuantile(0.99)
+	q.SetValue(3)
+
+	g := metrics.AppendEmpty()
+	g.SetName("1up")
+	gp := g.SetEmptyGauge().DataPoints().AppendEmpty()
+	gp.SetTimestamp(ts(0))
+	gp.SetFlags(pmetric.DefaultMetricDataPointFlags.WithNoRecordedValue(true))
+
+	wr := &prompb.WriteRequest{}
+	newTestTranslator(t, DefaultConfig).ToWriteRequest(md, wr)
+	require.Len(t, wr.Timeseries, 4)
+	require.Equal(t, []prompb.Label{{Name: "__name__", Value: "rpc_duration"}, {Name: "quantile", Value: "0.99"}}, wr.Timeseries[0].Labels)
+	require.Equal(t, []prompb.Label{{Name: "__name__", Value: "rpc_duration_sum"}}, wr.Timeseries[1].Labels)
+	require.Equal(t, []prompb.Label{{Name: "__name__", Value: "rpc_duration_count"}}, wr.Timeseries[2].Labels)
+	require.Equal(t, float64(10), wr.Timeseries[2].Samples[0].Value)
+
+	require.Equal(t, []prompb.Label{{Name: "__name__", Value: "_1up"}}, wr.Timeseries[3].Labels)
+	require.True(t, value.IsStaleNaN(wr.Timeseries[3].Samples[0].Value))
+	require.True(t, math.IsNaN(wr.Timeseries[3].Samples[0].Value))
+}
+
+func TestParseResourceRules(t *testing.T) {
+	rules, err := parseResourceRules(" *, k8s.pod.name=pod ,host.name")
+	require.NoError(t, err)
+	require.Equal(t, resourceRules{
+		copyAll: true,
+		rules: []resourceRule{
+			{attribute: "k8s.pod.name", label: "pod"},
+			{attribute: "host.name", label: "host_name"},
+		},
+	}, rules)
+
+	_, err = parseResourceRules("=label")
+	require.Error(t, err)
+	_, err = parseResourceRules("attr=")
+	require.Error(t, err)
+}
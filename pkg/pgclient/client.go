@@ -12,6 +12,7 @@ import (
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 
 	"github.com/timescale/promscale/pkg/ha"
@@ -19,8 +20,10 @@ import (
 	"github.com/timescale/promscale/pkg/pgmodel/cache"
 	"github.com/timescale/promscale/pkg/pgmodel/health"
 	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/spool"
 	"github.com/timescale/promscale/pkg/pgmodel/lreader"
 	"github.com/timescale/promscale/pkg/pgmodel/querier"
+	"github.com/timescale/promscale/pkg/pgmodel/rollup"
 	"github.com/timescale/promscale/pkg/pgxconn"
 	"github.com/timescale/promscale/pkg/prompb"
 	"github.com/timescale/promscale/pkg/promql"
@@ -211,6 +214,16 @@ func NewClientWithPool(r prometheus.Registerer, cfg *Config, numCopiers int, wri
 		TracesBatchTimeout:      cfg.TracesBatchTimeout,
 		TracesMaxBatchSize:      cfg.TracesMaxBatchSize,
 		TracesBatchWorkers:      cfg.TracesBatchWorkers,
+		LogsAsyncAcks:           cfg.LogsAsyncAcks,
+		LogsBatchTimeout:        cfg.LogsBatchTimeout,
+		LogsMaxBatchSize:        cfg.LogsMaxBatchSize,
+		SeriesLimits:            cfg.SeriesLimitConfig,
+		MaxSampleAge:            cfg.MaxSampleAge,
+		OutOfOrderWindow:        cfg.OutOfOrderWindow,
+		OutOfBoundsAction:       cfg.OutOfBoundsAction,
+	}
+	if cfg.Dataset != nil {
+		c.OnMetricCreation = cfg.Dataset.ApplyNewMetricOverrides
 	}
 
 	var (
@@ -223,18 +236,29 @@ func NewClientWithPool(r prometheus.Registerer, cfg *Config, numCopiers int, wri
 	exemplarKeyPosCache := cache.NewExemplarLabelsPosCache(cfg.CacheConfig)
 
 	labelsReader := lreader.NewLabelsReader(readerConn, labelsCache, mt.ReadAuthorizer())
-	dbQuerier := querier.NewQuerier(readerConn, metricsCache, labelsReader, exemplarKeyPosCache, mt.ReadAuthorizer())
+	var rollups *rollup.Router
+	if cfg.RollupConfig.QueryRouting {
+		rollups = rollup.NewRouter(readerConn)
+	}
+	dbQuerier := querier.NewQuerier(readerConn, metricsCache, labelsReader, exemplarKeyPosCache, mt.ReadAuthorizer(), rollups)
 	queryable := query.NewQueryable(dbQuerier, labelsReader)
 
 	dbIngestor := ingestor.DBInserter(ingestor.ReadOnlyIngestor{})
 	if !readOnly {
-		var err error
 		writerConn = pgxconn.NewPgxConn(writerPool)
-		dbIngestor, err = ingestor.NewPgxIngestor(writerConn, metricsCache, seriesCache, exemplarKeyPosCache, &c)
+		pgxIngestor, err := ingestor.NewPgxIngestor(writerConn, metricsCache, seriesCache, exemplarKeyPosCache, &c)
 		if err != nil {
 			log.Error("msg", "err starting the ingestor", "err", err)
 			return nil, err
 		}
+		dbIngestor = pgxIngestor
+		if cfg.SpoolConfig.Enabled() {
+			dbIngestor, err = spool.NewInserter(&cfg.SpoolConfig, pgxIngestor, health.NewHealthChecker(writerConn), pgxIngestor.CopierBacklog)
+			if err != nil {
+				log.Error("msg", "err starting the ingestor", "err", err)
+				return nil, err
+			}
+		}
 	}
 	if maintPool != nil {
 		maintConn = pgxconn.NewPgxConn(maintPool)
@@ -316,6 +340,11 @@ func (c *Client) IngestTraces(ctx context.Context, tr ptrace.Traces) error {
 	return c.ingestor.IngestTraces(ctx, tr)
 }
 
+// IngestLogs writes the logs object into the DB.
+func (c *Client) IngestLogs(ctx context.Context, ld plog.Logs) error {
+	return c.ingestor.IngestLogs(ctx, ld)
+}
+
 // Read returns the promQL query results
 func (c *Client) Read(ctx context.Context, req *prompb.ReadRequest) (*prompb.ReadResponse, error) {
 	if req == nil {
@@ -14,16 +14,25 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v4"
+	"github.com/timescale/promscale/pkg/dataset"
 	"github.com/timescale/promscale/pkg/limits"
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgmodel/cache"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/logs"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/serieslimit"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/spool"
 	"github.com/timescale/promscale/pkg/pgmodel/ingestor/trace"
+	"github.com/timescale/promscale/pkg/pgmodel/rollup"
 	"github.com/timescale/promscale/pkg/version"
 )
 
 // Config for the database.
 type Config struct {
 	CacheConfig             cache.Config
+	RollupConfig            rollup.Config
+	SpoolConfig             spool.Config
+	SeriesLimitConfig       serieslimit.Config
 	AppName                 string
 	Host                    string
 	Port                    int
@@ -47,6 +56,15 @@ type Config struct {
 	TracesBatchTimeout      time.Duration
 	TracesMaxBatchSize      int
 	TracesBatchWorkers      int
+	LogsAsyncAcks           bool
+	LogsBatchTimeout        time.Duration
+	LogsMaxBatchSize        int
+	MaxSampleAge            time.Duration
+	OutOfOrderWindow        time.Duration
+	OutOfBoundsAction       string
+	// Dataset is the parsed dataset configuration, if any. Its overrides are
+	// applied to metrics as they are created.
+	Dataset *dataset.Config
 }
 
 const (
@@ -75,6 +93,9 @@ var (
 // ParseFlags parses the configuration flags specific to PostgreSQL and TimescaleDB
 func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
 	cache.ParseFlags(fs, &cfg.CacheConfig)
+	rollup.ParseFlags(fs, &cfg.RollupConfig)
+	spool.ParseFlags(fs, &cfg.SpoolConfig)
+	serieslimit.ParseFlags(fs, &cfg.SeriesLimitConfig)
 
 	fs.StringVar(&cfg.AppName, "db.app", DefaultApp, "This sets the application_name in database connection string. "+
 		"This is helpful during debugging when looking at pg_stat_activity.")
@@ -88,6 +109,10 @@ func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
 	fs.BoolVar(&cfg.IgnoreCompressedChunks, "metrics.ignore-samples-written-to-compressed-chunks", false, "Ignore/drop samples that are being written to compressed chunks. "+
 		"Setting this to false allows Promscale to ingest older data by decompressing chunks that were earlier compressed. "+
 		"However, setting this to true will save your resources that may be required during decompression. ")
+	fs.DurationVar(&cfg.MaxSampleAge, "metrics.max-sample-age", 0, "Maximum age of ingested samples. Older samples are out of bounds. 0 accepts samples of any age.")
+	fs.DurationVar(&cfg.OutOfOrderWindow, "metrics.out-of-order-window", 0, "Samples older than this before the newest sample of their series are out of bounds. 0 accepts out-of-order samples of any age.")
+	fs.StringVar(&cfg.OutOfBoundsAction, "metrics.out-of-bounds-action", ingestor.OutOfBoundsReject, "Action taken on samples out of bounds. Valid values: 'reject' rejects their series with an error naming it, "+
+		"'drop' drops and counts the samples out of bounds and ingests the others.")
 	fs.IntVar(&cfg.WriteConnections, "db.connections.num-writers", 0, "Number of database connections for writing metrics/traces to database. "+
 		"By default, this will be set based on the number of CPUs available to the DB Promscale is connected to.")
 	fs.IntVar(&cfg.WriterPoolSize, "db.connections.writer-pool.size", defaultPoolSize, "Maximum size of the writer pool of database connections. This defaults to 50% of max_connections "+
@@ -107,6 +132,9 @@ func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
 	fs.IntVar(&cfg.TracesMaxBatchSize, "tracing.max-batch-size", trace.DefaultBatchSize, "Maximum size of trace batch that is written to DB")
 	fs.DurationVar(&cfg.TracesBatchTimeout, "tracing.batch-timeout", trace.DefaultBatchTimeout, "Timeout after new trace batch is created")
 	fs.IntVar(&cfg.TracesBatchWorkers, "tracing.batch-workers", trace.DefaultBatchWorkers, "Number of workers responsible for creating trace batches. Defaults to number of CPUs.")
+	fs.BoolVar(&cfg.LogsAsyncAcks, "logs.async-acks", true, "Acknowledge asynchronous inserts. If this is true, the inserter will not wait after insertion of log records in the database. This increases throughput at the cost of a small chance of data loss.")
+	fs.IntVar(&cfg.LogsMaxBatchSize, "logs.max-batch-size", logs.DefaultBatchSize, "Maximum number of log records in a batch that is written to DB")
+	fs.DurationVar(&cfg.LogsBatchTimeout, "logs.batch-timeout", logs.DefaultBatchTimeout, "Timeout after new log batch is created")
 	return cfg
 }
 
@@ -114,9 +142,37 @@ func Validate(cfg *Config, lcfg limits.Config) error {
 	if err := cfg.validateConnectionSettings(); err != nil {
 		return err
 	}
+	if err := cfg.validateSampleBounds(); err != nil {
+		return err
+	}
+	if err := rollup.Validate(&cfg.RollupConfig); err != nil {
+		return err
+	}
+	if err := spool.Validate(&cfg.SpoolConfig); err != nil {
+		return err
+	}
+	if err := serieslimit.Validate(&cfg.SeriesLimitConfig); err != nil {
+		return err
+	}
 	return cache.Validate(&cfg.CacheConfig, lcfg)
 }
 
+// validateSampleBounds checks the maximum sample age, the out-of-order window
+// and the action taken on samples out of bounds.
+func (cfg Config) validateSampleBounds() error {
+	if cfg.MaxSampleAge < 0 {
+		return fmt.Errorf("metrics.max-sample-age must not be negative: %s", cfg.MaxSampleAge)
+	}
+	if cfg.OutOfOrderWindow < 0 {
+		return fmt.Errorf("metrics.out-of-order-window must not be negative: %s", cfg.OutOfOrderWindow)
+	}
+	switch cfg.OutOfBoundsAction {
+	case ingestor.OutOfBoundsReject, ingestor.OutOfBoundsDrop:
+		return nil
+	}
+	return fmt.Errorf("invalid metrics.out-of-bounds-action %q: must be one of 'reject' or 'drop'", cfg.OutOfBoundsAction)
+}
+
 // validateConnectionSettings checks that we are not using both a DB URI and
 // DB configuration flags
 func (cfg Config) validateConnectionSettings() error {
@@ -0,0 +1,290 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package cardinality
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultLimit is the number of entries returned by each statistic when the
+	// query does not set a limit.
+	DefaultLimit = 10
+	// MaxLimit is the maximum number of entries returned by each statistic.
+	MaxLimit = 10000
+)
+
+// Query selects the series the statistics are computed on.
+type Query struct {
+	Limit int
+	// Tenants restricts the statistics to the series of the tenants, if
+	// Restricted is set.
+	Tenants    []string
+	Restricted bool
+	// Start and End select the hours of the series creation statistics.
+	Start time.Time
+	End   time.Time
+}
+
+// Stat is an entry of a statistic.
+type Stat struct {
+	Name  string `json:"name"`
+	Value uint64 `json:"value"`
+}
+
+// HeadStats has the format of the head statistics of Prometheus. Promscale has
+// no head block, hence only the number of series and label pairs are set.
+type HeadStats struct {
+	NumSeries     uint64 `json:"numSeries"`
+	NumLabelPairs uint64 `json:"numLabelPairs"`
+	ChunkCount    int64  `json:"chunkCount"`
+	MinTime       int64  `json:"minTime"`
+	MaxTime       int64  `json:"maxTime"`
+}
+
+// TSDBStatus has the format of the Prometheus /api/v1/status/tsdb response.
+type TSDBStatus struct {
+	HeadStats                   HeadStats `json:"headStats"`
+	SeriesCountByMetricName     []Stat    `json:"seriesCountByMetricName"`
+	LabelValueCountByLabelName  []Stat    `json:"labelValueCountByLabelName"`
+	MemoryInBytesByLabelName    []Stat    `json:"memoryInBytesByLabelName"`
+	SeriesCountByLabelValuePair []Stat    `json:"seriesCountByLabelValuePair"`
+}
+
+// SeriesCreated is the number of series of a metric created during an hour.
+type SeriesCreated struct {
+	Time   time.Time `json:"time"`
+	Metric string    `json:"metric"`
+	Value  uint64    `json:"value"`
+}
+
+// Cardinality are the statistics of the cardinality API.
+type Cardinality struct {
+	NumSeries                  uint64          `json:"numSeries"`
+	SeriesCountByMetricName    []Stat          `json:"seriesCountByMetricName"`
+	LabelValueCountByLabelName []Stat          `json:"labelValueCountByLabelName"`
+	SeriesCountByTenant        []Stat          `json:"seriesCountByTenant"`
+	SeriesCreatedByHour        []SeriesCreated `json:"seriesCreatedByHour"`
+}
+
+const (
+	sqlNumSeries = `SELECT count(*) FROM _prom_catalog.series s WHERE s.delete_epoch IS NULL%s`
+
+	sqlNumLabelPairs = `SELECT count(*) FROM _prom_catalog.label l%s`
+
+	sqlSeriesCountByMetricName = `
+SELECT m.metric_name, count(*)
+FROM _prom_catalog.series s
+JOIN _prom_catalog.metric m ON m.id = s.metric_id
+WHERE s.delete_epoch IS NULL%s
+GROUP BY m.metric_name
+ORDER BY count(*) DESC, m.metric_name
+LIMIT $1`
+
+	sqlLabelValueCountByLabelName = `
+SELECT l.key, count(*)
+FROM _prom_catalog.label l%s
+GROUP BY l.key
+ORDER BY count(*) DESC, l.key
+LIMIT $1`
+
+	sqlMemoryInBytesByLabelName = `
+SELECT l.key, sum(octet_length(l.value))
+FROM _prom_catalog.label l%s
+GROUP BY l.key
+ORDER BY sum(octet_length(l.value)) DESC, l.key
+LIMIT $1`
+
+	sqlSeriesCountByLabelValuePair = `
+SELECT l.key || '=' || l.value, count(*)
+FROM _prom_catalog.series s
+CROSS JOIN LATERAL unnest(s.labels) AS lid(id)
+JOIN _prom_catalog.label l ON l.id = lid.id
+WHERE s.delete_epoch IS NULL%s
+GROUP BY l.key, l.value
+ORDER BY count(*) DESC, l.key, l.value
+LIMIT $1`
+
+	sqlSeriesCountByTenant = `
+SELECT l.value, (
+	SELECT count(*)
+	FROM _prom_catalog.series s
+	WHERE s.labels @> array[l.id]::int[] AND s.delete_epoch IS NULL
+)
+FROM _prom_catalog.label l
+WHERE l.key = '__tenant__'%s
+ORDER BY 2 DESC, l.value
+LIMIT $1`
+
+	// The series created during an hour have an id between the watermark of
+	// the hour and the next one.
+	sqlSeriesCreatedByHour = `
+SELECT c.hour, c.metric_name, c.series
+FROM (
+	SELECT
+		date_trunc('hour', w.prev_time) AS hour,
+		m.metric_name,
+		count(*) AS series,
+		row_number() OVER (PARTITION BY date_trunc('hour', w.prev_time) ORDER BY count(*) DESC, m.metric_name) AS rank
+	FROM (
+		SELECT
+			series_id,
+			lag(time) OVER (ORDER BY time) AS prev_time,
+			lag(series_id) OVER (ORDER BY time) AS prev_series_id
+		FROM _prom_catalog.series_id_watermark
+	) w
+	JOIN _prom_catalog.series s ON s.id > w.prev_series_id AND s.id <= w.series_id
+	JOIN _prom_catalog.metric m ON m.id = s.metric_id
+	WHERE w.prev_time >= date_trunc('hour', $2::timestamptz) AND w.prev_time <= $3 AND s.delete_epoch IS NULL%s
+	GROUP BY 1, 2
+) c
+WHERE c.rank <= $1
+ORDER BY c.hour, c.series DESC, c.metric_name`
+
+	// seriesTenantFilter restricts the series s to the tenants.
+	seriesTenantFilter = `
+	AND s.labels && (
+		SELECT coalesce(array_agg(id), '{}')
+		FROM _prom_catalog.label
+		WHERE key = '__tenant__' AND value = ANY($%d::text[])
+	)::int[]`
+	// labelTenantFilter restricts the labels l to the ones of the series of the tenants.
+	labelTenantFilter = `
+WHERE l.id IN (
+	SELECT unnest(s.labels)
+	FROM _prom_catalog.series s
+	WHERE s.delete_epoch IS NULL` + seriesTenantFilter + `
+)`
+	tenantLabelFilter = ` AND l.value = ANY($%d::text[])`
+)
+
+// filter returns the filter restricting a query to the tenants of q, and the
+// arguments of the query.
+func filter(q Query, format string, args ...interface{}) (string, []interface{}) {
+	if !q.Restricted {
+		return "", args
+	}
+	args = append(args, q.Tenants)
+	return fmt.Sprintf(format, len(args)), args
+}
+
+// getTSDBStatus returns the cardinality statistics in the format of the Prometheus
+// TSDB status.
+func getTSDBStatus(ctx context.Context, conn querier, q Query) (*TSDBStatus, error) {
+	status := &TSDBStatus{
+		SeriesCountByMetricName:     []Stat{},
+		LabelValueCountByLabelName:  []Stat{},
+		MemoryInBytesByLabelName:    []Stat{},
+		SeriesCountByLabelValuePair: []Stat{},
+	}
+	if q.Restricted && len(q.Tenants) == 0 {
+		return status, nil
+	}
+	var err error
+	if status.HeadStats.NumSeries, err = count(ctx, conn, q, sqlNumSeries, seriesTenantFilter); err != nil {
+		return nil, fmt.Errorf("count series: %w", err)
+	}
+	if status.HeadStats.NumLabelPairs, err = count(ctx, conn, q, sqlNumLabelPairs, labelTenantFilter); err != nil {
+		return nil, fmt.Errorf("count label pairs: %w", err)
+	}
+	if status.SeriesCountByMetricName, err = stats(ctx, conn, q, sqlSeriesCountByMetricName, seriesTenantFilter); err != nil {
+		return nil, fmt.Errorf("series count by metric name: %w", err)
+	}
+	if status.LabelValueCountByLabelName, err = stats(ctx, conn, q, sqlLabelValueCountByLabelName, labelTenantFilter); err != nil {
+		return nil, fmt.Errorf("label value count by label name: %w", err)
+	}
+	if status.MemoryInBytesByLabelName, err = stats(ctx, conn, q, sqlMemoryInBytesByLabelName, labelTenantFilter); err != nil {
+		return nil, fmt.Errorf("memory in bytes by label name: %w", err)
+	}
+	if status.SeriesCountByLabelValuePair, err = stats(ctx, conn, q, sqlSeriesCountByLabelValuePair, seriesTenantFilter); err != nil {
+		return nil, fmt.Errorf("series count by label value pair: %w", err)
+	}
+	return status, nil
+}
+
+// getCardinality returns the cardinality statistics of the series, along with
+// the number of series created per hour and metric.
+func getCardinality(ctx context.Context, conn querier, q Query) (*Cardinality, error) {
+	c := &Cardinality{
+		SeriesCountByMetricName:    []Stat{},
+		LabelValueCountByLabelName: []Stat{},
+		SeriesCountByTenant:        []Stat{},
+		SeriesCreatedByHour:        []SeriesCreated{},
+	}
+	if q.Restricted && len(q.Tenants) == 0 {
+		return c, nil
+	}
+	var err error
+	if c.NumSeries, err = count(ctx, conn, q, sqlNumSeries, seriesTenantFilter); err != nil {
+		return nil, fmt.Errorf("count series: %w", err)
+	}
+	if c.SeriesCountByMetricName, err = stats(ctx, conn, q, sqlSeriesCountByMetricName, seriesTenantFilter); err != nil {
+		return nil, fmt.Errorf("series count by metric name: %w", err)
+	}
+	if c.LabelValueCountByLabelName, err = stats(ctx, conn, q, sqlLabelValueCountByLabelName, labelTenantFilter); err != nil {
+		return nil, fmt.Errorf("label value count by label name: %w", err)
+	}
+	if c.SeriesCountByTenant, err = stats(ctx, conn, q, sqlSeriesCountByTenant, tenantLabelFilter); err != nil {
+		return nil, fmt.Errorf("series count by tenant: %w", err)
+	}
+	if c.SeriesCreatedByHour, err = seriesCreated(ctx, conn, q); err != nil {
+		return nil, fmt.Errorf("series created by hour: %w", err)
+	}
+	return c, nil
+}
+
+func count(ctx context.Context, conn querier, q Query, sql, tenantFilter string) (uint64, error) {
+	f, args := filter(q, tenantFilter)
+	var n int64
+	if err := conn.QueryRow(ctx, fmt.Sprintf(sql, f), args...).Scan(&n); err != nil {
+		return 0, err
+	}
+	return uint64(n), nil
+}
+
+func stats(ctx context.Context, conn querier, q Query, sql, tenantFilter string) ([]Stat, error) {
+	f, args := filter(q, tenantFilter, q.Limit)
+	rows, err := conn.Query(ctx, fmt.Sprintf(sql, f), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []Stat{}
+	for rows.Next() {
+		var (
+			name  string
+			value int64
+		)
+		if err = rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		res = append(res, Stat{Name: name, Value: uint64(value)})
+	}
+	return res, rows.Err()
+}
+
+func seriesCreated(ctx context.Context, conn querier, q Query) ([]SeriesCreated, error) {
+	f, args := filter(q, seriesTenantFilter, q.Limit, q.Start, q.End)
+	rows, err := conn.Query(ctx, fmt.Sprintf(sqlSeriesCreatedByHour, f), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []SeriesCreated{}
+	for rows.Next() {
+		var (
+			s     SeriesCreated
+			value int64
+		)
+		if err = rows.Scan(&s.Time, &s.Metric, &value); err != nil {
+			return nil, err
+		}
+		s.Value = uint64(value)
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,55 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package cardinality
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+)
+
+func TestFilter(t *testing.T) {
+	f, args := filter(Query{Limit: 10}, seriesTenantFilter, 10)
+	require.Empty(t, f)
+	require.Equal(t, []interface{}{10}, args)
+
+	start, end := time.Unix(100, 0), time.Unix(200, 0)
+	q := Query{Limit: 10, Tenants: []string{"a"}, Restricted: true, Start: start, End: end}
+	f, args = filter(q, seriesTenantFilter, q.Limit, q.Start, q.End)
+	require.Equal(t, `
+	AND s.labels && (
+		SELECT coalesce(array_agg(id), '{}')
+		FROM _prom_catalog.label
+		WHERE key = '__tenant__' AND value = ANY($4::text[])
+	)::int[]`, f)
+	require.Equal(t, []interface{}{10, start, end, []string{"a"}}, args)
+
+	f, args = filter(q, tenantLabelFilter, q.Limit)
+	require.Equal(t, ` AND l.value = ANY($2::text[])`, f)
+	require.Equal(t, []interface{}{10, []string{"a"}}, args)
+}
+
+func TestReaderCache(t *testing.T) {
+	setTimeout := model.SqlQuery{Sql: sqlSetStatementTimeout, Args: []interface{}{"60000ms"}}
+	r := NewReader(model.NewSqlRecorder([]model.SqlQuery{setTimeout, setTimeout}, t))
+	now := time.Unix(1000, 0)
+	r.now = func() time.Time { return now }
+
+	// The statistics of no tenant are empty, only the statement timeout is set.
+	q := Query{Limit: DefaultLimit, Restricted: true, End: now}
+	for i := 0; i < 2; i++ {
+		status, err := r.TSDBStatus(context.Background(), q)
+		require.NoError(t, err)
+		require.Zero(t, status.HeadStats.NumSeries)
+	}
+	now = now.Add(cacheTTL)
+	_, err := r.TSDBStatus(context.Background(), q)
+	require.NoError(t, err)
+	require.Len(t, r.cache, 1)
+}
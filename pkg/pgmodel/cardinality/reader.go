@@ -0,0 +1,124 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package cardinality
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+const (
+	// cacheTTL is how long the statistics are served from the cache. They scan
+	// all series, so dashboards polling them must not recompute them each time.
+	cacheTTL = 30 * time.Second
+	// statementTimeout bounds each query of the statistics.
+	statementTimeout = time.Minute
+
+	sqlSetStatementTimeout = `SELECT set_config('statement_timeout', $1, true)`
+)
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// Reader computes the cardinality statistics, each query with a statement
+// timeout, and caches them for a short time.
+type Reader struct {
+	conn pgxconn.PgxConn
+	now  func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// NewReader creates a new Reader.
+func NewReader(conn pgxconn.PgxConn) *Reader {
+	return &Reader{
+		conn:  conn,
+		now:   time.Now,
+		cache: make(map[string]cacheEntry),
+	}
+}
+
+// TSDBStatus returns the cardinality statistics in the format of the Prometheus
+// TSDB status.
+func (r *Reader) TSDBStatus(ctx context.Context, q Query) (*TSDBStatus, error) {
+	v, err := r.get(ctx, "tsdb", q, func(conn querier) (interface{}, error) {
+		return getTSDBStatus(ctx, conn, q)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*TSDBStatus), nil
+}
+
+// Cardinality returns the cardinality statistics of the series, along with the
+// number of series created per hour and metric.
+func (r *Reader) Cardinality(ctx context.Context, q Query) (*Cardinality, error) {
+	v, err := r.get(ctx, "cardinality", q, func(conn querier) (interface{}, error) {
+		return getCardinality(ctx, conn, q)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Cardinality), nil
+}
+
+// get returns the cached statistics of the query, or computes them in a
+// transaction with a statement timeout.
+func (r *Reader) get(ctx context.Context, kind string, q Query, compute func(querier) (interface{}, error)) (interface{}, error) {
+	key := cacheKey(kind, q)
+	now := r.now()
+	r.mu.Lock()
+	e, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.value, nil
+	}
+
+	tx, err := r.conn.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if _, err = tx.Exec(ctx, sqlSetStatementTimeout, fmt.Sprintf("%dms", statementTimeout.Milliseconds())); err != nil {
+		return nil, fmt.Errorf("set statement timeout: %w", err)
+	}
+	v, err := compute(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, e := range r.cache {
+		if !now.Before(e.expires) {
+			delete(r.cache, k)
+		}
+	}
+	r.cache[key] = cacheEntry{value: v, expires: now.Add(cacheTTL)}
+	return v, nil
+}
+
+// cacheKey identifies the statistics of a query. The times are truncated to
+// the cache TTL, since they default to the current time.
+func cacheKey(kind string, q Query) string {
+	return fmt.Sprintf("%s/%d/%t/%q/%d/%d", kind, q.Limit, q.Restricted, q.Tenants,
+		q.Start.Truncate(cacheTTL).Unix(), q.End.Truncate(cacheTTL).Unix())
+}
+
+// querier is the subset of the connection and transaction methods the
+// statistics use.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
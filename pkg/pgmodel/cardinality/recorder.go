@@ -0,0 +1,63 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package cardinality
+
+import (
+	"context"
+	"time"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+const (
+	// Connectors record the same watermark at the start of each hour, the first
+	// one wins.
+	sqlRecordWatermark = `
+INSERT INTO _prom_catalog.series_id_watermark(time, series_id)
+SELECT date_trunc('hour', now()), coalesce(max(id), 0) FROM _prom_catalog.series
+ON CONFLICT (time) DO NOTHING`
+	sqlDeleteWatermarks = `DELETE FROM _prom_catalog.series_id_watermark WHERE time < now() - $1::interval`
+
+	// WatermarkRetention is how long the series creation statistics are kept.
+	WatermarkRetention = 30 * 24 * time.Hour
+)
+
+// Recorder records the highest series id at the start of each hour, from which
+// the number of series created per hour is computed.
+type Recorder struct {
+	conn pgxconn.PgxConn
+}
+
+// NewRecorder creates a new Recorder.
+func NewRecorder(conn pgxconn.PgxConn) *Recorder {
+	return &Recorder{conn: conn}
+}
+
+// Run records a watermark on start and at the start of each hour until the
+// context is cancelled. It blocks until then.
+func (r *Recorder) Run(ctx context.Context) error {
+	for {
+		if err := r.record(ctx); err != nil && ctx.Err() == nil {
+			log.Error("msg", "failed to record series id watermark", "err", err)
+		}
+		now := time.Now()
+		timer := time.NewTimer(now.Truncate(time.Hour).Add(time.Hour).Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *Recorder) record(ctx context.Context) error {
+	if _, err := r.conn.Exec(ctx, sqlRecordWatermark); err != nil {
+		return err
+	}
+	_, err := r.conn.Exec(ctx, sqlDeleteWatermarks, WatermarkRetention)
+	return err
+}
@@ -7,7 +7,10 @@ package schema
 const (
 	PromData         = "prom_data"
 	PromDataExemplar = "prom_data_exemplar"
-	PromExt          = "_prom_ext"
+	// PromDataHistogram holds the native histograms of all metrics.
+	PromDataHistogram = "prom_data_histogram"
+	HistogramTable    = "sample"
+	PromExt           = "_prom_ext"
 	// Public is where all timescaledb-functions are loaded
 	Public = "public"
 
@@ -15,9 +18,12 @@ const (
 
 	PromDataSeries = "prom_data_series"
 	PsTrace        = "_ps_trace"
+	PsLog          = "_ps_log"
 )
 
 var (
-	PromDataColumns     = []string{"time", "value", "series_id"}
-	PromExemplarColumns = []string{"time", "series_id", "exemplar_label_values", "value"}
+	PromDataColumns      = []string{"time", "value", "series_id"}
+	PromExemplarColumns  = []string{"time", "series_id", "exemplar_label_values", "value"}
+	PromHistogramColumns = []string{"time", "series_id", "metric_id", "count", "sum", "schema", "zero_threshold", "zero_count",
+		"positive_spans", "positive_buckets", "negative_spans", "negative_buckets", "reset_hint"}
 )
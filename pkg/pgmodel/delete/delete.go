@@ -9,56 +9,79 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v4"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/pgmodel/querier"
+	"github.com/timescale/promscale/pkg/pgmodel/querier/clauses"
 	"github.com/timescale/promscale/pkg/pgxconn"
 )
 
-const queryDeleteSeries = "SELECT _prom_catalog.delete_series_from_metric($1, $2)"
+const (
+	queryDeleteSeries        = "SELECT _prom_catalog.delete_series_and_histograms_from_metric($1, $2)"
+	queryDeleteSeriesInRange = "SELECT _prom_catalog.delete_series_from_metric_in_range($1, $2, $3, $4)"
+)
 
 // PgDelete deletes the series based on matchers.
 type PgDelete struct {
 	Conn pgxconn.PgxConn
 }
 
-// DeleteSeries deletes the series that matches the provided label_matchers.
-func (pgDel *PgDelete) DeleteSeries(ctx context.Context, matchers []*labels.Matcher, _, _ time.Time) ([]string, []model.SeriesID, int, error) {
+// DeleteSeries deletes the data of the series that match the provided label_matchers.
+// If the time range covers all time, the series are deleted altogether. Otherwise only
+// their samples, exemplars and native histograms within [start, end] are deleted,
+// including those in compressed chunks. It returns the touched metrics, the matched
+// series IDs and the number of rows deleted per metric.
+func (pgDel *PgDelete) DeleteSeries(ctx context.Context, matchers []*labels.Matcher, start, end time.Time) ([]string, []model.SeriesID, map[string]int, error) {
 	var (
 		deletedSeriesIDs []model.SeriesID
-		totalRowsDeleted int
 		err              error
 		metricsTouched   = make(map[string]struct{})
+		rowsDeleted      = make(map[string]int)
 	)
 	metricNames, seriesIDMatrix, err := getMetricNameSeriesIDFromMatchers(ctx, pgDel.Conn, matchers)
 	if err != nil {
-		return nil, nil, -1, fmt.Errorf("delete-series: %w", err)
+		return nil, nil, nil, fmt.Errorf("delete-series: %w", err)
 	}
 	for metricIndex, metricName := range metricNames {
 		seriesIDs := seriesIDMatrix[metricIndex]
-		var rowsDeleted int
-		if err = pgDel.Conn.QueryRow(
-			ctx,
-			queryDeleteSeries,
-			metricName,
-			convertSeriesIDsToInt64s(seriesIDs),
-		).Scan(&rowsDeleted); err != nil {
-			return getKeys(metricsTouched), deletedSeriesIDs, totalRowsDeleted, fmt.Errorf("deleting series with metric_name=%s and series_ids=%v : %w", metricName, seriesIDs, err)
+		metricRowsDeleted, err := deleteMetricSeries(ctx, pgDel.Conn, metricName, seriesIDs, start, end)
+		if err != nil {
+			return getKeys(metricsTouched), deletedSeriesIDs, rowsDeleted, err
 		}
 		if _, ok := metricsTouched[metricName]; !ok {
 			metricsTouched[metricName] = struct{}{}
 		}
 		deletedSeriesIDs = append(deletedSeriesIDs, seriesIDs...)
-		totalRowsDeleted += rowsDeleted
+		rowsDeleted[metricName] += metricRowsDeleted
+	}
+	return getKeys(metricsTouched), deletedSeriesIDs, rowsDeleted, nil
+}
+
+// deleteMetricSeries deletes the data of the given series of a metric within [start, end],
+// or the series altogether if the time range covers all time. It returns the number of
+// rows deleted.
+func deleteMetricSeries(ctx context.Context, conn pgxconn.PgxConn, metricName string, seriesIDs []model.SeriesID, start, end time.Time) (int, error) {
+	var (
+		rowsDeleted int
+		row         pgx.Row
+	)
+	if !start.After(model.MinTime) && !end.Before(model.MaxTime) {
+		row = conn.QueryRow(ctx, queryDeleteSeries, metricName, convertSeriesIDsToInt64s(seriesIDs))
+	} else {
+		row = conn.QueryRow(ctx, queryDeleteSeriesInRange, metricName, convertSeriesIDsToInt64s(seriesIDs), start, end)
+	}
+	if err := row.Scan(&rowsDeleted); err != nil {
+		return 0, fmt.Errorf("deleting series with metric_name=%s and series_ids=%v : %w", metricName, seriesIDs, err)
 	}
-	return getKeys(metricsTouched), deletedSeriesIDs, totalRowsDeleted, nil
+	return rowsDeleted, nil
 }
 
 // getMetricNameSeriesIDFromMatchers returns the metric name list and the corresponding series ID array
 // as a matrix.
 func getMetricNameSeriesIDFromMatchers(ctx context.Context, conn pgxconn.PgxConn, matchers []*labels.Matcher) ([]string, [][]model.SeriesID, error) {
-	cb, err := querier.BuildSubQueries(matchers)
+	cb, err := clauses.BuildSubQueries(matchers)
 	if err != nil {
 		return nil, nil, fmt.Errorf("delete series build subqueries: %w", err)
 	}
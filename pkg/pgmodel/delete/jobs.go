@@ -0,0 +1,444 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package delete
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/common/errors"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+// JobStatus is the status of an asynchronous delete job.
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+const (
+	// DefaultListLimit is the number of jobs returned by List when no limit is given.
+	DefaultListLimit = 100
+
+	defaultPollInterval      = 10 * time.Second
+	defaultHeartbeatInterval = 30 * time.Second
+	// A running job whose heartbeat is older than this is considered abandoned,
+	// e.g. because its connector was restarted, and is resumed by any connector.
+	defaultStaleAfter = 2 * time.Minute
+
+	jobColumns = "id, matchers, start_time, end_time, status, cancel_requested, metrics_total, metrics_done, " +
+		"series_deleted, rows_deleted, error, created_at, started_at, finished_at"
+
+	querySubmitJob = "INSERT INTO _prom_catalog.delete_job (matchers, start_time, end_time) VALUES ($1, $2, $3) RETURNING id"
+	queryGetJob    = "SELECT " + jobColumns + " FROM _prom_catalog.delete_job WHERE id = $1"
+	queryListJobs  = "SELECT " + jobColumns + " FROM _prom_catalog.delete_job ORDER BY id DESC LIMIT $1"
+	// Pending jobs are cancelled right away. Running jobs are stopped by the connector
+	// executing them, which notices the request at its next heartbeat or progress update.
+	queryCancelJob = `UPDATE _prom_catalog.delete_job SET
+	cancel_requested = true,
+	status = CASE WHEN status = 'pending' THEN 'cancelled' ELSE status END,
+	finished_at = CASE WHEN status = 'pending' THEN now() ELSE finished_at END
+WHERE id = $1 AND status IN ('pending', 'running')
+RETURNING ` + jobColumns
+	queryClaimJob = `UPDATE _prom_catalog.delete_job SET status = 'running', started_at = coalesce(started_at, now()), heartbeat = now()
+WHERE id = (
+	SELECT id FROM _prom_catalog.delete_job
+	WHERE status = 'pending' OR (status = 'running' AND heartbeat < now() - make_interval(secs => $1))
+	ORDER BY id
+	LIMIT 1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING id, matchers, start_time, end_time, metrics_pending`
+	queryJobHeartbeat = "UPDATE _prom_catalog.delete_job SET heartbeat = now() WHERE id = $1 RETURNING cancel_requested"
+	queryJobMetrics   = "UPDATE _prom_catalog.delete_job SET metrics_total = $2, metrics_pending = $3, heartbeat = now() WHERE id = $1 RETURNING cancel_requested"
+	queryJobProgress  = `UPDATE _prom_catalog.delete_job SET
+	metrics_done = metrics_done + 1,
+	metrics_pending = array_remove(metrics_pending, $4),
+	series_deleted = series_deleted + $2,
+	rows_deleted = rows_deleted + $3,
+	heartbeat = now()
+WHERE id = $1
+RETURNING cancel_requested`
+	queryFinishJob = "UPDATE _prom_catalog.delete_job SET status = $2, error = $3, finished_at = now(), heartbeat = now() WHERE id = $1"
+)
+
+var errJobCancelled = fmt.Errorf("delete job cancelled")
+
+// Job describes an asynchronous delete job and its progress.
+type Job struct {
+	ID              int64      `json:"id"`
+	Status          JobStatus  `json:"status"`
+	Matchers        []string   `json:"matchers"`
+	Start           *time.Time `json:"start,omitempty"`
+	End             *time.Time `json:"end,omitempty"`
+	CancelRequested bool       `json:"cancel_requested"`
+	MetricsTotal    int        `json:"metrics_total"`
+	MetricsDone     int        `json:"metrics_done"`
+	SeriesDeleted   int64      `json:"series_deleted"`
+	RowsDeleted     int64      `json:"rows_deleted"`
+	Error           string     `json:"error,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+}
+
+// JobManager persists asynchronous delete jobs in the database and executes them
+// in the background. Since the job state lives in the database, jobs survive
+// connector restarts and can be executed by any connector of the deployment.
+type JobManager struct {
+	conn              pgxconn.PgxConn
+	wake              chan struct{}
+	pollInterval      time.Duration
+	heartbeatInterval time.Duration
+	staleAfter        time.Duration
+	onDelete          func()
+}
+
+// NewJobManager creates a new JobManager.
+func NewJobManager(conn pgxconn.PgxConn) *JobManager {
+	return &JobManager{
+		conn:              conn,
+		wake:              make(chan struct{}, 1),
+		pollInterval:      defaultPollInterval,
+		heartbeatInterval: defaultHeartbeatInterval,
+		staleAfter:        defaultStaleAfter,
+	}
+}
+
+// OnDelete sets a function called whenever a job has deleted data. It must be
+// set before the manager runs.
+func (m *JobManager) OnDelete(f func()) {
+	m.onDelete = f
+}
+
+// Submit creates a job deleting the data of the series matching any of the
+// matchers within [start, end], and returns its ID.
+func (m *JobManager) Submit(ctx context.Context, matchers []string, start, end time.Time) (int64, error) {
+	var id int64
+	if err := m.conn.QueryRow(ctx, querySubmitJob, matchers, boundOrNil(start), boundOrNil(end)).Scan(&id); err != nil {
+		return 0, fmt.Errorf("submit delete job: %w", err)
+	}
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+	return id, nil
+}
+
+// Get returns the job with the given ID.
+func (m *JobManager) Get(ctx context.Context, id int64) (Job, error) {
+	job, err := scanJob(m.conn.QueryRow(ctx, queryGetJob, id))
+	if err == pgx.ErrNoRows {
+		return Job{}, errors.ErrDeleteJobNotFound
+	}
+	if err != nil {
+		return Job{}, fmt.Errorf("get delete job: %w", err)
+	}
+	return job, nil
+}
+
+// List returns the most recent jobs, newest first.
+func (m *JobManager) List(ctx context.Context, limit int) ([]Job, error) {
+	rows, err := m.conn.Query(ctx, queryListJobs, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list delete jobs: %w", err)
+	}
+	defer rows.Close()
+	jobs := make([]Job, 0)
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list delete jobs: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// Cancel cancels the job with the given ID. A pending job is cancelled immediately,
+// a running job stops before deleting the data of the next metric. Data already
+// deleted by the job is not restored.
+func (m *JobManager) Cancel(ctx context.Context, id int64) (Job, error) {
+	job, err := scanJob(m.conn.QueryRow(ctx, queryCancelJob, id))
+	if err == pgx.ErrNoRows {
+		// Either the job does not exist or it has already finished.
+		if _, err = m.Get(ctx, id); err != nil {
+			return Job{}, err
+		}
+		return Job{}, errors.ErrDeleteJobFinished
+	}
+	if err != nil {
+		return Job{}, fmt.Errorf("cancel delete job: %w", err)
+	}
+	return job, nil
+}
+
+// Run executes the pending jobs, as well as the running jobs abandoned by other
+// connectors, until the context is cancelled. It blocks until then.
+func (m *JobManager) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+	for {
+		for m.runNextJob(ctx) {
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-m.wake:
+		}
+	}
+}
+
+type claimedJob struct {
+	id         int64
+	matchers   []string
+	start, end time.Time
+	// pending are the metrics left to process by a resumed job, nil if the
+	// job did not run yet.
+	pending []string
+}
+
+// runNextJob claims a job and executes it. It returns false if there was no job to run.
+func (m *JobManager) runNextJob(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var (
+		job        claimedJob
+		start, end pgtype.Timestamptz
+	)
+	err := m.conn.QueryRow(ctx, queryClaimJob, m.staleAfter.Seconds()).Scan(&job.id, &job.matchers, &start, &end, &job.pending)
+	if err == pgx.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error("msg", "failed to claim a delete job", "err", err)
+		}
+		return false
+	}
+	job.start = boundOrDefault(start, model.MinTime)
+	job.end = boundOrDefault(end, model.MaxTime)
+	m.runJob(ctx, job)
+	return true
+}
+
+func (m *JobManager) runJob(ctx context.Context, job claimedJob) {
+	log.Info("msg", "Running delete job", "id", job.id, "matchers", fmt.Sprintf("%v", job.matchers))
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg        sync.WaitGroup
+		cancelled bool
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		cancelled = m.heartbeat(jobCtx, job.id)
+		if cancelled {
+			cancel()
+		}
+	}()
+	err := m.execute(jobCtx, job)
+	cancel()
+	wg.Wait()
+
+	status, errMsg := JobSucceeded, pgtype.Text{Status: pgtype.Null}
+	switch {
+	case err == nil:
+	case err == errJobCancelled || cancelled:
+		status = JobCancelled
+	case ctx.Err() != nil:
+		// The connector is shutting down. The job stays running and is resumed
+		// once its heartbeat is stale.
+		log.Info("msg", "Interrupted delete job, it will be resumed later", "id", job.id)
+		return
+	default:
+		status = JobFailed
+		errMsg = pgtype.Text{String: err.Error(), Status: pgtype.Present}
+	}
+	// The job context may be cancelled at this point.
+	if _, err := m.conn.Exec(context.Background(), queryFinishJob, job.id, string(status), errMsg); err != nil {
+		log.Error("msg", "failed to record the status of delete job", "id", job.id, "status", status, "err", err)
+		return
+	}
+	log.Info("msg", "Delete job finished", "id", job.id, "status", status)
+}
+
+// heartbeat periodically marks the job as alive until the context is done. It
+// returns true if the job has been requested to be cancelled.
+func (m *JobManager) heartbeat(ctx context.Context, id int64) bool {
+	ticker := time.NewTicker(m.heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+		var cancelRequested bool
+		if err := m.conn.QueryRow(ctx, queryJobHeartbeat, id).Scan(&cancelRequested); err != nil {
+			if ctx.Err() == nil {
+				log.Warn("msg", "failed to update the heartbeat of delete job", "id", id, "err", err)
+			}
+			continue
+		}
+		if cancelRequested {
+			return true
+		}
+	}
+}
+
+type metricSeries struct {
+	metric    string
+	seriesIDs []model.SeriesID
+}
+
+// execute deletes the data of the series matched by the job, one metric at a time,
+// and records the progress after each metric. The metrics to process are recorded
+// when the job first runs, a resumed job matches the series again but only
+// processes the metrics that are left.
+func (m *JobManager) execute(ctx context.Context, job claimedJob) error {
+	var matched []metricSeries
+	for _, s := range job.matchers {
+		matchers, err := parser.ParseMetricSelector(s)
+		if err != nil {
+			return fmt.Errorf("parse matcher %s: %w", s, err)
+		}
+		metricNames, seriesIDMatrix, err := getMetricNameSeriesIDFromMatchers(ctx, m.conn, matchers)
+		if err != nil {
+			return fmt.Errorf("delete-series: %w", err)
+		}
+		for i := range metricNames {
+			matched = append(matched, metricSeries{metric: metricNames[i], seriesIDs: seriesIDMatrix[i]})
+		}
+	}
+	toDelete := groupByMetric(matched)
+
+	var cancelRequested bool
+	if job.pending == nil {
+		pending := make([]string, len(toDelete))
+		for i := range toDelete {
+			pending[i] = toDelete[i].metric
+		}
+		if err := m.conn.QueryRow(ctx, queryJobMetrics, job.id, len(toDelete), pending).Scan(&cancelRequested); err != nil {
+			return fmt.Errorf("update delete job progress: %w", err)
+		}
+	} else {
+		toDelete = pendingMetrics(toDelete, job.pending)
+	}
+	for _, ms := range toDelete {
+		if cancelRequested {
+			return errJobCancelled
+		}
+		var rowsDeleted int
+		if len(ms.seriesIDs) > 0 {
+			var err error
+			rowsDeleted, err = deleteMetricSeries(ctx, m.conn, ms.metric, ms.seriesIDs, job.start, job.end)
+			if rowsDeleted > 0 && m.onDelete != nil {
+				m.onDelete()
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if err := m.conn.QueryRow(ctx, queryJobProgress, job.id, len(ms.seriesIDs), rowsDeleted, ms.metric).Scan(&cancelRequested); err != nil {
+			return fmt.Errorf("update delete job progress: %w", err)
+		}
+	}
+	return nil
+}
+
+// groupByMetric merges the series of the same metric matched by several
+// matchers, and orders the metrics by name.
+func groupByMetric(matched []metricSeries) []metricSeries {
+	index := make(map[string]int, len(matched))
+	var grouped []metricSeries
+	for _, ms := range matched {
+		i, ok := index[ms.metric]
+		if !ok {
+			index[ms.metric] = len(grouped)
+			grouped = append(grouped, metricSeries{metric: ms.metric})
+			i = len(grouped) - 1
+		}
+		grouped[i].seriesIDs = append(grouped[i].seriesIDs, ms.seriesIDs...)
+	}
+	sort.Slice(grouped, func(i, j int) bool { return grouped[i].metric < grouped[j].metric })
+	return grouped
+}
+
+// pendingMetrics returns the matched series of the pending metrics of a resumed
+// job. Pending metrics which are not matched anymore are returned without series,
+// so that they are still counted as done.
+func pendingMetrics(matched []metricSeries, pending []string) []metricSeries {
+	series := make(map[string][]model.SeriesID, len(matched))
+	for _, ms := range matched {
+		series[ms.metric] = ms.seriesIDs
+	}
+	res := make([]metricSeries, len(pending))
+	for i, metric := range pending {
+		res[i] = metricSeries{metric: metric, seriesIDs: series[metric]}
+	}
+	return res
+}
+
+func scanJob(row pgx.Row) (Job, error) {
+	var (
+		job                           Job
+		status                        string
+		errMsg                        pgtype.Text
+		start, end, started, finished pgtype.Timestamptz
+	)
+	err := row.Scan(&job.ID, &job.Matchers, &start, &end, &status, &job.CancelRequested, &job.MetricsTotal, &job.MetricsDone,
+		&job.SeriesDeleted, &job.RowsDeleted, &errMsg, &job.CreatedAt, &started, &finished)
+	if err != nil {
+		return Job{}, err
+	}
+	job.Status = JobStatus(status)
+	job.Error = errMsg.String
+	job.Start = timeOrNil(start)
+	job.End = timeOrNil(end)
+	job.StartedAt = timeOrNil(started)
+	job.FinishedAt = timeOrNil(finished)
+	return job, nil
+}
+
+// boundOrNil returns nil if t is an unbounded end of a time range, as model.MinTime
+// and model.MaxTime are out of the range of timestamptz.
+func boundOrNil(t time.Time) *time.Time {
+	if !t.After(model.MinTime) || !t.Before(model.MaxTime) {
+		return nil
+	}
+	return &t
+}
+
+func boundOrDefault(t pgtype.Timestamptz, unbounded time.Time) time.Time {
+	if t.Status != pgtype.Present {
+		return unbounded
+	}
+	return t.Time
+}
+
+func timeOrNil(t pgtype.Timestamptz) *time.Time {
+	if t.Status != pgtype.Present {
+		return nil
+	}
+	return &t.Time
+}
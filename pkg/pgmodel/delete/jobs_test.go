@@ -0,0 +1,128 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package delete
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/pgmodel/common/errors"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+)
+
+func TestSubmitJob(t *testing.T) {
+	end := time.Unix(100, 0)
+	mock := model.NewSqlRecorder([]model.SqlQuery{
+		{
+			Sql:     querySubmitJob,
+			Args:    []interface{}{[]string{`{__name__="foo"}`}, (*time.Time)(nil), &end},
+			Results: model.RowResults{{int64(7)}},
+		},
+	}, t)
+	m := NewJobManager(mock)
+	id, err := m.Submit(context.Background(), []string{`{__name__="foo"}`}, model.MinTime, end)
+	require.NoError(t, err)
+	require.Equal(t, int64(7), id)
+}
+
+func TestCancelJob(t *testing.T) {
+	created := time.Unix(100, 0)
+	jobRow := func(status JobStatus) []interface{} {
+		return []interface{}{int64(1), []string{`{__name__="foo"}`}, nil, nil, string(status), true, 0, 0, int64(0), int64(0), nil, created, nil, nil}
+	}
+	cases := []struct {
+		name     string
+		queries  []model.SqlQuery
+		expected Job
+		err      error
+	}{
+		{
+			name: "pending job",
+			queries: []model.SqlQuery{
+				{Sql: queryCancelJob, Args: []interface{}{int64(1)}, Results: model.RowResults{jobRow(JobCancelled)}},
+			},
+			expected: Job{
+				ID:              1,
+				Status:          JobCancelled,
+				Matchers:        []string{`{__name__="foo"}`},
+				CancelRequested: true,
+				CreatedAt:       created,
+			},
+		},
+		{
+			name: "finished job",
+			queries: []model.SqlQuery{
+				{Sql: queryCancelJob, Args: []interface{}{int64(1)}, Err: pgx.ErrNoRows},
+				{Sql: queryGetJob, Args: []interface{}{int64(1)}, Results: model.RowResults{jobRow(JobSucceeded)}},
+			},
+			err: errors.ErrDeleteJobFinished,
+		},
+		{
+			name: "missing job",
+			queries: []model.SqlQuery{
+				{Sql: queryCancelJob, Args: []interface{}{int64(1)}, Err: pgx.ErrNoRows},
+				{Sql: queryGetJob, Args: []interface{}{int64(1)}, Err: pgx.ErrNoRows},
+			},
+			err: errors.ErrDeleteJobNotFound,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := NewJobManager(model.NewSqlRecorder(c.queries, t))
+			job, err := m.Cancel(context.Background(), 1)
+			if c.err != nil {
+				require.Equal(t, c.err, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expected, job)
+		})
+	}
+}
+
+func TestRunJobRecordsFailure(t *testing.T) {
+	mock := model.NewSqlRecorder([]model.SqlQuery{
+		{
+			Sql:     queryClaimJob,
+			Args:    []interface{}{defaultStaleAfter.Seconds()},
+			Results: model.RowResults{{int64(3), []string{`{__name__=`}, nil, nil, []string(nil)}},
+		},
+		{
+			Sql: queryFinishJob,
+			Args: []interface{}{
+				int64(3),
+				string(JobFailed),
+				pgtype.Text{String: `parse matcher {__name__=: 1:11: parse error: unexpected end of input inside braces`, Status: pgtype.Present},
+			},
+			Results: model.RowResults{{pgconn.CommandTag("UPDATE 1")}},
+		},
+	}, t)
+	m := NewJobManager(mock)
+	require.True(t, m.runNextJob(context.Background()))
+}
+
+func TestPendingMetrics(t *testing.T) {
+	matched := groupByMetric([]metricSeries{
+		{metric: "b", seriesIDs: []model.SeriesID{1}},
+		{metric: "a", seriesIDs: []model.SeriesID{2}},
+		{metric: "b", seriesIDs: []model.SeriesID{3}},
+	})
+	require.Equal(t, []metricSeries{
+		{metric: "a", seriesIDs: []model.SeriesID{2}},
+		{metric: "b", seriesIDs: []model.SeriesID{1, 3}},
+	}, matched)
+
+	// A resumed job only processes the pending metrics, including the ones
+	// that are not matched anymore.
+	require.Equal(t, []metricSeries{
+		{metric: "b", seriesIDs: []model.SeriesID{1, 3}},
+		{metric: "c"},
+	}, pendingMetrics(matched, []string{"b", "c"}))
+}
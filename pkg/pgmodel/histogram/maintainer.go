@@ -0,0 +1,97 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+// Package histogram maintains the native histograms, which are stored in a
+// single hypertable not covered by the maintenance of the Promscale extension.
+package histogram
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+const (
+	lockID = 6146524532519330417 // Chosen randomly.
+
+	sqlAcquireLock    = "SELECT pg_try_advisory_lock($1)"
+	sqlReleaseLock    = "SELECT pg_advisory_unlock($1)"
+	sqlApplyRetention = "SELECT _prom_catalog.apply_histogram_retention()"
+	sqlCollectSeries  = "SELECT _prom_catalog.collect_histogram_series()"
+	sqlCompressChunks = "SELECT _prom_catalog.compress_histogram_chunks()"
+
+	// MaintenanceInterval is how often the native histograms are maintained.
+	// It is well below the hour the series garbage collection waits between
+	// marking a series as unused and deleting it.
+	MaintenanceInterval = 15 * time.Minute
+)
+
+// Maintainer periodically applies the retention periods of the metrics to their
+// native histograms, deletes the histograms of the dropped metrics, keeps the
+// series with histograms from being garbage collected and compresses the old
+// histograms. Only one connector of the deployment maintains the histograms at
+// a time.
+type Maintainer struct {
+	conn     pgxconn.PgxConn
+	interval time.Duration
+}
+
+// NewMaintainer creates a new Maintainer.
+func NewMaintainer(conn pgxconn.PgxConn, interval time.Duration) *Maintainer {
+	return &Maintainer{conn: conn, interval: interval}
+}
+
+// Run maintains the histograms every interval until the context is cancelled.
+// It blocks until then.
+func (m *Maintainer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := m.maintain(ctx); err != nil && ctx.Err() == nil {
+			log.Error("msg", "failed to maintain native histograms", "err", err)
+		}
+	}
+}
+
+func (m *Maintainer) maintain(ctx context.Context) error {
+	conn, err := m.conn.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	acquired := false
+	if err = conn.QueryRow(ctx, sqlAcquireLock, lockID).Scan(&acquired); err != nil {
+		return fmt.Errorf("acquire advisory lock: %w", err)
+	}
+	if !acquired {
+		log.Debug("msg", "native histograms are being maintained by another connector")
+		return nil
+	}
+	defer func() {
+		// Release the lock even if the context was cancelled.
+		if _, err := conn.Exec(context.Background(), sqlReleaseLock, lockID); err != nil {
+			log.Error("msg", "failed to release native histogram advisory lock", "err", err)
+		}
+	}()
+
+	if _, err = conn.Exec(ctx, sqlApplyRetention); err != nil {
+		return fmt.Errorf("apply retention: %w", err)
+	}
+	if _, err = conn.Exec(ctx, sqlCollectSeries); err != nil {
+		return fmt.Errorf("collect series: %w", err)
+	}
+	if _, err = conn.Exec(ctx, sqlCompressChunks); err != nil {
+		return fmt.Errorf("compress chunks: %w", err)
+	}
+	return nil
+}
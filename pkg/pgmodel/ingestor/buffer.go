@@ -34,6 +34,8 @@ type pendingBuffer struct {
 	spanCtx       context.Context
 	needsResponse []insertDataTask
 	batch         model.Batch
+	// Distinct sources of the write requests of the batch.
+	sources []model.Source
 }
 
 var pendingBuffers = sync.Pool{
@@ -51,7 +53,7 @@ func NewPendingBuffer() *pendingBuffer {
 
 func (p *pendingBuffer) IsFull() bool {
 	samples, exemplars := p.batch.Count()
-	return samples+exemplars >= metrics.FlushSize
+	return samples+exemplars+p.batch.CountHistograms() >= metrics.FlushSize
 }
 
 func (p *pendingBuffer) IsEmpty() bool {
@@ -73,10 +75,17 @@ func (p *pendingBuffer) release() {
 	}
 	p.needsResponse = p.needsResponse[:0]
 	p.batch.Reset()
+	p.sources = p.sources[:0]
 	pendingBuffers.Put(p)
 }
 
 func (p *pendingBuffer) addReq(req *insertDataRequest) {
 	p.needsResponse = append(p.needsResponse, insertDataTask{finished: req.finished, errChan: req.errChan})
 	p.batch.AppendSlice(req.data)
+	for _, s := range p.sources {
+		if s == req.source {
+			return
+		}
+	}
+	p.sources = append(p.sources, req.source)
 }
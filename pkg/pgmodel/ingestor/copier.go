@@ -342,11 +342,15 @@ func insertSeries(ctx context.Context, conn pgxconn.PgxConn, onConflict bool, re
 	defer span.End()
 	numRowsPerInsert := make([]int, 0, len(reqs))
 	insertedRows := make([]int, 0, len(reqs))
+	// The request of each insert, to attribute its duplicates.
+	insertReqs := make([]*copyRequest, 0, len(reqs))
 	numRowsTotal := 0
 	totalSamples := 0
 	totalExemplars := 0
+	totalHistograms := 0
 	var sampleRows [][]interface{}
 	var exemplarRows [][]interface{}
+	var histogramRows [][]interface{}
 	insertStart := time.Now()
 	lowestEpoch := pgmodel.SeriesEpoch(math.MaxInt64)
 	lowestMinTime := int64(math.MaxInt64)
@@ -369,7 +373,8 @@ func insertSeries(ctx context.Context, conn pgxconn.PgxConn, onConflict bool, re
 		// We sort after PopulateOrCreateSeries call because we now have guarantees that all seriesIDs have been populated
 		sort.Sort(&req.data.batch)
 		numSamples, numExemplars := req.data.batch.Count()
-		metrics.IngestorRowsPerInsert.With(labelsCopier).Observe(float64(numSamples + numExemplars))
+		numHistograms := req.data.batch.CountHistograms()
+		metrics.IngestorRowsPerInsert.With(labelsCopier).Observe(float64(numSamples + numExemplars + numHistograms))
 
 		// flatten the various series into arrays.
 		// there are four main bottlenecks for insertion:
@@ -384,8 +389,9 @@ func insertSeries(ctx context.Context, conn pgxconn.PgxConn, onConflict bool, re
 		// multiple data, and brings INSERT nearly on par with CopyFrom. In the
 		// future we may wish to send compressed data instead.
 		var (
-			hasSamples   bool
-			hasExemplars bool
+			hasSamples    bool
+			hasExemplars  bool
+			hasHistograms bool
 		)
 
 		if numSamples > 0 {
@@ -394,6 +400,9 @@ func insertSeries(ctx context.Context, conn pgxconn.PgxConn, onConflict bool, re
 		if numExemplars > 0 {
 			exemplarRows = make([][]interface{}, 0, numExemplars)
 		}
+		if numHistograms > 0 {
+			histogramRows = make([][]interface{}, 0, numHistograms)
+		}
 
 		visitor := req.data.batch.Visitor()
 		err = visitor.Visit(
@@ -405,6 +414,14 @@ func insertSeries(ctx context.Context, conn pgxconn.PgxConn, onConflict bool, re
 				hasExemplars = true
 				exemplarRows = append(exemplarRows, []interface{}{t, seriesId, lvalues, v})
 			},
+			func(t time.Time, h *pgmodel.FloatHistogram, seriesId int64) {
+				hasHistograms = true
+				histogramRows = append(histogramRows, []interface{}{
+					t, seriesId, req.info.MetricID, h.Count, h.Sum, h.Schema, h.ZeroThreshold, h.ZeroCount,
+					pgmodel.SpansToInt32Array(h.PositiveSpans), h.PositiveBuckets,
+					pgmodel.SpansToInt32Array(h.NegativeSpans), h.NegativeBuckets, int16(h.ResetHint),
+				})
+			},
 		)
 		if err != nil {
 			return err, lowestMinTime
@@ -418,18 +435,24 @@ func insertSeries(ctx context.Context, conn pgxconn.PgxConn, onConflict bool, re
 			lowestMinTime = minTime
 		}
 
-		numRowsTotal += numSamples + numExemplars
+		numRowsTotal += numSamples + numExemplars + numHistograms
 		totalSamples += numSamples
 		totalExemplars += numExemplars
+		totalHistograms += numHistograms
 
-		copyFromFunc := func(tableName, schemaName string, isExemplar bool) error {
+		copyFromFunc := func(tableName, schemaName string, typ pgmodel.InsertableType) error {
 			columns := schema.PromDataColumns
 			tempTablePrefix := fmt.Sprintf("s%d_", req.info.MetricID)
 			rows := sampleRows
-			if isExemplar {
+			switch typ {
+			case pgmodel.Exemplar:
 				columns = schema.PromExemplarColumns
 				tempTablePrefix = fmt.Sprintf("e%d_", req.info.MetricID)
 				rows = exemplarRows
+			case pgmodel.Histogram:
+				columns = schema.PromHistogramColumns
+				tempTablePrefix = fmt.Sprintf("h%d_", req.info.MetricID)
+				rows = histogramRows
 			}
 			table := pgx.Identifier{schemaName, tableName}
 			if onConflict {
@@ -454,18 +477,25 @@ func insertSeries(ctx context.Context, conn pgxconn.PgxConn, onConflict bool, re
 
 			}
 			insertedRows = append(insertedRows, int(inserted))
+			insertReqs = append(insertReqs, req)
 			return nil
 		}
 
 		if hasSamples {
 			numRowsPerInsert = append(numRowsPerInsert, numSamples)
-			if err = copyFromFunc(req.info.TableName, req.info.TableSchema, false); err != nil {
+			if err = copyFromFunc(req.info.TableName, req.info.TableSchema, pgmodel.Sample); err != nil {
 				return err, lowestMinTime
 			}
 		}
 		if hasExemplars {
 			numRowsPerInsert = append(numRowsPerInsert, numExemplars)
-			if err = copyFromFunc(req.info.TableName, schema.PromDataExemplar, true); err != nil {
+			if err = copyFromFunc(req.info.TableName, schema.PromDataExemplar, pgmodel.Exemplar); err != nil {
+				return err, lowestMinTime
+			}
+		}
+		if hasHistograms {
+			numRowsPerInsert = append(numRowsPerInsert, numHistograms)
+			if err = copyFromFunc(schema.HistogramTable, schema.PromDataHistogram, pgmodel.Histogram); err != nil {
 				return err, lowestMinTime
 			}
 		}
@@ -491,11 +521,15 @@ func insertSeries(ctx context.Context, conn pgxconn.PgxConn, onConflict bool, re
 	for idx, numRows := range numRowsPerInsert {
 		if numRows != insertedRows[idx] {
 			affectedMetrics++
-			registerDuplicates(int64(numRows - insertedRows[idx]))
+			// All the data of a request belongs to a single metric.
+			req := insertReqs[idx]
+			metric := req.data.batch.Data()[0].Series().MetricName()
+			registerDuplicates(metric, req.data.sources, int64(numRows-insertedRows[idx]))
 		}
 	}
 	metrics.IngestorItems.With(prometheus.Labels{"type": "metric", "subsystem": "copier", "kind": "sample"}).Add(float64(totalSamples))
 	metrics.IngestorItems.With(prometheus.Labels{"type": "metric", "subsystem": "copier", "kind": "exemplar"}).Add(float64(totalExemplars))
+	metrics.IngestorItems.With(prometheus.Labels{"type": "metric", "subsystem": "copier", "kind": "histogram"}).Add(float64(totalHistograms))
 
 	reportDuplicates(affectedMetrics)
 	metrics.IngestorInsertDuration.With(prometheus.Labels{"type": "metric", "subsystem": "copier", "kind": "sample"}).Observe(time.Since(insertStart).Seconds())
@@ -0,0 +1,143 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package ingestor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+// existingSeriesSQL returns the ordinality, in the array of their numbers of
+// labels, of the series which exist and are not deleted. The labels of the
+// series are given as (ordinality, name, value) triples.
+const existingSeriesSQL = `WITH found AS (
+	SELECT k.nr, array_agg(l.id) AS ids, count(*) AS num_labels
+	FROM unnest($1::int[], $2::text[], $3::text[]) k(nr, key, value)
+	INNER JOIN _prom_catalog.label l ON (l.key = k.key AND l.value = k.value)
+	GROUP BY k.nr
+)
+SELECT c.nr
+FROM unnest($4::int[]) WITH ORDINALITY c(num_labels, nr)
+INNER JOIN found f ON (f.nr = c.nr AND f.num_labels = c.num_labels)
+WHERE EXISTS (
+	SELECT 1
+	FROM _prom_catalog.series s
+	WHERE s.labels @> f.ids AND cardinality(array_remove(s.labels::int[], 0)) = f.num_labels AND s.delete_epoch IS NULL
+)`
+
+// createdZeros drops the zeros marking the created timestamp of series, see
+// prompb.CreatedZero, if the series already exists in the database. Senders
+// repeat the created timestamp in every request, so the zero is only written
+// when the series is created, instead of being written again into older, maybe
+// compressed, chunks.
+type createdZeros struct {
+	conn pgxconn.PgxConn
+}
+
+// drop removes the zeros of the created timestamps of the time-series whose
+// series exist. A series with a cached id exists, the others are looked up in
+// the database. Without a database, i.e. on a nil receiver, only the series
+// with a cached id are known to exist.
+func (c *createdZeros) drop(ctx context.Context, allSeries []*model.Series, timeseries []prompb.TimeSeries) error {
+	var (
+		unknown               []int
+		numLabels, nrs        []int32
+		labelNames, labelVals []string
+	)
+	for i, series := range allSeries {
+		ts := &timeseries[i]
+		if series == nil || !hasCreatedZero(ts) {
+			continue
+		}
+		names, values, ok := series.NameValues()
+		if !ok {
+			// The id is only set once the series is created.
+			dropCreatedZero(ts)
+			continue
+		}
+		if c == nil {
+			continue
+		}
+		unknown = append(unknown, i)
+		numLabels = append(numLabels, int32(len(names)))
+		for j := range names {
+			nrs = append(nrs, int32(len(unknown)))
+			labelNames = append(labelNames, names[j])
+			labelVals = append(labelVals, values[j])
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	rows, err := c.conn.Query(ctx, existingSeriesSQL, nrs, labelNames, labelVals, numLabels)
+	if err != nil {
+		return fmt.Errorf("looking up series with created timestamps: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var nr int64
+		if err := rows.Scan(&nr); err != nil {
+			return fmt.Errorf("looking up series with created timestamps: %w", err)
+		}
+		dropCreatedZero(&timeseries[unknown[nr-1]])
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("looking up series with created timestamps: %w", err)
+	}
+	return nil
+}
+
+// hasCreatedZero returns true if ts has a zero marking its created timestamp,
+// which always precedes its samples and histograms.
+func hasCreatedZero(ts *prompb.TimeSeries) bool {
+	return (len(ts.Samples) > 0 && ts.Samples[0].IsCreatedZero()) ||
+		(len(ts.Histograms) > 0 && ts.Histograms[0].IsCreatedZero())
+}
+
+// dropCreatedZero removes the zero marking the created timestamp of ts.
+func dropCreatedZero(ts *prompb.TimeSeries) {
+	if len(ts.Samples) > 0 && ts.Samples[0].IsCreatedZero() {
+		ts.Samples = ts.Samples[:copy(ts.Samples, ts.Samples[1:])]
+	}
+	if len(ts.Histograms) > 0 && ts.Histograms[0].IsCreatedZero() {
+		ts.Histograms = ts.Histograms[:copy(ts.Histograms, ts.Histograms[1:])]
+	}
+}
+
+// resetCreatedZeros replaces the markers of the created timestamp of ts by
+// zeros before they are written, and returns the number of samples and
+// histograms replaced.
+func resetCreatedZeros(ts *prompb.TimeSeries) (samples, histograms int) {
+	if len(ts.Samples) > 0 && ts.Samples[0].IsCreatedZero() {
+		ts.Samples[0].Value = 0
+		samples++
+	}
+	if len(ts.Histograms) > 0 && ts.Histograms[0].IsCreatedZero() {
+		ts.Histograms[0].Sum = 0
+		histograms++
+	}
+	return samples, histograms
+}
+
+// newestTimestamp returns the timestamp of the newest sample or histogram of ts.
+func newestTimestamp(ts *prompb.TimeSeries) int64 {
+	var newest int64
+	for _, s := range ts.Samples {
+		if s.Timestamp > newest {
+			newest = s.Timestamp
+		}
+	}
+	for _, h := range ts.Histograms {
+		if h.Timestamp > newest {
+			newest = h.Timestamp
+		}
+	}
+	return newest
+}
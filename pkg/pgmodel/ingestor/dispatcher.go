@@ -11,6 +11,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/jackc/pgx/v4"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
@@ -42,6 +43,7 @@ type pgxDispatcher struct {
 	exemplarKeyPosCache    cache.PositionCache
 	batchers               sync.Map
 	completeMetricCreation chan struct{}
+	onMetricCreation       func(ctx context.Context, tx pgx.Tx) error
 	asyncAcks              bool
 	copierReadRequestCh    chan<- readRequest
 	seriesEpochRefresh     *time.Ticker
@@ -52,6 +54,11 @@ type pgxDispatcher struct {
 
 var _ model.Dispatcher = &pgxDispatcher{}
 
+// copierBacklog returns the fraction of the copier queue in use.
+func (p *pgxDispatcher) copierBacklog() float64 {
+	return float64(len(p.copierReadRequestCh)) / float64(cap(p.copierReadRequestCh))
+}
+
 func newPgxDispatcher(conn pgxconn.PgxConn, mCache cache.MetricCache, scache cache.SeriesCache, eCache cache.PositionCache, cfg *Cfg) (*pgxDispatcher, error) {
 	numCopiers := cfg.NumCopiers
 	if numCopiers < 1 {
@@ -94,6 +101,7 @@ func newPgxDispatcher(conn pgxconn.PgxConn, mCache cache.MetricCache, scache cac
 		invertedLabelsCache:    labelsCache,
 		exemplarKeyPosCache:    eCache,
 		completeMetricCreation: make(chan struct{}, 1),
+		onMetricCreation:       cfg.OnMetricCreation,
 		asyncAcks:              cfg.MetricsAsyncAcks,
 		copierReadRequestCh:    copierReadRequestCh,
 		// set to run at half our deletion interval
@@ -127,10 +135,30 @@ func (p *pgxDispatcher) runCompleteMetricCreationWorker() {
 		err := p.CompleteMetricCreation(context.Background())
 		if err != nil {
 			log.Warn("msg", "Got an error finalizing metric", "err", err)
+			continue
+		}
+		if err = p.runOnMetricCreation(context.Background()); err != nil {
+			log.Warn("msg", "Got an error running the metric creation hook", "err", err)
 		}
 	}
 }
 
+// runOnMetricCreation runs the metric creation hook, if any, in a transaction.
+func (p *pgxDispatcher) runOnMetricCreation(ctx context.Context) error {
+	if p.onMetricCreation == nil {
+		return nil
+	}
+	tx, err := p.conn.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err = p.onMetricCreation(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
 func (p *pgxDispatcher) runSeriesEpochSync() {
 	epoch, err := p.refreshSeriesEpoch(model.InvalidSeriesEpoch)
 	// we don't have any great place to report errors, and if the
@@ -240,7 +268,7 @@ func (p *pgxDispatcher) InsertTs(ctx context.Context, dataTS model.Data) (uint64
 				maxt = ts
 			}
 		}
-		p.getMetricBatcher(metricName) <- &insertDataRequest{spanCtx: span.SpanContext(), metric: metricName, data: data, finished: workFinished, errChan: errChan}
+		p.getMetricBatcher(metricName) <- &insertDataRequest{spanCtx: span.SpanContext(), metric: metricName, data: data, source: dataTS.Source, finished: workFinished, errChan: errChan}
 	}
 	span.SetAttributes(attribute.Int64("num_rows", int64(numRows)))
 	span.SetAttributes(attribute.Int("num_metrics", len(rows)))
@@ -334,6 +362,7 @@ type insertDataRequest struct {
 	metric   string
 	finished *sync.WaitGroup
 	data     []model.Insertable
+	source   model.Source
 	errChan  chan error
 }
 
@@ -5,6 +5,9 @@
 package ingestor
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -13,22 +16,162 @@ import (
 
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgmodel/metrics"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
 )
 
-const reportDuplicatesInterval = time.Minute
+const (
+	reportDuplicatesInterval = time.Minute
+	// maxDuplicateExamples is the number of recent duplicates kept.
+	maxDuplicateExamples = 100
+	// maxReportedDuplicateMetrics is the number of metrics with the most
+	// duplicates logged in each report.
+	maxReportedDuplicateMetrics = 5
+
+	// DetectedInWriteRequest duplicates are samples of a series at a timestamp
+	// which another entry of the series in the same write request has.
+	DetectedInWriteRequest = "write_request"
+	// DetectedInDatabase duplicates are samples already in the database.
+	DetectedInDatabase = "database"
+)
 
 var (
 	launchReporterOnce    sync.Once
 	duplicateMetricsTotal uint64
+
+	duplicates = newDuplicateTracker(time.Now)
 )
 
 func init() {
 	atomic.StoreUint64(&duplicateMetricsTotal, 0)
 }
 
-func registerDuplicates(duplicateSamples int64) {
+// DuplicateExample is a recent occurrence of duplicate samples of a metric.
+type DuplicateExample struct {
+	Time     time.Time    `json:"time"`
+	Metric   string       `json:"metric"`
+	Detected string       `json:"detected"`
+	Samples  int64        `json:"samples"`
+	Source   model.Source `json:"source"`
+	// The series and the timestamp of a duplicate sample are only known
+	// for duplicates within a write request.
+	Series    string `json:"series,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+}
+
+// duplicateTracker keeps the recent duplicates, and the number of duplicate
+// samples of each metric since the last report.
+type duplicateTracker struct {
+	mu        sync.Mutex
+	examples  []DuplicateExample
+	next      int
+	perMetric map[string]int64
+	now       func() time.Time
+}
+
+func newDuplicateTracker(now func() time.Time) *duplicateTracker {
+	return &duplicateTracker{
+		examples:  make([]DuplicateExample, 0, maxDuplicateExamples),
+		perMetric: make(map[string]int64),
+		now:       now,
+	}
+}
+
+// add records a duplicate. The client address is only kept in the examples,
+// it would make the cardinality of the metric unbounded.
+func (t *duplicateTracker) add(e DuplicateExample) {
+	metrics.IngestorDuplicatesBySource.With(prometheus.Labels{
+		"type":    "metric",
+		"metric":  e.Metric,
+		"tenant":  e.Source.Tenant,
+		"replica": e.Source.Replica,
+	}).Add(float64(e.Samples))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e.Time = t.now()
+	if len(t.examples) < maxDuplicateExamples {
+		t.examples = append(t.examples, e)
+	} else {
+		t.examples[t.next] = e
+	}
+	t.next = (t.next + 1) % maxDuplicateExamples
+	t.perMetric[e.Metric] += e.Samples
+}
+
+// recent returns the recent duplicates of the metric, or of all metrics if
+// empty, newest first.
+func (t *duplicateTracker) recent(metric string, limit int) []DuplicateExample {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	res := make([]DuplicateExample, 0, len(t.examples))
+	for i := 1; i <= len(t.examples) && len(res) < limit; i++ {
+		e := t.examples[(t.next-i+len(t.examples))%len(t.examples)]
+		if metric == "" || e.Metric == metric {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// topMetrics returns the metrics with the most duplicate samples since the
+// last call, formatted for the logs.
+func (t *duplicateTracker) topMetrics() string {
+	t.mu.Lock()
+	perMetric := t.perMetric
+	t.perMetric = make(map[string]int64)
+	t.mu.Unlock()
+
+	names := make([]string, 0, len(perMetric))
+	for name := range perMetric {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if perMetric[names[i]] != perMetric[names[j]] {
+			return perMetric[names[i]] > perMetric[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > maxReportedDuplicateMetrics {
+		names = names[:maxReportedDuplicateMetrics]
+	}
+	top := make([]string, len(names))
+	for i, name := range names {
+		top[i] = fmt.Sprintf("%s=%d", name, perMetric[name])
+	}
+	return strings.Join(top, ", ")
+}
+
+// RecentDuplicates returns up to limit of the recent duplicates of the metric,
+// or of all metrics if empty, newest first.
+func RecentDuplicates(metric string, limit int) []DuplicateExample {
+	return duplicates.recent(metric, limit)
+}
+
+// registerDuplicates records the duplicate samples of a metric found while
+// inserting data sent by the given sources.
+func registerDuplicates(metric string, sources []model.Source, duplicateSamples int64) {
 	metrics.IngestorDuplicates.With(prometheus.Labels{"type": "metric", "kind": "sample"}).Add(float64(duplicateSamples))
 	metrics.IngestorDuplicates.With(prometheus.Labels{"type": "metric", "kind": "writes_to_db"}).Inc()
+	duplicates.add(DuplicateExample{
+		Metric:   metric,
+		Detected: DetectedInDatabase,
+		Samples:  duplicateSamples,
+		Source:   model.MergeSources(sources),
+	})
+}
+
+// registerRequestDuplicates records the duplicate samples of a series within
+// a write request, timestamp being the one of the first of them.
+func registerRequestDuplicates(series *model.Series, labels string, timestamp int64, duplicateSamples int, source model.Source) {
+	metrics.IngestorDuplicates.With(prometheus.Labels{"type": "metric", "kind": "sample"}).Add(float64(duplicateSamples))
+	duplicates.add(DuplicateExample{
+		Metric:    series.MetricName(),
+		Detected:  DetectedInWriteRequest,
+		Samples:   int64(duplicateSamples),
+		Source:    source,
+		Series:    labels,
+		Timestamp: timestamp,
+	})
 }
 
 func reportDuplicates(duplicateMetrics uint64) {
@@ -38,8 +181,9 @@ func reportDuplicates(duplicateMetrics uint64) {
 		go func() {
 			report := time.NewTicker(reportDuplicatesInterval)
 			for range report.C {
-				if atomic.LoadUint64(&duplicateMetricsTotal) != 0 {
-					log.Warn("msg", "duplicate data in sample", "total-duplicate-metrics", atomic.LoadUint64(&duplicateMetricsTotal))
+				top := duplicates.topMetrics()
+				if atomic.LoadUint64(&duplicateMetricsTotal) != 0 || top != "" {
+					log.Warn("msg", "duplicate data in sample", "total-duplicate-metrics", atomic.LoadUint64(&duplicateMetricsTotal), "top-metrics", top)
 					atomic.StoreUint64(&duplicateMetricsTotal, 0)
 				}
 			}
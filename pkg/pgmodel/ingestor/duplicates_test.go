@@ -0,0 +1,68 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package ingestor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	io_prometheus_client "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/timescale/promscale/pkg/pgmodel/metrics"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+func TestDuplicateTracker(t *testing.T) {
+	tracker := newDuplicateTracker(func() time.Time { return time.Unix(0, 0) })
+	for i := 0; i < maxDuplicateExamples+10; i++ {
+		tracker.add(DuplicateExample{Metric: fmt.Sprintf("metric_%d", i%3), Samples: int64(i)})
+	}
+
+	recent := tracker.recent("", maxDuplicateExamples+10)
+	require.Len(t, recent, maxDuplicateExamples)
+	require.Equal(t, int64(maxDuplicateExamples+9), recent[0].Samples)
+	require.Equal(t, int64(10), recent[len(recent)-1].Samples)
+
+	recent = tracker.recent("metric_1", 2)
+	require.Len(t, recent, 2)
+	require.Equal(t, "metric_1", recent[0].Metric)
+	require.Equal(t, int64(maxDuplicateExamples+6), recent[1].Samples)
+
+	require.Equal(t, "metric_1=2035, metric_0=1998, metric_2=1962", tracker.topMetrics())
+	require.Equal(t, "", tracker.topMetrics())
+}
+
+func TestRegisterRequestDuplicates(t *testing.T) {
+	value := func(c prometheus.Counter) float64 {
+		var m io_prometheus_client.Metric
+		require.NoError(t, c.Write(&m))
+		return m.Counter.GetValue()
+	}
+	total := metrics.IngestorDuplicates.With(prometheus.Labels{"type": "metric", "kind": "sample"})
+	bySource := metrics.IngestorDuplicatesBySource.With(prometheus.Labels{"type": "metric", "metric": "request_duplicates", "tenant": "t", "replica": "r"})
+	before := value(total)
+
+	series := model.NewSeries("", []prompb.Label{{Name: model.MetricNameLabelName, Value: "request_duplicates"}})
+	registerRequestDuplicates(series, series.String(), 1, 3, model.Source{Tenant: "t", Replica: "r", Client: "10.0.0.1"})
+	require.Equal(t, before+3, value(total))
+	require.Equal(t, float64(3), value(bySource))
+	require.Equal(t, "10.0.0.1", RecentDuplicates("request_duplicates", 1)[0].Source.Client)
+}
+
+func TestMergeSources(t *testing.T) {
+	a := model.Source{Tenant: "t", Replica: "r1", Client: "10.0.0.1"}
+	b := model.Source{Tenant: "t", Replica: "r2", Client: "10.0.0.1"}
+	require.Equal(t, model.Source{}, model.MergeSources(nil))
+	require.Equal(t, a, model.MergeSources([]model.Source{a}))
+	require.Equal(t, model.Source{Tenant: "t", Replica: model.MultipleSources, Client: "10.0.0.1"}, model.MergeSources([]model.Source{a, b}))
+
+	var s model.Source
+	s.SetReplicas([]string{"r2", "r1", "r2"})
+	require.Equal(t, "r1,r2", s.Replica)
+}
@@ -9,12 +9,16 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v4"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.uber.org/atomic"
 
 	"github.com/timescale/promscale/pkg/pgmodel/cache"
 	"github.com/timescale/promscale/pkg/pgmodel/common/errors"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/logs"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/serieslimit"
 	"github.com/timescale/promscale/pkg/pgmodel/ingestor/trace"
 	"github.com/timescale/promscale/pkg/pgmodel/metrics"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
@@ -33,6 +37,16 @@ type Cfg struct {
 	TracesBatchTimeout      time.Duration
 	TracesMaxBatchSize      int
 	TracesBatchWorkers      int
+	LogsAsyncAcks           bool
+	LogsBatchTimeout        time.Duration
+	LogsMaxBatchSize        int
+	SeriesLimits            serieslimit.Config
+	MaxSampleAge            time.Duration
+	OutOfOrderWindow        time.Duration
+	OutOfBoundsAction       string
+	// OnMetricCreation, if set, is run in a transaction after new metrics
+	// are created, e.g. to apply the dataset overrides to them.
+	OnMetricCreation func(ctx context.Context, tx pgx.Tx) error
 }
 
 // DBIngestor ingest the TimeSeries data into Timescale database.
@@ -40,7 +54,14 @@ type DBIngestor struct {
 	sCache     cache.SeriesCache
 	dispatcher model.Dispatcher
 	tWriter    trace.Writer
-	closed     *atomic.Bool
+	lWriter    logs.Writer
+	// nil when no series limits are set.
+	seriesLimiter *serieslimit.Limiter
+	// nil when samples of any age are accepted.
+	bounds *sampleBounds
+	// nil when the database cannot be looked up for existing series.
+	createdZeros *createdZeros
+	closed       *atomic.Bool
 }
 
 // NewPgxIngestor returns a new Ingestor that uses connection pool and a metrics cache
@@ -57,11 +78,26 @@ func NewPgxIngestor(conn pgxconn.PgxConn, cache cache.MetricCache, sCache cache.
 		Writers:      cfg.NumCopiers,
 	}
 	traceWriter := trace.NewWriter(conn)
+
+	logsBatcherConfig := logs.BatcherConfig{
+		MaxBatchSize: cfg.LogsMaxBatchSize,
+		BatchTimeout: cfg.LogsBatchTimeout,
+		Writers:      cfg.NumCopiers,
+	}
+	logWriter := logs.NewWriter(conn)
+	var seriesLimiter *serieslimit.Limiter
+	if cfg.SeriesLimits.Enabled() {
+		seriesLimiter = serieslimit.NewLimiter(cfg.SeriesLimits)
+	}
 	return &DBIngestor{
-		sCache:     sCache,
-		dispatcher: dispatcher,
-		tWriter:    trace.NewDispatcher(traceWriter, cfg.TracesAsyncAcks, batcherConfg),
-		closed:     atomic.NewBool(false),
+		sCache:        sCache,
+		dispatcher:    dispatcher,
+		tWriter:       trace.NewDispatcher(traceWriter, cfg.TracesAsyncAcks, batcherConfg),
+		lWriter:       logs.NewDispatcher(logWriter, cfg.LogsAsyncAcks, logsBatcherConfig),
+		seriesLimiter: seriesLimiter,
+		bounds:        newSampleBounds(cfg),
+		createdZeros:  &createdZeros{conn: conn},
+		closed:        atomic.NewBool(false),
 	}, nil
 }
 
@@ -99,6 +135,15 @@ func (ingestor *DBIngestor) IngestTraces(ctx context.Context, traces ptrace.Trac
 	return ingestor.tWriter.InsertTraces(ctx, traces)
 }
 
+func (ingestor *DBIngestor) IngestLogs(ctx context.Context, logs plog.Logs) error {
+	if ingestor.closed.Load() {
+		return fmt.Errorf("ingestor is closed and can't ingest logs")
+	}
+	_, span := tracer.Default().Start(ctx, "ingest-logs")
+	defer span.End()
+	return ingestor.lWriter.InsertLogs(ctx, logs)
+}
+
 // IngestMetrics transforms and ingests the timeseries data into Timescale database.
 // input:
 //     req the WriteRequest backing tts. It will be added to our WriteRequest
@@ -157,6 +202,9 @@ func (ingestor *DBIngestor) IngestMetrics(ctx context.Context, r *prompb.WriteRe
 	}()
 
 	mergeErr := func(prevErr, err error, message string) error {
+		if err == nil {
+			return prevErr
+		}
 		if prevErr != nil {
 			err = fmt.Errorf("%s: %s: %w", prevErr.Error(), message, err)
 		}
@@ -189,31 +237,116 @@ func (ingestor *DBIngestor) ingestTimeseries(ctx context.Context, timeseries []p
 	defer span.End()
 	var (
 		totalRowsExpected uint64
+		outcomes          Outcomes
 
 		insertables = make(map[string][]model.Insertable)
+		source      model.Source
 	)
+	if s := model.SourceFromContext(ctx); s != nil {
+		source = *s
+	}
 
+	// The series of all time-series are resolved and their samples checked
+	// against the bounds first, so that the series limits only count the
+	// series which can be ingested. Invalid series are rejected, the others
+	// are still ingested.
+	allSeries := make([]*model.Series, len(timeseries))
 	for i := range timeseries {
-		var (
-			err        error
-			series     *model.Series
-			metricName string
-
-			ts = &timeseries[i]
-		)
+		ts := &timeseries[i]
 		if len(ts.Labels) == 0 {
 			continue
 		}
 		// Normalize and canonicalize ts.Labels.
-		// After this point ts.Labels should never be used again.
-		series, metricName, err = ingestor.sCache.GetSeriesFromProtos(ts.Labels)
+		// After this point ts.Labels should only be used to report the series.
+		series, metricName, err := ingestor.sCache.GetSeriesFromProtos(ts.Labels)
 		if err != nil {
-			return 0, err
+			outcomes.add(Invalid, ts, err)
+			continue
 		}
 		if metricName == "" {
-			return 0, errors.ErrNoMetricName
+			outcomes.add(Invalid, ts, fmt.Errorf("%w: series %s", errors.ErrNoMetricName, model.FormatLabels(ts.Labels)))
+			continue
+		}
+		allSeries[i] = series
+	}
+	if err := ingestor.createdZeros.drop(ctx, allSeries, timeseries); err != nil {
+		return 0, err
+	}
+	maxTimes := make([]int64, len(timeseries))
+	// Number of entries of each series, a series may appear several times
+	// in a request, e.g. after relabeling.
+	entries := make(map[*model.Series]int, len(timeseries))
+	for i, series := range allSeries {
+		if series == nil {
+			continue
+		}
+		ts := &timeseries[i]
+		if ingestor.bounds != nil {
+			var err error
+			if maxTimes[i], err = ingestor.bounds.check(series, ts); err != nil {
+				outcomes.add(Invalid, ts, err)
+				allSeries[i] = nil
+				continue
+			}
+		} else {
+			maxTimes[i] = newestTimestamp(ts)
+		}
+		entries[series]++
+	}
+	if ingestor.seriesLimiter != nil {
+		dropped, err := ingestor.seriesLimiter.Admit(allSeries, timeseries)
+		if err != nil {
+			return 0, err
+		}
+		for i, err := range dropped {
+			if err != nil {
+				outcomes.add(RejectedByLimit, &timeseries[i], fmt.Errorf("series %s: %w", model.FormatLabels(timeseries[i].Labels), err))
+				allSeries[i] = nil
+			}
+		}
+	}
+
+	// Timestamps of the samples of the series with several entries.
+	var timestamps map[*model.Series]*seenTimestamps
+	for i := range timeseries {
+		var (
+			series = allSeries[i]
+			ts     = &timeseries[i]
+		)
+		if series == nil {
+			continue
+		}
+		metricName := series.MetricName()
+		if entries[series] > 1 {
+			if timestamps == nil {
+				timestamps = make(map[*model.Series]*seenTimestamps)
+			}
+			if timestamps[series] == nil {
+				timestamps[series] = newSeenTimestamps()
+			}
+			duplicates, left := dedupe(timestamps[series], ts)
+			if n := len(duplicates.Samples) + len(duplicates.Histograms); n > 0 {
+				registerRequestDuplicates(series, model.FormatLabels(ts.Labels), firstTimestamp(&duplicates), n, source)
+			}
+			if !left {
+				outcomes.add(Duplicate, &duplicates, nil)
+				continue
+			}
+			outcomes.Counts[Duplicate].Samples += len(duplicates.Samples)
+			outcomes.Counts[Duplicate].Histograms += len(duplicates.Histograms)
+			outcomes.Counts[Duplicate].Exemplars += len(duplicates.Exemplars)
 		}
 
+		zeroSamples, zeroHistograms := resetCreatedZeros(ts)
+		var histograms model.Insertable
+		if len(ts.Histograms) > 0 {
+			var err error
+			if histograms, _, err = ingestor.histograms(series, ts); err != nil {
+				outcomes.add(Invalid, ts, fmt.Errorf("histograms of series %s: %w", model.FormatLabels(ts.Labels), err))
+				allSeries[i] = nil
+				continue
+			}
+		}
 		if len(ts.Samples) > 0 {
 			samples, count, err := ingestor.samples(series, ts)
 			if err != nil {
@@ -230,20 +363,100 @@ func (ingestor *DBIngestor) ingestTimeseries(ctx context.Context, timeseries []p
 			totalRowsExpected += uint64(count)
 			insertables[metricName] = append(insertables[metricName], exemplars)
 		}
+		if histograms != nil {
+			totalRowsExpected += uint64(len(ts.Histograms))
+			insertables[metricName] = append(insertables[metricName], histograms)
+		}
+		outcomes.add(Accepted, ts, nil)
+		outcomes.CreatedZeros.Samples += zeroSamples
+		outcomes.CreatedZeros.Histograms += zeroHistograms
 		// we're going to free req after this, but we still need the samples,
 		// so nil the field
 		ts.Samples = nil
 		ts.Exemplars = nil
+		ts.Histograms = nil
 	}
 	releaseMem()
-
-	numInsertablesIngested, errSamples := ingestor.dispatcher.InsertTs(ctx, model.Data{Rows: insertables, ReceivedTime: time.Now()})
+	outcomes.report()
+
+	numInsertablesIngested, errSamples := ingestor.dispatcher.InsertTs(ctx, model.Data{Rows: insertables, ReceivedTime: time.Now(), Source: source})
+	if errSamples == nil {
+		// The newest sample of each series is only recorded once the samples
+		// are inserted, rejected series and failed inserts must not move the
+		// out-of-order window.
+		for i, t := range maxTimes {
+			if allSeries[i] != nil {
+				allSeries[i].UpdateMaxTime(t)
+			}
+		}
+	}
 	if errSamples == nil && numInsertablesIngested != totalRowsExpected {
 		return numInsertablesIngested, fmt.Errorf("failed to insert all the data! Expected: %d, Got: %d", totalRowsExpected, numInsertablesIngested)
 	}
+	if errSamples == nil && outcomes.Rejected() > 0 {
+		return numInsertablesIngested, &PartialWriteError{Outcomes: outcomes}
+	}
 	return numInsertablesIngested, errSamples
 }
 
+// seenTimestamps are the timestamps of the samples, histograms and exemplars
+// of a series with several entries in a write request.
+type seenTimestamps struct {
+	samples   map[int64]struct{}
+	exemplars map[int64]struct{}
+}
+
+func newSeenTimestamps() *seenTimestamps {
+	return &seenTimestamps{
+		samples:   make(map[int64]struct{}),
+		exemplars: make(map[int64]struct{}),
+	}
+}
+
+// dedupe removes the samples, histograms and exemplars of ts at timestamps
+// which another entry of the same series already has, and records the
+// timestamps of the others in seen. It returns the removed ones, and false if
+// nothing is left to ingest.
+func dedupe(seen *seenTimestamps, ts *prompb.TimeSeries) (prompb.TimeSeries, bool) {
+	var duplicates prompb.TimeSeries
+	samples := ts.Samples[:0]
+	for _, s := range ts.Samples {
+		if _, ok := seen.samples[s.Timestamp]; ok {
+			duplicates.Samples = append(duplicates.Samples, s)
+			continue
+		}
+		seen.samples[s.Timestamp] = struct{}{}
+		samples = append(samples, s)
+	}
+	histograms := ts.Histograms[:0]
+	for _, h := range ts.Histograms {
+		if _, ok := seen.samples[h.Timestamp]; ok {
+			duplicates.Histograms = append(duplicates.Histograms, h)
+			continue
+		}
+		seen.samples[h.Timestamp] = struct{}{}
+		histograms = append(histograms, h)
+	}
+	exemplars := ts.Exemplars[:0]
+	for _, e := range ts.Exemplars {
+		if _, ok := seen.exemplars[e.Timestamp]; ok {
+			duplicates.Exemplars = append(duplicates.Exemplars, e)
+			continue
+		}
+		seen.exemplars[e.Timestamp] = struct{}{}
+		exemplars = append(exemplars, e)
+	}
+	ts.Samples, ts.Histograms, ts.Exemplars = samples, histograms, exemplars
+	return duplicates, len(samples)+len(histograms)+len(exemplars) > 0
+}
+
+func firstTimestamp(ts *prompb.TimeSeries) int64 {
+	if len(ts.Samples) > 0 {
+		return ts.Samples[0].Timestamp
+	}
+	return ts.Histograms[0].Timestamp
+}
+
 func (ingestor *DBIngestor) samples(l *model.Series, ts *prompb.TimeSeries) (model.Insertable, int, error) {
 	return model.NewPromSamples(l, ts.Samples), len(ts.Samples), nil
 }
@@ -252,6 +465,14 @@ func (ingestor *DBIngestor) exemplars(l *model.Series, ts *prompb.TimeSeries) (m
 	return model.NewPromExemplars(l, ts.Exemplars), len(ts.Exemplars), nil
 }
 
+func (ingestor *DBIngestor) histograms(l *model.Series, ts *prompb.TimeSeries) (model.Insertable, int, error) {
+	histograms, err := model.NewPromHistograms(l, ts.Histograms)
+	if err != nil {
+		return nil, 0, err
+	}
+	return histograms, len(ts.Histograms), nil
+}
+
 // ingestMetadata ingests metric metadata received from Prometheus. It runs as a secondary routine, independent from
 // the main dataflow (i.e., samples ingestion) since metadata ingestion is not as frequent as that of samples.
 func (ingestor *DBIngestor) ingestMetadata(ctx context.Context, metadata []prompb.MetricMetadata, releaseMem func()) (uint64, error) {
@@ -276,6 +497,15 @@ func (ingestor *DBIngestor) ingestMetadata(ctx context.Context, metadata []promp
 	return numMetadataIngested, nil
 }
 
+// CopierBacklog returns the fraction of the copier queue in use, which tells
+// how far ingestion falls behind.
+func (ingestor *DBIngestor) CopierBacklog() float64 {
+	if d, ok := ingestor.dispatcher.(*pgxDispatcher); ok {
+		return d.copierBacklog()
+	}
+	return 0
+}
+
 func (ingestor *DBIngestor) Dispatcher() model.Dispatcher {
 	return ingestor.dispatcher
 }
@@ -295,6 +525,7 @@ func (ingestor *DBIngestor) Close() {
 		return
 	}
 	ingestor.tWriter.Close()
+	ingestor.lWriter.Close()
 	ingestor.closed.Store(true)
 	ingestor.dispatcher.Close()
 }
@@ -307,4 +538,7 @@ func (ReadOnlyIngestor) IngestMetrics(context.Context, *prompb.WriteRequest) (nu
 func (ReadOnlyIngestor) IngestTraces(context.Context, ptrace.Traces) error {
 	return fmt.Errorf("ingesting traces not allowed in read-only mode")
 }
+func (ReadOnlyIngestor) IngestLogs(context.Context, plog.Logs) error {
+	return fmt.Errorf("ingesting logs not allowed in read-only mode")
+}
 func (ReadOnlyIngestor) Close() {}
@@ -8,6 +8,7 @@ import (
 	"context"
 
 	"github.com/timescale/promscale/pkg/prompb"
+	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 )
 
@@ -18,5 +19,12 @@ type DBInserter interface {
 	// Returns the number of metrics ingested and any error encountered before finishing.
 	IngestMetrics(context.Context, *prompb.WriteRequest) (uint64, uint64, error)
 	IngestTraces(context.Context, ptrace.Traces) error
+	IngestLogs(context.Context, plog.Logs) error
 	Close()
 }
+
+// TraceProcessor processes traces before they are ingested. Processors can
+// modify the traces, e.g. to drop spans, or reject them with an error.
+type TraceProcessor interface {
+	ProcessTraces(context.Context, ptrace.Traces) error
+}
@@ -8,11 +8,14 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
 
 	"github.com/timescale/promscale/pkg/pgmodel/cache"
 	"github.com/timescale/promscale/pkg/pgmodel/common/errors"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor/serieslimit"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/prompb"
 )
@@ -316,3 +319,257 @@ func TestDBIngestorIngest(t *testing.T) {
 		})
 	}
 }
+
+func TestDBIngestorSeriesLimit(t *testing.T) {
+	timeseries := func() []prompb.TimeSeries {
+		var ts []prompb.TimeSeries
+		for _, instance := range []string{"1", "2", "3"} {
+			ts = append(ts, prompb.TimeSeries{
+				Labels: []prompb.Label{
+					{Name: model.MetricNameLabelName, Value: "test"},
+					{Name: "instance", Value: instance},
+				},
+				Samples: []prompb.Sample{{Timestamp: 1, Value: 0.1}},
+			})
+		}
+		return ts
+	}
+	for _, action := range []serieslimit.Action{serieslimit.ActionReject, serieslimit.ActionDrop, serieslimit.ActionLog} {
+		t.Run(string(action), func(t *testing.T) {
+			inserter := model.MockInserter{InsertedSeries: make(map[string]model.SeriesID)}
+			i := DBIngestor{
+				dispatcher: &inserter,
+				sCache:     cache.NewSeriesCache(cache.DefaultConfig, nil),
+				seriesLimiter: serieslimit.NewLimiter(serieslimit.Config{
+					MaxActiveSeriesPerMetric: 2,
+					NewSeriesInterval:        time.Minute,
+					ActiveWindow:             time.Minute,
+					Action:                   string(action),
+				}),
+				closed: atomic.NewBool(false),
+			}
+
+			wr := NewWriteRequest()
+			wr.Timeseries = timeseries()
+			countSamples, _, err := i.IngestMetrics(context.Background(), wr)
+			switch action {
+			case serieslimit.ActionReject:
+				require.ErrorIs(t, err, serieslimit.ErrLimitExceeded)
+				require.Equal(t, uint64(0), countSamples)
+			case serieslimit.ActionDrop:
+				var partialErr *PartialWriteError
+				require.ErrorAs(t, err, &partialErr)
+				require.Equal(t, 1, partialErr.Outcomes.Counts[RejectedByLimit].Series)
+				require.Equal(t, uint64(2), countSamples)
+			case serieslimit.ActionLog:
+				require.NoError(t, err)
+				require.Equal(t, uint64(3), countSamples)
+			}
+		})
+	}
+}
+
+func TestDBIngestorSampleBounds(t *testing.T) {
+	series := func(instance string, timestamp int64) prompb.TimeSeries {
+		return prompb.TimeSeries{
+			Labels: []prompb.Label{
+				{Name: model.MetricNameLabelName, Value: "test"},
+				{Name: "instance", Value: instance},
+			},
+			Samples: []prompb.Sample{{Timestamp: timestamp, Value: 0.1}},
+		}
+	}
+	bounds := newSampleBounds(&Cfg{MaxSampleAge: 500 * time.Second, OutOfOrderWindow: 100 * time.Second, OutOfBoundsAction: OutOfBoundsReject})
+	bounds.now = func() time.Time { return time.Unix(1000, 0) }
+	inserter := model.MockInserter{InsertedSeries: make(map[string]model.SeriesID)}
+	i := DBIngestor{
+		dispatcher: &inserter,
+		sCache:     cache.NewSeriesCache(cache.DefaultConfig, nil),
+		seriesLimiter: serieslimit.NewLimiter(serieslimit.Config{
+			MaxActiveSeriesPerMetric: 1,
+			NewSeriesInterval:        time.Minute,
+			ActiveWindow:             time.Minute,
+			Action:                   string(serieslimit.ActionDrop),
+		}),
+		bounds: bounds,
+		closed: atomic.NewBool(false),
+	}
+
+	// Series with samples out of bounds do not count against the series limits.
+	wr := NewWriteRequest()
+	wr.Timeseries = []prompb.TimeSeries{series("1", 100_000), series("2", 900_000)}
+	countSamples, _, err := i.IngestMetrics(context.Background(), wr)
+	var partialErr *PartialWriteError
+	require.ErrorAs(t, err, &partialErr)
+	require.Equal(t, 1, partialErr.Outcomes.Counts[Invalid].Series)
+	require.Equal(t, 0, partialErr.Outcomes.Counts[RejectedByLimit].Series)
+	require.Equal(t, uint64(1), countSamples)
+
+	// Failed inserts do not move the out-of-order window.
+	ts := series("2", 990_000)
+	s, _, err := i.sCache.GetSeriesFromProtos(ts.Labels)
+	require.NoError(t, err)
+	require.Equal(t, int64(900_000), s.MaxTime())
+	inserter.InsertDataErr = fmt.Errorf("insert failed")
+	wr = NewWriteRequest()
+	wr.Timeseries = []prompb.TimeSeries{ts}
+	_, _, err = i.IngestMetrics(context.Background(), wr)
+	require.Error(t, err)
+	require.Equal(t, int64(900_000), s.MaxTime())
+}
+
+func TestDBIngestorCreatedZeros(t *testing.T) {
+	series := func(timestamp int64) prompb.TimeSeries {
+		return prompb.TimeSeries{
+			Labels: []prompb.Label{{Name: model.MetricNameLabelName, Value: "test"}},
+			Samples: []prompb.Sample{
+				{Timestamp: 1000, Value: prompb.CreatedZero},
+				{Timestamp: timestamp, Value: 5},
+			},
+		}
+	}
+	inserter := model.MockInserter{InsertedSeries: make(map[string]model.SeriesID)}
+	i := DBIngestor{
+		dispatcher: &inserter,
+		sCache:     cache.NewSeriesCache(cache.DefaultConfig, nil),
+		closed:     atomic.NewBool(false),
+	}
+
+	// The zero of the created timestamp is written as such.
+	wr := NewWriteRequest()
+	wr.Timeseries = []prompb.TimeSeries{series(2000)}
+	countSamples, _, err := i.IngestMetrics(context.Background(), wr)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), countSamples)
+	it := inserter.InsertedData[0]["test"][0].Iterator().(model.SamplesIterator)
+	ts, v := it.Value()
+	require.Equal(t, int64(1000), ts)
+	require.Equal(t, 0.0, v)
+
+	// Later requests repeat the created timestamp, which is not written again.
+	wr = NewWriteRequest()
+	wr.Timeseries = []prompb.TimeSeries{series(3000)}
+	countSamples, _, err = i.IngestMetrics(context.Background(), wr)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), countSamples)
+
+	// Series which are not cached, e.g. after a restart, are looked up in the
+	// database, and get no zero if they exist.
+	i.sCache = cache.NewSeriesCache(cache.DefaultConfig, nil)
+	i.createdZeros = &createdZeros{conn: model.NewSqlRecorder([]model.SqlQuery{
+		{
+			Sql:     existingSeriesSQL,
+			Args:    []interface{}{[]int32{1}, []string{model.MetricNameLabelName}, []string{"test"}, []int32{1}},
+			Results: model.RowResults{{int64(1)}},
+		},
+	}, t)}
+	wr = NewWriteRequest()
+	wr.Timeseries = []prompb.TimeSeries{series(4000)}
+	countSamples, _, err = i.IngestMetrics(context.Background(), wr)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), countSamples)
+}
+
+func TestDBIngestorCreatedZerosOutOfBounds(t *testing.T) {
+	// A long running counter, created long before the maximum sample age.
+	series := func(timestamp int64) prompb.TimeSeries {
+		return prompb.TimeSeries{
+			Labels: []prompb.Label{{Name: model.MetricNameLabelName, Value: "test"}},
+			Samples: []prompb.Sample{
+				{Timestamp: 1000, Value: prompb.CreatedZero},
+				{Timestamp: timestamp, Value: 5},
+			},
+		}
+	}
+	bounds := newSampleBounds(&Cfg{MaxSampleAge: 500 * time.Second, OutOfOrderWindow: 100 * time.Second, OutOfBoundsAction: OutOfBoundsReject})
+	bounds.now = func() time.Time { return time.Unix(1000, 0) }
+	inserter := model.MockInserter{InsertedSeries: make(map[string]model.SeriesID)}
+	i := DBIngestor{
+		dispatcher: &inserter,
+		sCache:     cache.NewSeriesCache(cache.DefaultConfig, nil),
+		bounds:     bounds,
+		createdZeros: &createdZeros{conn: model.NewSqlRecorder([]model.SqlQuery{
+			{
+				Sql:     existingSeriesSQL,
+				Args:    []interface{}{[]int32{1}, []string{model.MetricNameLabelName}, []string{"test"}, []int32{1}},
+				Results: model.RowResults{},
+			},
+		}, t)},
+		closed: atomic.NewBool(false),
+	}
+
+	// The zero of a new series older than the maximum sample age is left out,
+	// without rejecting the series.
+	wr := NewWriteRequest()
+	wr.Timeseries = []prompb.TimeSeries{series(900_000)}
+	countSamples, _, err := i.IngestMetrics(context.Background(), wr)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), countSamples)
+
+	// Later requests are not rejected either.
+	wr = NewWriteRequest()
+	wr.Timeseries = []prompb.TimeSeries{series(950_000)}
+	countSamples, _, err = i.IngestMetrics(context.Background(), wr)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), countSamples)
+}
+
+func TestDBIngestorOutcomes(t *testing.T) {
+	series := func(labels ...string) []prompb.Label {
+		var res []prompb.Label
+		for i := 0; i < len(labels); i += 2 {
+			res = append(res, prompb.Label{Name: labels[i], Value: labels[i+1]})
+		}
+		return res
+	}
+	samples := func(timestamps ...int64) []prompb.Sample {
+		var res []prompb.Sample
+		for _, t := range timestamps {
+			res = append(res, prompb.Sample{Timestamp: t, Value: 0.1})
+		}
+		return res
+	}
+	exemplars := func(timestamps ...int64) []prompb.Exemplar {
+		var res []prompb.Exemplar
+		for _, t := range timestamps {
+			res = append(res, prompb.Exemplar{Labels: series("trace_id", "abc"), Timestamp: t, Value: 0.1})
+		}
+		return res
+	}
+
+	inserter := model.MockInserter{InsertedSeries: make(map[string]model.SeriesID)}
+	i := DBIngestor{
+		dispatcher: &inserter,
+		sCache:     cache.NewSeriesCache(cache.DefaultConfig, nil),
+		closed:     atomic.NewBool(false),
+	}
+	wr := NewWriteRequest()
+	wr.Timeseries = []prompb.TimeSeries{
+		{Labels: series(model.MetricNameLabelName, "test", "job", "a"), Samples: samples(1, 2), Exemplars: exemplars(2)},
+		{Labels: series("job", "b"), Samples: samples(1)},
+		{Labels: series(model.MetricNameLabelName, "test", "job", "a"), Samples: samples(2, 3), Exemplars: exemplars(2, 3)},
+		{Labels: series(model.MetricNameLabelName, "test", "job", "a"), Samples: samples(1), Exemplars: exemplars(2)},
+	}
+	source := model.Source{Tenant: "tenant", Client: "10.0.0.1"}
+	ctx := model.NewSourceContext(context.Background(), &source)
+	countSamples, _, err := i.IngestMetrics(ctx, wr)
+	var partialErr *PartialWriteError
+	require.ErrorAs(t, err, &partialErr)
+	require.ErrorIs(t, partialErr.Outcomes.Errors[0], errors.ErrNoMetricName)
+	require.Equal(t, uint64(5), countSamples)
+	require.Equal(t, OutcomeCount{Series: 2, Samples: 3, Exemplars: 2}, partialErr.Outcomes.Counts[Accepted])
+	require.Equal(t, OutcomeCount{Series: 1, Samples: 2, Exemplars: 2}, partialErr.Outcomes.Counts[Duplicate])
+	require.Equal(t, OutcomeCount{Series: 1, Samples: 1}, partialErr.Outcomes.Counts[Invalid])
+	require.Equal(t, 1, partialErr.Outcomes.Rejected())
+
+	// Duplicates are attributed to the source of the request.
+	recent := RecentDuplicates("test", 2)
+	require.Len(t, recent, 2)
+	for _, e := range recent {
+		require.Equal(t, DetectedInWriteRequest, e.Detected)
+		require.Equal(t, source, e.Source)
+		require.Equal(t, `{__name__="test", job="a"}`, e.Series)
+	}
+	require.Equal(t, int64(1), recent[0].Timestamp)
+	require.Equal(t, int64(2), recent[1].Timestamp)
+}
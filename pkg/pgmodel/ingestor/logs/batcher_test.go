@@ -0,0 +1,75 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package logs
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+type noopWriter struct {
+	mu      sync.Mutex
+	batches []int
+}
+
+func (nw *noopWriter) InsertLogs(_ context.Context, logs plog.Logs) error {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	nw.batches = append(nw.batches, logs.LogRecordCount())
+	return nil
+}
+
+func (nw *noopWriter) Close() {}
+
+func generateTestLogs(records int) plog.Logs {
+	logs := plog.NewLogs()
+	lr := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	for i := 0; i < records; i++ {
+		lr.AppendEmpty().Body().SetStr("message")
+	}
+	return logs
+}
+
+func TestLogBatcherBatching(t *testing.T) {
+	writer := &noopWriter{}
+	batcher := NewBatcher(BatcherConfig{
+		BatchTimeout: time.Hour, // we set long enough batch timeout
+		MaxBatchSize: 100,
+		Writers:      1,
+		Batchers:     1,
+	}, writer)
+	batcher.Run()
+	for i := 0; i < 4; i++ {
+		batcher.in[0] <- insertLogsReq{payload: generateTestLogs(50), response: make(chan error, 1)}
+	}
+	batcher.Stop()
+	require.Equal(t, []int{100, 100}, writer.batches)
+}
+
+func TestLogBatcherTimeout(t *testing.T) {
+	writer := &noopWriter{}
+	batcher := NewBatcher(BatcherConfig{Writers: 1, Batchers: 1, BatchTimeout: 50 * time.Millisecond}, writer)
+	batcher.Run()
+	for i := 0; i < 3; i++ {
+		batcher.in[0] <- insertLogsReq{payload: generateTestLogs(10), response: make(chan error, 1)}
+		time.Sleep(200 * time.Millisecond) // to make sure batch timeout is reached
+	}
+	batcher.Stop()
+	require.Equal(t, []int{10, 10, 10}, writer.batches)
+}
+
+func TestLogDispatcherEmptyRequest(t *testing.T) {
+	dispatcher := NewDispatcher(&noopWriter{}, false, BatcherConfig{Writers: 1, Batchers: 1})
+	defer dispatcher.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, dispatcher.InsertLogs(ctx, plog.NewLogs()))
+}
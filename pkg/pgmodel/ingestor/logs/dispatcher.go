@@ -0,0 +1,87 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package logs
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/metrics"
+	"github.com/timescale/promscale/pkg/tracer"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/otel/trace"
+	uber_atomic "go.uber.org/atomic"
+)
+
+// Dispatcher manages log batcher and ingestion.
+// Dispatches user requests which are combined into batches later on.
+type Dispatcher struct {
+	batcher       *Batcher
+	async         bool   // 'true' means that we don't wait for request to be persisted
+	curBatcherIdx uint32 // used for round-robin
+	stopped       *uber_atomic.Bool
+}
+
+func NewDispatcher(writer Writer, async bool, batchConfig BatcherConfig) *Dispatcher {
+	batcher := NewBatcher(batchConfig, writer)
+	batcher.Run()
+	return &Dispatcher{batcher: batcher, async: async, stopped: uber_atomic.NewBool(false)}
+}
+
+type insertLogsReq struct {
+	payload  plog.Logs
+	response chan error
+	spanCtx  trace.SpanContext
+}
+
+func (ld *Dispatcher) InsertLogs(ctx context.Context, logs plog.Logs) (err error) {
+	code := "200"
+	defer func() {
+		if err != nil {
+			code = "500"
+		}
+		if !ld.async {
+			metrics.IngestorRequests.With(prometheus.Labels{"type": "log", "code": code}).Inc()
+		}
+	}()
+	_, span := tracer.Default().Start(ctx, "log-dispatcher")
+	defer span.End()
+	if ld.stopped.Load() {
+		return fmt.Errorf("log dispatcher stopped")
+	}
+	// Unlike spans, log records of a request do not need to end up in the same
+	// batch, hence batchers are always picked round-robin.
+	batcherIdx := int(atomic.AddUint32(&ld.curBatcherIdx, 1)) % ld.batcher.config.Batchers
+	req := insertLogsReq{payload: logs, response: make(chan error, 1), spanCtx: span.SpanContext()}
+	ld.batcher.send(req, batcherIdx)
+	metrics.IngestorRequestsQueued.With(prometheus.Labels{"type": "log", "queue_idx": fmt.Sprintf("%d", batcherIdx)}).Inc()
+	span.AddEvent("Log request dispatched")
+	if ld.async {
+		go func() {
+			err := <-req.response
+			code := "200"
+			if err != nil {
+				log.Error("async", ld.async, "error", err)
+				code = "500"
+			}
+			metrics.IngestorRequests.With(prometheus.Labels{"type": "log", "code": code}).Inc()
+		}()
+		return nil
+	}
+	select {
+	case err = <-req.response:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return err
+}
+
+func (ld *Dispatcher) Close() {
+	ld.stopped.Store(true)
+	ld.batcher.Stop()
+}
@@ -0,0 +1,162 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package logs
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+
+	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
+	"github.com/timescale/promscale/pkg/pgmodel/metrics"
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+const logTable = "log"
+
+var (
+	logTableColumns = []string{"time", "observed_time", "trace_id", "span_id", "trace_flags", "severity_number", "severity_text",
+		"body", "attributes", "dropped_attributes_count", "resource_attributes", "resource_schema_url", "scope_name", "scope_version"}
+
+	logRecordLabel = prometheus.Labels{"type": "log", "kind": "record"}
+)
+
+type Writer interface {
+	InsertLogs(ctx context.Context, logs plog.Logs) error
+	Close()
+}
+
+type logWriterImpl struct {
+	conn pgxconn.PgxConn
+}
+
+func NewWriter(conn pgxconn.PgxConn) *logWriterImpl {
+	return &logWriterImpl{conn: conn}
+}
+
+func (l *logWriterImpl) InsertLogs(ctx context.Context, logs plog.Logs) error {
+	startIngest := time.Now() // Time taken for complete ingestion => Processing + DB insert.
+	code := "500"
+	metrics.IngestorActiveWriteRequests.With(logRecordLabel).Inc()
+	metrics.IngestorItemsReceived.With(logRecordLabel).Add(float64(logs.LogRecordCount()))
+	defer func() {
+		metrics.IngestorDuration.With(prometheus.Labels{"type": "log", "code": code}).Observe(time.Since(startIngest).Seconds())
+		metrics.IngestorActiveWriteRequests.With(logRecordLabel).Dec()
+	}()
+
+	rows, maxTime := logRows(logs, startIngest)
+	if len(rows) == 0 {
+		code = "2xx"
+		return nil
+	}
+	metrics.InsertBatchSize.With(logRecordLabel).Observe(float64(len(rows)))
+
+	start := time.Now()
+	if _, err := l.conn.CopyFrom(ctx, pgx.Identifier{schema.PsLog, logTable}, logTableColumns, l.conn.CopyFromRows(rows)); err != nil {
+		return fmt.Errorf("error inserting log records: %w", err)
+	}
+	metrics.IngestorInsertDuration.With(prometheus.Labels{"type": "log", "subsystem": "", "kind": "record"}).Observe(time.Since(start).Seconds())
+	metrics.IngestorItems.With(prometheus.Labels{"type": "log", "kind": "record", "subsystem": ""}).Add(float64(len(rows)))
+	metrics.IngestorMaxSentTimestamp.With(logLabel).Set(float64(maxTime.UnixNano() / 1e6))
+	code = "2xx"
+	return nil
+}
+
+func (l *logWriterImpl) Close() {}
+
+// logRows converts the log records into rows of the log table. It also returns
+// the latest timestamp among the records.
+func logRows(logs plog.Logs, received time.Time) ([][]interface{}, time.Time) {
+	var (
+		rows    = make([][]interface{}, 0, logs.LogRecordCount())
+		maxTime time.Time
+	)
+	rLogs := logs.ResourceLogs()
+	for i := 0; i < rLogs.Len(); i++ {
+		rLog := rLogs.At(i)
+		resourceAttributes := rLog.Resource().Attributes().AsRaw()
+		resourceSchemaURL := nullableText(rLog.SchemaUrl())
+
+		scopeLogs := rLog.ScopeLogs()
+		for j := 0; j < scopeLogs.Len(); j++ {
+			scopeLog := scopeLogs.At(j)
+			scope := scopeLog.Scope()
+			records := scopeLog.LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				record := records.At(k)
+				t := recordTime(record, received)
+				if maxTime.Before(t) {
+					maxTime = t
+				}
+				rows = append(rows, []interface{}{
+					t,
+					nullableTimestamp(record.ObservedTimestamp()),
+					traceIDToUUID(record.TraceID()),
+					spanIDToInt8(record.SpanID()),
+					int32(record.Flags()),
+					int16(record.SeverityNumber()),
+					nullableText(record.SeverityText()),
+					record.Body().AsString(),
+					record.Attributes().AsRaw(),
+					int32(record.DroppedAttributesCount()),
+					resourceAttributes,
+					resourceSchemaURL,
+					nullableText(scope.Name()),
+					nullableText(scope.Version()),
+				})
+			}
+		}
+	}
+	return rows, maxTime
+}
+
+// recordTime returns the time of the log record. As per the OpenTelemetry data model,
+// the observed timestamp is used when the record was sent without a timestamp.
+func recordTime(record plog.LogRecord, received time.Time) time.Time {
+	// postgresql timestamptz only has microsecond precision while time.Time has nanosecond precision
+	switch {
+	case record.Timestamp() != 0:
+		return record.Timestamp().AsTime().Truncate(time.Microsecond)
+	case record.ObservedTimestamp() != 0:
+		return record.ObservedTimestamp().AsTime().Truncate(time.Microsecond)
+	default:
+		return received.Truncate(time.Microsecond)
+	}
+}
+
+func nullableTimestamp(ts pcommon.Timestamp) pgtype.Timestamptz {
+	if ts == 0 {
+		return pgtype.Timestamptz{Status: pgtype.Null}
+	}
+	return pgtype.Timestamptz{Time: ts.AsTime().Truncate(time.Microsecond), Status: pgtype.Present}
+}
+
+func nullableText(s string) pgtype.Text {
+	if s == "" {
+		return pgtype.Text{Status: pgtype.Null}
+	}
+	return pgtype.Text{String: s, Status: pgtype.Present}
+}
+
+func traceIDToUUID(id pcommon.TraceID) pgtype.UUID {
+	if id.IsEmpty() {
+		return pgtype.UUID{Status: pgtype.Null}
+	}
+	return pgtype.UUID{Bytes: id, Status: pgtype.Present}
+}
+
+func spanIDToInt8(id pcommon.SpanID) pgtype.Int8 {
+	if id.IsEmpty() {
+		return pgtype.Int8{Status: pgtype.Null}
+	}
+	return pgtype.Int8{Int: int64(binary.BigEndian.Uint64(id[:])), Status: pgtype.Present}
+}
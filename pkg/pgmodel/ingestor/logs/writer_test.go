@@ -0,0 +1,79 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package logs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestLogRows(t *testing.T) {
+	var (
+		received = time.Unix(300, 0).UTC()
+		observed = time.Unix(200, 0).UTC()
+		logged   = time.Unix(100, 123456789).UTC()
+	)
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.SetSchemaUrl("https://opentelemetry.io/schemas/1.9.0")
+	rl.Resource().Attributes().PutString("service.name", "checkout")
+	sl := rl.ScopeLogs().AppendEmpty()
+	sl.Scope().SetName("logger")
+
+	record := sl.LogRecords().AppendEmpty()
+	record.SetTimestamp(pcommon.NewTimestampFromTime(logged))
+	record.SetObservedTimestamp(pcommon.NewTimestampFromTime(observed))
+	record.SetTraceID(pcommon.TraceID([16]byte{15: 1}))
+	record.SetSpanID(pcommon.SpanID([8]byte{7: 2}))
+	record.SetSeverityNumber(plog.SeverityNumberError)
+	record.SetSeverityText("ERROR")
+	record.Body().SetStr("payment failed")
+	record.Attributes().PutInt("attempt", 3)
+
+	// Neither timestamp is set.
+	sl.LogRecords().AppendEmpty().Body().SetStr("no timestamp")
+
+	rows, maxTime := logRows(logs, received)
+	require.Equal(t, received, maxTime)
+	require.Equal(t, [][]interface{}{
+		{
+			logged.Truncate(time.Microsecond),
+			pgtype.Timestamptz{Time: observed, Status: pgtype.Present},
+			pgtype.UUID{Bytes: [16]byte{15: 1}, Status: pgtype.Present},
+			pgtype.Int8{Int: 2, Status: pgtype.Present},
+			int32(0),
+			int16(plog.SeverityNumberError),
+			pgtype.Text{String: "ERROR", Status: pgtype.Present},
+			"payment failed",
+			map[string]interface{}{"attempt": int64(3)},
+			int32(0),
+			map[string]interface{}{"service.name": "checkout"},
+			pgtype.Text{String: "https://opentelemetry.io/schemas/1.9.0", Status: pgtype.Present},
+			pgtype.Text{String: "logger", Status: pgtype.Present},
+			pgtype.Text{Status: pgtype.Null},
+		},
+		{
+			received,
+			pgtype.Timestamptz{Status: pgtype.Null},
+			pgtype.UUID{Status: pgtype.Null},
+			pgtype.Int8{Status: pgtype.Null},
+			int32(0),
+			int16(0),
+			pgtype.Text{Status: pgtype.Null},
+			"no timestamp",
+			map[string]interface{}{},
+			int32(0),
+			map[string]interface{}{"service.name": "checkout"},
+			pgtype.Text{String: "https://opentelemetry.io/schemas/1.9.0", Status: pgtype.Present},
+			pgtype.Text{String: "logger", Status: pgtype.Present},
+			pgtype.Text{Status: pgtype.Null},
+		},
+	}, rows)
+}
@@ -0,0 +1,127 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package ingestor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/timescale/promscale/pkg/pgmodel/metrics"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+// Outcome is the outcome of the ingestion of a series of a write request.
+type Outcome int8
+
+const (
+	// Accepted series are ingested.
+	Accepted Outcome = iota
+	// Duplicate series only have samples at timestamps which another entry
+	// of the same series in the write request already has.
+	Duplicate
+	// RejectedByLimit series exceed a series limit.
+	RejectedByLimit
+	// Invalid series cannot be ingested, e.g. because they have no metric
+	// name or samples out of bounds.
+	Invalid
+
+	numOutcomes = iota
+)
+
+// maxOutcomeErrors is the number of errors of rejected series reported.
+const maxOutcomeErrors = 10
+
+func (o Outcome) String() string {
+	switch o {
+	case Accepted:
+		return "accepted"
+	case Duplicate:
+		return "duplicate"
+	case RejectedByLimit:
+		return "rejected_by_limit"
+	case Invalid:
+		return "invalid"
+	}
+	return "unknown"
+}
+
+// OutcomeCount is the number of series of a write request with an outcome,
+// along with their samples, histograms and exemplars.
+type OutcomeCount struct {
+	Series     int
+	Samples    int
+	Histograms int
+	Exemplars  int
+}
+
+// Outcomes are the outcomes of the series of a write request.
+type Outcomes struct {
+	Counts [numOutcomes]OutcomeCount
+	// CreatedZeros are the zero valued samples and histograms written for
+	// the created timestamps of accepted series. They are counted as
+	// accepted, although they were not sent.
+	CreatedZeros OutcomeCount
+	// The errors of the first rejected series.
+	Errors []error
+}
+
+func (o *Outcomes) add(outcome Outcome, ts *prompb.TimeSeries, err error) {
+	c := &o.Counts[outcome]
+	c.Series++
+	c.Samples += len(ts.Samples)
+	c.Histograms += len(ts.Histograms)
+	c.Exemplars += len(ts.Exemplars)
+	if err != nil && len(o.Errors) < maxOutcomeErrors {
+		o.Errors = append(o.Errors, err)
+	}
+}
+
+// Rejected returns the number of series which are rejected.
+func (o *Outcomes) Rejected() int {
+	return o.Counts[RejectedByLimit].Series + o.Counts[Invalid].Series
+}
+
+func (o *Outcomes) report() {
+	for i := range o.Counts {
+		c := o.Counts[i]
+		if c.Series == 0 {
+			continue
+		}
+		outcome := Outcome(i).String()
+		metrics.IngestorSeriesOutcomes.With(prometheus.Labels{"type": "metric", "kind": "series", "outcome": outcome}).Add(float64(c.Series))
+		metrics.IngestorSeriesOutcomes.With(prometheus.Labels{"type": "metric", "kind": "sample", "outcome": outcome}).Add(float64(c.Samples))
+		metrics.IngestorSeriesOutcomes.With(prometheus.Labels{"type": "metric", "kind": "histogram", "outcome": outcome}).Add(float64(c.Histograms))
+		metrics.IngestorSeriesOutcomes.With(prometheus.Labels{"type": "metric", "kind": "exemplar", "outcome": outcome}).Add(float64(c.Exemplars))
+	}
+}
+
+// PartialWriteError is returned when some series of a write request are
+// rejected, while the others are ingested. Sending the rejected series again
+// does not help, hence the error is not retryable.
+type PartialWriteError struct {
+	Outcomes Outcomes
+}
+
+func (e *PartialWriteError) Error() string {
+	var b strings.Builder
+	invalid, limited := e.Outcomes.Counts[Invalid], e.Outcomes.Counts[RejectedByLimit]
+	fmt.Fprintf(&b, "%d of %d series rejected: %d invalid with %d samples, %d exceeding limits with %d samples",
+		e.Outcomes.Rejected(), e.total(), invalid.Series, invalid.Samples+invalid.Histograms, limited.Series, limited.Samples+limited.Histograms)
+	for _, err := range e.Outcomes.Errors {
+		b.WriteString("; ")
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+func (e *PartialWriteError) total() int {
+	total := 0
+	for _, c := range e.Outcomes.Counts {
+		total += c.Series
+	}
+	return total
+}
@@ -0,0 +1,135 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package ingestor
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/timestamp"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/common/errors"
+	"github.com/timescale/promscale/pkg/pgmodel/metrics"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+const (
+	// OutOfBoundsReject rejects the series with samples out of bounds.
+	OutOfBoundsReject = "reject"
+	// OutOfBoundsDrop drops the samples out of bounds and ingests the others
+	// of their series.
+	OutOfBoundsDrop = "drop"
+
+	reasonTooOld     = "too_old"
+	reasonOutOfOrder = "out_of_order"
+)
+
+// sampleBounds checks that samples are not older than the maximum sample age,
+// nor older than the out-of-order window before the newest sample of their
+// series. It keeps misbehaving clients from writing into compressed chunks.
+type sampleBounds struct {
+	maxAge           int64
+	outOfOrderWindow int64
+	drop             bool
+	now              func() time.Time
+}
+
+// newSampleBounds returns nil if neither the maximum sample age nor the
+// out-of-order window is set.
+func newSampleBounds(cfg *Cfg) *sampleBounds {
+	if cfg.MaxSampleAge <= 0 && cfg.OutOfOrderWindow <= 0 {
+		return nil
+	}
+	return &sampleBounds{
+		maxAge:           cfg.MaxSampleAge.Milliseconds(),
+		outOfOrderWindow: cfg.OutOfOrderWindow.Milliseconds(),
+		drop:             cfg.OutOfBoundsAction == OutOfBoundsDrop,
+		now:              time.Now,
+	}
+}
+
+// check removes the samples and histograms of ts which are out of bounds if
+// they are to be dropped, or returns an error wrapping ErrSampleOutOfBounds.
+// It returns the timestamp of the newest sample of ts, which must be recorded
+// on the series once the samples are ingested. The zero of the created
+// timestamp, see prompb.CreatedZero, is only checked against the maximum
+// sample age and never causes an error.
+func (b *sampleBounds) check(series *model.Series, ts *prompb.TimeSeries) (int64, error) {
+	minTime := int64(math.MinInt64)
+	if b.maxAge > 0 {
+		minTime = timestamp.FromTime(b.now()) - b.maxAge
+	}
+	maxTime := series.MaxTime()
+	reason := func(t int64) string {
+		switch {
+		case t < minTime:
+			return reasonTooOld
+		case b.outOfOrderWindow > 0 && t < maxTime-b.outOfOrderWindow:
+			return reasonOutOfOrder
+		}
+		if t > maxTime {
+			maxTime = t
+		}
+		return ""
+	}
+
+	var err error
+	keep := func(kind string, t int64, createdZero bool) bool {
+		if createdZero {
+			// The zero of the created timestamp of a new series was not
+			// sent, it never rejects the series and is left out when older
+			// than the maximum sample age.
+			return t >= minTime
+		}
+		r := reason(t)
+		if r == "" {
+			return true
+		}
+		if err == nil && !b.drop {
+			err = fmt.Errorf("%w: %s of series %s at %s is %s", errors.ErrSampleOutOfBounds, kind, model.FormatLabels(ts.Labels), timestamp.Time(t).Format(time.RFC3339Nano), describe(r))
+		}
+		metrics.IngestorOutOfBoundsSamples.With(prometheus.Labels{"type": kind, "reason": r, "action": b.action()}).Inc()
+		return false
+	}
+
+	samples := ts.Samples[:0]
+	for _, s := range ts.Samples {
+		if keep("sample", s.Timestamp, s.IsCreatedZero()) {
+			samples = append(samples, s)
+		}
+	}
+	histograms := ts.Histograms[:0]
+	for _, h := range ts.Histograms {
+		if keep("histogram", h.Timestamp, h.IsCreatedZero()) {
+			histograms = append(histograms, h)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	if dropped := len(ts.Samples) - len(samples) + len(ts.Histograms) - len(histograms); dropped > 0 {
+		log.DebugRateLimited("msg", "Dropped samples out of bounds", "metric", series.MetricName())
+	}
+	ts.Samples, ts.Histograms = samples, histograms
+	return maxTime, nil
+}
+
+func (b *sampleBounds) action() string {
+	if b.drop {
+		return OutOfBoundsDrop
+	}
+	return OutOfBoundsReject
+}
+
+func describe(reason string) string {
+	if reason == reasonTooOld {
+		return "older than the maximum sample age"
+	}
+	return "older than the out-of-order window"
+}
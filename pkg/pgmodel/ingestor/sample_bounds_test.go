@@ -0,0 +1,62 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package ingestor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/timescale/promscale/pkg/pgmodel/common/errors"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+func TestSampleBounds(t *testing.T) {
+	labels := []prompb.Label{{Name: model.MetricNameLabelName, Value: "test"}}
+	timeseries := func(timestamps ...int64) *prompb.TimeSeries {
+		ts := &prompb.TimeSeries{Labels: labels}
+		for _, t := range timestamps {
+			ts.Samples = append(ts.Samples, prompb.Sample{Timestamp: t})
+		}
+		return ts
+	}
+	timestamps := func(ts *prompb.TimeSeries) []int64 {
+		var res []int64
+		for _, s := range ts.Samples {
+			res = append(res, s.Timestamp)
+		}
+		return res
+	}
+	now := time.Unix(1000, 0)
+
+	require.Nil(t, newSampleBounds(&Cfg{OutOfBoundsAction: OutOfBoundsDrop}))
+
+	drop := newSampleBounds(&Cfg{MaxSampleAge: 500 * time.Second, OutOfOrderWindow: 100 * time.Second, OutOfBoundsAction: OutOfBoundsDrop})
+	drop.now = func() time.Time { return now }
+	series := model.NewSeries("test", labels)
+
+	ts := timeseries(400_000, 500_000, 700_000, 590_000, 610_000)
+	maxTime, err := drop.check(series, ts)
+	require.NoError(t, err)
+	require.Equal(t, int64(700_000), maxTime)
+	require.Equal(t, []int64{500_000, 700_000, 610_000}, timestamps(ts))
+
+	// The out-of-order window starts from the newest sample ingested before.
+	series.UpdateMaxTime(maxTime)
+	ts = timeseries(599_000, 650_000)
+	_, err = drop.check(series, ts)
+	require.NoError(t, err)
+	require.Equal(t, []int64{650_000}, timestamps(ts))
+
+	reject := newSampleBounds(&Cfg{MaxSampleAge: 500 * time.Second, OutOfBoundsAction: OutOfBoundsReject})
+	reject.now = func() time.Time { return now }
+	_, err = reject.check(series, timeseries(600_000, 400_000))
+	require.ErrorIs(t, err, errors.ErrSampleOutOfBounds)
+	require.Contains(t, err.Error(), `series {__name__="test"}`)
+	_, err = reject.check(series, timeseries(600_000))
+	require.NoError(t, err)
+}
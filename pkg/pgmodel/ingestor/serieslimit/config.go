@@ -0,0 +1,78 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package serieslimit
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+// Action is what happens to the series exceeding a limit.
+type Action string
+
+const (
+	// ActionReject rejects the whole write request.
+	ActionReject Action = "reject"
+	// ActionDrop drops the series exceeding the limit, the other series of
+	// the write request are ingested.
+	ActionDrop Action = "drop"
+	// ActionLog only logs the series exceeding the limit, they are ingested.
+	ActionLog Action = "log"
+
+	defaultNewSeriesInterval = time.Minute
+	defaultActiveWindow      = 20 * time.Minute
+)
+
+// Config configures the limits on the number of series ingested. A limit of 0
+// disables it.
+type Config struct {
+	MaxActiveSeries          int
+	MaxActiveSeriesPerMetric int
+	MaxNewSeries             int
+	MaxNewSeriesPerMetric    int
+	NewSeriesInterval        time.Duration
+	ActiveWindow             time.Duration
+	Action                   string
+}
+
+// Enabled returns true if any of the limits is set.
+func (cfg *Config) Enabled() bool {
+	return cfg.MaxActiveSeries > 0 || cfg.MaxActiveSeriesPerMetric > 0 || cfg.MaxNewSeries > 0 || cfg.MaxNewSeriesPerMetric > 0
+}
+
+func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
+	fs.IntVar(&cfg.MaxActiveSeries, "metrics.series-limit.max-active-series", 0, "Maximum number of active series across all metrics. 0 disables the limit.")
+	fs.IntVar(&cfg.MaxActiveSeriesPerMetric, "metrics.series-limit.max-active-series-per-metric", 0, "Maximum number of active series of a single metric. 0 disables the limit.")
+	fs.IntVar(&cfg.MaxNewSeries, "metrics.series-limit.max-new-series", 0, "Maximum number of new series across all metrics per new series interval. 0 disables the limit.")
+	fs.IntVar(&cfg.MaxNewSeriesPerMetric, "metrics.series-limit.max-new-series-per-metric", 0, "Maximum number of new series of a single metric per new series interval. 0 disables the limit.")
+	fs.DurationVar(&cfg.NewSeriesInterval, "metrics.series-limit.new-series-interval", defaultNewSeriesInterval, "Interval over which new series are counted towards the new series limits.")
+	fs.DurationVar(&cfg.ActiveWindow, "metrics.series-limit.active-window", defaultActiveWindow, "Time after which a series that stopped receiving samples is not active anymore. "+
+		"A series is new if it was not active. New series limits only apply once Promscale has been running for this long.")
+	fs.StringVar(&cfg.Action, "metrics.series-limit.action", string(ActionReject), "Action taken on series exceeding a limit. Valid values: 'reject' rejects the whole write request, "+
+		"'drop' drops the offending series and 'log' only logs them.")
+	return cfg
+}
+
+func Validate(cfg *Config) error {
+	if cfg.MaxActiveSeries < 0 || cfg.MaxActiveSeriesPerMetric < 0 || cfg.MaxNewSeries < 0 || cfg.MaxNewSeriesPerMetric < 0 {
+		return fmt.Errorf("metrics.series-limit limits must not be negative")
+	}
+	if !cfg.Enabled() {
+		return nil
+	}
+	if cfg.NewSeriesInterval <= 0 {
+		return fmt.Errorf("metrics.series-limit.new-series-interval must be positive: %s", cfg.NewSeriesInterval)
+	}
+	if cfg.ActiveWindow <= 0 {
+		return fmt.Errorf("metrics.series-limit.active-window must be positive: %s", cfg.ActiveWindow)
+	}
+	switch Action(cfg.Action) {
+	case ActionReject, ActionDrop, ActionLog:
+	default:
+		return fmt.Errorf("invalid metrics.series-limit.action %q: must be one of 'reject', 'drop' or 'log'", cfg.Action)
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package serieslimit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"go.uber.org/atomic"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+// ErrLimitExceeded is returned when a write request is rejected because it
+// exceeds a series limit.
+var ErrLimitExceeded = fmt.Errorf("series limit exceeded")
+
+const (
+	limitActive          = "active_series"
+	limitActivePerMetric = "active_series_per_metric"
+	limitNew             = "new_series"
+	limitNewPerMetric    = "new_series_per_metric"
+
+	numShards = 64
+)
+
+// Limiter enforces the limits on the number of active and new series. The
+// series are tracked in memory, hence the limits apply per connector.
+//
+// The usage of the metrics is sharded by metric name, so that concurrent write
+// requests only contend on the metrics they share. New series reserve their
+// place in the limits while a request is admitted, and are recorded once all
+// the series of the request are admitted.
+type Limiter struct {
+	cfg    Config
+	action Action
+	shards [numShards]shard
+	// Number of active series, and of series that became active in the
+	// current interval, across all metrics. Both include the reserved series.
+	active  atomic.Int64
+	created atomic.Int64
+
+	started time.Time
+	now     func() time.Time
+
+	// protects intervalStart and lastPurge
+	mu            sync.Mutex
+	intervalStart time.Time
+	lastPurge     time.Time
+}
+
+// shard holds the usage of the metrics whose names hash to it.
+type shard struct {
+	mu      sync.Mutex
+	metrics map[string]*metricUsage
+	// Start of the new series interval the created series of the metrics are
+	// counted in.
+	interval time.Time
+}
+
+type metricUsage struct {
+	// Last time each active series of the metric received a sample.
+	series  map[uint64]time.Time
+	created int
+	// Number of new series admitted by requests but not recorded yet.
+	reserved int
+	// Set once an offending series of the metric was logged in the current
+	// interval, so that a cardinality explosion does not flood the logs.
+	logged bool
+}
+
+// admission is the state of the series of a write request being admitted.
+type admission struct {
+	series     []*model.Series
+	timeseries []prompb.TimeSeries
+	hashes     []uint64
+	admitted   []bool
+	// Set for the new series which reserved their place in the limits.
+	reserved []bool
+	dropped  []error
+}
+
+// NewLimiter creates a new Limiter.
+func NewLimiter(cfg Config) *Limiter {
+	return newLimiter(cfg, time.Now)
+}
+
+func newLimiter(cfg Config, now func() time.Time) *Limiter {
+	start := now()
+	l := &Limiter{
+		cfg:           cfg,
+		action:        Action(cfg.Action),
+		started:       start,
+		intervalStart: start,
+		lastPurge:     start,
+		now:           now,
+	}
+	for i := range l.shards {
+		l.shards[i] = shard{metrics: make(map[string]*metricUsage), interval: start}
+	}
+	return l
+}
+
+// Admit checks the series of a write request against the limits, series[i]
+// being the series of timeseries[i], or nil if the time-series is skipped. It
+// returns the errors of the series which are dropped, nil for the ones which
+// may be ingested, or an error wrapping ErrLimitExceeded if the whole request
+// is rejected.
+func (l *Limiter) Admit(series []*model.Series, timeseries []prompb.TimeSeries) ([]error, error) {
+	now := l.now()
+	interval := l.advance(now)
+
+	a := &admission{
+		series:     series,
+		timeseries: timeseries,
+		hashes:     make([]uint64, len(series)),
+		admitted:   make([]bool, len(series)),
+		reserved:   make([]bool, len(series)),
+		dropped:    make([]error, len(series)),
+	}
+	// Indexes of the series of each shard.
+	var perShard [numShards][]int
+	for i, s := range series {
+		if s == nil {
+			continue
+		}
+		a.hashes[i] = xxhash.Sum64String(s.String())
+		sh := shardOf(s.MetricName())
+		perShard[sh] = append(perShard[sh], i)
+	}
+
+	for sh, idxs := range perShard {
+		if len(idxs) == 0 {
+			continue
+		}
+		if err := l.admitShard(&l.shards[sh], idxs, a, interval, now); err != nil {
+			// Rejected requests do not count.
+			for prev := 0; prev < sh; prev++ {
+				l.release(&l.shards[prev], perShard[prev], a)
+			}
+			return nil, err
+		}
+	}
+	created := 0
+	for sh, idxs := range perShard {
+		if len(idxs) > 0 {
+			created += l.record(&l.shards[sh], idxs, a, now)
+		}
+	}
+	newSeries.Add(float64(created))
+	activeSeries.Set(float64(l.active.Load()))
+	return a.dropped, nil
+}
+
+// admitShard admits the series of the shard sh, given by their indexes, and
+// reserves the place of their new series.
+func (l *Limiter) admitShard(sh *shard, idxs []int, a *admission, interval, now time.Time) error {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.startInterval(interval)
+
+	// The series of the request that are not active yet.
+	pending := make(map[uint64]struct{})
+	for _, i := range idxs {
+		metric := a.series[i].MetricName()
+		u := sh.usage(metric)
+		_, active := u.series[a.hashes[i]]
+		if _, ok := pending[a.hashes[i]]; active || ok {
+			a.admitted[i] = true
+			continue
+		}
+		if limit, err := l.reserve(u, metric, now); err != nil {
+			exceededSeries.WithLabelValues(limit, string(l.action)).Inc()
+			if !u.logged {
+				u.logged = true
+				log.Warn("msg", "Series limit exceeded", "action", l.action, "series", model.FormatLabels(a.timeseries[i].Labels), "err", err)
+			}
+			switch l.action {
+			case ActionReject:
+				l.releaseLocked(sh, idxs, a)
+				return err
+			case ActionDrop:
+				a.dropped[i] = err
+				continue
+			}
+		}
+		pending[a.hashes[i]] = struct{}{}
+		a.reserved[i] = true
+		a.admitted[i] = true
+	}
+	return nil
+}
+
+// reserve reserves the place of a new series of the metric u in the limits.
+// If a limit is exceeded, it returns the limit along with the error, and the
+// place is only kept if the action only logs.
+func (l *Limiter) reserve(u *metricUsage, metric string, now time.Time) (string, error) {
+	u.reserved++
+	active, created := l.active.Inc(), l.created.Inc()
+	limit, err := l.check(u, metric, active, created, now)
+	if err != nil && l.action != ActionLog {
+		l.unreserve(u)
+	}
+	return limit, err
+}
+
+func (l *Limiter) unreserve(u *metricUsage) {
+	u.reserved--
+	l.active.Dec()
+	l.created.Dec()
+}
+
+// check returns the limit exceeded by the series of the metric u, including
+// the reserved ones, along with the error. active and created are the
+// number of active and created series across all metrics.
+func (l *Limiter) check(u *metricUsage, metric string, active, created int64, now time.Time) (string, error) {
+	cfg := l.cfg
+	if cfg.MaxActiveSeriesPerMetric > 0 && len(u.series)+u.reserved > cfg.MaxActiveSeriesPerMetric {
+		return limitActivePerMetric, fmt.Errorf("%w: limit of %d active series per metric reached by metric %s", ErrLimitExceeded, cfg.MaxActiveSeriesPerMetric, metric)
+	}
+	if cfg.MaxActiveSeries > 0 && active > int64(cfg.MaxActiveSeries) {
+		return limitActive, fmt.Errorf("%w: limit of %d active series reached", ErrLimitExceeded, cfg.MaxActiveSeries)
+	}
+	// All series are new to a limiter which just started, so new series are
+	// only limited once it tracked the series active before.
+	if now.Sub(l.started) < cfg.ActiveWindow {
+		return "", nil
+	}
+	if cfg.MaxNewSeriesPerMetric > 0 && u.created+u.reserved > cfg.MaxNewSeriesPerMetric {
+		return limitNewPerMetric, fmt.Errorf("%w: limit of %d new series per metric in %s reached by metric %s", ErrLimitExceeded, cfg.MaxNewSeriesPerMetric, cfg.NewSeriesInterval, metric)
+	}
+	if cfg.MaxNewSeries > 0 && created > int64(cfg.MaxNewSeries) {
+		return limitNew, fmt.Errorf("%w: limit of %d new series in %s reached", ErrLimitExceeded, cfg.MaxNewSeries, cfg.NewSeriesInterval)
+	}
+	return "", nil
+}
+
+// release releases the places reserved by the series of the shard sh of a
+// rejected request.
+func (l *Limiter) release(sh *shard, idxs []int, a *admission) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	l.releaseLocked(sh, idxs, a)
+}
+
+func (l *Limiter) releaseLocked(sh *shard, idxs []int, a *admission) {
+	for _, i := range idxs {
+		if a.reserved[i] {
+			a.reserved[i] = false
+			l.unreserve(sh.usage(a.series[i].MetricName()))
+		}
+	}
+}
+
+// record records the admitted series of the shard sh as active, and returns
+// the number of new series.
+func (l *Limiter) record(sh *shard, idxs []int, a *admission, now time.Time) int {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	created := 0
+	for _, i := range idxs {
+		if !a.admitted[i] {
+			continue
+		}
+		u := sh.usage(a.series[i].MetricName())
+		_, known := u.series[a.hashes[i]]
+		switch {
+		case a.reserved[i] && known:
+			// Recorded by a concurrent request meanwhile.
+			l.unreserve(u)
+		case a.reserved[i]:
+			u.reserved--
+			u.created++
+			created++
+		case !known:
+			// Purged since it was admitted.
+			l.active.Inc()
+		}
+		u.series[a.hashes[i]] = now
+	}
+	return created
+}
+
+func (sh *shard) usage(metric string) *metricUsage {
+	u, ok := sh.metrics[metric]
+	if !ok {
+		u = &metricUsage{series: make(map[uint64]time.Time)}
+		sh.metrics[metric] = u
+	}
+	return u
+}
+
+// startInterval resets the new series of the metrics of the shard once a new
+// interval started.
+func (sh *shard) startInterval(interval time.Time) {
+	if sh.interval.Equal(interval) {
+		return
+	}
+	sh.interval = interval
+	for _, u := range sh.metrics {
+		u.created = 0
+		u.logged = false
+	}
+}
+
+func shardOf(metric string) int {
+	return int(xxhash.Sum64String(metric) % numShards)
+}
+
+// advance starts a new interval of the new series limits once the current one
+// is over, and forgets the series which are not active anymore. It returns
+// the start of the current interval.
+func (l *Limiter) advance(now time.Time) time.Time {
+	l.mu.Lock()
+	if now.Sub(l.intervalStart) >= l.cfg.NewSeriesInterval {
+		l.intervalStart = now
+		l.created.Store(0)
+	}
+	interval := l.intervalStart
+	// Purging iterates over all series, so it is only done every tenth of the window.
+	purge := now.Sub(l.lastPurge) >= l.cfg.ActiveWindow/10
+	if purge {
+		l.lastPurge = now
+	}
+	l.mu.Unlock()
+
+	if purge {
+		for i := range l.shards {
+			l.purge(&l.shards[i], interval, now)
+		}
+		activeSeries.Set(float64(l.active.Load()))
+	}
+	return interval
+}
+
+func (l *Limiter) purge(sh *shard, interval, now time.Time) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.startInterval(interval)
+	for metric, u := range sh.metrics {
+		for s, seen := range u.series {
+			if now.Sub(seen) > l.cfg.ActiveWindow {
+				delete(u.series, s)
+				l.active.Dec()
+			}
+		}
+		if len(u.series) == 0 && u.created == 0 && u.reserved == 0 {
+			delete(sh.metrics, metric)
+		}
+	}
+}
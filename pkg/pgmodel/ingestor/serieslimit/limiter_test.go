@@ -0,0 +1,197 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package serieslimit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func request(metric string, instances ...int) ([]*model.Series, []prompb.TimeSeries) {
+	series := make([]*model.Series, len(instances))
+	timeseries := make([]prompb.TimeSeries, len(instances))
+	for i, instance := range instances {
+		labels := []prompb.Label{
+			{Name: model.MetricNameLabelName, Value: metric},
+			{Name: "instance", Value: fmt.Sprint(instance)},
+		}
+		series[i] = model.NewSeries(fmt.Sprintf("%s/%d", metric, instance), labels)
+		timeseries[i] = prompb.TimeSeries{Labels: labels}
+	}
+	return series, timeseries
+}
+
+// admitted returns which series are admitted, given the errors of the
+// dropped ones.
+func admitted(dropped []error, err error) ([]bool, error) {
+	if err != nil {
+		return nil, err
+	}
+	res := make([]bool, len(dropped))
+	for i, e := range dropped {
+		res[i] = e == nil
+	}
+	return res, nil
+}
+
+func TestActiveSeriesLimits(t *testing.T) {
+	c := &clock{t: time.Unix(0, 0)}
+	l := newLimiter(Config{
+		MaxActiveSeries:          4,
+		MaxActiveSeriesPerMetric: 3,
+		NewSeriesInterval:        time.Minute,
+		ActiveWindow:             10 * time.Minute,
+		Action:                   string(ActionReject),
+	}, c.now)
+
+	ok, err := admitted(l.Admit(request("a", 1, 2, 3, 3)))
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, true, true, true}, ok)
+
+	_, err = l.Admit(request("a", 1, 4))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	_, err = l.Admit(request("b", 1, 2))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	// Rejected requests do not count, known series are always admitted.
+	ok, err = admitted(l.Admit(request("b", 1)))
+	require.NoError(t, err)
+	require.Equal(t, []bool{true}, ok)
+	ok, err = admitted(l.Admit(request("a", 2)))
+	require.NoError(t, err)
+	require.Equal(t, []bool{true}, ok)
+
+	// The series which are not active anymore do not count.
+	c.t = c.t.Add(5 * time.Minute)
+	_, err = l.Admit(request("a", 2))
+	require.NoError(t, err)
+	c.t = c.t.Add(6 * time.Minute)
+	ok, err = admitted(l.Admit(request("a", 2, 4, 5)))
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, true, true}, ok)
+	require.Equal(t, int64(3), l.active.Load())
+}
+
+func TestNewSeriesLimits(t *testing.T) {
+	c := &clock{t: time.Unix(0, 0)}
+	l := newLimiter(Config{
+		MaxNewSeries:          3,
+		MaxNewSeriesPerMetric: 2,
+		NewSeriesInterval:     time.Minute,
+		ActiveWindow:          10 * time.Minute,
+		Action:                string(ActionDrop),
+	}, c.now)
+
+	// New series are not limited while the limiter warms up.
+	ok, err := admitted(l.Admit(request("a", 1, 2, 3, 4)))
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, true, true, true}, ok)
+
+	c.t = c.t.Add(10 * time.Minute)
+	ok, err = admitted(l.Admit(request("a", 1, 5, 6, 7)))
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, true, true, false}, ok)
+	ok, err = admitted(l.Admit(request("b", 1, 2)))
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, false}, ok)
+
+	c.t = c.t.Add(time.Minute)
+	ok, err = admitted(l.Admit(request("b", 2)))
+	require.NoError(t, err)
+	require.Equal(t, []bool{true}, ok)
+}
+
+func TestRejectedRequestAcrossMetrics(t *testing.T) {
+	c := &clock{t: time.Unix(0, 0)}
+	l := newLimiter(Config{
+		MaxActiveSeriesPerMetric: 1,
+		NewSeriesInterval:        time.Minute,
+		ActiveWindow:             10 * time.Minute,
+		Action:                   string(ActionReject),
+	}, c.now)
+
+	// The series of the other metrics of a rejected request do not count.
+	series, timeseries := request("a", 1)
+	bSeries, bTimeseries := request("b", 1, 2)
+	_, err := l.Admit(append(series, bSeries...), append(timeseries, bTimeseries...))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	require.Equal(t, int64(0), l.active.Load())
+	require.Equal(t, int64(0), l.created.Load())
+
+	ok, err := admitted(l.Admit(request("a", 2)))
+	require.NoError(t, err)
+	require.Equal(t, []bool{true}, ok)
+}
+
+func TestConcurrentAdmit(t *testing.T) {
+	c := &clock{t: time.Unix(0, 0)}
+	l := newLimiter(Config{
+		MaxActiveSeries:   50,
+		NewSeriesInterval: time.Minute,
+		ActiveWindow:      10 * time.Minute,
+		Action:            string(ActionDrop),
+	}, c.now)
+
+	const workers = 8
+	admittedSeries := make(chan int, workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			n := 0
+			for i := 0; i < 20; i++ {
+				ok, err := admitted(l.Admit(request(fmt.Sprintf("metric_%d", i), w)))
+				if err == nil && ok[0] {
+					n++
+				}
+			}
+			admittedSeries <- n
+		}(w)
+	}
+	total := 0
+	for w := 0; w < workers; w++ {
+		total += <-admittedSeries
+	}
+	require.Equal(t, 50, total)
+	require.Equal(t, int64(50), l.active.Load())
+}
+
+func TestLogAction(t *testing.T) {
+	c := &clock{t: time.Unix(0, 0)}
+	l := newLimiter(Config{
+		MaxActiveSeriesPerMetric: 1,
+		NewSeriesInterval:        time.Minute,
+		ActiveWindow:             10 * time.Minute,
+		Action:                   string(ActionLog),
+	}, c.now)
+
+	ok, err := admitted(l.Admit(request("a", 1, 2)))
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, true}, ok)
+	require.Equal(t, int64(2), l.active.Load())
+	require.True(t, l.shards[shardOf("a")].metrics["a"].logged)
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Config{NewSeriesInterval: time.Minute, ActiveWindow: time.Minute, Action: "ignore"}
+	require.NoError(t, Validate(&cfg))
+	cfg.MaxActiveSeries = 10
+	require.Error(t, Validate(&cfg))
+	cfg.Action = string(ActionDrop)
+	require.NoError(t, Validate(&cfg))
+	cfg.MaxNewSeries = -1
+	require.Error(t, Validate(&cfg))
+}
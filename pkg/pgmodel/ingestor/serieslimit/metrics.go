@@ -0,0 +1,45 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package serieslimit
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/timescale/promscale/pkg/util"
+)
+
+var (
+	exceededSeries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "series_limit",
+			Name:      "exceeded_series_total",
+			Help:      "Total number of series exceeding a series limit, by limit and the action taken. A rejected write request only counts its first offending series.",
+		}, []string{"limit", "action"},
+	)
+	activeSeries = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "series_limit",
+			Name:      "active_series",
+			Help:      "Number of active series tracked by the series limits.",
+		},
+	)
+	newSeries = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "series_limit",
+			Name:      "new_series_total",
+			Help:      "Total number of series that became active.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		exceededSeries,
+		activeSeries,
+		newSeries,
+	)
+}
@@ -0,0 +1,55 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package spool
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultMaxSizeBytes  = 1 << 30
+	defaultMaxAge        = 24 * time.Hour
+	defaultCopierBacklog = 0.9
+)
+
+// Config configures the on-disk spool of write requests and traces, which are
+// accepted while the database is unreachable and replayed once it recovers.
+type Config struct {
+	Dir           string
+	MaxSizeBytes  int64
+	MaxAge        time.Duration
+	CopierBacklog float64
+}
+
+// Enabled returns true if a spool directory is configured.
+func (cfg *Config) Enabled() bool {
+	return cfg.Dir != ""
+}
+
+func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
+	fs.StringVar(&cfg.Dir, "spool.dir", "", "Directory of the on-disk spool of metrics and traces accepted while the database is unreachable or ingestion falls behind. They are replayed in order once the database recovers. Empty disables the spool.")
+	fs.Int64Var(&cfg.MaxSizeBytes, "spool.max-size-bytes", defaultMaxSizeBytes, "Maximum size of the spooled data waiting to be replayed. Writes are rejected while the spool is full.")
+	fs.DurationVar(&cfg.MaxAge, "spool.max-age", defaultMaxAge, "Maximum age of spooled data. Older data is dropped instead of being replayed.")
+	fs.Float64Var(&cfg.CopierBacklog, "spool.copier-backlog", defaultCopierBacklog, "Fraction of the copier queue in use above which incoming metrics and traces are spooled, because ingestion falls behind.")
+	return cfg
+}
+
+func Validate(cfg *Config) error {
+	if !cfg.Enabled() {
+		return nil
+	}
+	if cfg.MaxSizeBytes <= 0 {
+		return fmt.Errorf("spool.max-size-bytes must be positive: %d", cfg.MaxSizeBytes)
+	}
+	if cfg.MaxAge <= 0 {
+		return fmt.Errorf("spool.max-age must be positive: %s", cfg.MaxAge)
+	}
+	if cfg.CopierBacklog <= 0 || cfg.CopierBacklog > 1 {
+		return fmt.Errorf("spool.copier-backlog must be in (0, 1]: %v", cfg.CopierBacklog)
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package spool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/atomic"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+// retryInterval is how often the database is checked while it is unreachable,
+// and how often ingestion is checked while it falls behind.
+const retryInterval = 5 * time.Second
+
+// Inserter is a DBInserter which stores metrics and traces in an on-disk spool
+// instead of ingesting them while the database is unreachable, while ingestion
+// falls behind, or while the spool is not empty, so that the order of the
+// writes is kept. The write failing because the database became unreachable is
+// spooled too. The spooled data is replayed in order in the background, along
+// with the source of its write request. Logs are not spooled.
+type Inserter struct {
+	cfg         *Config
+	inserter    ingestor.DBInserter
+	queue       *queue
+	healthCheck func() error
+	backlog     func() float64
+	down        *atomic.Bool
+	notify      chan struct{}
+	done        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// NewInserter opens the spool and starts replaying the data it contains into
+// the inserter. The health check tells if the database is reachable, and the
+// backlog function returns the fraction of the copier queue in use.
+func NewInserter(cfg *Config, inserter ingestor.DBInserter, healthCheck func() error, backlog func() float64) (*Inserter, error) {
+	q, err := openQueue(cfg.Dir, cfg.MaxSizeBytes)
+	if err != nil {
+		return nil, fmt.Errorf("open spool: %w", err)
+	}
+	i := &Inserter{
+		cfg:         cfg,
+		inserter:    inserter,
+		queue:       q,
+		healthCheck: healthCheck,
+		backlog:     backlog,
+		down:        atomic.NewBool(false),
+		notify:      make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+	if records, _ := q.stats(); records > 0 {
+		log.Info("msg", "Replaying spooled data", "records", records)
+	}
+	i.updateGauges()
+	i.wg.Add(1)
+	go i.replay()
+	return i, nil
+}
+
+func (i *Inserter) IngestMetrics(ctx context.Context, r *prompb.WriteRequest) (uint64, uint64, error) {
+	var numInsertables uint64
+	for _, ts := range r.Timeseries {
+		numInsertables += uint64(len(ts.Samples) + len(ts.Exemplars) + len(ts.Histograms))
+	}
+	numMetadata := uint64(len(r.Metadata))
+	if !i.shouldSpool() {
+		// The request is released by the ingestion, hence a write failing as
+		// the database goes down is not spooled, but retried by the client.
+		n, m, err := i.inserter.IngestMetrics(ctx, r)
+		if err != nil {
+			i.checkHealth()
+		}
+		return n, m, err
+	}
+	data, err := r.Marshal()
+	ingestor.FinishWriteRequest(r)
+	if err != nil {
+		return 0, 0, fmt.Errorf("marshal write request: %w", err)
+	}
+	if err = i.spool(ctx, metricsRecord, data); err != nil {
+		return 0, 0, err
+	}
+	return numInsertables, numMetadata, nil
+}
+
+func (i *Inserter) IngestTraces(ctx context.Context, traces ptrace.Traces) error {
+	if !i.shouldSpool() {
+		err := i.inserter.IngestTraces(ctx, traces)
+		if err == nil || !i.checkHealth() {
+			return err
+		}
+	}
+	data, err := ptrace.NewProtoMarshaler().MarshalTraces(traces)
+	if err != nil {
+		return fmt.Errorf("marshal traces: %w", err)
+	}
+	return i.spool(ctx, tracesRecord, data)
+}
+
+func (i *Inserter) IngestLogs(ctx context.Context, logs plog.Logs) error {
+	return i.inserter.IngestLogs(ctx, logs)
+}
+
+// Close stops the replay, leaving the data left in the spool for the next
+// start, and closes the underlying inserter.
+func (i *Inserter) Close() {
+	close(i.done)
+	i.wg.Wait()
+	if err := i.queue.close(); err != nil {
+		log.Error("msg", "Error closing the spool", "err", err)
+	}
+	i.inserter.Close()
+}
+
+func (i *Inserter) shouldSpool() bool {
+	if i.down.Load() || i.behind() {
+		return true
+	}
+	records, _ := i.queue.stats()
+	return records > 0
+}
+
+func (i *Inserter) behind() bool {
+	return i.backlog != nil && i.backlog() >= i.cfg.CopierBacklog
+}
+
+// checkHealth marks the database as down if it is unreachable, after an
+// ingestion error, and returns true if it is.
+func (i *Inserter) checkHealth() bool {
+	err := i.healthCheck()
+	if err == nil {
+		return false
+	}
+	if !i.down.Swap(true) {
+		log.Warn("msg", "Database unreachable, spooling metrics and traces", "dir", i.cfg.Dir, "err", err)
+	}
+	return true
+}
+
+// spool stores the data in the spool, along with the source of the write
+// request of ctx, if any.
+func (i *Inserter) spool(ctx context.Context, k kind, data []byte) error {
+	var meta []byte
+	if source := model.SourceFromContext(ctx); source != nil {
+		var err error
+		if meta, err = json.Marshal(source); err != nil {
+			return fmt.Errorf("marshal write source: %w", err)
+		}
+	}
+	if err := i.queue.append(k, time.Now(), meta, data); err != nil {
+		if errors.Is(err, ErrFull) {
+			rejectedRecords.WithLabelValues(k.String()).Inc()
+		}
+		return err
+	}
+	spooledRecords.WithLabelValues(k.String()).Inc()
+	i.updateGauges()
+	select {
+	case i.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (i *Inserter) replay() {
+	defer i.wg.Done()
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-i.done:
+			return
+		default:
+		}
+		if i.replayNext() {
+			continue
+		}
+		select {
+		case <-i.done:
+			return
+		case <-i.notify:
+		case <-ticker.C:
+		}
+	}
+}
+
+// replayNext replays the oldest spooled record, and returns false if it has to
+// wait for the database to recover, for ingestion to catch up or for new
+// records to be spooled.
+func (i *Inserter) replayNext() bool {
+	if i.down.Load() {
+		if err := i.healthCheck(); err != nil {
+			return false
+		}
+		log.Info("msg", "Database reachable again, replaying spooled data")
+		i.down.Store(false)
+	}
+	rec, err := i.queue.next()
+	if err == errEmpty {
+		return false
+	}
+	if err != nil {
+		log.Error("msg", "Error reading the spool", "err", err)
+		i.updateGauges()
+		return errors.Is(err, errCorrupt)
+	}
+	if i.behind() {
+		return false
+	}
+
+	defer i.updateGauges()
+	switch {
+	case time.Since(rec.time) > i.cfg.MaxAge:
+		droppedRecords.WithLabelValues(rec.kind.String(), "expired").Inc()
+	default:
+		var partialErr *ingestor.PartialWriteError
+		if err = i.ingest(rec); errors.As(err, &partialErr) {
+			log.Warn("msg", "Spooled data partially rejected", "type", rec.kind, "err", err)
+			replayedRecords.WithLabelValues(rec.kind.String()).Inc()
+		} else if err != nil {
+			if i.healthCheck() != nil {
+				i.down.Store(true)
+				log.Warn("msg", "Database unreachable, spooled data will be replayed once it recovers", "err", err)
+				return false
+			}
+			log.Warn("msg", "Dropping spooled data rejected by the database", "type", rec.kind, "err", err)
+			droppedRecords.WithLabelValues(rec.kind.String(), "rejected").Inc()
+		} else {
+			replayedRecords.WithLabelValues(rec.kind.String()).Inc()
+		}
+	}
+	if err = i.queue.commit(rec); err != nil {
+		log.Error("msg", "Error checkpointing the spool, data may be replayed twice after a restart", "err", err)
+	}
+	return true
+}
+
+func (i *Inserter) ingest(rec record) error {
+	ctx := context.Background()
+	if rec.meta != nil {
+		source := &model.Source{}
+		if err := json.Unmarshal(rec.meta, source); err != nil {
+			return fmt.Errorf("unmarshal spooled write source: %w", err)
+		}
+		ctx = model.NewSourceContext(ctx, source)
+	}
+	switch rec.kind {
+	case metricsRecord:
+		req := ingestor.NewWriteRequest()
+		if err := req.Unmarshal(rec.data); err != nil {
+			ingestor.FinishWriteRequest(req)
+			return fmt.Errorf("unmarshal spooled write request: %w", err)
+		}
+		_, _, err := i.inserter.IngestMetrics(ctx, req)
+		return err
+	case tracesRecord:
+		traces, err := ptrace.NewProtoUnmarshaler().UnmarshalTraces(rec.data)
+		if err != nil {
+			return fmt.Errorf("unmarshal spooled traces: %w", err)
+		}
+		return i.inserter.IngestTraces(ctx, traces)
+	}
+	return fmt.Errorf("unknown spool record type %d", rec.kind)
+}
+
+func (i *Inserter) updateGauges() {
+	records, size := i.queue.stats()
+	pendingRecords.Set(float64(records))
+	sizeBytes.Set(float64(size))
+}
@@ -0,0 +1,177 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package spool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+type fakeInserter struct {
+	mu      sync.Mutex
+	down    bool
+	writes  []string
+	sources map[string]model.Source
+}
+
+func (f *fakeInserter) health() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return fmt.Errorf("connection refused")
+	}
+	return nil
+}
+
+func (f *fakeInserter) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+func (f *fakeInserter) written() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.writes...)
+}
+
+func (f *fakeInserter) IngestMetrics(ctx context.Context, r *prompb.WriteRequest) (uint64, uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return 0, 0, fmt.Errorf("connection refused")
+	}
+	f.writes = append(f.writes, r.Timeseries[0].Labels[0].Value)
+	if s := model.SourceFromContext(ctx); s != nil {
+		if f.sources == nil {
+			f.sources = make(map[string]model.Source)
+		}
+		f.sources[r.Timeseries[0].Labels[0].Value] = *s
+	}
+	return uint64(len(r.Timeseries[0].Samples)), 0, nil
+}
+
+func (f *fakeInserter) IngestTraces(_ context.Context, traces ptrace.Traces) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return fmt.Errorf("connection refused")
+	}
+	name, _ := traces.ResourceSpans().At(0).Resource().Attributes().Get("service.name")
+	f.writes = append(f.writes, name.AsString())
+	return nil
+}
+
+func (f *fakeInserter) IngestLogs(context.Context, plog.Logs) error { return nil }
+
+func (f *fakeInserter) Close() {}
+
+func writeRequest(name string) *prompb.WriteRequest {
+	return &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{{
+			Labels:  []prompb.Label{{Name: "__name__", Value: name}},
+			Samples: []prompb.Sample{{Timestamp: 1, Value: 1}},
+		}},
+	}
+}
+
+func traces(service string) ptrace.Traces {
+	tr := ptrace.NewTraces()
+	rs := tr.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutString("service.name", service)
+	rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	return tr
+}
+
+func TestInserterSpoolsWhileDatabaseIsDown(t *testing.T) {
+	fake := &fakeInserter{}
+	backlog := 0.0
+	var backlogMu sync.Mutex
+	i, err := NewInserter(&Config{Dir: t.TempDir(), MaxSizeBytes: 1 << 20, MaxAge: time.Hour, CopierBacklog: 0.9}, fake, fake.health, func() float64 {
+		backlogMu.Lock()
+		defer backlogMu.Unlock()
+		return backlog
+	})
+	require.NoError(t, err)
+	defer i.Close()
+	ctx := context.Background()
+
+	_, _, err = i.IngestMetrics(ctx, writeRequest("first"))
+	require.NoError(t, err)
+
+	// The write failing when the database goes down is retried by the client,
+	// and the retry is spooled along with its source.
+	fake.setDown(true)
+	source := &model.Source{Tenant: "tenant-a", Replica: "replica-1", Client: "10.0.0.1"}
+	_, _, err = i.IngestMetrics(model.NewSourceContext(ctx, source), writeRequest("failed"))
+	require.Error(t, err)
+	n, _, err := i.IngestMetrics(model.NewSourceContext(ctx, source), writeRequest("failed"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), n)
+
+	n, _, err = i.IngestMetrics(ctx, writeRequest("second"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), n)
+	require.NoError(t, i.IngestTraces(ctx, traces("third")))
+
+	// Writes are spooled while ingestion falls behind, even once the database is back.
+	backlogMu.Lock()
+	backlog = 0.95
+	backlogMu.Unlock()
+	fake.setDown(false)
+	_, _, err = i.IngestMetrics(ctx, writeRequest("fourth"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"first"}, fake.written())
+
+	backlogMu.Lock()
+	backlog = 0
+	backlogMu.Unlock()
+	require.Eventually(t, func() bool {
+		records, _ := i.queue.stats()
+		return records == 0
+	}, 3*retryInterval, 10*time.Millisecond)
+	require.Equal(t, []string{"first", "failed", "second", "third", "fourth"}, fake.written())
+	fake.mu.Lock()
+	require.Equal(t, map[string]model.Source{"failed": *source}, fake.sources)
+	fake.mu.Unlock()
+
+	// Once the spool is drained, writes go straight to the database.
+	_, _, err = i.IngestMetrics(ctx, writeRequest("fifth"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"first", "failed", "second", "third", "fourth", "fifth"}, fake.written())
+}
+
+func TestInserterDropsExpiredRecords(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openQueue(dir, 1<<20)
+	require.NoError(t, err)
+	data, err := writeRequest("expired").Marshal()
+	require.NoError(t, err)
+	require.NoError(t, q.append(metricsRecord, time.Now().Add(-2*time.Hour), nil, data))
+	data, err = writeRequest("recent").Marshal()
+	require.NoError(t, err)
+	require.NoError(t, q.append(metricsRecord, time.Now(), nil, data))
+	require.NoError(t, q.close())
+
+	fake := &fakeInserter{}
+	i, err := NewInserter(&Config{Dir: dir, MaxSizeBytes: 1 << 20, MaxAge: time.Hour, CopierBacklog: 0.9}, fake, fake.health, nil)
+	require.NoError(t, err)
+	defer i.Close()
+	require.Eventually(t, func() bool {
+		records, _ := i.queue.stats()
+		return records == 0
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"recent"}, fake.written())
+}
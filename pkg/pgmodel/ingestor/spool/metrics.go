@@ -0,0 +1,72 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package spool
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/timescale/promscale/pkg/util"
+)
+
+var (
+	spooledRecords = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "spool",
+			Name:      "spooled_records_total",
+			Help:      "Total number of write requests and trace batches stored in the spool.",
+		}, []string{"type"},
+	)
+	replayedRecords = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "spool",
+			Name:      "replayed_records_total",
+			Help:      "Total number of spooled write requests and trace batches ingested into the database.",
+		}, []string{"type"},
+	)
+	droppedRecords = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "spool",
+			Name:      "dropped_records_total",
+			Help:      "Total number of spooled records dropped instead of being replayed, because they expired, were rejected by the database or were corrupt.",
+		}, []string{"type", "reason"},
+	)
+	rejectedRecords = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "spool",
+			Name:      "rejected_records_total",
+			Help:      "Total number of write requests and trace batches rejected because the spool is full.",
+		}, []string{"type"},
+	)
+	pendingRecords = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "spool",
+			Name:      "pending_records",
+			Help:      "Number of spooled records waiting to be replayed.",
+		},
+	)
+	sizeBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "spool",
+			Name:      "size_bytes",
+			Help:      "Size of the spooled data waiting to be replayed.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		spooledRecords,
+		replayedRecords,
+		droppedRecords,
+		rejectedRecords,
+		pendingRecords,
+		sizeBytes,
+	)
+}
@@ -0,0 +1,482 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package spool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/timescale/promscale/pkg/log"
+)
+
+const (
+	segmentSuffix  = ".spool"
+	checkpointFile = "checkpoint"
+	maxSegmentSize = 64 << 20
+
+	// A record is stored as its length and the CRC of its body, followed by the
+	// body: the kind of the record, its unix time in nanoseconds and its payload.
+	// Records with metadata have the withMeta bit set in their kind, and store
+	// the length of the metadata and the metadata before their payload.
+	recordHeaderSize     = 8
+	recordBodyHeaderSize = 9
+	recordMetaHeaderSize = 4
+)
+
+var (
+	ErrFull    = fmt.Errorf("spool is full")
+	errEmpty   = fmt.Errorf("spool is empty")
+	errCorrupt = fmt.Errorf("corrupt spool record")
+	castagnoli = crc32.MakeTable(crc32.Castagnoli)
+)
+
+type kind byte
+
+const (
+	metricsRecord kind = iota + 1
+	tracesRecord
+
+	withMeta kind = 0x80
+)
+
+func (k kind) String() string {
+	switch k {
+	case metricsRecord:
+		return "metrics"
+	case tracesRecord:
+		return "traces"
+	}
+	return "unknown"
+}
+
+type record struct {
+	kind kind
+	time time.Time
+	meta []byte // e.g. the source of a write request, nil if none
+	data []byte
+	size int64 // size on disk, including the header
+}
+
+type segment struct {
+	seq     uint64
+	size    int64
+	records int // records left to read
+}
+
+// queue is a FIFO of records stored in segment files. Records are appended to
+// the last segment and read from the first one, whose fully read segments are
+// removed. The read position is checkpointed after each record, so that the
+// records read before a restart are not read again. Only the records left to
+// read count against the maximum size of the queue.
+//
+// Appends are safe for concurrent use, while records must be read by a single
+// goroutine.
+type queue struct {
+	dir     string
+	maxSize int64
+
+	mu       sync.Mutex
+	segments []*segment
+	w        *os.File
+	size     int64 // size of the records left to read
+	records  int
+	readOff  int64
+	r        *os.File
+}
+
+// openQueue opens the queue stored in dir, creating it if needed. Records left
+// half-written by a crash are truncated.
+func openQueue(dir string, maxSize int64) (*queue, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create spool directory: %w", err)
+	}
+	seqs, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	cpSeq, cpOff, err := readCheckpoint(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &queue{dir: dir, maxSize: maxSize}
+	nextSeq := cpSeq + 1
+	for _, seq := range seqs {
+		path := segmentPath(dir, seq)
+		if seq < cpSeq {
+			if err = os.Remove(path); err != nil {
+				return nil, fmt.Errorf("remove replayed spool segment: %w", err)
+			}
+			continue
+		}
+		start := int64(0)
+		if seq == cpSeq {
+			start = cpOff
+		}
+		seg, err := recoverSegment(path, seq, start)
+		if err != nil {
+			return nil, err
+		}
+		if start > seg.size {
+			start = seg.size
+		}
+		if seq == cpSeq {
+			q.readOff = start
+		}
+		q.segments = append(q.segments, seg)
+		q.size += seg.size - start
+		q.records += seg.records
+		nextSeq = seq + 1
+	}
+	// Records are always appended to a new segment, rather than after a
+	// possibly truncated one.
+	if err = q.newSegment(nextSeq); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// recoverSegment validates the records of a segment and truncates it after the
+// last valid one. Only the records from start are counted as left to read.
+func recoverSegment(path string, seq uint64, start int64) (*segment, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("open spool segment: %w", err)
+	}
+	defer f.Close()
+
+	seg := &segment{seq: seq}
+	r := bufio.NewReader(f)
+	for {
+		rec, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Warn("msg", "Truncating spool segment after its last valid record", "segment", path, "offset", seg.size, "err", err)
+			if err = f.Truncate(seg.size); err != nil {
+				return nil, fmt.Errorf("truncate spool segment: %w", err)
+			}
+			break
+		}
+		if seg.size >= start {
+			seg.records++
+		}
+		seg.size += rec.size
+	}
+	return seg, nil
+}
+
+func (q *queue) newSegment(seq uint64) error {
+	f, err := os.OpenFile(segmentPath(q.dir, seq), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("create spool segment: %w", err)
+	}
+	// The records synced to the segment are lost in a crash if its directory
+	// entry is not.
+	if err = syncDir(q.dir); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("create spool segment: %w", err)
+	}
+	if q.w != nil {
+		_ = q.w.Close()
+	}
+	q.w = f
+	q.segments = append(q.segments, &segment{seq: seq})
+	return nil
+}
+
+// append durably stores a record, along with its metadata if not nil. ErrFull
+// is returned if the record would exceed the maximum size of the queue.
+func (q *queue) append(k kind, t time.Time, meta, data []byte) error {
+	buf := encodeRecord(k, t, meta, data)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.size+int64(len(buf)) > q.maxSize {
+		return ErrFull
+	}
+	last := q.segments[len(q.segments)-1]
+	// A fully read segment is replaced rather than appended to, so that it is
+	// removed once the new one is read.
+	drained := len(q.segments) == 1 && q.readOff >= last.size
+	if last.size > 0 && (drained || last.size+int64(len(buf)) > q.segmentSize()) {
+		if err := q.newSegment(last.seq + 1); err != nil {
+			return err
+		}
+		last = q.segments[len(q.segments)-1]
+	}
+	if _, err := q.w.Write(buf); err != nil {
+		// Drop the partially written record, the next ones are appended after it.
+		_ = q.w.Truncate(last.size)
+		return fmt.Errorf("write spool record: %w", err)
+	}
+	if err := q.w.Sync(); err != nil {
+		_ = q.w.Truncate(last.size)
+		return fmt.Errorf("sync spool segment: %w", err)
+	}
+	last.size += int64(len(buf))
+	last.records++
+	q.size += int64(len(buf))
+	q.records++
+	return nil
+}
+
+// next returns the oldest record of the queue, or errEmpty. The record is
+// returned again until it is committed.
+func (q *queue) next() (record, error) {
+	for {
+		q.mu.Lock()
+		if q.records == 0 {
+			q.mu.Unlock()
+			return record{}, errEmpty
+		}
+		seg := q.segments[0]
+		// The size grows while records are appended to the segment.
+		off, size := q.readOff, seg.size
+		if off >= size && len(q.segments) > 1 {
+			q.segments = q.segments[1:]
+			q.readOff = 0
+			nextSeq := q.segments[0].seq
+			q.mu.Unlock()
+			if err := q.removeSegment(seg.seq, nextSeq); err != nil {
+				return record{}, err
+			}
+			continue
+		}
+		q.mu.Unlock()
+
+		if q.r == nil {
+			f, err := os.Open(segmentPath(q.dir, seg.seq))
+			if err != nil {
+				return record{}, fmt.Errorf("open spool segment: %w", err)
+			}
+			q.r = f
+		}
+		rec, err := readRecord(io.NewSectionReader(q.r, off, size-off))
+		if err != nil {
+			// The rest of the segment cannot be read, hence it is skipped.
+			q.mu.Lock()
+			skipped := seg.records
+			q.records -= skipped
+			q.size -= seg.size - q.readOff
+			seg.records = 0
+			q.readOff = seg.size
+			q.mu.Unlock()
+			droppedRecords.WithLabelValues("unknown", "corrupt").Add(float64(skipped))
+			return record{}, fmt.Errorf("%w in segment %d at offset %d, skipping %d records: %v", errCorrupt, seg.seq, off, skipped, err)
+		}
+		return rec, nil
+	}
+}
+
+// commit marks the record returned by next as read.
+func (q *queue) commit(rec record) error {
+	q.mu.Lock()
+	q.readOff += rec.size
+	q.segments[0].records--
+	q.records--
+	q.size -= rec.size
+	seq, off := q.segments[0].seq, q.readOff
+	q.mu.Unlock()
+	return writeCheckpoint(q.dir, seq, off)
+}
+
+func (q *queue) removeSegment(seq, nextSeq uint64) error {
+	if q.r != nil {
+		_ = q.r.Close()
+		q.r = nil
+	}
+	if err := writeCheckpoint(q.dir, nextSeq, 0); err != nil {
+		return err
+	}
+	if err := os.Remove(segmentPath(q.dir, seq)); err != nil {
+		return fmt.Errorf("remove replayed spool segment: %w", err)
+	}
+	return nil
+}
+
+// segmentSize returns the size above which records are appended to a new
+// segment. Segments are kept below the maximum size of the queue, so that the
+// space of the records read is freed before the queue fills up.
+func (q *queue) segmentSize() int64 {
+	if q.maxSize < maxSegmentSize {
+		return q.maxSize
+	}
+	return maxSegmentSize
+}
+
+// stats returns the number of records left to read and their size.
+func (q *queue) stats() (records int, size int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.records, q.size
+}
+
+func (q *queue) close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.r != nil {
+		_ = q.r.Close()
+		q.r = nil
+	}
+	return q.w.Close()
+}
+
+func encodeRecord(k kind, t time.Time, meta, data []byte) []byte {
+	bodySize := recordBodyHeaderSize + len(data)
+	if meta != nil {
+		k |= withMeta
+		bodySize += recordMetaHeaderSize + len(meta)
+	}
+	buf := make([]byte, recordHeaderSize+bodySize)
+	body := buf[recordHeaderSize:]
+	body[0] = byte(k)
+	binary.BigEndian.PutUint64(body[1:recordBodyHeaderSize], uint64(t.UnixNano()))
+	payload := body[recordBodyHeaderSize:]
+	if meta != nil {
+		binary.BigEndian.PutUint32(payload[:recordMetaHeaderSize], uint32(len(meta)))
+		copy(payload[recordMetaHeaderSize:], meta)
+		payload = payload[recordMetaHeaderSize+len(meta):]
+	}
+	copy(payload, data)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(bodySize))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.Checksum(body, castagnoli))
+	return buf
+}
+
+// readRecord reads the next record, returning io.EOF if there is none and
+// io.ErrUnexpectedEOF if it is truncated.
+func readRecord(r io.Reader) (record, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return record{}, err
+	}
+	bodySize := binary.BigEndian.Uint32(header[0:4])
+	if bodySize < recordBodyHeaderSize || bodySize > maxSegmentSize {
+		return record{}, fmt.Errorf("invalid record size %d", bodySize)
+	}
+	body := make([]byte, bodySize)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return record{}, err
+	}
+	if crc32.Checksum(body, castagnoli) != binary.BigEndian.Uint32(header[4:8]) {
+		return record{}, fmt.Errorf("checksum mismatch")
+	}
+	rec := record{
+		kind: kind(body[0]) &^ withMeta,
+		time: time.Unix(0, int64(binary.BigEndian.Uint64(body[1:recordBodyHeaderSize]))),
+		data: body[recordBodyHeaderSize:],
+		size: int64(recordHeaderSize + bodySize),
+	}
+	if kind(body[0])&withMeta != 0 {
+		if len(rec.data) < recordMetaHeaderSize {
+			return record{}, fmt.Errorf("invalid record metadata")
+		}
+		metaSize := binary.BigEndian.Uint32(rec.data[:recordMetaHeaderSize])
+		if metaSize > uint32(len(rec.data)-recordMetaHeaderSize) {
+			return record{}, fmt.Errorf("invalid record metadata size %d", metaSize)
+		}
+		rec.meta = rec.data[recordMetaHeaderSize : recordMetaHeaderSize+metaSize]
+		rec.data = rec.data[recordMetaHeaderSize+metaSize:]
+	}
+	return rec, nil
+}
+
+func segmentPath(dir string, seq uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", seq, segmentSuffix))
+}
+
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read spool directory: %w", err)
+	}
+	var seqs []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+func readCheckpoint(dir string) (seq uint64, off int64, err error) {
+	contents, err := os.ReadFile(filepath.Join(dir, checkpointFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("read spool checkpoint: %w", err)
+	}
+	if _, err = fmt.Sscanf(string(contents), "%d %d", &seq, &off); err != nil {
+		log.Warn("msg", "Ignoring invalid spool checkpoint, spooled data may be replayed twice", "err", err)
+		return 0, 0, nil
+	}
+	return seq, off, nil
+}
+
+// writeCheckpoint atomically and durably replaces the checkpoint of the read
+// position.
+func writeCheckpoint(dir string, seq uint64, off int64) error {
+	path := filepath.Join(dir, checkpointFile)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("write spool checkpoint: %w", err)
+	}
+	_, err = fmt.Fprintf(f, "%d %d\n", seq, off)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write spool checkpoint: %w", err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write spool checkpoint: %w", err)
+	}
+	if err = syncDir(dir); err != nil {
+		return fmt.Errorf("write spool checkpoint: %w", err)
+	}
+	return nil
+}
+
+// syncDir syncs the entries of a directory, e.g. after a file was created or
+// renamed in it.
+func syncDir(dir string) error {
+	d, err := os.Open(dir) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("open spool directory: %w", err)
+	}
+	defer d.Close()
+	if err = d.Sync(); err != nil {
+		return fmt.Errorf("sync spool directory: %w", err)
+	}
+	return nil
+}
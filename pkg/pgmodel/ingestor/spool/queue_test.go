@@ -0,0 +1,154 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package spool
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, q *queue) []string {
+	var payloads []string
+	for {
+		rec, err := q.next()
+		if err == errEmpty {
+			return payloads
+		}
+		require.NoError(t, err)
+		payloads = append(payloads, string(rec.data))
+		require.NoError(t, q.commit(rec))
+	}
+}
+
+func TestQueueOrder(t *testing.T) {
+	q, err := openQueue(t.TempDir(), 1<<20)
+	require.NoError(t, err)
+	defer q.close()
+
+	now := time.Unix(1000, 0)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, q.append(metricsRecord, now, nil, []byte(fmt.Sprintf("record-%d", i))))
+	}
+	rec, err := q.next()
+	require.NoError(t, err)
+	require.Equal(t, metricsRecord, rec.kind)
+	require.True(t, now.Equal(rec.time))
+
+	// A record is returned until it is committed.
+	again, err := q.next()
+	require.NoError(t, err)
+	require.Equal(t, rec.data, again.data)
+
+	require.Equal(t, []string{"record-0", "record-1", "record-2"}, readAll(t, q))
+	records, _ := q.stats()
+	require.Equal(t, 0, records)
+}
+
+func TestQueueConcurrentAppend(t *testing.T) {
+	q, err := openQueue(t.TempDir(), 1<<20)
+	require.NoError(t, err)
+	defer q.close()
+
+	// Records are read from the segment they are appended to.
+	const numRecords = 100
+	appended := make(chan error, 1)
+	go func() {
+		for i := 0; i < numRecords; i++ {
+			if err := q.append(metricsRecord, time.Now(), nil, []byte(fmt.Sprintf("record-%d", i))); err != nil {
+				appended <- err
+				return
+			}
+		}
+		appended <- nil
+	}()
+	for i := 0; i < numRecords; {
+		rec, err := q.next()
+		if err == errEmpty {
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("record-%d", i), string(rec.data))
+		require.NoError(t, q.commit(rec))
+		i++
+	}
+	require.NoError(t, <-appended)
+}
+
+func TestQueueMaxSize(t *testing.T) {
+	q, err := openQueue(t.TempDir(), 100)
+	require.NoError(t, err)
+	defer q.close()
+
+	require.NoError(t, q.append(tracesRecord, time.Now(), nil, make([]byte, 50)))
+	require.Equal(t, ErrFull, q.append(tracesRecord, time.Now(), nil, make([]byte, 50)))
+}
+
+func TestQueueMaxSizeReplayed(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openQueue(dir, 1000)
+	require.NoError(t, err)
+	defer q.close()
+
+	// The records read no longer count against the maximum size.
+	for i := 0; i < 50; i++ {
+		require.NoError(t, q.append(metricsRecord, time.Now(), nil, make([]byte, 100)))
+		rec, err := q.next()
+		require.NoError(t, err)
+		require.NoError(t, q.commit(rec))
+	}
+	records, size := q.stats()
+	require.Equal(t, 0, records)
+	require.Equal(t, int64(0), size)
+
+	// Nor do they stay on disk.
+	seqs, err := listSegments(dir)
+	require.NoError(t, err)
+	require.Len(t, seqs, 1)
+}
+
+func TestQueueRecovery(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openQueue(dir, 1<<20)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, q.append(metricsRecord, time.Now(), nil, []byte(fmt.Sprintf("record-%d", i))))
+	}
+	rec, err := q.next()
+	require.NoError(t, err)
+	require.NoError(t, q.commit(rec))
+	require.NoError(t, q.close())
+
+	// Simulate a crash in the middle of an append.
+	seqs, err := listSegments(dir)
+	require.NoError(t, err)
+	f, err := os.OpenFile(segmentPath(dir, seqs[len(seqs)-1]), os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write(encodeRecord(metricsRecord, time.Now(), nil, []byte("half-written"))[:15])
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// The committed record is not read again and the truncated one is dropped.
+	q, err = openQueue(dir, 1<<20)
+	require.NoError(t, err)
+	records, _ := q.stats()
+	require.Equal(t, 2, records)
+	require.NoError(t, q.append(metricsRecord, time.Now(), nil, []byte("record-3")))
+	require.Equal(t, []string{"record-1", "record-2", "record-3"}, readAll(t, q))
+	require.NoError(t, q.close())
+
+	// Fully read segments are removed.
+	q, err = openQueue(dir, 1<<20)
+	require.NoError(t, err)
+	defer q.close()
+	seqs, err = listSegments(dir)
+	require.NoError(t, err)
+	require.Len(t, seqs, 2)
+	records, _ = q.stats()
+	require.Equal(t, 0, records)
+}
@@ -0,0 +1,84 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package logs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+const (
+	lockID = 3489612770615203851 // Chosen randomly.
+
+	sqlAcquireLock    = "SELECT pg_try_advisory_lock($1)"
+	sqlReleaseLock    = "SELECT pg_advisory_unlock($1)"
+	sqlApplyRetention = "SELECT _ps_log.apply_log_retention()"
+
+	// MaintenanceInterval is how often the retention period of the logs is
+	// applied.
+	MaintenanceInterval = 15 * time.Minute
+)
+
+// Maintainer periodically drops the logs older than the log retention period,
+// since the log hypertable is not covered by the maintenance of the Promscale
+// extension. Only one connector of the deployment maintains the logs at a time.
+type Maintainer struct {
+	conn     pgxconn.PgxConn
+	interval time.Duration
+}
+
+// NewMaintainer creates a new Maintainer.
+func NewMaintainer(conn pgxconn.PgxConn, interval time.Duration) *Maintainer {
+	return &Maintainer{conn: conn, interval: interval}
+}
+
+// Run maintains the logs every interval until the context is cancelled. It
+// blocks until then.
+func (m *Maintainer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := m.maintain(ctx); err != nil && ctx.Err() == nil {
+			log.Error("msg", "failed to maintain logs", "err", err)
+		}
+	}
+}
+
+func (m *Maintainer) maintain(ctx context.Context) error {
+	conn, err := m.conn.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	acquired := false
+	if err = conn.QueryRow(ctx, sqlAcquireLock, lockID).Scan(&acquired); err != nil {
+		return fmt.Errorf("acquire advisory lock: %w", err)
+	}
+	if !acquired {
+		log.Debug("msg", "logs are being maintained by another connector")
+		return nil
+	}
+	defer func() {
+		// Release the lock even if the context was cancelled.
+		if _, err := conn.Exec(context.Background(), sqlReleaseLock, lockID); err != nil {
+			log.Error("msg", "failed to release log advisory lock", "err", err)
+		}
+	}()
+
+	if _, err = conn.Exec(ctx, sqlApplyRetention); err != nil {
+		return fmt.Errorf("apply retention: %w", err)
+	}
+	return nil
+}
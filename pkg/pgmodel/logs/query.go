@@ -0,0 +1,160 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package logs
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgtype"
+
+	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+const (
+	// DefaultLimit is the number of log records returned when the query does not set a limit.
+	DefaultLimit = 100
+	// MaxLimit is the maximum number of log records returned by a single query.
+	MaxLimit = 5000
+)
+
+// Query selects the log records to return. Records are returned newest first.
+type Query struct {
+	Selector Selector
+	Start    time.Time
+	End      time.Time
+	// TraceID, when set, returns only the records that were logged within the trace.
+	TraceID *[16]byte
+	// ResourceMatchers only match the resource attributes, which the record
+	// attributes cannot override, e.g. to restrict the tenants of the records.
+	ResourceMatchers []TagMatcher
+	Limit            int
+}
+
+// Entry is a log record returned by a query.
+type Entry struct {
+	Timestamp          time.Time              `json:"timestamp"`
+	TraceID            string                 `json:"trace_id,omitempty"`
+	SpanID             string                 `json:"span_id,omitempty"`
+	SeverityNumber     int16                  `json:"severity_number"`
+	SeverityText       string                 `json:"severity_text,omitempty"`
+	Body               string                 `json:"body"`
+	Attributes         map[string]interface{} `json:"attributes"`
+	ResourceAttributes map[string]interface{} `json:"resource_attributes"`
+}
+
+// QueryLogs returns the log records matching the query.
+func QueryLogs(ctx context.Context, conn pgxconn.PgxConn, q Query) ([]Entry, error) {
+	sql, args := buildQuery(q)
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query logs: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]Entry, 0)
+	for rows.Next() {
+		var (
+			e            Entry
+			traceID      pgtype.UUID
+			spanID       pgtype.Int8
+			severityText pgtype.Text
+		)
+		if err := rows.Scan(&e.Timestamp, &traceID, &spanID, &e.SeverityNumber, &severityText, &e.Body, &e.Attributes, &e.ResourceAttributes); err != nil {
+			return nil, fmt.Errorf("query result: %w", err)
+		}
+		if traceID.Status == pgtype.Present {
+			e.TraceID = hex.EncodeToString(traceID.Bytes[:])
+		}
+		if spanID.Status == pgtype.Present {
+			var b [8]byte
+			binary.BigEndian.PutUint64(b[:], uint64(spanID.Int))
+			e.SpanID = hex.EncodeToString(b[:])
+		}
+		e.SeverityText = severityText.String
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query result: %w", err)
+	}
+	return entries, nil
+}
+
+func buildQuery(q Query) (string, []interface{}) {
+	var (
+		args       []interface{}
+		conditions []string
+	)
+	param := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions = append(conditions, "time >= "+param(q.Start), "time <= "+param(q.End))
+	if q.TraceID != nil {
+		conditions = append(conditions, "trace_id = "+param(pgtype.UUID{Bytes: *q.TraceID, Status: pgtype.Present}))
+	}
+	match := func(tagValue string, m TagMatcher) string {
+		switch m.Type {
+		case MatchNotEqual:
+			return tagValue + " <> " + param(m.Value)
+		case MatchRegexp:
+			return tagValue + " ~ " + param("^(?:"+m.Value+")$")
+		case MatchNotRegexp:
+			return tagValue + " !~ " + param("^(?:"+m.Value+")$")
+		}
+		return tagValue + " = " + param(m.Value)
+	}
+	for _, m := range q.Selector.Matchers {
+		key := param(m.Key)
+		conditions = append(conditions, match(fmt.Sprintf("coalesce(attributes->>%[1]s, resource_attributes->>%[1]s, '')", key), m))
+	}
+	for _, m := range q.ResourceMatchers {
+		conditions = append(conditions, match(fmt.Sprintf("coalesce(resource_attributes->>%s, '')", param(m.Key)), m))
+	}
+	for _, f := range q.Selector.Filters {
+		switch f.Type {
+		case FilterContains:
+			conditions = append(conditions, "strpos(body, "+param(f.Value)+") > 0")
+		case FilterNotContains:
+			conditions = append(conditions, "strpos(body, "+param(f.Value)+") = 0")
+		case FilterRegexp:
+			conditions = append(conditions, "body ~ "+param(f.Value))
+		case FilterNotRegexp:
+			conditions = append(conditions, "body !~ "+param(f.Value))
+		}
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	sql := fmt.Sprintf(`SELECT time, trace_id, span_id, severity_number, severity_text, body, attributes, resource_attributes
+FROM %s.log
+WHERE %s
+ORDER BY time DESC
+LIMIT %s`, schema.PsLog, strings.Join(conditions, " AND "), param(limit))
+	return sql, args
+}
+
+// ParseTraceID parses a hex encoded trace id. As in Jaeger, leading zeros can be
+// omitted, and the UUID representation used by the database is accepted as well.
+func ParseTraceID(s string) ([16]byte, error) {
+	var id [16]byte
+	digits := strings.ReplaceAll(s, "-", "")
+	if digits == "" || len(digits) > 32 {
+		return id, fmt.Errorf("invalid trace id %q", s)
+	}
+	digits = strings.Repeat("0", 32-len(digits)) + digits
+	if _, err := hex.Decode(id[:], []byte(digits)); err != nil {
+		return id, fmt.Errorf("invalid trace id %q: %w", s, err)
+	}
+	return id, nil
+}
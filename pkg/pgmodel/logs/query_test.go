@@ -0,0 +1,88 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package logs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildQuery(t *testing.T) {
+	start, end := time.Unix(100, 0), time.Unix(200, 0)
+	traceID := [16]byte{15: 1}
+	sql, args := buildQuery(Query{
+		Selector: Selector{
+			Matchers: []TagMatcher{
+				{Type: MatchEqual, Key: "service.name", Value: "checkout"},
+				{Type: MatchNotRegexp, Key: "env", Value: "dev|test"},
+			},
+			Filters: []LineFilter{
+				{Type: FilterContains, Value: "timeout"},
+				{Type: FilterNotRegexp, Value: "^debug"},
+			},
+		},
+		Start:   start,
+		End:     end,
+		TraceID: &traceID,
+	})
+	require.Equal(t, `SELECT time, trace_id, span_id, severity_number, severity_text, body, attributes, resource_attributes
+FROM _ps_log.log
+WHERE time >= $1 AND time <= $2 AND trace_id = $3`+
+		` AND coalesce(attributes->>$4, resource_attributes->>$4, '') = $5`+
+		` AND coalesce(attributes->>$6, resource_attributes->>$6, '') !~ $7`+
+		` AND strpos(body, $8) > 0 AND body !~ $9
+ORDER BY time DESC
+LIMIT $10`, sql)
+	require.Equal(t, []interface{}{
+		start, end, pgtype.UUID{Bytes: traceID, Status: pgtype.Present},
+		"service.name", "checkout",
+		"env", "^(?:dev|test)$",
+		"timeout", "^debug",
+		DefaultLimit,
+	}, args)
+}
+
+func TestBuildQueryResourceMatchers(t *testing.T) {
+	start, end := time.Unix(100, 0), time.Unix(200, 0)
+	sql, args := buildQuery(Query{
+		Selector:         Selector{Matchers: []TagMatcher{{Type: MatchEqual, Key: "__tenant__", Value: "a"}}},
+		ResourceMatchers: []TagMatcher{{Type: MatchRegexp, Key: "__tenant__", Value: "a|b"}},
+		Start:            start,
+		End:              end,
+		Limit:            10,
+	})
+	require.Equal(t, `SELECT time, trace_id, span_id, severity_number, severity_text, body, attributes, resource_attributes
+FROM _ps_log.log
+WHERE time >= $1 AND time <= $2`+
+		` AND coalesce(attributes->>$3, resource_attributes->>$3, '') = $4`+
+		` AND coalesce(resource_attributes->>$5, '') ~ $6
+ORDER BY time DESC
+LIMIT $7`, sql)
+	require.Equal(t, []interface{}{start, end, "__tenant__", "a", "__tenant__", "^(?:a|b)$", 10}, args)
+}
+
+func TestParseTraceID(t *testing.T) {
+	expected := [16]byte{0: 0x0a, 15: 0xbc}
+	for _, s := range []string{
+		"0a0000000000000000000000000000bc",
+		"0a000000-0000-0000-0000-0000000000bc",
+	} {
+		id, err := ParseTraceID(s)
+		require.NoError(t, err)
+		require.Equal(t, expected, id)
+	}
+
+	id, err := ParseTraceID("abc")
+	require.NoError(t, err)
+	require.Equal(t, [16]byte{14: 0x0a, 15: 0xbc}, id)
+
+	for _, s := range []string{"", "xyz", "0a0000000000000000000000000000bc00"} {
+		_, err := ParseTraceID(s)
+		require.Error(t, err, s)
+	}
+}
@@ -0,0 +1,239 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MatchType is the type of a tag matcher.
+type MatchType int
+
+const (
+	MatchEqual MatchType = iota
+	MatchNotEqual
+	MatchRegexp
+	MatchNotRegexp
+)
+
+var matchTypeOperators = map[MatchType]string{
+	MatchEqual:     "=",
+	MatchNotEqual:  "!=",
+	MatchRegexp:    "=~",
+	MatchNotRegexp: "!~",
+}
+
+func (m MatchType) String() string {
+	return matchTypeOperators[m]
+}
+
+// TagMatcher matches the value of a tag. A tag is looked up in the attributes of
+// the log record first and in the attributes of its resource otherwise. A missing
+// tag has the empty value, as with Prometheus label matchers.
+type TagMatcher struct {
+	Type  MatchType
+	Key   string
+	Value string
+}
+
+// FilterType is the type of a line filter.
+type FilterType int
+
+const (
+	FilterContains FilterType = iota
+	FilterNotContains
+	FilterRegexp
+	FilterNotRegexp
+)
+
+var filterTypeOperators = map[FilterType]string{
+	FilterContains:    "|=",
+	FilterNotContains: "!=",
+	FilterRegexp:      "|~",
+	FilterNotRegexp:   "!~",
+}
+
+func (f FilterType) String() string {
+	return filterTypeOperators[f]
+}
+
+// LineFilter filters log records by their body.
+type LineFilter struct {
+	Type  FilterType
+	Value string
+}
+
+// Selector is a parsed LogQL-style log selector, e.g.
+//
+//	{service.name="checkout", http.status_code=~"5.."} |= "timeout" != "retry"
+type Selector struct {
+	Matchers []TagMatcher
+	Filters  []LineFilter
+}
+
+// ParseSelector parses a LogQL-style log selector. The stream selector is a list
+// of tag matchers in braces; unlike Prometheus label names, tag names can contain
+// dots, dashes and slashes. It can be followed by line filters. An empty string
+// selects all log records.
+func ParseSelector(input string) (Selector, error) {
+	p := &selectorParser{input: input}
+	sel, err := p.parse()
+	if err != nil {
+		return Selector{}, fmt.Errorf("parse error at char %d: %w", p.pos+1, err)
+	}
+	return sel, nil
+}
+
+type selectorParser struct {
+	input string
+	pos   int
+}
+
+func (p *selectorParser) parse() (Selector, error) {
+	var sel Selector
+	p.skipSpaces()
+	if p.done() {
+		return sel, nil
+	}
+	if p.consume("{") {
+		matchers, err := p.parseMatchers()
+		if err != nil {
+			return Selector{}, err
+		}
+		sel.Matchers = matchers
+	}
+	for p.skipSpaces(); !p.done(); p.skipSpaces() {
+		filter, err := p.parseFilter()
+		if err != nil {
+			return Selector{}, err
+		}
+		sel.Filters = append(sel.Filters, filter)
+	}
+	return sel, nil
+}
+
+func (p *selectorParser) parseMatchers() ([]TagMatcher, error) {
+	var matchers []TagMatcher
+	for {
+		p.skipSpaces()
+		if p.consume("}") {
+			return matchers, nil
+		}
+		if len(matchers) > 0 {
+			if !p.consume(",") {
+				return nil, fmt.Errorf("expected \",\" or \"}\"")
+			}
+			p.skipSpaces()
+			// Allow a trailing comma.
+			if p.consume("}") {
+				return matchers, nil
+			}
+		}
+		key := p.parseKey()
+		if key == "" {
+			return nil, fmt.Errorf("expected tag name")
+		}
+		p.skipSpaces()
+		var typ MatchType
+		switch {
+		case p.consume("=~"):
+			typ = MatchRegexp
+		case p.consume("!~"):
+			typ = MatchNotRegexp
+		case p.consume("!="):
+			typ = MatchNotEqual
+		case p.consume("="):
+			typ = MatchEqual
+		default:
+			return nil, fmt.Errorf("expected one of \"=\", \"!=\", \"=~\", \"!~\" after tag name %q", key)
+		}
+		p.skipSpaces()
+		value, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		if typ == MatchRegexp || typ == MatchNotRegexp {
+			if _, err := regexp.Compile("^(?:" + value + ")$"); err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q: %w", value, err)
+			}
+		}
+		matchers = append(matchers, TagMatcher{Type: typ, Key: key, Value: value})
+	}
+}
+
+func (p *selectorParser) parseFilter() (LineFilter, error) {
+	var typ FilterType
+	switch {
+	case p.consume("|="):
+		typ = FilterContains
+	case p.consume("!="):
+		typ = FilterNotContains
+	case p.consume("|~"):
+		typ = FilterRegexp
+	case p.consume("!~"):
+		typ = FilterNotRegexp
+	default:
+		return LineFilter{}, fmt.Errorf("expected one of \"|=\", \"!=\", \"|~\", \"!~\"")
+	}
+	p.skipSpaces()
+	value, err := p.parseString()
+	if err != nil {
+		return LineFilter{}, err
+	}
+	if typ == FilterRegexp || typ == FilterNotRegexp {
+		if _, err := regexp.Compile(value); err != nil {
+			return LineFilter{}, fmt.Errorf("invalid regular expression %q: %w", value, err)
+		}
+	}
+	return LineFilter{Type: typ, Value: value}, nil
+}
+
+func (p *selectorParser) parseKey() string {
+	start := p.pos
+	for !p.done() {
+		r := rune(p.input[p.pos])
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.-/:", r)) {
+			break
+		}
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+// parseString parses a double-quoted or back-quoted string, using Go escaping rules.
+func (p *selectorParser) parseString() (string, error) {
+	quoted, err := strconv.QuotedPrefix(p.input[p.pos:])
+	if err != nil || quoted[0] == '\'' {
+		return "", fmt.Errorf("expected quoted string")
+	}
+	value, err := strconv.Unquote(quoted)
+	if err != nil {
+		return "", err
+	}
+	p.pos += len(quoted)
+	return value, nil
+}
+
+func (p *selectorParser) skipSpaces() {
+	for !p.done() && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *selectorParser) consume(token string) bool {
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *selectorParser) done() bool {
+	return p.pos >= len(p.input)
+}
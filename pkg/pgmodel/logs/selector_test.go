@@ -0,0 +1,98 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package logs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSelector(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected Selector
+		err      bool
+	}{
+		{
+			name:  "empty",
+			input: "  ",
+		},
+		{
+			name:  "empty stream selector",
+			input: "{}",
+		},
+		{
+			name:  "tag matchers",
+			input: `{service.name="checkout", k8s.pod-name!="a\"b", http/code=~"5..",env!~` + "`dev|test`" + `,}`,
+			expected: Selector{Matchers: []TagMatcher{
+				{Type: MatchEqual, Key: "service.name", Value: "checkout"},
+				{Type: MatchNotEqual, Key: "k8s.pod-name", Value: `a"b`},
+				{Type: MatchRegexp, Key: "http/code", Value: "5.."},
+				{Type: MatchNotRegexp, Key: "env", Value: "dev|test"},
+			}},
+		},
+		{
+			name:  "line filters",
+			input: `{level="error"} |= "timeout" != "retry" |~ "db-[0-9]+" !~ "^debug"`,
+			expected: Selector{
+				Matchers: []TagMatcher{{Type: MatchEqual, Key: "level", Value: "error"}},
+				Filters: []LineFilter{
+					{Type: FilterContains, Value: "timeout"},
+					{Type: FilterNotContains, Value: "retry"},
+					{Type: FilterRegexp, Value: "db-[0-9]+"},
+					{Type: FilterNotRegexp, Value: "^debug"},
+				},
+			},
+		},
+		{
+			name:     "line filters only",
+			input:    `|= "panic"`,
+			expected: Selector{Filters: []LineFilter{{Type: FilterContains, Value: "panic"}}},
+		},
+		{
+			name:  "missing closing brace",
+			input: `{a="b"`,
+			err:   true,
+		},
+		{
+			name:  "missing comma",
+			input: `{a="b" c="d"}`,
+			err:   true,
+		},
+		{
+			name:  "unquoted value",
+			input: `{a=b}`,
+			err:   true,
+		},
+		{
+			name:  "single quoted value",
+			input: `{a='b'}`,
+			err:   true,
+		},
+		{
+			name:  "invalid regexp",
+			input: `{a=~"("}`,
+			err:   true,
+		},
+		{
+			name:  "invalid line filter",
+			input: `{a="b"} |> "c"`,
+			err:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sel, err := ParseSelector(tc.input)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, sel)
+		})
+	}
+}
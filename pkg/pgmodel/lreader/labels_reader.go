@@ -9,13 +9,17 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
 
 	"github.com/timescale/promscale/pkg/log"
 	"github.com/timescale/promscale/pkg/pgmodel/cache"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/pgmodel/model/pgutf8str"
+	"github.com/timescale/promscale/pkg/pgmodel/querier/clauses"
 	"github.com/timescale/promscale/pkg/pgxconn"
 	"github.com/timescale/promscale/pkg/tenancy"
 )
@@ -26,12 +30,70 @@ const (
 	getLabelsSQL      = "SELECT (prom_api.labels_info($1::int[])).*"
 )
 
+// LabelsQuery restricts the series whose labels are read. The zero value reads
+// the labels of all series.
+type LabelsQuery struct {
+	// Matchers select the series. All series are selected if empty.
+	Matchers []*labels.Matcher
+	// Start and End, in milliseconds, select the series of the metrics having
+	// chunks overlapping the range. Like Prometheus blocks, chunks may hold
+	// older or newer samples of a series, so the result can include a few
+	// labels of series without samples in the range. Zero values are unbounded.
+	// The range is ignored without TimescaleDB.
+	Start, End int64
+	// Limit is the maximum number of labels returned, 0 meaning no limit.
+	Limit int
+}
+
+// timeBounded returns true if the query restricts the time range.
+func (q LabelsQuery) timeBounded() bool {
+	return q.start() != nil || q.end() != nil
+}
+
+// timeBounds returns the qualifiers of the chunks overlapping the time range,
+// with parameter number placeholders, and their parameters.
+func (q LabelsQuery) timeBounds() ([]string, []interface{}) {
+	var (
+		bounds []string
+		args   []interface{}
+	)
+	if start := q.start(); start != nil {
+		bounds, args = append(bounds, scopedTimeStartQual), append(args, start)
+	}
+	if end := q.end(); end != nil {
+		bounds, args = append(bounds, scopedTimeEndQual), append(args, end)
+	}
+	return bounds, args
+}
+
+func (q LabelsQuery) start() interface{} {
+	if q.Start == 0 || q.Start <= timestamp.FromTime(model.MinTime) {
+		return nil
+	}
+	return timestamp.Time(q.Start)
+}
+
+func (q LabelsQuery) end() interface{} {
+	if q.End == 0 || q.End >= timestamp.FromTime(model.MaxTime) {
+		return nil
+	}
+	return timestamp.Time(q.End)
+}
+
+// scoped returns true if the query selects a subset of the series.
+func (q LabelsQuery) scoped() bool {
+	return len(q.Matchers) > 0 || q.timeBounded()
+}
+
 // LabelsReader defines the methods for accessing labels data
 type LabelsReader interface {
-	// LabelNames returns all the distinct label names in the system.
-	LabelNames() ([]string, error)
-	// LabelValues returns all the distinct values for a given label name.
-	LabelValues(labelName string) ([]string, error)
+	// LabelNames returns the distinct label names of the series selected by
+	// the query, restricted to the tenants authorized for the context.
+	LabelNames(ctx context.Context, q LabelsQuery) ([]string, error)
+	// LabelValues returns the distinct values for a given label name of the
+	// series selected by the query, restricted to the tenants authorized for
+	// the context.
+	LabelValues(ctx context.Context, labelName string, q LabelsQuery) ([]string, error)
 	// LabelsForIdMap fills in the label.Label values in a map of label id => labels.Label.
 	LabelsForIdMap(idMap map[int64]labels.Label) (err error)
 }
@@ -94,18 +156,149 @@ const (
 	)`
 )
 
+const (
+	// The labels of the selected series are read through the ids of the
+	// series labels. Ordering with the "C" collation matches sort.Strings, so
+	// that limited results are the first of the sorted ones.
+	getScopedLabelNamesSQL = `SELECT l.key
+FROM _prom_catalog.label l
+WHERE l.id IN (
+	SELECT unnest(s.labels)
+	FROM _prom_catalog.series s
+	WHERE s.delete_epoch IS NULL AND %s
+)
+GROUP BY l.key
+ORDER BY l.key COLLATE "C"%s`
+
+	getScopedLabelValuesSQL = `SELECT l.value
+FROM _prom_catalog.label l
+WHERE l.key = $%d AND l.id IN (
+	SELECT unnest(s.labels)
+	FROM _prom_catalog.series s
+	WHERE s.delete_epoch IS NULL AND %s
+)
+GROUP BY l.value
+ORDER BY l.value COLLATE "C"%s`
+
+	// Only series having the label are read for its values. The label name
+	// parameter is shared with the outer query.
+	scopedLabelKeyQual = "labels && (SELECT COALESCE(array_agg(k.id), array[]::int[]) FROM _prom_catalog.label k WHERE k.key = $%d)"
+	scopedTenantQual   = "labels && (SELECT COALESCE(array_agg(t.id), array[]::int[]) FROM _prom_catalog.label t WHERE t.key = '__tenant__' AND t.value = ANY($%d::text[]))"
+	// The time range is matched against the chunks of the metric tables, hence
+	// it requires TimescaleDB.
+	scopedTimeQual = `s.metric_id IN (
+		SELECT m.id
+		FROM _prom_catalog.metric m
+		WHERE EXISTS (
+			SELECT 1
+			FROM timescaledb_information.chunks c
+			WHERE c.hypertable_schema = m.table_schema AND c.hypertable_name = m.table_name AND %s
+		)
+	)`
+	scopedTimeStartQual = "c.range_end > $%d"
+	scopedTimeEndQual   = "c.range_start <= $%d"
+	scopedLimit         = "\nLIMIT $%d"
+
+	isTimescaleDBInstalledSQL = "SELECT _prom_catalog.is_timescaledb_installed()"
+	// A time range without chunks of the metric tables outside of it selects
+	// all the series.
+	chunksOutsideRangeSQL = `SELECT EXISTS (
+	SELECT 1
+	FROM _prom_catalog.metric m
+	INNER JOIN timescaledb_information.chunks c ON c.hypertable_schema = m.table_schema AND c.hypertable_name = m.table_name
+	WHERE NOT (%s)
+)`
+)
+
+// Values of labelsReader.timescaleDB.
+const (
+	timescaleDBUnknown int32 = iota
+	timescaleDBInstalled
+	timescaleDBMissing
+)
+
 type labelsReader struct {
 	conn       pgxconn.PgxConn
 	labels     cache.LabelsCache
 	authConfig tenancy.AuthConfig
+	// Whether TimescaleDB is installed, checked on the first time bounded query.
+	timescaleDB int32
 }
 
-// LabelValues implements the LabelsReader interface. It returns all distinct values
+func (lr *labelsReader) isTimescaleDBInstalled(ctx context.Context) (bool, error) {
+	switch atomic.LoadInt32(&lr.timescaleDB) {
+	case timescaleDBInstalled:
+		return true, nil
+	case timescaleDBMissing:
+		return false, nil
+	}
+	var installed bool
+	if err := lr.conn.QueryRow(ctx, isTimescaleDBInstalledSQL).Scan(&installed); err != nil {
+		return false, fmt.Errorf("error checking if TimescaleDB is installed: %w", err)
+	}
+	state := timescaleDBMissing
+	if installed {
+		state = timescaleDBInstalled
+	}
+	atomic.StoreInt32(&lr.timescaleDB, state)
+	return installed, nil
+}
+
+// scope returns the query without its time range when the range cannot
+// restrict the series. Without TimescaleDB, there are no chunks to match the
+// range against. Without matchers, a range that all the chunks are within
+// selects all the series, which are read faster without any scope.
+func (lr *labelsReader) scope(ctx context.Context, q LabelsQuery) (LabelsQuery, error) {
+	if !q.timeBounded() {
+		return q, nil
+	}
+	installed, err := lr.isTimescaleDBInstalled(ctx)
+	if err != nil {
+		return q, err
+	}
+	if installed && len(q.Matchers) > 0 {
+		return q, nil
+	}
+	if installed {
+		bounds, args := q.timeBounds()
+		query, args, err := clauses.SetParameterNumbers(fmt.Sprintf(chunksOutsideRangeSQL, strings.Join(bounds, " AND ")), nil, args...)
+		if err != nil {
+			return q, err
+		}
+		var outside bool
+		if err = lr.conn.QueryRow(ctx, query, args...).Scan(&outside); err != nil {
+			return q, fmt.Errorf("error checking the chunks outside of the time range: %w", err)
+		}
+		if outside {
+			return q, nil
+		}
+	}
+	q.Start, q.End = 0, 0
+	return q, nil
+}
+
+// authorizedTenants returns the tenants whose labels can be read, and false if
+// labels are read regardless of the tenants.
+func (lr *labelsReader) authorizedTenants(ctx context.Context) ([]string, bool) {
+	if lr.authConfig == nil {
+		// Multi-tenancy is disabled.
+		return nil, false
+	}
+	return tenancy.AuthorizedTenants(ctx, lr.authConfig)
+}
+
+// LabelValues implements the LabelsReader interface. It returns the distinct values
 // for a specified label name.
-func (lr *labelsReader) LabelValues(labelName string) ([]string, error) {
-	if lr.authConfig != nil && lr.authConfig.AllowAuthorizedTenantsOnly() {
+func (lr *labelsReader) LabelValues(ctx context.Context, labelName string, q LabelsQuery) ([]string, error) {
+	q, err := lr.scope(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if q.scoped() {
+		return lr.scopedLabels(ctx, &labelName, q)
+	}
+	if validTenants, restricted := lr.authorizedTenants(ctx); restricted {
 		// For comments, see LabelNames().
-		validTenants := lr.authConfig.ValidTenants()
 		if len(validTenants) == 0 {
 			log.Debug("msg", "no tenants found for LabelValues()")
 			return []string{}, nil
@@ -117,15 +310,15 @@ func (lr *labelsReader) LabelValues(labelName string) ([]string, error) {
 		}
 		labelValuesQuery := fmt.Sprintf(getLabelValuesForTenant, strings.Join(tenantValueClauses, " OR "))
 		var labelValues []string
-		if err := lr.conn.QueryRow(context.Background(), labelValuesQuery, args...).Scan(&labelValues); err != nil {
+		if err := lr.conn.QueryRow(ctx, labelValuesQuery, args...).Scan(&labelValues); err != nil {
 			return nil, fmt.Errorf("error reading label values belonging to a tenant id: %w", err)
 		}
 		if labelValues == nil {
 			labelValues = []string{}
 		}
-		return labelValues, nil
+		return limitLabels(labelValues, q.Limit), nil
 	}
-	rows, err := lr.conn.Query(context.Background(), getLabelValuesSQL, labelName)
+	rows, err := lr.conn.Query(ctx, getLabelValuesSQL, labelName)
 	if err != nil {
 		return nil, err
 	}
@@ -148,18 +341,28 @@ func (lr *labelsReader) LabelValues(labelName string) ([]string, error) {
 		}
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	sort.Strings(labelValues)
-	return labelValues, nil
+	return limitLabels(labelValues, q.Limit), nil
 }
 
-// LabelNames implements the LabelReader interface. It returns all distinct
+// LabelNames implements the LabelReader interface. It returns the distinct
 // label names available in the database.
-func (lr *labelsReader) LabelNames() ([]string, error) {
-	if lr.authConfig != nil && lr.authConfig.AllowAuthorizedTenantsOnly() {
+func (lr *labelsReader) LabelNames(ctx context.Context, q LabelsQuery) ([]string, error) {
+	q, err := lr.scope(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if q.scoped() {
+		return lr.scopedLabels(ctx, nil, q)
+	}
+	if validTenants, restricted := lr.authorizedTenants(ctx); restricted {
 		// Multi-tenancy is enabled.
 		// Note: Label names of non-tenants will not be sent. Only label names belonging to
 		// authorized tenants will be sent.
-		validTenants := lr.authConfig.ValidTenants()
 		if len(validTenants) == 0 {
 			log.Debug("msg", "no tenants found for LabelNames()")
 			return []string{}, nil
@@ -172,16 +375,16 @@ func (lr *labelsReader) LabelNames() ([]string, error) {
 		}
 		query := fmt.Sprintf(getLabelNamesForTenant, strings.Join(tenantValueClauses, " OR "))
 		var labelNames []string
-		if err := lr.conn.QueryRow(context.Background(), query, args...).Scan(&labelNames); err != nil {
+		if err := lr.conn.QueryRow(ctx, query, args...).Scan(&labelNames); err != nil {
 			return nil, fmt.Errorf("error reading label names belonging to a tenant id: %w", err)
 		}
 		if labelNames == nil {
 			labelNames = []string{}
 		}
-		return labelNames, nil
+		return limitLabels(labelNames, q.Limit), nil
 	}
 
-	rows, err := lr.conn.Query(context.Background(), getLabelNamesSQL)
+	rows, err := lr.conn.Query(ctx, getLabelNamesSQL)
 	if err != nil {
 		return nil, err
 	}
@@ -199,8 +402,103 @@ func (lr *labelsReader) LabelNames() ([]string, error) {
 		labelNames = append(labelNames, labelName)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	sort.Strings(labelNames)
-	return labelNames, nil
+	return limitLabels(labelNames, q.Limit), nil
+}
+
+// scopedLabels returns the label names, or the values of the label name if
+// not nil, of the series selected by the query.
+func (lr *labelsReader) scopedLabels(ctx context.Context, labelName *string, q LabelsQuery) ([]string, error) {
+	validTenants, restricted := lr.authorizedTenants(ctx)
+	if restricted && len(validTenants) == 0 {
+		log.Debug("msg", "no tenants found for labels query")
+		return []string{}, nil
+	}
+
+	var quals []string
+	var args []interface{}
+	if len(q.Matchers) > 0 {
+		builder, err := clauses.BuildSubQueries(q.Matchers)
+		if err != nil {
+			return nil, fmt.Errorf("build subQueries: %w", err)
+		}
+		if quals, args, err = builder.Build(true); err != nil {
+			return nil, fmt.Errorf("building labels clauses: %w", err)
+		}
+	}
+	addQual := func(qual string, newArgs ...interface{}) error {
+		qual, newArgs, err := clauses.SetParameterNumbers(qual, args, newArgs...)
+		if err != nil {
+			return err
+		}
+		quals, args = append(quals, qual), newArgs
+		return nil
+	}
+	if restricted {
+		if err := addQual(scopedTenantQual, validTenants); err != nil {
+			return nil, err
+		}
+	}
+	if labelName != nil {
+		if err := addQual(scopedLabelKeyQual, *labelName); err != nil {
+			return nil, err
+		}
+	}
+	labelNameArg := len(args)
+	if q.timeBounded() {
+		bounds, boundArgs := q.timeBounds()
+		if err := addQual(fmt.Sprintf(scopedTimeQual, strings.Join(bounds, " AND ")), boundArgs...); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(quals) == 0 {
+		quals = []string{"TRUE"}
+	}
+	where := strings.Join(quals, " AND ")
+	limit := ""
+	if q.Limit > 0 {
+		args = append(args, q.Limit)
+		limit = fmt.Sprintf(scopedLimit, len(args))
+	}
+	var query string
+	if labelName != nil {
+		query = fmt.Sprintf(getScopedLabelValuesSQL, labelNameArg, where, limit)
+	} else {
+		query = fmt.Sprintf(getScopedLabelNamesSQL, where, limit)
+	}
+
+	rows, err := lr.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error reading labels: %w", err)
+	}
+	defer rows.Close()
+
+	res := make([]string, 0)
+	for rows.Next() {
+		var label string
+		if err := rows.Scan(&label); err != nil {
+			return nil, err
+		}
+		if labelName != nil && *labelName == tenancy.TenantLabelKey && lr.authConfig != nil && !lr.authConfig.IsTenantAllowed(label) {
+			continue
+		}
+		res = append(res, label)
+	}
+	return res, rows.Err()
+}
+
+// limitLabels returns the first limit sorted labels, or all of them if limit
+// is 0.
+func limitLabels(sorted []string, limit int) []string {
+	if limit > 0 && len(sorted) > limit {
+		return sorted[:limit]
+	}
+	return sorted
 }
 
 // LabelsForIdMap fills in the label.Label values in a map of label id => labels.Label.
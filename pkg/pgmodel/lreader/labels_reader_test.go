@@ -5,11 +5,15 @@
 package lreader
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"sort"
 	"testing"
 
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+
 	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/tenancy"
 )
@@ -19,6 +23,7 @@ func TestLabelsReaderLabelsNames(t *testing.T) {
 		name        string
 		expectedRes []string
 		sqlQueries  []model.SqlQuery
+		query       LabelsQuery
 	}{
 		{
 			name: "Error on query",
@@ -59,6 +64,40 @@ func TestLabelsReaderLabelsNames(t *testing.T) {
 				},
 			},
 			expectedRes: []string{"a", "b"},
+		}, {
+			name: "Limited result",
+			sqlQueries: []model.SqlQuery{
+				{
+					Sql:     "SELECT distinct key from _prom_catalog.label",
+					Args:    []interface{}(nil),
+					Results: model.RowResults{{"b"}, {"c"}, {"a"}},
+				},
+			},
+			query:       LabelsQuery{Limit: 2},
+			expectedRes: []string{"a", "b"},
+		}, {
+			name: "Scoped by matchers, time range and limit",
+			sqlQueries: []model.SqlQuery{
+				{
+					Sql:     "SELECT _prom_catalog.is_timescaledb_installed()",
+					Results: model.RowResults{{true}},
+				},
+				{
+					Sql:     "SELECT l.key FROM _prom_catalog.label l WHERE l.id IN ( SELECT unnest(s.labels) FROM _prom_catalog.series s WHERE s.delete_epoch IS NULL AND labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $1 and l.value ~ $2) AND labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $3 and l.value = $4) AND s.metric_id IN ( SELECT m.id FROM _prom_catalog.metric m WHERE EXISTS ( SELECT 1 FROM timescaledb_information.chunks c WHERE c.hypertable_schema = m.table_schema AND c.hypertable_name = m.table_name AND c.range_end > $5 AND c.range_start <= $6 ) ) ) GROUP BY l.key ORDER BY l.key COLLATE \"C\" LIMIT $7",
+					Args:    []interface{}{"job", "^(?:api|db)$", "__name__", "up", timestamp.Time(1000), timestamp.Time(2000), 2},
+					Results: model.RowResults{{"__name__"}, {"job"}},
+				},
+			},
+			query: LabelsQuery{
+				Matchers: []*labels.Matcher{
+					labels.MustNewMatcher(labels.MatchEqual, model.MetricNameLabelName, "up"),
+					labels.MustNewMatcher(labels.MatchRegexp, "job", "api|db"),
+				},
+				Start: 1000,
+				End:   2000,
+				Limit: 2,
+			},
+			expectedRes: []string{"__name__", "job"},
 		},
 	}
 
@@ -66,7 +105,7 @@ func TestLabelsReaderLabelsNames(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			mock := model.NewSqlRecorder(tc.sqlQueries, t)
 			reader := labelsReader{conn: mock}
-			res, err := reader.LabelNames()
+			res, err := reader.LabelNames(context.Background(), tc.query)
 
 			var expectedErr error
 			for _, q := range tc.sqlQueries {
@@ -108,6 +147,7 @@ func TestLabelsReaderLabelsValues(t *testing.T) {
 		sqlQueries  []model.SqlQuery
 		labelName   string
 		tenant      tenancy.AuthConfig
+		query       LabelsQuery
 	}{
 		{
 			name: "Error on query",
@@ -178,6 +218,87 @@ func TestLabelsReaderLabelsValues(t *testing.T) {
 			labelName:   tenancy.TenantLabelKey,
 			tenant:      tenancy.NewSelectiveTenancyConfig([]string{"a"}, false, true),
 		},
+		{
+			name: "Scoped by matchers and tenant",
+			sqlQueries: []model.SqlQuery{
+				{
+					Sql:     "SELECT l.value FROM _prom_catalog.label l WHERE l.key = $4 AND l.id IN ( SELECT unnest(s.labels) FROM _prom_catalog.series s WHERE s.delete_epoch IS NULL AND NOT labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $1 and l.value = $2) AND labels && (SELECT COALESCE(array_agg(t.id), array[]::int[]) FROM _prom_catalog.label t WHERE t.key = '__tenant__' AND t.value = ANY($3::text[])) AND labels && (SELECT COALESCE(array_agg(k.id), array[]::int[]) FROM _prom_catalog.label k WHERE k.key = $4) ) GROUP BY l.value ORDER BY l.value COLLATE \"C\"",
+					Args:    []interface{}{"job", "api", []string{"a"}, "instance"},
+					Results: model.RowResults{{"host:80"}},
+				},
+			},
+			query: LabelsQuery{
+				Matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchNotEqual, "job", "api")},
+			},
+			expectedRes: []string{"host:80"},
+			labelName:   "instance",
+			tenant:      tenancy.NewSelectiveTenancyConfig([]string{"a"}, false, true),
+		},
+		{
+			name: "Scoped by start time only",
+			sqlQueries: []model.SqlQuery{
+				{
+					Sql:     "SELECT _prom_catalog.is_timescaledb_installed()",
+					Results: model.RowResults{{true}},
+				},
+				{
+					Sql:     "SELECT EXISTS ( SELECT 1 FROM _prom_catalog.metric m INNER JOIN timescaledb_information.chunks c ON c.hypertable_schema = m.table_schema AND c.hypertable_name = m.table_name WHERE NOT (c.range_end > $1) )",
+					Args:    []interface{}{timestamp.Time(1000)},
+					Results: model.RowResults{{true}},
+				},
+				{
+					Sql:     "SELECT l.value FROM _prom_catalog.label l WHERE l.key = $1 AND l.id IN ( SELECT unnest(s.labels) FROM _prom_catalog.series s WHERE s.delete_epoch IS NULL AND labels && (SELECT COALESCE(array_agg(k.id), array[]::int[]) FROM _prom_catalog.label k WHERE k.key = $1) AND s.metric_id IN ( SELECT m.id FROM _prom_catalog.metric m WHERE EXISTS ( SELECT 1 FROM timescaledb_information.chunks c WHERE c.hypertable_schema = m.table_schema AND c.hypertable_name = m.table_name AND c.range_end > $2 ) ) ) GROUP BY l.value ORDER BY l.value COLLATE \"C\"",
+					Args:    []interface{}{"m", timestamp.Time(1000)},
+					Results: model.RowResults{{"a"}, {"b"}},
+				},
+			},
+			query:       LabelsQuery{Start: 1000, End: timestamp.FromTime(model.MaxTime)},
+			expectedRes: []string{"a", "b"},
+			labelName:   "m",
+		},
+		{
+			name: "Time range covering all chunks",
+			sqlQueries: []model.SqlQuery{
+				{
+					Sql:     "SELECT _prom_catalog.is_timescaledb_installed()",
+					Results: model.RowResults{{true}},
+				},
+				{
+					Sql:     "SELECT EXISTS ( SELECT 1 FROM _prom_catalog.metric m INNER JOIN timescaledb_information.chunks c ON c.hypertable_schema = m.table_schema AND c.hypertable_name = m.table_name WHERE NOT (c.range_end > $1 AND c.range_start <= $2) )",
+					Args:    []interface{}{timestamp.Time(1000), timestamp.Time(2000)},
+					Results: model.RowResults{{false}},
+				},
+				{
+					Sql:     "SELECT value from _prom_catalog.label WHERE key = $1",
+					Args:    []interface{}{"m"},
+					Results: model.RowResults{{"b"}, {"a"}},
+				},
+			},
+			query:       LabelsQuery{Start: 1000, End: 2000},
+			expectedRes: []string{"a", "b"},
+			labelName:   "m",
+		},
+		{
+			name: "Time range without TimescaleDB",
+			sqlQueries: []model.SqlQuery{
+				{
+					Sql:     "SELECT _prom_catalog.is_timescaledb_installed()",
+					Results: model.RowResults{{false}},
+				},
+				{
+					Sql:     "SELECT l.value FROM _prom_catalog.label l WHERE l.key = $3 AND l.id IN ( SELECT unnest(s.labels) FROM _prom_catalog.series s WHERE s.delete_epoch IS NULL AND labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $1 and l.value = $2) AND labels && (SELECT COALESCE(array_agg(k.id), array[]::int[]) FROM _prom_catalog.label k WHERE k.key = $3) ) GROUP BY l.value ORDER BY l.value COLLATE \"C\"",
+					Args:    []interface{}{"job", "api", "instance"},
+					Results: model.RowResults{{"host:80"}},
+				},
+			},
+			query: LabelsQuery{
+				Matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "job", "api")},
+				Start:    1000,
+				End:      2000,
+			},
+			expectedRes: []string{"host:80"},
+			labelName:   "instance",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -187,7 +308,7 @@ func TestLabelsReaderLabelsValues(t *testing.T) {
 			if tc.tenant != nil {
 				querier = labelsReader{conn: mock, authConfig: tc.tenant}
 			}
-			res, err := querier.LabelValues(tc.labelName)
+			res, err := querier.LabelValues(context.Background(), tc.labelName, tc.query)
 
 			var expectedErr error
 			for _, q := range tc.sqlQueries {
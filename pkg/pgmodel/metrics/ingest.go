@@ -31,6 +31,14 @@ var (
 			Help:      "Total number of processed samples/write_requests_to_db/metrics which where duplicates.",
 		}, []string{"type", "kind"},
 	)
+	IngestorDuplicatesBySource = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "ingest",
+			Name:      "duplicate_samples_by_source_total",
+			Help:      "Total number of duplicate samples, by metric and by the tenant and HA replica of the write requests which sent them.",
+		}, []string{"type", "metric", "tenant", "replica"},
+	)
 	IngestorDecompressCalls = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: util.PromNamespace,
@@ -46,6 +54,22 @@ var (
 			Name:      "decompress_min_unix_time",
 			Help:      "Earliest decompression time in unix.",
 		}, []string{"type", "kind", "table"})
+	IngestorOutOfBoundsSamples = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "ingest",
+			Name:      "out_of_bounds_samples_total",
+			Help:      "Total number of samples older than the maximum sample age or the out-of-order window of their series, by the action taken.",
+		}, []string{"type", "reason", "action"},
+	)
+	IngestorSeriesOutcomes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "ingest",
+			Name:      "series_outcomes_total",
+			Help:      "Total number of series of write requests, and of their samples, histograms and exemplars, by outcome: accepted, duplicate, rejected_by_limit or invalid.",
+		}, []string{"type", "kind", "outcome"},
+	)
 	IngestorMaxSentTimestamp = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: util.PromNamespace,
@@ -205,8 +229,11 @@ func init() {
 	IngestorChannelCap.With(prometheus.Labels{"type": "metric", "subsystem": "metric_batcher", "kind": "sample"}).Set(MetricBatcherChannelCap)
 	prometheus.MustRegister(
 		IngestorDuplicates,
+		IngestorDuplicatesBySource,
 		IngestorDecompressCalls,
 		IngestorDecompressEarliest,
+		IngestorOutOfBoundsSamples,
+		IngestorSeriesOutcomes,
 		IngestorMaxSentTimestamp,
 		IngestorChannelCap,
 		IngestorChannelLenBatcher,
@@ -30,6 +30,7 @@ const (
 	preinstallScripts = "preinstall"
 	versionScripts    = "versions/dev"
 	idempotentScripts = "idempotent"
+	connectorScripts  = "connector"
 )
 
 var (
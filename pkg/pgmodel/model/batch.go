@@ -16,17 +16,21 @@ import (
 type Data struct {
 	Rows         map[string][]Insertable
 	ReceivedTime time.Time
+	// Source of the write request the data comes from, used to attribute
+	// duplicate samples.
+	Source Source
 }
 
 // Batch is an iterator over a collection of Insertables that returns
 // data in the format expected for the data table row.
 type Batch struct {
-	data         []Insertable
-	numSamples   int
-	numExemplars int
+	data          []Insertable
+	numSamples    int
+	numExemplars  int
+	numHistograms int
 }
 
-// NewBatch returns a new batch that can hold samples and exemplars.
+// NewBatch returns a new batch that can hold samples, exemplars and histograms.
 func NewBatch() Batch {
 	si := Batch{data: make([]Insertable, 0)}
 	return si
@@ -37,7 +41,7 @@ func (t *Batch) Reset() {
 		// nil all pointers to prevent memory leaks
 		t.data[i] = nil
 	}
-	*t = Batch{data: t.data[:0], numSamples: 0, numExemplars: 0}
+	*t = Batch{data: t.data[:0], numSamples: 0, numExemplars: 0, numHistograms: 0}
 }
 
 func (t *Batch) CountSeries() int {
@@ -52,6 +56,10 @@ func (t *Batch) Count() (numSamples, numExemplars int) {
 	return t.numSamples, t.numExemplars
 }
 
+func (t *Batch) CountHistograms() int {
+	return t.numHistograms
+}
+
 func (t *Batch) AppendSlice(s []Insertable) {
 	t.data = append(t.data, s...)
 	for _, d := range s {
@@ -59,8 +67,10 @@ func (t *Batch) AppendSlice(s []Insertable) {
 			t.numSamples += d.Count()
 		} else if d.IsOfType(Exemplar) {
 			t.numExemplars += d.Count()
+		} else if d.IsOfType(Histogram) {
+			t.numHistograms += d.Count()
 		} else {
-			panic(fmt.Sprintf("invalid type %T. Valid options: ['Sample', 'Exemplar']", d))
+			panic(fmt.Sprintf("invalid type %T. Valid options: ['Sample', 'Exemplar', 'Histogram']", d))
 		}
 	}
 }
@@ -0,0 +1,386 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+// HistogramResetHint mirrors the reset hint sent by Prometheus along with a native histogram.
+type HistogramResetHint int8
+
+const (
+	HistogramUnknownCounterReset HistogramResetHint = iota
+	HistogramCounterReset
+	HistogramNotCounterReset
+	HistogramGaugeType
+)
+
+// HistogramSpan defines a number of consecutive buckets in a native histogram.
+type HistogramSpan struct {
+	// Offset is the gap to the previous span (or to zero for the first span).
+	Offset int32
+	// Length is the number of consecutive buckets in the span.
+	Length uint32
+}
+
+// FloatHistogram is a sparse (native) histogram whose bucket counts
+// are stored as absolute float values. It is the representation used
+// by Promscale for storing and querying native histograms, regardless of
+// whether the histogram was sent with integer or float counts.
+type FloatHistogram struct {
+	ResetHint HistogramResetHint
+	// Schema defines the resolution of the buckets. Valid range is [-4, 8].
+	Schema        int32
+	ZeroThreshold float64
+	ZeroCount     float64
+	Count         float64
+	Sum           float64
+
+	PositiveSpans   []HistogramSpan
+	NegativeSpans   []HistogramSpan
+	PositiveBuckets []float64
+	NegativeBuckets []float64
+}
+
+// HistogramBucket is a single bucket of a native histogram with its boundaries.
+type HistogramBucket struct {
+	Lower, Upper                   float64
+	LowerInclusive, UpperInclusive bool
+	Count                          float64
+}
+
+// FloatHistogramFromProto converts a native histogram received over remote-write
+// into a FloatHistogram. Integer histograms carry their bucket counts as deltas, which
+// are converted into absolute counts here.
+func FloatHistogramFromProto(h *prompb.Histogram) (*FloatHistogram, error) {
+	if h.Schema < -4 || h.Schema > 8 {
+		return nil, fmt.Errorf("invalid histogram schema %d: must be in range [-4, 8]", h.Schema)
+	}
+	fh := &FloatHistogram{
+		ResetHint:     HistogramResetHint(h.ResetHint),
+		Schema:        h.Schema,
+		ZeroThreshold: h.ZeroThreshold,
+		Sum:           h.Sum,
+		PositiveSpans: spansFromProto(h.PositiveSpans),
+		NegativeSpans: spansFromProto(h.NegativeSpans),
+	}
+	switch c := h.Count.(type) {
+	case *prompb.Histogram_CountInt:
+		fh.Count = float64(c.CountInt)
+	case *prompb.Histogram_CountFloat:
+		fh.Count = c.CountFloat
+	}
+	switch c := h.ZeroCount.(type) {
+	case *prompb.Histogram_ZeroCountInt:
+		fh.ZeroCount = float64(c.ZeroCountInt)
+	case *prompb.Histogram_ZeroCountFloat:
+		fh.ZeroCount = c.ZeroCountFloat
+	}
+	if h.IsFloatHistogram() {
+		fh.PositiveBuckets = append([]float64(nil), h.PositiveCounts...)
+		fh.NegativeBuckets = append([]float64(nil), h.NegativeCounts...)
+	} else {
+		fh.PositiveBuckets = deltasToCounts(h.PositiveDeltas)
+		fh.NegativeBuckets = deltasToCounts(h.NegativeDeltas)
+	}
+	if err := checkSpans(fh.PositiveSpans, len(fh.PositiveBuckets)); err != nil {
+		return nil, fmt.Errorf("positive side: %w", err)
+	}
+	if err := checkSpans(fh.NegativeSpans, len(fh.NegativeBuckets)); err != nil {
+		return nil, fmt.Errorf("negative side: %w", err)
+	}
+	return fh, nil
+}
+
+// ToProto converts the histogram into its remote-read representation, with float counts.
+func (h *FloatHistogram) ToProto(timestamp int64) prompb.Histogram {
+	return prompb.Histogram{
+		Count:          &prompb.Histogram_CountFloat{CountFloat: h.Count},
+		Sum:            h.Sum,
+		Schema:         h.Schema,
+		ZeroThreshold:  h.ZeroThreshold,
+		ZeroCount:      &prompb.Histogram_ZeroCountFloat{ZeroCountFloat: h.ZeroCount},
+		NegativeSpans:  spansToProto(h.NegativeSpans),
+		NegativeCounts: h.NegativeBuckets,
+		PositiveSpans:  spansToProto(h.PositiveSpans),
+		PositiveCounts: h.PositiveBuckets,
+		ResetHint:      prompb.Histogram_ResetHint(h.ResetHint),
+		Timestamp:      timestamp,
+	}
+}
+
+// AllBuckets returns all the buckets of the histogram, including the zero bucket,
+// ordered by ascending bucket boundaries.
+func (h *FloatHistogram) AllBuckets() []HistogramBucket {
+	negative := h.buckets(h.NegativeSpans, h.NegativeBuckets, false)
+	positive := h.buckets(h.PositiveSpans, h.PositiveBuckets, true)
+	all := make([]HistogramBucket, 0, len(negative)+len(positive)+1)
+	for i := len(negative) - 1; i >= 0; i-- {
+		all = append(all, negative[i])
+	}
+	if h.ZeroThreshold != 0 || h.ZeroCount != 0 {
+		all = append(all, HistogramBucket{
+			Lower:          -h.ZeroThreshold,
+			Upper:          h.ZeroThreshold,
+			LowerInclusive: true,
+			UpperInclusive: true,
+			Count:          h.ZeroCount,
+		})
+	}
+	return append(all, positive...)
+}
+
+// Copy returns a deep copy of the histogram.
+func (h *FloatHistogram) Copy() *FloatHistogram {
+	c := *h
+	c.PositiveSpans = append([]HistogramSpan(nil), h.PositiveSpans...)
+	c.NegativeSpans = append([]HistogramSpan(nil), h.NegativeSpans...)
+	c.PositiveBuckets = append([]float64(nil), h.PositiveBuckets...)
+	c.NegativeBuckets = append([]float64(nil), h.NegativeBuckets...)
+	return &c
+}
+
+// Mul multiplies all the counts and the sum of the histogram by factor, in place.
+// It returns the receiver for convenience.
+func (h *FloatHistogram) Mul(factor float64) *FloatHistogram {
+	h.ZeroCount *= factor
+	h.Count *= factor
+	h.Sum *= factor
+	for i := range h.PositiveBuckets {
+		h.PositiveBuckets[i] *= factor
+	}
+	for i := range h.NegativeBuckets {
+		h.NegativeBuckets[i] *= factor
+	}
+	return h
+}
+
+// Add adds other to the histogram, in place. If the schemas differ, the result
+// has the lower resolution of the two. It returns the receiver for convenience.
+func (h *FloatHistogram) Add(other *FloatHistogram) *FloatHistogram {
+	return h.merge(other, 1)
+}
+
+// Sub subtracts other from the histogram, in place. If the schemas differ, the result
+// has the lower resolution of the two. It returns the receiver for convenience.
+func (h *FloatHistogram) Sub(other *FloatHistogram) *FloatHistogram {
+	return h.merge(other, -1)
+}
+
+// DetectReset returns true if the histogram is a counter reset compared to previous.
+func (h *FloatHistogram) DetectReset(previous *FloatHistogram) bool {
+	switch h.ResetHint {
+	case HistogramCounterReset:
+		return true
+	case HistogramNotCounterReset, HistogramGaugeType:
+		return false
+	}
+	return h.Count < previous.Count || h.ZeroCount < previous.ZeroCount
+}
+
+func (h *FloatHistogram) merge(other *FloatHistogram, sign float64) *FloatHistogram {
+	schema := h.Schema
+	if other.Schema < schema {
+		schema = other.Schema
+	}
+	zeroThreshold := math.Max(h.ZeroThreshold, other.ZeroThreshold)
+
+	var zeroCount float64
+	positive := make(map[int32]float64, len(h.PositiveBuckets)+len(other.PositiveBuckets))
+	negative := make(map[int32]float64, len(h.NegativeBuckets)+len(other.NegativeBuckets))
+	for _, c := range []struct {
+		hist *FloatHistogram
+		sign float64
+	}{{h, 1}, {other, sign}} {
+		zeroCount += c.sign * c.hist.ZeroCount
+		zeroCount += addToBucketMap(positive, c.hist.PositiveSpans, c.hist.PositiveBuckets, c.hist.Schema, schema, zeroThreshold, c.sign)
+		zeroCount += addToBucketMap(negative, c.hist.NegativeSpans, c.hist.NegativeBuckets, c.hist.Schema, schema, zeroThreshold, c.sign)
+	}
+
+	h.Schema = schema
+	h.ZeroThreshold = zeroThreshold
+	h.ZeroCount = zeroCount
+	h.Count += sign * other.Count
+	h.Sum += sign * other.Sum
+	h.PositiveSpans, h.PositiveBuckets = bucketMapToSpans(positive)
+	h.NegativeSpans, h.NegativeBuckets = bucketMapToSpans(negative)
+	return h
+}
+
+// addToBucketMap adds the buckets described by spans and counts to the map of bucket index
+// to count, converting the indexes to the target schema. Counts of buckets that fall into
+// the zero bucket are not added to the map but returned instead.
+func addToBucketMap(m map[int32]float64, spans []HistogramSpan, counts []float64, schema, targetSchema int32, zeroThreshold, sign float64) (zeroCount float64) {
+	var (
+		idx int32
+		pos int
+	)
+	for i, span := range spans {
+		if i == 0 {
+			idx = span.Offset
+		} else {
+			idx += span.Offset
+		}
+		for j := uint32(0); j < span.Length; j++ {
+			count := sign * counts[pos]
+			if bucketBoundary(schema, idx) <= zeroThreshold {
+				zeroCount += count
+			} else {
+				m[targetBucketIndex(idx, schema-targetSchema)] += count
+			}
+			idx++
+			pos++
+		}
+	}
+	return zeroCount
+}
+
+// targetBucketIndex returns the index of the bucket, which contains the bucket with
+// the given index, after reducing the schema by schemaChange.
+func targetBucketIndex(idx, schemaChange int32) int32 {
+	if schemaChange == 0 {
+		return idx
+	}
+	return ((idx - 1) >> uint(schemaChange)) + 1
+}
+
+func bucketMapToSpans(m map[int32]float64) ([]HistogramSpan, []float64) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	indexes := make([]int32, 0, len(m))
+	for idx := range m {
+		indexes = append(indexes, idx)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	var (
+		spans  []HistogramSpan
+		counts = make([]float64, 0, len(indexes))
+	)
+	for i, idx := range indexes {
+		switch {
+		case i == 0:
+			spans = append(spans, HistogramSpan{Offset: idx, Length: 1})
+		case idx == indexes[i-1]+1:
+			spans[len(spans)-1].Length++
+		default:
+			spans = append(spans, HistogramSpan{Offset: idx - indexes[i-1] - 1, Length: 1})
+		}
+		counts = append(counts, m[idx])
+	}
+	return spans, counts
+}
+
+func (h *FloatHistogram) buckets(spans []HistogramSpan, counts []float64, positive bool) []HistogramBucket {
+	buckets := make([]HistogramBucket, 0, len(counts))
+	var (
+		idx int32
+		pos int
+	)
+	for i, span := range spans {
+		if i == 0 {
+			idx = span.Offset
+		} else {
+			idx += span.Offset
+		}
+		for j := uint32(0); j < span.Length; j++ {
+			lower, upper := bucketBoundary(h.Schema, idx-1), bucketBoundary(h.Schema, idx)
+			b := HistogramBucket{Lower: lower, Upper: upper, UpperInclusive: true, Count: counts[pos]}
+			if !positive {
+				b = HistogramBucket{Lower: -upper, Upper: -lower, LowerInclusive: true, Count: counts[pos]}
+			}
+			buckets = append(buckets, b)
+			idx++
+			pos++
+		}
+	}
+	return buckets
+}
+
+// bucketBoundary returns the upper boundary of the bucket with the given index
+// for the given schema.
+func bucketBoundary(schema, idx int32) float64 {
+	if schema < 0 {
+		return math.Ldexp(1, int(idx)<<uint(-schema))
+	}
+	return math.Exp2(float64(idx) / float64(int(1)<<uint(schema)))
+}
+
+func checkSpans(spans []HistogramSpan, numBuckets int) error {
+	var total int
+	for _, s := range spans {
+		total += int(s.Length)
+	}
+	if total != numBuckets {
+		return fmt.Errorf("spans need %d buckets, have %d buckets", total, numBuckets)
+	}
+	return nil
+}
+
+func deltasToCounts(deltas []int64) []float64 {
+	if len(deltas) == 0 {
+		return nil
+	}
+	counts := make([]float64, len(deltas))
+	var curr int64
+	for i, d := range deltas {
+		curr += d
+		counts[i] = float64(curr)
+	}
+	return counts
+}
+
+func spansFromProto(spans []prompb.BucketSpan) []HistogramSpan {
+	if len(spans) == 0 {
+		return nil
+	}
+	res := make([]HistogramSpan, len(spans))
+	for i, s := range spans {
+		res[i] = HistogramSpan{Offset: s.Offset, Length: s.Length}
+	}
+	return res
+}
+
+func spansToProto(spans []HistogramSpan) []prompb.BucketSpan {
+	if len(spans) == 0 {
+		return nil
+	}
+	res := make([]prompb.BucketSpan, len(spans))
+	for i, s := range spans {
+		res[i] = prompb.BucketSpan{Offset: s.Offset, Length: s.Length}
+	}
+	return res
+}
+
+// SpansToInt32Array flattens the spans into an array of alternating offsets and lengths,
+// which is how the spans are stored in the database.
+func SpansToInt32Array(spans []HistogramSpan) []int32 {
+	res := make([]int32, 0, 2*len(spans))
+	for _, s := range spans {
+		res = append(res, s.Offset, int32(s.Length))
+	}
+	return res
+}
+
+// SpansFromInt32Array is the inverse of SpansToInt32Array.
+func SpansFromInt32Array(arr []int32) ([]HistogramSpan, error) {
+	if len(arr)%2 != 0 {
+		return nil, fmt.Errorf("invalid histogram spans: odd number of elements %d", len(arr))
+	}
+	if len(arr) == 0 {
+		return nil, nil
+	}
+	spans := make([]HistogramSpan, 0, len(arr)/2)
+	for i := 0; i < len(arr); i += 2 {
+		spans = append(spans, HistogramSpan{Offset: arr[i], Length: uint32(arr[i+1])})
+	}
+	return spans, nil
+}
@@ -0,0 +1,117 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+func TestFloatHistogramFromProto(t *testing.T) {
+	h, err := FloatHistogramFromProto(&prompb.Histogram{
+		Count:          &prompb.Histogram_CountInt{CountInt: 10},
+		Sum:            20.5,
+		Schema:         1,
+		ZeroThreshold:  0.001,
+		ZeroCount:      &prompb.Histogram_ZeroCountInt{ZeroCountInt: 2},
+		PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: 2}, {Offset: 1, Length: 1}},
+		PositiveDeltas: []int64{1, 2, -1},
+		NegativeSpans:  []prompb.BucketSpan{{Offset: 1, Length: 1}},
+		NegativeDeltas: []int64{3},
+		ResetHint:      prompb.Histogram_NO,
+	})
+	require.NoError(t, err)
+	require.Equal(t, &FloatHistogram{
+		ResetHint:       HistogramNotCounterReset,
+		Schema:          1,
+		ZeroThreshold:   0.001,
+		ZeroCount:       2,
+		Count:           10,
+		Sum:             20.5,
+		PositiveSpans:   []HistogramSpan{{Offset: 0, Length: 2}, {Offset: 1, Length: 1}},
+		NegativeSpans:   []HistogramSpan{{Offset: 1, Length: 1}},
+		PositiveBuckets: []float64{1, 3, 2},
+		NegativeBuckets: []float64{3},
+	}, h)
+
+	_, err = FloatHistogramFromProto(&prompb.Histogram{
+		Count:          &prompb.Histogram_CountFloat{CountFloat: 1},
+		PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: 2}},
+		PositiveCounts: []float64{1},
+	})
+	require.Error(t, err, "spans not matching the number of buckets must be rejected")
+
+	_, err = FloatHistogramFromProto(&prompb.Histogram{Schema: 9})
+	require.Error(t, err, "invalid schema must be rejected")
+}
+
+func TestFloatHistogramAllBuckets(t *testing.T) {
+	h := &FloatHistogram{
+		Schema:          0,
+		ZeroThreshold:   0.5,
+		ZeroCount:       1,
+		PositiveSpans:   []HistogramSpan{{Offset: 1, Length: 1}, {Offset: 1, Length: 1}},
+		PositiveBuckets: []float64{2, 3},
+		NegativeSpans:   []HistogramSpan{{Offset: 0, Length: 2}},
+		NegativeBuckets: []float64{4, 5},
+	}
+	require.Equal(t, []HistogramBucket{
+		{Lower: -2, Upper: -1, LowerInclusive: true, Count: 5},
+		{Lower: -1, Upper: -0.5, LowerInclusive: true, Count: 4},
+		{Lower: -0.5, Upper: 0.5, LowerInclusive: true, UpperInclusive: true, Count: 1},
+		{Lower: 1, Upper: 2, UpperInclusive: true, Count: 2},
+		{Lower: 4, Upper: 8, UpperInclusive: true, Count: 3},
+	}, h.AllBuckets())
+}
+
+func TestFloatHistogramArithmetic(t *testing.T) {
+	a := &FloatHistogram{
+		Schema:          1,
+		Count:           6,
+		Sum:             10,
+		PositiveSpans:   []HistogramSpan{{Offset: 0, Length: 4}},
+		PositiveBuckets: []float64{1, 2, 2, 1},
+	}
+	b := &FloatHistogram{
+		Schema:          0,
+		Count:           3,
+		Sum:             5,
+		PositiveSpans:   []HistogramSpan{{Offset: 0, Length: 1}, {Offset: 2, Length: 1}},
+		PositiveBuckets: []float64{1, 2},
+	}
+
+	// Reducing schema 1 to schema 0 merges bucket 0 into bucket 0, buckets 1 and 2
+	// into bucket 1 and bucket 3 into bucket 2.
+	sum := a.Copy().Add(b)
+	require.Equal(t, &FloatHistogram{
+		Schema:          0,
+		Count:           9,
+		Sum:             15,
+		PositiveSpans:   []HistogramSpan{{Offset: 0, Length: 4}},
+		PositiveBuckets: []float64{2, 4, 1, 2},
+	}, sum)
+
+	diff := sum.Copy().Sub(b)
+	require.Equal(t, float64(6), diff.Count)
+	require.Equal(t, []float64{1, 4, 1, 0}, diff.PositiveBuckets)
+
+	require.Equal(t, []float64{2, 4, 4, 2}, a.Copy().Mul(2).PositiveBuckets)
+	require.Equal(t, []float64{1, 2, 2, 1}, a.PositiveBuckets, "copy must not share buckets with the original")
+}
+
+func TestSpansInt32ArrayRoundTrip(t *testing.T) {
+	spans := []HistogramSpan{{Offset: -2, Length: 3}, {Offset: 4, Length: 1}}
+	arr := SpansToInt32Array(spans)
+	require.Equal(t, []int32{-2, 3, 4, 1}, arr)
+
+	res, err := SpansFromInt32Array(arr)
+	require.NoError(t, err)
+	require.Equal(t, spans, res)
+
+	_, err = SpansFromInt32Array([]int32{1, 2, 3})
+	require.Error(t, err)
+}
@@ -0,0 +1,83 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package model
+
+import (
+	"fmt"
+
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+type promHistograms struct {
+	series     *Series
+	timestamps []int64
+	histograms []*FloatHistogram
+}
+
+// NewPromHistograms returns an insertable for the native histograms of a series. Histograms
+// are validated and converted into FloatHistogram representation before insertion.
+func NewPromHistograms(series *Series, histogramSet []prompb.Histogram) (Insertable, error) {
+	h := &promHistograms{
+		series:     series,
+		timestamps: make([]int64, len(histogramSet)),
+		histograms: make([]*FloatHistogram, len(histogramSet)),
+	}
+	for i := range histogramSet {
+		fh, err := FloatHistogramFromProto(&histogramSet[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid native histogram at timestamp %d: %w", histogramSet[i].Timestamp, err)
+		}
+		h.timestamps[i] = histogramSet[i].Timestamp
+		h.histograms[i] = fh
+	}
+	return h, nil
+}
+
+func (t *promHistograms) Series() *Series {
+	return t.series
+}
+
+func (t *promHistograms) Count() int {
+	return len(t.histograms)
+}
+
+func (t *promHistograms) MaxTs() int64 {
+	numHistograms := len(t.timestamps)
+	if numHistograms == 0 {
+		// If no histograms exist, return a -ve int, so that the stats
+		// caller does not capture this value.
+		return -1
+	}
+	return t.timestamps[numHistograms-1]
+}
+
+type histogramsIterator struct {
+	curr       int
+	total      int
+	timestamps []int64
+	data       []*FloatHistogram
+}
+
+func (i *histogramsIterator) HasNext() bool {
+	return i.curr < i.total
+}
+
+func (i *histogramsIterator) Value() (timestamp int64, h *FloatHistogram) {
+	timestamp, h = i.timestamps[i.curr], i.data[i.curr]
+	i.curr++
+	return
+}
+
+func (t *promHistograms) Iterator() Iterator {
+	return &histogramsIterator{timestamps: t.timestamps, data: t.histograms, total: len(t.histograms)}
+}
+
+func (t *promHistograms) Type() InsertableType {
+	return Histogram
+}
+
+func (t *promHistograms) IsOfType(typ InsertableType) bool {
+	return Histogram == typ
+}
@@ -11,6 +11,7 @@ type InsertableType uint8
 const (
 	Sample InsertableType = iota
 	Exemplar
+	Histogram
 )
 
 type Insertable interface {
@@ -49,3 +50,10 @@ type ExemplarsIterator interface {
 	// Value returns the current exemplar's value array, timestamp and value.
 	Value() (labels []prompb.Label, timestamp int64, value float64)
 }
+
+// HistogramsIterator iterates over native histograms.
+type HistogramsIterator interface {
+	Iterator
+	// Value returns the current histogram's timestamp and the histogram.
+	Value() (timestamp int64, h *FloatHistogram)
+}
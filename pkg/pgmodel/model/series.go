@@ -32,13 +32,15 @@ type SeriesEpoch int64
 
 // Series stores a Prometheus labels.Labels in its canonical string representation
 type Series struct {
-	//protects names, values, seriesID, epoch
+	//protects names, values, seriesID, epoch, maxTime
 	//str and metricName are immutable and doesn't need a lock
 	lock     sync.RWMutex
 	names    []string
 	values   []string
 	seriesID SeriesID
 	epoch    SeriesEpoch
+	//timestamp of the newest sample ingested into the series while it was cached
+	maxTime int64
 
 	metricName string
 	str        string
@@ -69,6 +71,23 @@ func (l *Series) NameValues() (names []string, values []string, ok bool) {
 	return l.names, l.values, !l.isSeriesIDSetNoLock()
 }
 
+//MaxTime returns the timestamp of the newest sample ingested into the series
+//since it was cached, 0 if none was
+func (l *Series) MaxTime() int64 {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.maxTime
+}
+
+//UpdateMaxTime records the timestamp of the newest sample ingested into the series
+func (l *Series) UpdateMaxTime(t int64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if t > l.maxTime {
+		l.maxTime = t
+	}
+}
+
 func (l *Series) MetricName() string {
 	return l.metricName
 }
@@ -131,3 +150,20 @@ func (l *Series) SetSeriesID(sid SeriesID, eid SeriesEpoch) {
 	l.names = nil
 	l.values = nil
 }
+
+// FormatLabels returns the label set in the Prometheus text format, for logs
+// and error messages.
+func FormatLabels(labels []prompb.Label) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(l.Name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(l.Value))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
@@ -0,0 +1,72 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package model
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// Source identifies the sender of a write request, so that duplicate samples
+// can be attributed to it. Fields are empty when unknown.
+type Source struct {
+	Tenant  string `json:"tenant"`
+	Replica string `json:"replica"`
+	Client  string `json:"client"`
+}
+
+// MultipleSources is the value of the fields of a source which differ between
+// the write requests duplicates are attributed to.
+const MultipleSources = "multiple"
+
+type sourceKey struct{}
+
+// NewSourceContext returns a context carrying the source of a write request.
+// The source is shared, so that preprocessors can complete it, e.g. with the
+// HA replica.
+func NewSourceContext(ctx context.Context, s *Source) context.Context {
+	return context.WithValue(ctx, sourceKey{}, s)
+}
+
+// SourceFromContext returns the source of the write request of ctx, or nil.
+func SourceFromContext(ctx context.Context) *Source {
+	s, _ := ctx.Value(sourceKey{}).(*Source)
+	return s
+}
+
+// SetReplicas records the HA replicas of a write request. Requests of
+// aggregating agents may mix several replicas.
+func (s *Source) SetReplicas(replicas []string) {
+	replicas = append([]string(nil), replicas...)
+	sort.Strings(replicas)
+	distinct := replicas[:0]
+	for i, r := range replicas {
+		if i == 0 || r != replicas[i-1] {
+			distinct = append(distinct, r)
+		}
+	}
+	s.Replica = strings.Join(distinct, ",")
+}
+
+// MergeSources returns the source of data coming from several write requests.
+// The fields which differ between them are set to MultipleSources.
+func MergeSources(sources []Source) Source {
+	if len(sources) == 0 {
+		return Source{}
+	}
+	merged := sources[0]
+	merge := func(field *string, value string) {
+		if *field != value {
+			*field = MultipleSources
+		}
+	}
+	for _, s := range sources[1:] {
+		merge(&merged.Tenant, s.Tenant)
+		merge(&merged.Replica, s.Replica)
+		merge(&merged.Client, s.Client)
+	}
+	return merged
+}
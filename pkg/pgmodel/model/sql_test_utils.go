@@ -414,7 +414,7 @@ func (m *MockRows) Scan(dest ...interface{}) error {
 				*d = s
 			}
 		case float64:
-			if _, ok := dest[i].(float64); !ok {
+			if _, ok := dest[i].(*float64); !ok {
 				return fmt.Errorf("wrong value type float64")
 			}
 			dv := reflect.ValueOf(dest[i])
@@ -434,6 +434,13 @@ func (m *MockRows) Scan(dest ...interface{}) error {
 			dv := reflect.ValueOf(dest[i])
 			dvp := reflect.Indirect(dv)
 			dvp.SetBool(m.results[m.idx][i].(bool))
+		case int16:
+			if _, ok := dest[i].(*int16); !ok {
+				return fmt.Errorf("wrong value type int16")
+			}
+			dv := reflect.ValueOf(dest[i])
+			dvp := reflect.Indirect(dv)
+			dvp.SetInt(int64(m.results[m.idx][i].(int16)))
 		case int32:
 			if _, ok := dest[i].(*int32); !ok {
 				return fmt.Errorf("wrong value type int32")
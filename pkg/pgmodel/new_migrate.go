@@ -149,6 +149,30 @@ func Migrate(conn *pgx.Conn, appVersion VersionInfo, leaseLock *util.PgAdvisoryL
 			return err
 		}
 	}
+	if err = applyConnectorScripts(conn); err != nil {
+		return fmt.Errorf("error applying connector owned schema: %w", err)
+	}
+	return nil
+}
+
+// applyConnectorScripts applies the idempotent scripts for database objects that are
+// managed by the connector instead of the Promscale extension.
+func applyConnectorScripts(conn *pgx.Conn) error {
+	ctx := context.Background()
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+	mig := NewMigrator(conn, migrations.MigrationFiles, TableOfContents)
+	if err = mig.execMigrationDir(tx, connectorScripts); err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("unable to commit migration transaction: %w", err)
+	}
 	return nil
 }
 
@@ -0,0 +1,250 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+// Package clauses builds the SQL clauses selecting the series which match
+// PromQL label matchers.
+package clauses
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grafana/regexp"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/timescale/promscale/pkg/pgmodel/common/errors"
+	pgmodel "github.com/timescale/promscale/pkg/pgmodel/model"
+)
+
+const (
+	subQueryEQ            = "labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value = $%d)"
+	subQueryEQMatchEmpty  = "NOT labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value != $%d)"
+	subQueryNEQ           = "labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value != $%d)"
+	subQueryNEQMatchEmpty = "NOT labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value = $%d)"
+	subQueryRE            = "labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value ~ $%d)"
+	subQueryREMatchEmpty  = "NOT labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value !~ $%d)"
+	subQueryNRE           = "labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value !~ $%d)"
+	subQueryNREMatchEmpty = "NOT labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value ~ $%d)"
+
+	// RE2 regex matching sub-queries using custom regex matching function.
+	// TODO: we might want to reduce the complexity in the future by using re2_match function for all regex matching.
+	subQueryRE2            = "labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and _prom_ext.re2_match(l.value, $%d))"
+	subQueryRE2MatchEmpty  = "NOT labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and not _prom_ext.re2_match(l.value, $%d))"
+	subQueryNRE2           = "labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and not _prom_ext.re2_match(l.value, $%d))"
+	subQueryNRE2MatchEmpty = "NOT labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and _prom_ext.re2_match(l.value, $%d))"
+)
+
+// DefaultColumnName is the column of the samples of a metric.
+const DefaultColumnName = "value"
+
+// Regex used to try to detect any non-POSIX regex features that should
+// be treated as RE2 regexes.
+var re2Regex = regexp.MustCompile(`\(\?`)
+
+// SetParameterNumbers, given a clause with %d placeholder for parameter numbers,
+// and the existing and new parameters, returns a clause with the parameters set
+// to the appropriate $index and the full set of parameter values
+func SetParameterNumbers(clause string, existingArgs []interface{}, newArgs ...interface{}) (string, []interface{}, error) {
+	argIndex := len(existingArgs) + 1
+	argCountInClause := strings.Count(clause, "%d")
+
+	if argCountInClause != len(newArgs) {
+		return "", nil, fmt.Errorf("invalid number of args: in sql %d vs args %d", argCountInClause, len(newArgs))
+	}
+
+	argIndexes := make([]interface{}, 0, argCountInClause)
+
+	for argCountInClause > 0 {
+		argIndexes = append(argIndexes, argIndex)
+		argIndex++
+		argCountInClause--
+	}
+
+	newSQL := fmt.Sprintf(clause, argIndexes...)
+	resArgs := append(existingArgs, newArgs...)
+	return newSQL, resArgs, nil
+}
+
+// Builder accumulates the clauses of the label matchers of a selector.
+type Builder struct {
+	schemaName    string
+	metricName    string
+	columnName    string
+	contradiction bool
+	clauses       []string
+	args          []interface{}
+}
+
+func (c *Builder) SetMetricName(name string) {
+	if c.metricName == "" {
+		c.metricName = name
+		return
+	}
+
+	/* Impossible to have 2 different metric names at same time */
+	if c.metricName != name {
+		c.contradiction = true
+	}
+}
+
+func (c *Builder) GetMetricName() string {
+	return c.metricName
+}
+
+func (c *Builder) SetSchemaName(name string) {
+	if c.schemaName == "" {
+		c.schemaName = name
+		return
+	}
+
+	/* Impossible to have 2 different schema names at same time */
+	if c.schemaName != name {
+		c.contradiction = true
+	}
+}
+
+func (c *Builder) GetSchemaName() string {
+	return c.schemaName
+}
+
+func (c *Builder) SetColumnName(name string) {
+	if c.columnName == "" {
+		c.columnName = name
+		return
+	}
+
+	/* Impossible to have 2 different column names at same time */
+	if c.columnName != name {
+		c.contradiction = true
+	}
+}
+
+func (c *Builder) GetColumnName() string {
+	if c.columnName == "" {
+		return DefaultColumnName
+	}
+	return c.columnName
+}
+
+func (c *Builder) addClause(clause string, args ...interface{}) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case pgmodel.SchemaNameLabelName:
+			return fmt.Errorf("__schema__ label matcher only supports equals matcher")
+		case pgmodel.ColumnNameLabelName:
+			return fmt.Errorf("__column__ label matcher only supports equals matcher")
+		}
+	}
+	clauseWithParameters, newArgs, err := SetParameterNumbers(clause, c.args, args...)
+	if err != nil {
+		return err
+	}
+
+	c.clauses = append(c.clauses, clauseWithParameters)
+	c.args = newArgs
+	return nil
+}
+
+func (c *Builder) Build(includeMetricName bool) ([]string, []interface{}, error) {
+	if c.contradiction {
+		return []string{"FALSE"}, nil, nil
+	}
+
+	/* no support for queries across all data */
+	if len(c.clauses) == 0 && c.metricName == "" {
+		return nil, nil, errors.ErrNoClausesGen
+	}
+
+	if includeMetricName && c.metricName != "" {
+		nameClause, newArgs, err := SetParameterNumbers(subQueryEQ, c.args, pgmodel.MetricNameLabelName, c.metricName)
+		if err != nil {
+			return nil, nil, err
+		}
+		return append(c.clauses, nameClause), newArgs, err
+	}
+
+	if len(c.clauses) == 0 {
+		return []string{"TRUE"}, nil, nil
+	}
+	return c.clauses, c.args, nil
+}
+
+// BuildSubQueries builds the clauses of the label matchers, except those on the
+// metric name, schema and column which are kept apart.
+func BuildSubQueries(matchers []*labels.Matcher) (*Builder, error) {
+	var err error
+	cb := &Builder{}
+
+	for _, m := range matchers {
+		// From the PromQL docs: "Label matchers that match
+		// empty label values also select all time series that
+		// do not have the specific label set at all."
+		matchesEmpty := m.Matches("")
+
+		switch m.Type {
+		case labels.MatchEqual:
+			switch m.Name {
+			case pgmodel.MetricNameLabelName:
+				cb.SetMetricName(m.Value)
+			case pgmodel.SchemaNameLabelName:
+				cb.SetSchemaName(m.Value)
+			case pgmodel.ColumnNameLabelName:
+				cb.SetColumnName(m.Value)
+			default:
+				sq := subQueryEQ
+				if matchesEmpty {
+					sq = subQueryEQMatchEmpty
+				}
+				err = cb.addClause(sq, m.Name, m.Value)
+			}
+		case labels.MatchNotEqual:
+			sq := subQueryNEQ
+			if matchesEmpty {
+				sq = subQueryNEQMatchEmpty
+			}
+			err = cb.addClause(sq, m.Name, m.Value)
+		case labels.MatchRegexp:
+			re2 := re2Regex.MatchString(m.Value)
+			sq := subQueryRE
+			switch {
+			case !re2 && !matchesEmpty:
+				sq = subQueryRE
+			case !re2 && matchesEmpty:
+				sq = subQueryREMatchEmpty
+			case re2 && matchesEmpty:
+				sq = subQueryRE2MatchEmpty
+			case re2 && !matchesEmpty:
+				sq = subQueryRE2
+			}
+			err = cb.addClause(sq, m.Name, anchorValue(m.Value))
+		case labels.MatchNotRegexp:
+			re2 := re2Regex.MatchString(m.Value)
+			sq := subQueryNRE
+			switch {
+			case !re2 && !matchesEmpty:
+				sq = subQueryNRE
+			case !re2 && matchesEmpty:
+				sq = subQueryNREMatchEmpty
+			case re2 && matchesEmpty:
+				sq = subQueryNRE2MatchEmpty
+			case re2 && !matchesEmpty:
+				sq = subQueryNRE2
+			}
+			err = cb.addClause(sq, m.Name, anchorValue(m.Value))
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cb, err
+}
+
+// anchorValue adds anchors to values in regexps since PromQL docs
+// states that "Regex-matches are fully anchored."
+func anchorValue(str string) string {
+	//Reference:  NewFastRegexMatcher in Prometheus source code
+	return "^(?:" + str + ")$"
+}
@@ -1,11 +1,13 @@
 package querier
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/storage"
+	"github.com/timescale/promscale/pkg/pgmodel/querier/clauses"
 )
 
 // promqlMetadata is metadata received directly from our native PromQL engine.
@@ -30,6 +32,10 @@ type timeFilter struct {
 	seriesTable string
 	start       string
 	end         string
+	// Set when the samples are read from a rollup, see routeToRollup.
+	rollupSchema string
+	rollupUntil  string
+	rollupColumn string
 }
 
 type evalMetadata struct {
@@ -47,13 +53,13 @@ func GetMetadata(clauses []string, values []interface{}) *evalMetadata {
 }
 
 // getEvaluationMetadata gives the metadata that will be required in evaluating a query.
-func getEvaluationMetadata(tools *queryTools, start, end int64, promMetadata *promqlMetadata) (*evalMetadata, error) {
+func getEvaluationMetadata(ctx context.Context, tools *queryTools, start, end int64, promMetadata *promqlMetadata) (*evalMetadata, error) {
 	matchers := promMetadata.matchers
 	if tools.rAuth != nil {
-		matchers = tools.rAuth.AppendTenantMatcher(matchers)
+		matchers = tools.rAuth.AppendTenantMatcher(ctx, matchers)
 	}
 	// Build a subquery per metric matcher.
-	builder, err := BuildSubQueries(matchers)
+	builder, err := clauses.BuildSubQueries(matchers)
 	if err != nil {
 		return nil, fmt.Errorf("build subQueries: %w", err)
 	}
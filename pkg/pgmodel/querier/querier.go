@@ -11,6 +11,7 @@ import (
 	"github.com/prometheus/prometheus/storage"
 	"github.com/timescale/promscale/pkg/pgmodel/cache"
 	"github.com/timescale/promscale/pkg/pgmodel/lreader"
+	"github.com/timescale/promscale/pkg/pgmodel/rollup"
 	"github.com/timescale/promscale/pkg/pgxconn"
 	"github.com/timescale/promscale/pkg/tenancy"
 )
@@ -23,12 +24,14 @@ var _ Querier = (*pgxQuerier)(nil)
 
 // NewQuerier returns a new pgxQuerier that reads from PostgreSQL using PGX
 // and caches metric table names and label sets using the supplied caches.
+// Range queries are routed to the rollups selected by rollups, unless it is nil.
 func NewQuerier(
 	conn pgxconn.PgxConn,
 	metricCache cache.MetricCache,
 	labelsReader lreader.LabelsReader,
 	exemplarCache cache.PositionCache,
 	rAuth tenancy.ReadAuthorizer,
+	rollups *rollup.Router,
 ) Querier {
 	querier := &pgxQuerier{
 		tools: &queryTools{
@@ -37,6 +40,7 @@ func NewQuerier(
 			metricTableNames: metricCache,
 			exemplarPosCache: exemplarCache,
 			rAuth:            rAuth,
+			rollups:          rollups,
 		},
 	}
 	return querier
@@ -148,7 +148,23 @@ func TestPGXQuerierQuery(t *testing.T) {
 						"GROUP BY s.id",
 					Args:    []interface{}(nil),
 					Results: model.RowResults(nil),
-					Err:     fmt.Errorf("some error 3")}},
+					Err:     fmt.Errorf("some error 3")},
+				{
+					Sql: `SELECT series.labels, h.time, h.count, h.sum, h.schema, h.zero_threshold, h.zero_count,
+					h.positive_spans, h.positive_buckets, h.negative_spans, h.negative_buckets, h.reset_hint
+					FROM "prom_data_histogram"."sample" h
+					INNER JOIN "prom_data_series"."foo" series
+					ON h.series_id = series.id
+					WHERE h.metric_id = 1
+					AND h.time >= '1970-01-01T00:00:01Z'
+					AND h.time <= '1970-01-01T00:00:02Z'
+					AND h.series_id IN (1)
+					ORDER BY h.series_id, h.time`,
+					Args:    []interface{}(nil),
+					Results: model.RowResults{},
+					Err:     error(nil),
+				},
+			},
 		},
 		{
 			name: "Error scan values",
@@ -270,6 +286,21 @@ func TestPGXQuerierQuery(t *testing.T) {
 					Results: model.RowResults{{[]int64{1}, []time.Time{time.Unix(0, 0)}, []float64{1}}},
 					Err:     error(nil),
 				},
+				{
+					Sql: `SELECT series.labels, h.time, h.count, h.sum, h.schema, h.zero_threshold, h.zero_count,
+					h.positive_spans, h.positive_buckets, h.negative_spans, h.negative_buckets, h.reset_hint
+					FROM "prom_data_histogram"."sample" h
+					INNER JOIN "prom_data_series"."foo" series
+					ON h.series_id = series.id
+					WHERE h.metric_id = 1
+					AND h.time >= '1970-01-01T00:00:01Z'
+					AND h.time <= '1970-01-01T00:00:02Z'
+					AND h.series_id IN (1)
+					ORDER BY h.series_id, h.time`,
+					Args:    []interface{}(nil),
+					Results: model.RowResults{},
+					Err:     error(nil),
+				},
 				{
 					Sql:     "SELECT (prom_api.labels_info($1::int[])).*",
 					Args:    []interface{}{[]int64{1}},
@@ -314,6 +345,94 @@ func TestPGXQuerierQuery(t *testing.T) {
 					Results: model.RowResults{{[]int64{2}, []time.Time{time.Unix(0, 0)}, []float64{1}}},
 					Err:     error(nil),
 				},
+				{
+					Sql: `SELECT series.labels, h.time, h.count, h.sum, h.schema, h.zero_threshold, h.zero_count,
+					h.positive_spans, h.positive_buckets, h.negative_spans, h.negative_buckets, h.reset_hint
+					FROM "prom_data_histogram"."sample" h
+					INNER JOIN "prom_data_series"."bar" series
+					ON h.series_id = series.id
+					WHERE h.metric_id = 1
+					AND h.time >= '1970-01-01T00:00:01Z'
+					AND h.time <= '1970-01-01T00:00:02Z'
+					AND TRUE
+					ORDER BY h.series_id, h.time`,
+					Args:    nil,
+					Results: model.RowResults{},
+					Err:     error(nil),
+				},
+				{
+					Sql:     "SELECT (prom_api.labels_info($1::int[])).*",
+					Args:    []interface{}{[]int64{2}},
+					Results: model.RowResults{{[]int64{2}, []string{"__name__"}, []string{"bar"}}},
+					Err:     error(nil),
+				},
+			},
+		},
+		{
+			name: "Simple query, native histograms",
+			query: &prompb.Query{
+				StartTimestampMs: 1000,
+				EndTimestampMs:   2000,
+				Matchers: []*prompb.LabelMatcher{
+					{Type: prompb.LabelMatcher_EQ, Name: model.MetricNameLabelName, Value: "bar"},
+				},
+			},
+			result: []*prompb.TimeSeries{
+				{
+					Labels: []prompb.Label{{Name: model.MetricNameLabelName, Value: "bar"}},
+					Histograms: []prompb.Histogram{
+						{
+							Count:          &prompb.Histogram_CountFloat{CountFloat: 5},
+							Sum:            12,
+							Schema:         1,
+							ZeroThreshold:  0.001,
+							ZeroCount:      &prompb.Histogram_ZeroCountFloat{ZeroCountFloat: 1},
+							PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: 2}},
+							PositiveCounts: []float64{3, 1},
+							Timestamp:      1500,
+						},
+					},
+				},
+			},
+			sqlQueries: []model.SqlQuery{
+				{
+					Sql:     "SELECT id, table_schema, table_name, series_table FROM _prom_catalog.get_metric_table_name_if_exists($1, $2)",
+					Args:    []interface{}{"", "bar"},
+					Results: model.RowResults{{int64(7), "prom_data", "bar", "bar"}},
+					Err:     error(nil),
+				},
+				{
+					Sql: `SELECT series.labels, result.time_array, result.value_array
+					FROM "prom_data_series"."bar" series
+					INNER JOIN (
+						SELECT series_id, array_agg(time) as time_array, array_agg(value) as value_array
+						FROM ( SELECT series_id, time, "value" as value FROM "prom_data"."bar" metric
+						WHERE time >= '1970-01-01T00:00:01Z' AND time <= '1970-01-01T00:00:02Z'
+						ORDER BY series_id, time ) as time_ordered_rows
+						GROUP BY series_id
+						) as result ON (result.value_array is not null AND result.series_id = series.id)`,
+					Args:    nil,
+					Results: model.RowResults{},
+					Err:     error(nil),
+				},
+				{
+					Sql: `SELECT series.labels, h.time, h.count, h.sum, h.schema, h.zero_threshold, h.zero_count,
+					h.positive_spans, h.positive_buckets, h.negative_spans, h.negative_buckets, h.reset_hint
+					FROM "prom_data_histogram"."sample" h
+					INNER JOIN "prom_data_series"."bar" series
+					ON h.series_id = series.id
+					WHERE h.metric_id = 7
+					AND h.time >= '1970-01-01T00:00:01Z'
+					AND h.time <= '1970-01-01T00:00:02Z'
+					AND TRUE
+					ORDER BY h.series_id, h.time`,
+					Args: nil,
+					Results: model.RowResults{{
+						[]int64{2}, time.Unix(1, 500000000), float64(5), float64(12), int32(1), float64(0.001), float64(1),
+						[]int32{0, 2}, []float64{3, 1}, []int32{}, []float64{}, int16(0),
+					}},
+					Err: error(nil),
+				},
 				{
 					Sql:     "SELECT (prom_api.labels_info($1::int[])).*",
 					Args:    []interface{}{[]int64{2}},
@@ -474,6 +593,21 @@ func TestPGXQuerierQuery(t *testing.T) {
 					Results: model.RowResults{{[]int64{3}, []time.Time{time.Unix(0, 0)}, []float64{1}}},
 					Err:     error(nil),
 				},
+				{
+					Sql: `SELECT series.labels, h.time, h.count, h.sum, h.schema, h.zero_threshold, h.zero_count,
+					h.positive_spans, h.positive_buckets, h.negative_spans, h.negative_buckets, h.reset_hint
+					FROM "prom_data_histogram"."sample" h
+					INNER JOIN "prom_data_series"."foo" series
+					ON h.series_id = series.id
+					WHERE h.metric_id = 1
+					AND h.time >= '1970-01-01T00:00:01Z'
+					AND h.time <= '1970-01-01T00:00:02Z'
+					AND h.series_id IN (1)
+					ORDER BY h.series_id, h.time`,
+					Args:    []interface{}(nil),
+					Results: model.RowResults{},
+					Err:     error(nil),
+				},
 				{
 					Sql: "SELECT s.labels, array_agg(m.time ORDER BY time), array_agg(m.value ORDER BY time)\n\t" +
 						"FROM \"prom_data\".\"bar\" m\n\t" +
@@ -487,6 +621,21 @@ func TestPGXQuerierQuery(t *testing.T) {
 					Results: model.RowResults{{[]int64{4}, []time.Time{time.Unix(0, 0)}, []float64{1}}},
 					Err:     error(nil),
 				},
+				{
+					Sql: `SELECT series.labels, h.time, h.count, h.sum, h.schema, h.zero_threshold, h.zero_count,
+					h.positive_spans, h.positive_buckets, h.negative_spans, h.negative_buckets, h.reset_hint
+					FROM "prom_data_histogram"."sample" h
+					INNER JOIN "prom_data_series"."bar" series
+					ON h.series_id = series.id
+					WHERE h.metric_id = 1
+					AND h.time >= '1970-01-01T00:00:01Z'
+					AND h.time <= '1970-01-01T00:00:02Z'
+					AND h.series_id IN (1)
+					ORDER BY h.series_id, h.time`,
+					Args:    []interface{}(nil),
+					Results: model.RowResults{},
+					Err:     error(nil),
+				},
 				{
 					Sql:           "SELECT (prom_api.labels_info($1::int[])).*",
 					Args:          []interface{}{[]int64{3, 4}},
@@ -523,6 +672,21 @@ func TestPGXQuerierQuery(t *testing.T) {
 					Results: model.RowResults{},
 					Err:     error(nil),
 				},
+				{
+					Sql: `SELECT series.labels, h.time, h.count, h.sum, h.schema, h.zero_threshold, h.zero_count,
+					h.positive_spans, h.positive_buckets, h.negative_spans, h.negative_buckets, h.reset_hint
+					FROM "prom_data_histogram"."sample" h
+					INNER JOIN "prom_data_series"."foo" series
+					ON h.series_id = series.id
+					WHERE h.metric_id = 1
+					AND h.time >= '1970-01-01T00:00:01Z'
+					AND h.time <= '1970-01-01T00:00:02Z'
+					AND FALSE
+					ORDER BY h.series_id, h.time`,
+					Args:    []interface{}(nil),
+					Results: model.RowResults{},
+					Err:     error(nil),
+				},
 			},
 		},
 		{
@@ -572,6 +736,106 @@ func TestPGXQuerierQuery(t *testing.T) {
 					Results: model.RowResults{{[]int64{7}, []time.Time{time.Unix(0, 0)}, []float64{1}}},
 					Err:     error(nil),
 				},
+				{
+					Sql: `SELECT series.labels, h.time, h.count, h.sum, h.schema, h.zero_threshold, h.zero_count,
+					h.positive_spans, h.positive_buckets, h.negative_spans, h.negative_buckets, h.reset_hint
+					FROM "prom_data_histogram"."sample" h
+					INNER JOIN "prom_data_series"."metric" series
+					ON h.series_id = series.id
+					WHERE h.metric_id = 1
+					AND h.time >= '1970-01-01T00:00:01Z'
+					AND h.time <= '1970-01-01T00:00:02Z'
+					AND h.series_id IN (1,99,98)
+					ORDER BY h.series_id, h.time`,
+					Args:    []interface{}(nil),
+					Results: model.RowResults{},
+					Err:     error(nil),
+				},
+				{
+					Sql:     "SELECT (prom_api.labels_info($1::int[])).*",
+					Args:    []interface{}{[]int64{7}},
+					Results: model.RowResults{{[]int64{7}, []string{"foo"}, []string{"bar"}}},
+					Err:     error(nil),
+				},
+			},
+		},
+		{
+			name: "Simple query, no metric name matcher, float samples and native histograms",
+			query: &prompb.Query{
+				StartTimestampMs: 1000,
+				EndTimestampMs:   2000,
+				Matchers: []*prompb.LabelMatcher{
+					{Type: prompb.LabelMatcher_EQ, Name: "foo", Value: "bar"},
+				},
+			},
+			result: []*prompb.TimeSeries{
+				{
+					Labels:  []prompb.Label{{Name: "foo", Value: "bar"}},
+					Samples: []prompb.Sample{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 2}},
+					Histograms: []prompb.Histogram{
+						{
+							Count:          &prompb.Histogram_CountFloat{CountFloat: 5},
+							Sum:            12,
+							Schema:         1,
+							ZeroThreshold:  0.001,
+							ZeroCount:      &prompb.Histogram_ZeroCountFloat{ZeroCountFloat: 1},
+							PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: 2}},
+							PositiveCounts: []float64{3, 1},
+							Timestamp:      1500,
+						},
+					},
+				},
+			},
+			sqlQueries: []model.SqlQuery{
+				{
+					Sql: "SELECT m.table_schema, m.metric_name, array_agg(s.id)\n\t" +
+						"FROM _prom_catalog.series s\n\t" +
+						"INNER JOIN _prom_catalog.metric m\n\t" +
+						"ON (m.id = s.metric_id)\n\t" +
+						"WHERE labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $1 and l.value = $2)\n\t" +
+						"GROUP BY m.metric_name, m.table_schema\n\t" +
+						"ORDER BY m.metric_name, m.table_schema",
+					Args:    []interface{}{"foo", "bar"},
+					Results: model.RowResults{{"prom_data", "metric", []int64{1}}},
+					Err:     error(nil),
+				},
+				{
+					Sql:     "SELECT id, table_schema, table_name, series_table FROM _prom_catalog.get_metric_table_name_if_exists($1, $2)",
+					Args:    []interface{}{"prom_data", "metric"},
+					Results: model.RowResults{{int64(3), "prom_data", "metric", "metric"}},
+					Err:     error(nil),
+				},
+				{
+					Sql: "SELECT s.labels, array_agg(m.time ORDER BY time), array_agg(m.value ORDER BY time)\n\t" +
+						"FROM \"prom_data\".\"metric\" m\n\t" +
+						"INNER JOIN \"prom_data_series\".\"metric\" s\n\t" +
+						"ON m.series_id = s.id\n\t" +
+						"WHERE m.series_id IN (1)\n\t" +
+						"AND time >= '1970-01-01T00:00:01Z'\n\t" +
+						"AND time <= '1970-01-01T00:00:02Z'\n\t" +
+						"GROUP BY s.id",
+					Args:    []interface{}(nil),
+					Results: model.RowResults{{[]int64{7}, []time.Time{time.Unix(1, 0), time.Unix(2, 0)}, []float64{1, 2}}},
+					Err:     error(nil),
+				},
+				{
+					Sql: `SELECT series.labels, h.time, h.count, h.sum, h.schema, h.zero_threshold, h.zero_count,
+					h.positive_spans, h.positive_buckets, h.negative_spans, h.negative_buckets, h.reset_hint
+					FROM "prom_data_histogram"."sample" h
+					INNER JOIN "prom_data_series"."metric" series
+					ON h.series_id = series.id
+					WHERE h.metric_id = 3
+					AND h.time >= '1970-01-01T00:00:01Z'
+					AND h.time <= '1970-01-01T00:00:02Z'
+					AND h.series_id IN (1)
+					ORDER BY h.series_id, h.time`,
+					Args: []interface{}(nil),
+					Results: model.RowResults{{
+						[]int64{7}, time.Unix(1, 500000000), float64(5), float64(12), int32(1), float64(0.001), float64(1),
+						[]int32{0, 2}, []float64{3, 1}, []int32{}, []float64{}, int16(0),
+					}},
+					Err: error(nil),
+				},
 				{
 					Sql:     "SELECT (prom_api.labels_info($1::int[])).*",
 					Args:    []interface{}{[]int64{7}},
@@ -633,6 +897,21 @@ func TestPGXQuerierQuery(t *testing.T) {
 					Results: model.RowResults{{[]int64{8, 9}, []time.Time{time.Unix(0, 0)}, []float64{1}}},
 					Err:     error(nil),
 				},
+				{
+					Sql: `SELECT series.labels, h.time, h.count, h.sum, h.schema, h.zero_threshold, h.zero_count,
+					h.positive_spans, h.positive_buckets, h.negative_spans, h.negative_buckets, h.reset_hint
+					FROM "prom_data_histogram"."sample" h
+					INNER JOIN "prom_data_series"."metric" series
+					ON h.series_id = series.id
+					WHERE h.metric_id = 1
+					AND h.time >= '1970-01-01T00:00:01Z'
+					AND h.time <= '1970-01-01T00:00:02Z'
+					AND h.series_id IN (1,4,5)
+					ORDER BY h.series_id, h.time`,
+					Args:    []interface{}(nil),
+					Results: model.RowResults{},
+					Err:     error(nil),
+				},
 				{
 					Sql:           "SELECT (prom_api.labels_info($1::int[])).*",
 					Args:          []interface{}{[]int64{9, 8}},
@@ -694,6 +973,21 @@ func TestPGXQuerierQuery(t *testing.T) {
 					Results: model.RowResults{{[]int64{10}, []time.Time{time.Unix(0, 0)}, []float64{1}}},
 					Err:     error(nil),
 				},
+				{
+					Sql: `SELECT series.labels, h.time, h.count, h.sum, h.schema, h.zero_threshold, h.zero_count,
+					h.positive_spans, h.positive_buckets, h.negative_spans, h.negative_buckets, h.reset_hint
+					FROM "prom_data_histogram"."sample" h
+					INNER JOIN "prom_data_series"."metric" series
+					ON h.series_id = series.id
+					WHERE h.metric_id = 1
+					AND h.time >= '1970-01-01T00:00:01Z'
+					AND h.time <= '1970-01-01T00:00:02Z'
+					AND h.series_id IN (1,2)
+					ORDER BY h.series_id, h.time`,
+					Args:    []interface{}(nil),
+					Results: model.RowResults{},
+					Err:     error(nil),
+				},
 				{
 					Sql:     "SELECT (prom_api.labels_info($1::int[])).*",
 					Args:    []interface{}{[]int64{10}},
@@ -11,7 +11,6 @@ import (
 	"time"
 
 	"github.com/blang/semver/v4"
-	"github.com/grafana/regexp"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/timestamp"
@@ -25,103 +24,11 @@ import (
 	"github.com/timescale/promscale/pkg/prompb"
 )
 
-const (
-	subQueryEQ            = "labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value = $%d)"
-	subQueryEQMatchEmpty  = "NOT labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value != $%d)"
-	subQueryNEQ           = "labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value != $%d)"
-	subQueryNEQMatchEmpty = "NOT labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value = $%d)"
-	subQueryRE            = "labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value ~ $%d)"
-	subQueryREMatchEmpty  = "NOT labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value !~ $%d)"
-	subQueryNRE           = "labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value !~ $%d)"
-	subQueryNREMatchEmpty = "NOT labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and l.value ~ $%d)"
-
-	// RE2 regex matching sub-queries using custom regex matching function.
-	// TODO: we might want to reduce the complexity in the future by using re2_match function for all regex matching.
-	subQueryRE2            = "labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and _prom_ext.re2_match(l.value, $%d))"
-	subQueryRE2MatchEmpty  = "NOT labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and not _prom_ext.re2_match(l.value, $%d))"
-	subQueryNRE2           = "labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and not _prom_ext.re2_match(l.value, $%d))"
-	subQueryNRE2MatchEmpty = "NOT labels && (SELECT COALESCE(array_agg(l.id), array[]::int[]) FROM _prom_catalog.label l WHERE l.key = $%d and _prom_ext.re2_match(l.value, $%d))"
-)
-
 var (
 	minTime = timestamp.FromTime(time.Unix(math.MinInt64/1000+62135596801, 0).UTC())
 	maxTime = timestamp.FromTime(time.Unix(math.MaxInt64/1000-62135596801, 999999999).UTC())
-
-	// Regex used to try to detect any non-POSIX regex features that should
-	// be treated as RE2 regexes.
-	re2Regex = regexp.MustCompile(`\(\?`)
 )
 
-func BuildSubQueries(matchers []*labels.Matcher) (*clauseBuilder, error) {
-	var err error
-	cb := &clauseBuilder{}
-
-	for _, m := range matchers {
-		// From the PromQL docs: "Label matchers that match
-		// empty label values also select all time series that
-		// do not have the specific label set at all."
-		matchesEmpty := m.Matches("")
-
-		switch m.Type {
-		case labels.MatchEqual:
-			switch m.Name {
-			case pgmodel.MetricNameLabelName:
-				cb.SetMetricName(m.Value)
-			case pgmodel.SchemaNameLabelName:
-				cb.SetSchemaName(m.Value)
-			case pgmodel.ColumnNameLabelName:
-				cb.SetColumnName(m.Value)
-			default:
-				sq := subQueryEQ
-				if matchesEmpty {
-					sq = subQueryEQMatchEmpty
-				}
-				err = cb.addClause(sq, m.Name, m.Value)
-			}
-		case labels.MatchNotEqual:
-			sq := subQueryNEQ
-			if matchesEmpty {
-				sq = subQueryNEQMatchEmpty
-			}
-			err = cb.addClause(sq, m.Name, m.Value)
-		case labels.MatchRegexp:
-			re2 := re2Regex.MatchString(m.Value)
-			sq := subQueryRE
-			switch {
-			case !re2 && !matchesEmpty:
-				sq = subQueryRE
-			case !re2 && matchesEmpty:
-				sq = subQueryREMatchEmpty
-			case re2 && matchesEmpty:
-				sq = subQueryRE2MatchEmpty
-			case re2 && !matchesEmpty:
-				sq = subQueryRE2
-			}
-			err = cb.addClause(sq, m.Name, anchorValue(m.Value))
-		case labels.MatchNotRegexp:
-			re2 := re2Regex.MatchString(m.Value)
-			sq := subQueryNRE
-			switch {
-			case !re2 && !matchesEmpty:
-				sq = subQueryNRE
-			case !re2 && matchesEmpty:
-				sq = subQueryNREMatchEmpty
-			case re2 && matchesEmpty:
-				sq = subQueryNRE2MatchEmpty
-			case re2 && !matchesEmpty:
-				sq = subQueryNRE2
-			}
-			err = cb.addClause(sq, m.Name, anchorValue(m.Value))
-		}
-
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	return cb, err
-}
-
 func initLabelIdIndexForSamples(index map[int64]labels.Label, rows []sampleRow) {
 	for i := range rows {
 		for _, id := range rows[i].labelIds {
@@ -185,8 +92,11 @@ func buildTimeSeries(rows []sampleRow, lr lreader.LabelsReader) ([]*prompb.TimeS
 		})
 
 		result := &prompb.TimeSeries{
-			Labels:  promLabels,
-			Samples: make([]prompb.Sample, 0, row.times.Len()),
+			Labels: promLabels,
+		}
+
+		if row.histograms == nil {
+			result.Samples = make([]prompb.Sample, 0, row.times.Len())
 		}
 
 		for i := 0; i < row.times.Len(); i++ {
@@ -194,6 +104,12 @@ func buildTimeSeries(rows []sampleRow, lr lreader.LabelsReader) ([]*prompb.TimeS
 			if !ok {
 				return nil, fmt.Errorf("invalid timestamp found")
 			}
+			// Rows of series holding both float samples and native histograms
+			// have no histogram at the timestamps of the float samples.
+			if row.histograms != nil && row.histograms[i] != nil {
+				result.Histograms = append(result.Histograms, row.histograms[i].ToProto(ts))
+				continue
+			}
 			result.Samples = append(result.Samples, prompb.Sample{
 				Timestamp: ts,
 				Value:     row.values.Elements[i].Float,
@@ -428,13 +344,6 @@ func buildVectorSelectorFunctionCallAggregator(lookback int64, selectHints *stor
 	return &qf
 }
 
-// anchorValue adds anchors to values in regexps since PromQL docs
-// states that "Regex-matches are fully anchored."
-func anchorValue(str string) string {
-	//Reference:  NewFastRegexMatcher in Prometheus source code
-	return "^(?:" + str + ")$"
-}
-
 func toRFC3339Nano(milliseconds int64) string {
 	if milliseconds == minTime {
 		return "-Infinity"
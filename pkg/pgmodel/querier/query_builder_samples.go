@@ -8,6 +8,7 @@ import (
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
 	pgmodel "github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/pgmodel/querier/clauses"
 )
 
 const (
@@ -98,7 +99,7 @@ const (
 		GROUP BY series_id
 	) as result ON (result.value_array is not null AND result.series_id = series.id)`
 
-	defaultColumnName = "value"
+	defaultColumnName = clauses.DefaultColumnName
 )
 
 // buildSingleMetricSamplesQuery builds a SQL query which fetches the data for
@@ -135,14 +136,14 @@ func buildSingleMetricSamplesQuery(metadata *evalMetadata) (string, []interface{
 	if qf.timeClause != "" {
 		var timeClauseBound string
 		var err error
-		timeClauseBound, values, err = setParameterNumbers(qf.timeClause, values, qf.timeParams...)
+		timeClauseBound, values, err = clauses.SetParameterNumbers(qf.timeClause, values, qf.timeParams...)
 		if err != nil {
 			return "", nil, nil, nil, err
 		}
 		selectors = append(selectors, "result.time_array")
 		selectorClauses = append(selectorClauses, timeClauseBound+" as time_array")
 	}
-	valueClauseBound, values, err := setParameterNumbers(qf.valueClause, values, qf.valueParams...)
+	valueClauseBound, values, err := clauses.SetParameterNumbers(qf.valueClause, values, qf.valueParams...)
 	if err != nil {
 		return "", nil, nil, nil, err
 	}
@@ -214,7 +215,7 @@ func buildSingleMetricSamplesQuery(metadata *evalMetadata) (string, []interface{
 	}
 
 	finalSQL := fmt.Sprintf(template,
-		pgx.Identifier{filter.schema, filter.metric}.Sanitize(),
+		metricSource(filter),
 		pgx.Identifier{schema.PromDataSeries, filter.seriesTable}.Sanitize(),
 		strings.Join(cases, " AND "),
 		start,
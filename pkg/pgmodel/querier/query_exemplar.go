@@ -33,7 +33,7 @@ func (q *queryExemplars) Select(start, end time.Time, matchersList ...[]*labels.
 			continue
 		}
 		evaluatedMatchers[matcherStr] = struct{}{}
-		metadata, err := getEvaluationMetadata(q.tools, timestamp.FromTime(start), timestamp.FromTime(end), GetPromQLMetadata(matchers, nil, nil, nil))
+		metadata, err := getEvaluationMetadata(q.ctx, q.tools, timestamp.FromTime(start), timestamp.FromTime(end), GetPromQLMetadata(matchers, nil, nil, nil))
 		if err != nil {
 			return nil, fmt.Errorf("get evaluation metadata: %w", err)
 		}
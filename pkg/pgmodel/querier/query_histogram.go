@@ -0,0 +1,257 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package querier
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+// Native histograms of all metrics are stored in a single table, hence the rows
+// are filtered by the metric id and joined with the series table of the metric.
+// Histograms are not aggregated into arrays, so the rows are ordered by series,
+// then time, and grouped into series while scanning.
+const histogramsByMetricSQLFormat = `SELECT series.labels, h.time, h.count, h.sum, h.schema, h.zero_threshold, h.zero_count,
+	h.positive_spans, h.positive_buckets, h.negative_spans, h.negative_buckets, h.reset_hint
+	FROM %[1]s h
+	INNER JOIN %[2]s series
+	ON h.series_id = series.id
+	WHERE h.metric_id = %[3]d
+	AND h.time >= '%[4]s'
+	AND h.time <= '%[5]s'
+	AND %[6]s
+	ORDER BY h.series_id, h.time`
+
+// canHaveHistograms returns true if the data being queried can contain native histograms.
+// Histograms are only stored for raw metrics in the default data schema, hence custom
+// metric views and columns never contain them.
+func canHaveHistograms(metadata *evalMetadata) bool {
+	filter := metadata.timeFilter
+	return (filter.schema == "" || filter.schema == schema.PromData) &&
+		(filter.column == "" || filter.column == defaultColumnName) &&
+		filter.metric == filter.seriesTable
+}
+
+func buildSingleMetricHistogramsQuery(metadata *evalMetadata, metricID int64) string {
+	filter := metadata.timeFilter
+	start, end := filter.start, filter.end
+	if sh := metadata.selectHints; sh != nil {
+		start, end = toRFC3339Nano(sh.Start), toRFC3339Nano(sh.End)
+	}
+	return fmt.Sprintf(histogramsByMetricSQLFormat,
+		pgx.Identifier{schema.PromDataHistogram, schema.HistogramTable}.Sanitize(),
+		pgx.Identifier{schema.PromDataSeries, filter.seriesTable}.Sanitize(),
+		metricID,
+		start,
+		end,
+		strings.Join(metadata.clauses, " AND "),
+	)
+}
+
+// buildMultipleMetricHistogramsQuery returns the query of the native histograms of the given
+// series of a metric, for the multiple metric path.
+func buildMultipleMetricHistogramsQuery(filter timeFilter, metricID int64, series []model.SeriesID) string {
+	s := make([]string, len(series))
+	for i, sID := range series {
+		s[i] = fmt.Sprintf("%d", sID)
+	}
+	return fmt.Sprintf(histogramsByMetricSQLFormat,
+		pgx.Identifier{schema.PromDataHistogram, schema.HistogramTable}.Sanitize(),
+		pgx.Identifier{schema.PromDataSeries, filter.seriesTable}.Sanitize(),
+		metricID,
+		filter.start,
+		filter.end,
+		fmt.Sprintf("h.series_id IN (%s)", strings.Join(s, ",")),
+	)
+}
+
+// fetchSingleMetricHistograms returns the native histograms of a single metric as result rows.
+func fetchSingleMetricHistograms(ctx context.Context, tools *queryTools, metadata *evalMetadata, metricID int64) ([]sampleRow, error) {
+	rows, err := tools.conn.Query(ctx, buildSingleMetricHistogramsQuery(metadata, metricID), metadata.values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return appendHistogramRows(nil, rows)
+}
+
+// appendHistogramRows adds the native histograms of the rows, grouped by series, to the
+// result rows. The value of each row is the count of observations of the histogram, which
+// makes the rows usable by callers that are unaware of histograms.
+func appendHistogramRows(results []sampleRow, rows pgxconn.PgxRows) ([]sampleRow, error) {
+	var (
+		current *histogramRowBuilder
+		err     error
+	)
+	for rows.Next() {
+		var (
+			labelIds                     []int64
+			h                            model.FloatHistogram
+			t                            time.Time
+			resetHint                    int16
+			positiveSpans, negativeSpans []int32
+		)
+		err = rows.Scan(&labelIds, &t, &h.Count, &h.Sum, &h.Schema, &h.ZeroThreshold, &h.ZeroCount,
+			&positiveSpans, &h.PositiveBuckets, &negativeSpans, &h.NegativeBuckets, &resetHint)
+		if err != nil {
+			return nil, fmt.Errorf("scanning histogram row: %w", err)
+		}
+		if h.PositiveSpans, err = model.SpansFromInt32Array(positiveSpans); err != nil {
+			return nil, err
+		}
+		if h.NegativeSpans, err = model.SpansFromInt32Array(negativeSpans); err != nil {
+			return nil, err
+		}
+		h.ResetHint = model.HistogramResetHint(resetHint)
+		if len(h.PositiveBuckets) == 0 {
+			h.PositiveBuckets = nil
+		}
+		if len(h.NegativeBuckets) == 0 {
+			h.NegativeBuckets = nil
+		}
+
+		if current == nil || !sameLabelIds(current.labelIds, labelIds) {
+			if current != nil {
+				results = append(results, current.build())
+			}
+			current = &histogramRowBuilder{labelIds: labelIds}
+		}
+		current.append(timestamp.FromTime(t), &h)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		results = append(results, current.build())
+	}
+	return results, nil
+}
+
+// mergeHistogramRows merges the rows of native histograms into the rows of float samples
+// of the same series, so that a series holding both is returned once. A histogram wins
+// over a float sample with the same timestamp.
+func mergeHistogramRows(samples, histograms []sampleRow) []sampleRow {
+	if len(histograms) == 0 {
+		return samples
+	}
+	bySeries := make(map[string]int, len(samples))
+	for i := range samples {
+		bySeries[labelIdsKey(samples[i].labelIds)] = i
+	}
+	for _, h := range histograms {
+		i, ok := bySeries[labelIdsKey(h.labelIds)]
+		if !ok || samples[i].err != nil {
+			samples = append(samples, h)
+			continue
+		}
+		merged := mergeRows(&samples[i], &h)
+		samples[i].Close()
+		h.Close()
+		samples[i] = merged
+	}
+	return samples
+}
+
+// mergeRows returns a row holding the float samples of f and the histograms of h, ordered
+// by time.
+func mergeRows(f, h *sampleRow) sampleRow {
+	b := &histogramRowBuilder{labelIds: f.labelIds}
+	values := fPool.Get().(*pgtype.Float8Array)
+	values.Elements = values.Elements[:0]
+	fi, hi := 0, 0
+	for fi < f.times.Len() || hi < h.times.Len() {
+		var ft int64 = math.MaxInt64
+		if fi < f.times.Len() {
+			t, ok := f.times.At(fi)
+			if !ok {
+				fi++
+				continue
+			}
+			ft = t
+		}
+		if hi < h.times.Len() {
+			if ht, _ := h.times.At(hi); ht <= ft {
+				b.append(ht, h.histograms[hi])
+				values.Elements = append(values.Elements, h.values.Elements[hi])
+				hi++
+				if ht == ft {
+					fi++
+				}
+				continue
+			}
+		}
+		b.append(ft, nil)
+		values.Elements = append(values.Elements, f.values.Elements[fi])
+		fi++
+	}
+	values.Status = pgtype.Present
+	return sampleRow{
+		labelIds:       f.labelIds,
+		times:          sliceTimestampSeries(b.times),
+		values:         values,
+		metricOverride: f.metricOverride,
+		schema:         f.schema,
+		column:         f.column,
+		histograms:     b.histograms,
+	}
+}
+
+func labelIdsKey(labelIds []int64) string {
+	var sb strings.Builder
+	for _, id := range labelIds {
+		sb.WriteString(strconv.FormatInt(id, 10))
+		sb.WriteByte(',')
+	}
+	return sb.String()
+}
+
+type histogramRowBuilder struct {
+	labelIds   []int64
+	times      []int64
+	histograms []*model.FloatHistogram
+}
+
+func (b *histogramRowBuilder) append(t int64, h *model.FloatHistogram) {
+	b.times = append(b.times, t)
+	b.histograms = append(b.histograms, h)
+}
+
+func (b *histogramRowBuilder) build() sampleRow {
+	values := fPool.Get().(*pgtype.Float8Array)
+	values.Elements = values.Elements[:0]
+	for _, h := range b.histograms {
+		values.Elements = append(values.Elements, pgtype.Float8{Float: h.Count, Status: pgtype.Present})
+	}
+	values.Status = pgtype.Present
+	return sampleRow{
+		labelIds:   b.labelIds,
+		times:      sliceTimestampSeries(b.times),
+		values:     values,
+		histograms: b.histograms,
+	}
+}
+
+func sameLabelIds(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
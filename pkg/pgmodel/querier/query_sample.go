@@ -34,7 +34,7 @@ func (q *querySamples) Select(mint, maxt int64, _ bool, hints *storage.SelectHin
 }
 
 func (q *querySamples) fetchSamplesRows(mint, maxt int64, hints *storage.SelectHints, qh *QueryHints, path []parser.Node, ms []*labels.Matcher) ([]sampleRow, parser.Node, error) {
-	metadata, err := getEvaluationMetadata(q.tools, mint, maxt, GetPromQLMetadata(ms, hints, qh, path))
+	metadata, err := getEvaluationMetadata(q.ctx, q.tools, mint, maxt, GetPromQLMetadata(ms, hints, qh, path))
 	if err != nil {
 		return nil, nil, fmt.Errorf("get evaluation metadata: %w", err)
 	}
@@ -52,12 +52,23 @@ func (q *querySamples) fetchSamplesRows(mint, maxt int64, hints *storage.SelectH
 		metadata.timeFilter.metric = mInfo.TableName
 		metadata.timeFilter.schema = mInfo.TableSchema
 		metadata.timeFilter.seriesTable = mInfo.SeriesTable
+		routeToRollup(q.ctx, q.tools, metadata)
 
 		sampleRows, topNode, err := fetchSingleMetricSamples(q.ctx, q.tools, metadata)
 		if err != nil {
 			return nil, nil, err
 		}
 
+		// Native histograms cannot be pushed down, so they are only fetched
+		// when the raw samples were requested.
+		if topNode == nil && canHaveHistograms(metadata) {
+			histogramRows, err := fetchSingleMetricHistograms(q.ctx, q.tools, metadata, mInfo.MetricID)
+			if err != nil {
+				return nil, nil, fmt.Errorf("fetching native histograms: %w", err)
+			}
+			sampleRows = mergeHistogramRows(sampleRows, histogramRows)
+		}
+
 		return sampleRows, topNode, nil
 	}
 	// Multiple vector selector case.
@@ -158,6 +169,7 @@ func fetchMultipleMetricsSamples(ctx context.Context, tools *queryTools, metadat
 			return nil, fmt.Errorf("build timeseries by series-id: %w", err)
 		}
 		batch.Queue(sqlQuery)
+		batch.Queue(buildMultipleMetricHistogramsQuery(filter, metricInfo.MetricID, series[i]))
 		numQueries += 1
 	}
 
@@ -167,6 +179,7 @@ func fetchMultipleMetricsSamples(ctx context.Context, tools *queryTools, metadat
 	}
 	defer batchResults.Close()
 
+	var histograms []sampleRow
 	for i := 0; i < numQueries; i++ {
 		rows, err := batchResults.Query()
 		if err != nil {
@@ -180,7 +193,18 @@ func fetchMultipleMetricsSamples(ctx context.Context, tools *queryTools, metadat
 			rows.Close()
 			return nil, err
 		}
+
+		rows, err = batchResults.Query()
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		histograms, err = appendHistogramRows(histograms, rows)
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("fetching native histograms: %w", err)
+		}
 	}
 
-	return results, nil
+	return mergeHistogramRows(results, histograms), nil
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
 	"github.com/timescale/promscale/pkg/pgmodel/lreader"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/pgmodel/rollup"
 	"github.com/timescale/promscale/pkg/pgxconn"
 	"github.com/timescale/promscale/pkg/tenancy"
 )
@@ -20,6 +21,7 @@ type queryTools struct {
 	exemplarPosCache cache.PositionCache
 	labelsReader     lreader.LabelsReader
 	rAuth            tenancy.ReadAuthorizer
+	rollups          *rollup.Router
 }
 
 // getMetricTableName gets the table name for a specific metric from internal
@@ -0,0 +1,125 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package querier
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
+)
+
+// rollupSourceSQLFormat reads the given column of the rollup before the time it
+// has been refreshed until, and the raw samples after it.
+const rollupSourceSQLFormat = `(
+		SELECT time, series_id, %[4]s AS value FROM %[1]s WHERE time < '%[3]s'
+		UNION ALL
+		SELECT time, series_id, value FROM %[2]s WHERE time >= '%[3]s'
+	)`
+
+// The rollup column read by the selectors of the range functions which can be
+// evaluated on rollups. The value column holds the last sample of each bucket,
+// which is enough for functions of counters and of the latest samples, while
+// the extremes and the sum of a range are the extremes and the sum of the
+// buckets. Functions depending on the number or on the distribution of the
+// samples, such as count_over_time, avg_over_time or quantile_over_time,
+// always read the raw samples.
+var rollupRangeFuncColumns = map[string]string{
+	"rate":              "value",
+	"irate":             "value",
+	"increase":          "value",
+	"delta":             "value",
+	"idelta":            "value",
+	"last_over_time":    "value",
+	"present_over_time": "value",
+	"absent_over_time":  "value",
+	"max_over_time":     "max",
+	"min_over_time":     "min",
+	"sum_over_time":     "sum",
+}
+
+// Functions of instant selectors which can be evaluated on the last sample of
+// each bucket. The empty name is a selector outside of any function, such as a
+// selector of an aggregation or of a binary operation.
+var rollupInstantFuncs = map[string]struct{}{
+	"":                   {},
+	"abs":                {},
+	"absent":             {},
+	"ceil":               {},
+	"clamp":              {},
+	"clamp_max":          {},
+	"clamp_min":          {},
+	"exp":                {},
+	"floor":              {},
+	"histogram_quantile": {},
+	"label_join":         {},
+	"label_replace":      {},
+	"ln":                 {},
+	"log10":              {},
+	"log2":               {},
+	"round":              {},
+	"sgn":                {},
+	"sqrt":               {},
+}
+
+// routeToRollup makes a single metric query of a range query read from the
+// coarsest rollup of the metric allowed by the step and by the selector.
+// Queries without a step, such as instant queries and remote reads, always
+// read the raw samples.
+func routeToRollup(ctx context.Context, tools *queryTools, metadata *evalMetadata) {
+	filter := &metadata.timeFilter
+	if tools.rollups == nil || metadata.promqlMetadata == nil || filter.schema != schema.PromData || filter.column != defaultColumnName {
+		return
+	}
+	sh, qh := metadata.selectHints, metadata.queryHints
+	if sh == nil || sh.Step <= 0 {
+		return
+	}
+
+	column := "value"
+	maxResolution := time.Duration(sh.Step) * time.Millisecond
+	switch {
+	case sh.Range > 0:
+		var ok bool
+		if column, ok = rollupRangeFuncColumns[sh.Func]; !ok {
+			return
+		}
+		// A range selector needs at least two samples within its range.
+		if r := time.Duration(sh.Range) * time.Millisecond / 2; r < maxResolution {
+			maxResolution = r
+		}
+	case qh != nil && qh.Lookback > 0:
+		if _, ok := rollupInstantFuncs[sh.Func]; !ok {
+			return
+		}
+		// An instant selector needs a sample within the lookback delta.
+		if qh.Lookback < maxResolution {
+			maxResolution = qh.Lookback
+		}
+	default:
+		return
+	}
+
+	source, ok := tools.rollups.Select(ctx, filter.metric, maxResolution, time.UnixMilli(sh.Start))
+	if !ok {
+		return
+	}
+	filter.rollupSchema = source.Schema
+	filter.rollupUntil = toRFC3339Nano(source.Until.UnixMilli())
+	filter.rollupColumn = column
+}
+
+// metricSource returns the relation the samples of a single metric query are
+// read from.
+func metricSource(filter timeFilter) string {
+	table := pgx.Identifier{filter.schema, filter.metric}.Sanitize()
+	if filter.rollupSchema == "" {
+		return table
+	}
+	return fmt.Sprintf(rollupSourceSQLFormat, pgx.Identifier{filter.rollupSchema, filter.metric}.Sanitize(), table, filter.rollupUntil,
+		pgx.Identifier{filter.rollupColumn}.Sanitize())
+}
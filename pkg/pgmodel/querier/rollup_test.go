@@ -0,0 +1,155 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package querier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/pgmodel/model"
+	"github.com/timescale/promscale/pkg/pgmodel/rollup"
+)
+
+func TestRouteToRollup(t *testing.T) {
+	var (
+		hour   = time.Hour.Milliseconds()
+		minute = time.Minute.Milliseconds()
+		until  = time.Date(2022, 10, 18, 12, 0, 0, 0, time.UTC)
+		start  = until.Add(-24 * time.Hour).UnixMilli()
+	)
+	testCases := []struct {
+		name         string
+		hints        *storage.SelectHints
+		lookback     time.Duration
+		column       string
+		schema       string
+		expected     string
+		rollupColumn string
+	}{
+		{
+			name:     "instant selector limited by lookback",
+			hints:    &storage.SelectHints{Start: start, Step: hour},
+			lookback: 5 * time.Minute,
+			expected: "prom_data_rollup_5m",
+		},
+		{
+			name:     "range selector limited by range",
+			hints:    &storage.SelectHints{Start: start, Step: hour, Range: 30 * minute, Func: "rate"},
+			expected: "prom_data_rollup_5m",
+		},
+		{
+			name:     "range selector limited by step",
+			hints:    &storage.SelectHints{Start: start, Step: hour, Range: 4 * hour, Func: "rate"},
+			expected: "prom_data_rollup_1h",
+		},
+		{
+			name:  "step finer than rollups",
+			hints: &storage.SelectHints{Start: start, Step: 30 * 1000, Range: 4 * hour, Func: "rate"},
+		},
+		{
+			name:  "function depending on sample count",
+			hints: &storage.SelectHints{Start: start, Step: hour, Range: 4 * hour, Func: "count_over_time"},
+		},
+		{
+			name:  "function depending on sample distribution",
+			hints: &storage.SelectHints{Start: start, Step: hour, Range: 4 * hour, Func: "quantile_over_time"},
+		},
+		{
+			name:         "function reading the max column",
+			hints:        &storage.SelectHints{Start: start, Step: hour, Range: 4 * hour, Func: "max_over_time"},
+			expected:     "prom_data_rollup_1h",
+			rollupColumn: "max",
+		},
+		{
+			name:         "function reading the sum column",
+			hints:        &storage.SelectHints{Start: start, Step: hour, Range: 4 * hour, Func: "sum_over_time"},
+			expected:     "prom_data_rollup_1h",
+			rollupColumn: "sum",
+		},
+		{
+			name:     "instant selector within a function",
+			hints:    &storage.SelectHints{Start: start, Step: hour, Func: "abs"},
+			lookback: 5 * time.Minute,
+			expected: "prom_data_rollup_5m",
+		},
+		{
+			name:     "instant selector within timestamp",
+			hints:    &storage.SelectHints{Start: start, Step: hour, Func: "timestamp"},
+			lookback: 5 * time.Minute,
+		},
+		{
+			name:     "instant query",
+			hints:    &storage.SelectHints{Start: start},
+			lookback: 5 * time.Minute,
+		},
+		{
+			name:  "query after the rollups",
+			hints: &storage.SelectHints{Start: until.UnixMilli(), Step: hour, Range: 4 * hour, Func: "rate"},
+		},
+		{
+			name:   "custom column",
+			hints:  &storage.SelectHints{Start: start, Step: hour, Range: 4 * hour, Func: "rate"},
+			column: "max",
+		},
+		{
+			name:   "custom schema",
+			hints:  &storage.SelectHints{Start: start, Step: hour, Range: 4 * hour, Func: "rate"},
+			schema: "my_schema",
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock := model.NewSqlRecorder([]model.SqlQuery{
+				{
+					Sql: "SELECT mr.table_name, r.schema_name, (extract(epoch FROM r.resolution) * 1000)::bigint, mr.refreshed_until\n\t" +
+						"FROM _prom_catalog.metric_rollup mr\n\t" +
+						"INNER JOIN _prom_catalog.rollup r ON r.name = mr.rollup_name",
+					Results: model.RowResults{
+						{"foo", "prom_data_rollup_5m", 5 * minute, until},
+						{"foo", "prom_data_rollup_1h", hour, until},
+					},
+				},
+			}, t)
+			tools := &queryTools{rollups: rollup.NewRouter(mock)}
+			column, schema := defaultColumnName, "prom_data"
+			if c.column != "" {
+				column = c.column
+			}
+			if c.schema != "" {
+				schema = c.schema
+			}
+			metadata := &evalMetadata{
+				timeFilter:     timeFilter{metric: "foo", schema: schema, column: column},
+				promqlMetadata: &promqlMetadata{selectHints: c.hints, queryHints: &QueryHints{Lookback: c.lookback}},
+			}
+
+			routeToRollup(context.Background(), tools, metadata)
+			require.Equal(t, c.expected, metadata.timeFilter.rollupSchema)
+			if c.expected != "" {
+				require.Equal(t, "2022-10-18T12:00:00Z", metadata.timeFilter.rollupUntil)
+				expectedColumn := "value"
+				if c.rollupColumn != "" {
+					expectedColumn = c.rollupColumn
+				}
+				require.Equal(t, expectedColumn, metadata.timeFilter.rollupColumn)
+			}
+		})
+	}
+}
+
+func TestMetricSource(t *testing.T) {
+	filter := timeFilter{schema: "prom_data", metric: "foo"}
+	require.Equal(t, `"prom_data"."foo"`, metricSource(filter))
+
+	filter.rollupSchema, filter.rollupUntil, filter.rollupColumn = "prom_data_rollup_5m", "2022-10-18T12:00:00Z", "max"
+	require.Equal(t, `(
+		SELECT time, series_id, "max" AS value FROM "prom_data_rollup_5m"."foo" WHERE time < '2022-10-18T12:00:00Z'
+		UNION ALL
+		SELECT time, series_id, value FROM "prom_data"."foo" WHERE time >= '2022-10-18T12:00:00Z'
+	)`, metricSource(filter))
+}
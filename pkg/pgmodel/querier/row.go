@@ -140,6 +140,9 @@ type sampleRow struct {
 	metricOverride string
 	schema         string
 	column         string
+	// histograms holds the native histograms of the row, one per timestamp.
+	// It is nil for rows of float samples.
+	histograms []*model.FloatHistogram
 
 	//only used to hold ownership for releasing to pool
 	timeArrayOwnership *pgtype.TimestamptzArray
@@ -5,6 +5,7 @@
 package querier
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
 	"github.com/timescale/promscale/pkg/pgmodel/cache"
+	"github.com/timescale/promscale/pkg/pgmodel/lreader"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
 )
 
@@ -78,12 +80,12 @@ func newMockLabelsReader(keys []int64, lbls []labels.Label) mockLabelsReader {
 	return mockLabelsReader{items}
 }
 
-func (m mockLabelsReader) LabelNames() ([]string, error) {
+func (m mockLabelsReader) LabelNames(context.Context, lreader.LabelsQuery) ([]string, error) {
 	return nil, nil
 }
 
 // LabelValues returns all the distinct values for a given label name.
-func (m mockLabelsReader) LabelValues(_ string) ([]string, error) {
+func (m mockLabelsReader) LabelValues(_ context.Context, _ string, _ lreader.LabelsQuery) ([]string, error) {
 	return nil, nil
 }
 
@@ -83,8 +83,9 @@ func (p *pgxSamplesSeriesSet) At() storage.Series {
 	}
 
 	ps := &pgxSeries{
-		times:  row.times,
-		values: row.values,
+		times:      row.times,
+		values:     row.values,
+		histograms: row.histograms,
 	}
 
 	// this should pretty much always be non-empty due to __name__, but it
@@ -150,9 +151,10 @@ func (p *pgxSamplesSeriesSet) Close() {
 
 // pgxSeries implements storage.Series.
 type pgxSeries struct {
-	labels labels.Labels
-	times  TimestampSeries
-	values *pgtype.Float8Array
+	labels     labels.Labels
+	times      TimestampSeries
+	values     *pgtype.Float8Array
+	histograms []*model.FloatHistogram
 }
 
 // Labels returns the label names and values for the series.
@@ -160,6 +162,26 @@ func (p *pgxSeries) Labels() labels.Labels {
 	return p.labels
 }
 
+// HistogramAt returns the native histogram of the series at timestamp t, or nil if
+// the series does not hold a histogram at that timestamp. The iterator of a
+// histogram series yields the count of observations as the sample value.
+func (p *pgxSeries) HistogramAt(t int64) *model.FloatHistogram {
+	if len(p.histograms) == 0 {
+		return nil
+	}
+	idx := sort.Search(p.times.Len(), func(i int) bool {
+		ts, _ := p.times.At(i)
+		return ts >= t
+	})
+	if idx == p.times.Len() {
+		return nil
+	}
+	if ts, _ := p.times.At(idx); ts != t {
+		return nil
+	}
+	return p.histograms[idx]
+}
+
 // Iterator returns a chunkenc.Iterator for iterating over series data.
 func (p *pgxSeries) Iterator() chunkenc.Iterator {
 	return newIterator(p.times, p.values)
@@ -33,6 +33,17 @@ func (t *rowTimestampSeries) Len() int {
 	return len(t.times.Elements)
 }
 
+// sliceTimestampSeries is a TimestampSeries based on a slice of timestamps in milliseconds.
+type sliceTimestampSeries []int64
+
+func (t sliceTimestampSeries) At(index int) (int64, bool) {
+	return t[index], true
+}
+
+func (t sliceTimestampSeries) Len() int {
+	return len(t)
+}
+
 // regularTimestampSeries represents a time-series that is regular (e.g. each timestamp is step duration ahead of the previous one)
 type regularTimestampSeries struct {
 	start time.Time
@@ -0,0 +1,36 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package rollup
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultRefreshInterval = time.Minute
+	defaultQueryRouting    = true
+)
+
+// Config configures the maintenance of the rollups declared in the dataset
+// configuration and the routing of queries to them.
+type Config struct {
+	RefreshInterval time.Duration
+	QueryRouting    bool
+}
+
+func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
+	fs.DurationVar(&cfg.RefreshInterval, "metrics.rollup.refresh-interval", defaultRefreshInterval, "How often the rollups are refreshed with the newly ingested samples. 0 disables refreshing the rollups from this connector.")
+	fs.BoolVar(&cfg.QueryRouting, "metrics.rollup.query-routing", defaultQueryRouting, "Read from the coarsest rollup that satisfies the step of a range query, instead of the raw samples.")
+	return cfg
+}
+
+func Validate(cfg *Config) error {
+	if cfg.RefreshInterval < 0 {
+		return fmt.Errorf("metrics.rollup.refresh-interval must not be negative: %s", cfg.RefreshInterval)
+	}
+	return nil
+}
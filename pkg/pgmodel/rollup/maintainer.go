@@ -0,0 +1,144 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package rollup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+const (
+	lockID = 3826153902128373129 // Chosen randomly.
+
+	sqlAcquireLock    = "SELECT pg_try_advisory_lock($1)"
+	sqlReleaseLock    = "SELECT pg_advisory_unlock($1)"
+	sqlListRollups    = "SELECT name FROM _prom_catalog.rollup ORDER BY resolution"
+	sqlListTables     = "SELECT table_name FROM _prom_catalog.metric WHERE table_schema = 'prom_data' AND NOT is_view ORDER BY table_name"
+	sqlRefreshMetric  = "SELECT _prom_catalog.refresh_metric_rollup($1, $2, $3, $4)"
+	sqlApplyRetention = "SELECT _prom_catalog.apply_rollup_retention($1)"
+
+	// Samples may arrive late, so the buckets are only rolled up once they are
+	// older than refreshDelay. Samples arriving even later are not rolled up.
+	refreshDelay = 5 * time.Minute
+	// Maximum time range rolled up in a single statement.
+	refreshWindow = 24 * time.Hour
+)
+
+// Maintainer periodically refreshes the rollups with the newly ingested samples
+// and drops the rollup data older than the retention period of the rollup. Only
+// one connector of the deployment refreshes the rollups at a time.
+type Maintainer struct {
+	conn     pgxconn.PgxConn
+	interval time.Duration
+}
+
+// NewMaintainer creates a new Maintainer.
+func NewMaintainer(conn pgxconn.PgxConn, interval time.Duration) *Maintainer {
+	return &Maintainer{conn: conn, interval: interval}
+}
+
+// Run refreshes the rollups every interval until the context is cancelled. It
+// blocks until then.
+func (m *Maintainer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := m.refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Error("msg", "failed to refresh rollups", "err", err)
+		}
+	}
+}
+
+func (m *Maintainer) refresh(ctx context.Context) error {
+	conn, err := m.conn.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	acquired := false
+	if err = conn.QueryRow(ctx, sqlAcquireLock, lockID).Scan(&acquired); err != nil {
+		return fmt.Errorf("acquire advisory lock: %w", err)
+	}
+	if !acquired {
+		log.Debug("msg", "rollups are being refreshed by another connector")
+		return nil
+	}
+	defer func() {
+		// Release the lock even if the context was cancelled.
+		if _, err := conn.Exec(context.Background(), sqlReleaseLock, lockID); err != nil {
+			log.Error("msg", "failed to release rollup advisory lock", "err", err)
+		}
+	}()
+
+	rollups, err := queryStrings(ctx, conn, sqlListRollups)
+	if err != nil || len(rollups) == 0 {
+		return err
+	}
+	tables, err := queryStrings(ctx, conn, sqlListTables)
+	if err != nil {
+		return err
+	}
+
+	until := time.Now().Add(-refreshDelay)
+	for _, rollup := range rollups {
+		for _, table := range tables {
+			if err = refreshMetric(ctx, conn, rollup, table, until); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				// The metric might have been dropped in the meantime.
+				log.Warn("msg", "failed to refresh metric rollup", "rollup", rollup, "table", table, "err", err)
+			}
+		}
+		if _, err = conn.Exec(ctx, sqlApplyRetention, rollup); err != nil {
+			return fmt.Errorf("apply retention of rollup %s: %w", rollup, err)
+		}
+	}
+	return nil
+}
+
+// refreshMetric rolls up the metric table window by window, until the rollup
+// is up to date.
+func refreshMetric(ctx context.Context, conn *pgxpool.Conn, rollup, table string, until time.Time) error {
+	var previous time.Time
+	for {
+		var refreshedUntil time.Time
+		if err := conn.QueryRow(ctx, sqlRefreshMetric, rollup, table, until, refreshWindow).Scan(&refreshedUntil); err != nil {
+			return err
+		}
+		if refreshedUntil.Equal(previous) {
+			return nil
+		}
+		previous = refreshedUntil
+	}
+}
+
+func queryStrings(ctx context.Context, conn *pgxpool.Conn, sql string) ([]string, error) {
+	rows, err := conn.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []string
+	for rows.Next() {
+		var s string
+		if err = rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
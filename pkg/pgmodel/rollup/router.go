@@ -0,0 +1,111 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package rollup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgxconn"
+)
+
+const (
+	listSourcesSQL = `SELECT mr.table_name, r.schema_name, (extract(epoch FROM r.resolution) * 1000)::bigint, mr.refreshed_until
+	FROM _prom_catalog.metric_rollup mr
+	INNER JOIN _prom_catalog.rollup r ON r.name = mr.rollup_name`
+
+	defaultRouterTTL = 30 * time.Second
+)
+
+// Source is a rollup of a metric table that queries can read from.
+type Source struct {
+	Schema     string
+	Resolution time.Duration
+	// The rollup contains the buckets before Until. The samples after it
+	// have to be read from the metric table.
+	Until time.Time
+}
+
+// Router selects the rollup to read a metric from. The rollups of the metrics
+// are cached, and reloaded from the database once they are older than the TTL.
+// A stale cache only means that more raw samples are read than necessary.
+type Router struct {
+	conn pgxconn.PgxConn
+	ttl  time.Duration
+
+	mu       sync.Mutex
+	loadedAt time.Time
+	// Rollups of each metric table, coarsest first.
+	sources map[string][]Source
+}
+
+// NewRouter creates a new Router.
+func NewRouter(conn pgxconn.PgxConn) *Router {
+	return &Router{conn: conn, ttl: defaultRouterTTL}
+}
+
+// Select returns the coarsest rollup of the metric table with a resolution of
+// at most maxResolution, provided that it contains buckets after start. Otherwise
+// reading the rollup would not save reading any raw samples.
+func (r *Router) Select(ctx context.Context, table string, maxResolution time.Duration, start time.Time) (Source, bool) {
+	return selectSource(r.metricSources(ctx, table), maxResolution, start)
+}
+
+func selectSource(sources []Source, maxResolution time.Duration, start time.Time) (Source, bool) {
+	for _, s := range sources {
+		if s.Resolution <= maxResolution && s.Until.After(start) {
+			return s, true
+		}
+	}
+	return Source{}, false
+}
+
+func (r *Router) metricSources(ctx context.Context, table string) []Source {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.loadedAt) >= r.ttl {
+		sources, err := r.load(ctx)
+		if err != nil {
+			// Keep using the previous rollups, and retry once the TTL has expired again.
+			log.Warn("msg", "failed to load metric rollups", "err", err)
+		} else {
+			r.sources = sources
+		}
+		r.loadedAt = time.Now()
+	}
+	return r.sources[table]
+}
+
+func (r *Router) load(ctx context.Context) (map[string][]Source, error) {
+	rows, err := r.conn.Query(ctx, listSourcesSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sources := make(map[string][]Source)
+	for rows.Next() {
+		var (
+			table        string
+			resolutionMs int64
+			s            Source
+		)
+		if err = rows.Scan(&table, &s.Schema, &resolutionMs, &s.Until); err != nil {
+			return nil, fmt.Errorf("scan metric rollup: %w", err)
+		}
+		s.Resolution = time.Duration(resolutionMs) * time.Millisecond
+		sources[table] = append(sources[table], s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, s := range sources {
+		sort.Slice(s, func(i, j int) bool { return s[i].Resolution > s[j].Resolution })
+	}
+	return sources, nil
+}
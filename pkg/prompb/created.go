@@ -0,0 +1,29 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package prompb
+
+import "math"
+
+// createdZeroBits is the NaN marking the zero valued sample, or the sum of the
+// zero valued histogram, added for the created timestamp of a series. Like the
+// staleness marker of Prometheus, it lets the ingestor tell these zeros apart
+// from the samples which were sent, it is replaced by a zero when ingested.
+const createdZeroBits uint64 = 0x7ff0000000000003
+
+// CreatedZero is the value of the samples, and the sum of the histograms,
+// marking the created timestamp of a series.
+var CreatedZero = math.Float64frombits(createdZeroBits)
+
+// IsCreatedZero returns true if the sample marks the created timestamp of its
+// series.
+func (m Sample) IsCreatedZero() bool {
+	return math.Float64bits(m.Value) == createdZeroBits
+}
+
+// IsCreatedZero returns true if the histogram marks the created timestamp of
+// its series.
+func (h Histogram) IsCreatedZero() bool {
+	return math.Float64bits(h.Sum) == createdZeroBits
+}
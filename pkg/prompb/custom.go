@@ -15,3 +15,10 @@ package prompb
 
 func (m Sample) T() int64   { return m.Timestamp }
 func (m Sample) V() float64 { return m.Value }
+
+// IsFloatHistogram returns true if the histogram carries float counts
+// instead of integer deltas.
+func (h Histogram) IsFloatHistogram() bool {
+	_, ok := h.GetCount().(*Histogram_CountFloat)
+	return ok
+}
@@ -44,6 +44,7 @@ import (
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/util/stats"
 
+	pgmodel "github.com/timescale/promscale/pkg/pgmodel/model"
 	pgquerier "github.com/timescale/promscale/pkg/pgmodel/querier"
 	"github.com/timescale/promscale/pkg/util"
 )
@@ -58,14 +59,22 @@ type Queryable interface {
 	ExemplarsQuerier(ctx context.Context) pgquerier.ExemplarQuerier
 }
 
+// LabelHints specifies hints passed for label reads.
+type LabelHints struct {
+	// Limit is the maximum number of results returned, 0 meaning no limit.
+	Limit int
+}
+
 // SamplesQuerier provides querying access over time series data of a fixed time range.
 type SamplesQuerier interface {
-	// LabelValues returns all potential values for a label name.
+	// LabelValues returns all potential values for a label name in sorted order,
+	// of the series matching the matchers if any.
 	// It is not safe to use the strings beyond the lifefime of the querier.
-	LabelValues(name string) ([]string, storage.Warnings, error)
+	LabelValues(name string, hints *LabelHints, matchers ...*labels.Matcher) ([]string, storage.Warnings, error)
 
-	// LabelNames returns all the unique label names present in the block in sorted order.
-	LabelNames(...*labels.Matcher) ([]string, storage.Warnings, error)
+	// LabelNames returns all the unique label names present in the block in sorted order,
+	// of the series matching the matchers if any.
+	LabelNames(hints *LabelHints, matchers ...*labels.Matcher) ([]string, storage.Warnings, error)
 
 	// Close releases the resources of the Querier.
 	Close()
@@ -1512,7 +1521,7 @@ func (ev *evaluator) eval(expr parser.Expr) (parser.Value, storage.Warnings) {
 				maxt := ts - offset
 				mint := maxt - selRange
 				// Evaluate the matrix selector for this series for this step.
-				points = ev.matrixIterSlice(it, mint, maxt, points)
+				points = ev.matrixIterSlice(it, selVS.Series[i], mint, maxt, points)
 				if len(points) == 0 {
 					continue
 				}
@@ -1676,10 +1685,10 @@ func (ev *evaluator) eval(expr parser.Expr) (parser.Value, storage.Warnings) {
 
 			for ts, step := ev.startTimestamp, -1; ts <= ev.endTimestamp; ts += ev.interval {
 				step++
-				_, v, ok := ev.vectorSelectorSingle(it, e, ts)
+				t, v, ok := ev.vectorSelectorSingle(it, e, ts)
 				if ok {
 					if ev.currentSamples < ev.maxSamples {
-						ss.Points = append(ss.Points, Point{V: v, T: ts})
+						ss.Points = append(ss.Points, Point{V: v, T: ts, H: histogramAt(s, t)})
 						ev.samplesStats.IncrementSamplesAtStep(step, 1)
 						ev.currentSamples++
 					} else {
@@ -1790,6 +1799,7 @@ func (ev *evaluator) eval(expr parser.Expr) (parser.Value, storage.Warnings) {
 				mat[i].Points = append(mat[i].Points, Point{
 					T: ts,
 					V: mat[i].Points[0].V,
+					H: mat[i].Points[0].H,
 				})
 				ev.currentSamples++
 				if ev.currentSamples > ev.maxSamples {
@@ -1819,7 +1829,7 @@ func (ev *evaluator) vectorSelector(node *parser.VectorSelector, ts int64) (Vect
 		if ok {
 			vec = append(vec, Sample{
 				Metric: node.Series[i].Labels(),
-				Point:  Point{V: v, T: t},
+				Point:  Point{V: v, T: t, H: histogramAt(s, t)},
 			})
 
 			ev.currentSamples++
@@ -1862,6 +1872,21 @@ func (ev *evaluator) vectorSelectorSingle(it *storage.MemoizedSeriesIterator, no
 	return t, v, true
 }
 
+// histogramSeries is implemented by series which can hold native histograms.
+type histogramSeries interface {
+	// HistogramAt returns the native histogram at timestamp t, or nil if
+	// there is none.
+	HistogramAt(t int64) *pgmodel.FloatHistogram
+}
+
+// histogramAt returns the native histogram of the series at timestamp t, if any.
+func histogramAt(s storage.Series, t int64) *pgmodel.FloatHistogram {
+	if hs, ok := s.(histogramSeries); ok {
+		return hs.HistogramAt(t)
+	}
+	return nil
+}
+
 var pointPool = sync.Pool{}
 
 func getPointSlice(sz int) []Point {
@@ -1904,7 +1929,7 @@ func (ev *evaluator) matrixSelector(node *parser.MatrixSelector) (Matrix, storag
 			Metric: series[i].Labels(),
 		}
 
-		ss.Points = ev.matrixIterSlice(it, mint, maxt, getPointSlice(16))
+		ss.Points = ev.matrixIterSlice(it, s, mint, maxt, getPointSlice(16))
 		ev.samplesStats.IncrementSamplesAtTimestamp(ev.startTimestamp, int64(len(ss.Points)))
 
 		if len(ss.Points) > 0 {
@@ -1924,7 +1949,7 @@ func (ev *evaluator) matrixSelector(node *parser.MatrixSelector) (Matrix, storag
 // values). Any such points falling before mint are discarded; points that fall
 // into the [mint, maxt] range are retained; only points with later timestamps
 // are populated from the iterator.
-func (ev *evaluator) matrixIterSlice(it *storage.BufferedSeriesIterator, mint, maxt int64, out []Point) []Point {
+func (ev *evaluator) matrixIterSlice(it *storage.BufferedSeriesIterator, s storage.Series, mint, maxt int64, out []Point) []Point {
 	if len(out) > 0 && out[len(out)-1].T >= mint {
 		// There is an overlap between previous and current ranges, retain common
 		// points. In most such cases:
@@ -1963,7 +1988,7 @@ func (ev *evaluator) matrixIterSlice(it *storage.BufferedSeriesIterator, mint, m
 				ev.error(ErrTooManySamples(env))
 			}
 			ev.currentSamples++
-			out = append(out, Point{T: t, V: v})
+			out = append(out, Point{T: t, V: v, H: histogramAt(s, t)})
 		}
 	}
 	// The seeked sample might also be in the range.
@@ -1973,7 +1998,7 @@ func (ev *evaluator) matrixIterSlice(it *storage.BufferedSeriesIterator, mint, m
 			if ev.currentSamples >= ev.maxSamples {
 				ev.error(ErrTooManySamples(env))
 			}
-			out = append(out, Point{T: t, V: v})
+			out = append(out, Point{T: t, V: v, H: histogramAt(s, t)})
 			ev.currentSamples++
 		}
 	}
@@ -2354,6 +2379,10 @@ type groupedAggregation struct {
 	groupCount  int
 	heap        vectorByValueHeap
 	reverseHeap vectorByReverseValueHeap
+	// histogram is the sum of native histograms, for sum and avg. It is
+	// only used if all the aggregated samples are native histograms.
+	histogram  *pgmodel.FloatHistogram
+	floatsSeen bool
 }
 
 // aggregation evaluates an aggregation operation on a Vector. The provided grouping labels
@@ -2399,6 +2428,10 @@ func (ev *evaluator) aggregation(op parser.ItemType, grouping []string, without
 	for si, s := range vec {
 		metric := s.Metric
 
+		if s.H != nil && !supportsHistograms(op) {
+			ev.errorf("%s aggregation is not supported for native histograms", op)
+		}
+
 		if op == parser.COUNT_VALUES {
 			lb.Reset(metric)
 			lb.Set(valueLabel, strconv.FormatFloat(s.V, 'f', -1, 64))
@@ -2447,6 +2480,12 @@ func (ev *evaluator) aggregation(op parser.ItemType, grouping []string, without
 				resultSize = 1
 			}
 			switch op {
+			case parser.SUM, parser.AVG:
+				if s.H != nil {
+					result[groupingKey].histogram = s.H.Copy()
+				} else {
+					result[groupingKey].floatsSeen = true
+				}
 			case parser.STDVAR, parser.STDDEV:
 				result[groupingKey].value = 0
 			case parser.TOPK, parser.QUANTILE:
@@ -2470,9 +2509,19 @@ func (ev *evaluator) aggregation(op parser.ItemType, grouping []string, without
 		switch op {
 		case parser.SUM:
 			group.value += s.V
+			if s.H == nil {
+				group.floatsSeen = true
+			} else if group.histogram != nil {
+				group.histogram.Add(s.H)
+			}
 
 		case parser.AVG:
 			group.groupCount++
+			if s.H == nil {
+				group.floatsSeen = true
+			} else if group.histogram != nil {
+				group.histogram.Add(s.H)
+			}
 			if math.IsInf(group.mean, 0) {
 				if math.IsInf(s.V, 0) && (group.mean > 0) == (s.V > 0) {
 					// The `mean` and `s.V` values are `Inf` of the same sign.  They
@@ -2563,6 +2612,14 @@ func (ev *evaluator) aggregation(op parser.ItemType, grouping []string, without
 	for _, aggr := range orderedResult {
 		switch op {
 		case parser.AVG:
+			if aggr.histogram != nil && !aggr.floatsSeen {
+				h := aggr.histogram.Mul(1 / float64(aggr.groupCount))
+				enh.Out = append(enh.Out, Sample{
+					Metric: aggr.labels,
+					Point:  Point{V: h.Count, H: h},
+				})
+				continue // Bypass default append.
+			}
 			aggr.value = aggr.mean
 
 		case parser.COUNT, parser.COUNT_VALUES:
@@ -2603,6 +2660,15 @@ func (ev *evaluator) aggregation(op parser.ItemType, grouping []string, without
 		case parser.QUANTILE:
 			aggr.value = quantile(q, aggr.heap)
 
+		case parser.SUM:
+			if aggr.histogram != nil && !aggr.floatsSeen {
+				enh.Out = append(enh.Out, Sample{
+					Metric: aggr.labels,
+					Point:  Point{V: aggr.histogram.Count, H: aggr.histogram},
+				})
+				continue // Bypass default append.
+			}
+
 		default:
 			// For other aggregations, we already have the right value.
 		}
@@ -2615,6 +2681,18 @@ func (ev *evaluator) aggregation(op parser.ItemType, grouping []string, without
 	return enh.Out
 }
 
+// supportsHistograms returns true if the aggregation can be applied to native
+// histograms. count and group do not depend on the sample values, and sum and
+// avg aggregate the histograms themselves, if all the aggregated samples are
+// native histograms.
+func supportsHistograms(op parser.ItemType) bool {
+	switch op {
+	case parser.SUM, parser.AVG, parser.COUNT, parser.GROUP:
+		return true
+	}
+	return false
+}
+
 // groupingKey builds and returns the grouping key for the given metric and
 // grouping labels.
 func generateGroupingKey(metric labels.Labels, grouping []string, without bool, buf []byte) (uint64, []byte) {
@@ -26,6 +26,7 @@ import (
 
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql/parser"
+	pgmodel "github.com/timescale/promscale/pkg/pgmodel/model"
 )
 
 // FunctionCall is the type of a PromQL function implementation
@@ -71,6 +72,17 @@ func extrapolatedRate(vals []parser.Value, args parser.Expressions, enh *EvalNod
 		return enh.Out
 	}
 
+	// Native histograms are handled on a bucket basis, while the
+	// extrapolation is calculated on their count of observations.
+	var resultHistogram *pgmodel.FloatHistogram
+	if samples.Points[0].H != nil && samples.Points[len(samples.Points)-1].H != nil {
+		resultHistogram = histogramIncrease(samples.Points, isCounter)
+		if resultHistogram == nil {
+			// Mix of floats and histograms, which cannot be combined.
+			return enh.Out
+		}
+	}
+
 	resultValue := samples.Points[len(samples.Points)-1].V - samples.Points[0].V
 	if isCounter {
 		var lastValue float64
@@ -125,11 +137,42 @@ func extrapolatedRate(vals []parser.Value, args parser.Expressions, enh *EvalNod
 		resultValue = resultValue / ms.Range.Seconds()
 	}
 
+	if resultHistogram != nil {
+		resultHistogram.Mul(extrapolateToInterval / sampledInterval)
+		if isRate {
+			resultHistogram.Mul(1 / ms.Range.Seconds())
+		}
+		return append(enh.Out, Sample{
+			Point: Point{V: resultHistogram.Count, H: resultHistogram},
+		})
+	}
+
 	return append(enh.Out, Sample{
 		Point: Point{V: resultValue},
 	})
 }
 
+// histogramIncrease returns the difference between the last and the first native
+// histogram of the points, taking counter resets into account if isCounter is true.
+// It returns nil if any of the points is not a native histogram.
+func histogramIncrease(points []Point, isCounter bool) *pgmodel.FloatHistogram {
+	prev := points[0].H
+	result := points[len(points)-1].H.Copy().Sub(prev)
+	if !isCounter {
+		return result
+	}
+	for _, p := range points[1:] {
+		if p.H == nil {
+			return nil
+		}
+		if p.H.DetectReset(prev) {
+			result.Add(prev)
+		}
+		prev = p.H
+	}
+	return result
+}
+
 // === delta(Matrix parser.ValueTypeMatrix) Vector ===
 func funcDelta(vals []parser.Value, args parser.Expressions, enh *EvalNodeHelper) Vector {
 	return extrapolatedRate(vals, args, enh, false, false)
@@ -800,6 +843,15 @@ func funcHistogramQuantile(vals []parser.Value, args parser.Expressions, enh *Ev
 		}
 	}
 	for _, el := range inVec {
+		if el.H != nil {
+			// Native histograms carry their own buckets, so the
+			// quantile can be calculated for each of them directly.
+			enh.Out = append(enh.Out, Sample{
+				Metric: enh.DropMetricName(el.Metric),
+				Point:  Point{V: histogramQuantile(q, el.H)},
+			})
+			continue
+		}
 		upperBound, err := strconv.ParseFloat(
 			el.Metric.Get(model.BucketLabel), 64,
 		)
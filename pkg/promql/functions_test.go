@@ -24,6 +24,7 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/timestamp"
 	"github.com/prometheus/prometheus/promql/parser"
+	pgmodel "github.com/timescale/promscale/pkg/pgmodel/model"
 )
 
 func TestDeriv(t *testing.T) {
@@ -84,3 +85,99 @@ func TestKahanSum(t *testing.T) {
 	expected := 2.0
 	require.Equal(t, expected, kahanSum(vals))
 }
+
+func TestHistogramQuantile(t *testing.T) {
+	// Buckets (0.5, 1], (1, 2], (2, 4] with 2, 4 and 2 observations and
+	// 2 observations in the zero bucket [-0.001, 0.001].
+	h := &pgmodel.FloatHistogram{
+		Schema:          0,
+		ZeroThreshold:   0.001,
+		ZeroCount:       2,
+		Count:           10,
+		Sum:             20,
+		PositiveSpans:   []pgmodel.HistogramSpan{{Offset: 0, Length: 3}},
+		PositiveBuckets: []float64{2, 4, 2},
+	}
+	cases := []struct {
+		q, expected float64
+	}{
+		{q: 0, expected: 0},
+		{q: 0.1, expected: 0.0005},
+		{q: 0.3, expected: 0.75},
+		{q: 0.5, expected: 1.25},
+		{q: 0.8, expected: 2},
+		{q: 1, expected: 4},
+		{q: -1, expected: math.Inf(-1)},
+		{q: 2, expected: math.Inf(+1)},
+	}
+	for _, c := range cases {
+		require.InDelta(t, c.expected, histogramQuantile(c.q, h), 1e-9, "q=%v", c.q)
+	}
+	require.True(t, math.IsNaN(histogramQuantile(math.NaN(), h)))
+	require.True(t, math.IsNaN(histogramQuantile(0.5, &pgmodel.FloatHistogram{})))
+}
+
+func TestHistogramIncrease(t *testing.T) {
+	hist := func(count float64, buckets ...float64) *pgmodel.FloatHistogram {
+		return &pgmodel.FloatHistogram{
+			Count:           count,
+			PositiveSpans:   []pgmodel.HistogramSpan{{Offset: 0, Length: uint32(len(buckets))}},
+			PositiveBuckets: buckets,
+		}
+	}
+	points := []Point{
+		{T: 0, V: 3, H: hist(3, 1, 2)},
+		{T: 10, V: 6, H: hist(6, 2, 4)},
+		// Counter reset.
+		{T: 20, V: 2, H: hist(2, 1, 1)},
+	}
+	res := histogramIncrease(points, true)
+	require.Equal(t, float64(5), res.Count)
+	require.Equal(t, []float64{2, 3}, res.PositiveBuckets)
+
+	res = histogramIncrease(points, false)
+	require.Equal(t, float64(-1), res.Count)
+
+	points[1].H = nil
+	require.Nil(t, histogramIncrease(points, true))
+}
+
+func TestHistogramAggregation(t *testing.T) {
+	hist := func(count float64, buckets ...float64) *pgmodel.FloatHistogram {
+		return &pgmodel.FloatHistogram{
+			Count:           count,
+			PositiveSpans:   []pgmodel.HistogramSpan{{Offset: 0, Length: uint32(len(buckets))}},
+			PositiveBuckets: buckets,
+		}
+	}
+	vec := Vector{
+		{Metric: labels.FromStrings("a", "1"), Point: Point{V: 3, H: hist(3, 1, 2)}},
+		{Metric: labels.FromStrings("a", "2"), Point: Point{V: 5, H: hist(5, 3, 2)}},
+	}
+	ev := &evaluator{}
+
+	res := ev.aggregation(parser.SUM, nil, false, nil, vec, make([]EvalSeriesHelper, len(vec)), &EvalNodeHelper{})
+	require.Len(t, res, 1)
+	require.Equal(t, float64(8), res[0].V)
+	require.Equal(t, []float64{4, 4}, res[0].H.PositiveBuckets)
+
+	res = ev.aggregation(parser.AVG, nil, false, nil, vec, make([]EvalSeriesHelper, len(vec)), &EvalNodeHelper{})
+	require.Len(t, res, 1)
+	require.Equal(t, float64(4), res[0].V)
+	require.Equal(t, []float64{2, 2}, res[0].H.PositiveBuckets)
+
+	res = ev.aggregation(parser.COUNT, nil, false, nil, vec, make([]EvalSeriesHelper, len(vec)), &EvalNodeHelper{})
+	require.Len(t, res, 1)
+	require.Equal(t, float64(2), res[0].V)
+	require.Nil(t, res[0].H)
+
+	for _, op := range []parser.ItemType{parser.MIN, parser.MAX, parser.STDDEV, parser.STDVAR, parser.QUANTILE, parser.TOPK, parser.BOTTOMK, parser.COUNT_VALUES} {
+		var param interface{} = float64(1)
+		if op == parser.COUNT_VALUES {
+			param = "value"
+		}
+		require.PanicsWithError(t, op.String()+" aggregation is not supported for native histograms", func() {
+			ev.aggregation(op, nil, false, param, vec, make([]EvalSeriesHelper, len(vec)), &EvalNodeHelper{})
+		}, op.String())
+	}
+}
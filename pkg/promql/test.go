@@ -114,10 +114,14 @@ func (t *QuerierWrapper) Select(b bool, sh *storage.SelectHints, _ *querier.Quer
 	return ss, nil
 }
 
-func (t *QuerierWrapper) LabelValues(n string) ([]string, storage.Warnings, error) {
+func (t *QuerierWrapper) LabelValues(string, *LabelHints, ...*labels.Matcher) ([]string, storage.Warnings, error) {
 	return nil, nil, nil
 }
 
+func (t *QuerierWrapper) LabelNames(_ *LabelHints, m ...*labels.Matcher) ([]string, storage.Warnings, error) {
+	return t.Querier.LabelNames(m...)
+}
+
 func (t *QuerierWrapper) Close() {
 	_ = t.Querier.Close()
 }
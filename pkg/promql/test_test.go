@@ -47,7 +47,7 @@ func TestLazyLoader_WithSamplesTill(t *testing.T) {
 						{
 							Metric: labels.FromStrings("__name__", "metric1"),
 							Points: []Point{
-								{0, 1}, {10000, 2}, {20000, 3}, {30000, 4}, {40000, 5},
+								{T: 0, V: 1}, {T: 10000, V: 2}, {T: 20000, V: 3}, {T: 30000, V: 4}, {T: 40000, V: 5},
 							},
 						},
 					},
@@ -58,7 +58,7 @@ func TestLazyLoader_WithSamplesTill(t *testing.T) {
 						{
 							Metric: labels.FromStrings("__name__", "metric1"),
 							Points: []Point{
-								{0, 1}, {10000, 2}, {20000, 3}, {30000, 4}, {40000, 5},
+								{T: 0, V: 1}, {T: 10000, V: 2}, {T: 20000, V: 3}, {T: 30000, V: 4}, {T: 40000, V: 5},
 							},
 						},
 					},
@@ -69,7 +69,7 @@ func TestLazyLoader_WithSamplesTill(t *testing.T) {
 						{
 							Metric: labels.FromStrings("__name__", "metric1"),
 							Points: []Point{
-								{0, 1}, {10000, 2}, {20000, 3}, {30000, 4}, {40000, 5}, {50000, 6}, {60000, 7},
+								{T: 0, V: 1}, {T: 10000, V: 2}, {T: 20000, V: 3}, {T: 30000, V: 4}, {T: 40000, V: 5}, {T: 50000, V: 6}, {T: 60000, V: 7},
 							},
 						},
 					},
@@ -89,13 +89,13 @@ func TestLazyLoader_WithSamplesTill(t *testing.T) {
 						{
 							Metric: labels.FromStrings("__name__", "metric1"),
 							Points: []Point{
-								{0, 1}, {10000, 1}, {20000, 1}, {30000, 1}, {40000, 1}, {50000, 1},
+								{T: 0, V: 1}, {T: 10000, V: 1}, {T: 20000, V: 1}, {T: 30000, V: 1}, {T: 40000, V: 1}, {T: 50000, V: 1},
 							},
 						},
 						{
 							Metric: labels.FromStrings("__name__", "metric2"),
 							Points: []Point{
-								{0, 1}, {10000, 2}, {20000, 3}, {30000, 4}, {40000, 5}, {50000, 6}, {60000, 7}, {70000, 8},
+								{T: 0, V: 1}, {T: 10000, V: 2}, {T: 20000, V: 3}, {T: 30000, V: 4}, {T: 40000, V: 5}, {T: 50000, V: 6}, {T: 60000, V: 7}, {T: 70000, V: 8},
 							},
 						},
 					},
@@ -25,6 +25,7 @@ import (
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	pgmodel "github.com/timescale/promscale/pkg/pgmodel/model"
 )
 
 func (Matrix) Type() parser.ValueType { return parser.ValueTypeMatrix }
@@ -77,12 +78,18 @@ func (s Series) String() string {
 }
 
 // Point represents a single data point for a given timestamp.
+// If H is not nil, the point is a native histogram and V holds
+// its count of observations.
 type Point struct {
 	T int64
 	V float64
+	H *pgmodel.FloatHistogram
 }
 
 func (p Point) String() string {
+	if p.H != nil {
+		return fmt.Sprintf("{count:%v, sum:%v} @[%v]", p.H.Count, p.H.Sum, p.T)
+	}
 	v := strconv.FormatFloat(p.V, 'f', -1, 64)
 	return fmt.Sprintf("%v @[%v]", v, p.T)
 }
@@ -14,6 +14,10 @@ const (
 	DefaultLookBackDelta        = time.Minute * 5
 	DefaultSubqueryStepInterval = time.Minute
 	DefaultMaxSamples           = 50000000
+	DefaultResultsCacheEntries  = 10000
+	DefaultResultsCacheBytes    = 256 << 20
+	DefaultResultsCacheFresh    = 10 * time.Minute
+	DefaultResultsCacheTTL      = time.Hour
 )
 
 type CommaSeparatedList []string
@@ -39,6 +43,12 @@ type Config struct {
 	LookBackDelta        time.Duration
 	MaxSamples           int
 	MaxPointsPerTs       int64
+
+	ResultsCacheEnabled    bool
+	ResultsCacheMaxEntries uint64
+	ResultsCacheMaxBytes   uint64
+	ResultsCacheFreshness  time.Duration
+	ResultsCacheTTL        time.Duration
 }
 
 func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
@@ -54,6 +64,13 @@ func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
 		"so this also limits the number of samples a query can return.")
 	fs.Int64Var(&cfg.MaxPointsPerTs, "metrics.promql.max-points-per-ts", 11000, "Maximum number of points per time-series in a query-range request. "+
 		"This calculation is an estimation, that happens as (start - end)/step where start and end are the 'start' and 'end' timestamps of the query_range.")
+	fs.BoolVar(&cfg.ResultsCacheEnabled, "metrics.promql.results-cache.enabled", false, "Cache the results of '/api/v1/query_range' requests. Queries are split by day and aligned to their step, "+
+		"and the results older than the freshness window are reused by the requests of the same query, step and tenants.")
+	fs.Uint64Var(&cfg.ResultsCacheMaxEntries, "metrics.promql.results-cache.max-entries", DefaultResultsCacheEntries, "Maximum number of query and day entries kept in the in-memory results cache.")
+	fs.Uint64Var(&cfg.ResultsCacheMaxBytes, "metrics.promql.results-cache.max-bytes", DefaultResultsCacheBytes, "Maximum estimated size in bytes of the results kept in the in-memory results cache. 0 is unbounded.")
+	fs.DurationVar(&cfg.ResultsCacheFreshness, "metrics.promql.results-cache.freshness", DefaultResultsCacheFresh, "Results more recent than this window are not cached, as samples may still be ingested for them. "+
+		"It must not be shorter than metrics.max-sample-age and metrics.out-of-order-window.")
+	fs.DurationVar(&cfg.ResultsCacheTTL, "metrics.promql.results-cache.ttl", DefaultResultsCacheTTL, "Time after which cached results are evaluated again, bounding how long results changed by other connectors, e.g. by deletes, are served. 0 keeps them until evicted.")
 	return cfg
 }
 
@@ -69,5 +86,14 @@ func Validate(cfg *Config) error {
 			return fmt.Errorf("invalid feature: %s", f)
 		}
 	}
+	if cfg.ResultsCacheEnabled && cfg.ResultsCacheMaxEntries == 0 {
+		return fmt.Errorf("metrics.promql.results-cache.max-entries must be positive when the results cache is enabled")
+	}
+	if cfg.ResultsCacheFreshness < 0 {
+		return fmt.Errorf("metrics.promql.results-cache.freshness must not be negative")
+	}
+	if cfg.ResultsCacheTTL < 0 {
+		return fmt.Errorf("metrics.promql.results-cache.ttl must not be negative")
+	}
 	return nil
 }
@@ -48,17 +48,24 @@ func (q queryable) newSamplesQuerier(ctx context.Context, mint, maxt int64) *sam
 	}
 }
 
-func (q samplesQuerier) LabelValues(name string) ([]string, storage.Warnings, error) {
-	lVals, err := q.labelsReader.LabelValues(name)
+func (q samplesQuerier) LabelValues(name string, hints *promql.LabelHints, matchers ...*labels.Matcher) ([]string, storage.Warnings, error) {
+	lVals, err := q.labelsReader.LabelValues(q.ctx, name, q.labelsQuery(hints, matchers))
 	return lVals, nil, err
 }
 
-func (q samplesQuerier) LabelNames(_ ...*labels.Matcher) ([]string, storage.Warnings, error) {
-	// todo: implement labels matcher
-	lNames, err := q.labelsReader.LabelNames()
+func (q samplesQuerier) LabelNames(hints *promql.LabelHints, matchers ...*labels.Matcher) ([]string, storage.Warnings, error) {
+	lNames, err := q.labelsReader.LabelNames(q.ctx, q.labelsQuery(hints, matchers))
 	return lNames, nil, err
 }
 
+func (q samplesQuerier) labelsQuery(hints *promql.LabelHints, matchers []*labels.Matcher) lreader.LabelsQuery {
+	lq := lreader.LabelsQuery{Matchers: matchers, Start: q.mint, End: q.maxt}
+	if hints != nil {
+		lq.Limit = hints.Limit
+	}
+	return lq
+}
+
 func (q *samplesQuerier) Close() {
 	for _, ss := range q.seriesSets {
 		ss.Close()
@@ -0,0 +1,307 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+// Package resultscache caches the results of range queries. Queries are split
+// by day, and the results of the days older than the freshness window are kept
+// as extents, so that only the missing parts of a range are evaluated again.
+package resultscache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/timescale/promscale/pkg/promql"
+)
+
+const day = int64(24 * time.Hour / time.Millisecond)
+
+// Extent is the result of a range query between Start and End, in
+// milliseconds and both included.
+type Extent struct {
+	Start, End int64
+	Matrix     promql.Matrix
+}
+
+// Backend stores the extents of the results of a query for a day.
+type Backend interface {
+	// Fetch returns the extents stored for the key, sorted by start time.
+	Fetch(key string) ([]Extent, bool)
+	// Store replaces the extents of the key.
+	Store(key string, extents []Extent)
+	// Clear removes the extents of all the keys.
+	Clear()
+}
+
+// QueryFunc evaluates a range query between start and end, in milliseconds
+// and both included.
+type QueryFunc func(start, end int64) (promql.Matrix, storage.Warnings, error)
+
+// Cache serves range queries from the cached extents of their results.
+type Cache struct {
+	backend   Backend
+	freshness time.Duration
+	now       func() time.Time
+}
+
+// New returns a cache storing the extents in the backend. Results of the last
+// freshness window are never cached, as samples may still be ingested for it.
+func New(backend Backend, freshness time.Duration) *Cache {
+	return &Cache{backend: backend, freshness: freshness, now: time.Now}
+}
+
+// Invalidate drops all the cached results, e.g. after data has been deleted.
+func (c *Cache) Invalidate() {
+	c.backend.Clear()
+}
+
+// Key returns the cache key of a range query run by a principal restricted to
+// the given tenants, or by an unrestricted one. Restricted principals never
+// share the results of unrestricted ones, even without tenants.
+func Key(query string, step time.Duration, tenants []string, restricted bool) string {
+	scope := "u:"
+	if restricted {
+		sorted := append([]string(nil), tenants...)
+		sort.Strings(sorted)
+		scope = fmt.Sprintf("r:%q", sorted)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s", step.Milliseconds(), scope, query)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Cacheable returns true if the results of the range query can be split and
+// cached. Queries using the start() and end() @ modifiers depend on the range
+// of the request, and those using a negative offset, or an @ modifier within
+// the freshness window, read samples which may not be ingested yet.
+func (c *Cache) Cacheable(query string) bool {
+	expr, err := parser.ParseExpr(query)
+	if err != nil {
+		return false
+	}
+	if t := expr.Type(); t != parser.ValueTypeVector && t != parser.ValueTypeScalar {
+		return false
+	}
+	cutoff := c.now().Add(-c.freshness).UnixMilli()
+	cacheableSelector := func(ts *int64, startOrEnd parser.ItemType, offset time.Duration) bool {
+		if startOrEnd != 0 || offset < 0 {
+			return false
+		}
+		return ts == nil || *ts-offset.Milliseconds() <= cutoff
+	}
+	cacheable := true
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		switch n := node.(type) {
+		case *parser.VectorSelector:
+			cacheable = cacheable && cacheableSelector(n.Timestamp, n.StartOrEnd, n.OriginalOffset)
+		case *parser.SubqueryExpr:
+			cacheable = cacheable && cacheableSelector(n.Timestamp, n.StartOrEnd, n.OriginalOffset)
+		}
+		return nil
+	})
+	return cacheable
+}
+
+// AlignToStep aligns the start and end of a range query, in milliseconds, to
+// its step, so that the requests of a refreshing dashboard evaluate the same
+// timestamps.
+func AlignToStep(start, end, step int64) (int64, int64) {
+	return start - start%step, end - end%step
+}
+
+// interval is a range of evaluation timestamps, both included.
+type interval struct {
+	start, end int64
+}
+
+// QueryRange returns the result of the query of key between start and end, in
+// milliseconds and aligned to step. The days older than the freshness window
+// are read from the cache, and their missing parts are evaluated with query
+// and cached. Results with warnings are not cached.
+func (c *Cache) QueryRange(key string, start, end, step int64, query QueryFunc) (promql.Matrix, storage.Warnings, error) {
+	cutoff := c.now().Add(-c.freshness).UnixMilli()
+	cutoff -= cutoff % step
+
+	type daySplit struct {
+		interval
+		key     string
+		extents []Extent
+		missing []interval
+	}
+	var (
+		splits  []*daySplit
+		toQuery []interval
+		pieces  []promql.Matrix
+	)
+	for s := start; s <= end; {
+		if s > cutoff {
+			toQuery = append(toQuery, interval{s, end})
+			break
+		}
+		e := (s/day+1)*day - 1
+		e -= e % step
+		if e < s {
+			e = s
+		}
+		if e > end {
+			e = end
+		}
+		if e > cutoff {
+			e = cutoff
+		}
+		split := &daySplit{interval: interval{s, e}, key: fmt.Sprintf("%s:%d", key, s/day)}
+		split.extents, _ = c.backend.Fetch(split.key)
+		split.missing = missingIntervals(split.interval, split.extents, step)
+		for _, ext := range split.extents {
+			pieces = append(pieces, extract(ext.Matrix, s, e))
+		}
+		toQuery = append(toQuery, split.missing...)
+		splits = append(splits, split)
+		s = e + step
+	}
+
+	var warnings storage.Warnings
+	cacheResults := true
+	var results []Extent
+	for _, i := range coalesce(toQuery, step) {
+		m, w, err := query(i.start, i.end)
+		if err != nil {
+			return nil, w, err
+		}
+		warnings = append(warnings, w...)
+		cacheResults = cacheResults && len(w) == 0
+		results = append(results, Extent{Start: i.start, End: i.end, Matrix: m})
+		pieces = append(pieces, m)
+	}
+
+	if cacheResults {
+		for _, split := range splits {
+			if len(split.missing) == 0 {
+				continue
+			}
+			extents := append([]Extent(nil), split.extents...)
+			for _, i := range split.missing {
+				for _, r := range results {
+					if r.Start <= i.start && i.end <= r.End {
+						extents = append(extents, Extent{Start: i.start, End: i.end, Matrix: extract(r.Matrix, i.start, i.end)})
+						break
+					}
+				}
+			}
+			c.backend.Store(split.key, mergeExtents(extents, step))
+		}
+	}
+	return mergeMatrices(pieces...), warnings, nil
+}
+
+// missingIntervals returns the parts of i not covered by the sorted extents.
+func missingIntervals(i interval, extents []Extent, step int64) []interval {
+	var missing []interval
+	next := i.start
+	for _, e := range extents {
+		if e.End < next || e.Start > i.end {
+			continue
+		}
+		if e.Start > next {
+			missing = append(missing, interval{next, e.Start - step})
+		}
+		next = e.End + step
+	}
+	if next <= i.end {
+		missing = append(missing, interval{next, i.end})
+	}
+	return missing
+}
+
+// coalesce joins the adjacent sorted intervals, so that a range missing from
+// the cache over several days is evaluated at once.
+func coalesce(intervals []interval, step int64) []interval {
+	var res []interval
+	for _, i := range intervals {
+		if n := len(res); n > 0 && res[n-1].end+step >= i.start {
+			res[n-1].end = i.end
+			continue
+		}
+		res = append(res, i)
+	}
+	return res
+}
+
+// mergeExtents sorts the extents and joins the adjacent ones.
+func mergeExtents(extents []Extent, step int64) []Extent {
+	sort.Slice(extents, func(i, j int) bool { return extents[i].Start < extents[j].Start })
+	var res []Extent
+	for _, e := range extents {
+		if n := len(res); n > 0 && res[n-1].End+step >= e.Start {
+			last := &res[n-1]
+			if e.End > last.End {
+				last.Matrix = mergeMatrices(last.Matrix, extract(e.Matrix, last.End+1, e.End))
+				last.End = e.End
+			}
+			continue
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+// extract returns a copy of the points of the matrix between start and end.
+func extract(m promql.Matrix, start, end int64) promql.Matrix {
+	res := make(promql.Matrix, 0, len(m))
+	for _, s := range m {
+		lo := sort.Search(len(s.Points), func(i int) bool { return s.Points[i].T >= start })
+		hi := sort.Search(len(s.Points), func(i int) bool { return s.Points[i].T > end })
+		if lo == hi {
+			continue
+		}
+		res = append(res, promql.Series{
+			Metric: s.Metric,
+			Points: append([]promql.Point(nil), s.Points[lo:hi]...),
+		})
+	}
+	return res
+}
+
+// mergeMatrices merges the series of matrices holding disjoint time ranges.
+func mergeMatrices(matrices ...promql.Matrix) promql.Matrix {
+	bySeries := make(map[string]int)
+	var res promql.Matrix
+	for _, m := range matrices {
+		for _, s := range m {
+			key := s.Metric.String()
+			i, ok := bySeries[key]
+			if !ok {
+				bySeries[key] = len(res)
+				res = append(res, promql.Series{Metric: s.Metric, Points: append([]promql.Point(nil), s.Points...)})
+				continue
+			}
+			res[i].Points = append(res[i].Points, s.Points...)
+		}
+	}
+	for _, s := range res {
+		points := s.Points
+		sort.Slice(points, func(i, j int) bool { return points[i].T < points[j].T })
+	}
+	sort.Sort(res)
+	return res
+}
+
+// size estimates the memory used by extents.
+func size(extents []Extent) uint64 {
+	var n uint64
+	for _, e := range extents {
+		for _, s := range e.Matrix {
+			n += uint64(len(s.Points)) * 24
+			for _, l := range s.Metric {
+				n += uint64(len(l.Name) + len(l.Value))
+			}
+		}
+	}
+	return n
+}
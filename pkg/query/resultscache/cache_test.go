@@ -0,0 +1,169 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package resultscache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/timescale/promscale/pkg/promql"
+)
+
+type mapBackend map[string][]Extent
+
+func (b mapBackend) Fetch(key string) ([]Extent, bool) {
+	e, ok := b[key]
+	return e, ok
+}
+
+func (b mapBackend) Store(key string, extents []Extent) {
+	b[key] = extents
+}
+
+func (b mapBackend) Clear() {
+	for k := range b {
+		delete(b, k)
+	}
+}
+
+// testQuery evaluates a query with a series having a point at each step, and
+// another one only before the second day.
+type testQuery struct {
+	step     int64
+	calls    []interval
+	warnings storage.Warnings
+}
+
+func (q *testQuery) query(start, end int64) (promql.Matrix, storage.Warnings, error) {
+	q.calls = append(q.calls, interval{start, end})
+	all := promql.Series{Metric: labels.FromStrings("s", "all")}
+	early := promql.Series{Metric: labels.FromStrings("s", "early")}
+	for t := start; t <= end; t += q.step {
+		all.Points = append(all.Points, promql.Point{T: t, V: float64(t)})
+		if t < day {
+			early.Points = append(early.Points, promql.Point{T: t, V: float64(t)})
+		}
+	}
+	m := promql.Matrix{all}
+	if len(early.Points) > 0 {
+		m = append(m, early)
+	}
+	return m, q.warnings, nil
+}
+
+func TestQueryRange(t *testing.T) {
+	step := int64(time.Hour / time.Millisecond)
+	backend := mapBackend{}
+	c := New(backend, 10*time.Minute)
+	c.now = func() time.Time { return time.UnixMilli(4 * day) }
+	tq := &testQuery{step: step}
+	expected, _, _ := tq.query(0, 4*day)
+	tq.calls = nil
+
+	// A cold cache evaluates the range at once, and caches the days older
+	// than the freshness window.
+	res, _, err := c.QueryRange("k", 0, 3*day-step, step, tq.query)
+	require.NoError(t, err)
+	require.Equal(t, []interval{{0, 3*day - step}}, tq.calls)
+	require.Equal(t, extract(expected, 0, 3*day-step), res)
+	require.Len(t, backend, 3)
+
+	// A cached range is not evaluated again.
+	tq.calls = nil
+	res, _, err = c.QueryRange("k", day, 2*day, step, tq.query)
+	require.NoError(t, err)
+	require.Empty(t, tq.calls)
+	require.Equal(t, extract(expected, day, 2*day), res)
+
+	// Only the parts missing from the cache and the fresh ones are evaluated.
+	tq.calls = nil
+	res, _, err = c.QueryRange("k", 0, 4*day, step, tq.query)
+	require.NoError(t, err)
+	require.Equal(t, []interval{{3 * day, 4 * day}}, tq.calls)
+	require.Equal(t, expected, res)
+	require.Equal(t, []Extent{{Start: 3 * day, End: 4*day - step, Matrix: extract(expected, 3*day, 4*day-step)}}, backend["k:3"])
+
+	// The fresh window is never cached.
+	tq.calls = nil
+	_, _, err = c.QueryRange("k", 4*day, 4*day, step, tq.query)
+	require.NoError(t, err)
+	require.Equal(t, []interval{{4 * day, 4 * day}}, tq.calls)
+	require.Len(t, backend, 4)
+}
+
+func TestQueryRangeMergesExtents(t *testing.T) {
+	step := int64(time.Minute / time.Millisecond)
+	backend := mapBackend{}
+	c := New(backend, 0)
+	c.now = func() time.Time { return time.UnixMilli(10 * day) }
+	tq := &testQuery{step: step}
+
+	for _, i := range []interval{{10 * step, 20 * step}, {30 * step, 40 * step}} {
+		_, _, err := c.QueryRange("k", i.start, i.end, step, tq.query)
+		require.NoError(t, err)
+	}
+	require.Len(t, backend["k:0"], 2)
+
+	tq.calls = nil
+	res, _, err := c.QueryRange("k", 0, 50*step, step, tq.query)
+	require.NoError(t, err)
+	require.Equal(t, []interval{{0, 9 * step}, {21 * step, 29 * step}, {41 * step, 50 * step}}, tq.calls)
+	expected, _, _ := tq.query(0, 50*step)
+	require.Equal(t, expected, res)
+	require.Len(t, backend["k:0"], 1)
+	require.Equal(t, Extent{Start: 0, End: 50 * step, Matrix: expected}, backend["k:0"][0])
+}
+
+func TestQueryRangeWithWarningsIsNotCached(t *testing.T) {
+	step := int64(time.Minute / time.Millisecond)
+	backend := mapBackend{}
+	c := New(backend, 0)
+	c.now = func() time.Time { return time.UnixMilli(10 * day) }
+	tq := &testQuery{step: step, warnings: storage.Warnings{storage.ErrDuplicateSampleForTimestamp}}
+
+	_, warnings, err := c.QueryRange("k", 0, 10*step, step, tq.query)
+	require.NoError(t, err)
+	require.Equal(t, tq.warnings, warnings)
+	require.Empty(t, backend)
+}
+
+func TestCacheable(t *testing.T) {
+	c := New(mapBackend{}, 10*time.Minute)
+	c.now = func() time.Time { return time.Unix(7200, 0) }
+	for query, cacheable := range map[string]bool{
+		`rate(up[5m])`:                     true,
+		`up @ 1000`:                        true,
+		`up @ 6600`:                        true,
+		`up @ 6601`:                        false,
+		`up @ 7000 offset 10m`:             true,
+		`max_over_time(up[1h:] @ 7000)`:    false,
+		`up offset 1h`:                     true,
+		`up @ end()`:                       false,
+		`max_over_time(up[1h:] @ start())`: false,
+		`up offset -1h`:                    false,
+		`"string"`:                         false,
+		`up{`:                              false,
+	} {
+		require.Equal(t, cacheable, c.Cacheable(query), query)
+	}
+}
+
+func TestKey(t *testing.T) {
+	unrestricted := Key("up", time.Minute, nil, false)
+	require.NotEqual(t, unrestricted, Key("up", time.Minute, []string{}, true))
+	require.NotEqual(t, unrestricted, Key("up", time.Minute, []string{"a"}, true))
+	require.Equal(t, Key("up", time.Minute, []string{"a", "b"}, true), Key("up", time.Minute, []string{"b", "a"}, true))
+	require.NotEqual(t, unrestricted, Key("up", time.Second, nil, false))
+}
+
+func TestAlignToStep(t *testing.T) {
+	start, end := AlignToStep(1001, 5999, 1000)
+	require.Equal(t, int64(1000), start)
+	require.Equal(t, int64(5000), end)
+}
@@ -0,0 +1,95 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package resultscache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	key      string
+	extents  []Extent
+	size     uint64
+	storedAt time.Time
+}
+
+// memoryBackend keeps the extents in memory, evicting the least recently used
+// entries beyond its bounds.
+type memoryBackend struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List
+	sizeBytes  uint64
+	maxEntries uint64
+	maxBytes   uint64
+	ttl        time.Duration
+	now        func() time.Time
+}
+
+// NewMemoryBackend returns a backend keeping the extents of up to maxEntries
+// queries and days, using up to about maxBytes, in memory. Entries expire
+// after ttl, so that results changed behind the back of the cache, e.g. by
+// samples ingested late or by deletes from another connector, are evaluated
+// again eventually. A zero maxBytes or ttl is unbounded.
+func NewMemoryBackend(maxEntries, maxBytes uint64, ttl time.Duration) Backend {
+	return &memoryBackend{
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ttl:        ttl,
+		now:        time.Now,
+	}
+}
+
+func (b *memoryBackend) Fetch(key string) ([]Extent, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	elem, ok := b.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*memoryEntry)
+	if b.ttl > 0 && b.now().Sub(entry.storedAt) > b.ttl {
+		b.remove(elem)
+		return nil, false
+	}
+	b.lru.MoveToFront(elem)
+	return entry.extents, true
+}
+
+func (b *memoryBackend) Store(key string, extents []Extent) {
+	entry := &memoryEntry{key: key, extents: extents, size: size(extents) + uint64(len(key)), storedAt: b.now()}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if elem, ok := b.entries[key]; ok {
+		b.remove(elem)
+	}
+	if b.maxBytes > 0 && entry.size > b.maxBytes {
+		return
+	}
+	b.entries[key] = b.lru.PushFront(entry)
+	b.sizeBytes += entry.size
+	for uint64(b.lru.Len()) > b.maxEntries || (b.maxBytes > 0 && b.sizeBytes > b.maxBytes) {
+		b.remove(b.lru.Back())
+	}
+}
+
+func (b *memoryBackend) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = make(map[string]*list.Element)
+	b.lru.Init()
+	b.sizeBytes = 0
+}
+
+func (b *memoryBackend) remove(elem *list.Element) {
+	entry := b.lru.Remove(elem).(*memoryEntry)
+	delete(b.entries, entry.key)
+	b.sizeBytes -= entry.size
+}
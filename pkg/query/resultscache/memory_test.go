@@ -0,0 +1,78 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package resultscache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/timescale/promscale/pkg/promql"
+)
+
+func testExtents(points int) []Extent {
+	s := promql.Series{Metric: labels.FromStrings("a", "b")}
+	for i := 0; i < points; i++ {
+		s.Points = append(s.Points, promql.Point{T: int64(i), V: 1})
+	}
+	return []Extent{{Start: 0, End: int64(points - 1), Matrix: promql.Matrix{s}}}
+}
+
+func TestMemoryBackendBounds(t *testing.T) {
+	// Each entry holds 10 points, i.e. 240 bytes, plus 4 bytes of labels and key.
+	b := NewMemoryBackend(10, 800, 0)
+	for _, key := range []string{"k1", "k2", "k3"} {
+		b.Store(key, testExtents(10))
+	}
+	_, ok := b.Fetch("k1")
+	require.True(t, ok)
+
+	// Beyond the maximum size, the least recently used entry is evicted.
+	b.Store("k4", testExtents(10))
+	for key, expected := range map[string]bool{"k1": true, "k2": false, "k3": true, "k4": true} {
+		_, ok = b.Fetch(key)
+		require.Equal(t, expected, ok, key)
+	}
+
+	// Beyond the maximum number of entries as well.
+	b = NewMemoryBackend(2, 0, 0)
+	for _, key := range []string{"k1", "k2", "k3"} {
+		b.Store(key, testExtents(1))
+	}
+	_, ok = b.Fetch("k1")
+	require.False(t, ok)
+
+	// Entries larger than the maximum size are not stored.
+	b = NewMemoryBackend(3, 100, 0)
+	b.Store("k1", testExtents(10))
+	_, ok = b.Fetch("k1")
+	require.False(t, ok)
+}
+
+func TestMemoryBackendTTL(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewMemoryBackend(10, 0, time.Hour).(*memoryBackend)
+	b.now = func() time.Time { return now }
+
+	b.Store("k1", testExtents(1))
+	now = now.Add(time.Hour)
+	_, ok := b.Fetch("k1")
+	require.True(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok = b.Fetch("k1")
+	require.False(t, ok)
+	require.Equal(t, uint64(0), b.sizeBytes)
+}
+
+func TestMemoryBackendClear(t *testing.T) {
+	b := NewMemoryBackend(10, 0, 0)
+	b.Store("k1", testExtents(1))
+	b.Clear()
+	_, ok := b.Fetch("k1")
+	require.False(t, ok)
+}
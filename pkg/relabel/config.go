@@ -0,0 +1,69 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package relabel
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	promrelabel "github.com/prometheus/prometheus/model/relabel"
+	"gopkg.in/yaml.v2"
+)
+
+// Config holds the configuration of ingest-time relabeling. The rules are
+// nested YAML lists, which the flat flags of the main configuration file cannot
+// hold, hence they are read from their own file, like the tenant limits and the
+// Prometheus rules configuration.
+type Config struct {
+	// ConfigFile is the path to the YAML file with the relabeling rules.
+	ConfigFile string
+}
+
+func ParseFlags(fs *flag.FlagSet, cfg *Config) *Config {
+	fs.StringVar(&cfg.ConfigFile, "relabel.config-file", "", "Path to a YAML file with the relabeling rules applied to the series of incoming write requests, "+
+		"and to the resource and span attributes of incoming traces. The rules follow the Prometheus relabel_config format. "+
+		"The file is read again when the configuration is reloaded. Ingested data is not relabeled if not set.")
+	return cfg
+}
+
+// Rules are the relabeling rules of metrics and traces.
+type Rules struct {
+	Metrics MetricRules `yaml:"metrics"`
+	Traces  TraceRules  `yaml:"traces"`
+}
+
+// MetricRules are applied to the labels of each series.
+type MetricRules struct {
+	RelabelConfigs []*promrelabel.Config `yaml:"relabel_configs"`
+}
+
+// TraceRules are applied to the attributes of each resource and span. Attribute
+// names are matched once sanitized as label names, e.g. `service.name` is
+// matched as `service_name`.
+type TraceRules struct {
+	ResourceRelabelConfigs []*promrelabel.Config `yaml:"resource_relabel_configs"`
+	SpanRelabelConfigs     []*promrelabel.Config `yaml:"span_relabel_configs"`
+}
+
+// LoadRules reads the relabeling rules from a YAML file.
+func LoadRules(path string) (Rules, error) {
+	var rules Rules
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return rules, fmt.Errorf("read relabel config file: %w", err)
+	}
+	if err = yaml.UnmarshalStrict(contents, &rules); err != nil {
+		return rules, fmt.Errorf("parse relabel config file: %w", err)
+	}
+	for _, configs := range [][]*promrelabel.Config{rules.Metrics.RelabelConfigs, rules.Traces.ResourceRelabelConfigs, rules.Traces.SpanRelabelConfigs} {
+		for i, c := range configs {
+			if c == nil {
+				return rules, fmt.Errorf("empty relabel config at index %d", i)
+			}
+		}
+	}
+	return rules, nil
+}
@@ -0,0 +1,33 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package relabel
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/timescale/promscale/pkg/util"
+)
+
+var (
+	droppedSeries = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "relabel",
+			Name:      "dropped_series_total",
+			Help:      "Total number of series dropped from write requests by the relabeling rules.",
+		},
+	)
+	droppedSpans = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "relabel",
+			Name:      "dropped_spans_total",
+			Help:      "Total number of spans dropped by the relabeling rules.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(droppedSeries, droppedSpans)
+}
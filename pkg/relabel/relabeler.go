@@ -0,0 +1,167 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package relabel
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/prometheus/model/labels"
+	promrelabel "github.com/prometheus/prometheus/model/relabel"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/timescale/promscale/pkg/otlp"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+// Relabeler applies the relabeling rules to the series of write requests and to
+// the attributes of traces before they are ingested. Rules can be changed while
+// data is being ingested.
+type Relabeler struct {
+	mu    sync.RWMutex
+	rules Rules
+}
+
+// NewRelabeler creates a new Relabeler with the given rules.
+func NewRelabeler(rules Rules) *Relabeler {
+	return &Relabeler{rules: rules}
+}
+
+// SetRules replaces the relabeling rules, e.g. on configuration reload.
+func (r *Relabeler) SetRules(rules Rules) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = rules
+}
+
+func (r *Relabeler) getRules() Rules {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rules
+}
+
+// Process implements the Preprocessor interface. Series dropped by the rules
+// are removed from the request along with their samples, exemplars and
+// histograms, as are series left without a metric name.
+func (r *Relabeler) Process(_ *http.Request, wr *prompb.WriteRequest) error {
+	configs := r.getRules().Metrics.RelabelConfigs
+	if len(configs) == 0 {
+		return nil
+	}
+	kept := 0
+	for i := range wr.Timeseries {
+		ts := wr.Timeseries[i]
+		lset := make(labels.Labels, 0, len(ts.Labels))
+		for _, l := range ts.Labels {
+			lset = append(lset, labels.Label{Name: l.Name, Value: l.Value})
+		}
+		lset = promrelabel.Process(lset, configs...)
+		if lset == nil || lset.Get(labels.MetricName) == "" {
+			continue
+		}
+		ts.Labels = ts.Labels[:0]
+		for _, l := range lset {
+			ts.Labels = append(ts.Labels, prompb.Label{Name: l.Name, Value: l.Value})
+		}
+		wr.Timeseries[kept] = ts
+		kept++
+	}
+	if dropped := len(wr.Timeseries) - kept; dropped > 0 {
+		droppedSeries.Add(float64(dropped))
+	}
+	wr.Timeseries = wr.Timeseries[:kept]
+	return nil
+}
+
+// ProcessTraces implements the TraceProcessor interface. Resources dropped by
+// the resource rules are removed along with all their spans, and spans dropped
+// by the span rules are removed from their resource.
+func (r *Relabeler) ProcessTraces(_ context.Context, traces ptrace.Traces) error {
+	rules := r.getRules().Traces
+	if len(rules.ResourceRelabelConfigs) == 0 && len(rules.SpanRelabelConfigs) == 0 {
+		return nil
+	}
+	dropped := 0
+	traces.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+		if !relabelAttributes(rs.Resource().Attributes(), rules.ResourceRelabelConfigs) {
+			dropped += countSpans(rs)
+			return true
+		}
+		if len(rules.SpanRelabelConfigs) == 0 {
+			return false
+		}
+		scopeSpans := rs.ScopeSpans()
+		for i := 0; i < scopeSpans.Len(); i++ {
+			scopeSpans.At(i).Spans().RemoveIf(func(span ptrace.Span) bool {
+				if relabelAttributes(span.Attributes(), rules.SpanRelabelConfigs) {
+					return false
+				}
+				dropped++
+				return true
+			})
+		}
+		return false
+	})
+	if dropped > 0 {
+		droppedSpans.Add(float64(dropped))
+	}
+	return nil
+}
+
+func countSpans(rs ptrace.ResourceSpans) int {
+	n := 0
+	scopeSpans := rs.ScopeSpans()
+	for i := 0; i < scopeSpans.Len(); i++ {
+		n += scopeSpans.At(i).Spans().Len()
+	}
+	return n
+}
+
+// relabelAttributes applies the rules to the attributes, seen as labels named
+// after the sanitized attribute names, and returns false if they are dropped.
+// Attributes whose value is left unchanged keep their type, while changed and
+// added attributes become strings. Attributes with an empty value are left as
+// they are, as labels cannot be empty.
+func relabelAttributes(attrs pcommon.Map, configs []*promrelabel.Config) bool {
+	if len(configs) == 0 {
+		return true
+	}
+	keys := make(map[string]string, attrs.Len())
+	lset := make(labels.Labels, 0, attrs.Len())
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		name := otlp.SanitizeLabelName(k)
+		value := v.AsString()
+		if _, ok := keys[name]; ok || value == "" {
+			return true
+		}
+		keys[name] = k
+		lset = append(lset, labels.Label{Name: name, Value: value})
+		return true
+	})
+	original := lset.Map()
+
+	lset = promrelabel.Process(lset, configs...)
+	if lset == nil {
+		return false
+	}
+	relabeled := lset.Map()
+	for name, k := range keys {
+		if _, ok := relabeled[name]; !ok {
+			attrs.Remove(k)
+		}
+	}
+	for name, value := range relabeled {
+		k, ok := keys[name]
+		if !ok {
+			k = name
+		} else if value == original[name] {
+			continue
+		}
+		attrs.PutString(k, value)
+	}
+	return true
+}
@@ -0,0 +1,144 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package relabel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+const testRules = `
+metrics:
+  relabel_configs:
+    - source_labels: [__name__]
+      regex: go_gc_.*
+      action: drop
+    - regex: pod_uid
+      action: labeldrop
+    - source_labels: [instance]
+      regex: "(.*):.*"
+      target_label: host
+      replacement: "$1"
+      action: replace
+    - source_labels: [host]
+      modulus: 4
+      target_label: shard
+      action: hashmod
+traces:
+  resource_relabel_configs:
+    - source_labels: [service_name]
+      regex: healthcheck
+      action: drop
+    - regex: k8s_pod_uid
+      action: labeldrop
+  span_relabel_configs:
+    - source_labels: [http_target]
+      regex: /health
+      action: drop
+    - source_labels: [http_target]
+      regex: "/users/.*"
+      target_label: http_target
+      replacement: /users/:id
+      action: replace
+`
+
+func loadTestRules(t *testing.T, contents string) Rules {
+	path := filepath.Join(t.TempDir(), "relabel.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+	rules, err := LoadRules(path)
+	require.NoError(t, err)
+	return rules
+}
+
+func series(lbls ...string) prompb.TimeSeries {
+	ts := prompb.TimeSeries{Samples: []prompb.Sample{{Timestamp: 1, Value: 1}}}
+	for i := 0; i < len(lbls); i += 2 {
+		ts.Labels = append(ts.Labels, prompb.Label{Name: lbls[i], Value: lbls[i+1]})
+	}
+	return ts
+}
+
+func TestLoadRules(t *testing.T) {
+	_, err := LoadRules(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "relabel.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("metrics:\n  relabel_configs:\n    - action: replace\n"), 0600))
+	_, err = LoadRules(path)
+	require.Error(t, err, "replace requires a target label")
+
+	require.NoError(t, os.WriteFile(path, []byte("metric_relabel_configs: []\n"), 0600))
+	_, err = LoadRules(path)
+	require.Error(t, err, "unknown fields are rejected")
+}
+
+func TestRelabelMetrics(t *testing.T) {
+	r := NewRelabeler(loadTestRules(t, testRules))
+	wr := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{
+		series("__name__", "go_gc_duration_seconds", "instance", "a:9090"),
+		series("__name__", "up", "instance", "a:9090", "pod_uid", "1234"),
+		series("instance", "b:9090"),
+	}}
+	require.NoError(t, r.Process(nil, wr))
+	require.Len(t, wr.Timeseries, 1, "dropped series and series without a name are removed")
+	require.Equal(t, []prompb.Label{
+		{Name: "__name__", Value: "up"},
+		{Name: "host", Value: "a"},
+		{Name: "instance", Value: "a:9090"},
+		{Name: "shard", Value: "1"},
+	}, wr.Timeseries[0].Labels)
+	require.Len(t, wr.Timeseries[0].Samples, 1)
+
+	// Rules are replaced on reload.
+	r.SetRules(Rules{})
+	wr = &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{series("__name__", "go_gc_duration_seconds")}}
+	require.NoError(t, r.Process(nil, wr))
+	require.Len(t, wr.Timeseries, 1)
+}
+
+func TestRelabelTraces(t *testing.T) {
+	r := NewRelabeler(loadTestRules(t, testRules))
+	traces := ptrace.NewTraces()
+
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutString("service.name", "api")
+	rs.Resource().Attributes().PutString("k8s.pod.uid", "1234")
+	rs.Resource().Attributes().PutInt("process.pid", 42)
+	spans := rs.ScopeSpans().AppendEmpty().Spans()
+	span := spans.AppendEmpty()
+	span.SetName("health")
+	span.Attributes().PutString("http.target", "/health")
+	span = spans.AppendEmpty()
+	span.SetName("get user")
+	span.Attributes().PutString("http.target", "/users/42")
+	span.Attributes().PutInt("http.status_code", 200)
+
+	rs = traces.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutString("service.name", "healthcheck")
+	rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+
+	require.NoError(t, r.ProcessTraces(context.Background(), traces))
+	require.Equal(t, 1, traces.ResourceSpans().Len())
+	require.Equal(t, 1, traces.SpanCount())
+
+	rs = traces.ResourceSpans().At(0)
+	require.Equal(t, map[string]interface{}{
+		"service.name": "api",
+		"process.pid":  int64(42),
+	}, rs.Resource().Attributes().AsRaw())
+	span = rs.ScopeSpans().At(0).Spans().At(0)
+	require.Equal(t, "get user", span.Name())
+	require.Equal(t, map[string]interface{}{
+		"http.target":      "/users/:id",
+		"http.status_code": int64(200),
+	}, span.Attributes().AsRaw())
+}
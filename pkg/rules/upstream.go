@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"net/http"
 	"time"
-	"unsafe"
 
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/notifier"
@@ -36,7 +35,7 @@ func engineQueryFunc(engine *promscale_promql.Engine, q promscale_promql.Queryab
 		}
 		switch v := res.Value.(type) {
 		case promscale_promql.Vector:
-			return yoloVector(&v), nil
+			return toPrometheusVector(v)
 		case promscale_promql.Scalar:
 			return prometheus_promql.Vector{prometheus_promql.Sample{
 				Point:  prometheus_promql.Point(v),
@@ -48,17 +47,24 @@ func engineQueryFunc(engine *promscale_promql.Engine, q promscale_promql.Queryab
 	}
 }
 
-// Note: This is a compile-time test to make sure that sizes of both vectors are the same.
-// If the upstream vector changes, then this will block compilation. This protects the yoloVector
-// function from breaking with unexpected changes in Prometheus mod version.
-// We also have a unit test for this that compares the equality of these two structs.
-var _ = [1]bool{}[unsafe.Sizeof(promscale_promql.Vector{})-unsafe.Sizeof(prometheus_promql.Vector{})] // #nosec
-
-// My guess is this should be way faster than looping through individual samples
-// and converting into Prometheus Vector type. This lets us convert the type with
-// very less processing.
-func yoloVector(v *promscale_promql.Vector) prometheus_promql.Vector {
-	return *(*prometheus_promql.Vector)(unsafe.Pointer(v)) // #nosec
+// toPrometheusVector converts the vector returned by Promscale's PromQL engine into
+// the upstream Prometheus vector. Promscale's points additionally carry native
+// histograms, which upstream rules cannot evaluate, hence vectors of histograms
+// are rejected rather than recorded as their float value.
+func toPrometheusVector(v promscale_promql.Vector) (prometheus_promql.Vector, error) {
+	if v == nil {
+		return nil, nil
+	}
+	promv := make(prometheus_promql.Vector, len(v))
+	for i := range v {
+		if v[i].H != nil {
+			return nil, fmt.Errorf("rule result contains the native histogram of series %s, rules can only evaluate floats", v[i].Metric)
+		}
+		promv[i].Metric = v[i].Metric
+		promv[i].T = v[i].T
+		promv[i].V = v[i].V
+	}
+	return promv, nil
 }
 
 type sender interface {
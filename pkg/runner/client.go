@@ -19,6 +19,7 @@ import (
 	"github.com/timescale/promscale/pkg/pgmodel"
 	"github.com/timescale/promscale/pkg/pgmodel/common/extension"
 	"github.com/timescale/promscale/pkg/pgmodel/common/schema"
+	"github.com/timescale/promscale/pkg/relabel"
 	"github.com/timescale/promscale/pkg/tenancy"
 	"github.com/timescale/promscale/pkg/util"
 	"github.com/timescale/promscale/pkg/version"
@@ -163,13 +164,33 @@ func CreateClient(r prometheus.Registerer, cfg *Config) (*pgclient.Client, error
 			return nil, fmt.Errorf("new tenancy: %w", err)
 		}
 		cfg.APICfg.MultiTenancy = multiTenancy
+
+		if cfg.TenancyCfg.LimitsFile != "" {
+			limits, err := tenancy.LoadLimitsConfig(cfg.TenancyCfg.LimitsFile)
+			if err != nil {
+				return nil, fmt.Errorf("load tenant limits: %w", err)
+			}
+			cfg.APICfg.TenantLimits = tenancy.NewLimiter(limits, tenancy.DefaultActiveSeriesWindow, cfg.TenancyCfg.ValidTenantsList)
+		}
+	}
+
+	if cfg.RelabelCfg.ConfigFile != "" {
+		rules, err := relabel.LoadRules(cfg.RelabelCfg.ConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("load relabel rules: %w", err)
+		}
+		cfg.APICfg.Relabeler = relabel.NewRelabeler(rules)
 	}
 
 	if cfg.DatasetConfig != "" {
-		err = ApplyDatasetConfig(conn, cfg.DatasetConfig)
+		datasetCfg, err := dataset.NewConfig(cfg.DatasetConfig)
 		if err != nil {
 			return nil, fmt.Errorf("error applying dataset configuration: %w", err)
 		}
+		if err = datasetCfg.Apply(conn); err != nil {
+			return nil, fmt.Errorf("error applying dataset configuration: %w", err)
+		}
+		cfg.PgmodelCfg.Dataset = &datasetCfg
 	}
 
 	// client has to be initiated after migrate since migrate
@@ -235,6 +256,17 @@ func ApplyDatasetConfig(conn *pgx.Conn, cfgFilename string) error {
 	return cfg.Apply(conn)
 }
 
+// reloadDatasetConfig applies the dataset configuration parsed on startup again.
+// This reconciles the per-metric overrides with the metrics in the database.
+func reloadDatasetConfig(datasetCfg *dataset.Config, client *pgclient.Client) error {
+	conn, err := client.MaintenanceConnection().Acquire(context.Background())
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+	return datasetCfg.Apply(conn.Conn())
+}
+
 func compileAnchoredRegexString(s string) (*regexp.Regexp, error) {
 	r, err := regexp.Compile("^(?:" + s + ")$")
 	if err != nil {
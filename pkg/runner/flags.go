@@ -18,8 +18,10 @@ import (
 	jaegerStore "github.com/timescale/promscale/pkg/jaeger/store"
 	"github.com/timescale/promscale/pkg/limits"
 	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/otlp"
 	"github.com/timescale/promscale/pkg/pgclient"
 	"github.com/timescale/promscale/pkg/query"
+	"github.com/timescale/promscale/pkg/relabel"
 	"github.com/timescale/promscale/pkg/rules"
 	"github.com/timescale/promscale/pkg/tenancy"
 	"github.com/timescale/promscale/pkg/tracer"
@@ -41,6 +43,8 @@ type Config struct {
 	PromQLCfg                   query.Config
 	RulesCfg                    rules.Config
 	TracingCfg                  jaegerStore.Config
+	OTLPCfg                     otlp.Config
+	RelabelCfg                  relabel.Config
 	VacuumCfg                   vacuum.Config
 	ConfigFile                  string
 	DatasetConfig               string
@@ -136,6 +140,8 @@ func ParseFlags(cfg *Config, args []string) (*Config, error) {
 	tenancy.ParseFlags(fs, &cfg.TenancyCfg)
 	query.ParseFlags(fs, &cfg.PromQLCfg)
 	jaegerStore.ParseFlags(fs, &cfg.TracingCfg)
+	otlp.ParseFlags(fs, &cfg.OTLPCfg)
+	relabel.ParseFlags(fs, &cfg.RelabelCfg)
 	rules.ParseFlags(fs, &cfg.RulesCfg)
 	vacuum.ParseFlags(fs, &cfg.VacuumCfg)
 
@@ -183,6 +189,9 @@ func ParseFlags(cfg *Config, args []string) (*Config, error) {
 	if (cfg.TLSCertFile != "") != (cfg.TLSKeyFile != "") {
 		return nil, fmt.Errorf("both TLS Ceriticate File and TLS Key File need to be provided for a valid TLS configuration")
 	}
+	if cfg.AuthConfig.ClientTLSEnabled() && cfg.TLSCertFile == "" {
+		return nil, fmt.Errorf("client certificate verification requires a TLS Certificate File and a TLS Key File")
+	}
 
 	corsOriginRegex, err := compileAnchoredRegexString(corsOriginFlag)
 	if err != nil {
@@ -217,6 +226,15 @@ func ParseFlags(cfg *Config, args []string) (*Config, error) {
 		if flagset["metrics.high-availability"] && cfg.APICfg.HighAvailability {
 			return nil, fmt.Errorf("cannot run Promscale in both HA and read-only mode")
 		}
+		if cfg.TracingCfg.HighAvailability {
+			return nil, fmt.Errorf("cannot run Promscale in both tracing HA and read-only mode")
+		}
+		if cfg.PgmodelCfg.SpoolConfig.Enabled() {
+			return nil, fmt.Errorf("cannot spool writes in read-only mode")
+		}
+		if cfg.RelabelCfg.ConfigFile != "" {
+			return nil, fmt.Errorf("cannot relabel writes in read-only mode")
+		}
 		cfg.Migrate = false
 		cfg.StopAfterMigrate = false
 		cfg.UseVersionLease = false
@@ -224,7 +242,7 @@ func ParseFlags(cfg *Config, args []string) (*Config, error) {
 		cfg.UpgradeExtensions = false
 	}
 
-	if cfg.APICfg.HighAvailability {
+	if cfg.APICfg.HighAvailability || cfg.TracingCfg.HighAvailability {
 		cfg.PgmodelCfg.UsesHA = true
 	}
 	return cfg, nil
@@ -246,9 +264,15 @@ func validate(cfg *Config) error {
 	if err := query.Validate(&cfg.PromQLCfg); err != nil {
 		return fmt.Errorf("error validating PromQL configuration: %w", err)
 	}
+	if err := validateResultsCacheFreshness(cfg); err != nil {
+		return fmt.Errorf("error validating PromQL configuration: %w", err)
+	}
 	if err := jaegerStore.Validate(&cfg.TracingCfg); err != nil {
 		return fmt.Errorf("error validating Tracing query configuration: %w", err)
 	}
+	if err := otlp.Validate(&cfg.OTLPCfg); err != nil {
+		return fmt.Errorf("error validating OTLP metrics configuration: %w", err)
+	}
 	if err := tenancy.Validate(&cfg.TenancyCfg); err != nil {
 		return fmt.Errorf("error validating multi-tenancy configuration: %w", err)
 	}
@@ -261,6 +285,22 @@ func validate(cfg *Config) error {
 	return nil
 }
 
+// validateResultsCacheFreshness checks that the results cache does not cache
+// results which can still change through samples ingested in bounds.
+func validateResultsCacheFreshness(cfg *Config) error {
+	if !cfg.PromQLCfg.ResultsCacheEnabled {
+		return nil
+	}
+	freshness := cfg.PromQLCfg.ResultsCacheFreshness
+	if age := cfg.PgmodelCfg.MaxSampleAge; freshness < age {
+		return fmt.Errorf("metrics.promql.results-cache.freshness (%s) must not be shorter than metrics.max-sample-age (%s)", freshness, age)
+	}
+	if window := cfg.PgmodelCfg.OutOfOrderWindow; freshness < window {
+		return fmt.Errorf("metrics.promql.results-cache.freshness (%s) must not be shorter than metrics.out-of-order-window (%s)", freshness, window)
+	}
+	return nil
+}
+
 func addAliases(fs *flag.FlagSet, aliases map[string]string) {
 	for newFlag, flagAlias := range aliases {
 		fs.String(flagAlias, "", fmt.Sprintf(aliasDescFormat, newFlag))
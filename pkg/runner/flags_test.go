@@ -134,6 +134,13 @@ func TestParseFlags(t *testing.T) {
 			},
 			shouldError: true,
 		},
+		{
+			name: "invalid TLS setup, client CA without server certificate",
+			args: []string{
+				"-auth.tls-client-ca-file", "foo",
+			},
+			shouldError: true,
+		},
 		{
 			name: "invalid auth setup",
 			args: []string{
@@ -193,6 +200,27 @@ func TestParseFlags(t *testing.T) {
 				return c
 			},
 		},
+		{
+			name:        "results cache freshness shorter than the out-of-order window",
+			args:        []string{"-metrics.promql.results-cache.enabled", "-metrics.promql.results-cache.freshness", "10m", "-metrics.out-of-order-window", "1h"},
+			shouldError: true,
+		},
+		{
+			name:        "results cache freshness shorter than the maximum sample age",
+			args:        []string{"-metrics.promql.results-cache.enabled", "-metrics.max-sample-age", "1h"},
+			shouldError: true,
+		},
+		{
+			name: "results cache freshness covering the sample bounds",
+			args: []string{"-metrics.promql.results-cache.enabled", "-metrics.promql.results-cache.freshness", "1h", "-metrics.max-sample-age", "1h", "-metrics.out-of-order-window", "30m"},
+			result: func(c Config) Config {
+				c.PromQLCfg.ResultsCacheEnabled = true
+				c.PromQLCfg.ResultsCacheFreshness = time.Hour
+				c.PgmodelCfg.MaxSampleAge = time.Hour
+				c.PgmodelCfg.OutOfOrderWindow = 30 * time.Minute
+				return c
+			},
+		},
 	}
 
 	for _, c := range testCases {
@@ -9,18 +9,22 @@ package runner
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"github.com/oklog/run"
 	"github.com/timescale/promscale/pkg/vacuum"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
 	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 	"go.opentelemetry.io/otel"
 	"google.golang.org/grpc"
@@ -35,13 +39,25 @@ import (
 	"github.com/thanos-io/thanos/pkg/store/storepb"
 
 	"github.com/timescale/promscale/pkg/api"
+	"github.com/timescale/promscale/pkg/auth"
+	"github.com/timescale/promscale/pkg/ha"
+	haClient "github.com/timescale/promscale/pkg/ha/client"
 	jaegerStore "github.com/timescale/promscale/pkg/jaeger/store"
 	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/otlp"
 	"github.com/timescale/promscale/pkg/pgclient"
+	"github.com/timescale/promscale/pkg/pgmodel/cardinality"
+	deletePkg "github.com/timescale/promscale/pkg/pgmodel/delete"
+	"github.com/timescale/promscale/pkg/pgmodel/histogram"
+	"github.com/timescale/promscale/pkg/pgmodel/ingestor"
 	"github.com/timescale/promscale/pkg/pgmodel/ingestor/trace"
+	"github.com/timescale/promscale/pkg/pgmodel/logs"
 	dbMetrics "github.com/timescale/promscale/pkg/pgmodel/metrics/database"
+	"github.com/timescale/promscale/pkg/pgmodel/rollup"
+	"github.com/timescale/promscale/pkg/relabel"
 	"github.com/timescale/promscale/pkg/rules"
 	"github.com/timescale/promscale/pkg/telemetry"
+	"github.com/timescale/promscale/pkg/tenancy"
 	"github.com/timescale/promscale/pkg/thanos"
 	"github.com/timescale/promscale/pkg/tracer"
 	"github.com/timescale/promscale/pkg/util"
@@ -82,6 +98,34 @@ func loggingStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.
 	return err
 }
 
+// grpcMethodScope returns the scope required by the methods of the gRPC servers.
+// Ingestion methods require the write scope, all the other methods the read scope.
+func grpcMethodScope(fullMethod string) (auth.Scope, bool) {
+	switch {
+	case strings.HasPrefix(fullMethod, "/grpc.health.v1.Health/"):
+		return auth.ReadScope, false
+	case strings.HasPrefix(fullMethod, "/opentelemetry.proto.collector."),
+		strings.HasPrefix(fullMethod, "/jaeger.storage.v1.SpanWriterPlugin/"),
+		strings.HasPrefix(fullMethod, "/jaeger.storage.v1.StreamingSpanWriterPlugin/"),
+		strings.HasPrefix(fullMethod, "/jaeger.storage.v1.ArchiveSpanWriterPlugin/"):
+		return auth.WriteScope, true
+	default:
+		return auth.ReadScope, true
+	}
+}
+
+// grpcTLSCredentials returns the TLS credentials of the gRPC servers, verifying
+// client certificates if enabled.
+func grpcTLSCredentials(cfg *Config) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	cfg.AuthConfig.ConfigureClientTLS(tlsConfig)
+	return credentials.NewTLS(tlsConfig), nil
+}
+
 func Run(cfg *Config) error {
 	log.Info("msg", version.Info())
 
@@ -179,13 +223,155 @@ func Run(cfg *Config) error {
 		)
 	}
 
-	jaegerStore := jaegerStore.New(client.ReadOnlyConnection(), client.Inserter(), &cfg.TracingCfg)
+	if !cfg.APICfg.ReadOnly {
+		deleteJobsCtx, stopDeleteJobs := context.WithCancel(context.Background())
+		defer stopDeleteJobs()
+		deleteJobs := deletePkg.NewJobManager(client.ReadOnlyConnection())
+		cfg.APICfg.DeleteJobs = deleteJobs
+
+		group.Add(
+			func() error {
+				log.Info("msg", "Started delete jobs manager")
+				return deleteJobs.Run(deleteJobsCtx)
+			}, func(error) {
+				log.Info("msg", "Stopping delete jobs manager")
+				stopDeleteJobs()
+			},
+		)
+	}
+
+	if !cfg.APICfg.ReadOnly && cfg.PgmodelCfg.RollupConfig.RefreshInterval > 0 {
+		rollupCtx, stopRollups := context.WithCancel(context.Background())
+		defer stopRollups()
+		rollups := rollup.NewMaintainer(client.MaintenanceConnection(), cfg.PgmodelCfg.RollupConfig.RefreshInterval)
+
+		group.Add(
+			func() error {
+				log.Info("msg", "Started rollup maintainer")
+				return rollups.Run(rollupCtx)
+			}, func(error) {
+				log.Info("msg", "Stopping rollup maintainer")
+				stopRollups()
+			},
+		)
+	}
+
+	if !cfg.APICfg.ReadOnly {
+		histogramsCtx, stopHistograms := context.WithCancel(context.Background())
+		defer stopHistograms()
+		histograms := histogram.NewMaintainer(client.MaintenanceConnection(), histogram.MaintenanceInterval)
+
+		group.Add(
+			func() error {
+				log.Info("msg", "Started native histogram maintainer")
+				return histograms.Run(histogramsCtx)
+			}, func(error) {
+				log.Info("msg", "Stopping native histogram maintainer")
+				stopHistograms()
+			},
+		)
+	}
+
+	if !cfg.APICfg.ReadOnly {
+		logsCtx, stopLogs := context.WithCancel(context.Background())
+		defer stopLogs()
+		logMaintainer := logs.NewMaintainer(client.MaintenanceConnection(), logs.MaintenanceInterval)
+
+		group.Add(
+			func() error {
+				log.Info("msg", "Started log maintainer")
+				return logMaintainer.Run(logsCtx)
+			}, func(error) {
+				log.Info("msg", "Stopping log maintainer")
+				stopLogs()
+			},
+		)
+	}
+
+	if !cfg.APICfg.ReadOnly {
+		cardinalityCtx, stopCardinality := context.WithCancel(context.Background())
+		defer stopCardinality()
+		recorder := cardinality.NewRecorder(client.MaintenanceConnection())
+
+		group.Add(
+			func() error {
+				log.Info("msg", "Started series id watermark recorder")
+				return recorder.Run(cardinalityCtx)
+			}, func(error) {
+				log.Info("msg", "Stopping series id watermark recorder")
+				stopCardinality()
+			},
+		)
+	}
+
+	var (
+		traceAuthorizer tenancy.TraceAuthorizer
+		logAuthorizer   tenancy.LogAuthorizer
+	)
+	if cfg.APICfg.MultiTenancy != nil {
+		traceAuthorizer = cfg.APICfg.MultiTenancy.TraceAuthorizer()
+		logAuthorizer = cfg.APICfg.MultiTenancy.LogAuthorizer()
+	}
+	if cfg.APICfg.HighAvailability || cfg.TracingCfg.HighAvailability {
+		// Metrics and traces share the HA service, so that all leases can be
+		// administered through the same API.
+		cfg.APICfg.HAService = ha.NewService(haClient.NewLeaseClient(client.ReadOnlyConnection()))
+		if cfg.PgmodelCfg.SpoolConfig.Enabled() {
+			// Writes are spooled while the database is unreachable, hence they must
+			// not be rejected because their leases cannot be checked.
+			cfg.APICfg.HAService.FallBackToCachedLeader()
+		}
+	}
+	var traceProcessors []ingestor.TraceProcessor
+	if cfg.TracingCfg.HighAvailability {
+		traceProcessors = append(traceProcessors, ha.NewTraceFilter(cfg.APICfg.HAService, cfg.TracingCfg.HAClusterAttribute, cfg.TracingCfg.HAReplicaAttribute))
+	}
+	if cfg.APICfg.Relabeler != nil {
+		traceProcessors = append(traceProcessors, cfg.APICfg.Relabeler)
+	}
+	jaegerStore := jaegerStore.New(client.ReadOnlyConnection(), client.Inserter(), &cfg.TracingCfg, traceAuthorizer, traceProcessors...)
 
 	authWrapper := func(h http.Handler) http.Handler {
 		return cfg.AuthConfig.AuthHandler(h)
 	}
 
-	router, err := api.GenerateRouter(&cfg.APICfg, &cfg.PromQLCfg, client, jaegerStore, authWrapper, rulesReloader)
+	otlpTranslator, err := otlp.NewTranslator(cfg.OTLPCfg)
+	if err != nil {
+		log.Error("msg", "aborting startup due to error", "err", fmt.Sprintf("create OTLP metrics translator: %s", err.Error()))
+		return fmt.Errorf("create OTLP metrics translator: %w", err)
+	}
+	cfg.APICfg.OTLPMetrics = otlpTranslator
+	dataParser := api.NewWriteParser(&cfg.APICfg, client)
+
+	reload := func() error {
+		if rulesReloader != nil {
+			if err := rulesReloader(); err != nil {
+				return fmt.Errorf("error reloading rules: %w", err)
+			}
+		}
+		if !cfg.APICfg.ReadOnly && cfg.PgmodelCfg.Dataset != nil {
+			if err := reloadDatasetConfig(cfg.PgmodelCfg.Dataset, client); err != nil {
+				return fmt.Errorf("error reloading dataset configuration: %w", err)
+			}
+		}
+		if cfg.APICfg.TenantLimits != nil {
+			limits, err := tenancy.LoadLimitsConfig(cfg.TenancyCfg.LimitsFile)
+			if err != nil {
+				return fmt.Errorf("error reloading tenant limits: %w", err)
+			}
+			cfg.APICfg.TenantLimits.SetConfig(limits)
+		}
+		if cfg.APICfg.Relabeler != nil {
+			rules, err := relabel.LoadRules(cfg.RelabelCfg.ConfigFile)
+			if err != nil {
+				return fmt.Errorf("error reloading relabel rules: %w", err)
+			}
+			cfg.APICfg.Relabeler.SetRules(rules)
+		}
+		return nil
+	}
+
+	router, err := api.GenerateRouter(&cfg.APICfg, &cfg.PromQLCfg, client, dataParser, jaegerStore, authWrapper, reload)
 	if err != nil {
 		log.Error("msg", "aborting startup due to error", "err", fmt.Sprintf("generate router: %s", err.Error()))
 		return fmt.Errorf("generate router: %w", err)
@@ -201,9 +387,12 @@ func Run(cfg *Config) error {
 
 	if len(cfg.ThanosStoreAPIListenAddr) > 0 {
 		srv := thanos.NewStorage(client.Queryable())
-		options := make([]grpc.ServerOption, 0)
+		options := []grpc.ServerOption{
+			grpc.ChainUnaryInterceptor(cfg.AuthConfig.UnaryServerInterceptor(grpcMethodScope)),
+			grpc.ChainStreamInterceptor(cfg.AuthConfig.StreamServerInterceptor(grpcMethodScope)),
+		}
 		if cfg.TLSCertFile != "" {
-			creds, err := credentials.NewServerTLSFromFile(cfg.TLSCertFile, cfg.TLSKeyFile)
+			creds, err := grpcTLSCredentials(cfg)
 			if err != nil {
 				log.Error("msg", "Setting up TLS credentials for Thanos StoreAPI failed", "err", err)
 				return err
@@ -230,11 +419,11 @@ func Run(cfg *Config) error {
 	}
 
 	options := []grpc.ServerOption{
-		grpc.ChainUnaryInterceptor(loggingUnaryInterceptor, grpc_prometheus.UnaryServerInterceptor),
-		grpc.ChainStreamInterceptor(loggingStreamInterceptor, grpc_prometheus.StreamServerInterceptor),
+		grpc.ChainUnaryInterceptor(loggingUnaryInterceptor, grpc_prometheus.UnaryServerInterceptor, cfg.AuthConfig.UnaryServerInterceptor(grpcMethodScope)),
+		grpc.ChainStreamInterceptor(loggingStreamInterceptor, grpc_prometheus.StreamServerInterceptor, cfg.AuthConfig.StreamServerInterceptor(grpcMethodScope)),
 	}
 	if cfg.TLSCertFile != "" {
-		creds, err := credentials.NewServerTLSFromFile(cfg.TLSCertFile, cfg.TLSKeyFile)
+		creds, err := grpcTLSCredentials(cfg)
 		if err != nil {
 			log.Error("msg", "Setting up TLS credentials for OpenTelemetry GRPC server failed", "err", err)
 			return err
@@ -242,7 +431,11 @@ func Run(cfg *Config) error {
 		options = append(options, grpc.Creds(creds))
 	}
 	grpcServer := grpc.NewServer(options...)
-	ptraceotlp.RegisterServer(grpcServer, api.NewTraceServer(client))
+	ptraceotlp.RegisterServer(grpcServer, api.NewTraceServer(client, traceAuthorizer, traceProcessors...))
+	plogotlp.RegisterServer(grpcServer, api.NewLogsServer(client, logAuthorizer))
+	if !cfg.APICfg.ReadOnly {
+		pmetricotlp.RegisterServer(grpcServer, api.NewMetricsServer(client, otlpTranslator, dataParser))
+	}
 
 	queryPlugin := shared.StorageGRPCPlugin{
 		Impl: jaegerStore,
@@ -300,6 +493,10 @@ func Run(cfg *Config) error {
 		Handler:           mux,
 		ReadHeaderTimeout: time.Second * 30, // To mitigate Slowloris DDoS attack. Value is arbitrary picked
 	}
+	if cfg.AuthConfig.ClientTLSEnabled() {
+		server.TLSConfig = &tls.Config{}
+		cfg.AuthConfig.ConfigureClientTLS(server.TLSConfig)
+	}
 	group.Add(
 		func() error {
 			var err error
@@ -334,11 +531,11 @@ func Run(cfg *Config) error {
 				case syscall.SIGINT:
 					return nil
 				case syscall.SIGHUP:
-					if err := rulesReloader(); err != nil {
-						log.Error("msg", "error reloading rules", "err", err.Error())
+					if err := reload(); err != nil {
+						log.Error("msg", "error reloading", "err", err.Error())
 						continue
 					}
-					log.Debug("msg", "success reloading rules")
+					log.Debug("msg", "success reloading")
 				}
 			}
 		}, func(err error) {
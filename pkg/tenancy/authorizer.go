@@ -12,12 +12,18 @@ type Authorizer interface {
 	ReadAuthorizer() ReadAuthorizer
 	// WriteAuthorizer returns a authorizer that authorizes write operations.
 	WriteAuthorizer() WriteAuthorizer
+	// TraceAuthorizer returns a authorizer that authorizes the write and read operations of traces.
+	TraceAuthorizer() TraceAuthorizer
+	// LogAuthorizer returns a authorizer that authorizes the write operations of logs.
+	LogAuthorizer() LogAuthorizer
 }
 
 // multiTenancy type implements the tenancy concept in Promscale.
 type genericAuthorizer struct {
-	write WriteAuthorizer
-	read  ReadAuthorizer
+	write  WriteAuthorizer
+	read   ReadAuthorizer
+	traces TraceAuthorizer
+	logs   LogAuthorizer
 }
 
 // NewAuthorizer returns a new MultiTenancy type.
@@ -28,8 +34,10 @@ func NewAuthorizer(c AuthConfig) (Authorizer, error) {
 	}
 	writeAuthr := NewWriteAuthorizer(c)
 	return &genericAuthorizer{
-		read:  readAuthr,
-		write: writeAuthr,
+		read:   readAuthr,
+		write:  writeAuthr,
+		traces: NewTraceAuthorizer(c),
+		logs:   NewLogAuthorizer(c),
 	}, nil
 }
 
@@ -41,6 +49,14 @@ func (mt *genericAuthorizer) WriteAuthorizer() WriteAuthorizer {
 	return mt.write
 }
 
+func (mt *genericAuthorizer) TraceAuthorizer() TraceAuthorizer {
+	return mt.traces
+}
+
+func (mt *genericAuthorizer) LogAuthorizer() LogAuthorizer {
+	return mt.logs
+}
+
 type noopAuthorizer struct{}
 
 // NewNoopAuthorizer returns a No-op tenancy that is used to initialize tenancy types for no operations.
@@ -55,3 +71,11 @@ func (np *noopAuthorizer) ReadAuthorizer() ReadAuthorizer {
 func (np *noopAuthorizer) WriteAuthorizer() WriteAuthorizer {
 	return nil
 }
+
+func (np *noopAuthorizer) TraceAuthorizer() TraceAuthorizer {
+	return nil
+}
+
+func (np *noopAuthorizer) LogAuthorizer() LogAuthorizer {
+	return nil
+}
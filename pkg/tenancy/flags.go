@@ -15,6 +15,7 @@ type Config struct {
 	UseExperimentalLabelQueries bool
 	ValidTenantsStr             string
 	ValidTenantsList            []string
+	LimitsFile                  string
 }
 
 func ParseFlags(fs *flag.FlagSet, cfg *Config) {
@@ -28,10 +29,15 @@ func ParseFlags(fs *flag.FlagSet, cfg *Config) {
 	fs.BoolVar(&cfg.UseExperimentalLabelQueries, "metrics.multi-tenancy.experimental.label-queries", true, "[EXPERIMENTAL] Use label queries "+
 		"that returns labels of authorized tenants only. This may affect system performance while running PromQL queries. "+
 		"By default this is enabled in -metrics.multi-tenancy mode.")
+	fs.StringVar(&cfg.LimitsFile, "metrics.multi-tenancy.limits-file", "", "Path to a YAML file with the ingestion and query limits of the tenants. "+
+		"The file is read again when the configuration is reloaded. Tenants are not limited if not set.")
 }
 
 func Validate(cfg *Config) error {
 	if !cfg.EnableMultiTenancy {
+		if cfg.LimitsFile != "" {
+			return fmt.Errorf("'metrics.multi-tenancy.limits-file' requires 'metrics.multi-tenancy' to be enabled")
+		}
 		return nil
 	}
 	if cfg.ValidTenantsStr == AllowAllTenants {
@@ -25,6 +25,11 @@ func TestParseFlags(t *testing.T) {
 	require.Equal(t, Config{ValidTenantsStr: AllowAllTenants, SkipTenantValidation: false, UseExperimentalLabelQueries: false}, config)
 }
 
+func TestValidateLimitsFile(t *testing.T) {
+	require.Error(t, Validate(&Config{LimitsFile: "limits.yaml"}))
+	require.NoError(t, Validate(&Config{EnableMultiTenancy: true, ValidTenantsStr: AllowAllTenants, LimitsFile: "limits.yaml"}))
+}
+
 func fullyParse(t *testing.T, args []string) Config {
 	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
@@ -5,11 +5,14 @@
 package tenancy
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/timescale/promscale/pkg/prompb"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
 )
 
 const regexOR = "|"
@@ -20,7 +23,8 @@ var ErrUnauthorizedTenant = fmt.Errorf("unauthorized or invalid tenant")
 type ReadAuthorizer interface {
 	// AppendTenantMatcher applies a safety matcher to incoming query matchers. This safety matcher is responsible
 	// from prevent unauthorized query reads from tenants that the incoming query is not supposed to read.
-	AppendTenantMatcher(ms []*labels.Matcher) []*labels.Matcher
+	// Queries of an authenticated principal are further restricted to the tenants of the principal.
+	AppendTenantMatcher(ctx context.Context, ms []*labels.Matcher) []*labels.Matcher
 }
 
 // WriteAuthorizer tells if a write request is authorized to be written.
@@ -28,3 +32,20 @@ type WriteAuthorizer interface {
 	// Process processes the incoming write requests to be multi-tenancy compatible.
 	Process(*http.Request, *prompb.WriteRequest) error
 }
+
+// TraceAuthorizer tells if traces are authorized to be written, and which traces can be read.
+type TraceAuthorizer interface {
+	// ProcessTraces authorizes the tenants of the incoming traces. The resources without a
+	// tenant attribute are tagged with the tenant named in the request.
+	ProcessTraces(context.Context, ptrace.Traces) error
+	// ReadFilter returns the tenants whose traces can be read in the context.
+	ReadFilter(context.Context) ReadFilter
+}
+
+// LogAuthorizer tells if logs are authorized to be written. Logs are read
+// through the ReadAuthorizer, as their tenant is a resource attribute.
+type LogAuthorizer interface {
+	// ProcessLogs authorizes the tenants of the incoming logs. The resources without a
+	// tenant attribute are tagged with the tenant named in the request.
+	ProcessLogs(context.Context, plog.Logs) error
+}
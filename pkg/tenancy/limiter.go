@@ -0,0 +1,441 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package tenancy
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/timescale/promscale/pkg/prompb"
+	"golang.org/x/time/rate"
+)
+
+// DefaultActiveSeriesWindow is the default time after which a series that
+// stopped receiving samples does not count as active anymore.
+const DefaultActiveSeriesWindow = 20 * time.Minute
+
+// OtherTenants is the name under which the usage of the tenants that are neither
+// listed in the limits configuration nor valid tenants is tracked and exposed.
+// They share the default limits, so that the usage kept in memory and the series
+// of the tenant metrics are bounded by the configuration.
+const OtherTenants = "<other>"
+
+// ErrLimitExceeded is returned when a request is rejected because it exceeds
+// the limits of a tenant.
+var ErrLimitExceeded = fmt.Errorf("tenant limit exceeded")
+
+const (
+	reasonRate        = "rate"
+	reasonSeries      = "active_series"
+	reasonCardinality = "label_cardinality"
+)
+
+// Limiter enforces the limits of the tenants. Write requests exceeding the
+// limits of any of their tenants are rejected as a whole. The usage of the
+// tenants is tracked in memory, hence the limits apply per connector.
+type Limiter struct {
+	// mu protects the configuration and the tenants, while the usage of each
+	// tenant has its own lock, so that the requests of different tenants do not
+	// wait on each other.
+	mu           sync.RWMutex
+	cfg          LimitsConfig
+	window       time.Duration
+	validTenants map[string]struct{}
+	tenants      map[string]*tenantUsage
+	now          func() time.Time
+}
+
+type tenantUsage struct {
+	mu     sync.Mutex
+	limits Limits
+	// nil when the ingestion rate is unlimited.
+	rate *rate.Limiter
+	// Last time each active series and label value received a sample, nil
+	// when the active series or label cardinality is unlimited.
+	series         map[uint64]time.Time
+	labelValues    map[string]map[string]time.Time
+	lastPurge      time.Time
+	runningQueries int
+}
+
+// NewLimiter creates a new Limiter. Series that did not receive samples within
+// window do not count towards the limits. The usage of the validTenants and of
+// the tenants of the configuration is tracked per tenant, and the usage of any
+// other tenant under OtherTenants.
+func NewLimiter(cfg LimitsConfig, window time.Duration, validTenants []string) *Limiter {
+	l := &Limiter{
+		cfg:          cfg,
+		window:       window,
+		validTenants: make(map[string]struct{}, len(validTenants)),
+		tenants:      make(map[string]*tenantUsage),
+		now:          time.Now,
+	}
+	for _, t := range validTenants {
+		l.validTenants[t] = struct{}{}
+	}
+	return l
+}
+
+// SetConfig replaces the limits of the tenants. The usage of the tenants is kept,
+// unless they are not part of the configuration anymore.
+func (l *Limiter) SetConfig(cfg LimitsConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cfg = cfg
+	now := l.now()
+	for tenant, u := range l.tenants {
+		u.mu.Lock()
+		if l.key(tenant) != tenant && u.runningQueries == 0 {
+			delete(l.tenants, tenant)
+			tenantActiveSeries.DeleteLabelValues(tenant)
+			tenantRunningQueries.DeleteLabelValues(tenant)
+		} else {
+			u.setLimits(cfg.limitsFor(tenant), now)
+			if u.series == nil {
+				tenantActiveSeries.DeleteLabelValues(tenant)
+			}
+		}
+		u.mu.Unlock()
+	}
+}
+
+// key returns the name under which the usage of the tenant is tracked.
+func (l *Limiter) key(tenant string) string {
+	if tenant == "" || tenant == OtherTenants {
+		return tenant
+	}
+	if _, ok := l.cfg.Tenants[tenant]; ok {
+		return tenant
+	}
+	if _, ok := l.validTenants[tenant]; ok {
+		return tenant
+	}
+	return OtherTenants
+}
+
+// keys returns the distinct names under which the usage of the tenants is
+// tracked, sorted.
+func (l *Limiter) keys(tenants []string) []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	keys := make([]string, 0, len(tenants))
+	for _, t := range tenants {
+		keys = append(keys, l.key(t))
+	}
+	sort.Strings(keys)
+	unique := keys[:0]
+	for i, k := range keys {
+		if i == 0 || k != keys[i-1] {
+			unique = append(unique, k)
+		}
+	}
+	return unique
+}
+
+// usage returns the usage of the tenant key, which is locked by the caller.
+func (l *Limiter) usage(tenant string, now time.Time) *tenantUsage {
+	l.mu.RLock()
+	u, ok := l.tenants[tenant]
+	l.mu.RUnlock()
+	if ok {
+		return u
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if u, ok = l.tenants[tenant]; !ok {
+		u = &tenantUsage{lastPurge: now}
+		u.setLimits(l.cfg.limitsFor(tenant), now)
+		l.tenants[tenant] = u
+	}
+	return u
+}
+
+// setLimits applies the limits to the usage, and only tracks the series and
+// label values if their limits are set.
+func (u *tenantUsage) setLimits(limits Limits, now time.Time) {
+	u.limits = limits
+	switch {
+	case limits.MaxActiveSeries == 0:
+		u.series = nil
+	case u.series == nil:
+		u.series = make(map[uint64]time.Time)
+	}
+	switch {
+	case limits.MaxLabelCardinality == 0:
+		u.labelValues = nil
+	case u.labelValues == nil:
+		u.labelValues = make(map[string]map[string]time.Time)
+	}
+	if limits.IngestionRate == 0 {
+		u.rate = nil
+		return
+	}
+	burst := limits.IngestionBurstSize
+	if burst == 0 {
+		burst = int(limits.IngestionRate)
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	if u.rate == nil {
+		u.rate = rate.NewLimiter(rate.Limit(limits.IngestionRate), burst)
+		return
+	}
+	u.rate.SetLimitAt(now, rate.Limit(limits.IngestionRate))
+	u.rate.SetBurstAt(now, burst)
+}
+
+// purge forgets the series and label values which are not active anymore.
+func (u *tenantUsage) purge(now time.Time, window time.Duration) {
+	// Purging iterates over all series, so it is only done every tenth of the window.
+	if now.Sub(u.lastPurge) < window/10 {
+		return
+	}
+	u.lastPurge = now
+	for s, seen := range u.series {
+		if now.Sub(seen) > window {
+			delete(u.series, s)
+		}
+	}
+	for name, values := range u.labelValues {
+		for v, seen := range values {
+			if now.Sub(seen) > window {
+				delete(values, v)
+			}
+		}
+		if len(values) == 0 {
+			delete(u.labelValues, name)
+		}
+	}
+}
+
+// tenantWrite is the part of a write request belonging to a tenant.
+type tenantWrite struct {
+	tenant      string
+	usage       *tenantUsage
+	timeseries  []*prompb.TimeSeries
+	samples     int
+	series      map[uint64]struct{}
+	labelValues map[string]map[string]struct{}
+	reservation *rate.Reservation
+}
+
+// Process implements the Preprocessor interface. It must run after the write
+// authorizer, which sets the tenant label of the series.
+func (l *Limiter) Process(_ *http.Request, wr *prompb.WriteRequest) error {
+	l.mu.RLock()
+	writes := groupByTenant(wr, l.key)
+	l.mu.RUnlock()
+	now := l.now()
+	for _, w := range writes {
+		w.usage = l.usage(w.tenant, now)
+	}
+	// The usages are locked in the order of their tenants, as in StartQuery.
+	for _, w := range writes {
+		w.usage.mu.Lock()
+		defer w.usage.mu.Unlock()
+	}
+
+	var (
+		err    error
+		reason string
+	)
+	for i, w := range writes {
+		w.usage.purge(now, l.window)
+		w.collect()
+		if reason, err = w.check(now); err != nil {
+			for _, accepted := range writes[:i] {
+				if accepted.reservation != nil {
+					accepted.reservation.CancelAt(now)
+				}
+			}
+			break
+		}
+	}
+	if err != nil {
+		for _, w := range writes {
+			tenantRejectedSamples.WithLabelValues(w.tenant, reason).Add(float64(w.samples))
+		}
+		return err
+	}
+
+	for _, w := range writes {
+		for s := range w.series {
+			w.usage.series[s] = now
+		}
+		for name, values := range w.labelValues {
+			seen, ok := w.usage.labelValues[name]
+			if !ok {
+				seen = make(map[string]time.Time, len(values))
+				w.usage.labelValues[name] = seen
+			}
+			for v := range values {
+				seen[v] = now
+			}
+		}
+		tenantIngestedSamples.WithLabelValues(w.tenant).Add(float64(w.samples))
+		if w.usage.series != nil {
+			tenantActiveSeries.WithLabelValues(w.tenant).Set(float64(len(w.usage.series)))
+		}
+	}
+	return nil
+}
+
+// collect gathers the series and label values of the write, if the tenant
+// tracks them.
+func (w *tenantWrite) collect() {
+	trackSeries, trackValues := w.usage.series != nil, w.usage.labelValues != nil
+	if !trackSeries && !trackValues {
+		return
+	}
+	w.series = make(map[uint64]struct{}, len(w.timeseries))
+	w.labelValues = make(map[string]map[string]struct{})
+	for _, ts := range w.timeseries {
+		lset := make(labels.Labels, 0, len(ts.Labels))
+		for _, l := range ts.Labels {
+			lset = append(lset, labels.Label{Name: l.Name, Value: l.Value})
+		}
+		sort.Sort(lset)
+		if trackSeries {
+			w.series[lset.Hash()] = struct{}{}
+		}
+		if !trackValues {
+			continue
+		}
+		for _, l := range lset {
+			if l.Name == labels.MetricName || l.Name == TenantLabelKey {
+				continue
+			}
+			values, ok := w.labelValues[l.Name]
+			if !ok {
+				values = make(map[string]struct{})
+				w.labelValues[l.Name] = values
+			}
+			values[l.Value] = struct{}{}
+		}
+	}
+}
+
+// check returns an error if the write exceeds the limits of the tenant, along
+// with the reason. Otherwise, the samples are taken from the ingestion rate.
+func (w *tenantWrite) check(now time.Time) (string, error) {
+	limits := w.usage.limits
+	if limits.MaxActiveSeries > 0 {
+		active := len(w.usage.series)
+		for s := range w.series {
+			if _, ok := w.usage.series[s]; !ok {
+				active++
+			}
+		}
+		if active > limits.MaxActiveSeries {
+			return reasonSeries, limitError(w.tenant, "active series limit of %d exceeded", limits.MaxActiveSeries)
+		}
+	}
+	if limits.MaxLabelCardinality > 0 {
+		names := make([]string, 0, len(w.labelValues))
+		for name := range w.labelValues {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			seen := w.usage.labelValues[name]
+			cardinality := len(seen)
+			for v := range w.labelValues[name] {
+				if _, ok := seen[v]; !ok {
+					cardinality++
+				}
+			}
+			if cardinality > limits.MaxLabelCardinality {
+				return reasonCardinality, limitError(w.tenant, "cardinality limit of %d exceeded for label %s", limits.MaxLabelCardinality, name)
+			}
+		}
+	}
+	if w.usage.rate != nil {
+		r := w.usage.rate.ReserveN(now, w.samples)
+		if !r.OK() {
+			return reasonRate, limitError(w.tenant, "%d samples exceed the ingestion burst size of %d", w.samples, w.usage.rate.Burst())
+		}
+		if r.DelayFrom(now) > 0 {
+			r.CancelAt(now)
+			return reasonRate, limitError(w.tenant, "ingestion rate limit of %g samples/s exceeded", w.usage.limits.IngestionRate)
+		}
+		w.reservation = r
+	}
+	return "", nil
+}
+
+func limitError(tenant, format string, args ...interface{}) error {
+	if tenant == "" {
+		tenant = "<none>"
+	}
+	return fmt.Errorf("%w: tenant %s: %s", ErrLimitExceeded, tenant, fmt.Sprintf(format, args...))
+}
+
+// groupByTenant splits the write request per tenant key, ordered by key.
+func groupByTenant(wr *prompb.WriteRequest, key func(string) string) []*tenantWrite {
+	byTenant := make(map[string]*tenantWrite)
+	for i := range wr.Timeseries {
+		ts := &wr.Timeseries[i]
+		tenant := ""
+		for _, l := range ts.Labels {
+			if l.Name == TenantLabelKey {
+				tenant = key(l.Value)
+				break
+			}
+		}
+		w, ok := byTenant[tenant]
+		if !ok {
+			w = &tenantWrite{tenant: tenant}
+			byTenant[tenant] = w
+		}
+		w.timeseries = append(w.timeseries, ts)
+		w.samples += len(ts.Samples) + len(ts.Histograms)
+	}
+	writes := make([]*tenantWrite, 0, len(byTenant))
+	for _, w := range byTenant {
+		writes = append(writes, w)
+	}
+	sort.Slice(writes, func(i, j int) bool { return writes[i].tenant < writes[j].tenant })
+	return writes
+}
+
+// StartQuery takes one of the concurrent query slots of each of the tenants the
+// query reads. The returned function releases them, and must be called once the
+// query is done.
+func (l *Limiter) StartQuery(tenants ...string) (func(), error) {
+	now := l.now()
+	keys := l.keys(tenants)
+	usages := make([]*tenantUsage, len(keys))
+	for i, key := range keys {
+		usages[i] = l.usage(key, now)
+	}
+	// The usages are locked in the order of their tenants, as in Process.
+	for _, u := range usages {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+	}
+	for i, u := range usages {
+		if max := u.limits.MaxConcurrentQueries; max > 0 && u.runningQueries >= max {
+			tenantRejectedQueries.WithLabelValues(keys[i]).Inc()
+			return nil, limitError(keys[i], "concurrent queries limit of %d exceeded", max)
+		}
+	}
+	for i, u := range usages {
+		u.runningQueries++
+		tenantRunningQueries.WithLabelValues(keys[i]).Set(float64(u.runningQueries))
+	}
+	return func() {
+		for i, u := range usages {
+			u.mu.Lock()
+			u.runningQueries--
+			tenantRunningQueries.WithLabelValues(keys[i]).Set(float64(u.runningQueries))
+			u.mu.Unlock()
+		}
+	}, nil
+}
@@ -0,0 +1,205 @@
+package tenancy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/prompb"
+)
+
+func TestLoadLimitsConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limits.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`defaults:
+  ingestion_rate: 1000
+  max_active_series: 100
+tenants:
+  tenant-a:
+    max_active_series: 10
+    max_concurrent_queries: 2`), 0600))
+
+	cfg, err := LoadLimitsConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, Limits{IngestionRate: 1000, MaxActiveSeries: 10, MaxConcurrentQueries: 2}, cfg.limitsFor("tenant-a"))
+	require.Equal(t, Limits{IngestionRate: 1000, MaxActiveSeries: 100}, cfg.limitsFor("tenant-b"))
+
+	require.NoError(t, os.WriteFile(path, []byte("defaults:\n  max_series: 1"), 0600))
+	_, err = LoadLimitsConfig(path)
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("tenants:\n  tenant-a:\n    max_active_series: -1"), 0600))
+	_, err = LoadLimitsConfig(path)
+	require.Error(t, err)
+}
+
+func limitsWriteRequest(tenant string, samples int, series ...string) *prompb.WriteRequest {
+	wr := &prompb.WriteRequest{}
+	for _, s := range series {
+		ts := prompb.TimeSeries{
+			Labels: []prompb.Label{
+				{Name: "__name__", Value: "foo"},
+				{Name: "instance", Value: s},
+			},
+			Samples: make([]prompb.Sample, samples),
+		}
+		if tenant != "" {
+			ts.Labels = append(ts.Labels, prompb.Label{Name: TenantLabelKey, Value: tenant})
+		}
+		wr.Timeseries = append(wr.Timeseries, ts)
+	}
+	return wr
+}
+
+func TestLimiterActiveSeries(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(LimitsConfig{Tenants: map[string]Limits{"a": {MaxActiveSeries: 2}, "b": {MaxActiveSeries: 10}}}, time.Minute, []string{"c"})
+	l.now = func() time.Time { return now }
+
+	require.NoError(t, l.Process(nil, limitsWriteRequest("a", 1, "1", "2")))
+	// Known series are still accepted.
+	require.NoError(t, l.Process(nil, limitsWriteRequest("a", 1, "1")))
+	err := l.Process(nil, limitsWriteRequest("a", 1, "3"))
+	require.True(t, errors.Is(err, ErrLimitExceeded))
+	require.Contains(t, err.Error(), "tenant a: active series limit of 2 exceeded")
+	// Other tenants are limited separately.
+	require.NoError(t, l.Process(nil, limitsWriteRequest("b", 1, "1", "2", "3")))
+	// The series of tenants without limit are not tracked.
+	require.NoError(t, l.Process(nil, limitsWriteRequest("c", 1, "1", "2", "3")))
+	require.Nil(t, l.tenants["c"].series)
+	require.Nil(t, l.tenants["c"].labelValues)
+
+	// Series of a request spanning several tenants are rejected as a whole.
+	wr := limitsWriteRequest("b", 1, "4")
+	wr.Timeseries = append(wr.Timeseries, limitsWriteRequest("a", 1, "3").Timeseries...)
+	require.Error(t, l.Process(nil, wr))
+	require.NotContains(t, l.tenants["b"].series, seriesHash(t, limitsWriteRequest("b", 1, "4")))
+
+	// Series stop being active after the window.
+	now = now.Add(2 * time.Minute)
+	require.NoError(t, l.Process(nil, limitsWriteRequest("a", 1, "3", "4")))
+
+	// Series are not tracked anymore once the limit is removed.
+	l.SetConfig(LimitsConfig{Tenants: map[string]Limits{"a": {}}})
+	require.Nil(t, l.tenants["a"].series)
+	require.NoError(t, l.Process(nil, limitsWriteRequest("a", 1, "5", "6", "7")))
+}
+
+func seriesHash(t *testing.T, wr *prompb.WriteRequest) uint64 {
+	writes := groupByTenant(wr, func(tenant string) string { return tenant })
+	require.Len(t, writes, 1)
+	writes[0].usage = &tenantUsage{series: make(map[uint64]time.Time)}
+	writes[0].collect()
+	for s := range writes[0].series {
+		return s
+	}
+	return 0
+}
+
+func TestLimiterLabelCardinality(t *testing.T) {
+	l := NewLimiter(LimitsConfig{Defaults: Limits{MaxLabelCardinality: 2}}, time.Minute, nil)
+
+	require.NoError(t, l.Process(nil, limitsWriteRequest("", 1, "1", "2")))
+	err := l.Process(nil, limitsWriteRequest("", 1, "3"))
+	require.True(t, errors.Is(err, ErrLimitExceeded))
+	require.Contains(t, err.Error(), "tenant <none>: cardinality limit of 2 exceeded for label instance")
+	require.Nil(t, l.tenants[""].series)
+}
+
+func TestLimiterConcurrentTenants(t *testing.T) {
+	l := NewLimiter(LimitsConfig{Defaults: Limits{MaxActiveSeries: 1000, MaxLabelCardinality: 1000, MaxConcurrentQueries: 2}}, time.Minute, []string{"a", "b"})
+
+	var wg sync.WaitGroup
+	for _, tenant := range []string{"a", "b"} {
+		tenant := tenant
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				wr := limitsWriteRequest(tenant, 1, strconv.Itoa(i))
+				wr.Timeseries = append(wr.Timeseries, limitsWriteRequest("b", 1, "shared").Timeseries...)
+				require.NoError(t, l.Process(nil, wr))
+				done, err := l.StartQuery("b", tenant)
+				require.NoError(t, err)
+				done()
+			}
+		}()
+	}
+	wg.Wait()
+	require.Len(t, l.tenants["a"].series, 100)
+	require.Len(t, l.tenants["b"].series, 101)
+}
+
+func TestLimiterIngestionRate(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(LimitsConfig{Defaults: Limits{IngestionRate: 10}}, time.Minute, []string{"a"})
+	l.now = func() time.Time { return now }
+
+	require.NoError(t, l.Process(nil, limitsWriteRequest("a", 5, "1", "2")))
+	err := l.Process(nil, limitsWriteRequest("a", 1, "1"))
+	require.True(t, errors.Is(err, ErrLimitExceeded))
+	require.Contains(t, err.Error(), "ingestion rate limit of 10 samples/s exceeded")
+
+	err = l.Process(nil, limitsWriteRequest("a", 11, "1"))
+	require.Contains(t, err.Error(), "11 samples exceed the ingestion burst size of 10")
+
+	now = now.Add(time.Second)
+	require.NoError(t, l.Process(nil, limitsWriteRequest("a", 10, "1")))
+
+	// Reloaded limits apply to the existing tenants.
+	now = now.Add(time.Second)
+	l.SetConfig(LimitsConfig{})
+	require.NoError(t, l.Process(nil, limitsWriteRequest("a", 100, "1")))
+}
+
+func TestLimiterConcurrentQueries(t *testing.T) {
+	l := NewLimiter(LimitsConfig{Defaults: Limits{MaxConcurrentQueries: 1}}, time.Minute, []string{"a", "b", "c"})
+
+	done, err := l.StartQuery("a")
+	require.NoError(t, err)
+	_, err = l.StartQuery("a")
+	require.True(t, errors.Is(err, ErrLimitExceeded))
+	doneB, err := l.StartQuery("b")
+	require.NoError(t, err)
+
+	// Queries of several tenants take a slot of each of them, or of none.
+	_, err = l.StartQuery("c", "b")
+	require.True(t, errors.Is(err, ErrLimitExceeded))
+	doneB()
+	doneCB, err := l.StartQuery("c", "b")
+	require.NoError(t, err)
+	_, err = l.StartQuery("c")
+	require.True(t, errors.Is(err, ErrLimitExceeded))
+	doneCB()
+
+	done()
+	_, err = l.StartQuery("a")
+	require.NoError(t, err)
+}
+
+func TestLimiterOtherTenants(t *testing.T) {
+	l := NewLimiter(LimitsConfig{Defaults: Limits{MaxConcurrentQueries: 1}, Tenants: map[string]Limits{"a": {}}}, time.Minute, []string{"b"})
+
+	// Tenants neither configured nor valid share their usage.
+	done, err := l.StartQuery("x")
+	require.NoError(t, err)
+	_, err = l.StartQuery("y")
+	require.True(t, errors.Is(err, ErrLimitExceeded))
+	require.Contains(t, err.Error(), "tenant <other>: concurrent queries limit of 1 exceeded")
+	done()
+
+	require.NoError(t, l.Process(nil, limitsWriteRequest("x", 1, "1")))
+	require.NoError(t, l.Process(nil, limitsWriteRequest("a", 1, "1")))
+	require.NoError(t, l.Process(nil, limitsWriteRequest("b", 1, "1")))
+	require.Len(t, l.tenants, 3)
+	require.Contains(t, l.tenants, OtherTenants)
+
+	// The usage of the tenants removed from the configuration is forgotten.
+	l.SetConfig(LimitsConfig{})
+	require.Len(t, l.tenants, 2)
+	require.NotContains(t, l.tenants, "a")
+}
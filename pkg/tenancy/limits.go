@@ -0,0 +1,90 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package tenancy
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Limits are the quotas of a tenant. Zero means unlimited.
+type Limits struct {
+	// IngestionRate is the maximum number of samples per second.
+	IngestionRate float64 `yaml:"ingestion_rate"`
+	// IngestionBurstSize is the maximum number of samples ingested at once.
+	// Defaults to one second worth of IngestionRate.
+	IngestionBurstSize int `yaml:"ingestion_burst_size"`
+	// MaxActiveSeries is the maximum number of series that received samples
+	// within the active series window.
+	MaxActiveSeries int `yaml:"max_active_series"`
+	// MaxLabelCardinality is the maximum number of distinct values of any label
+	// within the active series window.
+	MaxLabelCardinality int `yaml:"max_label_cardinality"`
+	// MaxConcurrentQueries is the maximum number of queries evaluated at once.
+	MaxConcurrentQueries int `yaml:"max_concurrent_queries"`
+}
+
+func (l Limits) validate() error {
+	if l.IngestionRate < 0 || l.IngestionBurstSize < 0 || l.MaxActiveSeries < 0 || l.MaxLabelCardinality < 0 || l.MaxConcurrentQueries < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	return nil
+}
+
+// merge returns the limits with the unset limits taken from defaults.
+func (l Limits) merge(defaults Limits) Limits {
+	if l.IngestionRate == 0 {
+		l.IngestionRate = defaults.IngestionRate
+	}
+	if l.IngestionBurstSize == 0 {
+		l.IngestionBurstSize = defaults.IngestionBurstSize
+	}
+	if l.MaxActiveSeries == 0 {
+		l.MaxActiveSeries = defaults.MaxActiveSeries
+	}
+	if l.MaxLabelCardinality == 0 {
+		l.MaxLabelCardinality = defaults.MaxLabelCardinality
+	}
+	if l.MaxConcurrentQueries == 0 {
+		l.MaxConcurrentQueries = defaults.MaxConcurrentQueries
+	}
+	return l
+}
+
+// LimitsConfig contains the default limits of the tenants, and the limits of
+// specific tenants. Limits that are not set for a tenant are taken from the
+// defaults. The limits of the writes without a tenant are set with the empty
+// tenant name.
+type LimitsConfig struct {
+	Defaults Limits            `yaml:"defaults"`
+	Tenants  map[string]Limits `yaml:"tenants"`
+}
+
+// LoadLimitsConfig reads the limits configuration from a YAML file.
+func LoadLimitsConfig(path string) (LimitsConfig, error) {
+	var cfg LimitsConfig
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("read tenant limits file: %w", err)
+	}
+	if err = yaml.UnmarshalStrict(contents, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse tenant limits file: %w", err)
+	}
+	if err = cfg.Defaults.validate(); err != nil {
+		return cfg, fmt.Errorf("default tenant limits: %w", err)
+	}
+	for tenant, l := range cfg.Tenants {
+		if err = l.validate(); err != nil {
+			return cfg, fmt.Errorf("limits of tenant %q: %w", tenant, err)
+		}
+	}
+	return cfg, nil
+}
+
+func (cfg LimitsConfig) limitsFor(tenant string) Limits {
+	return cfg.Tenants[tenant].merge(cfg.Defaults)
+}
@@ -0,0 +1,38 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package tenancy
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+
+	"github.com/timescale/promscale/pkg/auth"
+)
+
+type logAuthorizer struct {
+	writeAuthorizer
+}
+
+// NewLogAuthorizer returns an authorizer for the ingestion of logs.
+func NewLogAuthorizer(cfg AuthConfig) LogAuthorizer {
+	return &logAuthorizer{writeAuthorizer{cfg}}
+}
+
+// ProcessLogs implements the LogAuthorizer interface.
+func (a *logAuthorizer) ProcessLogs(ctx context.Context, logs plog.Logs) error {
+	var (
+		principal         = auth.PrincipalFromContext(ctx)
+		tenantFromRequest = TenantFromContext(ctx)
+		resourceLogs      = logs.ResourceLogs()
+	)
+	for i := 0; i < resourceLogs.Len(); i++ {
+		if err := a.tagResource(principal, tenantFromRequest, resourceLogs.At(i).Resource().Attributes()); err != nil {
+			return fmt.Errorf("log-authorizer process: %w", err)
+		}
+	}
+	return nil
+}
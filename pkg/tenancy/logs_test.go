@@ -0,0 +1,56 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package tenancy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/auth"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func getLogs(tenants ...string) plog.Logs {
+	logs := plog.NewLogs()
+	for _, tenant := range tenants {
+		rl := logs.ResourceLogs().AppendEmpty()
+		rl.Resource().Attributes().PutString("service.name", "service")
+		if tenant != "" {
+			rl.Resource().Attributes().PutString(TenantLabelKey, tenant)
+		}
+	}
+	return logs
+}
+
+func getLogTenants(logs plog.Logs) []string {
+	var tenants []string
+	for i := 0; i < logs.ResourceLogs().Len(); i++ {
+		tenant := ""
+		if v, ok := logs.ResourceLogs().At(i).Resource().Attributes().Get(TenantLabelKey); ok {
+			tenant = v.AsString()
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants
+}
+
+func TestProcessLogs(t *testing.T) {
+	authr := NewLogAuthorizer(NewSelectiveTenancyConfig([]string{"tenant-a", "tenant-b"}, true, false))
+	ctx := context.Background()
+
+	logs := getLogs("", "tenant-a")
+	require.NoError(t, authr.ProcessLogs(ctx, logs))
+	require.Equal(t, []string{"", "tenant-a"}, getLogTenants(logs))
+
+	require.ErrorIs(t, authr.ProcessLogs(ctx, getLogs("tenant-c")), ErrUnauthorizedTenant)
+
+	// The logs of a principal are tagged with its tenant.
+	principalCtx := auth.NewContext(ctx, &auth.Principal{Name: "user", Tenants: []string{"tenant-a"}})
+	logs = getLogs("")
+	require.NoError(t, authr.ProcessLogs(principalCtx, logs))
+	require.Equal(t, []string{"tenant-a"}, getLogTenants(logs))
+	require.Error(t, authr.ProcessLogs(principalCtx, getLogs("tenant-b")))
+}
@@ -0,0 +1,63 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package tenancy
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/timescale/promscale/pkg/util"
+)
+
+var (
+	tenantIngestedSamples = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "tenant",
+			Name:      "ingested_samples_total",
+			Help:      "Total number of samples accepted per tenant.",
+		}, []string{"tenant"},
+	)
+	tenantRejectedSamples = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "tenant",
+			Name:      "rejected_samples_total",
+			Help:      "Total number of samples rejected per tenant because a limit was exceeded.",
+		}, []string{"tenant", "reason"},
+	)
+	tenantActiveSeries = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "tenant",
+			Name:      "active_series",
+			Help:      "Number of series per tenant that received samples within the active series window.",
+		}, []string{"tenant"},
+	)
+	tenantRunningQueries = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "tenant",
+			Name:      "running_queries",
+			Help:      "Number of queries being evaluated per tenant.",
+		}, []string{"tenant"},
+	)
+	tenantRejectedQueries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: util.PromNamespace,
+			Subsystem: "tenant",
+			Name:      "rejected_queries_total",
+			Help:      "Total number of queries rejected per tenant because of the concurrent queries limit.",
+		}, []string{"tenant"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		tenantIngestedSamples,
+		tenantRejectedSamples,
+		tenantActiveSeries,
+		tenantRunningQueries,
+		tenantRejectedQueries,
+	)
+}
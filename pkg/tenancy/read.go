@@ -5,9 +5,13 @@
 package tenancy
 
 import (
+	"context"
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/timescale/promscale/pkg/auth"
 )
 
 type readAuthorizer struct {
@@ -29,10 +33,49 @@ func NewReadAuthorizer(cfg AuthConfig) (ReadAuthorizer, error) {
 	}, nil
 }
 
-func (a *readAuthorizer) AppendTenantMatcher(ms []*labels.Matcher) []*labels.Matcher {
-	if a.mtSafetyLabelMatcher == nil {
-		return ms
+func (a *readAuthorizer) AppendTenantMatcher(ctx context.Context, ms []*labels.Matcher) []*labels.Matcher {
+	if a.mtSafetyLabelMatcher != nil {
+		ms = append(ms, a.mtSafetyLabelMatcher)
+	}
+	if p := auth.PrincipalFromContext(ctx); p.RestrictsTenants() {
+		ms = append(ms, principalMatchers(p)...)
 	}
-	ms = append(ms, a.mtSafetyLabelMatcher)
 	return ms
 }
+
+// principalMatchers returns the matchers selecting the tenants of the principal.
+// A principal without tenants gets contradicting matchers, selecting nothing.
+func principalMatchers(p *auth.Principal) []*labels.Matcher {
+	if len(p.Tenants) == 0 {
+		return []*labels.Matcher{
+			labels.MustNewMatcher(labels.MatchEqual, TenantLabelKey, ""),
+			labels.MustNewMatcher(labels.MatchNotEqual, TenantLabelKey, ""),
+		}
+	}
+	tenants := make([]string, len(p.Tenants))
+	for i, t := range p.Tenants {
+		tenants[i] = regexp.QuoteMeta(t)
+	}
+	return []*labels.Matcher{labels.MustNewMatcher(labels.MatchRegexp, TenantLabelKey, strings.Join(tenants, regexOR))}
+}
+
+// AuthorizedTenants returns the tenants that the request is allowed to read,
+// and false if the request is allowed to read all tenants. The tenants are
+// restricted by the valid tenants of the configuration when it only allows
+// authorized tenants, and by the tenants of the authenticated principal.
+func AuthorizedTenants(ctx context.Context, cfg AuthConfig) ([]string, bool) {
+	p := auth.PrincipalFromContext(ctx)
+	switch {
+	case p.RestrictsTenants():
+		tenants := make([]string, 0, len(p.Tenants))
+		for _, t := range p.Tenants {
+			if cfg == nil || cfg.IsTenantAllowed(t) {
+				tenants = append(tenants, t)
+			}
+		}
+		return tenants, true
+	case cfg != nil && cfg.AllowAuthorizedTenantsOnly():
+		return cfg.ValidTenants(), true
+	}
+	return nil, false
+}
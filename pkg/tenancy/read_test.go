@@ -5,10 +5,12 @@
 package tenancy
 
 import (
+	"context"
 	"testing"
 
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/auth"
 )
 
 func TestMultiTenancyRead(t *testing.T) {
@@ -24,7 +26,7 @@ func TestMultiTenancyRead(t *testing.T) {
 	conf := NewSelectiveTenancyConfig([]string{"tenant-a", "tenant-b"}, false, true)
 	authr, err := NewReadAuthorizer(conf)
 	require.NoError(t, err)
-	newMatchers := authr.AppendTenantMatcher(matchers)
+	newMatchers := authr.AppendTenantMatcher(context.Background(), matchers)
 	safetyMatcher, present := getSafetyMatcher(newMatchers)
 	require.True(t, present)
 	require.Equal(t, "tenant-a|tenant-b", safetyMatcher)
@@ -33,7 +35,7 @@ func TestMultiTenancyRead(t *testing.T) {
 	conf = NewAllowAllTenantsConfig(false)
 	authr, err = NewReadAuthorizer(conf)
 	require.NoError(t, err)
-	newMatchers = authr.AppendTenantMatcher(matchers)
+	newMatchers = authr.AppendTenantMatcher(context.Background(), matchers)
 	safetyMatcher, present = getSafetyMatcher(newMatchers)
 	require.True(t, present)
 	require.Equal(t, "", safetyMatcher)
@@ -43,7 +45,7 @@ func TestMultiTenancyRead(t *testing.T) {
 	conf = NewSelectiveTenancyConfig([]string{"tenant-a", "tenant-b"}, true, true)
 	authr, err = NewReadAuthorizer(conf)
 	require.NoError(t, err)
-	newMatchers = authr.AppendTenantMatcher(matchers)
+	newMatchers = authr.AppendTenantMatcher(context.Background(), matchers)
 	safetyMatcher, present = getSafetyMatcher(newMatchers)
 	require.True(t, present)
 	require.Equal(t, "tenant-a|tenant-b|^$", safetyMatcher)
@@ -52,11 +54,49 @@ func TestMultiTenancyRead(t *testing.T) {
 	conf = NewAllowAllTenantsConfig(true)
 	authr, err = NewReadAuthorizer(conf)
 	require.NoError(t, err)
-	newMatchers = authr.AppendTenantMatcher(matchers)
+	newMatchers = authr.AppendTenantMatcher(context.Background(), matchers)
 	_, present = getSafetyMatcher(newMatchers)
 	require.False(t, present)
 }
 
+func TestMultiTenancyReadPrincipal(t *testing.T) {
+	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "__name__", "metric")}
+	authr, err := NewReadAuthorizer(NewAllowAllTenantsConfig(true))
+	require.NoError(t, err)
+
+	// Principals with all tenants are not restricted.
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Name: "admin", Tenants: []string{auth.AllTenants}})
+	require.Equal(t, matchers, authr.AppendTenantMatcher(ctx, matchers))
+
+	// Principals without tenants select nothing.
+	ctx = auth.NewContext(context.Background(), &auth.Principal{Name: "nobody"})
+	require.Equal(t, append(matchers,
+		labels.MustNewMatcher(labels.MatchEqual, TenantLabelKey, ""),
+		labels.MustNewMatcher(labels.MatchNotEqual, TenantLabelKey, ""),
+	), authr.AppendTenantMatcher(ctx, matchers))
+
+	ctx = auth.NewContext(context.Background(), &auth.Principal{Name: "user", Tenants: []string{"tenant-a", "tenant.b"}})
+	newMatchers := authr.AppendTenantMatcher(ctx, matchers)
+	safetyMatcher, present := getSafetyMatcher(newMatchers)
+	require.True(t, present)
+	require.Equal(t, `tenant-a|tenant\.b`, safetyMatcher)
+}
+
+func TestAuthorizedTenants(t *testing.T) {
+	conf := NewSelectiveTenancyConfig([]string{"tenant-a", "tenant-b"}, false, true)
+	tenants, restricted := AuthorizedTenants(context.Background(), conf)
+	require.True(t, restricted)
+	require.ElementsMatch(t, []string{"tenant-a", "tenant-b"}, tenants)
+
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Tenants: []string{"tenant-b", "tenant-c"}})
+	tenants, restricted = AuthorizedTenants(ctx, conf)
+	require.True(t, restricted)
+	require.Equal(t, []string{"tenant-b"}, tenants)
+
+	_, restricted = AuthorizedTenants(context.Background(), NewAllowAllTenantsConfig(false))
+	require.False(t, restricted)
+}
+
 func getSafetyMatcher(ms []*labels.Matcher) (string, bool) {
 	for _, m := range ms {
 		if m.Name == TenantLabelKey {
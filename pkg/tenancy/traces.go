@@ -0,0 +1,114 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package tenancy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/timescale/promscale/pkg/auth"
+)
+
+// ReadFilter describes the tenants whose data a read request is allowed to access.
+type ReadFilter struct {
+	// Tenants that can be read. All tenants can be read if nil.
+	Tenants []string
+	// NonTenants is true if the data without a tenant can be read.
+	NonTenants bool
+}
+
+// Restricted returns true if the filter does not allow reading all the data.
+func (f ReadFilter) Restricted() bool {
+	return f.Tenants != nil || !f.NonTenants
+}
+
+type traceAuthorizer struct {
+	writeAuthorizer
+}
+
+// NewTraceAuthorizer returns an authorizer for the ingestion and reads of traces.
+func NewTraceAuthorizer(cfg AuthConfig) TraceAuthorizer {
+	return &traceAuthorizer{writeAuthorizer{cfg}}
+}
+
+// ProcessTraces implements the TraceAuthorizer interface.
+func (a *traceAuthorizer) ProcessTraces(ctx context.Context, traces ptrace.Traces) error {
+	var (
+		principal         = auth.PrincipalFromContext(ctx)
+		tenantFromRequest = TenantFromContext(ctx)
+		resourceSpans     = traces.ResourceSpans()
+	)
+	for i := 0; i < resourceSpans.Len(); i++ {
+		if err := a.tagResource(principal, tenantFromRequest, resourceSpans.At(i).Resource().Attributes()); err != nil {
+			return fmt.Errorf("trace-authorizer process: %w", err)
+		}
+	}
+	return nil
+}
+
+// tagResource authorizes the tenant of the resource, and tags the resources
+// without a tenant attribute with the tenant named in the request.
+func (a *writeAuthorizer) tagResource(principal *auth.Principal, tenantFromRequest string, attrs pcommon.Map) error {
+	tenant := tenantFromRequest
+	if v, ok := attrs.Get(TenantLabelKey); ok {
+		switch {
+		case v.AsString() == "":
+			return fmt.Errorf("%s exists with an empty value", TenantLabelKey)
+		case tenantFromRequest != "" && v.AsString() != tenantFromRequest:
+			return errTenantMismatch
+		}
+		tenant = v.AsString()
+	}
+	if err := a.isAuthorized(principal, tenant); err != nil {
+		return err
+	}
+	if tenant != "" {
+		attrs.PutString(TenantLabelKey, tenant)
+	}
+	return nil
+}
+
+// ReadFilter implements the TraceAuthorizer interface. Reads without an
+// authenticated principal cannot read any tenant, and only read the spans
+// without a tenant if non-tenants are allowed.
+func (a *traceAuthorizer) ReadFilter(ctx context.Context) ReadFilter {
+	p := auth.PrincipalFromContext(ctx)
+	if p == nil {
+		return ReadFilter{Tenants: []string{}, NonTenants: a.allowNonTenants()}
+	}
+	if p.RestrictsTenants() {
+		tenants := make([]string, 0, len(p.Tenants))
+		for _, t := range p.Tenants {
+			if a.IsTenantAllowed(t) {
+				tenants = append(tenants, t)
+			}
+		}
+		return ReadFilter{Tenants: tenants}
+	}
+	filter := ReadFilter{NonTenants: a.allowNonTenants()}
+	if _, allowAll := a.AuthConfig.(*AllowAllTenantsConfig); !allowAll {
+		filter.Tenants = append([]string{}, a.ValidTenants()...)
+		sort.Strings(filter.Tenants)
+	}
+	return filter
+}
+
+// TenantFromContext returns the tenant named in the metadata of a gRPC request, if
+// the authenticated principal is allowed to access it. Otherwise, the tenant of the
+// principal is returned when the principal is allowed to access a single tenant.
+func TenantFromContext(ctx context.Context) string {
+	p := auth.PrincipalFromContext(ctx)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("tenant"); len(values) > 0 && values[0] != "" && p.AllowsTenant(values[0]) {
+			return values[0]
+		}
+	}
+	return p.SingleTenant()
+}
@@ -0,0 +1,145 @@
+// This file and its contents are licensed under the Apache License 2.0.
+// Please see the included NOTICE for copyright information and
+// LICENSE for a copy of the license.
+
+package tenancy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/auth"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/grpc/metadata"
+)
+
+func getTraces(tenants ...string) ptrace.Traces {
+	traces := ptrace.NewTraces()
+	for _, tenant := range tenants {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutString("service.name", "service")
+		if tenant != "" {
+			rs.Resource().Attributes().PutString(TenantLabelKey, tenant)
+		}
+	}
+	return traces
+}
+
+func getTenants(traces ptrace.Traces) []string {
+	var tenants []string
+	for i := 0; i < traces.ResourceSpans().Len(); i++ {
+		tenant := ""
+		if v, ok := traces.ResourceSpans().At(i).Resource().Attributes().Get(TenantLabelKey); ok {
+			tenant = v.AsString()
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants
+}
+
+func TestProcessTraces(t *testing.T) {
+	authr := NewTraceAuthorizer(NewSelectiveTenancyConfig([]string{"tenant-a", "tenant-b"}, true, false))
+	ctx := context.Background()
+
+	// Traces without tenants are kept as they are.
+	traces := getTraces("", "tenant-a")
+	require.NoError(t, authr.ProcessTraces(ctx, traces))
+	require.Equal(t, []string{"", "tenant-a"}, getTenants(traces))
+
+	// The tenant of the metadata applies to all resources.
+	tenantCtx := metadata.NewIncomingContext(ctx, metadata.Pairs("tenant", "tenant-b"))
+	traces = getTraces("", "tenant-b")
+	require.NoError(t, authr.ProcessTraces(tenantCtx, traces))
+	require.Equal(t, []string{"tenant-b", "tenant-b"}, getTenants(traces))
+
+	// A resource cannot name another tenant than the metadata.
+	require.Error(t, authr.ProcessTraces(tenantCtx, getTraces("tenant-a")))
+
+	// Invalid tenants are rejected.
+	require.ErrorIs(t, authr.ProcessTraces(ctx, getTraces("tenant-c")), ErrUnauthorizedTenant)
+
+	// Principals can only write their tenants.
+	principalCtx := auth.NewContext(ctx, &auth.Principal{Name: "user", Tenants: []string{"tenant-a"}})
+	traces = getTraces("")
+	require.NoError(t, authr.ProcessTraces(principalCtx, traces))
+	require.Equal(t, []string{"tenant-a"}, getTenants(traces))
+	require.Error(t, authr.ProcessTraces(principalCtx, getTraces("tenant-b")))
+
+	// Non-tenants are rejected unless allowed.
+	authr = NewTraceAuthorizer(NewAllowAllTenantsConfig(false))
+	require.Error(t, authr.ProcessTraces(ctx, getTraces("")))
+	require.NoError(t, authr.ProcessTraces(ctx, getTraces("tenant-c")))
+}
+
+func TestTraceReadFilter(t *testing.T) {
+	ctx := context.Background()
+	principalCtx := auth.NewContext(ctx, &auth.Principal{Name: "user", Tenants: []string{"tenant-b", "tenant-c"}})
+	adminCtx := auth.NewContext(ctx, &auth.Principal{Name: "admin", Tenants: []string{auth.AllTenants}})
+
+	testCases := []struct {
+		name       string
+		cfg        AuthConfig
+		ctx        context.Context
+		filter     ReadFilter
+		restricted bool
+	}{
+		{
+			name:       "unauthenticated",
+			cfg:        NewAllowAllTenantsConfig(true),
+			ctx:        ctx,
+			filter:     ReadFilter{Tenants: []string{}, NonTenants: true},
+			restricted: true,
+		},
+		{
+			name:   "all tenants and non-tenants",
+			cfg:    NewAllowAllTenantsConfig(true),
+			ctx:    adminCtx,
+			filter: ReadFilter{NonTenants: true},
+		},
+		{
+			name:       "all tenants",
+			cfg:        NewAllowAllTenantsConfig(false),
+			ctx:        adminCtx,
+			filter:     ReadFilter{},
+			restricted: true,
+		},
+		{
+			name:       "selected tenants",
+			cfg:        NewSelectiveTenancyConfig([]string{"tenant-b", "tenant-a"}, true, false),
+			ctx:        adminCtx,
+			filter:     ReadFilter{Tenants: []string{"tenant-a", "tenant-b"}, NonTenants: true},
+			restricted: true,
+		},
+		{
+			name:       "tenants of the principal",
+			cfg:        NewSelectiveTenancyConfig([]string{"tenant-a", "tenant-b"}, true, false),
+			ctx:        principalCtx,
+			filter:     ReadFilter{Tenants: []string{"tenant-b"}},
+			restricted: true,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			filter := NewTraceAuthorizer(c.cfg).ReadFilter(c.ctx)
+			require.Equal(t, c.filter, filter)
+			require.Equal(t, c.restricted, filter.Restricted())
+		})
+	}
+}
+
+func TestTenantFromContext(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, "", TenantFromContext(ctx))
+
+	principalCtx := auth.NewContext(ctx, &auth.Principal{Name: "user", Tenants: []string{"tenant-a"}})
+	require.Equal(t, "tenant-a", TenantFromContext(principalCtx))
+
+	md := metadata.Pairs("tenant", "tenant-b")
+	require.Equal(t, "tenant-b", TenantFromContext(metadata.NewIncomingContext(ctx, md)))
+
+	// The metadata cannot name a tenant the principal is not allowed to access.
+	require.Equal(t, "tenant-a", TenantFromContext(metadata.NewIncomingContext(principalCtx, md)))
+	principalCtx = auth.NewContext(ctx, &auth.Principal{Name: "user", Tenants: []string{"tenant-a", "tenant-b"}})
+	require.Equal(t, "tenant-b", TenantFromContext(metadata.NewIncomingContext(principalCtx, md)))
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/timescale/promscale/pkg/auth"
 	"github.com/timescale/promscale/pkg/prompb"
 )
 
@@ -23,35 +24,36 @@ func NewWriteAuthorizer(config AuthConfig) *writeAuthorizer {
 	return &writeAuthorizer{config}
 }
 
-func (a *writeAuthorizer) isAuthorized(tenantName string) error {
-	if a.IsTenantAllowed(tenantName) {
+func (a *writeAuthorizer) isAuthorized(principal *auth.Principal, tenantName string) error {
+	if a.IsTenantAllowed(tenantName) && principal.AllowsTenant(tenantName) {
 		return nil
 	}
 	return fmt.Errorf("authorization error for tenant %s: %w", tenantName, ErrUnauthorizedTenant)
 }
 
-func (a *writeAuthorizer) verifyAndApplyTenantLabel(tenantNameFromHeader string, labels []prompb.Label) ([]prompb.Label, error) {
+func (a *writeAuthorizer) verifyAndApplyTenantLabel(principal *auth.Principal, tenantNameFromHeader string, labels []prompb.Label) ([]prompb.Label, error) {
 	if tenantNameFromHeader != "" {
-		if err := a.isAuthorized(tenantNameFromHeader); err != nil {
+		if err := a.isAuthorized(principal, tenantNameFromHeader); err != nil {
 			return labels, err
 		}
 		return a.getTenantLabelMatchingHeader(tenantNameFromHeader, labels)
 	}
 	tenantNameFromLabels := a.getTenantNameFromLabel(labels)
-	return labels, a.isAuthorized(tenantNameFromLabels)
+	return labels, a.isAuthorized(principal, tenantNameFromLabels)
 }
 
 // Process implements the Preprocessor interface.
 func (a *writeAuthorizer) Process(r *http.Request, wr *prompb.WriteRequest) error {
 	var (
-		tenantFromHeader = getTenant(r)
+		principal        = auth.PrincipalFromContext(r.Context())
+		tenantFromHeader = TenantFromRequest(r)
 		num              = len(wr.Timeseries)
 	)
 	if num == 0 {
 		return nil
 	}
 	for i := 0; i < num; i++ {
-		modifiedLbls, err := a.verifyAndApplyTenantLabel(tenantFromHeader, wr.Timeseries[i].Labels)
+		modifiedLbls, err := a.verifyAndApplyTenantLabel(principal, tenantFromHeader, wr.Timeseries[i].Labels)
 		if err != nil {
 			return fmt.Errorf("write-authorizer process: %w", err)
 		}
@@ -60,9 +62,15 @@ func (a *writeAuthorizer) Process(r *http.Request, wr *prompb.WriteRequest) erro
 	return nil
 }
 
-func getTenant(r *http.Request) string {
+// TenantFromRequest returns the tenant named in the headers of the request. If the
+// headers do not name a tenant, the tenant of the authenticated principal is
+// returned when the principal is allowed to access a single tenant.
+func TenantFromRequest(r *http.Request) string {
 	// We do not look for `X-` since it has been deprecated as mentioned in https://datatracker.ietf.org/doc/html/rfc6648.
-	return r.Header.Get("TENANT")
+	if tenant := r.Header.Get("TENANT"); tenant != "" {
+		return tenant
+	}
+	return auth.PrincipalFromContext(r.Context()).SingleTenant()
 }
 
 func (a *writeAuthorizer) getTenantLabelMatchingHeader(tenantNameFromHeader string, labels []prompb.Label) ([]prompb.Label, error) {
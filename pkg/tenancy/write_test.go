@@ -6,9 +6,12 @@ package tenancy
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/auth"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
 	"github.com/timescale/promscale/pkg/prompb"
 )
@@ -21,7 +24,7 @@ func TestVerifyAndApplyTenantLabel(t *testing.T) {
 
 	// Test with tenant name from header.
 	lblsWithoutTenants := getlbls()
-	newLbls, err := authr.verifyAndApplyTenantLabel(tenantName, lblsWithoutTenants[0])
+	newLbls, err := authr.verifyAndApplyTenantLabel(nil, tenantName, lblsWithoutTenants[0])
 	require.NoError(t, err)
 
 	expectedLbls := [][]prompb.Label{
@@ -37,15 +40,15 @@ func TestVerifyAndApplyTenantLabel(t *testing.T) {
 
 	// Test with tenant name from labels.
 	lblsWithTenants := getlblsWithTenants()
-	_, err = authr.verifyAndApplyTenantLabel("", lblsWithTenants[0])
+	_, err = authr.verifyAndApplyTenantLabel(nil, "", lblsWithTenants[0])
 	require.NoError(t, err)
 
 	// Test with tenant name from labels but with empty value, but having the __tenant__ key.
-	_, err = authr.verifyAndApplyTenantLabel("", lblsWithTenants[2])
+	_, err = authr.verifyAndApplyTenantLabel(nil, "", lblsWithTenants[2])
 	require.Error(t, err)
 
 	// Test with no tenant names (non-MT write).
-	_, err = authr.verifyAndApplyTenantLabel("", lblsWithoutTenants[0])
+	_, err = authr.verifyAndApplyTenantLabel(nil, "", lblsWithoutTenants[0])
 	require.Error(t, err)
 
 	// ----- Test with allow non-MT being true -----.
@@ -53,12 +56,12 @@ func TestVerifyAndApplyTenantLabel(t *testing.T) {
 	authr = NewWriteAuthorizer(conf)
 
 	// Test with tenant name from header.
-	newLbls, err = authr.verifyAndApplyTenantLabel(tenantName, lblsWithoutTenants[0])
+	newLbls, err = authr.verifyAndApplyTenantLabel(nil, tenantName, lblsWithoutTenants[0])
 	require.NoError(t, err)
 	require.Equal(t, expectedLbls[0], newLbls)
 
 	// Test with tenant name from labels.
-	_, err = authr.verifyAndApplyTenantLabel("", lblsWithTenants[0])
+	_, err = authr.verifyAndApplyTenantLabel(nil, "", lblsWithTenants[0])
 	require.NoError(t, err)
 
 	// Test with no tenant names (non-MT write).
@@ -70,7 +73,7 @@ func TestVerifyAndApplyTenantLabel(t *testing.T) {
 			{Name: "empty", Value: ""},
 		},
 	}
-	newLbls, err = authr.verifyAndApplyTenantLabel("", lblsWithoutTenants[0])
+	newLbls, err = authr.verifyAndApplyTenantLabel(nil, "", lblsWithoutTenants[0])
 	require.NoError(t, err)
 	require.Equal(t, expectedLbls[0], newLbls)
 }
@@ -84,10 +87,10 @@ func TestLabelsWithoutTenants(t *testing.T) {
 	// With valid tenants.
 	conf := NewSelectiveTenancyConfig([]string{"tenant-a", "tenant-b"}, false, true)
 	authr := NewWriteAuthorizer(conf)
-	require.NoError(t, authr.isAuthorized(tenantName))
+	require.NoError(t, authr.isAuthorized(nil, tenantName))
 
 	for _, lbls := range lblsArr {
-		lb, err := authr.verifyAndApplyTenantLabel(tenantName, lbls)
+		lb, err := authr.verifyAndApplyTenantLabel(nil, tenantName, lbls)
 		require.NoError(t, err)
 		require.True(t, containsAppliedTenantLabel(lb))
 	}
@@ -95,7 +98,7 @@ func TestLabelsWithoutTenants(t *testing.T) {
 	// Should not verify.
 	conf = NewSelectiveTenancyConfig([]string{"tenant-b"}, false, true)
 	authr = NewWriteAuthorizer(conf)
-	err := authr.isAuthorized(tenantName)
+	err := authr.isAuthorized(nil, tenantName)
 	if expected := fmt.Sprintf("authorization error for tenant tenant-a: %s", ErrUnauthorizedTenant.Error()); err.Error() != expected {
 		require.Fail(t, "error does not match", err)
 	}
@@ -103,20 +106,20 @@ func TestLabelsWithoutTenants(t *testing.T) {
 	// Empty tenant write.
 	conf = NewAllowAllTenantsConfig(false)
 	authr = NewWriteAuthorizer(conf)
-	require.NoError(t, authr.isAuthorized(tenantName))
+	require.NoError(t, authr.isAuthorized(nil, tenantName))
 
 	for _, lbls := range lblsArr {
-		lb, err := authr.verifyAndApplyTenantLabel(tenantName, lbls)
+		lb, err := authr.verifyAndApplyTenantLabel(nil, tenantName, lbls)
 		require.NoError(t, err)
 		require.True(t, containsAppliedTenantLabel(lb))
 	}
 
 	conf = NewAllowAllTenantsConfig(true)
 	authr = NewWriteAuthorizer(conf)
-	require.NoError(t, authr.isAuthorized(""))
+	require.NoError(t, authr.isAuthorized(nil, ""))
 
 	for _, lbls := range lblsArr {
-		lb, err := authr.verifyAndApplyTenantLabel("", lbls)
+		lb, err := authr.verifyAndApplyTenantLabel(nil, "", lbls)
 		require.NoError(t, err)
 		require.True(t, !containsAppliedTenantLabel(lb))
 	}
@@ -130,14 +133,14 @@ func TestLabelsWithTenants(t *testing.T) {
 		conf               = NewSelectiveTenancyConfig([]string{"tenant-a"}, false, true)
 		authr              = NewWriteAuthorizer(conf)
 	)
-	require.NoError(t, authr.isAuthorized(tenantName))
+	require.NoError(t, authr.isAuthorized(nil, tenantName))
 	// Tenant label value and tenant header value same.
-	lb, err := authr.verifyAndApplyTenantLabel(tenantName, lblsArrWithTenants[0])
+	lb, err := authr.verifyAndApplyTenantLabel(nil, tenantName, lblsArrWithTenants[0])
 	require.NoError(t, err)
 	require.True(t, containsAppliedTenantLabel(lb))
 
 	// Tenant label value and tenant header are different.
-	_, err = authr.verifyAndApplyTenantLabel(tenantRandom, lblsArrWithTenants[0])
+	_, err = authr.verifyAndApplyTenantLabel(nil, tenantRandom, lblsArrWithTenants[0])
 	if err.Error() != "authorization error for tenant tenant-random: unauthorized or invalid tenant" {
 		require.Fail(t, "error does not match", err)
 	}
@@ -145,11 +148,11 @@ func TestLabelsWithTenants(t *testing.T) {
 	// Empty tenant write.
 	conf = NewAllowAllTenantsConfig(false)
 	authr = NewWriteAuthorizer(conf)
-	require.NoError(t, authr.isAuthorized(tenantName))
+	require.NoError(t, authr.isAuthorized(nil, tenantName))
 
 	for i, lbls := range lblsArrWithTenants {
 		if i < 2 {
-			lb, err := authr.verifyAndApplyTenantLabel(tenantName, lbls)
+			lb, err := authr.verifyAndApplyTenantLabel(nil, tenantName, lbls)
 			require.NoError(t, err)
 			require.True(t, containsAppliedTenantLabel(lb))
 		}
@@ -157,17 +160,63 @@ func TestLabelsWithTenants(t *testing.T) {
 
 	conf = NewAllowAllTenantsConfig(true)
 	authr = NewWriteAuthorizer(conf)
-	require.NoError(t, authr.isAuthorized(""))
+	require.NoError(t, authr.isAuthorized(nil, ""))
 
 	for i, lbls := range lblsArrWithTenants {
 		if i < 2 {
-			lb, err := authr.verifyAndApplyTenantLabel(tenantName, lbls)
+			lb, err := authr.verifyAndApplyTenantLabel(nil, tenantName, lbls)
 			require.NoError(t, err)
 			require.True(t, containsAppliedTenantLabel(lb))
 		}
 	}
 }
 
+func TestWriteAuthorizerPrincipal(t *testing.T) {
+	authr := NewWriteAuthorizer(NewAllowAllTenantsConfig(false))
+	request := func(tenantHeader string) *http.Request {
+		r := httptest.NewRequest("POST", "/write", nil)
+		if tenantHeader != "" {
+			r.Header.Set("TENANT", tenantHeader)
+		}
+		return r.WithContext(auth.NewContext(r.Context(), &auth.Principal{Name: "user", Tenants: []string{"tenant-a"}}))
+	}
+	writeRequest := func(lbls ...[]prompb.Label) *prompb.WriteRequest {
+		wr := &prompb.WriteRequest{}
+		for _, l := range lbls {
+			wr.Timeseries = append(wr.Timeseries, prompb.TimeSeries{Labels: l})
+		}
+		return wr
+	}
+
+	// The tenant of the principal applies when the header is not set.
+	wr := writeRequest(getlbls()[0])
+	require.NoError(t, authr.Process(request(""), wr))
+	require.True(t, containsAppliedTenantLabel(wr.Timeseries[0].Labels))
+
+	// The principal cannot write to other tenants through the header or the labels.
+	err := authr.Process(request("tenant-b"), writeRequest(getlbls()[0]))
+	require.ErrorIs(t, err, ErrUnauthorizedTenant)
+	err = authr.Process(request("tenant-a"), writeRequest([]prompb.Label{{Name: TenantLabelKey, Value: "tenant-b"}}))
+	require.Error(t, err)
+
+	r := httptest.NewRequest("POST", "/write", nil)
+	r = r.WithContext(auth.NewContext(r.Context(), &auth.Principal{Name: "user", Tenants: []string{"tenant-a", "tenant-b"}}))
+	err = authr.Process(r, writeRequest([]prompb.Label{{Name: TenantLabelKey, Value: "tenant-b"}}, []prompb.Label{{Name: TenantLabelKey, Value: "tenant-c"}}))
+	require.ErrorIs(t, err, ErrUnauthorizedTenant)
+
+	// A principal without tenants cannot write, not even non-tenant data.
+	r = httptest.NewRequest("POST", "/write", nil)
+	r = r.WithContext(auth.NewContext(r.Context(), &auth.Principal{Name: "nobody"}))
+	err = authr.Process(r, writeRequest(getlbls()[0]))
+	require.ErrorIs(t, err, ErrUnauthorizedTenant)
+
+	// A principal allowed all tenants writes to any tenant, and is not applied as a tenant.
+	r = httptest.NewRequest("POST", "/write", nil)
+	r = r.WithContext(auth.NewContext(r.Context(), &auth.Principal{Name: "admin", Tenants: []string{auth.AllTenants}}))
+	require.Equal(t, "", TenantFromRequest(r))
+	require.NoError(t, authr.Process(r, writeRequest([]prompb.Label{{Name: TenantLabelKey, Value: "tenant-c"}})))
+}
+
 func containsAppliedTenantLabel(lbls []prompb.Label) bool {
 	for _, lbl := range lbls {
 		if lbl.Name == TenantLabelKey {
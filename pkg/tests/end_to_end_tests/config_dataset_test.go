@@ -65,6 +65,66 @@ func TestDatasetConfigApply(t *testing.T) {
 	})
 }
 
+func TestDatasetConfigMetricOverrides(t *testing.T) {
+	withDB(t, *testDatabase, func(dbOwner *pgxpool.Pool, t testing.TB) {
+		conn, err := dbOwner.Acquire(context.Background())
+		require.NoError(t, err)
+		defer conn.Release()
+		pgxConn := conn.Conn()
+
+		for _, metric := range []string{"node_load1", "node_load5", "up"} {
+			_, err = pgxConn.Exec(context.Background(), "SELECT _prom_catalog.get_or_create_metric_table_name($1)", metric)
+			require.NoError(t, err)
+		}
+
+		cfg, err := dataset.NewConfig(`metrics:
+  overrides:
+    - metric: node_load1
+      retention_period: 7d
+    - metric_regex: node_.*
+      retention_period: 30d
+      compress_data: false`)
+		require.NoError(t, err)
+		require.NoError(t, cfg.Apply(pgxConn))
+
+		require.Equal(t, 7*24*time.Hour, getMetricRetention(t, pgxConn, "node_load1"))
+		require.Equal(t, 30*24*time.Hour, getMetricRetention(t, pgxConn, "node_load5"))
+		require.Equal(t, 90*24*time.Hour, getMetricRetention(t, pgxConn, "up"))
+		require.False(t, getMetricCompressionSetting(t, pgxConn, "node_load1"))
+		require.False(t, getMetricCompressionSetting(t, pgxConn, "node_load5"))
+		require.True(t, getMetricCompressionSetting(t, pgxConn, "up"))
+
+		// Settings of metrics that are not matched anymore are reset.
+		cfg, err = dataset.NewConfig(`metrics:
+  overrides:
+    - metric: node_load1
+      retention_period: 7d`)
+		require.NoError(t, err)
+		require.NoError(t, cfg.Apply(pgxConn))
+
+		require.Equal(t, 7*24*time.Hour, getMetricRetention(t, pgxConn, "node_load1"))
+		require.Equal(t, 90*24*time.Hour, getMetricRetention(t, pgxConn, "node_load5"))
+		require.True(t, getMetricCompressionSetting(t, pgxConn, "node_load1"))
+		require.True(t, getMetricCompressionSetting(t, pgxConn, "node_load5"))
+	})
+}
+
+func getMetricRetention(t testing.TB, conn *pgx.Conn, metric string) (retention time.Duration) {
+	err := conn.QueryRow(context.Background(), "SELECT _prom_catalog.get_metric_retention_period('prom_data', $1)", metric).Scan(&retention)
+	if err != nil {
+		t.Fatal("error getting metric retention period", err)
+	}
+	return retention
+}
+
+func getMetricCompressionSetting(t testing.TB, conn *pgx.Conn, metric string) (compressionSetting bool) {
+	err := conn.QueryRow(context.Background(), "SELECT _prom_catalog.get_metric_compression_setting($1)", metric).Scan(&compressionSetting)
+	if err != nil {
+		t.Fatal("error getting metric compression setting", err)
+	}
+	return compressionSetting
+}
+
 func getMetricsDefaultChunkInterval(t testing.TB, conn *pgx.Conn) (chunkInterval time.Duration) {
 	err := conn.QueryRow(context.Background(), "SELECT _prom_catalog.get_default_chunk_interval()").Scan(&chunkInterval)
 	if err != nil {
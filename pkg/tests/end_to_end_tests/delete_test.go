@@ -21,6 +21,7 @@ import (
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/stretchr/testify/require"
 	"github.com/timescale/promscale/pkg/internal/testhelpers"
+	pgErrs "github.com/timescale/promscale/pkg/pgmodel/common/errors"
 	pgDel "github.com/timescale/promscale/pkg/pgmodel/delete"
 	ingstr "github.com/timescale/promscale/pkg/pgmodel/ingestor"
 	"github.com/timescale/promscale/pkg/pgmodel/model"
@@ -503,6 +504,102 @@ func TestDeleteMixins(t *testing.T) {
 	})
 }
 
+func TestDeleteJob(t *testing.T) {
+	withDB(t, *testDatabase, func(dbOwner *pgxpool.Pool, t testing.TB) {
+		db := testhelpers.PgxPoolWithRole(t, *testDatabase, "prom_modifier")
+		defer db.Close()
+
+		ingestor, err := ingstr.NewPgxIngestorForTests(pgxconn.NewPgxConn(db), nil)
+		require.NoError(t, err)
+		defer ingestor.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		_, _, err = ingestor.IngestMetrics(ctx, newWriteRequestWithTs(copyMetrics(generateSmallTimeseries())))
+		require.NoError(t, err)
+		require.NoError(t, ingestor.CompleteMetricCreation(ctx))
+
+		jobs := pgDel.NewJobManager(pgxconn.NewPgxConn(db))
+		id, err := jobs.Submit(ctx, []string{`{__name__="firstMetric"}`, `{__name__="secondMetric"}`}, model.MinTime, model.MaxTime)
+		require.NoError(t, err)
+
+		go func() {
+			_ = jobs.Run(ctx)
+		}()
+		var job pgDel.Job
+		require.Eventually(t, func() bool {
+			job, err = jobs.Get(ctx, id)
+			require.NoError(t, err)
+			return job.Status != pgDel.JobPending && job.Status != pgDel.JobRunning
+		}, 30*time.Second, 100*time.Millisecond)
+		require.Equal(t, pgDel.JobSucceeded, job.Status)
+		require.Nil(t, job.Start)
+		require.Nil(t, job.End)
+		require.Equal(t, 2, job.MetricsTotal)
+		require.Equal(t, 2, job.MetricsDone)
+		require.Equal(t, int64(2), job.SeriesDeleted)
+		require.Equal(t, int64(10), job.RowsDeleted)
+
+		_, err = jobs.Cancel(ctx, id)
+		require.Equal(t, pgErrs.ErrDeleteJobFinished, err)
+	})
+}
+
+func TestDeleteSeriesInTimeRange(t *testing.T) {
+	if *useMultinode {
+		t.Skip("time-range deletion decompresses chunks locally, which is not supported for distributed hypertables")
+	}
+	for _, compressed := range []bool{false, true} {
+		withDB(t, *testDatabase, func(dbOwner *pgxpool.Pool, t testing.TB) {
+			db := testhelpers.PgxPoolWithRole(t, *testDatabase, "prom_modifier")
+			defer db.Close()
+
+			ingestor, err := ingstr.NewPgxIngestorForTests(pgxconn.NewPgxConn(db), nil)
+			require.NoError(t, err)
+			defer ingestor.Close()
+			ctx := context.Background()
+			_, _, err = ingestor.IngestMetrics(ctx, newWriteRequestWithTs(copyMetrics(generateSmallTimeseries())))
+			require.NoError(t, err)
+			require.NoError(t, ingestor.CompleteMetricCreation(ctx))
+
+			var tableName string
+			err = dbOwner.QueryRow(ctx, "SELECT table_name from _prom_catalog.metric WHERE metric_name=$1", "firstMetric").Scan(&tableName)
+			require.NoError(t, err)
+			if compressed {
+				_, err = dbOwner.Exec(ctx, fmt.Sprintf("SELECT public.compress_chunk(i) from public.show_chunks('prom_data.\"%s\"') i;", tableName))
+				require.NoError(t, err)
+			}
+
+			matcher, err := getMatchers(`{__name__="firstMetric"}`)
+			require.NoError(t, err)
+			pgDelete := &pgDel.PgDelete{Conn: pgxconn.NewPgxConn(db)}
+			touchedMetrics, seriesIDs, rowsDeleted, err := pgDelete.DeleteSeries(ctx, matcher, time.UnixMilli(2), time.UnixMilli(4))
+			require.NoError(t, err)
+			require.Equal(t, []string{"firstMetric"}, touchedMetrics)
+			require.Len(t, seriesIDs, 1)
+			require.Equal(t, map[string]int{"firstMetric": 3}, rowsDeleted)
+
+			var (
+				remaining []int64
+				numSeries int
+			)
+			rows, err := dbOwner.Query(ctx, fmt.Sprintf("SELECT time FROM prom_data.\"%s\" ORDER BY time", tableName))
+			require.NoError(t, err)
+			for rows.Next() {
+				var ts time.Time
+				require.NoError(t, rows.Scan(&ts))
+				remaining = append(remaining, ts.UnixMilli())
+			}
+			require.NoError(t, rows.Err())
+			require.Equal(t, []int64{1, 5}, remaining)
+
+			// The series is kept.
+			err = dbOwner.QueryRow(ctx, fmt.Sprintf("SELECT count(*) FROM prom_data_series.\"%s\"", tableName)).Scan(&numSeries)
+			require.NoError(t, err)
+			require.Equal(t, 1, numSeries)
+		})
+	}
+}
+
 var (
 	minTime          = time.Unix(math.MinInt64/1000+62135596801, 0).UTC()
 	maxTime          = time.Unix(math.MaxInt64/1000-62135596801, 999999999).UTC()
@@ -209,7 +209,7 @@ func TestExemplarQueryingAPI(t *testing.T) {
 			pgxconn.NewPgxConn(db),
 			cache.NewMetricCache(cache.DefaultConfig),
 			labelsReader,
-			cache.NewExemplarLabelsPosCache(cache.DefaultConfig), nil)
+			cache.NewExemplarLabelsPosCache(cache.DefaultConfig), nil, nil)
 		queryable := query.NewQueryable(r, labelsReader)
 
 		// Query all exemplars corresponding to metric_2 histogram.
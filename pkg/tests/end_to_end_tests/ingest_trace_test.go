@@ -80,7 +80,7 @@ func TestQueryTraces(t *testing.T) {
 		err = ingestor.IngestTraces(context.Background(), fixtures.traces)
 		require.NoError(t, err)
 
-		q := store.New(pgxconn.NewQueryLoggingPgxConn(db), ingestor, &store.DefaultConfig)
+		q := store.New(pgxconn.NewQueryLoggingPgxConn(db), ingestor, &store.DefaultConfig, nil)
 
 		getOperationsTest(t, q)
 		findTraceTest(t, q, fixtures)
@@ -71,7 +71,7 @@ func TestJaegerStorageIntegration(t *testing.T) {
 
 				jaegerStore := jaegerstore.New(pgxconn.NewQueryLoggingPgxConn(db), ingestor, &store.Config{
 					MaxTraceDuration: 17 * time.Hour, // FindTraces/Trace_spans_over_multiple_indices test has events which has timestamp difference of ~17hrs when comparing to span.
-				})
+				}, nil)
 				writer := jaegerStore.SpanWriter()
 				if c.streaming {
 					writer = jaegerStore.StreamingSpanWriter()
@@ -19,7 +19,7 @@ func TestJaegerSpanIngestion(t *testing.T) {
 		require.NoError(t, err)
 		defer ingestor.Close()
 
-		jaegerStore := jaegerstore.New(pgxconn.NewQueryLoggingPgxConn(db), ingestor, &store.DefaultConfig)
+		jaegerStore := jaegerstore.New(pgxconn.NewQueryLoggingPgxConn(db), ingestor, &store.DefaultConfig, nil)
 
 		fixtures, err := getTracesFixtures()
 		if err != nil {
@@ -321,7 +321,7 @@ func buildRouterWithAPIConfig(pool *pgxpool.Pool, cfg *api.Config, authWrapper m
 		return nil, nil, fmt.Errorf("init promql engine: %w", err)
 	}
 
-	router, err := api.GenerateRouter(cfg, qryCfg, pgClient, nil, authWrapper, nil)
+	router, err := api.GenerateRouter(cfg, qryCfg, pgClient, api.NewWriteParser(cfg, pgClient), nil, authWrapper, nil)
 	if err != nil {
 		return nil, nil, fmt.Errorf("generate router: %w", err)
 	}
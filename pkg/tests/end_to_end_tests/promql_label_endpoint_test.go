@@ -5,6 +5,7 @@
 package end_to_end_tests
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -111,7 +112,7 @@ func TestPromQLLabelEndpoint(t *testing.T) {
 		lCache := clockcache.WithMax(100)
 		dbConn := pgxconn.NewPgxConn(readOnly)
 		labelsReader := lreader.NewLabelsReader(dbConn, lCache, noopReadAuthorizer)
-		labelNames, err := labelsReader.LabelNames()
+		labelNames, err := labelsReader.LabelNames(context.Background(), lreader.LabelsQuery{})
 		if err != nil {
 			t.Fatalf("could not get label names from querier")
 		}
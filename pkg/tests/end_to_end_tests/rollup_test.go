@@ -0,0 +1,129 @@
+package end_to_end_tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+	"github.com/timescale/promscale/pkg/clockcache"
+	"github.com/timescale/promscale/pkg/dataset"
+	"github.com/timescale/promscale/pkg/log"
+	"github.com/timescale/promscale/pkg/pgmodel/cache"
+	"github.com/timescale/promscale/pkg/pgmodel/lreader"
+	"github.com/timescale/promscale/pkg/pgmodel/querier"
+	"github.com/timescale/promscale/pkg/pgmodel/rollup"
+	"github.com/timescale/promscale/pkg/pgxconn"
+	"github.com/timescale/promscale/pkg/promql"
+	"github.com/timescale/promscale/pkg/query"
+)
+
+func TestRollups(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	if *useMultinode {
+		t.Skip("rollups are not supported in multinode TimescaleDB setup")
+	}
+	withDB(t, *testDatabase, func(dbOwner *pgxpool.Pool, t testing.TB) {
+		ctx := context.Background()
+		conn, err := dbOwner.Acquire(ctx)
+		require.NoError(t, err)
+		defer conn.Release()
+
+		cfg, err := dataset.NewConfig(`metrics:
+  rollups:
+    - name: 1m
+      resolution: 1m
+      retention_period: 30d`)
+		require.NoError(t, err)
+		require.NoError(t, cfg.Apply(conn.Conn()))
+
+		// Two buckets of 4 samples each.
+		base := time.Now().Add(-time.Hour).Truncate(time.Minute)
+		var (
+			tableName string
+			seriesID  int64
+		)
+		err = dbOwner.QueryRow(ctx, "SELECT * FROM _prom_catalog.get_or_create_series_id_for_kv_array($1, array['__name__', 'job'], array[$1, 'test'])", "rollup_metric").Scan(&tableName, &seriesID)
+		require.NoError(t, err)
+		for i := 0; i < 8; i++ {
+			_, err = dbOwner.Exec(ctx, fmt.Sprintf(`INSERT INTO prom_data.%q (time, value, series_id) VALUES ($1, $2, $3)`, tableName),
+				base.Add(time.Duration(i)*15*time.Second), float64(i), seriesID)
+			require.NoError(t, err)
+		}
+
+		var previous, refreshedUntil time.Time
+		for {
+			err = dbOwner.QueryRow(ctx, "SELECT _prom_catalog.refresh_metric_rollup('1m', $1, now(), '1 day')", tableName).Scan(&refreshedUntil)
+			require.NoError(t, err)
+			if refreshedUntil.Equal(previous) {
+				break
+			}
+			previous = refreshedUntil
+		}
+
+		type bucket struct {
+			Time                 time.Time
+			Value, Sum, Min, Max float64
+			Count                int64
+		}
+		var buckets []bucket
+		rows, err := dbOwner.Query(ctx, fmt.Sprintf(`SELECT time, value, sum, min, max, count FROM prom_data_rollup_1m.%q ORDER BY time`, tableName))
+		require.NoError(t, err)
+		for rows.Next() {
+			var b bucket
+			require.NoError(t, rows.Scan(&b.Time, &b.Value, &b.Sum, &b.Min, &b.Max, &b.Count))
+			b.Time = b.Time.UTC()
+			buckets = append(buckets, b)
+		}
+		require.NoError(t, rows.Err())
+		require.Equal(t, []bucket{
+			{Time: base.UTC(), Value: 3, Sum: 6, Min: 0, Max: 3, Count: 4},
+			{Time: base.Add(time.Minute).UTC(), Value: 7, Sum: 22, Min: 4, Max: 7, Count: 4},
+		}, buckets)
+
+		// Without the raw samples, range queries can only be answered from the rollup.
+		_, err = dbOwner.Exec(ctx, fmt.Sprintf(`DELETE FROM prom_data.%q`, tableName))
+		require.NoError(t, err)
+
+		dbConn := pgxconn.NewPgxConn(dbOwner)
+		labelsReader := lreader.NewLabelsReader(dbConn, clockcache.WithMax(100), noopReadAuthorizer)
+		queryEngine, err := query.NewEngine(log.GetLogger(), time.Minute, 5*time.Minute, time.Minute, 50000000, nil)
+		require.NoError(t, err)
+		rangeQuery := func(r *rollup.Router) promql.Matrix {
+			mCache := &cache.MetricNameCache{Metrics: clockcache.WithMax(cache.DefaultMetricCacheSize)}
+			queryable := query.NewQueryable(querier.NewQuerier(dbConn, mCache, labelsReader, nil, nil, r), labelsReader)
+			qry, err := queryEngine.NewRangeQuery(queryable, nil, "rollup_metric", base, base.Add(time.Minute), time.Minute)
+			require.NoError(t, err)
+			res := qry.Exec(ctx)
+			require.NoError(t, res.Err)
+			matrix, err := res.Matrix()
+			require.NoError(t, err)
+			return matrix
+		}
+
+		require.Empty(t, rangeQuery(nil))
+		require.Equal(t, promql.Matrix{
+			promql.Series{
+				Metric: labels.FromStrings("__name__", "rollup_metric", "job", "test"),
+				Points: []promql.Point{
+					{T: base.UnixMilli(), V: 3},
+					{T: base.Add(time.Minute).UnixMilli(), V: 7},
+				},
+			},
+		}, rangeQuery(rollup.NewRouter(dbConn)))
+
+		// Removing the rollup from the configuration drops it.
+		cfg, err = dataset.NewConfig("")
+		require.NoError(t, err)
+		require.NoError(t, cfg.Apply(conn.Conn()))
+		var exists bool
+		err = dbOwner.QueryRow(ctx, "SELECT EXISTS (SELECT FROM pg_namespace WHERE nspname = 'prom_data_rollup_1m')").Scan(&exists)
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+}
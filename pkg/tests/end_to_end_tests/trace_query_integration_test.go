@@ -116,7 +116,7 @@ func TestCompareTraceQueryResponse(t *testing.T) {
 		// Start Promscale's HTTP endpoint for Jaeger query.
 		router, _, err := buildRouter(db)
 		require.NoError(t, err)
-		jaegerStore := jaegerstore.New(pgxconn.NewPgxConn(db), ingestor, &jaegerstore.DefaultConfig)
+		jaegerStore := jaegerstore.New(pgxconn.NewPgxConn(db), ingestor, &jaegerstore.DefaultConfig, nil)
 		promscaleJaeger.ExtendQueryAPIs(router, pgxconn.NewPgxConn(db), jaegerStore)
 
 		// Bind to the server port. This must be outside of the goroutine below